@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/app/alert"
+	"analytics-api/internal/app/mobile"
+
+	"github.com/spf13/cobra"
+)
+
+// alertCmd tasks of the alert templates
+func alertCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "alert",
+		Short: "Manage the alert templates of websites",
+	}
+	cmd.AddCommand(alertEvaluateCmd())
+	return cmd
+}
+
+// alertEvaluateCmd evaluate the alert templates once, for tenants the server
+// does not evaluate
+func alertEvaluateCmd() *cobra.Command {
+	var tenantID string
+	cmd := &cobra.Command{
+		Use:   "evaluate",
+		Short: "Apply the alert templates to their websites and notify the alerts started or stopped",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db.NewMongo()
+			if configs.UsesClickHouse() {
+				db.NewClickHouse()
+			}
+			store := db.DefaultStore()
+			if tenantID != "" {
+				store = db.TenantStore(tenantID, 0)
+			}
+
+			sent, err := alert.NewUseCase(store).Evaluate(mobile.NewUseCase(store))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("sent %d alert notifications\n", sent)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&tenantID, "tenant", "", "evaluate the alert templates of a tenant")
+	return cmd
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/app/archive"
+
+	"github.com/spf13/cobra"
+)
+
+// archiveCmd tasks of the S3 archive of events
+func archiveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "archive",
+		Short: "Manage the S3 archive of events",
+	}
+	cmd.AddCommand(archiveRunCmd())
+	return cmd
+}
+
+// archiveRunCmd archive a day once, for tenants the server does not archive
+// and to backfill days
+func archiveRunCmd() *cobra.Command {
+	var tenantID, day string
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Archive the events of a day, by default the days since the latest archived, and the days corrected or with late events since they were archived",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var at time.Time
+			if day != "" {
+				var err error
+				if at, err = time.Parse("2006-01-02", day); err != nil {
+					return fmt.Errorf("day must be a date like 2006-01-02")
+				}
+			}
+			db.NewMongo()
+			if configs.UsesClickHouse() {
+				db.NewClickHouse()
+			}
+			store := db.DefaultStore()
+			if tenantID != "" {
+				store = db.TenantStore(tenantID, 0)
+			}
+
+			if day == "" {
+				count, err := archive.NewUseCase(store).CatchUp(time.Now())
+				if err != nil {
+					return err
+				}
+				fmt.Printf("archived the missing days of %d websites\n", count)
+			} else {
+				count, err := archive.NewUseCase(store).ArchiveDay(at)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("archived %s of %d websites\n", at.Format("2006-01-02"), count)
+			}
+			count, err := archive.NewUseCase(store).ArchiveDirty()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("archived again %d changed days\n", count)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&tenantID, "tenant", "", "archive the events of a tenant")
+	cmd.Flags().StringVar(&day, "day", "", "day to archive, like 2006-01-02, instead of the missing days")
+	return cmd
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"analytics-api/db"
+
+	"github.com/spf13/cobra"
+)
+
+const dateLayout = "2006-01-02"
+
+// backupCmd export management data and optionally events to an archive
+func backupCmd() *cobra.Command {
+	var output, from, to string
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Export users, websites and optionally sessions of a date range",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var opts db.BackupOptions
+			if from != "" {
+				eventsFrom, err := time.Parse(dateLayout, from)
+				if err != nil {
+					return fmt.Errorf("invalid --from: %w", err)
+				}
+				opts.EventsFrom = &eventsFrom
+			}
+			if to != "" {
+				if from == "" {
+					return fmt.Errorf("--to requires --from")
+				}
+				eventsTo, err := time.Parse(dateLayout, to)
+				if err != nil {
+					return fmt.Errorf("invalid --to: %w", err)
+				}
+				// include the whole last day
+				eventsTo = eventsTo.AddDate(0, 0, 1)
+				opts.EventsTo = &eventsTo
+			}
+
+			file, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			db.NewMongo()
+			manifest, err := db.Backup(file, opts)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("backup written to %s: %v\n", output, manifest.Collections)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "backup.tar.gz", "archive file to write")
+	cmd.Flags().StringVar(&from, "from", "", "include sessions from this date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&to, "to", "", "include sessions until this date (YYYY-MM-DD)")
+	return cmd
+}
+
+// restoreCmd import an archive written by backup
+func restoreCmd() *cobra.Command {
+	var input string
+	var drop bool
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Import an archive written by backup",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, err := os.Open(input)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			db.NewMongo()
+			manifest, err := db.Restore(file, drop)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("restored backup created at %s: %v\n", manifest.CreatedAt.Format(time.RFC3339), manifest.Collections)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&input, "input", "i", "backup.tar.gz", "archive file to read")
+	cmd.Flags().BoolVar(&drop, "drop", false, "drop existing collections in the archive before import")
+	return cmd
+}
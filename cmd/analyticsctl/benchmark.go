@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/app/benchmark"
+
+	"github.com/spf13/cobra"
+)
+
+// benchmarkCmd tasks of the category benchmarks
+func benchmarkCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "benchmark",
+		Short: "Manage the benchmarks of website categories",
+	}
+	cmd.AddCommand(benchmarkComputeCmd())
+	return cmd
+}
+
+// benchmarkComputeCmd compute the benchmarks once, for tenants the server
+// does not compute and right after turning them on
+func benchmarkComputeCmd() *cobra.Command {
+	var tenantID string
+	cmd := &cobra.Command{
+		Use:   "compute",
+		Short: "Compute the median bounce rate and visit duration of each category",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db.NewMongo()
+			if configs.UsesClickHouse() {
+				db.NewClickHouse()
+			}
+			store := db.DefaultStore()
+			if tenantID != "" {
+				store = db.TenantStore(tenantID, 0)
+			}
+
+			count, err := benchmark.NewUseCase(store).ComputeBenchmarks()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("computed benchmarks of %d categories\n", count)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&tenantID, "tenant", "", "compute the benchmarks of a tenant")
+	return cmd
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/crm"
+
+	"github.com/spf13/cobra"
+)
+
+// crmCmd tasks of the CRM integrations
+func crmCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "crm",
+		Short: "Manage pushes to CRMs",
+	}
+	cmd.AddCommand(crmRetryCmd())
+	return cmd
+}
+
+// crmRetryCmd retry due pushes once, for tenants whose pushes the server does not retry
+func crmRetryCmd() *cobra.Command {
+	var tenantID string
+	cmd := &cobra.Command{
+		Use:   "retry",
+		Short: "Retry the queued CRM pushes that are due",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db.NewMongo()
+			store := db.DefaultStore()
+			if tenantID != "" {
+				store = db.TenantStore(tenantID, 0)
+			}
+
+			sent, failed, err := crm.NewUseCase(store).Retry()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("sent %d pushes, %d failed again\n", sent, failed)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&tenantID, "tenant", "", "retry the pushes of a tenant")
+	return cmd
+}
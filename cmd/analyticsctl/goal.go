@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/goal"
+
+	"github.com/spf13/cobra"
+)
+
+// goalCmd tasks of the goals of websites
+func goalCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "goal",
+		Short: "Manage the goals of websites",
+	}
+	cmd.AddCommand(goalPurgeCmd())
+	return cmd
+}
+
+// goalPurgeCmd empty the goal trash once, for tenants whose trash the server
+// does not empty
+func goalPurgeCmd() *cobra.Command {
+	var tenantID string
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Remove the goals deleted for longer than the trash window",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db.NewMongo()
+			store := db.DefaultStore()
+			if tenantID != "" {
+				store = db.TenantStore(tenantID, 0)
+			}
+
+			purged, err := goal.NewUseCase(store).PurgeTrash()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("purged %d goals\n", purged)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&tenantID, "tenant", "", "purge the goal trash of a tenant")
+	return cmd
+}
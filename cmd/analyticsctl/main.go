@@ -0,0 +1,21 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "analyticsctl",
+	Short: "Admin tasks for a self-hosted analytics instance",
+	// silence usage so errors from the usecase layer are not buried under help text
+	SilenceUsage: true,
+}
+
+func main() {
+	rootCmd.AddCommand(userCmd(), websiteCmd(), migrateCmd(), pruneCmd(), backupCmd(), restoreCmd(), storageCmd(), crmCmd(), archiveCmd(), usageCmd(), benchmarkCmd(), alertCmd(), goalCmd(), reingestCmd())
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"analytics-api/configs"
+	"analytics-api/db"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// migrateCmd create collections and indexes
+func migrateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "migrate",
+		Short: "Create collections and indexes if not exists",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db.NewMongo()
+			if err := db.Migrate(configs.MongoDB.Client); err != nil {
+				return err
+			}
+			logrus.Info("migrate done")
+			return nil
+		},
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/session"
+	"analytics-api/internal/app/website"
+
+	"github.com/spf13/cobra"
+)
+
+// pruneCmd delete old collected data
+func pruneCmd() *cobra.Command {
+	var days int
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete sessions older than the given number of days",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if days <= 0 {
+				return fmt.Errorf("days must be greater than 0")
+			}
+			db.NewMongo()
+
+			// sessions of websites under legal hold are kept
+			held, err := website.NewUseCase(db.DefaultStore()).HeldWebsiteIDs()
+			if err != nil {
+				return err
+			}
+			before := time.Now().AddDate(0, 0, -days)
+			count, err := session.NewUseCase(db.DefaultStore()).DeleteSessionBefore(before, held)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("deleted %d session documents reported before %s\n", count, before.Format("2006-01-02"))
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&days, "days", 180, "keep sessions of the last days")
+	return cmd
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/app/reingest"
+
+	"github.com/spf13/cobra"
+)
+
+// reingestCmd tasks of the corrections of stored events
+func reingestCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reingest",
+		Short: "Manage the corrections of stored events",
+	}
+	cmd.AddCommand(reingestRunCmd())
+	return cmd
+}
+
+// reingestRunCmd run the pending jobs once, for tenants whose jobs the server
+// does not run
+func reingestRunCmd() *cobra.Command {
+	var tenantID string
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Replace the stored events corrected by the pending re-ingestion jobs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db.NewMongo()
+			if configs.UsesClickHouse() {
+				db.NewClickHouse()
+			}
+			store := db.DefaultStore()
+			if tenantID != "" {
+				store = db.TenantStore(tenantID, 0)
+			}
+
+			count, err := reingest.NewUseCase(store).RunJobs()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("ran %d re-ingestion jobs\n", count)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&tenantID, "tenant", "", "run the re-ingestion jobs of a tenant")
+	return cmd
+}
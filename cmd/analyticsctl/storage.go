@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/app/session"
+
+	"github.com/spf13/cobra"
+)
+
+// storageCmd tasks of the event storage migration
+func storageCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "storage",
+		Short: "Manage the migration of events between Mongo and ClickHouse",
+	}
+	cmd.AddCommand(storageCheckCmd())
+	return cmd
+}
+
+// storageCheckCmd report days whose event count differs between backends
+func storageCheckCmd() *cobra.Command {
+	var from, to, tenantID string
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Compare event counts per website per day of Mongo and ClickHouse",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fromDate, err := time.Parse(dateLayout, from)
+			if err != nil {
+				return fmt.Errorf("invalid --from: %w", err)
+			}
+			toDate := time.Now().UTC()
+			if to != "" {
+				toDate, err = time.Parse(dateLayout, to)
+				if err != nil {
+					return fmt.Errorf("invalid --to: %w", err)
+				}
+				// include the whole last day
+				toDate = toDate.AddDate(0, 0, 1)
+			}
+			if configs.ClickHouse.URL == "" {
+				return fmt.Errorf("CLICKHOUSE_URL is not set")
+			}
+
+			db.NewMongo()
+			db.NewClickHouse()
+			store := db.DefaultStore()
+			if tenantID != "" {
+				store = db.TenantStore(tenantID, 0)
+			}
+
+			listDivergence, err := session.CheckConsistency(store, fromDate, toDate)
+			if err != nil {
+				return err
+			}
+			for _, divergence := range listDivergence {
+				fmt.Printf("%s\t%s\tmongo=%d\tclickhouse=%d\n",
+					divergence.Day, divergence.WebsiteID, divergence.Mongo, divergence.ClickHouse)
+			}
+			if len(listDivergence) > 0 {
+				return fmt.Errorf("%d website days diverge", len(listDivergence))
+			}
+			fmt.Println("storage backends are consistent")
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "check events from this date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&to, "to", "", "check events until this date (YYYY-MM-DD), default today")
+	cmd.Flags().StringVar(&tenantID, "tenant", "", "check the database of a tenant")
+	cmd.MarkFlagRequired("from")
+	return cmd
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/mobile"
+	"analytics-api/internal/app/usage"
+
+	"github.com/spf13/cobra"
+)
+
+// usageCmd tasks of the monthly event quota
+func usageCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Manage the monthly event quota of websites",
+	}
+	cmd.AddCommand(usageAlertsCmd())
+	return cmd
+}
+
+// usageAlertsCmd send pending quota alerts once, for tenants whose alerts the
+// server does not send
+func usageAlertsCmd() *cobra.Command {
+	var tenantID string
+	cmd := &cobra.Command{
+		Use:   "alerts",
+		Short: "Notify owners of websites past 80% or 100% of their quota",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db.NewMongo()
+			store := db.DefaultStore()
+			if tenantID != "" {
+				store = db.TenantStore(tenantID, 0)
+			}
+
+			sent, err := usage.NewUseCase(store).SendAlerts(mobile.NewUseCase(store))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("sent %d alerts\n", sent)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&tenantID, "tenant", "", "send the alerts of a tenant")
+	return cmd
+}
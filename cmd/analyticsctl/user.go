@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/user"
+
+	"github.com/spf13/cobra"
+)
+
+// userCmd manage users
+func userCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "user",
+		Short: "Manage users",
+	}
+	cmd.AddCommand(userCreateCmd(), userResetPasswordCmd(), userSetRoleCmd(), userSetPlanCmd(), userDisableTwoFactorCmd())
+	return cmd
+}
+
+func userCreateCmd() *cobra.Command {
+	var email, fullName, password string
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a new user",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db.NewMongo()
+			userUseCase := user.NewUseCase(db.DefaultStore())
+
+			count, err := userUseCase.FindUser(email)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("email %s already exists", email)
+			}
+
+			userID, err := userUseCase.CreateUser(email, fullName, password)
+			if err != nil {
+				return err
+			}
+			fmt.Println(userID)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&email, "email", "", "email of user")
+	cmd.Flags().StringVar(&fullName, "fullname", "", "full name of user")
+	cmd.Flags().StringVar(&password, "password", "", "password of user")
+	_ = cmd.MarkFlagRequired("email")
+	_ = cmd.MarkFlagRequired("password")
+	return cmd
+}
+
+func userResetPasswordCmd() *cobra.Command {
+	var email, password string
+	cmd := &cobra.Command{
+		Use:   "reset-password",
+		Short: "Set a new password for user",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db.NewMongo()
+			return user.NewUseCase(db.DefaultStore()).ResetPassword(email, password)
+		},
+	}
+	cmd.Flags().StringVar(&email, "email", "", "email of user")
+	cmd.Flags().StringVar(&password, "password", "", "new password of user")
+	_ = cmd.MarkFlagRequired("email")
+	_ = cmd.MarkFlagRequired("password")
+	return cmd
+}
+
+// userSetRoleCmd make a user owner, admin or viewer: admins cannot delete or
+// transfer websites, viewers only see reports
+func userSetRoleCmd() *cobra.Command {
+	var email, role, tenantID string
+	cmd := &cobra.Command{
+		Use:   "set-role",
+		Short: "Set the role of user, owner, admin or viewer",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db.NewMongo()
+			store := db.DefaultStore()
+			if tenantID != "" {
+				store = db.TenantStore(tenantID, 0)
+			}
+			return user.NewUseCase(store).UpdateRole(email, role)
+		},
+	}
+	cmd.Flags().StringVar(&email, "email", "", "email of user")
+	cmd.Flags().StringVar(&role, "role", "", "owner, admin or viewer")
+	cmd.Flags().StringVar(&tenantID, "tenant", "", "user of a tenant")
+	_ = cmd.MarkFlagRequired("email")
+	_ = cmd.MarkFlagRequired("role")
+	return cmd
+}
+
+// userSetPlanCmd set the plan of a user or an organization, which limits how
+// many websites it may add
+func userSetPlanCmd() *cobra.Command {
+	var email, orgID, plan, tenantID string
+	cmd := &cobra.Command{
+		Use:   "set-plan",
+		Short: "Set the plan of user or organization, free, pro or agency",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db.NewMongo()
+			store := db.DefaultStore()
+			if tenantID != "" {
+				store = db.TenantStore(tenantID, 0)
+			}
+			if orgID != "" {
+				return user.NewUseCase(store).UpdateOrgPlan(orgID, plan)
+			}
+			return user.NewUseCase(store).UpdatePlan(email, plan)
+		},
+	}
+	cmd.Flags().StringVar(&email, "email", "", "email of user")
+	cmd.Flags().StringVar(&orgID, "org", "", "id of organization")
+	cmd.Flags().StringVar(&plan, "plan", "", "free, pro or agency")
+	cmd.Flags().StringVar(&tenantID, "tenant", "", "user of a tenant")
+	cmd.MarkFlagsOneRequired("email", "org")
+	cmd.MarkFlagsMutuallyExclusive("email", "org")
+	_ = cmd.MarkFlagRequired("plan")
+	return cmd
+}
+
+// userDisableTwoFactorCmd turn two factor authentication off for a user who
+// lost both their authenticator app and their recovery codes
+func userDisableTwoFactorCmd() *cobra.Command {
+	var email, tenantID string
+	cmd := &cobra.Command{
+		Use:   "disable-2fa",
+		Short: "Turn two factor authentication off for user",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db.NewMongo()
+			store := db.DefaultStore()
+			if tenantID != "" {
+				store = db.TenantStore(tenantID, 0)
+			}
+			return user.NewUseCase(store).ResetTwoFactor(email)
+		},
+	}
+	cmd.Flags().StringVar(&email, "email", "", "email of user")
+	cmd.Flags().StringVar(&tenantID, "tenant", "", "user of a tenant")
+	_ = cmd.MarkFlagRequired("email")
+	return cmd
+}
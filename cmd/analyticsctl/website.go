@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/app/mobile"
+	"analytics-api/internal/app/website"
+
+	"github.com/spf13/cobra"
+)
+
+// websiteCmd manage websites
+func websiteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "website",
+		Short: "Manage websites",
+	}
+	cmd.AddCommand(websiteListCmd())
+	cmd.AddCommand(websitePurgeCmd())
+	cmd.AddCommand(websiteInstallCheckCmd())
+	cmd.AddCommand(websiteActivationCmd())
+	return cmd
+}
+
+func websiteListCmd() *cobra.Command {
+	var userID string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List websites, of all users or of one user",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db.NewMongo()
+			websiteUseCase := website.NewUseCase(db.DefaultStore())
+
+			websites, err := websiteUseCase.ListWebsite()
+			if userID != "" {
+				websites, err = websiteUseCase.GetAllWebsite(userID)
+			}
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tUSER ID\tHOST NAME\tCREATED AT")
+			for _, aWebsite := range *websites {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", aWebsite.ID, aWebsite.UserID, aWebsite.HostName, aWebsite.CreatedAt)
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&userID, "user-id", "", "only list websites of this user")
+	return cmd
+}
+
+// websiteActivationCmd count the websites that reached each onboarding step
+func websiteActivationCmd() *cobra.Command {
+	var tenantID string
+	cmd := &cobra.Command{
+		Use:   "activation",
+		Short: "Count the websites that reached each step of the onboarding checklist",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db.NewMongo()
+			store := db.DefaultStore()
+			if tenantID != "" {
+				store = db.TenantStore(tenantID, 0)
+			}
+
+			anActivation, err := website.NewUseCase(store).GetActivation()
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "STEP\tWEBSITES\tPERCENT")
+			for _, step := range website.OnboardingSteps {
+				percent := 0.0
+				if anActivation.Websites > 0 {
+					percent = float64(anActivation.Steps[step]) * 100 / float64(anActivation.Websites)
+				}
+				fmt.Fprintf(w, "%s\t%d\t%.1f\n", step, anActivation.Steps[step], percent)
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&tenantID, "tenant", "", "count the websites of a tenant")
+	return cmd
+}
+
+// websitePurgeCmd remove the data of deleted websites once, for tenants whose
+// deletions the server does not run
+func websitePurgeCmd() *cobra.Command {
+	var tenantID string
+	var retry bool
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Remove the sessions, events and other data of deleted websites",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db.NewMongo()
+			if configs.UsesClickHouse() {
+				db.NewClickHouse()
+			}
+			store := db.DefaultStore()
+			if tenantID != "" {
+				store = db.TenantStore(tenantID, 0)
+			}
+
+			useCase := website.NewUseCase(store)
+			if retry {
+				retried, err := useCase.RetryFailedDeletions()
+				if err != nil {
+					return err
+				}
+				fmt.Printf("retrying %d deletions out of attempts\n", retried)
+			}
+			purged, failed, err := useCase.PurgeDeleted()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("purged %d websites, %d failed\n", purged, failed)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&tenantID, "tenant", "", "purge the deleted websites of a tenant")
+	cmd.Flags().BoolVar(&retry, "retry", false, "try again the deletions that failed too many times")
+	return cmd
+}
+
+// websiteInstallCheckCmd send pending install notices once, for tenants whose
+// notices the server does not send
+func websiteInstallCheckCmd() *cobra.Command {
+	var tenantID string
+	cmd := &cobra.Command{
+		Use:   "install-check",
+		Short: "Notify owners of the first event of their websites, or of none a week after they were added",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db.NewMongo()
+			store := db.DefaultStore()
+			if tenantID != "" {
+				store = db.TenantStore(tenantID, 0)
+			}
+
+			sent, err := website.NewUseCase(store).CheckInstalls(mobile.NewUseCase(store))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("sent %d install notices\n", sent)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&tenantID, "tenant", "", "send the install notices of a tenant")
+	return cmd
+}
@@ -2,6 +2,12 @@ package configs
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"analytics-api/internal/pkg/clickhouse"
+	"analytics-api/internal/pkg/keystore"
 
 	"github.com/go-redis/redis"
 	"github.com/joho/godotenv"
@@ -13,11 +19,52 @@ var (
 	Port   string
 	AppURL string
 
-	PathGeoDB string
-
 	AccessSecretKey string
 	// RefreshSecretKey string
 
+	// AdminSecretKey guard the instance admin API, admin API is disabled when empty
+	AdminSecretKey string
+
+	// MultiTenant host isolated tenants, each in a separate database
+	MultiTenant bool
+	// DataMasterKey base64 key from which per-tenant data keys are derived
+	DataMasterKey string
+
+	// SignedWrites writes of the management API must be signed with an API
+	// key in single tenant mode, tenants set it through the admin API
+	SignedWrites bool
+
+	// ReplayWatermark recordings are played with the identity of the viewer
+	// over them in single tenant mode, tenants set it through the admin API
+	ReplayWatermark bool
+
+	// VisitorStream support agents may watch the live events of an
+	// identified visitor in single tenant mode, tenants set it through the
+	// admin API
+	VisitorStream bool
+
+	// SelfMonitoringOwner email of the account owning the internal website
+	// that tracks use of the dashboard, off when empty. Single tenant mode only
+	SelfMonitoringOwner string
+
+	// FakeData allow filling websites with synthetic traffic, never set in production
+	FakeData bool
+
+	// AllowPrivateURLs accept websites, webhooks and the endpoints of every
+	// other delivery on loopback and private networks, for internal
+	// deployments. Off they must resolve publicly
+	AllowPrivateURLs bool
+
+	// TrustedProxies addresses and CIDRs of the reverse proxies whose
+	// X-Forwarded-For and X-Real-IP headers are believed. Empty, the client
+	// address is the one of the connection
+	TrustedProxies []string
+
+	// GlobalHostNames a host name is served by one website of the instance
+	// at most. Off, the default, each account may add it once, so agencies
+	// and their clients can track the same site
+	GlobalHostNames bool
+
 	MongoDB struct {
 		Client            *mongo.Database
 		URI               string
@@ -25,6 +72,166 @@ var (
 		UserCollection    string
 		WebsiteCollection string
 		SessionCollection string
+		TenantCollection  string
+		DeviceCollection  string
+		GoalCollection    string
+		// IntegrationCollection ad platform integrations of websites, and
+		// DeliveryLogCollection their deliveries
+		IntegrationCollection string
+		DeliveryLogCollection string
+		// VisitorCollection identified visitors with their first touch
+		VisitorCollection string
+		// CRMConnectionCollection connected CRM accounts, CRMMappingCollection
+		// the fields websites push and CRMQueueCollection the pushes to retry
+		CRMConnectionCollection string
+		CRMMappingCollection    string
+		CRMQueueCollection      string
+		// FirehoseCollection event stream destinations of users and
+		// FirehoseMetricCollection their hourly delivery metrics
+		FirehoseCollection       string
+		FirehoseMetricCollection string
+		// ArchiveCollection manifests of the days of events archived to S3
+		ArchiveCollection string
+		// APIKeyCollection keys users sign management requests with
+		APIKeyCollection string
+		// ReconciliationCollection daily counts of events sent and stored
+		ReconciliationCollection string
+		// UsageCollection monthly event counts of websites against their quota
+		UsageCollection string
+		// DeletionCollection deleted websites whose data is yet to be removed
+		DeletionCollection string
+		// AuditCollection trace of the recordings users played
+		AuditCollection string
+		// AggregateCollection daily counters of websites in aggregate-only mode
+		AggregateCollection string
+		// BenchmarkCollection medians of the websites of each category
+		BenchmarkCollection string
+		// AlertTemplateCollection alert rules defined once for many websites,
+		// AlertInstanceCollection their state on each website
+		AlertTemplateCollection string
+		AlertInstanceCollection string
+		// MetricCollection calculated metrics of websites
+		MetricCollection string
+		// KeyCollection keys of the instance when KEY_STORE is mongo
+		KeyCollection string
+		// PersonalTokenCollection personal tokens of users for scripts
+		PersonalTokenCollection string
+		// ReingestCollection corrected events waiting to replace the stored ones
+		ReingestCollection string
+	}
+
+	// Push credentials of the push services, a platform without credentials is not delivered
+	Push struct {
+		FCMCredentialsFile string
+		APNsKeyFile        string
+		APNsKeyID          string
+		APNsTeamID         string
+		APNsTopic          string
+		APNsSandbox        bool
+	}
+
+	// CRM oauth apps registered with each CRM, a CRM without credentials cannot be connected
+	CRM struct {
+		HubSpotClientID        string
+		HubSpotClientSecret    string
+		SalesforceClientID     string
+		SalesforceClientSecret string
+	}
+
+	// OAuth apps users sign in with, a provider without credentials is not offered
+	OAuth struct {
+		GoogleClientID     string
+		GoogleClientSecret string
+		GitHubClientID     string
+		GitHubClientSecret string
+	}
+
+	// Archive S3 bucket receiving the nightly Parquet archive of events, off
+	// when Bucket is empty. Endpoint overrides AWS for S3 compatible stores
+	Archive struct {
+		Bucket          string
+		Region          string
+		Prefix          string
+		Endpoint        string
+		AccessKeyID     string
+		SecretAccessKey string
+	}
+
+	// SIEM endpoint the audit log and sign in events are exported to, off
+	// when URL is empty. Format is cef or json
+	SIEM struct {
+		URL    string
+		Format string
+		Token  string
+	}
+
+	// Mail server emails to users are sent through, like password resets,
+	// off when URL is empty. From is the address they are sent from
+	Mail struct {
+		URL  string
+		From string
+	}
+
+	// SLO latency objectives of routes, route=threshold@target separated by
+	// commas. Shed turns low priority routes away while the collector is at
+	// risk
+	SLO struct {
+		Objectives string
+		Shed       bool
+	}
+
+	// Spam remote feed of referrer spam domains fetched every Refresh on top
+	// of the embedded list, off when FeedURL is empty
+	Spam struct {
+		FeedURL string
+		Refresh time.Duration
+	}
+
+	// Lifecycle rolling deploys: a stopping replica serves in lame duck for
+	// LameDuck with its readiness failing, then waits up to DrainTimeout for
+	// the requests in flight
+	Lifecycle struct {
+		LameDuck     time.Duration
+		DrainTimeout time.Duration
+	}
+
+	// Storage event storage backends, events are written to both during a migration
+	Storage struct {
+		Primary   string
+		DualWrite bool
+	}
+
+	// Workers background tasks of websites run at once by each pool, like
+	// the archive and re-ingestion, shared fairly between the websites
+	Workers int
+
+	// LateArrivalHours how late an event may arrive and still be merged into
+	// the rollups of its day, those arriving later are stored flagged
+	LateArrivalHours int
+
+	// Lite run the whole stack in one process on a small server: MongoDB is
+	// embedded with its data in SQLite files under Dir, and keys are kept in
+	// memory unless KEY_STORE says otherwise. Events stay in MongoDB and
+	// tenants are not hosted
+	Lite struct {
+		Enabled bool
+		Dir     string
+	}
+
+	// KeyStore store of the keys shared by replicas, the tokens of sign ins,
+	// caches, flags, queues and live streams: redis, mongo or memory. The
+	// memory store only suits a single replica, its keys are lost on restart
+	KeyStore struct {
+		Client keystore.Store
+		Kind   string
+	}
+
+	ClickHouse struct {
+		Client   *clickhouse.Client
+		URL      string
+		Database string
+		User     string
+		Password string
 	}
 
 	Redis struct {
@@ -36,21 +243,135 @@ var (
 )
 
 func init() {
-	if err := godotenv.Load(".env"); err != nil {
+	fromEnvironment = environmentKeys()
+	loadErr := godotenv.Load(configFile)
+	// an invalid tunable is reported where it is used, like ALLOWED_CIDRS
+	aTunables, _ := readTunables(os.Getenv)
+	tunables.Store(aTunables)
+	if loadErr != nil {
 		return
 	}
 
 	Port = os.Getenv("PORT")
 	AppURL = os.Getenv("APP_URL")
-	PathGeoDB = os.Getenv("PATH_GEO_DB")
 	AccessSecretKey = os.Getenv("ACCESS_SECRET")
 	// RefreshSecretKey = os.Getenv("REFRESH_SECRET")
+	AdminSecretKey = os.Getenv("ADMIN_SECRET")
+	MultiTenant = os.Getenv("MULTI_TENANT") == "true"
+	DataMasterKey = os.Getenv("DATA_MASTER_KEY")
+	SignedWrites = os.Getenv("SIGNED_WRITES") == "true"
+	ReplayWatermark = os.Getenv("REPLAY_WATERMARK") == "true"
+	VisitorStream = os.Getenv("VISITOR_STREAM") == "true"
+	SelfMonitoringOwner = os.Getenv("SELF_MONITORING_OWNER")
+	FakeData = os.Getenv("FAKE_DATA") == "true"
+	AllowPrivateURLs = os.Getenv("ALLOW_PRIVATE_URLS") == "true"
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		TrustedProxies = strings.Split(proxies, ",")
+	}
+	GlobalHostNames = os.Getenv("GLOBAL_HOST_NAMES") == "true"
 
 	MongoDB.URI = os.Getenv("URI")
 	MongoDB.Name = os.Getenv("NAME")
 	MongoDB.UserCollection = os.Getenv("USER_COLLECTION")
 	MongoDB.WebsiteCollection = os.Getenv("WEBSITE_COLLECTION")
 	MongoDB.SessionCollection = os.Getenv("SESSION_COLLECTION")
+	MongoDB.TenantCollection = os.Getenv("TENANT_COLLECTION")
+	MongoDB.DeviceCollection = os.Getenv("DEVICE_COLLECTION")
+	MongoDB.GoalCollection = os.Getenv("GOAL_COLLECTION")
+	MongoDB.IntegrationCollection = os.Getenv("INTEGRATION_COLLECTION")
+	MongoDB.DeliveryLogCollection = os.Getenv("DELIVERY_LOG_COLLECTION")
+	MongoDB.VisitorCollection = os.Getenv("VISITOR_COLLECTION")
+	MongoDB.CRMConnectionCollection = os.Getenv("CRM_CONNECTION_COLLECTION")
+	MongoDB.CRMMappingCollection = os.Getenv("CRM_MAPPING_COLLECTION")
+	MongoDB.CRMQueueCollection = os.Getenv("CRM_QUEUE_COLLECTION")
+	MongoDB.FirehoseCollection = os.Getenv("FIREHOSE_COLLECTION")
+	MongoDB.FirehoseMetricCollection = os.Getenv("FIREHOSE_METRIC_COLLECTION")
+	MongoDB.ArchiveCollection = os.Getenv("ARCHIVE_COLLECTION")
+	MongoDB.APIKeyCollection = os.Getenv("API_KEY_COLLECTION")
+	MongoDB.ReconciliationCollection = os.Getenv("RECONCILIATION_COLLECTION")
+	MongoDB.UsageCollection = os.Getenv("USAGE_COLLECTION")
+	MongoDB.DeletionCollection = os.Getenv("DELETION_COLLECTION")
+	MongoDB.AuditCollection = os.Getenv("AUDIT_COLLECTION")
+	MongoDB.AggregateCollection = os.Getenv("AGGREGATE_COLLECTION")
+	MongoDB.BenchmarkCollection = os.Getenv("BENCHMARK_COLLECTION")
+	MongoDB.AlertTemplateCollection = os.Getenv("ALERT_TEMPLATE_COLLECTION")
+	MongoDB.AlertInstanceCollection = os.Getenv("ALERT_INSTANCE_COLLECTION")
+	MongoDB.MetricCollection = os.Getenv("METRIC_COLLECTION")
+	MongoDB.KeyCollection = os.Getenv("KEY_COLLECTION")
+	MongoDB.PersonalTokenCollection = os.Getenv("PERSONAL_TOKEN_COLLECTION")
+	MongoDB.ReingestCollection = os.Getenv("REINGEST_COLLECTION")
+
+	Push.FCMCredentialsFile = os.Getenv("FCM_CREDENTIALS_FILE")
+	Push.APNsKeyFile = os.Getenv("APNS_KEY_FILE")
+	Push.APNsKeyID = os.Getenv("APNS_KEY_ID")
+	Push.APNsTeamID = os.Getenv("APNS_TEAM_ID")
+	Push.APNsTopic = os.Getenv("APNS_TOPIC")
+	Push.APNsSandbox = os.Getenv("APNS_SANDBOX") == "true"
+
+	CRM.HubSpotClientID = os.Getenv("HUBSPOT_CLIENT_ID")
+	CRM.HubSpotClientSecret = os.Getenv("HUBSPOT_CLIENT_SECRET")
+	CRM.SalesforceClientID = os.Getenv("SALESFORCE_CLIENT_ID")
+	CRM.SalesforceClientSecret = os.Getenv("SALESFORCE_CLIENT_SECRET")
+
+	OAuth.GoogleClientID = os.Getenv("GOOGLE_CLIENT_ID")
+	OAuth.GoogleClientSecret = os.Getenv("GOOGLE_CLIENT_SECRET")
+	OAuth.GitHubClientID = os.Getenv("GITHUB_CLIENT_ID")
+	OAuth.GitHubClientSecret = os.Getenv("GITHUB_CLIENT_SECRET")
+
+	Archive.Bucket = os.Getenv("ARCHIVE_S3_BUCKET")
+	Archive.Region = os.Getenv("ARCHIVE_S3_REGION")
+	Archive.Prefix = strings.Trim(os.Getenv("ARCHIVE_S3_PREFIX"), "/")
+	Archive.Endpoint = os.Getenv("ARCHIVE_S3_ENDPOINT")
+	Archive.AccessKeyID = os.Getenv("ARCHIVE_S3_ACCESS_KEY_ID")
+	Archive.SecretAccessKey = os.Getenv("ARCHIVE_S3_SECRET_ACCESS_KEY")
+
+	SIEM.URL = os.Getenv("SIEM_URL")
+	SIEM.Format = os.Getenv("SIEM_FORMAT")
+	SIEM.Token = os.Getenv("SIEM_TOKEN")
+
+	Mail.URL = os.Getenv("MAIL_URL")
+	Mail.From = os.Getenv("MAIL_FROM")
+
+	SLO.Objectives = os.Getenv("LATENCY_SLOS")
+	SLO.Shed = os.Getenv("SLO_SHED") == "true"
+
+	Spam.FeedURL = os.Getenv("SPAM_FEED_URL")
+	Spam.Refresh = durationEnv("SPAM_REFRESH", 24*time.Hour)
+
+	Lifecycle.LameDuck = durationEnv("LAME_DUCK", 15*time.Second)
+	Lifecycle.DrainTimeout = durationEnv("DRAIN_TIMEOUT", 30*time.Second)
+
+	Storage.Primary = os.Getenv("STORAGE_PRIMARY")
+	if Storage.Primary == "" {
+		Storage.Primary = "mongo"
+	}
+	Storage.DualWrite = os.Getenv("STORAGE_DUAL_WRITE") == "true"
+
+	Workers = intEnv("WORKERS", 4)
+	LateArrivalHours = intEnv("LATE_ARRIVAL_HOURS", 48)
+
+	Lite.Enabled = os.Getenv("LITE_MODE") == "true"
+	Lite.Dir = os.Getenv("LITE_DIR")
+	if Lite.Dir == "" {
+		Lite.Dir = "data"
+	}
+
+	// AUTH_STORE picked the store when it only kept the tokens of sign ins
+	KeyStore.Kind = os.Getenv("KEY_STORE")
+	if KeyStore.Kind == "" {
+		KeyStore.Kind = os.Getenv("AUTH_STORE")
+	}
+	if KeyStore.Kind == "" && Lite.Enabled {
+		KeyStore.Kind = keystore.KindMemory
+	}
+	if KeyStore.Kind == "" {
+		KeyStore.Kind = keystore.KindRedis
+	}
+
+	ClickHouse.URL = os.Getenv("CLICKHOUSE_URL")
+	ClickHouse.Database = os.Getenv("CLICKHOUSE_DATABASE")
+	ClickHouse.User = os.Getenv("CLICKHOUSE_USER")
+	ClickHouse.Password = os.Getenv("CLICKHOUSE_PASSWORD")
 
 	if IsDev() {
 		Redis.Host = os.Getenv("REDIS_HOST")
@@ -60,7 +381,37 @@ func init() {
 	}
 }
 
+// UsesClickHouse events are read from or written to ClickHouse
+func UsesClickHouse() bool {
+	return Storage.Primary == "clickhouse" || Storage.DualWrite
+}
+
+// ArchiveEnabled events are archived to S3 every night
+func ArchiveEnabled() bool {
+	return Archive.Bucket != ""
+}
+
 // IsDev ...
 func IsDev() bool {
 	return os.Getenv("MODE") == "dev"
 }
+
+// durationEnv duration of the variable key, fallback when it is empty or
+// invalid
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
+
+// intEnv positive number of the variable key, fallback when it is empty or
+// invalid
+func intEnv(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value < 1 {
+		return fallback
+	}
+	return value
+}
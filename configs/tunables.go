@@ -0,0 +1,177 @@
+package configs
+
+import (
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"analytics-api/internal/pkg/ipallow"
+
+	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
+)
+
+// configFile read at start and watched for the tunables
+const configFile = ".env"
+
+// Tunables settings taking effect without a restart, read again when the
+// config file changes or the process gets SIGHUP. Readers take a snapshot
+// with Current so a reload never shows them half of a change
+type Tunables struct {
+	// LogLevel of logrus, info by default
+	LogLevel string `json:"log_level"`
+	// PathGeoDB city database, opened for every batch
+	PathGeoDB string `json:"path_geo_db"`
+	// CityMinSessions cities with fewer sessions are not named in reports, so
+	// visitors of low traffic sites cannot be singled out
+	CityMinSessions int64 `json:"city_min_sessions"`
+	// MaintenanceMode keep the management API read-only, can also be turned
+	// on through the admin API
+	MaintenanceMode bool `json:"maintenance_mode"`
+	// AllowedCIDRs networks allowed to reach the dashboard in single tenant
+	// mode, empty allows all
+	AllowedCIDRs []string `json:"allowed_cidrs"`
+	// Quota monthly events of each website, no quota when MonthlyEvents is 0.
+	// Policy drop, overage or sample tells what happens to events past it
+	Quota struct {
+		MonthlyEvents int64  `json:"monthly_events"`
+		Policy        string `json:"policy"`
+		// SamplePercent share of the batches past the quota kept by sample
+		SamplePercent int `json:"sample_percent"`
+	} `json:"quota"`
+	// Benchmarks compare websites with the median of the websites of their
+	// category on the instance, categories with fewer than MinWebsites
+	// websites get no benchmark so no website can be told apart
+	Benchmarks struct {
+		Enabled     bool `json:"enabled"`
+		MinWebsites int  `json:"min_websites"`
+	} `json:"benchmarks"`
+	// LoadedAt when the tunables were read
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+var tunables atomic.Pointer[Tunables]
+
+// fromEnvironment keys set in the environment of the process before the
+// config file was loaded, they win over the file on reload like at start
+var fromEnvironment = map[string]bool{}
+
+// Current tunables, never to be modified
+func Current() *Tunables {
+	return tunables.Load()
+}
+
+// environmentKeys keys set in the environment of the process
+func environmentKeys() map[string]bool {
+	keys := map[string]bool{}
+	for _, pair := range os.Environ() {
+		keys[strings.SplitN(pair, "=", 2)[0]] = true
+	}
+	return keys
+}
+
+// readTunables tunables by lookup. Numbers out of range fall back to their
+// default like at start, an unknown log level or an invalid CIDR is an error
+// returned along with the tunables read
+func readTunables(lookup func(string) string) (*Tunables, error) {
+	aTunables := &Tunables{LoadedAt: time.Now()}
+	var err error
+
+	aTunables.LogLevel = lookup("LOG_LEVEL")
+	if aTunables.LogLevel == "" {
+		aTunables.LogLevel = logrus.InfoLevel.String()
+	}
+	if _, levelErr := logrus.ParseLevel(aTunables.LogLevel); levelErr != nil {
+		err = levelErr
+		aTunables.LogLevel = logrus.InfoLevel.String()
+	}
+	aTunables.PathGeoDB = lookup("PATH_GEO_DB")
+	aTunables.CityMinSessions = 5
+	if value, parseErr := strconv.ParseInt(lookup("CITY_MIN_SESSIONS"), 10, 64); parseErr == nil && value >= 0 {
+		aTunables.CityMinSessions = value
+	}
+	aTunables.MaintenanceMode = lookup("MAINTENANCE_MODE") == "true"
+	if cidrs := lookup("ALLOWED_CIDRS"); cidrs != "" {
+		aTunables.AllowedCIDRs = strings.Split(cidrs, ",")
+		if _, parseErr := ipallow.Parse(aTunables.AllowedCIDRs); parseErr != nil {
+			err = parseErr
+		}
+	}
+
+	if value, parseErr := strconv.ParseInt(lookup("EVENT_QUOTA"), 10, 64); parseErr == nil && value >= 0 {
+		aTunables.Quota.MonthlyEvents = value
+	}
+	aTunables.Quota.Policy = lookup("OVERAGE_POLICY")
+	if aTunables.Quota.Policy == "" {
+		aTunables.Quota.Policy = "overage"
+	}
+	aTunables.Quota.SamplePercent = 10
+	if value, parseErr := strconv.Atoi(lookup("OVERAGE_SAMPLE_PERCENT")); parseErr == nil && value >= 0 && value <= 100 {
+		aTunables.Quota.SamplePercent = value
+	}
+
+	aTunables.Benchmarks.Enabled = lookup("BENCHMARKS") == "true"
+	aTunables.Benchmarks.MinWebsites = 5
+	if value, parseErr := strconv.Atoi(lookup("BENCHMARK_MIN_WEBSITES")); parseErr == nil && value >= 2 {
+		aTunables.Benchmarks.MinWebsites = value
+	}
+	return aTunables, err
+}
+
+// Reload read the tunables of the config file again, keys of the
+// environment of the process excepted. Invalid tunables are returned as an
+// error and the current ones kept
+func Reload() (*Tunables, error) {
+	file, err := godotenv.Read(configFile)
+	if err != nil {
+		return nil, err
+	}
+	aTunables, err := readTunables(func(key string) string {
+		if fromEnvironment[key] {
+			return os.Getenv(key)
+		}
+		return file[key]
+	})
+	if err != nil {
+		return nil, err
+	}
+	tunables.Store(aTunables)
+	return aTunables, nil
+}
+
+// Watch reload the tunables on SIGHUP and when the config file is modified,
+// checked every interval, then hand them to apply. Runs until the process exits
+func Watch(interval time.Duration, apply func(*Tunables)) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var modified time.Time
+	if info, err := os.Stat(configFile); err == nil {
+		modified = info.ModTime()
+	}
+	for {
+		select {
+		case <-hangup:
+		case <-ticker.C:
+			info, err := os.Stat(configFile)
+			if err != nil || info.ModTime().Equal(modified) {
+				continue
+			}
+			modified = info.ModTime()
+		}
+
+		aTunables, err := Reload()
+		if err != nil {
+			logrus.Error("reload config error, keeping the current one ", err)
+			continue
+		}
+		apply(aTunables)
+		logrus.Info("reloaded config")
+	}
+}
@@ -0,0 +1,394 @@
+package db
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"analytics-api/configs"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Backup archive format, version 1
+//
+// A gzip compressed tar holding:
+//   - manifest.json: BackupManifest, the first entry of the archive
+//   - <name>.jsonl: one document per line in canonical extended JSON, for
+//     each collection listed in the manifest, see backupCollections
+//
+// Sessions are only included when a time range is given. Archives written
+// before the manifest led them are still read.
+const backupVersion = 1
+
+const manifestFile = "manifest.json"
+
+// BackupManifest describe content of a backup archive
+type BackupManifest struct {
+	Version     int              `json:"version"`
+	CreatedAt   time.Time        `json:"created_at"`
+	Collections map[string]int64 `json:"collections"`
+	EventsFrom  *time.Time       `json:"events_from,omitempty"`
+	EventsTo    *time.Time       `json:"events_to,omitempty"`
+}
+
+// BackupOptions select what to export, events are skipped when EventsFrom is nil
+type BackupOptions struct {
+	EventsFrom *time.Time
+	EventsTo   *time.Time
+}
+
+// backupCollections map archive name to configured collection name, every
+// collection the instance persists. Restore only writes to these
+func backupCollections() map[string]string {
+	return map[string]string{
+		"user":            configs.MongoDB.UserCollection,
+		"website":         configs.MongoDB.WebsiteCollection,
+		"session":         configs.MongoDB.SessionCollection,
+		"tenant":          configs.MongoDB.TenantCollection,
+		"device":          configs.MongoDB.DeviceCollection,
+		"goal":            configs.MongoDB.GoalCollection,
+		"integration":     configs.MongoDB.IntegrationCollection,
+		"delivery_log":    configs.MongoDB.DeliveryLogCollection,
+		"visitor":         configs.MongoDB.VisitorCollection,
+		"crm_connection":  configs.MongoDB.CRMConnectionCollection,
+		"crm_mapping":     configs.MongoDB.CRMMappingCollection,
+		"crm_queue":       configs.MongoDB.CRMQueueCollection,
+		"firehose":        configs.MongoDB.FirehoseCollection,
+		"firehose_metric": configs.MongoDB.FirehoseMetricCollection,
+		"archive":         configs.MongoDB.ArchiveCollection,
+		"api_key":         configs.MongoDB.APIKeyCollection,
+		"reconciliation":  configs.MongoDB.ReconciliationCollection,
+		"usage":           configs.MongoDB.UsageCollection,
+		"deletion":        configs.MongoDB.DeletionCollection,
+		"audit":           configs.MongoDB.AuditCollection,
+		"aggregate":       configs.MongoDB.AggregateCollection,
+		"benchmark":       configs.MongoDB.BenchmarkCollection,
+		"alert_template":  configs.MongoDB.AlertTemplateCollection,
+		"alert_instance":  configs.MongoDB.AlertInstanceCollection,
+		"metric":          configs.MongoDB.MetricCollection,
+		"personal_token":  configs.MongoDB.PersonalTokenCollection,
+		"reingest":        configs.MongoDB.ReingestCollection,
+	}
+}
+
+// backupNames archive names of the collections to export in order, those
+// without a configured collection are left out and sessions come last
+func backupNames(opts BackupOptions) []string {
+	names := []string{}
+	for name, collection := range backupCollections() {
+		if collection == "" || name == "session" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if opts.EventsFrom != nil && configs.MongoDB.SessionCollection != "" {
+		names = append(names, "session")
+	}
+	return names
+}
+
+// Backup write management data and optionally events to w. Collections are
+// spooled to temporary files first, so the manifest leads the archive and no
+// collection is held in memory
+func Backup(w io.Writer, opts BackupOptions) (*BackupManifest, error) {
+	manifest := &BackupManifest{
+		Version:     backupVersion,
+		CreatedAt:   time.Now(),
+		Collections: map[string]int64{},
+		EventsFrom:  opts.EventsFrom,
+		EventsTo:    opts.EventsTo,
+	}
+
+	dir, err := os.MkdirTemp("", "analytics-backup")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	names := backupNames(opts)
+	for _, name := range names {
+		filter := bson.M{}
+		if name == "session" {
+			timeFilter := bson.M{"$gte": *opts.EventsFrom}
+			if opts.EventsTo != nil {
+				timeFilter["$lt"] = *opts.EventsTo
+			}
+			filter = bson.M{"time_report": timeFilter}
+		}
+
+		count, err := dumpCollection(backupCollections()[name], filter, filepath.Join(dir, name+".jsonl"))
+		if err != nil {
+			return nil, fmt.Errorf("backup %s: %w", name, err)
+		}
+		manifest.Collections[name] = count
+		logrus.Infof("backup %d documents of %s", count, name)
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeTarFile(tw, manifestFile, int64(len(data)), bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if err := copyTarFile(tw, name+".jsonl", filepath.Join(dir, name+".jsonl")); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Restore read archive from r and insert documents, drop existing data first
+// if drop is true. Collections are streamed once the manifest is read, those
+// of older archives coming before it are spooled to temporary files. Archives
+// naming a collection outside of backupCollections are refused before
+// anything is written
+func Restore(r io.Reader, drop bool) (*BackupManifest, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	dir, err := os.MkdirTemp("", "analytics-restore")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	var manifest *BackupManifest
+	spooled := []string{}
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if header.Name == manifestFile {
+			manifest, err = readManifest(tr)
+			if err != nil {
+				return nil, err
+			}
+			if err := prepareRestore(manifest, drop); err != nil {
+				return nil, err
+			}
+			for _, name := range spooled {
+				if err := restoreSpooled(manifest, dir, name); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		name := strings.TrimSuffix(header.Name, ".jsonl")
+		if manifest == nil {
+			if err := spoolFile(filepath.Join(dir, filepath.Base(header.Name)), tr); err != nil {
+				return nil, err
+			}
+			spooled = append(spooled, name)
+			continue
+		}
+		if err := restoreCollection(manifest, name, tr); err != nil {
+			return nil, err
+		}
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("read manifest: %s missing", manifestFile)
+	}
+	return manifest, nil
+}
+
+func readManifest(r io.Reader) (*BackupManifest, error) {
+	var manifest BackupManifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	if manifest.Version != backupVersion {
+		return nil, fmt.Errorf("unsupported backup version %d", manifest.Version)
+	}
+	return &manifest, nil
+}
+
+// prepareRestore check every collection of manifest may be restored, then
+// drop them when drop is true and create the collections and indexes
+func prepareRestore(manifest *BackupManifest, drop bool) error {
+	for name := range manifest.Collections {
+		if backupCollections()[name] == "" {
+			return fmt.Errorf("unknown collection %q in backup", name)
+		}
+	}
+
+	if drop {
+		for name := range manifest.Collections {
+			err := configs.MongoDB.Client.Collection(backupCollections()[name]).Drop(context.TODO())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// make sure the session time series collection and the indexes exist before inserting
+	return Migrate(configs.MongoDB.Client)
+}
+
+// restoreCollection insert the documents of the archive file of name read
+// from r, files the manifest does not list are skipped
+func restoreCollection(manifest *BackupManifest, name string, r io.Reader) error {
+	if _, ok := manifest.Collections[name]; !ok {
+		logrus.Warnf("restore skip %s, not in the manifest", name)
+		return nil
+	}
+	count, err := loadCollection(backupCollections()[name], r)
+	if err != nil {
+		return fmt.Errorf("restore %s: %w", name, err)
+	}
+	logrus.Infof("restore %d documents of %s", count, name)
+	return nil
+}
+
+func restoreSpooled(manifest *BackupManifest, dir, name string) error {
+	file, err := os.Open(filepath.Join(dir, filepath.Base(name)+".jsonl"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return restoreCollection(manifest, name, file)
+}
+
+func spoolFile(path string, r io.Reader) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// dumpCollection write the documents of collection name matching filter to
+// the file at path, one per line
+func dumpCollection(name string, filter bson.M, path string) (int64, error) {
+	var count int64
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	buf := bufio.NewWriter(file)
+
+	cursor, err := configs.MongoDB.Client.Collection(name).Find(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		line, err := bson.MarshalExtJSON(cursor.Current, true, false)
+		if err != nil {
+			return 0, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return 0, err
+	}
+	if err := buf.Flush(); err != nil {
+		return 0, err
+	}
+	return count, file.Close()
+}
+
+func loadCollection(name string, r io.Reader) (int64, error) {
+	const batchSize = 500
+	var count int64
+	var docs []interface{}
+	collection := configs.MongoDB.Client.Collection(name)
+
+	flush := func() error {
+		if len(docs) == 0 {
+			return nil
+		}
+		_, err := collection.InsertMany(context.TODO(), docs)
+		count += int64(len(docs))
+		docs = nil
+		return err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(scanner.Bytes(), true, &doc); err != nil {
+			return count, err
+		}
+		docs = append(docs, doc)
+		if len(docs) == batchSize {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return count, err
+	}
+	return count, flush()
+}
+
+func copyTarFile(tw *tar.Writer, name, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return writeTarFile(tw, name, info.Size(), file)
+}
+
+func writeTarFile(tw *tar.Writer, name string, size int64, r io.Reader) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    size,
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.Copy(tw, r)
+	return err
+}
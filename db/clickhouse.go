@@ -0,0 +1,81 @@
+package db
+
+import (
+	"fmt"
+
+	"analytics-api/configs"
+	"analytics-api/internal/pkg/clickhouse"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ClickHouseEventTable table of session events in ClickHouse
+const ClickHouseEventTable = "session_events"
+
+// NewClickHouse open client to clickhouse and create the event table if not exists
+func NewClickHouse() {
+	configs.ClickHouse.Client = clickhouse.New(
+		configs.ClickHouse.URL,
+		configs.ClickHouse.Database,
+		configs.ClickHouse.User,
+		configs.ClickHouse.Password,
+	)
+
+	// same retention as the mongo time series collection
+	err := configs.ClickHouse.Client.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS `+ClickHouseEventTable+` (
+		tenant_id String,
+		id String,
+		user_id String,
+		website_id String,
+		country String,
+		city String,
+		device String,
+		os String,
+		browser String,
+		version String,
+		created_at String,
+		platform LowCardinality(String),
+		app_version String,
+		os_version String,
+		device_model String,
+		country_code LowCardinality(String),
+		region String,
+		region_code String,
+		overage Bool,
+		referrer String,
+		duration String,
+		event_id String,
+		type Int64,
+		data String,
+		sealed String,
+		late Bool,
+		timestamp Int64,
+		time_report DateTime64(3, 'UTC')
+	) ENGINE = MergeTree
+	ORDER BY (tenant_id, user_id, website_id, id, timestamp)
+	TTL toDateTime(time_report) + INTERVAL %d DAY`, RetentionDays), nil)
+	if err != nil {
+		logrus.Error("create clickhouse event table error ", err)
+		return
+	}
+
+	// columns added after the table was first released
+	for _, column := range []string{
+		"platform LowCardinality(String) AFTER created_at",
+		"app_version String AFTER platform",
+		"os_version String AFTER app_version",
+		"device_model String AFTER os_version",
+		"country_code LowCardinality(String) AFTER device_model",
+		"region String AFTER country_code",
+		"region_code String AFTER region",
+		"overage Bool AFTER region_code",
+		"referrer String AFTER overage",
+		"event_id String AFTER duration",
+		"late Bool AFTER sealed",
+	} {
+		err := configs.ClickHouse.Client.Exec("ALTER TABLE "+ClickHouseEventTable+" ADD COLUMN IF NOT EXISTS "+column, nil)
+		if err != nil {
+			logrus.Error("add clickhouse event column error ", err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Package dbtest stores to check the reports against: one on MongoDB, where
+// aggregations run on the server, and one on embedded MongoDB, where lite
+// mode runs them in the process
+package dbtest
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+
+	"github.com/FerretDB/FerretDB/ferretdb"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// URIEnv environment variable of the MongoDB to check the reports against,
+// the tests are skipped when it is unset
+const URIEnv = "MONGO_TEST_URI"
+
+// Stores store on the MongoDB at URIEnv and one on embedded MongoDB, both
+// on a database of their own dropped once t is done
+func Stores(t *testing.T) (mongoStore, liteStore *db.Store) {
+	t.Helper()
+	uri := os.Getenv(URIEnv)
+	if uri == "" {
+		t.Skip(URIEnv + " is not set")
+	}
+	name := "analytics_test_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	return &db.Store{Mongo: connect(t, uri, name)}, &db.Store{Mongo: connect(t, lite(t), name)}
+}
+
+// lite start embedded MongoDB with its data in a temporary directory, as
+// lite mode does, and return the URI to connect to
+func lite(t *testing.T) string {
+	t.Helper()
+	embedded, err := ferretdb.New(&ferretdb.Config{
+		Listener:  ferretdb.ListenerConfig{TCP: "127.0.0.1:0"},
+		Handler:   "sqlite",
+		SQLiteURL: "file:" + filepath.ToSlash(t.TempDir()) + "/",
+		Logger:    slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})),
+	})
+	if err != nil {
+		t.Fatalf("start embedded MongoDB: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		embedded.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return embedded.MongoDBURI()
+}
+
+// connect database name of the MongoDB at uri, dropped once t is done
+func connect(t *testing.T, uri, name string) *mongo.Database {
+	t.Helper()
+	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connect %s: %v", uri, err)
+	}
+	database := client.Database(name)
+	t.Cleanup(func() {
+		database.Drop(context.TODO())
+		client.Disconnect(context.TODO())
+	})
+	return database
+}
+
+// Insert documents into collection of each store
+func Insert(t *testing.T, collection string, documents []interface{}, stores ...*db.Store) {
+	t.Helper()
+	for _, store := range stores {
+		if _, err := store.Mongo.Collection(collection).InsertMany(context.TODO(), documents); err != nil {
+			t.Fatalf("insert into %s: %v", collection, err)
+		}
+	}
+}
+
+// Compare run report on mongoStore with configs.Lite off, then on liteStore
+// with it on, and fail t when they give different results. unordered when
+// the report leaves the order of its rows to the database
+func Compare(t *testing.T, mongoStore, liteStore *db.Store, unordered bool, report func(*db.Store) (interface{}, error)) {
+	t.Helper()
+	enabled := configs.Lite.Enabled
+	defer func() { configs.Lite.Enabled = enabled }()
+
+	configs.Lite.Enabled = false
+	want, err := report(mongoStore)
+	if err != nil {
+		t.Fatalf("report on MongoDB: %v", err)
+	}
+	configs.Lite.Enabled = true
+	got, err := report(liteStore)
+	if err != nil {
+		t.Fatalf("report in lite mode: %v", err)
+	}
+	if wantJSON, gotJSON := jsonOf(t, want, unordered), jsonOf(t, got, unordered); gotJSON != wantJSON {
+		t.Errorf("report in lite mode = %s, want %s as on MongoDB", gotJSON, wantJSON)
+	}
+}
+
+// jsonOf value in JSON, with the rows of a list sorted when unordered
+func jsonOf(t *testing.T, value interface{}, unordered bool) string {
+	t.Helper()
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("marshal %v: %v", value, err)
+	}
+	var rows []json.RawMessage
+	if !unordered || json.Unmarshal(data, &rows) != nil {
+		return string(data)
+	}
+	sort.Slice(rows, func(i, j int) bool { return string(rows[i]) < string(rows[j]) })
+	if data, err = json.Marshal(rows); err != nil {
+		t.Fatalf("marshal %v: %v", value, err)
+	}
+	return string(data)
+}
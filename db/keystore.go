@@ -0,0 +1,28 @@
+package db
+
+import (
+	"fmt"
+
+	"analytics-api/configs"
+	"analytics-api/internal/pkg/keystore"
+)
+
+// NewKeyStore open the store of keys picked by KEY_STORE, connecting to
+// Redis only when it is the store
+func NewKeyStore() error {
+	switch configs.KeyStore.Kind {
+	case keystore.KindRedis:
+		NewRedis()
+		configs.KeyStore.Client = keystore.NewRedis(configs.Redis.Client)
+	case keystore.KindMongo:
+		if err := CreateKeyCollection(); err != nil {
+			return err
+		}
+		configs.KeyStore.Client = keystore.NewMongo(configs.MongoDB.Client.Collection(configs.MongoDB.KeyCollection))
+	case keystore.KindMemory:
+		configs.KeyStore.Client = keystore.NewMemory()
+	default:
+		return fmt.Errorf("KEY_STORE must be %s, %s or %s", keystore.KindRedis, keystore.KindMongo, keystore.KindMemory)
+	}
+	return nil
+}
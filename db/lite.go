@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"analytics-api/configs"
+
+	"github.com/FerretDB/FerretDB/ferretdb"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// liteSweepEvery how often documents past the expiry of their collection are
+// deleted in lite mode, where indexes do not expire them
+const liteSweepEvery = time.Minute
+
+// liteExpiry field of a collection whose documents are deleted once it is
+// older than after, as a TTL index does
+type liteExpiry struct {
+	database   *mongo.Database
+	collection string
+	field      string
+	after      time.Duration
+}
+
+var (
+	liteExpiriesMutex sync.Mutex
+	liteExpiries      = map[string]liteExpiry{}
+)
+
+// startLite run MongoDB in the process, with the data of every database in
+// SQLite files of configs.Lite.Dir, and the sweeps of expired documents.
+// Returns the URI to connect to, on the loopback only
+func startLite() (string, error) {
+	dir, err := filepath.Abs(configs.Lite.Dir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	embedded, err := ferretdb.New(&ferretdb.Config{
+		Listener:  ferretdb.ListenerConfig{TCP: "127.0.0.1:0"},
+		Handler:   "sqlite",
+		SQLiteURL: "file:" + filepath.ToSlash(dir) + "/",
+		Logger:    slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})),
+	})
+	if err != nil {
+		return "", err
+	}
+	go embedded.Run(context.Background())
+	go runLiteExpiry(liteSweepEvery)
+	logrus.Info("lite mode, data kept in ", dir)
+	return embedded.MongoDBURI(), nil
+}
+
+// createLiteIndexes create models on a collection without the options
+// embedded MongoDB lacks: expiry is left to the sweeps and partial indexes
+// cover every document. They are created one by one, embedded MongoDB fails
+// to skip several existing indexes at once
+func createLiteIndexes(database *mongo.Database, collection string, models []mongo.IndexModel) error {
+	for _, model := range models {
+		if model.Options != nil && (model.Options.ExpireAfterSeconds != nil || model.Options.PartialFilterExpression != nil) {
+			options := *model.Options
+			if options.ExpireAfterSeconds != nil {
+				expireLite(database, collection, indexField(model.Keys), time.Duration(*options.ExpireAfterSeconds)*time.Second)
+			}
+			options.ExpireAfterSeconds = nil
+			options.PartialFilterExpression = nil
+			model.Options = &options
+		}
+		if _, err := database.Collection(collection).Indexes().CreateOne(context.Background(), model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexField first field of the keys of an index
+func indexField(keys interface{}) string {
+	switch keys := keys.(type) {
+	case bson.M:
+		for field := range keys {
+			return field
+		}
+	case primitive.D:
+		if len(keys) > 0 {
+			return keys[0].Key
+		}
+	}
+	return ""
+}
+
+// expireLite delete the documents of collection once their field is older
+// than after, from the next sweep on
+func expireLite(database *mongo.Database, collection, field string, after time.Duration) {
+	liteExpiriesMutex.Lock()
+	defer liteExpiriesMutex.Unlock()
+	liteExpiries[database.Name()+"."+collection+"."+field] = liteExpiry{
+		database:   database,
+		collection: collection,
+		field:      field,
+		after:      after,
+	}
+}
+
+// runLiteExpiry sweep the expired documents every interval, forever
+func runLiteExpiry(interval time.Duration) {
+	for range time.Tick(interval) {
+		liteExpiriesMutex.Lock()
+		expiries := make([]liteExpiry, 0, len(liteExpiries))
+		for _, anExpiry := range liteExpiries {
+			expiries = append(expiries, anExpiry)
+		}
+		liteExpiriesMutex.Unlock()
+
+		now := time.Now()
+		for _, anExpiry := range expiries {
+			filter := bson.M{anExpiry.field: bson.M{"$lt": now.Add(-anExpiry.after)}}
+			_, err := anExpiry.database.Collection(anExpiry.collection).DeleteMany(context.Background(), filter)
+			if err != nil {
+				logrus.Error("sweep expired documents of ", anExpiry.collection, " error ", err)
+			}
+		}
+	}
+}
@@ -2,19 +2,42 @@ package db
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"analytics-api/configs"
 
 	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"gopkg.in/mgo.v2/bson"
 )
 
-// NewMongo open new client to mongodb
+// RetentionDays sessions older than this are expired by mongo and clickhouse
+const RetentionDays = 180
+
+// DeliveryLogDays deliveries of integrations older than this are expired by mongo
+const DeliveryLogDays = 30
+
+// CRMQueueDays pushes to CRMs still queued after this are expired by mongo
+const CRMQueueDays = 7
+
+// FirehoseMetricDays hourly metrics of firehose destinations older than this
+// are expired by mongo
+const FirehoseMetricDays = 30
+
+// NewMongo open new client to mongodb, started in the process in lite mode
 func NewMongo() {
+	uri := configs.MongoDB.URI
+	if configs.Lite.Enabled {
+		var err error
+		if uri, err = startLite(); err != nil {
+			logrus.Fatalln("start lite mode ", err)
+		}
+	}
 	serverAPIOptions := options.ServerAPI(options.ServerAPIVersion1)
-	clientOptions := options.Client().ApplyURI(configs.MongoDB.URI).SetServerAPIOptions(serverAPIOptions)
+	clientOptions := options.Client().ApplyURI(uri).SetServerAPIOptions(serverAPIOptions)
 
 	client, err := mongo.Connect(context.TODO(), clientOptions)
 	if err != nil {
@@ -23,13 +46,88 @@ func NewMongo() {
 	configs.MongoDB.Client = client.Database(configs.MongoDB.Name)
 }
 
-// CreateSessionCollection create timeseries session collection if not exists
-func CreateSessionCollection() error {
-	exists, err := checkCollection(configs.MongoDB.SessionCollection)
+// Migrate create collections of database if not exists, and their indexes
+func Migrate(database *mongo.Database) error {
+	if err := CreateUserCollection(database); err != nil {
+		return err
+	}
+	if err := CreateWebsiteCollection(database); err != nil {
+		return err
+	}
+	if err := CreateSessionCollection(database); err != nil {
+		return err
+	}
+	if err := CreateDeviceCollection(database); err != nil {
+		return err
+	}
+	if err := CreateGoalCollection(database); err != nil {
+		return err
+	}
+	if err := CreateIntegrationCollection(database); err != nil {
+		return err
+	}
+	if err := CreateDeliveryLogCollection(database); err != nil {
+		return err
+	}
+	if err := CreateVisitorCollection(database); err != nil {
+		return err
+	}
+	if err := CreateCRMCollections(database); err != nil {
+		return err
+	}
+	if err := CreateFirehoseCollections(database); err != nil {
+		return err
+	}
+	if err := CreateArchiveCollection(database); err != nil {
+		return err
+	}
+	if err := CreateAPIKeyCollection(database); err != nil {
+		return err
+	}
+	if err := CreateReconciliationCollection(database); err != nil {
+		return err
+	}
+	if err := CreateUsageCollection(database); err != nil {
+		return err
+	}
+	if err := CreateDeletionCollection(database); err != nil {
+		return err
+	}
+	if err := CreateAuditCollection(database); err != nil {
+		return err
+	}
+	if err := CreateAggregateCollection(database); err != nil {
+		return err
+	}
+	if err := CreateBenchmarkCollection(database); err != nil {
+		return err
+	}
+	if err := CreateAlertCollections(database); err != nil {
+		return err
+	}
+	if err := CreateMetricCollection(database); err != nil {
+		return err
+	}
+	if err := CreatePersonalTokenCollection(database); err != nil {
+		return err
+	}
+	if err := CreateReingestCollection(database); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreateSessionCollection create timeseries session collection if not exists.
+// Embedded MongoDB has no time series, in lite mode sessions are a plain
+// collection swept once they are past retention
+func CreateSessionCollection(database *mongo.Database) error {
+	exists, err := checkCollection(database, configs.MongoDB.SessionCollection)
 	if err != nil {
 		return err
 	}
-	if !exists {
+	if configs.Lite.Enabled {
+		expireLite(database, configs.MongoDB.SessionCollection, "time_report", RetentionDays*24*time.Hour)
+	} else if !exists {
 		logrus.Info("not exists, create collection name ", configs.MongoDB.SessionCollection)
 		ts := options.
 			TimeSeries().
@@ -39,14 +137,15 @@ func CreateSessionCollection() error {
 		opts := options.
 			CreateCollection().
 			SetTimeSeriesOptions(ts).
-			SetExpireAfterSeconds(180 * 86400)
-		err := configs.MongoDB.Client.CreateCollection(context.TODO(), configs.MongoDB.SessionCollection, opts)
+			SetExpireAfterSeconds(RetentionDays * 86400)
+		err := database.CreateCollection(context.TODO(), configs.MongoDB.SessionCollection, opts)
 		if err != nil {
 			return err
 		}
+	}
 
-		// created index
-		models := []mongo.IndexModel{
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.SessionCollection: {
 			{
 				Keys: bson.M{"meta_data.user_id": 1},
 			},
@@ -56,79 +155,151 @@ func CreateSessionCollection() error {
 			{
 				Keys: bson.M{"meta_data.website_id": 1},
 			},
-		}
-
-		collection := configs.MongoDB.Client.Collection(configs.MongoDB.SessionCollection)
-		_, CreateIndexErr := collection.Indexes().CreateMany(context.Background(), models)
-		if CreateIndexErr != nil {
-			return err
-		}
-	} else {
-		logrus.Debug("collection exists")
+		},
 	}
-	return nil
+	return createCollections(database, collections)
 }
 
-func CreateUserCollection() error {
-	exists, err := checkCollection(configs.MongoDB.UserCollection)
-	if err != nil {
-		return err
-	}
-	if !exists {
-		logrus.Info("not exists, create collection name ", configs.MongoDB.UserCollection)
-		models := []mongo.IndexModel{
+func CreateUserCollection(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.UserCollection: {
 			{
 				Keys: bson.M{"id": 1},
 			},
 			{
 				Keys: bson.M{"email": 1},
 			},
-		}
-
-		collection := configs.MongoDB.Client.Collection(configs.MongoDB.UserCollection)
-		_, err := collection.Indexes().CreateMany(context.Background(), models)
-		if err != nil {
-			return err
-		}
-	} else {
-		logrus.Debug("collection exists")
+			{
+				Keys: bson.M{"members.user_id": 1},
+			},
+			{
+				Keys: bson.M{"invitations.email": 1},
+			},
+		},
 	}
-	return nil
+	return createCollections(database, collections)
 }
 
-func CreateWebsiteCollection() error {
-	exists, err := checkCollection(configs.MongoDB.WebsiteCollection)
-	if err != nil {
-		return err
-	}
-	if !exists {
-		logrus.Info("not exists, create collection name ", configs.MongoDB.WebsiteCollection)
-		models := []mongo.IndexModel{
+func CreateWebsiteCollection(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.WebsiteCollection: {
 			{
 				Keys: bson.M{"user_id": 1},
 			},
 			{
 				Keys: bson.M{"id": 1},
 			},
-		}
+			{
+				Keys:    bson.M{"segment_write_key": 1},
+				Options: options.Index().SetUnique(true).SetSparse(true),
+			},
+			{
+				Keys:    bson.M{"tracking_id": 1},
+				Options: options.Index().SetUnique(true).SetSparse(true),
+			},
+			{
+				Keys:    bson.M{"server_keys.hash": 1},
+				Options: options.Index().SetSparse(true),
+			},
+			{
+				Keys:    bson.M{"legal_hold": 1},
+				Options: options.Index().SetSparse(true),
+			},
+		},
+	}
+	return createCollections(database, collections)
+}
 
-		collection := configs.MongoDB.Client.Collection(configs.MongoDB.WebsiteCollection)
-		_, err := collection.Indexes().CreateMany(context.Background(), models)
-		if err != nil {
-			return err
-		}
-	} else {
-		logrus.Debug("collection exists")
+// CreateDeviceCollection create collection of mobile device tokens if not exists
+func CreateDeviceCollection(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.DeviceCollection: {
+			{
+				Keys:    bson.M{"token": 1},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: bson.M{"user_id": 1},
+			},
+		},
 	}
-	return nil
+	return createCollections(database, collections)
+}
+
+// CreateGoalCollection create collection of website goals if not exists
+func CreateGoalCollection(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.GoalCollection: {
+			{
+				Keys: primitive.D{{Key: "user_id", Value: 1}, {Key: "website_id", Value: 1}},
+			},
+			{
+				Keys:    bson.M{"id": 1},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys:    bson.M{"purge_after": 1},
+				Options: options.Index().SetSparse(true),
+			},
+		},
+	}
+	return createCollections(database, collections)
+}
+
+// CreateIntegrationCollection create collection of ad platform integrations if not exists
+func CreateIntegrationCollection(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.IntegrationCollection: {
+			{
+				Keys: primitive.D{{Key: "user_id", Value: 1}, {Key: "website_id", Value: 1}},
+			},
+			{
+				Keys:    bson.M{"id": 1},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+	}
+	return createCollections(database, collections)
+}
+
+// CreateDeliveryLogCollection create collection of integration deliveries,
+// expired after DeliveryLogDays, if not exists
+func CreateDeliveryLogCollection(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.DeliveryLogCollection: {
+			{
+				Keys: primitive.D{{Key: "integration_id", Value: 1}, {Key: "created_at", Value: -1}},
+			},
+			{
+				Keys:    bson.M{"created_at": 1},
+				Options: options.Index().SetExpireAfterSeconds(DeliveryLogDays * 86400),
+			},
+		},
+	}
+	return createCollections(database, collections)
+}
+
+// CreateTenantCollection create tenant collection of multi-tenant mode if not exists
+func CreateTenantCollection() error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.TenantCollection: {
+			{
+				Keys: bson.M{"id": 1},
+			},
+			{
+				Keys: bson.M{"host_names": 1},
+			},
+		},
+	}
+	return createCollections(configs.MongoDB.Client, collections)
 }
 
 // checkCollection check collection exists or not exists
-func checkCollection(name string) (bool, error) {
+func checkCollection(database *mongo.Database, name string) (bool, error) {
 	var exists bool = false
 	filter := bson.M{}
 
-	names, err := configs.MongoDB.Client.ListCollectionNames(context.TODO(), filter, nil)
+	names, err := database.ListCollectionNames(context.TODO(), filter, nil)
 	if err != nil {
 		return false, err
 	}
@@ -141,3 +312,323 @@ func checkCollection(name string) (bool, error) {
 	}
 	return exists, nil
 }
+
+// CreateVisitorCollection create collection of identified visitors if not exists
+func CreateVisitorCollection(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.VisitorCollection: {
+			{
+				Keys:    primitive.D{{Key: "user_id", Value: 1}, {Key: "website_id", Value: 1}, {Key: "id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+	}
+	return createCollections(database, collections)
+}
+
+// CreateCRMCollections create collections of connected CRM accounts, the fields
+// websites push to them and the queue of pushes to retry, expired after
+// CRMQueueDays, if not exists
+func CreateCRMCollections(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.CRMConnectionCollection: {
+			{
+				Keys:    primitive.D{{Key: "user_id", Value: 1}, {Key: "provider", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+		configs.MongoDB.CRMMappingCollection: {
+			{
+				Keys:    primitive.D{{Key: "user_id", Value: 1}, {Key: "website_id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+		configs.MongoDB.CRMQueueCollection: {
+			{
+				Keys: bson.M{"next_attempt_at": 1},
+			},
+			{
+				Keys:    bson.M{"created_at": 1},
+				Options: options.Index().SetExpireAfterSeconds(CRMQueueDays * 86400),
+			},
+		},
+	}
+	return createCollections(database, collections)
+}
+
+// CreateFirehoseCollections create collections of firehose destinations and of
+// their hourly delivery metrics, expired after FirehoseMetricDays, if not exists
+func CreateFirehoseCollections(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.FirehoseCollection: {
+			{
+				Keys:    primitive.D{{Key: "user_id", Value: 1}, {Key: "id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+		configs.MongoDB.FirehoseMetricCollection: {
+			{
+				Keys:    primitive.D{{Key: "destination_id", Value: 1}, {Key: "hour", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys:    bson.M{"hour": 1},
+				Options: options.Index().SetExpireAfterSeconds(FirehoseMetricDays * 86400),
+			},
+		},
+	}
+	return createCollections(database, collections)
+}
+
+// CreateArchiveCollection create collection of the manifests of archived days
+// if not exists, one per website and day
+func CreateArchiveCollection(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.ArchiveCollection: {
+			{
+				Keys:    primitive.D{{Key: "user_id", Value: 1}, {Key: "website_id", Value: 1}, {Key: "day", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+	}
+	return createCollections(database, collections)
+}
+
+// CreateAPIKeyCollection create api key collection if not exists
+func CreateAPIKeyCollection(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.APIKeyCollection: {
+			{
+				Keys:    primitive.D{{Key: "id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: primitive.D{{Key: "user_id", Value: 1}},
+			},
+		},
+	}
+	return createCollections(database, collections)
+}
+
+// CreateReconciliationCollection create collection of the daily ingestion
+// counts of websites if not exists, expired with the sessions they count
+func CreateReconciliationCollection(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.ReconciliationCollection: {
+			{
+				Keys:    primitive.D{{Key: "user_id", Value: 1}, {Key: "website_id", Value: 1}, {Key: "day", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys:    bson.M{"day": 1},
+				Options: options.Index().SetExpireAfterSeconds(RetentionDays * 86400),
+			},
+			{
+				Keys:    bson.M{"late_at": 1},
+				Options: options.Index().SetSparse(true),
+			},
+		},
+	}
+	return createCollections(database, collections)
+}
+
+// CreateUsageCollection create collection of the monthly event counts of
+// websites if not exists, kept since overage is billed from it
+func CreateUsageCollection(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.UsageCollection: {
+			{
+				Keys:    primitive.D{{Key: "user_id", Value: 1}, {Key: "website_id", Value: 1}, {Key: "month", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+	}
+	return createCollections(database, collections)
+}
+
+// CreateDeletionCollection create collection of the deleted websites whose
+// data is yet to be removed if not exists
+func CreateDeletionCollection(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.DeletionCollection: {
+			{
+				Keys:    primitive.D{{Key: "user_id", Value: 1}, {Key: "website_id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: primitive.D{{Key: "next_attempt_at", Value: 1}, {Key: "created_at", Value: 1}},
+			},
+		},
+	}
+	return createCollections(database, collections)
+}
+
+// CreateAuditCollection create collection of the audit log if not exists,
+// entries are kept as long as compliance asks so they never expire
+func CreateAuditCollection(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.AuditCollection: {
+			{
+				Keys: primitive.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+			},
+			{
+				Keys: primitive.D{{Key: "website_id", Value: 1}, {Key: "created_at", Value: -1}},
+			},
+		},
+	}
+	return createCollections(database, collections)
+}
+
+// CreateAggregateCollection create collection of the daily counters of the
+// websites in aggregate-only mode if not exists, kept since they are all
+// these websites have
+func CreateAggregateCollection(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.AggregateCollection: {
+			{
+				Keys:    primitive.D{{Key: "user_id", Value: 1}, {Key: "website_id", Value: 1}, {Key: "dimension", Value: 1}, {Key: "day", Value: 1}, {Key: "value", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+	}
+	return createCollections(database, collections)
+}
+
+// CreateBenchmarkCollection create collection of the medians of the websites
+// of each category if not exists, computed again every night
+func CreateBenchmarkCollection(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.BenchmarkCollection: {
+			{
+				Keys:    bson.M{"category": 1},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+	}
+	return createCollections(database, collections)
+}
+
+// CreateAlertCollections create collections of the alert templates and of
+// their instances on websites if not exist
+func CreateAlertCollections(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.AlertTemplateCollection: {
+			{
+				Keys:    bson.M{"id": 1},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: bson.M{"user_id": 1},
+			},
+		},
+		configs.MongoDB.AlertInstanceCollection: {
+			{
+				Keys:    primitive.D{{Key: "template_id", Value: 1}, {Key: "website_id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+	}
+	return createCollections(database, collections)
+}
+
+// CreateMetricCollection create collection of the calculated metrics of
+// websites if not exists
+func CreateMetricCollection(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.MetricCollection: {
+			{
+				Keys:    primitive.D{{Key: "user_id", Value: 1}, {Key: "website_id", Value: 1}, {Key: "key", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys:    bson.M{"id": 1},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+	}
+	return createCollections(database, collections)
+}
+
+// CreateKeyCollection create collection of the keys of the instance when
+// KEY_STORE is mongo, shared by tenants whose keys are prefixed. Keys and
+// messages are removed some time after they expire
+func CreateKeyCollection() error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.KeyCollection: {
+			{
+				Keys:    bson.M{"expires_at": 1},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+			{
+				Keys:    primitive.D{{Key: "list", Value: 1}, {Key: "seq", Value: 1}},
+				Options: options.Index().SetPartialFilterExpression(bson.M{"list": bson.M{"$exists": true}}),
+			},
+			{
+				Keys:    primitive.D{{Key: "channel", Value: 1}, {Key: "seq", Value: 1}},
+				Options: options.Index().SetPartialFilterExpression(bson.M{"channel": bson.M{"$exists": true}}),
+			},
+		},
+	}
+	return createCollections(configs.MongoDB.Client, collections)
+}
+
+// CreatePersonalTokenCollection create collection of the personal tokens of
+// users if not exists
+func CreatePersonalTokenCollection(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.PersonalTokenCollection: {
+			{
+				Keys:    primitive.D{{Key: "id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: primitive.D{{Key: "user_id", Value: 1}},
+			},
+		},
+	}
+	return createCollections(database, collections)
+}
+
+// CreateReingestCollection create collection of the re-ingestion jobs if not
+// exists
+func CreateReingestCollection(database *mongo.Database) error {
+	collections := map[string][]mongo.IndexModel{
+		configs.MongoDB.ReingestCollection: {
+			{
+				Keys:    primitive.D{{Key: "id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: primitive.D{{Key: "user_id", Value: 1}, {Key: "website_id", Value: 1}, {Key: "created_at", Value: -1}},
+			},
+			{
+				Keys: primitive.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+			},
+		},
+	}
+	return createCollections(database, collections)
+}
+
+// createCollections create each collection with its indexes, and the
+// indexes added since on the collections existing already. An index existing
+// with the same keys and options is left as it is
+func createCollections(database *mongo.Database, collections map[string][]mongo.IndexModel) error {
+	for name, models := range collections {
+		exists, err := checkCollection(database, name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			logrus.Info("not exists, create collection name ", name)
+		}
+		if configs.Lite.Enabled {
+			err = createLiteIndexes(database, name, models)
+		} else {
+			_, err = database.Collection(name).Indexes().CreateMany(context.Background(), models)
+		}
+		if err != nil {
+			return fmt.Errorf("create indexes of collection %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,1288 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"analytics-api/configs"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Aggregate run pipeline on collection. The MongoDB embedded in lite mode
+// only filters, there the documents of the leading $match stages are fetched
+// and the stages after them run in the process
+func Aggregate(ctx context.Context, collection *mongo.Collection, pipeline interface{}) (*mongo.Cursor, error) {
+	if !configs.Lite.Enabled {
+		return collection.Aggregate(ctx, pipeline)
+	}
+	stages, err := stagesOf(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	filters := primitive.A{}
+	for len(stages) > 0 && stages[0][0].Key == "$match" && !hasExpr(stages[0][0].Value) {
+		filters = append(filters, stages[0][0].Value)
+		stages = stages[1:]
+	}
+	var filter interface{} = primitive.D{}
+	if len(filters) == 1 {
+		filter = filters[0]
+	} else if len(filters) > 1 {
+		filter = primitive.D{{Key: "$and", Value: filters}}
+	}
+
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var documents []primitive.D
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, err
+	}
+	documents, err = runStages(documents, stages)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]interface{}, len(documents))
+	for i, aDocument := range documents {
+		results[i] = aDocument
+	}
+	return mongo.NewCursorFromDocuments(results, nil, nil)
+}
+
+// missingValue value of a field a document does not have. Unlike null it is
+// left out of the documents built from expressions
+type missingValue struct{}
+
+var missing = missingValue{}
+
+// stagesOf pipeline with the types the driver decodes to, whichever it was
+// built with
+func stagesOf(pipeline interface{}) ([]primitive.D, error) {
+	data, err := bson.Marshal(bson.M{"pipeline": pipeline})
+	if err != nil {
+		return nil, err
+	}
+	var wrapped struct {
+		Pipeline []primitive.D `bson:"pipeline"`
+	}
+	if err := bson.Unmarshal(data, &wrapped); err != nil {
+		return nil, err
+	}
+	for _, stage := range wrapped.Pipeline {
+		if len(stage) != 1 {
+			return nil, errors.New("a stage of the pipeline must have one field")
+		}
+	}
+	return wrapped.Pipeline, nil
+}
+
+// hasExpr whether a filter compares fields with $expr, which embedded
+// MongoDB cannot run
+func hasExpr(value interface{}) bool {
+	switch value := value.(type) {
+	case primitive.D:
+		for _, element := range value {
+			if element.Key == "$expr" || hasExpr(element.Value) {
+				return true
+			}
+		}
+	case primitive.A:
+		for _, item := range value {
+			if hasExpr(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runStages run stages on documents in order
+func runStages(documents []primitive.D, stages []primitive.D) ([]primitive.D, error) {
+	var err error
+	for _, stage := range stages {
+		name, spec := stage[0].Key, stage[0].Value
+		switch name {
+		case "$match":
+			documents, err = matchStage(documents, spec)
+		case "$group":
+			documents, err = groupStage(documents, spec)
+		case "$project":
+			documents, err = projectStage(documents, spec)
+		case "$unwind":
+			documents, err = unwindStage(documents, spec)
+		case "$sort":
+			documents, err = sortStage(documents, spec)
+		case "$limit":
+			documents, err = limitStage(documents, spec)
+		case "$count":
+			documents, err = countStage(documents, spec)
+		default:
+			err = fmt.Errorf("stage %s is not supported in lite mode", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return documents, nil
+}
+
+func matchStage(documents []primitive.D, spec interface{}) ([]primitive.D, error) {
+	query, ok := spec.(primitive.D)
+	if !ok {
+		return nil, errors.New("$match takes a document")
+	}
+	matched := []primitive.D{}
+	for _, aDocument := range documents {
+		ok, err := matches(aDocument, query)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, aDocument)
+		}
+	}
+	return matched, nil
+}
+
+// group documents sharing the value of _id with their accumulators
+type group struct {
+	id     interface{}
+	values []interface{}
+	seen   []map[string]bool
+}
+
+func groupStage(documents []primitive.D, spec interface{}) ([]primitive.D, error) {
+	fields, ok := spec.(primitive.D)
+	if !ok {
+		return nil, errors.New("$group takes a document")
+	}
+	var idExpr interface{}
+	var accumulators primitive.D
+	for _, field := range fields {
+		if field.Key == "_id" {
+			idExpr = field.Value
+			continue
+		}
+		accumulator, ok := field.Value.(primitive.D)
+		if !ok || len(accumulator) != 1 {
+			return nil, fmt.Errorf("$group field %s must be an accumulator", field.Key)
+		}
+		accumulators = append(accumulators, primitive.E{Key: field.Key, Value: accumulator})
+	}
+
+	groups := map[string]*group{}
+	var order []string
+	for _, aDocument := range documents {
+		id, err := evaluate(idExpr, aDocument, nil)
+		if err != nil {
+			return nil, err
+		}
+		id = nullIfMissing(id)
+		key := keyOf(id)
+		aGroup := groups[key]
+		if aGroup == nil {
+			aGroup = &group{id: id, values: make([]interface{}, len(accumulators)), seen: make([]map[string]bool, len(accumulators))}
+			for i := range aGroup.values {
+				aGroup.values[i] = missing
+			}
+			groups[key] = aGroup
+			order = append(order, key)
+		}
+		for i, field := range accumulators {
+			accumulator := field.Value.(primitive.D)[0]
+			value, err := evaluate(accumulator.Value, aDocument, nil)
+			if err != nil {
+				return nil, err
+			}
+			if err := accumulate(aGroup, i, accumulator.Key, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	grouped := make([]primitive.D, 0, len(order))
+	for _, key := range order {
+		aGroup := groups[key]
+		aDocument := primitive.D{{Key: "_id", Value: aGroup.id}}
+		for i, field := range accumulators {
+			value := aGroup.values[i]
+			if field.Value.(primitive.D)[0].Key == "$sum" && value == missing {
+				value = int32(0)
+			}
+			aDocument = append(aDocument, primitive.E{Key: field.Key, Value: nullIfMissing(value)})
+		}
+		grouped = append(grouped, aDocument)
+	}
+	return grouped, nil
+}
+
+// accumulate value into the accumulator at i of aGroup
+func accumulate(aGroup *group, i int, operator string, value interface{}) error {
+	current := aGroup.values[i]
+	switch operator {
+	case "$sum":
+		if !isNumber(value) {
+			return nil
+		}
+		if current == missing {
+			current = int32(0)
+		}
+		sum, err := arithmetic(current, value, false)
+		if err != nil {
+			return err
+		}
+		aGroup.values[i] = sum
+	case "$first":
+		if current == missing {
+			aGroup.values[i] = nullIfMissing(value)
+		}
+	case "$max", "$min":
+		if value == missing || value == nil {
+			return nil
+		}
+		if current == missing || current == nil {
+			aGroup.values[i] = value
+			return nil
+		}
+		order := compare(value, current)
+		if (operator == "$max" && order > 0) || (operator == "$min" && order < 0) {
+			aGroup.values[i] = value
+		}
+	case "$addToSet":
+		if value == missing {
+			return nil
+		}
+		if current == missing {
+			current = primitive.A{}
+			aGroup.seen[i] = map[string]bool{}
+		}
+		set := current.(primitive.A)
+		if key := keyOf(value); !aGroup.seen[i][key] {
+			aGroup.seen[i][key] = true
+			set = append(set, value)
+		}
+		aGroup.values[i] = set
+	default:
+		return fmt.Errorf("accumulator %s is not supported in lite mode", operator)
+	}
+	return nil
+}
+
+func projectStage(documents []primitive.D, spec interface{}) ([]primitive.D, error) {
+	fields, ok := spec.(primitive.D)
+	if !ok {
+		return nil, errors.New("$project takes a document")
+	}
+	excluding, keepID, computedID := true, true, false
+	for _, field := range fields {
+		include, isFlag := flag(field.Value)
+		if field.Key == "_id" && isFlag {
+			keepID = include
+			continue
+		}
+		computedID = computedID || field.Key == "_id"
+		if !isFlag || include {
+			excluding = false
+		}
+	}
+
+	projected := make([]primitive.D, 0, len(documents))
+	for _, aDocument := range documents {
+		if excluding {
+			aProjection := primitive.D{}
+			for _, element := range aDocument {
+				if !excludes(fields, element.Key) && (element.Key != "_id" || keepID) {
+					aProjection = append(aProjection, element)
+				}
+			}
+			projected = append(projected, aProjection)
+			continue
+		}
+
+		aProjection := primitive.D{}
+		if keepID && !computedID {
+			if id := lookup(aDocument, "_id"); id != missing {
+				aProjection = append(aProjection, primitive.E{Key: "_id", Value: id})
+			}
+		}
+		for _, field := range fields {
+			if _, isFlag := flag(field.Value); field.Key == "_id" && isFlag {
+				continue
+			}
+			if strings.Contains(field.Key, ".") {
+				return nil, fmt.Errorf("$project of the dotted field %s is not supported in lite mode", field.Key)
+			}
+			var value interface{}
+			if include, isFlag := flag(field.Value); isFlag && include {
+				value = lookup(aDocument, field.Key)
+			} else {
+				var err error
+				if value, err = evaluate(field.Value, aDocument, nil); err != nil {
+					return nil, err
+				}
+			}
+			if value != missing {
+				aProjection = append(aProjection, primitive.E{Key: field.Key, Value: value})
+			}
+		}
+		projected = append(projected, aProjection)
+	}
+	return projected, nil
+}
+
+// excludes whether the fields of a $project leave key out
+func excludes(fields primitive.D, key string) bool {
+	for _, field := range fields {
+		if field.Key == key {
+			include, isFlag := flag(field.Value)
+			return isFlag && !include
+		}
+	}
+	return false
+}
+
+// flag whether value of a $project field includes it, when it is a flag
+// rather than an expression
+func flag(value interface{}) (bool, bool) {
+	switch value := value.(type) {
+	case bool:
+		return value, true
+	case int32, int64, float64:
+		number, _ := toFloat(value)
+		return number != 0, true
+	}
+	return false, false
+}
+
+func unwindStage(documents []primitive.D, spec interface{}) ([]primitive.D, error) {
+	path, ok := spec.(string)
+	if aSpec, isDocument := spec.(primitive.D); isDocument {
+		path, ok = lookup(aSpec, "path").(string)
+	}
+	if !ok || !strings.HasPrefix(path, "$") || strings.Contains(path, ".") {
+		return nil, errors.New("$unwind takes the path of a top level field in lite mode")
+	}
+	field := path[1:]
+	unwound := []primitive.D{}
+	for _, aDocument := range documents {
+		value := lookup(aDocument, field)
+		items, isArray := value.(primitive.A)
+		if !isArray {
+			if value != missing && value != nil {
+				unwound = append(unwound, aDocument)
+			}
+			continue
+		}
+		for _, item := range items {
+			unwound = append(unwound, withField(aDocument, field, item))
+		}
+	}
+	return unwound, nil
+}
+
+// withField copy of aDocument with its top level field key set to value
+func withField(aDocument primitive.D, key string, value interface{}) primitive.D {
+	copied := make(primitive.D, len(aDocument))
+	copy(copied, aDocument)
+	for i := range copied {
+		if copied[i].Key == key {
+			copied[i].Value = value
+			return copied
+		}
+	}
+	return append(copied, primitive.E{Key: key, Value: value})
+}
+
+func sortStage(documents []primitive.D, spec interface{}) ([]primitive.D, error) {
+	keys, ok := spec.(primitive.D)
+	if !ok || len(keys) == 0 {
+		return nil, errors.New("$sort takes a document")
+	}
+	sorted := make([]primitive.D, len(documents))
+	copy(sorted, documents)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		for _, key := range keys {
+			order := compare(nullIfMissing(lookup(sorted[i], key.Key)), nullIfMissing(lookup(sorted[j], key.Key)))
+			if direction, _ := toFloat(key.Value); direction < 0 {
+				order = -order
+			}
+			if order != 0 {
+				return order < 0
+			}
+		}
+		return false
+	})
+	return sorted, nil
+}
+
+func limitStage(documents []primitive.D, spec interface{}) ([]primitive.D, error) {
+	limit, ok := toFloat(spec)
+	if !ok || limit < 1 {
+		return nil, errors.New("$limit takes a positive number")
+	}
+	if int(limit) < len(documents) {
+		documents = documents[:int(limit)]
+	}
+	return documents, nil
+}
+
+func countStage(documents []primitive.D, spec interface{}) ([]primitive.D, error) {
+	name, ok := spec.(string)
+	if !ok || name == "" {
+		return nil, errors.New("$count takes the name of a field")
+	}
+	if len(documents) == 0 {
+		return []primitive.D{}, nil
+	}
+	return []primitive.D{{{Key: name, Value: int32(len(documents))}}}, nil
+}
+
+// matches whether aDocument matches query, a filter of find
+func matches(aDocument primitive.D, query primitive.D) (bool, error) {
+	for _, element := range query {
+		var ok bool
+		var err error
+		switch element.Key {
+		case "$and", "$or", "$nor":
+			ok, err = matchesAll(aDocument, element.Key, element.Value)
+		case "$expr":
+			var value interface{}
+			if value, err = evaluate(element.Value, aDocument, nil); err == nil {
+				ok = truthy(value)
+			}
+		default:
+			if strings.HasPrefix(element.Key, "$") {
+				return false, fmt.Errorf("query operator %s is not supported in lite mode", element.Key)
+			}
+			ok, err = matchesField(lookup(aDocument, element.Key), element.Value)
+		}
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchesAll whether aDocument matches the queries of a $and, $or or $nor
+func matchesAll(aDocument primitive.D, operator string, value interface{}) (bool, error) {
+	queries, ok := value.(primitive.A)
+	if !ok {
+		return false, fmt.Errorf("%s takes an array", operator)
+	}
+	for _, item := range queries {
+		query, ok := item.(primitive.D)
+		if !ok {
+			return false, fmt.Errorf("%s takes an array of documents", operator)
+		}
+		matched, err := matches(aDocument, query)
+		if err != nil {
+			return false, err
+		}
+		if operator == "$and" && !matched {
+			return false, nil
+		}
+		if operator != "$and" && matched {
+			return operator == "$or", nil
+		}
+	}
+	return operator != "$or", nil
+}
+
+// matchesField whether value of a field matches condition, a value it
+// equals or a document of operators
+func matchesField(value interface{}, condition interface{}) (bool, error) {
+	operators, ok := condition.(primitive.D)
+	if !ok || len(operators) == 0 || !strings.HasPrefix(operators[0].Key, "$") {
+		return equals(value, condition), nil
+	}
+	for _, operator := range operators {
+		var ok bool
+		switch operator.Key {
+		case "$eq":
+			ok = equals(value, operator.Value)
+		case "$ne":
+			ok = !equals(value, operator.Value)
+		case "$gt", "$gte", "$lt", "$lte":
+			ok = comparesTo(value, operator.Key, operator.Value)
+		case "$in", "$nin":
+			candidates, isArray := operator.Value.(primitive.A)
+			if !isArray {
+				return false, fmt.Errorf("%s takes an array", operator.Key)
+			}
+			for _, candidate := range candidates {
+				if equals(value, candidate) {
+					ok = true
+					break
+				}
+			}
+			ok = ok == (operator.Key == "$in")
+		case "$exists":
+			ok = (value != missing) == truthy(operator.Value)
+		default:
+			return false, fmt.Errorf("query operator %s is not supported in lite mode", operator.Key)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// equals whether value of a field equals expected, or holds it when it is
+// an array. A missing field equals null
+func equals(value, expected interface{}) bool {
+	if expected == nil && (value == nil || value == missing) {
+		return true
+	}
+	if items, ok := value.(primitive.A); ok {
+		for _, item := range items {
+			if compare(item, expected) == 0 {
+				return true
+			}
+		}
+	}
+	return value != missing && compare(value, expected) == 0
+}
+
+// comparesTo whether value of a field, or an item of it, compares to
+// operand as operator says. Only values of the same type compare
+func comparesTo(value interface{}, operator string, operand interface{}) bool {
+	candidates := primitive.A{value}
+	if items, ok := value.(primitive.A); ok {
+		candidates = append(candidates, items...)
+	}
+	for _, candidate := range candidates {
+		if candidate == missing || rank(candidate) != rank(operand) {
+			continue
+		}
+		order := compare(candidate, operand)
+		switch {
+		case operator == "$gt" && order > 0, operator == "$gte" && order >= 0,
+			operator == "$lt" && order < 0, operator == "$lte" && order <= 0:
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate expression of an aggregation on aDocument, vars are those of $let
+func evaluate(expression interface{}, aDocument primitive.D, vars map[string]interface{}) (interface{}, error) {
+	switch expression := expression.(type) {
+	case string:
+		if strings.HasPrefix(expression, "$$") {
+			name, path, _ := strings.Cut(expression[2:], ".")
+			value, ok := vars[name]
+			if name == "ROOT" || name == "CURRENT" {
+				value, ok = aDocument, true
+			}
+			if !ok {
+				return nil, fmt.Errorf("variable %s is not defined", name)
+			}
+			if path == "" {
+				return value, nil
+			}
+			return lookup(value, path), nil
+		}
+		if strings.HasPrefix(expression, "$") {
+			return lookup(aDocument, expression[1:]), nil
+		}
+		return expression, nil
+	case primitive.D:
+		if len(expression) == 1 && strings.HasPrefix(expression[0].Key, "$") {
+			return operate(expression[0].Key, expression[0].Value, aDocument, vars)
+		}
+		built := primitive.D{}
+		for _, element := range expression {
+			value, err := evaluate(element.Value, aDocument, vars)
+			if err != nil {
+				return nil, err
+			}
+			if value != missing {
+				built = append(built, primitive.E{Key: element.Key, Value: value})
+			}
+		}
+		return built, nil
+	case primitive.A:
+		built := make(primitive.A, len(expression))
+		for i, item := range expression {
+			value, err := evaluate(item, aDocument, vars)
+			if err != nil {
+				return nil, err
+			}
+			built[i] = nullIfMissing(value)
+		}
+		return built, nil
+	}
+	return expression, nil
+}
+
+// arguments values of the arguments of an operator, a single one when it is
+// not given an array
+func arguments(args interface{}, aDocument primitive.D, vars map[string]interface{}) ([]interface{}, error) {
+	list, ok := args.(primitive.A)
+	if !ok {
+		list = primitive.A{args}
+	}
+	values := make([]interface{}, len(list))
+	for i, item := range list {
+		value, err := evaluate(item, aDocument, vars)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// operate evaluate the operator of an expression with its args
+func operate(operator string, args interface{}, aDocument primitive.D, vars map[string]interface{}) (interface{}, error) {
+	switch operator {
+	case "$literal":
+		return args, nil
+	case "$cond":
+		return condition(args, aDocument, vars)
+	case "$let":
+		return let(args, aDocument, vars)
+	case "$dateToString":
+		return dateToString(args, aDocument, vars)
+	case "$dayOfWeek", "$hour":
+		return datePart(operator, args, aDocument, vars)
+	case "$regexFind":
+		return regexFind(args, aDocument, vars)
+	}
+
+	values, err := arguments(args, aDocument, vars)
+	if err != nil {
+		return nil, err
+	}
+	switch operator {
+	case "$and", "$or":
+		for _, value := range values {
+			if truthy(value) != (operator == "$and") {
+				return operator == "$or", nil
+			}
+		}
+		return operator == "$and", nil
+	case "$not":
+		return !truthy(values[0]), nil
+	case "$eq", "$ne", "$gt", "$gte", "$lt", "$lte":
+		if len(values) != 2 {
+			return nil, fmt.Errorf("%s takes 2 arguments", operator)
+		}
+		order := compare(values[0], values[1])
+		switch operator {
+		case "$eq":
+			return order == 0, nil
+		case "$ne":
+			return order != 0, nil
+		case "$gt":
+			return order > 0, nil
+		case "$gte":
+			return order >= 0, nil
+		case "$lt":
+			return order < 0, nil
+		}
+		return order <= 0, nil
+	case "$in":
+		if len(values) != 2 {
+			return nil, errors.New("$in takes 2 arguments")
+		}
+		items, ok := values[1].(primitive.A)
+		if !ok {
+			return nil, errors.New("$in takes an array as second argument")
+		}
+		for _, item := range items {
+			if compare(values[0], item) == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$ifNull":
+		for _, value := range values[:len(values)-1] {
+			if value != nil && value != missing {
+				return value, nil
+			}
+		}
+		return values[len(values)-1], nil
+	case "$add", "$multiply":
+		var result interface{} = int32(0)
+		if operator == "$multiply" {
+			result = int32(1)
+		}
+		for _, value := range values {
+			if value == nil || value == missing {
+				return nil, nil
+			}
+			if result, err = arithmetic(result, value, operator == "$multiply"); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	case "$toInt":
+		return toInt(values[0])
+	case "$split":
+		if len(values) != 2 {
+			return nil, errors.New("$split takes 2 arguments")
+		}
+		if values[0] == nil || values[0] == missing {
+			return nil, nil
+		}
+		text, ok := values[0].(string)
+		separator, isString := values[1].(string)
+		if !ok || !isString {
+			return nil, errors.New("$split takes strings")
+		}
+		parts := primitive.A{}
+		for _, part := range strings.Split(text, separator) {
+			parts = append(parts, part)
+		}
+		return parts, nil
+	case "$arrayElemAt":
+		if len(values) != 2 {
+			return nil, errors.New("$arrayElemAt takes 2 arguments")
+		}
+		if values[0] == nil || values[0] == missing {
+			return nil, nil
+		}
+		items, ok := values[0].(primitive.A)
+		index, isNumber := toFloat(values[1])
+		if !ok || !isNumber {
+			return nil, errors.New("$arrayElemAt takes an array and an index")
+		}
+		at := int(index)
+		if at < 0 {
+			at += len(items)
+		}
+		if at < 0 || at >= len(items) {
+			return missing, nil
+		}
+		return items[at], nil
+	case "$size":
+		items, ok := values[0].(primitive.A)
+		if !ok {
+			return nil, errors.New("$size takes an array")
+		}
+		return int32(len(items)), nil
+	case "$setDifference":
+		if len(values) != 2 {
+			return nil, errors.New("$setDifference takes 2 arguments")
+		}
+		if values[0] == nil || values[0] == missing || values[1] == nil || values[1] == missing {
+			return nil, nil
+		}
+		first, ok := values[0].(primitive.A)
+		second, isArray := values[1].(primitive.A)
+		if !ok || !isArray {
+			return nil, errors.New("$setDifference takes arrays")
+		}
+		seen := map[string]bool{}
+		for _, item := range second {
+			seen[keyOf(item)] = true
+		}
+		difference := primitive.A{}
+		for _, item := range first {
+			if key := keyOf(item); !seen[key] {
+				seen[key] = true
+				difference = append(difference, item)
+			}
+		}
+		return difference, nil
+	}
+	return nil, fmt.Errorf("expression %s is not supported in lite mode", operator)
+}
+
+// condition value of a $cond, given as [if, then, else] or a document
+func condition(args interface{}, aDocument primitive.D, vars map[string]interface{}) (interface{}, error) {
+	branches, ok := args.(primitive.A)
+	if spec, isDocument := args.(primitive.D); isDocument {
+		branches, ok = primitive.A{lookup(spec, "if"), lookup(spec, "then"), lookup(spec, "else")}, true
+	}
+	if !ok || len(branches) != 3 {
+		return nil, errors.New("$cond takes if, then and else")
+	}
+	test, err := evaluate(branches[0], aDocument, vars)
+	if err != nil {
+		return nil, err
+	}
+	if truthy(test) {
+		return evaluate(branches[1], aDocument, vars)
+	}
+	return evaluate(branches[2], aDocument, vars)
+}
+
+// let evaluate in of a $let with its vars set
+func let(args interface{}, aDocument primitive.D, vars map[string]interface{}) (interface{}, error) {
+	spec, ok := args.(primitive.D)
+	if !ok {
+		return nil, errors.New("$let takes a document")
+	}
+	definitions, ok := lookup(spec, "vars").(primitive.D)
+	if !ok {
+		return nil, errors.New("$let takes vars")
+	}
+	scope := map[string]interface{}{}
+	for name, value := range vars {
+		scope[name] = value
+	}
+	for _, definition := range definitions {
+		value, err := evaluate(definition.Value, aDocument, vars)
+		if err != nil {
+			return nil, err
+		}
+		scope[definition.Key] = value
+	}
+	return evaluate(lookup(spec, "in"), aDocument, scope)
+}
+
+// dateOf date and timezone an operator on dates is given, nil when the
+// date is null
+func dateOf(args interface{}, aDocument primitive.D, vars map[string]interface{}) (*time.Time, error) {
+	dateExpr, timezone := args, interface{}("UTC")
+	if spec, ok := args.(primitive.D); ok && lookup(spec, "date") != missing {
+		dateExpr = lookup(spec, "date")
+		if zone := lookup(spec, "timezone"); zone != missing {
+			timezone = zone
+		}
+	}
+	value, err := evaluate(dateExpr, aDocument, vars)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil || value == missing {
+		return nil, nil
+	}
+	date, ok := value.(primitive.DateTime)
+	if !ok {
+		return nil, errors.New("operators on dates take a date")
+	}
+	zone, err := evaluate(timezone, aDocument, vars)
+	if err != nil {
+		return nil, err
+	}
+	name, ok := zone.(string)
+	if !ok {
+		return nil, errors.New("timezone must be a string")
+	}
+	location, err := locationOf(name)
+	if err != nil {
+		return nil, err
+	}
+	at := date.Time().In(location)
+	return &at, nil
+}
+
+// locationOf timezone given by name or as an offset like +07:00
+func locationOf(name string) (*time.Location, error) {
+	if name != "" && (name[0] == '+' || name[0] == '-') {
+		offset, err := time.Parse("-07:00", name)
+		if err != nil {
+			offset, err = time.Parse("-0700", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %s", name)
+		}
+		_, seconds := offset.Zone()
+		return time.FixedZone(name, seconds), nil
+	}
+	return time.LoadLocation(name)
+}
+
+func datePart(operator string, args interface{}, aDocument primitive.D, vars map[string]interface{}) (interface{}, error) {
+	at, err := dateOf(args, aDocument, vars)
+	if err != nil || at == nil {
+		return nil, err
+	}
+	if operator == "$hour" {
+		return int32(at.Hour()), nil
+	}
+	// Sunday is 1 and Saturday 7
+	return int32(at.Weekday()) + 1, nil
+}
+
+func dateToString(args interface{}, aDocument primitive.D, vars map[string]interface{}) (interface{}, error) {
+	spec, ok := args.(primitive.D)
+	if !ok {
+		return nil, errors.New("$dateToString takes a document")
+	}
+	at, err := dateOf(args, aDocument, vars)
+	if err != nil {
+		return nil, err
+	}
+	if at == nil {
+		if onNull := lookup(spec, "onNull"); onNull != missing {
+			return evaluate(onNull, aDocument, vars)
+		}
+		return nil, nil
+	}
+	format, ok := lookup(spec, "format").(string)
+	if !ok {
+		format = "%Y-%m-%dT%H:%M:%S.%LZ"
+	}
+	var formatted strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i == len(format)-1 {
+			formatted.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'Y':
+			fmt.Fprintf(&formatted, "%04d", at.Year())
+		case 'm':
+			fmt.Fprintf(&formatted, "%02d", int(at.Month()))
+		case 'd':
+			fmt.Fprintf(&formatted, "%02d", at.Day())
+		case 'H':
+			fmt.Fprintf(&formatted, "%02d", at.Hour())
+		case 'M':
+			fmt.Fprintf(&formatted, "%02d", at.Minute())
+		case 'S':
+			fmt.Fprintf(&formatted, "%02d", at.Second())
+		case 'L':
+			fmt.Fprintf(&formatted, "%03d", at.Nanosecond()/int(time.Millisecond))
+		case 'j':
+			fmt.Fprintf(&formatted, "%03d", at.YearDay())
+		case '%':
+			formatted.WriteByte('%')
+		default:
+			return nil, fmt.Errorf("$dateToString format %%%c is not supported in lite mode", format[i])
+		}
+	}
+	return formatted.String(), nil
+}
+
+// regexFind first match of a $regexFind with its captures, null when none
+func regexFind(args interface{}, aDocument primitive.D, vars map[string]interface{}) (interface{}, error) {
+	spec, ok := args.(primitive.D)
+	if !ok {
+		return nil, errors.New("$regexFind takes a document")
+	}
+	input, err := evaluate(lookup(spec, "input"), aDocument, vars)
+	if err != nil {
+		return nil, err
+	}
+	if input == nil || input == missing {
+		return nil, nil
+	}
+	text, ok := input.(string)
+	pattern, isString := lookup(spec, "regex").(string)
+	if !ok || !isString {
+		return nil, errors.New("$regexFind takes a string input and regex")
+	}
+	if flags, ok := lookup(spec, "options").(string); ok && flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	expression, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	at := expression.FindStringSubmatchIndex(text)
+	if at == nil {
+		return nil, nil
+	}
+	captures := primitive.A{}
+	for i := 2; i < len(at); i += 2 {
+		if at[i] < 0 {
+			captures = append(captures, nil)
+			continue
+		}
+		captures = append(captures, text[at[i]:at[i+1]])
+	}
+	return primitive.D{
+		{Key: "match", Value: text[at[0]:at[1]]},
+		{Key: "idx", Value: int32(len([]rune(text[:at[0]])))},
+		{Key: "captures", Value: captures},
+	}, nil
+}
+
+// lookup value at the dotted path of value, missing when it has none. A path
+// through an array gives the values of its documents
+func lookup(value interface{}, path string) interface{} {
+	if path == "" {
+		return value
+	}
+	key, rest, _ := strings.Cut(path, ".")
+	switch value := value.(type) {
+	case primitive.D:
+		for _, element := range value {
+			if element.Key == key {
+				return lookup(element.Value, rest)
+			}
+		}
+	case primitive.A:
+		values := primitive.A{}
+		for _, item := range value {
+			if _, ok := item.(primitive.D); !ok {
+				continue
+			}
+			if found := lookup(item, path); found != missing {
+				values = append(values, found)
+			}
+		}
+		return values
+	}
+	return missing
+}
+
+func nullIfMissing(value interface{}) interface{} {
+	if value == missing {
+		return nil
+	}
+	return value
+}
+
+// truthy whether value is true to $cond, $and and $or: all but null,
+// missing, false and zero
+func truthy(value interface{}) bool {
+	switch value := value.(type) {
+	case nil, missingValue:
+		return false
+	case bool:
+		return value
+	case int32, int64, float64:
+		number, _ := toFloat(value)
+		return number != 0
+	}
+	return true
+}
+
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case int32, int64, float64:
+		return true
+	}
+	return false
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case int32:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case float64:
+		return value, true
+	}
+	return 0, false
+}
+
+// arithmetic sum, or product when multiply, of two numbers: an int32 while it
+// fits, then an int64, a double once one of them is
+func arithmetic(a, b interface{}, multiply bool) (interface{}, error) {
+	if !isNumber(a) || !isNumber(b) {
+		return nil, errors.New("arithmetic takes numbers")
+	}
+	_, aDouble := a.(float64)
+	_, bDouble := b.(float64)
+	if aDouble || bDouble {
+		x, _ := toFloat(a)
+		y, _ := toFloat(b)
+		if multiply {
+			return x * y, nil
+		}
+		return x + y, nil
+	}
+	x, y := toInt64(a), toInt64(b)
+	result := x + y
+	if multiply {
+		result = x * y
+	}
+	_, aLong := a.(int64)
+	_, bLong := b.(int64)
+	if !aLong && !bLong && result >= math.MinInt32 && result <= math.MaxInt32 {
+		return int32(result), nil
+	}
+	return result, nil
+}
+
+func toInt64(value interface{}) int64 {
+	switch value := value.(type) {
+	case int32:
+		return int64(value)
+	case int64:
+		return value
+	case float64:
+		return int64(value)
+	}
+	return 0
+}
+
+// toInt value of $toInt, null stays null
+func toInt(value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case nil, missingValue:
+		return nil, nil
+	case bool:
+		if value {
+			return int32(1), nil
+		}
+		return int32(0), nil
+	case int32, int64, float64:
+		return int32(toInt64(value)), nil
+	case string:
+		number, err := strconv.ParseInt(strings.TrimSpace(value), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("$toInt cannot convert %q", value)
+		}
+		return int32(number), nil
+	}
+	return nil, fmt.Errorf("$toInt cannot convert %T", value)
+}
+
+// rank of the type of value in the order of BSON comparisons
+func rank(value interface{}) int {
+	switch value.(type) {
+	case missingValue:
+		return 0
+	case nil:
+		return 1
+	case int32, int64, float64:
+		return 2
+	case string:
+		return 3
+	case primitive.D:
+		return 4
+	case primitive.A:
+		return 5
+	case primitive.Binary:
+		return 6
+	case primitive.ObjectID:
+		return 7
+	case bool:
+		return 8
+	case primitive.DateTime:
+		return 9
+	case primitive.Timestamp:
+		return 10
+	}
+	return 11
+}
+
+// compare a with b as MongoDB orders values: -1, 0 or 1
+func compare(a, b interface{}) int {
+	if rankA, rankB := rank(a), rank(b); rankA != rankB {
+		return sign(rankA - rankB)
+	}
+	switch a := a.(type) {
+	case int32, int64, float64:
+		if isInteger(a) && isInteger(b) {
+			return compareInt(toInt64(a), toInt64(b))
+		}
+		x, _ := toFloat(a)
+		y, _ := toFloat(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(a, b.(string))
+	case primitive.D:
+		b := b.(primitive.D)
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if order := strings.Compare(a[i].Key, b[i].Key); order != 0 {
+				return order
+			}
+			if order := compare(a[i].Value, b[i].Value); order != 0 {
+				return order
+			}
+		}
+		return compareInt(int64(len(a)), int64(len(b)))
+	case primitive.A:
+		b := b.(primitive.A)
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if order := compare(a[i], b[i]); order != 0 {
+				return order
+			}
+		}
+		return compareInt(int64(len(a)), int64(len(b)))
+	case primitive.ObjectID:
+		return strings.Compare(a.Hex(), b.(primitive.ObjectID).Hex())
+	case bool:
+		bBool := b.(bool)
+		switch {
+		case a == bBool:
+			return 0
+		case bBool:
+			return -1
+		}
+		return 1
+	case primitive.DateTime:
+		return compareInt(int64(a), int64(b.(primitive.DateTime)))
+	case primitive.Timestamp:
+		return primitive.CompareTimestamp(a, b.(primitive.Timestamp))
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func isInteger(value interface{}) bool {
+	_, isDouble := value.(float64)
+	return !isDouble
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func sign(n int) int {
+	return compareInt(int64(n), 0)
+}
+
+// keyOf value as a string equal for values MongoDB groups together, numbers
+// of any type included
+func keyOf(value interface{}) string {
+	switch value := value.(type) {
+	case nil, missingValue:
+		return "null"
+	case int32, int64, float64:
+		if isInteger(value) {
+			return "n" + strconv.FormatInt(toInt64(value), 10)
+		}
+		if number := value.(float64); number == math.Trunc(number) && math.Abs(number) < 1<<53 {
+			return "n" + strconv.FormatInt(int64(number), 10)
+		}
+		return "n" + strconv.FormatFloat(value.(float64), 'g', -1, 64)
+	case string:
+		return "s" + strconv.Quote(value)
+	case primitive.D:
+		var key strings.Builder
+		key.WriteString("{")
+		for _, element := range value {
+			key.WriteString(strconv.Quote(element.Key) + ":" + keyOf(element.Value) + ",")
+		}
+		key.WriteString("}")
+		return key.String()
+	case primitive.A:
+		var key strings.Builder
+		key.WriteString("[")
+		for _, item := range value {
+			key.WriteString(keyOf(item) + ",")
+		}
+		key.WriteString("]")
+		return key.String()
+	case primitive.ObjectID:
+		return "o" + value.Hex()
+	case primitive.DateTime:
+		return "d" + strconv.FormatInt(int64(value), 10)
+	}
+	return fmt.Sprintf("%T%v", value, value)
+}
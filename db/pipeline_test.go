@@ -0,0 +1,373 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestRunStages(t *testing.T) {
+	at := func(value string) primitive.DateTime {
+		parsed, _ := time.Parse(time.RFC3339, value)
+		return primitive.NewDateTimeFromTime(parsed)
+	}
+	documents := []primitive.D{
+		{{Key: "platform", Value: "web"}, {Key: "time_report", Value: at("2024-01-01T18:00:00Z")}, {Key: "pages", Value: primitive.A{"/", "/pricing"}}, {Key: "views", Value: int32(2)}},
+		{{Key: "platform", Value: "ios"}, {Key: "time_report", Value: at("2024-01-01T16:00:00Z")}, {Key: "pages", Value: primitive.A{"/"}}, {Key: "views", Value: int32(1)}},
+		{{Key: "platform", Value: "web"}, {Key: "time_report", Value: at("2024-01-02T03:00:00Z")}, {Key: "pages", Value: primitive.A{}}, {Key: "views", Value: int32(4)}},
+	}
+	tests := []struct {
+		name     string
+		pipeline []bson.M
+		want     []primitive.D
+		wantErr  string
+	}{
+		{
+			name: "should group, sum and sort",
+			pipeline: []bson.M{
+				{"$group": bson.M{"_id": "$platform", "sessions": bson.M{"$sum": 1}, "views": bson.M{"$sum": "$views"}}},
+				{"$sort": bson.M{"sessions": -1}},
+			},
+			want: []primitive.D{
+				{{Key: "_id", Value: "web"}, {Key: "sessions", Value: int32(2)}, {Key: "views", Value: int32(6)}},
+				{{Key: "_id", Value: "ios"}, {Key: "sessions", Value: int32(1)}, {Key: "views", Value: int32(1)}},
+			},
+		},
+		{
+			name: "should group by day in the timezone",
+			pipeline: []bson.M{
+				{"$group": bson.M{"_id": bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$time_report", "timezone": "Asia/Ho_Chi_Minh"}}, "sessions": bson.M{"$sum": 1}}},
+				{"$sort": bson.M{"_id": 1}},
+			},
+			want: []primitive.D{
+				{{Key: "_id", Value: "2024-01-01"}, {Key: "sessions", Value: int32(1)}},
+				{{Key: "_id", Value: "2024-01-02"}, {Key: "sessions", Value: int32(2)}},
+			},
+		},
+		{
+			name: "should unwind arrays and count the items",
+			pipeline: []bson.M{
+				{"$unwind": "$pages"},
+				{"$match": bson.M{"pages": "/"}},
+				{"$count": "total"},
+			},
+			want: []primitive.D{{{Key: "total", Value: int32(2)}}},
+		},
+		{
+			name: "should match with $expr and project computed fields",
+			pipeline: []bson.M{
+				{"$match": bson.M{"$expr": bson.M{"$gt": []interface{}{"$views", 1}}}},
+				{"$project": bson.M{"_id": 0, "double": bson.M{"$multiply": []interface{}{"$views", 2}}}},
+				{"$sort": bson.M{"double": 1}},
+				{"$limit": 1},
+			},
+			want: []primitive.D{{{Key: "double", Value: int32(4)}}},
+		},
+		{
+			name:     "should fail on a stage it cannot run",
+			pipeline: []bson.M{{"$lookup": bson.M{"from": "website"}}},
+			wantErr:  "stage $lookup is not supported in lite mode",
+		},
+		{
+			name:     "should fail on an accumulator it cannot run",
+			pipeline: []bson.M{{"$group": bson.M{"_id": nil, "average": bson.M{"$avg": "$views"}}}},
+			wantErr:  "accumulator $avg is not supported in lite mode",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stages, err := stagesOf(tt.pipeline)
+			if err != nil {
+				t.Fatalf("stagesOf() error = %v", err)
+			}
+			got, err := runStages(documents, stages)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("runStages() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("runStages() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("runStages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasExpr(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter bson.M
+		want   bool
+	}{
+		{name: "should find $expr at the top", filter: bson.M{"$expr": bson.M{"$gt": []interface{}{"$a", "$b"}}}, want: true},
+		{name: "should find $expr within $or", filter: bson.M{"$or": []bson.M{{"a": 1}, {"$expr": true}}}, want: true},
+		{name: "should run filters without $expr", filter: bson.M{"website_id": "id", "time_report": bson.M{"$gte": 1}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stages, err := stagesOf([]bson.M{{"$match": tt.filter}})
+			if err != nil {
+				t.Fatalf("stagesOf() error = %v", err)
+			}
+			if got := hasExpr(stages[0][0].Value); got != tt.want {
+				t.Errorf("hasExpr() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpressions(t *testing.T) {
+	at, _ := time.Parse(time.RFC3339, "2024-03-02T23:30:00Z")
+	aDocument := primitive.D{
+		{Key: "duration", Value: "01:02:03"},
+		{Key: "at", Value: primitive.NewDateTimeFromTime(at)},
+		{Key: "href", Value: "https://example.com/pricing?plan=pro#faq"},
+		{Key: "city", Value: "Hồ Chí Minh"},
+		{Key: "pages", Value: primitive.A{"/", "/pricing"}},
+		{Key: "alerts", Value: primitive.A{int32(80), int32(100), int32(100)}},
+		{Key: "notified", Value: primitive.A{int64(80)}},
+		{Key: "views", Value: int32(2)},
+		{Key: "long", Value: int64(3)},
+		{Key: "ratio", Value: 0.5},
+		{Key: "nothing", Value: nil},
+	}
+	part := func(i int) bson.M {
+		return bson.M{"$toInt": bson.M{"$arrayElemAt": []interface{}{"$$parts", i}}}
+	}
+	tests := []struct {
+		name        string
+		expression  interface{}
+		want        interface{}
+		wantMissing bool
+		wantErr     string
+	}{
+		{name: "should keep a $literal as it is", expression: bson.M{"$literal": "$views"}, want: "$views"},
+		{name: "should take the then branch of a $cond array", expression: bson.M{"$cond": []interface{}{bson.M{"$gt": []interface{}{"$views", 1}}, "many", "few"}}, want: "many"},
+		{name: "should take the else branch of a $cond document on null", expression: bson.M{"$cond": bson.M{"if": "$nothing", "then": 1, "else": 0}}, want: int32(0)},
+		{
+			name: "should convert a duration to seconds with $let, $split, $arrayElemAt and $toInt",
+			expression: bson.M{"$let": bson.M{
+				"vars": bson.M{"parts": bson.M{"$split": []interface{}{"$duration", ":"}}},
+				"in":   bson.M{"$add": []interface{}{bson.M{"$multiply": []interface{}{part(0), 3600}}, bson.M{"$multiply": []interface{}{part(1), 60}}, part(2)}},
+			}},
+			want: int32(3723),
+		},
+		{name: "should fail on a variable $let does not define", expression: bson.M{"$add": []interface{}{"$$parts", 1}}, wantErr: "variable parts is not defined"},
+		{name: "should format a date in UTC by default", expression: bson.M{"$dateToString": bson.M{"date": "$at"}}, want: "2024-03-02T23:30:00.000Z"},
+		{name: "should format a date in a timezone", expression: bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d %H:%M", "date": "$at", "timezone": "Asia/Ho_Chi_Minh"}}, want: "2024-03-03 06:30"},
+		{name: "should format a date at an offset", expression: bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d %H:%M", "date": "$at", "timezone": "-05:00"}}, want: "2024-03-02 18:30"},
+		{name: "should format the day of the year and a percent sign", expression: bson.M{"$dateToString": bson.M{"format": "%j%%", "date": "$at"}}, want: "062%"},
+		{name: "should give onNull for a null date", expression: bson.M{"$dateToString": bson.M{"date": "$nothing", "onNull": "none"}}, want: "none"},
+		{name: "should give null for a missing date", expression: bson.M{"$dateToString": bson.M{"date": "$missing"}}, want: nil},
+		{name: "should fail on a format it cannot write", expression: bson.M{"$dateToString": bson.M{"format": "%U", "date": "$at"}}, wantErr: "$dateToString format %U is not supported in lite mode"},
+		{name: "should count days of the week from sunday in UTC", expression: bson.M{"$dayOfWeek": "$at"}, want: int32(7)},
+		{name: "should count days of the week in a timezone", expression: bson.M{"$dayOfWeek": bson.M{"date": "$at", "timezone": "Asia/Ho_Chi_Minh"}}, want: int32(1)},
+		{name: "should give the hour in a timezone", expression: bson.M{"$hour": bson.M{"date": "$at", "timezone": "America/New_York"}}, want: int32(18)},
+		{name: "should give null for the hour of null", expression: bson.M{"$hour": "$nothing"}, want: nil},
+		{
+			name:       "should find a match with its captures",
+			expression: bson.M{"$regexFind": bson.M{"input": "$href", "regex": "^[a-zA-Z][a-zA-Z0-9+.-]*://[^/?#]*([^?#]*)"}},
+			want:       primitive.D{{Key: "match", Value: "https://example.com/pricing"}, {Key: "idx", Value: int32(0)}, {Key: "captures", Value: primitive.A{"/pricing"}}},
+		},
+		{
+			name:       "should find a match at an index in code points with options",
+			expression: bson.M{"$regexFind": bson.M{"input": "$city", "regex": "(x)?minh", "options": "i"}},
+			want:       primitive.D{{Key: "match", Value: "Minh"}, {Key: "idx", Value: int32(7)}, {Key: "captures", Value: primitive.A{nil}}},
+		},
+		{name: "should give null without a match", expression: bson.M{"$regexFind": bson.M{"input": "$href", "regex": "^ftp"}}, want: nil},
+		{name: "should give null for a missing input", expression: bson.M{"$regexFind": bson.M{"input": "$missing", "regex": "a"}}, want: nil},
+		{name: "should take an element of an array", expression: bson.M{"$arrayElemAt": []interface{}{"$pages", 1}}, want: "/pricing"},
+		{name: "should take an element from the end of an array", expression: bson.M{"$arrayElemAt": []interface{}{"$pages", -2}}, want: "/"},
+		{name: "should leave out an element past the end of an array", expression: bson.M{"$arrayElemAt": []interface{}{"$pages", 2}}, wantMissing: true},
+		{name: "should give null for an element of null", expression: bson.M{"$arrayElemAt": []interface{}{"$nothing", 0}}, want: nil},
+		{name: "should give the values of the first set not in the second, once", expression: bson.M{"$setDifference": []interface{}{"$alerts", "$notified"}}, want: primitive.A{int32(100)}},
+		{name: "should give null for the difference with null", expression: bson.M{"$setDifference": []interface{}{"$alerts", "$nothing"}}, want: nil},
+		{
+			name: "should count the difference of sets defaulted with $ifNull",
+			expression: bson.M{"$size": bson.M{"$setDifference": []interface{}{
+				bson.M{"$ifNull": []interface{}{"$alerts", []int{}}},
+				bson.M{"$ifNull": []interface{}{"$missing", []int{}}},
+			}}},
+			want: int32(2),
+		},
+		{name: "should fail on the size of null", expression: bson.M{"$size": "$nothing"}, wantErr: "$size takes an array"},
+		{name: "should take the first value of $ifNull that is set", expression: bson.M{"$ifNull": []interface{}{"$nothing", "$missing", "$views", 0}}, want: int32(2)},
+		{name: "should take the default of $ifNull", expression: bson.M{"$ifNull": []interface{}{"$nothing", "default"}}, want: "default"},
+		{name: "should add an int and a long into a long", expression: bson.M{"$add": []interface{}{"$views", "$long"}}, want: int64(5)},
+		{name: "should add a double into a double", expression: bson.M{"$add": []interface{}{"$views", "$ratio"}}, want: 2.5},
+		{name: "should give null for a sum with null", expression: bson.M{"$add": []interface{}{"$views", "$nothing"}}, want: nil},
+		{name: "should multiply ints past the int range into a long", expression: bson.M{"$multiply": []interface{}{100000, 100000}}, want: int64(10000000000)},
+		{name: "should convert a string to an int", expression: bson.M{"$toInt": "08"}, want: int32(8)},
+		{name: "should convert a double to an int by truncating", expression: bson.M{"$toInt": 2.9}, want: int32(2)},
+		{name: "should convert a bool to an int", expression: bson.M{"$toInt": true}, want: int32(1)},
+		{name: "should keep null converted to an int", expression: bson.M{"$toInt": "$nothing"}, want: nil},
+		{name: "should fail to convert text to an int", expression: bson.M{"$toInt": "one"}, wantErr: `$toInt cannot convert "one"`},
+		{name: "should split a string", expression: bson.M{"$split": []interface{}{"$duration", ":"}}, want: primitive.A{"01", "02", "03"}},
+		{name: "should be true when every value of $and is", expression: bson.M{"$and": []interface{}{bson.M{"$gte": []interface{}{"$views", 2}}, bson.M{"$lt": []interface{}{"$ratio", 1}}}}, want: true},
+		{name: "should be false when no value of $or is true", expression: bson.M{"$or": []interface{}{"$nothing", 0, "$missing"}}, want: false},
+		{name: "should negate null with $not", expression: bson.M{"$not": []interface{}{"$nothing"}}, want: true},
+		{name: "should not take a missing field equal to null", expression: bson.M{"$eq": []interface{}{"$missing", nil}}, want: false},
+		{name: "should take a null field equal to null", expression: bson.M{"$eq": []interface{}{"$nothing", nil}}, want: true},
+		{name: "should take numbers of any type equal", expression: bson.M{"$eq": []interface{}{"$views", 2.0}}, want: true},
+		{name: "should order strings after numbers", expression: bson.M{"$gt": []interface{}{"a", "$long"}}, want: true},
+		{name: "should order dates after strings", expression: bson.M{"$lte": []interface{}{"$at", "z"}}, want: false},
+		{name: "should find a value in an array", expression: bson.M{"$in": []interface{}{"/pricing", "$pages"}}, want: true},
+		{name: "should not find a value missing from an array", expression: bson.M{"$ne": []interface{}{bson.M{"$in": []interface{}{"/blog", "$pages"}}, false}}, want: false},
+		{name: "should build a document leaving out missing fields", expression: bson.M{"views": "$views", "lost": "$missing"}, want: primitive.D{{Key: "views", Value: int32(2)}}},
+		{name: "should build an array with null for missing items", expression: []interface{}{"$views", "$missing"}, want: primitive.A{int32(2), nil}},
+		{name: "should fail on an operator it cannot run", expression: bson.M{"$concat": []interface{}{"a", "b"}}, wantErr: "expression $concat is not supported in lite mode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stages, err := stagesOf([]bson.M{{"$project": bson.M{"_id": 0, "value": tt.expression}}})
+			if err != nil {
+				t.Fatalf("stagesOf() error = %v", err)
+			}
+			got, err := runStages([]primitive.D{aDocument}, stages)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("runStages() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("runStages() error = %v", err)
+			}
+			value, ok := got[0].Map()["value"]
+			if ok == tt.wantMissing {
+				t.Fatalf("runStages() = %v, want the value missing %v", got[0], tt.wantMissing)
+			}
+			if !tt.wantMissing && !reflect.DeepEqual(value, tt.want) {
+				t.Errorf("runStages() value = %#v, want %#v", value, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccumulators(t *testing.T) {
+	documents := []primitive.D{
+		{{Key: "k", Value: "a"}, {Key: "n", Value: int32(1)}, {Key: "tag", Value: "x"}},
+		{{Key: "k", Value: "a"}, {Key: "n", Value: int64(2)}, {Key: "tag", Value: "y"}},
+		{{Key: "k", Value: "a"}, {Key: "n", Value: "text"}, {Key: "tag", Value: "x"}},
+		{{Key: "k", Value: "b"}, {Key: "n", Value: 2.5}, {Key: "tag", Value: nil}},
+		{{Key: "k", Value: "b"}},
+	}
+	tests := []struct {
+		name      string
+		group     bson.M
+		want      []primitive.D
+		documents []primitive.D
+	}{
+		{
+			name: "should accumulate the values of each group",
+			group: bson.M{
+				"_id":      "$k",
+				"sum":      bson.M{"$sum": "$n"},
+				"count":    bson.M{"$sum": 1},
+				"first":    bson.M{"$first": "$tag"},
+				"max":      bson.M{"$max": "$n"},
+				"min":      bson.M{"$min": "$n"},
+				"addToSet": bson.M{"$addToSet": "$tag"},
+			},
+			want: []primitive.D{
+				{{Key: "_id", Value: "a"}, {Key: "sum", Value: int64(3)}, {Key: "count", Value: int32(3)}, {Key: "first", Value: "x"}, {Key: "max", Value: "text"}, {Key: "min", Value: int32(1)}, {Key: "addToSet", Value: primitive.A{"x", "y"}}},
+				{{Key: "_id", Value: "b"}, {Key: "sum", Value: 2.5}, {Key: "count", Value: int32(2)}, {Key: "first", Value: nil}, {Key: "max", Value: 2.5}, {Key: "min", Value: 2.5}, {Key: "addToSet", Value: primitive.A{nil}}},
+			},
+		},
+		{
+			name:      "should merge group ids that are equal numbers of other types",
+			group:     bson.M{"_id": "$n", "count": bson.M{"$sum": 1}},
+			documents: []primitive.D{{{Key: "n", Value: int32(1)}}, {{Key: "n", Value: int64(1)}}, {{Key: "n", Value: 1.0}}, {{Key: "n", Value: nil}}, {}},
+			want:      []primitive.D{{{Key: "_id", Value: int32(1)}, {Key: "count", Value: int32(3)}}, {{Key: "_id", Value: nil}, {Key: "count", Value: int32(2)}}},
+		},
+		{
+			name:      "should sum to zero and leave other accumulators null without values",
+			group:     bson.M{"_id": nil, "sum": bson.M{"$sum": "$tag"}, "max": bson.M{"$max": "$none"}, "tags": bson.M{"$addToSet": "$none"}},
+			documents: []primitive.D{{{Key: "tag", Value: "x"}}},
+			want:      []primitive.D{{{Key: "_id", Value: nil}, {Key: "sum", Value: int32(0)}, {Key: "max", Value: nil}, {Key: "tags", Value: nil}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stages, err := stagesOf([]bson.M{{"$group": tt.group}})
+			if err != nil {
+				t.Fatalf("stagesOf() error = %v", err)
+			}
+			input := documents
+			if tt.documents != nil {
+				input = tt.documents
+			}
+			got, err := runStages(input, stages)
+			if err != nil {
+				t.Fatalf("runStages() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("runStages() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !reflect.DeepEqual(got[i].Map(), tt.want[i].Map()) {
+					t.Errorf("runStages() group %v = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	at := func(value string) primitive.DateTime {
+		parsed, _ := time.Parse(time.RFC3339, value)
+		return primitive.NewDateTimeFromTime(parsed)
+	}
+	aDocument := primitive.D{
+		{Key: "meta_data", Value: primitive.D{{Key: "platform", Value: "ios"}, {Key: "country_code", Value: "VN"}}},
+		{Key: "event", Value: primitive.D{{Key: "type", Value: int32(5)}, {Key: "data", Value: primitive.D{{Key: "tag", Value: "heartbeat"}}}}},
+		{Key: "time_report", Value: at("2024-03-02T10:00:00Z")},
+		{Key: "pages", Value: primitive.A{"/", "/pricing"}},
+		{Key: "views", Value: int64(2)},
+		{Key: "nothing", Value: nil},
+	}
+	tests := []struct {
+		name  string
+		query bson.M
+		want  bool
+	}{
+		{name: "should match fields at a path", query: bson.M{"meta_data.platform": "ios", "event.type": 5}, want: true},
+		{name: "should not match a field of another value", query: bson.M{"meta_data.platform": "web"}, want: false},
+		{name: "should match a missing field not equal to true", query: bson.M{"event.late": bson.M{"$ne": true}}, want: true},
+		{name: "should match a missing field with null in $in", query: bson.M{"meta_data.platform_v2": bson.M{"$in": []interface{}{"", nil}}}, want: true},
+		{name: "should match a null field with $exists", query: bson.M{"nothing": bson.M{"$exists": true}}, want: true},
+		{name: "should not match a missing field with $exists", query: bson.M{"missing": bson.M{"$exists": true}}, want: false},
+		{name: "should match a value held by an array", query: bson.M{"pages": "/pricing"}, want: true},
+		{name: "should match an array with $nin when it holds none of the values", query: bson.M{"pages": bson.M{"$nin": []interface{}{"/blog"}}}, want: true},
+		{name: "should match a date within a range", query: bson.M{"time_report": bson.M{"$gte": at("2024-03-02T00:00:00Z"), "$lt": at("2024-03-03T00:00:00Z")}}, want: true},
+		{name: "should not compare values of other types", query: bson.M{"views": bson.M{"$lt": "3"}}, want: false},
+		{name: "should compare numbers of any type", query: bson.M{"views": bson.M{"$gte": 1.5}}, want: true},
+		{name: "should match any query of $or", query: bson.M{"$or": []bson.M{{"views": 3}, {"meta_data.country_code": "VN"}}}, want: true},
+		{name: "should not match a query of $nor", query: bson.M{"$nor": []bson.M{{"views": 3}, {"pages": "/"}}}, want: false},
+		{name: "should match every query of $and", query: bson.M{"$and": []bson.M{{"views": 2}, {"event.data.tag": "heartbeat"}}}, want: true},
+		{name: "should match with $expr", query: bson.M{"$expr": bson.M{"$eq": []interface{}{bson.M{"$size": "$pages"}, "$views"}}}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stages, err := stagesOf([]bson.M{{"$match": tt.query}})
+			if err != nil {
+				t.Fatalf("stagesOf() error = %v", err)
+			}
+			got, err := runStages([]primitive.D{aDocument}, stages)
+			if err != nil {
+				t.Fatalf("runStages() error = %v", err)
+			}
+			if (len(got) == 1) != tt.want {
+				t.Errorf("runStages() matched %v, want %v", len(got) == 1, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Seal seal v encoded as JSON with the secrets keyring of the store, ""
+// when DATA_MASTER_KEY is unset and v is to be kept in the clear
+func (instance *Store) Seal(v interface{}) (string, error) {
+	if instance.Secrets == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return instance.Secrets.Seal(data)
+}
+
+// Open decode into v the value sealed by Seal
+func (instance *Store) Open(sealed string, v interface{}) error {
+	if instance.Secrets == nil {
+		return errors.New("value is sealed but DATA_MASTER_KEY is unset")
+	}
+	data, err := instance.Secrets.Open(sealed)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
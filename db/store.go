@@ -0,0 +1,99 @@
+package db
+
+import (
+	"sync/atomic"
+
+	"analytics-api/configs"
+	"analytics-api/internal/pkg/encryption"
+	"analytics-api/internal/pkg/ipallow"
+
+	"github.com/sirupsen/logrus"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Store data store used by repositories, one per tenant in multi-tenant mode
+type Store struct {
+	TenantID string
+	Mongo    *mongo.Database
+	// Keys seal recordings of tenant, nil when data encryption is off
+	Keys *encryption.Keyring
+	// Secrets seal the credentials and personal data kept in the store, like
+	// integration tokens and identified visitors, nil when DATA_MASTER_KEY is
+	// unset. Tenants seal them with Keys
+	Secrets *encryption.Keyring
+	// AllowList networks allowed to reach the dashboard and management API
+	AllowList *ipallow.List
+	// SignedWrites writes of the management API must be signed with an API key
+	SignedWrites *atomic.Bool
+	// ReplayWatermark recordings are played with the identity of the viewer
+	// over them
+	ReplayWatermark *atomic.Bool
+	// VisitorStream the live events of identified visitors may be watched
+	VisitorStream *atomic.Bool
+}
+
+// DefaultStore store of the configured database, used in single tenant mode
+func DefaultStore() *Store {
+	allowList, err := ipallow.New(configs.Current().AllowedCIDRs)
+	if err != nil {
+		logrus.Fatalln("invalid ALLOWED_CIDRS ", err)
+	}
+	signedWrites := &atomic.Bool{}
+	signedWrites.Store(configs.SignedWrites)
+	replayWatermark := &atomic.Bool{}
+	replayWatermark.Store(configs.ReplayWatermark)
+	visitorStream := &atomic.Bool{}
+	visitorStream.Store(configs.VisitorStream)
+	return &Store{
+		Mongo:           configs.MongoDB.Client,
+		Secrets:         defaultSecrets(),
+		AllowList:       allowList,
+		SignedWrites:    signedWrites,
+		ReplayWatermark: replayWatermark,
+		VisitorStream:   visitorStream,
+	}
+}
+
+// TenantStore store of a tenant, data of each tenant lives in a separate database
+// and is sealed with keys derived for that tenant only
+func TenantStore(tenantID string, keyVersion int) *Store {
+	store := &Store{
+		TenantID:        tenantID,
+		Mongo:           configs.MongoDB.Client.Client().Database(configs.MongoDB.Name + "_" + tenantID),
+		AllowList:       &ipallow.List{},
+		SignedWrites:    &atomic.Bool{},
+		ReplayWatermark: &atomic.Bool{},
+		VisitorStream:   &atomic.Bool{},
+	}
+	if configs.DataMasterKey != "" {
+		store.Keys = encryption.NewKeyring(masterKey(), tenantID, keyVersion)
+		store.Secrets = store.Keys
+	}
+	return store
+}
+
+// defaultSecrets keyring sealing the values of the default store, nil when
+// DATA_MASTER_KEY is unset
+func defaultSecrets() *encryption.Keyring {
+	if configs.DataMasterKey == "" {
+		return nil
+	}
+	return encryption.NewKeyring(masterKey(), "", 1)
+}
+
+func masterKey() []byte {
+	master, err := encryption.ParseMasterKey(configs.DataMasterKey)
+	if err != nil {
+		logrus.Fatalln("invalid DATA_MASTER_KEY ", err)
+	}
+	return master
+}
+
+// Key prefix key of the key store with tenant id so tenants never share cached data
+func (instance *Store) Key(key string) string {
+	if instance.TenantID == "" {
+		return key
+	}
+	return instance.TenantID + ":" + key
+}
@@ -0,0 +1,28 @@
+package admin
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPDelivery instance-wide admin API, guarded by the admin secret
+type HTTPDelivery interface {
+	// This function is required for every HTTPDelivery
+	InitRoutes(r *gin.RouterGroup)
+
+	// Other functions to handle HTTP requests
+	GetMaintenance(c *gin.Context)
+	SetMaintenance(c *gin.Context)
+	GetConfig(c *gin.Context)
+	GetSIEM(c *gin.Context)
+	GetWorkers(c *gin.Context)
+	GetSLO(c *gin.Context)
+	GetSLOMetrics(c *gin.Context)
+	GetLegalHolds(c *gin.Context)
+	PlaceLegalHold(c *gin.Context)
+	ReleaseLegalHold(c *gin.Context)
+}
+
+// NewHTTPDelivery ...
+func NewHTTPDelivery() HTTPDelivery {
+	return &httpDelivery{}
+}
@@ -0,0 +1,129 @@
+package admin
+
+import (
+	"net/http"
+
+	"analytics-api/configs"
+	"analytics-api/internal/pkg/fairqueue"
+	"analytics-api/internal/pkg/maintenance"
+	"analytics-api/internal/pkg/middleware"
+	req "analytics-api/internal/pkg/request"
+	"analytics-api/internal/pkg/siem"
+	"analytics-api/internal/pkg/slo"
+	"analytics-api/internal/pkg/spam"
+
+	"github.com/gin-gonic/gin"
+)
+
+type httpDelivery struct{}
+
+// RequestMaintenance ...
+type RequestMaintenance struct {
+	Enabled bool `json:"enabled"`
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	adminRoutes := r.Group("/admin", middleware.AdminMiddleware())
+	{
+		adminRoutes.GET("/maintenance", instance.GetMaintenance)
+		adminRoutes.PUT("/maintenance", instance.SetMaintenance)
+		adminRoutes.GET("/config", instance.GetConfig)
+		adminRoutes.GET("/siem", instance.GetSIEM)
+		adminRoutes.GET("/workers", instance.GetWorkers)
+		adminRoutes.GET("/slo", instance.GetSLO)
+		adminRoutes.GET("/slo/metrics", instance.GetSLOMetrics)
+		adminRoutes.GET("/legal-holds", instance.GetLegalHolds)
+		adminRoutes.PUT("/legal-holds/:website_id", instance.PlaceLegalHold)
+		adminRoutes.DELETE("/legal-holds/:website_id", instance.ReleaseLegalHold)
+	}
+}
+
+func (instance *httpDelivery) GetMaintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":   maintenance.Enabled(),
+		"by_config": configs.Current().MaintenanceMode,
+	})
+}
+
+// SetMaintenance turn maintenance mode on or off, it stays on while MAINTENANCE_MODE is set
+func (instance *httpDelivery) SetMaintenance(c *gin.Context) {
+	request, err := req.BindAndValidate[RequestMaintenance](c)
+	if err != nil {
+		req.BadRequest(c, "invalid maintenance request", err)
+		return
+	}
+
+	err = maintenance.Set(request.Enabled)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "set maintenance mode failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":   maintenance.Enabled(),
+		"by_config": configs.Current().MaintenanceMode,
+	})
+}
+
+// GetConfig effective configuration of the instance, the tunables as last
+// reloaded and the settings read at start. Secrets are never shown
+func (instance *httpDelivery) GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"tunables": configs.Current(),
+		"static": gin.H{
+			"port":               configs.Port,
+			"app_url":            configs.AppURL,
+			"multi_tenant":       configs.MultiTenant,
+			"signed_writes":      configs.SignedWrites,
+			"replay_watermark":   configs.ReplayWatermark,
+			"visitor_stream":     configs.VisitorStream,
+			"allow_private_urls": configs.AllowPrivateURLs,
+			"fake_data":          configs.FakeData,
+			"data_encryption":    configs.DataMasterKey != "",
+			"storage_primary":    configs.Storage.Primary,
+			"storage_dual_write": configs.Storage.DualWrite,
+			"archive":            configs.ArchiveEnabled(),
+			"siem":               configs.SIEM.URL != "",
+			"slo_shed":           configs.SLO.Shed,
+			"oauth_google":       configs.OAuth.GoogleClientID != "",
+			"oauth_github":       configs.OAuth.GitHubClientID != "",
+			"spam_feed":          configs.Spam.FeedURL != "",
+			"spam_domains":       spam.Default.Len(),
+			"lame_duck":          configs.Lifecycle.LameDuck.String(),
+			"drain_timeout":      configs.Lifecycle.DrainTimeout.String(),
+			"workers":            configs.Workers,
+		},
+	})
+}
+
+// GetSIEM delivery metrics of the export to the SIEM since the start of the
+// process, the events dropped tell the SIEM is slower than the events
+func (instance *httpDelivery) GetSIEM(c *gin.Context) {
+	c.JSON(http.StatusOK, siem.Current())
+}
+
+// GetWorkers queues of the background pools of the process by website, the
+// lag of a website tells how long its oldest task has waited
+func (instance *httpDelivery) GetWorkers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"pools": fairqueue.All()})
+}
+
+// GetSLO compliance of the latency objectives over the last hour with their
+// burn rates, the objectives at risk shed the low priority routes
+func (instance *httpDelivery) GetSLO(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"objectives": slo.Default().Statuses(),
+		"shed":       configs.SLO.Shed,
+	})
+}
+
+// GetSLOMetrics latency objectives for Prometheus to scrape
+func (instance *httpDelivery) GetSLOMetrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4")
+	c.Status(http.StatusOK)
+	err := slo.Default().WritePrometheus(c.Writer)
+	if err != nil {
+		c.Error(err)
+	}
+}
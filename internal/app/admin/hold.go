@@ -0,0 +1,115 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/app/audit"
+	"analytics-api/internal/app/website"
+	req "analytics-api/internal/pkg/request"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// errTenantRequired ...
+var errTenantRequired = errors.New("set ?tenant= to the tenant of the website")
+
+// RequestLegalHold ...
+type RequestLegalHold struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// holdStore store of the websites the request targets, the one of the tenant
+// of ?tenant= in multi-tenant mode. false once the reply is written
+func holdStore(c *gin.Context) (*db.Store, bool) {
+	if !configs.MultiTenant {
+		return db.DefaultStore(), true
+	}
+	tenantID := c.Query("tenant")
+	if tenantID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errTenantRequired.Error()})
+		return nil, false
+	}
+	// a website of an unknown tenant is not found
+	return db.TenantStore(tenantID, 0), true
+}
+
+// GetLegalHolds websites under legal hold
+func (instance *httpDelivery) GetLegalHolds(c *gin.Context) {
+	store, ok := holdStore(c)
+	if !ok {
+		return
+	}
+	websites, err := website.NewUseCase(store).GetHeldWebsite()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get legal holds failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"websites": websites})
+}
+
+// PlaceLegalHold keep every data of a website until the hold is released,
+// the change is written to the audit log of its owner
+func (instance *httpDelivery) PlaceLegalHold(c *gin.Context) {
+	request, err := req.BindAndValidate[RequestLegalHold](c)
+	if err != nil {
+		req.BadRequest(c, "invalid legal hold", err)
+		return
+	}
+	store, ok := holdStore(c)
+	if !ok {
+		return
+	}
+
+	aWebsite, err := website.NewUseCase(store).PlaceLegalHold(c.Param("website_id"), request.Reason)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "this website not exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "place legal hold failed"})
+		return
+	}
+	recordHold(c, store, aWebsite.UserID, aWebsite.ID, true, request.Reason)
+	if c.IsAborted() {
+		return
+	}
+	c.JSON(http.StatusOK, aWebsite)
+}
+
+// ReleaseLegalHold let the data of a website be pruned and deleted again
+func (instance *httpDelivery) ReleaseLegalHold(c *gin.Context) {
+	store, ok := holdStore(c)
+	if !ok {
+		return
+	}
+
+	aWebsite, err := website.NewUseCase(store).ReleaseLegalHold(c.Param("website_id"))
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "this website not exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "release legal hold failed"})
+		return
+	}
+	recordHold(c, store, aWebsite.UserID, aWebsite.ID, false, "")
+	if c.IsAborted() {
+		return
+	}
+	c.JSON(http.StatusOK, aWebsite)
+}
+
+// recordHold write the hold change to the audit log, a failure aborts with
+// 500 so the admin repeats the change, which records it again
+func recordHold(c *gin.Context, store *db.Store, userID, websiteID string, held bool, reason string) {
+	_, err := audit.NewUseCase(store).RecordHold(audit.ClientOf(c), userID, websiteID, held, reason)
+	if err != nil {
+		logrus.Error("record legal hold of website id ", websiteID, " error ", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "record legal hold failed"})
+	}
+}
@@ -0,0 +1,25 @@
+package aggregate
+
+import (
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPDelivery reports of the websites in aggregate-only mode
+type HTTPDelivery interface {
+	// This function is required for every HTTPDelivery
+	InitRoutes(r *gin.RouterGroup)
+
+	// Other functions to handle HTTP requests
+	GetReport(c *gin.Context)
+}
+
+// NewHTTPDelivery ...
+func NewHTTPDelivery(store *db.Store) HTTPDelivery {
+	return &httpDelivery{
+		aggregateUseCase: NewUseCase(store),
+		authUsecase:      auth.NewUseCase(store),
+	}
+}
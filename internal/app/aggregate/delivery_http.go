@@ -0,0 +1,57 @@
+package aggregate
+
+import (
+	"net/http"
+
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/pkg/cursor"
+	"analytics-api/internal/pkg/middleware"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type httpDelivery struct {
+	aggregateUseCase UseCase
+	authUsecase      auth.UseCase
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	aggregateRoutes := r.Group("aggregate")
+	{
+		aggregateRoutes.GET("/:website_id", middleware.JWTMiddleware(), instance.GetReport)
+	}
+}
+
+// GetReport daily counters of a website, the last 30 days by default and at
+// most MaxReportDays, broken down by the dimension of ?by= when given
+func (instance *httpDelivery) GetReport(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	days := cursor.Limit(c.Query("days"), 30, MaxReportDays)
+	aReport, err := instance.aggregateUseCase.GetReport(userID, c.Param("website_id"), c.Query("by"), days)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, aReport)
+	case ErrInvalidDimension:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case mongo.ErrNoDocuments:
+		c.JSON(http.StatusNotFound, gin.H{"error": "this website not exists"})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get aggregate report failed"})
+	}
+}
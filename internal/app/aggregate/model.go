@@ -0,0 +1,65 @@
+package aggregate
+
+import "time"
+
+const (
+	// DimensionTotal counters of the whole website, its value is empty
+	DimensionTotal = "total"
+	// DimensionPath pageviews by path of the page, or name of the screen
+	DimensionPath     = "path"
+	DimensionCountry  = "country"
+	DimensionDevice   = "device"
+	DimensionBrowser  = "browser"
+	DimensionPlatform = "platform"
+	// DimensionEvent custom events by tag
+	DimensionEvent = "event"
+)
+
+// Dimensions counters are kept by, total aside
+var Dimensions = []string{DimensionPath, DimensionCountry, DimensionDevice, DimensionBrowser, DimensionPlatform, DimensionEvent}
+
+// counter counts of a value of a dimension of a website during a UTC day
+type counter struct {
+	UserID    string    `json:"-" bson:"user_id"`
+	WebsiteID string    `json:"-" bson:"website_id"`
+	Day       time.Time `json:"day" bson:"day"`
+	Dimension string    `json:"-" bson:"dimension"`
+	Value     string    `json:"value,omitempty" bson:"value"`
+	Sessions  int64     `json:"sessions" bson:"sessions"`
+	Pageviews int64     `json:"pageviews" bson:"pageviews"`
+	Events    int64     `json:"events" bson:"events"`
+}
+
+// row counts of a value over the days of a report
+type row struct {
+	Value     string `json:"value"`
+	Sessions  int64  `json:"sessions"`
+	Pageviews int64  `json:"pageviews"`
+	Events    int64  `json:"events"`
+}
+
+// report daily totals of a website, oldest day first, with the values of a
+// dimension when one is asked, most viewed first
+type report struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Days      []counter `json:"days"`
+	Total     row       `json:"total"`
+	Dimension string    `json:"dimension,omitempty"`
+	Rows      []row     `json:"rows,omitempty"`
+}
+
+// Batch what a batch of a website adds to its counters. Pages and Events
+// count pageviews by path and custom events by tag, the other fields describe
+// the client and are counted once per batch
+type Batch struct {
+	Day time.Time
+	// First batch of a session, the session is counted once
+	First    bool
+	Country  string
+	Device   string
+	Browser  string
+	Platform string
+	Pages    map[string]int64
+	Events   map[string]int64
+}
@@ -0,0 +1,83 @@
+package aggregate
+
+import (
+	"context"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Repository ...
+type Repository interface {
+	IncCounters(counters []counter) error
+	GetCounters(userID, websiteID, dimension string, from, to time.Time) ([]counter, error)
+}
+
+type repository struct {
+	store *db.Store
+}
+
+// NewRepository ...
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store: store,
+	}
+}
+
+// IncCounters add counters to those of their website, day and value in a
+// single round trip
+func (instance *repository) IncCounters(counters []counter) error {
+	if len(counters) == 0 {
+		return nil
+	}
+	aggregateCollection := instance.store.Mongo.Collection(configs.MongoDB.AggregateCollection)
+	models := make([]mongo.WriteModel, 0, len(counters))
+	for _, aCounter := range counters {
+		filter := bson.M{"$and": []bson.M{
+			{"user_id": aCounter.UserID},
+			{"website_id": aCounter.WebsiteID},
+			{"day": aCounter.Day},
+			{"dimension": aCounter.Dimension},
+			{"value": aCounter.Value},
+		}}
+		update := bson.M{"$inc": bson.M{
+			"sessions":  aCounter.Sessions,
+			"pageviews": aCounter.Pageviews,
+			"events":    aCounter.Events,
+		}}
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true))
+	}
+	_, err := aggregateCollection.BulkWrite(context.TODO(), models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetCounters counters of dimension of website from the day from to the day
+// before to, oldest first
+func (instance *repository) GetCounters(userID, websiteID, dimension string, from, to time.Time) ([]counter, error) {
+	counters := []counter{}
+	aggregateCollection := instance.store.Mongo.Collection(configs.MongoDB.AggregateCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"dimension": dimension},
+		{"day": bson.M{"$gte": from, "$lt": to}},
+	}}
+	opts := options.Find().SetSort(primitive.D{{Key: "day", Value: 1}})
+	cursor, err := aggregateCollection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &counters); err != nil {
+		return nil, err
+	}
+	return counters, nil
+}
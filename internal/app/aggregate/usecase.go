@@ -0,0 +1,173 @@
+package aggregate
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/website"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrInvalidDimension ...
+var ErrInvalidDimension = errors.New("by must be path, country, device, browser, platform or event")
+
+// MaxReportDays counters never expire, a report covers at most a year
+const MaxReportDays = 366
+
+const dateLayout = "2006-01-02"
+
+// UseCase ...
+type UseCase interface {
+	Record(userID, websiteID string, aBatch Batch) error
+	GetReport(userID, websiteID, dimension string, days int) (*report, error)
+}
+
+type useCase struct {
+	repo           Repository
+	websiteUseCase website.UseCase
+}
+
+// NewUseCase ...
+func NewUseCase(store *db.Store) UseCase {
+	return &useCase{
+		repo:           NewRepository(store),
+		websiteUseCase: website.NewUseCase(store),
+	}
+}
+
+// Record add aBatch to the counters of website, the only trace the batch
+// leaves
+func (instance *useCase) Record(userID, websiteID string, aBatch Batch) error {
+	return instance.repo.IncCounters(counters(userID, websiteID, aBatch))
+}
+
+// counters what aBatch adds to each dimension, values without counts are
+// left out
+func counters(userID, websiteID string, aBatch Batch) []counter {
+	aDay := aBatch.Day.UTC().Truncate(24 * time.Hour)
+	total := counter{Pageviews: sum(aBatch.Pages), Events: sum(aBatch.Events)}
+	if aBatch.First {
+		total.Sessions = 1
+	}
+
+	var list []counter
+	add := func(dimension, value string, aCounter counter) {
+		if aCounter.Sessions == 0 && aCounter.Pageviews == 0 && aCounter.Events == 0 {
+			return
+		}
+		aCounter.UserID = userID
+		aCounter.WebsiteID = websiteID
+		aCounter.Day = aDay
+		aCounter.Dimension = dimension
+		aCounter.Value = value
+		list = append(list, aCounter)
+	}
+	add(DimensionTotal, "", total)
+	add(DimensionCountry, aBatch.Country, total)
+	add(DimensionDevice, aBatch.Device, total)
+	add(DimensionBrowser, aBatch.Browser, total)
+	add(DimensionPlatform, aBatch.Platform, total)
+	for path, pageviews := range aBatch.Pages {
+		add(DimensionPath, path, counter{Pageviews: pageviews})
+	}
+	for tag, events := range aBatch.Events {
+		add(DimensionEvent, tag, counter{Events: events})
+	}
+	return list
+}
+
+func sum(counts map[string]int64) int64 {
+	var total int64
+	for _, count := range counts {
+		total += count
+	}
+	return total
+}
+
+// GetReport daily totals of website over the last days, today included, with
+// the values of dimension unless it is empty
+func (instance *useCase) GetReport(userID, websiteID, dimension string, days int) (*report, error) {
+	if dimension != "" && !validDimension(dimension) {
+		return nil, ErrInvalidDimension
+	}
+	count, err := instance.websiteUseCase.FindWebsiteByID(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, -(days - 1))
+	to := today.AddDate(0, 0, 1)
+	listDay, err := instance.repo.GetCounters(userID, websiteID, DimensionTotal, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	aReport := &report{
+		From: from.Format(dateLayout),
+		To:   today.Format(dateLayout),
+		Days: listDay,
+	}
+	for _, aDay := range listDay {
+		aReport.Total.Sessions += aDay.Sessions
+		aReport.Total.Pageviews += aDay.Pageviews
+		aReport.Total.Events += aDay.Events
+	}
+	if dimension == "" {
+		return aReport, nil
+	}
+
+	listCounter, err := instance.repo.GetCounters(userID, websiteID, dimension, from, to)
+	if err != nil {
+		return nil, err
+	}
+	aReport.Dimension = dimension
+	aReport.Rows = rows(listCounter)
+	return aReport, nil
+}
+
+// rows counters summed by value, most viewed first then most sessions and
+// events
+func rows(counters []counter) []row {
+	list := []row{}
+	index := map[string]int{}
+	for _, aCounter := range counters {
+		i, ok := index[aCounter.Value]
+		if !ok {
+			i = len(list)
+			index[aCounter.Value] = i
+			list = append(list, row{Value: aCounter.Value})
+		}
+		list[i].Sessions += aCounter.Sessions
+		list[i].Pageviews += aCounter.Pageviews
+		list[i].Events += aCounter.Events
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Pageviews != list[j].Pageviews {
+			return list[i].Pageviews > list[j].Pageviews
+		}
+		if list[i].Sessions != list[j].Sessions {
+			return list[i].Sessions > list[j].Sessions
+		}
+		if list[i].Events != list[j].Events {
+			return list[i].Events > list[j].Events
+		}
+		return list[i].Value < list[j].Value
+	})
+	return list
+}
+
+func validDimension(dimension string) bool {
+	for _, name := range Dimensions {
+		if name == dimension {
+			return true
+		}
+	}
+	return false
+}
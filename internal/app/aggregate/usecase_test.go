@@ -0,0 +1,116 @@
+package aggregate
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestCounters(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		batch Batch
+		want  []counter
+	}{
+		{
+			name:  "should count nothing for an empty batch",
+			batch: Batch{Day: day.Add(5 * time.Hour), Country: "VN"},
+			want:  nil,
+		},
+		{
+			name: "should count a session once on its first batch",
+			batch: Batch{
+				Day: day.Add(5 * time.Hour), First: true, Country: "VN", Device: "mobile", Browser: "Chrome", Platform: "web",
+				Pages: map[string]int64{"/": 2},
+			},
+			want: []counter{
+				{Dimension: DimensionBrowser, Value: "Chrome", Sessions: 1, Pageviews: 2},
+				{Dimension: DimensionCountry, Value: "VN", Sessions: 1, Pageviews: 2},
+				{Dimension: DimensionDevice, Value: "mobile", Sessions: 1, Pageviews: 2},
+				{Dimension: DimensionPath, Value: "/", Pageviews: 2},
+				{Dimension: DimensionPlatform, Value: "web", Sessions: 1, Pageviews: 2},
+				{Dimension: DimensionTotal, Sessions: 1, Pageviews: 2},
+			},
+		},
+		{
+			name: "should count pages and events of a later batch",
+			batch: Batch{
+				Day: day.Add(23 * time.Hour), Country: "FR",
+				Pages:  map[string]int64{"/a": 1, "/b": 3},
+				Events: map[string]int64{"signup": 2},
+			},
+			want: []counter{
+				{Dimension: DimensionBrowser, Pageviews: 4, Events: 2},
+				{Dimension: DimensionCountry, Value: "FR", Pageviews: 4, Events: 2},
+				{Dimension: DimensionDevice, Pageviews: 4, Events: 2},
+				{Dimension: DimensionEvent, Value: "signup", Events: 2},
+				{Dimension: DimensionPath, Value: "/a", Pageviews: 1},
+				{Dimension: DimensionPath, Value: "/b", Pageviews: 3},
+				{Dimension: DimensionPlatform, Pageviews: 4, Events: 2},
+				{Dimension: DimensionTotal, Pageviews: 4, Events: 2},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := counters("u1", "w1", tt.batch)
+			for i, aCounter := range got {
+				if aCounter.UserID != "u1" || aCounter.WebsiteID != "w1" || !aCounter.Day.Equal(day) {
+					t.Errorf("counter %d of %s %s on %s, want u1 w1 on %s", i, aCounter.UserID, aCounter.WebsiteID, aCounter.Day, day)
+				}
+				got[i].UserID, got[i].WebsiteID, got[i].Day = "", "", time.Time{}
+			}
+			sort.Slice(got, func(i, j int) bool {
+				if got[i].Dimension != got[j].Dimension {
+					return got[i].Dimension < got[j].Dimension
+				}
+				return got[i].Value < got[j].Value
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("counters() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRows(t *testing.T) {
+	tests := []struct {
+		name     string
+		counters []counter
+		want     []row
+	}{
+		{name: "should return no row without counters", counters: nil, want: []row{}},
+		{
+			name: "should sum the days of a value",
+			counters: []counter{
+				{Value: "/", Sessions: 1, Pageviews: 2},
+				{Value: "/", Sessions: 3, Pageviews: 4, Events: 1},
+			},
+			want: []row{{Value: "/", Sessions: 4, Pageviews: 6, Events: 1}},
+		},
+		{
+			name: "should list the most viewed first then the most sessions and events",
+			counters: []counter{
+				{Value: "c", Pageviews: 1, Sessions: 1, Events: 5},
+				{Value: "a", Pageviews: 1, Sessions: 1, Events: 5},
+				{Value: "b", Pageviews: 1, Sessions: 2},
+				{Value: "d", Pageviews: 9},
+			},
+			want: []row{
+				{Value: "d", Pageviews: 9},
+				{Value: "b", Pageviews: 1, Sessions: 2},
+				{Value: "a", Pageviews: 1, Sessions: 1, Events: 5},
+				{Value: "c", Pageviews: 1, Sessions: 1, Events: 5},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rows(tt.counters); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
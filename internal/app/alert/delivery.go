@@ -0,0 +1,29 @@
+package alert
+
+import (
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPDelivery alert templates of the websites of a user
+type HTTPDelivery interface {
+	// This function is required for every HTTPDelivery
+	InitRoutes(r *gin.RouterGroup)
+
+	// Other functions to handle HTTP requests
+	GetAllTemplate(c *gin.Context)
+	CreateTemplate(c *gin.Context)
+	GetTemplate(c *gin.Context)
+	UpdateTemplate(c *gin.Context)
+	DeleteTemplate(c *gin.Context)
+}
+
+// NewHTTPDelivery ...
+func NewHTTPDelivery(store *db.Store) HTTPDelivery {
+	return &httpDelivery{
+		alertUseCase: NewUseCase(store),
+		authUsecase:  auth.NewUseCase(store),
+	}
+}
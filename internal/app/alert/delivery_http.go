@@ -0,0 +1,209 @@
+package alert
+
+import (
+	"net/http"
+
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/pkg/etag"
+	"analytics-api/internal/pkg/httperr"
+	"analytics-api/internal/pkg/middleware"
+	req "analytics-api/internal/pkg/request"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type httpDelivery struct {
+	alertUseCase UseCase
+	authUsecase  auth.UseCase
+}
+
+// RequestTemplate ...
+type RequestTemplate struct {
+	Name      string   `json:"name" validate:"required,max=100"`
+	Kind      string   `json:"kind" validate:"required,oneof=no_traffic low_traffic metric_below metric_above browser_drop referrer_spike page_gap"`
+	Minutes   int      `json:"minutes" validate:"min=5,max=1440"`
+	Threshold int64    `json:"threshold" validate:"min=0"`
+	Metric    string   `json:"metric" validate:"max=100"`
+	Limit     float64  `json:"limit"`
+	Page      string   `json:"page" validate:"max=500"`
+	Tags      []string `json:"tags" validate:"max=20,dive,min=1,max=30"`
+}
+
+// RequestUpdateTemplate fields of the template to change, those left out
+// are kept
+type RequestUpdateTemplate struct {
+	Name      *string  `json:"name" validate:"omitempty,min=1,max=100"`
+	Kind      *string  `json:"kind" validate:"omitempty,oneof=no_traffic low_traffic metric_below metric_above browser_drop referrer_spike page_gap"`
+	Minutes   *int     `json:"minutes" validate:"omitempty,min=5,max=1440"`
+	Threshold *int64   `json:"threshold" validate:"omitempty,min=0"`
+	Metric    *string  `json:"metric" validate:"omitempty,max=100"`
+	Limit     *float64 `json:"limit"`
+	Page      *string  `json:"page" validate:"omitempty,max=500"`
+	// Tags replace the tags of the template, an empty list applies it to
+	// every website
+	Tags *[]string `json:"tags" validate:"omitempty,max=20,dive,min=1,max=30"`
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	alertRoutes := r.Group("alert")
+	{
+		alertRoutes.GET("/templates", middleware.JWTMiddleware(), instance.GetAllTemplate)
+		alertRoutes.POST("/templates", middleware.JWTMiddleware(), instance.CreateTemplate)
+		alertRoutes.GET("/templates/:template_id", middleware.JWTMiddleware(), instance.GetTemplate)
+		alertRoutes.PATCH("/templates/:template_id", middleware.JWTMiddleware(), instance.UpdateTemplate)
+		alertRoutes.DELETE("/templates/:template_id", middleware.JWTMiddleware(), instance.DeleteTemplate)
+	}
+}
+
+func (instance *httpDelivery) GetAllTemplate(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	templates, err := instance.alertUseCase.GetAllTemplate(userID)
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get alert templates failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+// CreateTemplate add a template, applied at once to the websites of its tags
+func (instance *httpDelivery) CreateTemplate(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	request, err := req.BindAndValidate[RequestTemplate](c)
+	if err != nil {
+		req.BadRequest(c, "invalid alert template", err)
+		return
+	}
+
+	aTemplate, err := instance.alertUseCase.CreateTemplate(userID, request.Name, request.Kind, request.Metric, request.Page, request.Minutes, request.Threshold, request.Limit, request.Tags)
+	switch err {
+	case nil:
+		c.JSON(http.StatusCreated, aTemplate)
+	case ErrInvalidTemplate:
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "create alert template failed")
+	}
+}
+
+// GetTemplate template with the websites it applies to and their state
+func (instance *httpDelivery) GetTemplate(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	aTemplate, err := instance.alertUseCase.GetTemplate(userID, c.Param("template_id"))
+	switch err {
+	case nil:
+		etag.Set(c, aTemplate.Version)
+		c.JSON(http.StatusOK, aTemplate)
+	case ErrTemplateNotFound:
+		httperr.Abort(c, http.StatusNotFound, CodeTemplateNotFound, err.Error())
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get alert template failed")
+	}
+}
+
+// UpdateTemplate change a template, every website it applies to follows.
+// With If-Match the template is only changed at that version
+func (instance *httpDelivery) UpdateTemplate(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	request, err := req.BindAndValidate[RequestUpdateTemplate](c)
+	if err != nil {
+		req.BadRequest(c, "invalid alert template", err)
+		return
+	}
+	version, err := etag.IfMatch(c)
+	if err != nil {
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	aTemplate, err := instance.alertUseCase.UpdateTemplate(userID, c.Param("template_id"), request.Name, request.Kind, request.Metric, request.Page, request.Minutes, request.Threshold, request.Limit, request.Tags, version)
+	switch err {
+	case nil:
+		etag.Set(c, aTemplate.Version)
+		c.JSON(http.StatusOK, aTemplate)
+	case ErrInvalidTemplate:
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+	case ErrTemplateNotFound:
+		httperr.Abort(c, http.StatusNotFound, CodeTemplateNotFound, err.Error())
+	case etag.ErrConflict:
+		httperr.Abort(c, http.StatusConflict, etag.CodeConflict, err.Error())
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "update alert template failed")
+	}
+}
+
+// DeleteTemplate remove a template from all its websites
+func (instance *httpDelivery) DeleteTemplate(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	err = instance.alertUseCase.DeleteTemplate(userID, c.Param("template_id"))
+	switch err {
+	case nil:
+		c.Status(http.StatusNoContent)
+	case ErrTemplateNotFound:
+		httperr.Abort(c, http.StatusNotFound, CodeTemplateNotFound, err.Error())
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "delete alert template failed")
+	}
+}
@@ -0,0 +1,25 @@
+package alert
+
+import (
+	"time"
+
+	"analytics-api/db"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RunEvaluate evaluate the alert templates of store every interval, until the
+// process exits
+func RunEvaluate(store *db.Store, notifier Notifier, interval time.Duration) {
+	useCase := NewUseCase(store)
+	for range time.Tick(interval) {
+		sent, err := useCase.Evaluate(notifier)
+		if err != nil {
+			logrus.Error("evaluate alert templates error ", err)
+			continue
+		}
+		if sent > 0 {
+			logrus.Info("sent alert template notifications ", sent)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package alert
+
+import "time"
+
+// Kinds of alert templates
+const (
+	// KindNoTraffic fires when a website had no session in the last Minutes
+	KindNoTraffic = "no_traffic"
+	// KindLowTraffic fires when a website had fewer than Threshold sessions
+	// in the last Minutes
+	KindLowTraffic = "low_traffic"
+	// KindMetricBelow and KindMetricAbove fire when the calculated metric
+	// Metric of a website over the last Minutes is below, or above, Limit.
+	// Websites without the metric are not checked
+	KindMetricBelow = "metric_below"
+	KindMetricAbove = "metric_above"
+	// KindBrowserDrop fires when a browser had Limit percent fewer sessions
+	// in the last Minutes than its share of the Minutes before predicts,
+	// among browsers with at least Threshold sessions then
+	KindBrowserDrop = "browser_drop"
+	// KindReferrerSpike fires when the share of web sessions without referrer
+	// in the last Minutes rose Limit points above that of the Minutes before,
+	// once there were at least Threshold web sessions
+	KindReferrerSpike = "referrer_spike"
+	// KindPageGap fires when Page had no page view in the last Minutes while
+	// the website had sessions, after at least Threshold in the Minutes before
+	KindPageGap = "page_gap"
+)
+
+// template alert rule defined once and applied to every website of its user
+// carrying one of Tags, every website when there is no tag
+type template struct {
+	ID        string `json:"id" bson:"id"`
+	UserID    string `json:"-" bson:"user_id"`
+	Name      string `json:"name" bson:"name"`
+	Kind      string `json:"kind" bson:"kind"`
+	Minutes   int    `json:"minutes" bson:"minutes"`
+	Threshold int64  `json:"threshold,omitempty" bson:"threshold,omitempty"`
+	// Metric key of the calculated metric of metric kinds, and Limit the
+	// value it is compared with, a percent for data quality kinds
+	Metric string  `json:"metric,omitempty" bson:"metric,omitempty"`
+	Limit  float64 `json:"limit,omitempty" bson:"limit,omitempty"`
+	// Page path watched by KindPageGap
+	Page      string   `json:"page,omitempty" bson:"page,omitempty"`
+	Tags      []string `json:"tags" bson:"tags"`
+	CreatedAt string   `json:"created_at" bson:"created_at"`
+	UpdatedAt string   `json:"updated_at" bson:"updated_at"`
+	// Version counts the changes of the template, answered as its ETag
+	Version int64 `json:"version" bson:"version"`
+}
+
+// websiteAlert template applied to a website, with the state of its last
+// check
+type websiteAlert struct {
+	TemplateID string `json:"-" bson:"template_id"`
+	UserID     string `json:"-" bson:"user_id"`
+	WebsiteID  string `json:"website_id" bson:"website_id"`
+	URL        string `json:"url" bson:"url"`
+	// Firing the rule held at the last check, owners are notified when it
+	// starts and stops
+	Firing   bool  `json:"firing" bson:"firing"`
+	Sessions int64 `json:"sessions" bson:"sessions"`
+	// Value of the calculated metric at the last check of metric kinds, nil
+	// when a divisor of its expression was zero. For data quality kinds the
+	// drop or share in percent, or the page views of the page
+	Value *float64 `json:"value,omitempty" bson:"value,omitempty"`
+	// Detail what data quality kinds found at the last check, the browser
+	// that dropped for KindBrowserDrop
+	Detail    string    `json:"detail,omitempty" bson:"detail,omitempty"`
+	AppliedAt time.Time `json:"applied_at" bson:"applied_at"`
+	CheckedAt time.Time `json:"checked_at,omitempty" bson:"checked_at,omitempty"`
+	FiredAt   time.Time `json:"fired_at,omitempty" bson:"fired_at,omitempty"`
+}
+
+// templateDetail template with the websites it is applied to
+type templateDetail struct {
+	template
+	Websites []websiteAlert `json:"websites"`
+}
@@ -0,0 +1,133 @@
+package alert
+
+import (
+	"fmt"
+	"time"
+
+	"analytics-api/internal/app/session"
+)
+
+// qualityKind whether kind monitors the quality of the data collected,
+// comparing the last Minutes with the Minutes before
+func qualityKind(kind string) bool {
+	return kind == KindBrowserDrop || kind == KindReferrerSpike || kind == KindPageGap
+}
+
+// checkQuality compare the data of website over window before now with the
+// window before it, for data quality kinds. sessions were counted over the
+// last window. Returns whether aTemplate fires, the value it found and its
+// detail
+func (instance *useCase) checkQuality(aTemplate template, websiteID string, sessions int64, now time.Time, window time.Duration) (bool, *float64, string, error) {
+	current := session.BreakdownFilter{From: now.Add(-window), To: now}
+	previous := session.BreakdownFilter{From: now.Add(-2 * window), To: now.Add(-window)}
+	switch aTemplate.Kind {
+	case KindBrowserDrop:
+		before, err := instance.sessionUseCase.Breakdown(aTemplate.UserID, websiteID, "browser", previous)
+		if err != nil {
+			return false, nil, "", err
+		}
+		after, err := instance.sessionUseCase.Breakdown(aTemplate.UserID, websiteID, "browser", current)
+		if err != nil {
+			return false, nil, "", err
+		}
+		browser, drop, ok := browserDrop(before, after, aTemplate.Threshold)
+		if !ok {
+			return false, nil, "", nil
+		}
+		return drop >= aTemplate.Limit, &drop, browser, nil
+
+	case KindReferrerSpike:
+		before, err := instance.sessionUseCase.Referrals(aTemplate.UserID, websiteID, previous)
+		if err != nil {
+			return false, nil, "", err
+		}
+		after, err := instance.sessionUseCase.Referrals(aTemplate.UserID, websiteID, current)
+		if err != nil {
+			return false, nil, "", err
+		}
+		if before.Sessions == 0 || after.Sessions < aTemplate.Threshold {
+			return false, nil, "", nil
+		}
+		share := percent(after.NotSet, after.Sessions)
+		return share-percent(before.NotSet, before.Sessions) >= aTemplate.Limit, &share, "", nil
+
+	case KindPageGap:
+		// a website without any session is the concern of no_traffic
+		if sessions == 0 {
+			return false, nil, "", nil
+		}
+		before, err := instance.sessionUseCase.Pages(aTemplate.UserID, websiteID, previous)
+		if err != nil {
+			return false, nil, "", err
+		}
+		if pageviews(before, aTemplate.Page) < aTemplate.Threshold {
+			return false, nil, "", nil
+		}
+		after, err := instance.sessionUseCase.Pages(aTemplate.UserID, websiteID, current)
+		if err != nil {
+			return false, nil, "", err
+		}
+		views := float64(pageviews(after, aTemplate.Page))
+		return views == 0, &views, aTemplate.Page, nil
+	}
+	return false, nil, "", nil
+}
+
+// browserDrop browser of after furthest below the sessions its share of
+// before predicts for the sessions of after, with how far in percent. Only
+// browsers with threshold sessions before count, so a website losing
+// traffic everywhere does not drop any. False when none counts
+func browserDrop(before, after []session.Bucket, threshold int64) (string, float64, bool) {
+	var totalBefore, totalAfter int64
+	for _, aBucket := range before {
+		totalBefore += aBucket.Sessions
+	}
+	sessionsAfter := map[string]int64{}
+	for _, aBucket := range after {
+		totalAfter += aBucket.Sessions
+		sessionsAfter[aBucket.Key] = aBucket.Sessions
+	}
+	if totalBefore == 0 || totalAfter == 0 {
+		return "", 0, false
+	}
+
+	browser, drop, found := "", 0.0, false
+	for _, aBucket := range before {
+		// apps report no browser
+		if aBucket.Key == "" || aBucket.Sessions < threshold {
+			continue
+		}
+		expected := float64(aBucket.Sessions) * float64(totalAfter) / float64(totalBefore)
+		aDrop := (expected - float64(sessionsAfter[aBucket.Key])) / expected * 100
+		if !found || aDrop > drop {
+			browser, drop, found = aBucket.Key, aDrop, true
+		}
+	}
+	return browser, drop, found
+}
+
+// percent part of total
+func percent(part, total int64) float64 {
+	return float64(part) / float64(total) * 100
+}
+
+// pageviews of path among pages
+func pageviews(pages []session.Page, path string) int64 {
+	for _, aPage := range pages {
+		if aPage.Path == path {
+			return aPage.Pageviews
+		}
+	}
+	return 0
+}
+
+// qualityBody what a data quality kind found on the website of url
+func qualityBody(aTemplate template, url string, value *float64, detail string) string {
+	switch aTemplate.Kind {
+	case KindBrowserDrop:
+		return fmt.Sprintf("%s had %.0f%% fewer %s sessions in the last %d minutes than the rest of its traffic predicts", url, *value, detail, aTemplate.Minutes)
+	case KindReferrerSpike:
+		return fmt.Sprintf("%.0f%% of the web sessions of %s had no referrer in the last %d minutes, up %g points or more", *value, url, aTemplate.Minutes, aTemplate.Limit)
+	}
+	return fmt.Sprintf("%s had no page view of %s in the last %d minutes while it had sessions", url, detail, aTemplate.Minutes)
+}
@@ -0,0 +1,197 @@
+package alert
+
+import (
+	"context"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/etag"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Repository ...
+type Repository interface {
+	InsertTemplate(aTemplate template) error
+	GetAllTemplate(userID string) ([]template, error)
+	ListTemplate() ([]template, error)
+	GetTemplate(userID, templateID string, aTemplate *template) error
+	UpdateTemplate(userID, templateID string, version int64, fields bson.M) (int64, error)
+	DeleteTemplate(userID, templateID string) (int64, error)
+
+	GetAllWebsiteAlert(templateID string) ([]websiteAlert, error)
+	UpsertWebsiteAlert(aWebsiteAlert websiteAlert) error
+	DeleteOtherWebsiteAlert(templateID string, websiteIDs []string) error
+	SetChecked(templateID, websiteID string, firing bool, sessions int64, value *float64, detail string, checkedAt, firedAt time.Time) error
+}
+
+type repository struct {
+	store *db.Store
+}
+
+// NewRepository ...
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store: store,
+	}
+}
+
+func (instance *repository) InsertTemplate(aTemplate template) error {
+	templateCollection := instance.store.Mongo.Collection(configs.MongoDB.AlertTemplateCollection)
+	_, err := templateCollection.InsertOne(context.TODO(), aTemplate)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) GetAllTemplate(userID string) ([]template, error) {
+	templates := []template{}
+	templateCollection := instance.store.Mongo.Collection(configs.MongoDB.AlertTemplateCollection)
+	cursor, err := templateCollection.Find(context.TODO(), bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// ListTemplate templates of every user, for the evaluation
+func (instance *repository) ListTemplate() ([]template, error) {
+	templates := []template{}
+	templateCollection := instance.store.Mongo.Collection(configs.MongoDB.AlertTemplateCollection)
+	cursor, err := templateCollection.Find(context.TODO(), bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (instance *repository) GetTemplate(userID, templateID string, aTemplate *template) error {
+	templateCollection := instance.store.Mongo.Collection(configs.MongoDB.AlertTemplateCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": templateID},
+	}}
+	err := templateCollection.FindOne(context.TODO(), filter).Decode(aTemplate)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateTemplate set fields of template at version and bump its version,
+// returns the count matched
+func (instance *repository) UpdateTemplate(userID, templateID string, version int64, fields bson.M) (int64, error) {
+	templateCollection := instance.store.Mongo.Collection(configs.MongoDB.AlertTemplateCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": templateID},
+		etag.Filter(version),
+	}}
+	update := bson.M{"$set": fields, "$inc": bson.M{"version": 1}}
+	result, err := templateCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.MatchedCount, nil
+}
+
+// DeleteTemplate remove template with its alerts, returns the count of
+// templates deleted
+func (instance *repository) DeleteTemplate(userID, templateID string) (int64, error) {
+	templateCollection := instance.store.Mongo.Collection(configs.MongoDB.AlertTemplateCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": templateID},
+	}}
+	result, err := templateCollection.DeleteOne(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	if result.DeletedCount == 0 {
+		return 0, nil
+	}
+	return result.DeletedCount, instance.DeleteOtherWebsiteAlert(templateID, []string{})
+}
+
+func (instance *repository) GetAllWebsiteAlert(templateID string) ([]websiteAlert, error) {
+	websiteAlerts := []websiteAlert{}
+	websiteAlertCollection := instance.store.Mongo.Collection(configs.MongoDB.AlertInstanceCollection)
+	cursor, err := websiteAlertCollection.Find(context.TODO(), bson.M{"template_id": templateID})
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &websiteAlerts); err != nil {
+		return nil, err
+	}
+	return websiteAlerts, nil
+}
+
+// UpsertWebsiteAlert add aWebsiteAlert when its template is not applied to its
+// website yet, the state of an alert already there is kept
+func (instance *repository) UpsertWebsiteAlert(aWebsiteAlert websiteAlert) error {
+	websiteAlertCollection := instance.store.Mongo.Collection(configs.MongoDB.AlertInstanceCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"template_id": aWebsiteAlert.TemplateID},
+		{"website_id": aWebsiteAlert.WebsiteID},
+	}}
+	update := bson.M{
+		"$set": bson.M{"url": aWebsiteAlert.URL},
+		"$setOnInsert": bson.M{
+			"user_id":    aWebsiteAlert.UserID,
+			"firing":     false,
+			"sessions":   int64(0),
+			"applied_at": aWebsiteAlert.AppliedAt,
+		},
+	}
+	_, err := websiteAlertCollection.UpdateOne(context.TODO(), filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteOtherWebsiteAlert remove the alerts of template on websites not in
+// websiteIDs, those out of its scope now
+func (instance *repository) DeleteOtherWebsiteAlert(templateID string, websiteIDs []string) error {
+	websiteAlertCollection := instance.store.Mongo.Collection(configs.MongoDB.AlertInstanceCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"template_id": templateID},
+		{"website_id": bson.M{"$nin": websiteIDs}},
+	}}
+	deleteResult, err := websiteAlertCollection.DeleteMany(context.TODO(), filter)
+	if err != nil {
+		return err
+	}
+	if deleteResult.DeletedCount > 0 {
+		logrus.Printf("deleted %v documents in the alert instance collection\n", deleteResult.DeletedCount)
+	}
+	return nil
+}
+
+// SetChecked store the result of the last check of template on website
+func (instance *repository) SetChecked(templateID, websiteID string, firing bool, sessions int64, value *float64, detail string, checkedAt, firedAt time.Time) error {
+	websiteAlertCollection := instance.store.Mongo.Collection(configs.MongoDB.AlertInstanceCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"template_id": templateID},
+		{"website_id": websiteID},
+	}}
+	fields := bson.M{"firing": firing, "sessions": sessions, "value": value, "detail": detail, "checked_at": checkedAt}
+	if !firedAt.IsZero() {
+		fields["fired_at"] = firedAt
+	}
+	_, err := websiteAlertCollection.UpdateOne(context.TODO(), filter, bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	return nil
+}
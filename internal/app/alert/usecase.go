@@ -0,0 +1,412 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/metric"
+	"analytics-api/internal/app/session"
+	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/etag"
+	"analytics-api/internal/pkg/formula"
+	"analytics-api/internal/pkg/push"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// CodeTemplateNotFound ...
+const CodeTemplateNotFound = "alert_template_not_found"
+
+var (
+	// ErrInvalidTemplate ...
+	ErrInvalidTemplate = errors.New("template needs a name, kind no_traffic, low_traffic, metric_below, metric_above, browser_drop, referrer_spike or page_gap, 5 to 1440 minutes, a threshold for low_traffic and data quality kinds, the key of a calculated metric for metric kinds, a limit of 1 to 100 percent for browser_drop and referrer_spike and a page path for page_gap")
+	// ErrTemplateNotFound ...
+	ErrTemplateNotFound = errors.New("this alert template not exists")
+)
+
+// Bounds of the minutes a template looks back on
+const (
+	MinMinutes = 5
+	MaxMinutes = 1440
+)
+
+// Notifier push notifications to the devices of a user, the mobile usecase
+type Notifier interface {
+	Notify(userID string, notification push.Notification) (int, error)
+}
+
+// UseCase ...
+type UseCase interface {
+	CreateTemplate(userID, name, kind, metricKey, page string, minutes int, threshold int64, limit float64, tags []string) (*templateDetail, error)
+	GetAllTemplate(userID string) ([]template, error)
+	GetTemplate(userID, templateID string) (*templateDetail, error)
+	UpdateTemplate(userID, templateID string, name, kind, metricKey, page *string, minutes *int, threshold *int64, limit *float64, tags *[]string, version *int64) (*templateDetail, error)
+	DeleteTemplate(userID, templateID string) error
+	Evaluate(notifier Notifier) (int, error)
+}
+
+type useCase struct {
+	repo           Repository
+	sessionUseCase session.UseCase
+	websiteUseCase website.UseCase
+	metricUseCase  metric.UseCase
+}
+
+// NewUseCase ...
+func NewUseCase(store *db.Store) UseCase {
+	return &useCase{
+		repo:           NewRepository(store),
+		sessionUseCase: session.NewUseCase(store),
+		websiteUseCase: website.NewUseCase(store),
+		metricUseCase:  metric.NewUseCase(store),
+	}
+}
+
+// metricKind whether kind compares a calculated metric
+func metricKind(kind string) bool {
+	return kind == KindMetricBelow || kind == KindMetricAbove
+}
+
+// valid whether aTemplate may be stored, the fields of other kinds are
+// dropped since they have no use
+func valid(aTemplate *template) bool {
+	if aTemplate.Kind != KindLowTraffic && !qualityKind(aTemplate.Kind) {
+		aTemplate.Threshold = 0
+	}
+	if !metricKind(aTemplate.Kind) {
+		aTemplate.Metric = ""
+	}
+	if !metricKind(aTemplate.Kind) && aTemplate.Kind != KindBrowserDrop && aTemplate.Kind != KindReferrerSpike {
+		aTemplate.Limit = 0
+	}
+	if aTemplate.Kind != KindPageGap {
+		aTemplate.Page = ""
+	}
+	switch {
+	case aTemplate.Name == "":
+		return false
+	case aTemplate.Kind != KindNoTraffic && aTemplate.Kind != KindLowTraffic && !metricKind(aTemplate.Kind) && !qualityKind(aTemplate.Kind):
+		return false
+	case aTemplate.Minutes < MinMinutes || aTemplate.Minutes > MaxMinutes:
+		return false
+	case (aTemplate.Kind == KindLowTraffic || qualityKind(aTemplate.Kind)) && aTemplate.Threshold < 1:
+		return false
+	case metricKind(aTemplate.Kind) && (aTemplate.Metric == "" || formula.Identifier(aTemplate.Metric) != aTemplate.Metric):
+		return false
+	case (aTemplate.Kind == KindBrowserDrop || aTemplate.Kind == KindReferrerSpike) && (aTemplate.Limit < 1 || aTemplate.Limit > 100):
+		return false
+	case aTemplate.Kind == KindPageGap && !strings.HasPrefix(aTemplate.Page, "/"):
+		return false
+	}
+	return true
+}
+
+// fires whether aTemplate holds for a website with sessions in its minutes,
+// and value of its calculated metric for metric kinds. A metric without
+// value never fires
+func fires(aTemplate template, sessions int64, value *float64) bool {
+	switch aTemplate.Kind {
+	case KindLowTraffic:
+		return sessions < aTemplate.Threshold
+	case KindMetricBelow:
+		return value != nil && *value < aTemplate.Limit
+	case KindMetricAbove:
+		return value != nil && *value > aTemplate.Limit
+	}
+	return sessions == 0
+}
+
+// CreateTemplate add template and apply it to the websites of its tags
+func (instance *useCase) CreateTemplate(userID, name, kind, metricKey, page string, minutes int, threshold int64, limit float64, tags []string) (*templateDetail, error) {
+	now := time.Now().Format("2006-01-02, 15:04:05")
+	aTemplate := template{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      strings.TrimSpace(name),
+		Kind:      kind,
+		Minutes:   minutes,
+		Threshold: threshold,
+		Metric:    metricKey,
+		Limit:     limit,
+		Page:      strings.TrimSpace(page),
+		Tags:      website.NormalizeTags(tags),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if !valid(&aTemplate) {
+		return nil, ErrInvalidTemplate
+	}
+	err := instance.repo.InsertTemplate(aTemplate)
+	if err != nil {
+		return nil, err
+	}
+	websiteAlerts, err := instance.apply(aTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &templateDetail{template: aTemplate, Websites: websiteAlerts}, nil
+}
+
+func (instance *useCase) GetAllTemplate(userID string) ([]template, error) {
+	templates, err := instance.repo.GetAllTemplate(userID)
+	if err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// GetTemplate template with the websites it applies to now, websites tagged
+// or added since the last evaluation are applied first
+func (instance *useCase) GetTemplate(userID, templateID string) (*templateDetail, error) {
+	var aTemplate template
+	err := instance.repo.GetTemplate(userID, templateID, &aTemplate)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	websiteAlerts, err := instance.apply(aTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &templateDetail{template: aTemplate, Websites: websiteAlerts}, nil
+}
+
+// UpdateTemplate change the fields of template not nil, every website it
+// applies to follows the change. Websites out of its new tags lose it, with
+// their state. etag.ErrConflict when version is given and no longer
+// current, or the template changes while it is updated
+func (instance *useCase) UpdateTemplate(userID, templateID string, name, kind, metricKey, page *string, minutes *int, threshold *int64, limit *float64, tags *[]string, version *int64) (*templateDetail, error) {
+	var aTemplate template
+	err := instance.repo.GetTemplate(userID, templateID, &aTemplate)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if version != nil && *version != aTemplate.Version {
+		return nil, etag.ErrConflict
+	}
+
+	if name != nil {
+		aTemplate.Name = strings.TrimSpace(*name)
+	}
+	if kind != nil {
+		aTemplate.Kind = *kind
+	}
+	if minutes != nil {
+		aTemplate.Minutes = *minutes
+	}
+	if threshold != nil {
+		aTemplate.Threshold = *threshold
+	}
+	if metricKey != nil {
+		aTemplate.Metric = *metricKey
+	}
+	if limit != nil {
+		aTemplate.Limit = *limit
+	}
+	if page != nil {
+		aTemplate.Page = strings.TrimSpace(*page)
+	}
+	if tags != nil {
+		aTemplate.Tags = website.NormalizeTags(*tags)
+	}
+	if !valid(&aTemplate) {
+		return nil, ErrInvalidTemplate
+	}
+	aTemplate.UpdatedAt = time.Now().Format("2006-01-02, 15:04:05")
+
+	// the template is only written at the version read, so a change made in
+	// between is not overwritten
+	count, err := instance.repo.UpdateTemplate(userID, templateID, aTemplate.Version, bson.M{
+		"name":       aTemplate.Name,
+		"kind":       aTemplate.Kind,
+		"minutes":    aTemplate.Minutes,
+		"threshold":  aTemplate.Threshold,
+		"metric":     aTemplate.Metric,
+		"limit":      aTemplate.Limit,
+		"page":       aTemplate.Page,
+		"tags":       aTemplate.Tags,
+		"updated_at": aTemplate.UpdatedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, etag.ErrConflict
+	}
+	aTemplate.Version++
+	websiteAlerts, err := instance.apply(aTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &templateDetail{template: aTemplate, Websites: websiteAlerts}, nil
+}
+
+// DeleteTemplate remove template from all the websites it applies to
+func (instance *useCase) DeleteTemplate(userID, templateID string) error {
+	count, err := instance.repo.DeleteTemplate(userID, templateID)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+// apply aTemplate to the websites of its user carrying one of its tags, all
+// of them without tags, and remove it from the others. Aggregate-only,
+// unverified and archived websites have no session to check and are left out
+func (instance *useCase) apply(aTemplate template) ([]websiteAlert, error) {
+	websites, err := instance.websiteUseCase.GetAllWebsite(aTemplate.UserID)
+	if err != nil {
+		return nil, err
+	}
+	websiteIDs := []string{}
+	for _, aWebsite := range *websites {
+		if aWebsite.AggregateOnly || !aWebsite.Verified() || aWebsite.Archived || aWebsite.HostName == website.InternalHostName {
+			continue
+		}
+		if len(aTemplate.Tags) > 0 && !slices.ContainsFunc(aWebsite.Tags, func(tag string) bool {
+			return slices.Contains(aTemplate.Tags, tag)
+		}) {
+			continue
+		}
+		err = instance.repo.UpsertWebsiteAlert(websiteAlert{
+			TemplateID: aTemplate.ID,
+			UserID:     aTemplate.UserID,
+			WebsiteID:  aWebsite.ID,
+			URL:        aWebsite.URL,
+			AppliedAt:  time.Now().UTC(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		websiteIDs = append(websiteIDs, aWebsite.ID)
+	}
+	err = instance.repo.DeleteOtherWebsiteAlert(aTemplate.ID, websiteIDs)
+	if err != nil {
+		return nil, err
+	}
+	return instance.repo.GetAllWebsiteAlert(aTemplate.ID)
+}
+
+// Evaluate apply every template again and check it on each of its websites.
+// A website is checked once the template has applied to it for its minutes,
+// so a website just tagged is not alerted for the time before. The owner is
+// notified when an alert starts and when it stops, not at every check.
+// Returns the number of notifications sent
+func (instance *useCase) Evaluate(notifier Notifier) (int, error) {
+	templates, err := instance.repo.ListTemplate()
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, aTemplate := range templates {
+		websiteAlerts, err := instance.apply(aTemplate)
+		if err != nil {
+			logrus.Error("apply alert template error ", err)
+			continue
+		}
+		now := time.Now().UTC()
+		window := time.Duration(aTemplate.Minutes) * time.Minute
+		for _, aWebsiteAlert := range websiteAlerts {
+			if now.Sub(aWebsiteAlert.AppliedAt) < window {
+				continue
+			}
+			sessions, err := instance.sessionUseCase.CountSessionSince(aTemplate.UserID, aWebsiteAlert.WebsiteID, now.Add(-window))
+			if err != nil {
+				return sent, err
+			}
+			var value *float64
+			if metricKind(aTemplate.Kind) {
+				aValue, err := instance.metricUseCase.Get(aTemplate.UserID, aWebsiteAlert.WebsiteID, aTemplate.Metric, session.BreakdownFilter{
+					From: now.Add(-window),
+					To:   now,
+				})
+				if err == metric.ErrMetricNotFound {
+					continue
+				}
+				if err != nil {
+					return sent, err
+				}
+				value = aValue.Value
+			}
+			firing := fires(aTemplate, sessions, value)
+			detail := ""
+			if qualityKind(aTemplate.Kind) {
+				firing, value, detail, err = instance.checkQuality(aTemplate, aWebsiteAlert.WebsiteID, sessions, now, window)
+				if err != nil {
+					return sent, err
+				}
+			}
+			var firedAt time.Time
+			if firing && !aWebsiteAlert.Firing {
+				firedAt = now
+			}
+			if firing != aWebsiteAlert.Firing {
+				_, err = notifier.Notify(aTemplate.UserID, alertNotification(aTemplate, aWebsiteAlert, firing, sessions, value, detail))
+				if err != nil {
+					logrus.Error("send alert template notification error ", err)
+					continue
+				}
+				sent++
+			}
+			err = instance.repo.SetChecked(aTemplate.ID, aWebsiteAlert.WebsiteID, firing, sessions, value, detail, now, firedAt)
+			if err != nil {
+				return sent, err
+			}
+		}
+	}
+	return sent, nil
+}
+
+// alertNotification tell the owner aTemplate started, or stopped, to hold
+// for the website of aWebsiteAlert
+func alertNotification(aTemplate template, aWebsiteAlert websiteAlert, firing bool, sessions int64, value *float64, detail string) push.Notification {
+	state := "firing"
+	title := fmt.Sprintf("%s: %s", aTemplate.Name, aWebsiteAlert.URL)
+	body := fmt.Sprintf("%s had no session in the last %d minutes", aWebsiteAlert.URL, aTemplate.Minutes)
+	switch aTemplate.Kind {
+	case KindLowTraffic:
+		body = fmt.Sprintf("%s had %d sessions in the last %d minutes, fewer than %d", aWebsiteAlert.URL, sessions, aTemplate.Minutes, aTemplate.Threshold)
+	case KindMetricBelow:
+		body = fmt.Sprintf("%s of %s was %g in the last %d minutes, below %g", aTemplate.Metric, aWebsiteAlert.URL, *value, aTemplate.Minutes, aTemplate.Limit)
+	case KindMetricAbove:
+		body = fmt.Sprintf("%s of %s was %g in the last %d minutes, above %g", aTemplate.Metric, aWebsiteAlert.URL, *value, aTemplate.Minutes, aTemplate.Limit)
+	case KindBrowserDrop, KindReferrerSpike, KindPageGap:
+		body = qualityBody(aTemplate, aWebsiteAlert.URL, value, detail)
+	}
+	if !firing {
+		state = "resolved"
+		title = fmt.Sprintf("Resolved %s: %s", aTemplate.Name, aWebsiteAlert.URL)
+		body = fmt.Sprintf("%s had %d sessions in the last %d minutes", aWebsiteAlert.URL, sessions, aTemplate.Minutes)
+		if metricKind(aTemplate.Kind) {
+			body = fmt.Sprintf("%s of %s is back within %g", aTemplate.Metric, aWebsiteAlert.URL, aTemplate.Limit)
+		}
+		if qualityKind(aTemplate.Kind) {
+			body = fmt.Sprintf("the data of %s looks right again", aWebsiteAlert.URL)
+		}
+	}
+	return push.Notification{
+		Title: title,
+		Body:  body,
+		Data: map[string]string{
+			"template_id": aTemplate.ID,
+			"website_id":  aWebsiteAlert.WebsiteID,
+			"state":       state,
+		},
+	}
+}
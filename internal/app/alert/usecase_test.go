@@ -0,0 +1,102 @@
+package alert
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		template template
+		want     bool
+		check    func(template) bool
+	}{
+		{name: "should accept no traffic over 30 minutes", template: template{Name: "down", Kind: KindNoTraffic, Minutes: 30}, want: true},
+		{name: "should refuse a template without name", template: template{Kind: KindNoTraffic, Minutes: 30}, want: false},
+		{name: "should refuse an unknown kind", template: template{Name: "x", Kind: "slow", Minutes: 30}, want: false},
+		{name: "should refuse fewer than the minimum minutes", template: template{Name: "x", Kind: KindNoTraffic, Minutes: MinMinutes - 1}, want: false},
+		{name: "should refuse more than a day", template: template{Name: "x", Kind: KindNoTraffic, Minutes: MaxMinutes + 1}, want: false},
+		{name: "should need a threshold for low traffic", template: template{Name: "x", Kind: KindLowTraffic, Minutes: 30}, want: false},
+		{name: "should need a metric for metric kinds", template: template{Name: "x", Kind: KindMetricBelow, Minutes: 30, Limit: 1}, want: false},
+		{name: "should refuse a metric key that is no identifier", template: template{Name: "x", Kind: KindMetricAbove, Minutes: 30, Metric: "a b"}, want: false},
+		{name: "should accept a metric kind with its key", template: template{Name: "x", Kind: KindMetricAbove, Minutes: 30, Metric: "conversion_rate", Limit: 2}, want: true},
+		{
+			name:     "should drop the fields other kinds use",
+			template: template{Name: "x", Kind: KindNoTraffic, Minutes: 30, Threshold: 4, Metric: "m", Limit: 3, Page: "/a"},
+			want:     true,
+			check: func(aTemplate template) bool {
+				return aTemplate.Threshold == 0 && aTemplate.Metric == "" && aTemplate.Limit == 0 && aTemplate.Page == ""
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aTemplate := tt.template
+			if got := valid(&aTemplate); got != tt.want {
+				t.Errorf("valid() = %v, want %v", got, tt.want)
+			}
+			if tt.check != nil && !tt.check(aTemplate) {
+				t.Errorf("valid() kept %+v", aTemplate)
+			}
+		})
+	}
+}
+
+func TestFires(t *testing.T) {
+	value := func(v float64) *float64 {
+		return &v
+	}
+	tests := []struct {
+		name     string
+		template template
+		sessions int64
+		value    *float64
+		want     bool
+	}{
+		{name: "should fire no traffic without session", template: template{Kind: KindNoTraffic}, sessions: 0, want: true},
+		{name: "should not fire no traffic with a session", template: template{Kind: KindNoTraffic}, sessions: 1, want: false},
+		{name: "should fire low traffic below the threshold", template: template{Kind: KindLowTraffic, Threshold: 10}, sessions: 9, want: true},
+		{name: "should not fire low traffic at the threshold", template: template{Kind: KindLowTraffic, Threshold: 10}, sessions: 10, want: false},
+		{name: "should fire a metric below its limit", template: template{Kind: KindMetricBelow, Limit: 2}, value: value(1.5), want: true},
+		{name: "should fire a metric above its limit", template: template{Kind: KindMetricAbove, Limit: 2}, value: value(2.5), want: true},
+		{name: "should not fire a metric without value", template: template{Kind: KindMetricBelow, Limit: 2}, value: nil, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fires(tt.template, tt.sessions, tt.value); got != tt.want {
+				t.Errorf("fires() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlertNotification(t *testing.T) {
+	anAlert := websiteAlert{WebsiteID: "w1", URL: "example.com"}
+	limit := 1.5
+	tests := []struct {
+		name      string
+		template  template
+		firing    bool
+		sessions  int64
+		value     *float64
+		wantState string
+		wantBody  string
+	}{
+		{name: "should tell a website got no session", template: template{ID: "t1", Name: "down", Kind: KindNoTraffic, Minutes: 30}, firing: true, wantState: "firing", wantBody: "example.com had no session in the last 30 minutes"},
+		{name: "should tell the sessions of low traffic", template: template{ID: "t1", Name: "low", Kind: KindLowTraffic, Minutes: 60, Threshold: 20}, firing: true, sessions: 3, wantState: "firing", wantBody: "fewer than 20"},
+		{name: "should tell the value of a metric", template: template{ID: "t1", Name: "cr", Kind: KindMetricBelow, Minutes: 60, Metric: "cr", Limit: 2}, firing: true, value: &limit, wantState: "firing", wantBody: "cr of example.com was 1.5 in the last 60 minutes, below 2"},
+		{name: "should tell an alert stopped", template: template{ID: "t1", Name: "down", Kind: KindNoTraffic, Minutes: 30}, firing: false, sessions: 12, wantState: "resolved", wantBody: "example.com had 12 sessions in the last 30 minutes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := alertNotification(tt.template, anAlert, tt.firing, tt.sessions, tt.value, "")
+			if got.Data["state"] != tt.wantState || got.Data["template_id"] != "t1" || got.Data["website_id"] != "w1" {
+				t.Errorf("alertNotification() data = %v", got.Data)
+			}
+			if !strings.Contains(got.Body, tt.wantBody) {
+				t.Errorf("alertNotification() body = %q, want %q in it", got.Body, tt.wantBody)
+			}
+		})
+	}
+}
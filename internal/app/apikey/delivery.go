@@ -0,0 +1,27 @@
+package apikey
+
+import (
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPDelivery api keys users sign management requests with
+type HTTPDelivery interface {
+	// This function is required for every HTTPDelivery
+	InitRoutes(r *gin.RouterGroup)
+
+	// Other functions to handle HTTP requests
+	GetAllKey(c *gin.Context)
+	CreateKey(c *gin.Context)
+	DeleteKey(c *gin.Context)
+}
+
+// NewHTTPDelivery ...
+func NewHTTPDelivery(store *db.Store) HTTPDelivery {
+	return &httpDelivery{
+		apiKeyUseCase: NewUseCase(store),
+		authUsecase:   auth.NewUseCase(store),
+	}
+}
@@ -0,0 +1,118 @@
+package apikey
+
+import (
+	"net/http"
+
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/pkg/middleware"
+	req "analytics-api/internal/pkg/request"
+	"analytics-api/internal/pkg/security"
+	"analytics-api/internal/pkg/signature"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type httpDelivery struct {
+	apiKeyUseCase UseCase
+	authUsecase   auth.UseCase
+}
+
+// RequestKey ...
+type RequestKey struct {
+	Name string `json:"name"`
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	apiKeyRoutes := r.Group("api-keys")
+	{
+		apiKeyRoutes.GET("", middleware.JWTMiddleware(), instance.GetAllKey)
+		apiKeyRoutes.POST("", middleware.JWTMiddleware(), instance.CreateKey)
+		apiKeyRoutes.DELETE("/:key_id", middleware.JWTMiddleware(), instance.DeleteKey)
+	}
+}
+
+func (instance *httpDelivery) GetAllKey(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	keys, err := instance.apiKeyUseCase.GetAllKey(userID)
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get api keys failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// CreateKey add an api key, its secret is in this response only
+func (instance *httpDelivery) CreateKey(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	request, err := req.BindAndValidate[RequestKey](c)
+	if err != nil {
+		req.BadRequest(c, "invalid api key", err)
+		return
+	}
+
+	// a present signature was verified by JWTMiddleware
+	signed := c.GetHeader(signature.HeaderSignature) != ""
+	aKey, err := instance.apiKeyUseCase.CreateKey(userID, request.Name, signed)
+	switch err {
+	case nil:
+		c.JSON(http.StatusCreated, aKey)
+	case ErrSignatureRequired:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case ErrInvalidName, ErrTooManyKeys:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create api key failed"})
+	}
+}
+
+// DeleteKey revoke an api key
+func (instance *httpDelivery) DeleteKey(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	err = instance.apiKeyUseCase.DeleteKey(userID, c.Param("key_id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{"deleted": true})
+	case ErrKeyNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete api key failed"})
+	}
+}
@@ -0,0 +1,12 @@
+package apikey
+
+// apiKey key a user signs management requests with, the secret is only shown
+// when the key is created
+type apiKey struct {
+	ID         string `json:"id" bson:"id"`
+	UserID     string `json:"-" bson:"user_id"`
+	Name       string `json:"name" bson:"name"`
+	Secret     string `json:"secret,omitempty" bson:"secret"`
+	CreatedAt  string `json:"created_at" bson:"created_at"`
+	LastUsedAt string `json:"last_used_at,omitempty" bson:"last_used_at,omitempty"`
+}
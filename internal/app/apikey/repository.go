@@ -0,0 +1,97 @@
+package apikey
+
+import (
+	"context"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Repository ...
+type Repository interface {
+	InsertKey(aKey apiKey) error
+	GetAllKey(userID string) ([]apiKey, error)
+	CountKey(userID string) (int64, error)
+	GetKey(keyID string, aKey *apiKey) error
+	UpdateLastUsed(keyID, lastUsedAt string) error
+	DeleteKey(userID, keyID string) (int64, error)
+}
+
+type repository struct {
+	store *db.Store
+}
+
+// NewRepository ...
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store: store,
+	}
+}
+
+func (instance *repository) InsertKey(aKey apiKey) error {
+	keyCollection := instance.store.Mongo.Collection(configs.MongoDB.APIKeyCollection)
+	_, err := keyCollection.InsertOne(context.TODO(), aKey)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetAllKey keys of user, oldest first
+func (instance *repository) GetAllKey(userID string) ([]apiKey, error) {
+	keys := []apiKey{}
+	keyCollection := instance.store.Mongo.Collection(configs.MongoDB.APIKeyCollection)
+	opts := options.Find().SetSort(primitive.D{{Key: "created_at", Value: 1}})
+	cursor, err := keyCollection.Find(context.TODO(), bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (instance *repository) CountKey(userID string) (int64, error) {
+	keyCollection := instance.store.Mongo.Collection(configs.MongoDB.APIKeyCollection)
+	count, err := keyCollection.CountDocuments(context.TODO(), bson.M{"user_id": userID})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (instance *repository) GetKey(keyID string, aKey *apiKey) error {
+	keyCollection := instance.store.Mongo.Collection(configs.MongoDB.APIKeyCollection)
+	err := keyCollection.FindOne(context.TODO(), bson.M{"id": keyID}).Decode(aKey)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) UpdateLastUsed(keyID, lastUsedAt string) error {
+	keyCollection := instance.store.Mongo.Collection(configs.MongoDB.APIKeyCollection)
+	_, err := keyCollection.UpdateOne(context.TODO(), bson.M{"id": keyID}, bson.M{"$set": bson.M{"last_used_at": lastUsedAt}})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) DeleteKey(userID, keyID string) (int64, error) {
+	keyCollection := instance.store.Mongo.Collection(configs.MongoDB.APIKeyCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": keyID},
+	}}
+	result, err := keyCollection.DeleteOne(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,158 @@
+package apikey
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/pkg/security"
+	"analytics-api/internal/pkg/signature"
+	"analytics-api/internal/pkg/webhook"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrInvalidName ...
+	ErrInvalidName = errors.New("name of a key must be 1 to 100 characters")
+	// ErrTooManyKeys ...
+	ErrTooManyKeys = errors.New("at most 10 api keys per user")
+	// ErrKeyNotFound ...
+	ErrKeyNotFound = errors.New("this api key not exists")
+	// ErrUnknownKey signature made with a key that does not exist or belongs
+	// to another user than the access token
+	ErrUnknownKey = errors.New("unknown signature key")
+	// ErrReplayed ...
+	ErrReplayed = errors.New("signed request already received")
+	// ErrSignatureRequired only the first key of a user can be created
+	// without a signed request while writes must be signed
+	ErrSignatureRequired = errors.New("writes must be signed")
+)
+
+// MaxKeys api keys of a user
+const MaxKeys = 10
+
+// UseCase ...
+type UseCase interface {
+	CreateKey(userID, name string, signed bool) (*apiKey, error)
+	GetAllKey(userID string) ([]apiKey, error)
+	DeleteKey(userID, keyID string) error
+	VerifySignature(r *http.Request, body []byte) error
+}
+
+type useCase struct {
+	repo        Repository
+	store       *db.Store
+	authUseCase auth.UseCase
+}
+
+// NewUseCase ...
+func NewUseCase(store *db.Store) UseCase {
+	return &useCase{
+		repo:        NewRepository(store),
+		store:       store,
+		authUseCase: auth.NewUseCase(store),
+	}
+}
+
+// CreateKey new key of user, the returned key carries its secret. A request
+// not signed creates the first key of the user only when writes must be signed
+func (instance *useCase) CreateKey(userID, name string, signed bool) (*apiKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, ErrInvalidName
+	}
+	count, err := instance.repo.CountKey(userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= MaxKeys {
+		return nil, ErrTooManyKeys
+	}
+	if count > 0 && !signed && instance.store.SignedWrites.Load() {
+		return nil, ErrSignatureRequired
+	}
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	aKey := apiKey{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		Secret:    secret,
+		CreatedAt: time.Now().Format("2006-01-02, 15:04:05"),
+	}
+	err = instance.repo.InsertKey(aKey)
+	if err != nil {
+		return nil, err
+	}
+	return &aKey, nil
+}
+
+// GetAllKey keys of user without their secret
+func (instance *useCase) GetAllKey(userID string) ([]apiKey, error) {
+	keys, err := instance.repo.GetAllKey(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range keys {
+		keys[i].Secret = ""
+	}
+	return keys, nil
+}
+
+// DeleteKey revoke a key of user, requests signed with it are rejected at once
+func (instance *useCase) DeleteKey(userID, keyID string) error {
+	deleted, err := instance.repo.DeleteKey(userID, keyID)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+// VerifySignature check the signature of r was made with a key of the user
+// of its access token and was not received before. Signatures are kept in
+// the key store for twice signature.MaxSkew, by then they have expired anyway
+func (instance *useCase) VerifySignature(r *http.Request, body []byte) error {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(r)
+	if err != nil {
+		return err
+	}
+	userID, err := instance.authUseCase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		return err
+	}
+
+	var aKey apiKey
+	err = instance.repo.GetKey(r.Header.Get(signature.HeaderKey), &aKey)
+	if err == mongo.ErrNoDocuments || (err == nil && aKey.UserID != userID) {
+		return ErrUnknownKey
+	}
+	if err != nil {
+		return err
+	}
+	err = signature.Verify(aKey.Secret, r.Header.Get(signature.HeaderTimestamp), r.Method, r.URL.RequestURI(), body,
+		r.Header.Get(signature.HeaderSignature), time.Now())
+	if err != nil {
+		return err
+	}
+
+	fresh, err := configs.KeyStore.Client.SetNX(instance.store.Key("signature:"+aKey.ID+":"+r.Header.Get(signature.HeaderSignature)), "1", 2*signature.MaxSkew)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrReplayed
+	}
+	return instance.repo.UpdateLastUsed(aKey.ID, time.Now().Format("2006-01-02, 15:04:05"))
+}
@@ -0,0 +1,25 @@
+package archive
+
+import (
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPDelivery manifests of the archived events of websites
+type HTTPDelivery interface {
+	// This function is required for every HTTPDelivery
+	InitRoutes(r *gin.RouterGroup)
+
+	// Other functions to handle HTTP requests
+	GetManifest(c *gin.Context)
+}
+
+// NewHTTPDelivery ...
+func NewHTTPDelivery(store *db.Store) HTTPDelivery {
+	return &httpDelivery{
+		archiveUseCase: NewUseCase(store),
+		authUsecase:    auth.NewUseCase(store),
+	}
+}
@@ -0,0 +1,76 @@
+package archive
+
+import (
+	"net/http"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/pkg/middleware"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type httpDelivery struct {
+	archiveUseCase UseCase
+	authUsecase    auth.UseCase
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	archiveRoutes := r.Group("archive")
+	{
+		archiveRoutes.GET("/:website_id", middleware.JWTMiddleware(), instance.GetManifest)
+	}
+}
+
+// GetManifest archived days of a website between from and to, both included,
+// the last 30 days by default
+func (instance *httpDelivery) GetManifest(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(0, 0, -30)
+	if value := c.Query("to"); value != "" {
+		if to, err = time.Parse(dayLayout, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date like 2006-01-02"})
+			return
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	if value := c.Query("from"); value != "" {
+		if from, err = time.Parse(dayLayout, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date like 2006-01-02"})
+			return
+		}
+	}
+
+	manifests, err := instance.archiveUseCase.GetManifest(userID, c.Param("website_id"), from, to)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{
+			"enabled":   configs.ArchiveEnabled(),
+			"bucket":    configs.Archive.Bucket,
+			"manifests": manifests,
+		})
+	case mongo.ErrNoDocuments:
+		c.JSON(http.StatusNotFound, gin.H{"error": "this website not exists"})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get manifests failed"})
+	}
+}
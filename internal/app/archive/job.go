@@ -0,0 +1,44 @@
+package archive
+
+import (
+	"time"
+
+	"analytics-api/db"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runAt time of day, in UTC, the previous day is archived
+const runAt = 30 * time.Minute
+
+// RunArchive archive the days of store since the latest archived, the
+// previous one at least, and the days marked dirty every night, and once on
+// start to catch up on missed nights, until the process exits
+func RunArchive(store *db.Store) {
+	useCase := NewUseCase(store)
+	for {
+		now := time.Now().UTC()
+		count, err := useCase.CatchUp(now)
+		if err != nil {
+			logrus.Error("archive events error ", err)
+		}
+		if count > 0 {
+			logrus.Info("archived events of ", count, " websites")
+		}
+		// days whose events were corrected, or got late events, since they
+		// were archived
+		count, err = useCase.ArchiveDirty()
+		if err != nil {
+			logrus.Error("archive changed days error ", err)
+		}
+		if count > 0 {
+			logrus.Info("archived again ", count, " changed days")
+		}
+
+		next := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).Add(runAt)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		time.Sleep(time.Until(next))
+	}
+}
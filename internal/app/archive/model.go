@@ -0,0 +1,87 @@
+package archive
+
+import (
+	"analytics-api/internal/pkg/parquet"
+)
+
+// dayLayout of archived days, in UTC
+const dayLayout = "2006-01-02"
+
+// manifest archived events of a website for a day, a day without events has
+// no files
+type manifest struct {
+	UserID    string `json:"-" bson:"user_id"`
+	WebsiteID string `json:"website_id" bson:"website_id"`
+	Day       string `json:"day" bson:"day"`
+	Files     []file `json:"files" bson:"files"`
+	Rows      int64  `json:"rows" bson:"rows"`
+	Bytes     int64  `json:"bytes" bson:"bytes"`
+	CreatedAt string `json:"created_at" bson:"created_at"`
+	// Dirty events of the day were corrected since, it is archived again
+	Dirty bool `json:"dirty,omitempty" bson:"dirty,omitempty"`
+}
+
+// file Parquet object of the archive
+type file struct {
+	Key   string `json:"key" bson:"key"`
+	URL   string `json:"url" bson:"url"`
+	Rows  int64  `json:"rows" bson:"rows"`
+	Bytes int64  `json:"bytes" bson:"bytes"`
+}
+
+// schema columns of the archived files, one row per event
+var schema = []parquet.Column{
+	{Name: "website_id", Type: parquet.String},
+	{Name: "session_id", Type: parquet.String},
+	{Name: "type", Type: parquet.Int64},
+	{Name: "timestamp", Type: parquet.TimestampMillis},
+	{Name: "time_report", Type: parquet.TimestampMillis},
+	{Name: "data", Type: parquet.String},
+	{Name: "sealed", Type: parquet.String},
+	{Name: "platform", Type: parquet.String},
+	{Name: "country", Type: parquet.String},
+	{Name: "country_code", Type: parquet.String},
+	{Name: "region", Type: parquet.String},
+	{Name: "region_code", Type: parquet.String},
+	{Name: "city", Type: parquet.String},
+	{Name: "device", Type: parquet.String},
+	{Name: "os", Type: parquet.String},
+	{Name: "os_version", Type: parquet.String},
+	{Name: "browser", Type: parquet.String},
+	{Name: "version", Type: parquet.String},
+	{Name: "app_version", Type: parquet.String},
+	{Name: "device_model", Type: parquet.String},
+	// late 1 for events flagged late, missing in files written before
+	{Name: "late", Type: parquet.Int64},
+}
+
+// Coverage days of a report read from the archive, the dates both included.
+// Missing days were never archived, their events are lost or the archive
+// was off
+type Coverage struct {
+	Enabled bool   `json:"enabled"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Days    int    `json:"days"`
+	Missing int    `json:"missing"`
+}
+
+// Add days of other to the coverage, for reports reading two ranges
+func (instance *Coverage) Add(other *Coverage) *Coverage {
+	if instance == nil {
+		return other
+	}
+	if other == nil {
+		return instance
+	}
+	sum := *instance
+	if other.From < sum.From {
+		sum.From = other.From
+	}
+	if other.To > sum.To {
+		sum.To = other.To
+	}
+	sum.Days += other.Days
+	sum.Missing += other.Missing
+	return &sum
+}
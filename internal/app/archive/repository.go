@@ -0,0 +1,149 @@
+package archive
+
+import (
+	"context"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Repository ...
+type Repository interface {
+	ReplaceManifest(aManifest manifest) error
+	CountManifest(userID, websiteID, day string) (int64, error)
+	GetManifest(userID, websiteID, from, to string) ([]manifest, error)
+	MarkDirty(userID, websiteID string, days []string) (int64, error)
+	MarkDirtyBefore(userID, websiteID, day, before string) (int64, error)
+	ListDirty(limit int64) ([]manifest, error)
+	LatestDay() (string, error)
+}
+
+type repository struct {
+	store *db.Store
+}
+
+// NewRepository ...
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store: store,
+	}
+}
+
+// ReplaceManifest store the manifest of the day of its website, replacing
+// the one of a day archived again
+func (instance *repository) ReplaceManifest(aManifest manifest) error {
+	archiveCollection := instance.store.Mongo.Collection(configs.MongoDB.ArchiveCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": aManifest.UserID},
+		{"website_id": aManifest.WebsiteID},
+		{"day": aManifest.Day},
+	}}
+	_, err := archiveCollection.ReplaceOne(context.TODO(), filter, aManifest, options.Replace().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) CountManifest(userID, websiteID, day string) (int64, error) {
+	archiveCollection := instance.store.Mongo.Collection(configs.MongoDB.ArchiveCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"day": day},
+	}}
+	count, err := archiveCollection.CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetManifest manifests of website for the days in [from, to], oldest first
+func (instance *repository) GetManifest(userID, websiteID, from, to string) ([]manifest, error) {
+	manifests := []manifest{}
+	archiveCollection := instance.store.Mongo.Collection(configs.MongoDB.ArchiveCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"day": bson.M{"$gte": from, "$lte": to}},
+	}}
+	opts := options.Find().SetSort(primitive.D{{Key: "day", Value: 1}})
+	cursor, err := archiveCollection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &manifests); err != nil {
+		return nil, err
+	}
+	return manifests, nil
+}
+
+// MarkDirty mark the archived days of website among days to be archived
+// again, days not archived yet are left alone
+func (instance *repository) MarkDirty(userID, websiteID string, days []string) (int64, error) {
+	archiveCollection := instance.store.Mongo.Collection(configs.MongoDB.ArchiveCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"day": bson.M{"$in": days}},
+	}}
+	result, err := archiveCollection.UpdateMany(context.TODO(), filter, bson.M{"$set": bson.M{"dirty": true}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// MarkDirtyBefore mark the day of website to be archived again when it was
+// archived before before
+func (instance *repository) MarkDirtyBefore(userID, websiteID, day, before string) (int64, error) {
+	archiveCollection := instance.store.Mongo.Collection(configs.MongoDB.ArchiveCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"day": day},
+		{"created_at": bson.M{"$lt": before}},
+	}}
+	result, err := archiveCollection.UpdateMany(context.TODO(), filter, bson.M{"$set": bson.M{"dirty": true}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// ListDirty manifests to archive again, up to limit, oldest day first
+func (instance *repository) ListDirty(limit int64) ([]manifest, error) {
+	manifests := []manifest{}
+	archiveCollection := instance.store.Mongo.Collection(configs.MongoDB.ArchiveCollection)
+	opts := options.Find().SetSort(primitive.D{{Key: "day", Value: 1}}).SetLimit(limit)
+	cursor, err := archiveCollection.Find(context.TODO(), bson.M{"dirty": true}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &manifests); err != nil {
+		return nil, err
+	}
+	return manifests, nil
+}
+
+// LatestDay the most recent day archived for any website, empty when nothing
+// was archived yet
+func (instance *repository) LatestDay() (string, error) {
+	archiveCollection := instance.store.Mongo.Collection(configs.MongoDB.ArchiveCollection)
+	opts := options.FindOne().SetSort(primitive.D{{Key: "day", Value: -1}}).SetProjection(bson.M{"day": 1})
+	var aManifest manifest
+	err := archiveCollection.FindOne(context.TODO(), bson.M{}, opts).Decode(&aManifest)
+	if err == mongo.ErrNoDocuments {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return aManifest.Day, nil
+}
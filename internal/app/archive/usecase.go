@@ -0,0 +1,459 @@
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/app/reconcile"
+	"analytics-api/internal/app/session"
+	"analytics-api/internal/app/user"
+	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/fairqueue"
+	"analytics-api/internal/pkg/parquet"
+	"analytics-api/internal/pkg/s3"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrDisabled ...
+var ErrDisabled = errors.New("archive is off, set ARCHIVE_S3_BUCKET")
+
+// dirtyBatch days archived again by one run of ArchiveDirty
+const dirtyBatch = 100
+
+// lateScan how far back days with late events merged are looked for
+const lateScan = 7 * 24 * time.Hour
+
+// partRows rows of a Parquet file before the next part starts, so a busy day
+// is never held in memory at once
+const partRows = 50000
+
+// UseCase ...
+type UseCase interface {
+	ArchiveDay(day time.Time) (int, error)
+	CatchUp(now time.Time) (int, error)
+	MarkDirty(userID, websiteID string, days []time.Time) error
+	ArchiveDirty() (int, error)
+	GetManifest(userID, websiteID string, from, to time.Time) ([]manifest, error)
+	Scan(userID, websiteID string, from, to time.Time, fn func(session.RawEvent) error) (*Coverage, error)
+}
+
+type useCase struct {
+	repo             Repository
+	store            *db.Store
+	sessionUseCase   session.UseCase
+	websiteUseCase   website.UseCase
+	userUseCase      user.UseCase
+	reconcileUseCase reconcile.UseCase
+	pool             *fairqueue.Pool
+}
+
+// NewUseCase ...
+func NewUseCase(store *db.Store) UseCase {
+	return &useCase{
+		repo:             NewRepository(store),
+		store:            store,
+		sessionUseCase:   session.NewUseCase(store),
+		websiteUseCase:   website.NewUseCase(store),
+		userUseCase:      user.NewUseCase(store),
+		reconcileUseCase: reconcile.NewUseCase(store),
+		pool:             fairqueue.New("archive", configs.Workers),
+	}
+}
+
+// ArchiveDay write the events of every website reported on day, in UTC, to
+// the archive bucket. Websites already archived for day are skipped so a
+// failed run can be repeated, the count of websites archived is returned.
+// Websites are archived on the workers of the pool, fairly by plan
+func (instance *useCase) ArchiveDay(day time.Time) (int, error) {
+	if !configs.ArchiveEnabled() {
+		return 0, ErrDisabled
+	}
+	client := s3.NewClient(configs.Archive.Bucket, configs.Archive.Region, configs.Archive.Endpoint,
+		configs.Archive.AccessKeyID, configs.Archive.SecretAccessKey)
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+
+	websites, err := instance.websiteUseCase.ListWebsite()
+	if err != nil {
+		return 0, err
+	}
+	weights := map[string]int{}
+	aRun := &run{}
+	for _, aWebsite := range *websites {
+		count, err := instance.repo.CountManifest(aWebsite.UserID, aWebsite.ID, day.Format(dayLayout))
+		if err != nil {
+			instance.pool.Wait()
+			return aRun.archived, err
+		}
+		if count > 0 {
+			continue
+		}
+		userID, websiteID := aWebsite.UserID, aWebsite.ID
+		instance.pool.Submit(websiteID, instance.weight(userID, weights), func() error {
+			err := instance.archiveWebsite(client, userID, websiteID, day)
+			if err != nil {
+				logrus.Error("archive website ", websiteID, " of ", day.Format(dayLayout), " error ", err)
+			}
+			aRun.done(err)
+			return err
+		})
+	}
+	instance.pool.Wait()
+	return aRun.archived, aRun.lastErr
+}
+
+// CatchUp archive every day from the latest one archived through the day
+// before now, oldest first, so nights missed while the server was down or the
+// bucket failing are not left behind. The latest day is archived again for
+// the websites a failed run skipped. Returns the websites archived over all days
+func (instance *useCase) CatchUp(now time.Time) (int, error) {
+	if !configs.ArchiveEnabled() {
+		return 0, ErrDisabled
+	}
+	latest, err := instance.repo.LatestDay()
+	if err != nil {
+		return 0, err
+	}
+	archived := 0
+	var lastErr error
+	for _, day := range missingDays(latest, now) {
+		count, err := instance.ArchiveDay(day)
+		archived += count
+		if err != nil {
+			// a website failing does not hold back the days after
+			lastErr = err
+		}
+	}
+	return archived, lastErr
+}
+
+// missingDays days to archive from latest, a day archived or empty, through
+// the day before now. Days whose events expired from the hot store are left
+// out, with nothing archived yet only the day before now is
+func missingDays(latest string, now time.Time) []time.Time {
+	now = now.UTC()
+	yesterday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+	from, err := time.Parse(dayLayout, latest)
+	if err != nil {
+		from = yesterday
+	}
+	if oldest := yesterday.AddDate(0, 0, 1-db.RetentionDays); from.Before(oldest) {
+		from = oldest
+	}
+	days := []time.Time{}
+	for day := from; !day.After(yesterday); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	return days
+}
+
+// MarkDirty mark the archived days of website to be archived again, for
+// events reported on them that were corrected
+func (instance *useCase) MarkDirty(userID, websiteID string, days []time.Time) error {
+	listDay := make([]string, 0, len(days))
+	for _, day := range days {
+		listDay = append(listDay, day.UTC().Format(dayLayout))
+	}
+	_, err := instance.repo.MarkDirty(userID, websiteID, listDay)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ArchiveDirty archive again the days marked dirty and those late events
+// were merged into since they were archived, their parts are replaced and
+// those left over deleted. Returns the days archived
+func (instance *useCase) ArchiveDirty() (int, error) {
+	if !configs.ArchiveEnabled() {
+		return 0, ErrDisabled
+	}
+	if err := instance.markLate(); err != nil {
+		return 0, err
+	}
+	client := s3.NewClient(configs.Archive.Bucket, configs.Archive.Region, configs.Archive.Endpoint,
+		configs.Archive.AccessKeyID, configs.Archive.SecretAccessKey)
+
+	manifests, err := instance.repo.ListDirty(dirtyBatch)
+	if err != nil {
+		return 0, err
+	}
+	weights := map[string]int{}
+	aRun := &run{}
+	for _, aManifest := range manifests {
+		day, err := time.Parse(dayLayout, aManifest.Day)
+		if err != nil {
+			instance.pool.Wait()
+			return aRun.archived, err
+		}
+		aManifest := aManifest
+		instance.pool.Submit(aManifest.WebsiteID, instance.weight(aManifest.UserID, weights), func() error {
+			err := instance.archiveWebsite(client, aManifest.UserID, aManifest.WebsiteID, day)
+			if err != nil {
+				logrus.Error("archive again website ", aManifest.WebsiteID, " of ", aManifest.Day, " error ", err)
+			}
+			aRun.done(err)
+			return err
+		})
+	}
+	instance.pool.Wait()
+	return aRun.archived, aRun.lastErr
+}
+
+// markLate mark dirty the archived days late events were merged into after
+// they were archived. Late events are looked for over lateScan, so missed
+// nights are caught up
+func (instance *useCase) markLate() error {
+	listLate, err := instance.reconcileUseCase.GetLateDays(time.Now().Add(-lateScan))
+	if err != nil {
+		return err
+	}
+	for _, aLate := range listLate {
+		_, err := instance.repo.MarkDirtyBefore(aLate.UserID, aLate.WebsiteID, aLate.Day.UTC().Format(dayLayout), aLate.LateAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run outcome of the websites of one run archived on the pool
+type run struct {
+	mu       sync.Mutex
+	archived int
+	lastErr  error
+}
+
+func (instance *run) done(err error) {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	if err != nil {
+		instance.lastErr = err
+		return
+	}
+	instance.archived++
+}
+
+// weight share of the workers of the websites of userID, looked up once a
+// run in weights. An owner whose plan cannot be read gets the smallest
+func (instance *useCase) weight(userID string, weights map[string]int) int {
+	if weight, ok := weights[userID]; ok {
+		return weight
+	}
+	weight, err := instance.userUseCase.WorkerWeight(userID)
+	if err != nil {
+		logrus.Error("get plan of ", userID, " error ", err)
+		weight = 1
+	}
+	weights[userID] = weight
+	return weight
+}
+
+// archiveWebsite upload the events of website reported on day as Parquet parts
+// then record their manifest, in place of the one of a day archived before
+func (instance *useCase) archiveWebsite(client *s3.Client, userID, websiteID string, day time.Time) error {
+	aManifest := manifest{
+		UserID:    userID,
+		WebsiteID: websiteID,
+		Day:       day.Format(dayLayout),
+		Files:     []file{},
+	}
+	var buf bytes.Buffer
+	writer := parquet.NewWriter(&buf, schema)
+	upload := func() error {
+		if writer.Rows() == 0 {
+			return nil
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+		key := instance.key(websiteID, day, len(aManifest.Files))
+		if err := client.Put(key, buf.Bytes(), "application/vnd.apache.parquet"); err != nil {
+			return err
+		}
+		aFile := file{Key: key, URL: client.URL(key), Rows: writer.Rows(), Bytes: int64(buf.Len())}
+		aManifest.Files = append(aManifest.Files, aFile)
+		aManifest.Rows += aFile.Rows
+		aManifest.Bytes += aFile.Bytes
+		buf.Reset()
+		writer = parquet.NewWriter(&buf, schema)
+		return nil
+	}
+
+	err := instance.sessionUseCase.StreamRawEvent(userID, websiteID, day, day.AddDate(0, 0, 1), func(anEvent session.RawEvent) error {
+		late := int64(0)
+		if anEvent.Late {
+			late = 1
+		}
+		err := writer.Write([]interface{}{
+			anEvent.WebsiteID, anEvent.SessionID, anEvent.Type, anEvent.Timestamp, anEvent.TimeReport.UnixMilli(),
+			anEvent.Data, anEvent.Sealed, anEvent.Platform, anEvent.Country, anEvent.CountryCode,
+			anEvent.Region, anEvent.RegionCode, anEvent.City, anEvent.Device, anEvent.OS,
+			anEvent.OSVersion, anEvent.Browser, anEvent.Version, anEvent.AppVersion, anEvent.DeviceModel, late,
+		})
+		if err != nil {
+			return err
+		}
+		if writer.Rows() >= partRows {
+			return upload()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := upload(); err != nil {
+		return err
+	}
+
+	aManifest.CreatedAt = time.Now().Format("2006-01-02, 15:04:05")
+	previous, err := instance.repo.GetManifest(userID, websiteID, aManifest.Day, aManifest.Day)
+	if err != nil {
+		return err
+	}
+	err = instance.repo.ReplaceManifest(aManifest)
+	if err != nil {
+		return err
+	}
+	// parts of a day archived before with more parts than now
+	for _, aPrevious := range previous {
+		for _, aFile := range aPrevious.Files[min(len(aManifest.Files), len(aPrevious.Files)):] {
+			if err := client.Delete(aFile.Key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// key of a part of the archive, hive partitioned so query engines prune by
+// date and website. Tenants archive under their own prefix
+func (instance *useCase) key(websiteID string, day time.Time, part int) string {
+	key := fmt.Sprintf("year=%04d/month=%02d/day=%02d/website=%s/part-%05d.parquet", day.Year(), day.Month(), day.Day(), websiteID, part)
+	if instance.store.TenantID != "" {
+		key = "tenant=" + instance.store.TenantID + "/" + key
+	}
+	if configs.Archive.Prefix != "" {
+		key = configs.Archive.Prefix + "/" + key
+	}
+	return key
+}
+
+// GetManifest manifests of the archived days of website in [from, to)
+func (instance *useCase) GetManifest(userID, websiteID string, from, to time.Time) ([]manifest, error) {
+	exists, err := instance.websiteUseCase.HasWebsite(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, mongo.ErrNoDocuments
+	}
+	manifests, err := instance.repo.GetManifest(userID, websiteID, from.Format(dayLayout), to.AddDate(0, 0, -1).Format(dayLayout))
+	if err != nil {
+		return nil, err
+	}
+	return manifests, nil
+}
+
+// Scan call fn with each archived event of website reported in [from, to),
+// day by day. The files are read by ranges a row group at a time, so memory
+// holds one row group whatever the size of a day
+func (instance *useCase) Scan(userID, websiteID string, from, to time.Time, fn func(session.RawEvent) error) (*Coverage, error) {
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	last := to.Add(-time.Millisecond).UTC()
+	last = time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, time.UTC)
+	aCoverage := &Coverage{
+		Enabled: configs.ArchiveEnabled(),
+		From:    from.Format(dayLayout),
+		To:      last.Format(dayLayout),
+		Missing: int(last.Sub(from)/(24*time.Hour)) + 1,
+	}
+	if !aCoverage.Enabled {
+		return aCoverage, nil
+	}
+	client := s3.NewClient(configs.Archive.Bucket, configs.Archive.Region, configs.Archive.Endpoint,
+		configs.Archive.AccessKeyID, configs.Archive.SecretAccessKey)
+
+	manifests, err := instance.repo.GetManifest(userID, websiteID, aCoverage.From, aCoverage.To)
+	if err != nil {
+		return nil, err
+	}
+	for _, aManifest := range manifests {
+		for _, aFile := range aManifest.Files {
+			object, err := client.Open(aFile.Key)
+			if err != nil {
+				return nil, err
+			}
+			reader, err := parquet.NewReaderAt(object, object.Size)
+			if err != nil {
+				return nil, fmt.Errorf("archive %s: %w", aFile.Key, err)
+			}
+			columns := map[string]int{}
+			for i, column := range reader.Schema() {
+				columns[column.Name] = i
+			}
+			err = reader.Read(func(row []interface{}) error {
+				anEvent := rawEvent(columns, row)
+				if anEvent.TimeReport.Before(from) || !anEvent.TimeReport.Before(to) {
+					return nil
+				}
+				anEvent.UserID = userID
+				return fn(anEvent)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		aCoverage.Days++
+		aCoverage.Missing--
+	}
+	return aCoverage, nil
+}
+
+// rawEvent event of an archived row, columns by name so files written before
+// a column was added still read
+func rawEvent(columns map[string]int, row []interface{}) session.RawEvent {
+	str := func(name string) string {
+		if i, ok := columns[name]; ok {
+			v, _ := row[i].(string)
+			return v
+		}
+		return ""
+	}
+	number := func(name string) int64 {
+		if i, ok := columns[name]; ok {
+			v, _ := row[i].(int64)
+			return v
+		}
+		return 0
+	}
+	return session.RawEvent{
+		SessionID:   str("session_id"),
+		WebsiteID:   str("website_id"),
+		Type:        number("type"),
+		Data:        str("data"),
+		Sealed:      str("sealed"),
+		Timestamp:   number("timestamp"),
+		TimeReport:  time.UnixMilli(number("time_report")).UTC(),
+		Platform:    str("platform"),
+		Country:     str("country"),
+		CountryCode: str("country_code"),
+		Region:      str("region"),
+		RegionCode:  str("region_code"),
+		City:        str("city"),
+		Device:      str("device"),
+		OS:          str("os"),
+		OSVersion:   str("os_version"),
+		Browser:     str("browser"),
+		Version:     str("version"),
+		AppVersion:  str("app_version"),
+		DeviceModel: str("device_model"),
+		Late:        number("late") == 1,
+	}
+}
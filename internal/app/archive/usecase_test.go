@@ -0,0 +1,54 @@
+package archive
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"analytics-api/db"
+)
+
+func TestMissingDays(t *testing.T) {
+	now := time.Date(2024, 3, 10, 0, 30, 0, 0, time.UTC)
+	day := func(value string) time.Time {
+		at, _ := time.Parse(dayLayout, value)
+		return at
+	}
+	tests := []struct {
+		name   string
+		latest string
+		want   []time.Time
+	}{
+		{name: "should archive yesterday when nothing was archived", latest: "", want: []time.Time{day("2024-03-09")}},
+		{name: "should archive yesterday again when it was the latest", latest: "2024-03-09", want: []time.Time{day("2024-03-09")}},
+		{name: "should archive every day missed, oldest first", latest: "2024-03-06", want: []time.Time{day("2024-03-06"), day("2024-03-07"), day("2024-03-08"), day("2024-03-09")}},
+		{name: "should archive across months", latest: "2024-02-28", want: []time.Time{day("2024-02-28"), day("2024-02-29"), day("2024-03-01"), day("2024-03-02"), day("2024-03-03"), day("2024-03-04"), day("2024-03-05"), day("2024-03-06"), day("2024-03-07"), day("2024-03-08"), day("2024-03-09")}},
+		{name: "should archive nothing when today was archived", latest: "2024-03-10", want: []time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := missingDays(tt.latest, now)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("missingDays(%q) = %v, want %v", tt.latest, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("should leave out the days expired from the hot store", func(t *testing.T) {
+		got := missingDays("2020-01-01", now)
+		if len(got) != db.RetentionDays {
+			t.Fatalf("missingDays() = %d days, want %d", len(got), db.RetentionDays)
+		}
+		if want := day("2024-03-09"); !got[len(got)-1].Equal(want) {
+			t.Errorf("last day = %v, want %v", got[len(got)-1], want)
+		}
+	})
+
+	t.Run("should start from yesterday in UTC", func(t *testing.T) {
+		ahead := time.Date(2024, 3, 10, 5, 0, 0, 0, time.FixedZone("+07", 7*3600))
+		got := missingDays("", ahead)
+		if want := []time.Time{day("2024-03-08")}; !reflect.DeepEqual(got, want) {
+			t.Errorf("missingDays() = %v, want %v", got, want)
+		}
+	})
+}
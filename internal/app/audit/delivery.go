@@ -0,0 +1,25 @@
+package audit
+
+import (
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPDelivery audit log of what users did
+type HTTPDelivery interface {
+	// This function is required for every HTTPDelivery
+	InitRoutes(r *gin.RouterGroup)
+
+	// Other functions to handle HTTP requests
+	GetEntries(c *gin.Context)
+}
+
+// NewHTTPDelivery ...
+func NewHTTPDelivery(store *db.Store) HTTPDelivery {
+	return &httpDelivery{
+		auditUseCase: NewUseCase(store),
+		authUsecase:  auth.NewUseCase(store),
+	}
+}
@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"net/http"
+
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/pkg/cursor"
+	"analytics-api/internal/pkg/middleware"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type httpDelivery struct {
+	auditUseCase UseCase
+	authUsecase  auth.UseCase
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	auditRoutes := r.Group("audit")
+	{
+		auditRoutes.GET("", middleware.JWTMiddleware(), instance.GetEntries)
+	}
+}
+
+// ClientOf client of the request of c
+func ClientOf(c *gin.Context) Client {
+	return Client{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+}
+
+// GetEntries latest audit entries of the user, newest first
+func (instance *httpDelivery) GetEntries(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	limit := cursor.Limit(c.Query("limit"), 50, 500)
+	entries, err := instance.auditUseCase.GetEntries(userID, int64(limit))
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get audit entries failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
@@ -0,0 +1,60 @@
+package audit
+
+import "time"
+
+// Actions kept in the audit log
+const (
+	// ActionReplayView recording of a session was played
+	ActionReplayView = "session.replay"
+	// ActionVisitorWatch live events of an identified visitor were watched
+	ActionVisitorWatch = "visitor.watch"
+	// ActionLegalHold an admin placed a legal hold on a website
+	ActionLegalHold = "website.legal_hold"
+	// ActionLegalHoldRelease an admin released the legal hold of a website
+	ActionLegalHoldRelease = "website.legal_hold_release"
+	// ActionWebsiteCreate a website was added or imported
+	ActionWebsiteCreate = "website.create"
+	// ActionWebsiteUpdate the configuration of a website was changed
+	ActionWebsiteUpdate = "website.update"
+	// ActionWebsiteDelete a website was deleted
+	ActionWebsiteDelete = "website.delete"
+	// ActionWebsiteRestore a deleted website was restored
+	ActionWebsiteRestore = "website.restore"
+	// ActionWebsiteTransfer a transfer of a website was offered, cancelled or
+	// accepted
+	ActionWebsiteTransfer = "website.transfer"
+)
+
+// Change value of a field before and after an update, nil when the field
+// was not set
+type Change struct {
+	From interface{} `json:"from" bson:"from"`
+	To   interface{} `json:"to" bson:"to"`
+}
+
+// Client address and user agent an action was done from. The address is the
+// one gin resolves from the trusted proxies, forwarding headers of any other
+// client cannot forge it
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// entry something a user did that compliance asks to keep a trace of
+type entry struct {
+	ID        string `json:"id" bson:"id"`
+	UserID    string `json:"user_id" bson:"user_id"`
+	Action    string `json:"action" bson:"action"`
+	WebsiteID string `json:"website_id,omitempty" bson:"website_id,omitempty"`
+	SessionID string `json:"session_id,omitempty" bson:"session_id,omitempty"`
+	VisitorID string `json:"visitor_id,omitempty" bson:"visitor_id,omitempty"`
+	// Reason given by the admin for a legal hold
+	Reason string `json:"reason,omitempty" bson:"reason,omitempty"`
+	// Route method and route of the request changing a website, and the
+	// fields it changed
+	Route     string            `json:"route,omitempty" bson:"route,omitempty"`
+	Changes   map[string]Change `json:"changes,omitempty" bson:"changes,omitempty"`
+	IP        string            `json:"ip" bson:"ip"`
+	UserAgent string            `json:"user_agent" bson:"user_agent"`
+	CreatedAt time.Time         `json:"created_at" bson:"created_at"`
+}
@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"context"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Repository ...
+type Repository interface {
+	InsertEntry(anEntry entry) error
+	GetEntries(userID string, limit int64) ([]entry, error)
+	GetWebsiteEntries(userID, websiteID string, limit int64) ([]entry, error)
+}
+
+type repository struct {
+	store *db.Store
+}
+
+// NewRepository ...
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store: store,
+	}
+}
+
+func (instance *repository) InsertEntry(anEntry entry) error {
+	auditCollection := instance.store.Mongo.Collection(configs.MongoDB.AuditCollection)
+	_, err := auditCollection.InsertOne(context.TODO(), anEntry)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetEntries latest entries of user, newest first
+func (instance *repository) GetEntries(userID string, limit int64) ([]entry, error) {
+	entries := []entry{}
+	auditCollection := instance.store.Mongo.Collection(configs.MongoDB.AuditCollection)
+	opts := options.Find().SetSort(primitive.D{{Key: "created_at", Value: -1}}).SetLimit(limit)
+	cursor, err := auditCollection.Find(context.TODO(), bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetWebsiteEntries latest entries of user about website, newest first
+func (instance *repository) GetWebsiteEntries(userID, websiteID string, limit int64) ([]entry, error) {
+	entries := []entry{}
+	auditCollection := instance.store.Mongo.Collection(configs.MongoDB.AuditCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+	}}
+	opts := options.Find().SetSort(primitive.D{{Key: "created_at", Value: -1}}).SetLimit(limit)
+	cursor, err := auditCollection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
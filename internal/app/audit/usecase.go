@@ -0,0 +1,167 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/pkg/siem"
+
+	"github.com/google/uuid"
+)
+
+// UseCase ...
+type UseCase interface {
+	Record(client Client, userID, action, websiteID, sessionID string) (string, error)
+	RecordWatch(client Client, userID, websiteID, visitorID string) (string, error)
+	RecordHold(client Client, userID, websiteID string, held bool, reason string) (string, error)
+	RecordChange(client Client, userID, action, websiteID, route string, changes map[string]Change) (string, error)
+	GetEntries(userID string, limit int64) ([]entry, error)
+	GetWebsiteEntries(userID, websiteID string, limit int64) ([]entry, error)
+}
+
+type useCase struct {
+	repo     Repository
+	tenantID string
+}
+
+// NewUseCase ...
+func NewUseCase(store *db.Store) UseCase {
+	return &useCase{
+		repo:     NewRepository(store),
+		tenantID: store.TenantID,
+	}
+}
+
+// Record keep a trace of action of user from client, return the id
+// of the entry
+func (instance *useCase) Record(client Client, userID, action, websiteID, sessionID string) (string, error) {
+	return instance.record(client, entry{
+		UserID:    userID,
+		Action:    action,
+		WebsiteID: websiteID,
+		SessionID: sessionID,
+	})
+}
+
+// RecordWatch keep a trace of user watching the live events of visitor of
+// website from client
+func (instance *useCase) RecordWatch(client Client, userID, websiteID, visitorID string) (string, error) {
+	return instance.record(client, entry{
+		UserID:    userID,
+		Action:    ActionVisitorWatch,
+		WebsiteID: websiteID,
+		VisitorID: visitorID,
+	})
+}
+
+// RecordHold keep a trace of a legal hold of website of user placed, or
+// released when not held, by the admin from client
+func (instance *useCase) RecordHold(client Client, userID, websiteID string, held bool, reason string) (string, error) {
+	action := ActionLegalHoldRelease
+	if held {
+		action = ActionLegalHold
+	}
+	return instance.record(client, entry{
+		UserID:    userID,
+		Action:    action,
+		WebsiteID: websiteID,
+		Reason:    reason,
+	})
+}
+
+// RecordChange keep a trace of action of user on website through route,
+// with the fields it changed
+func (instance *useCase) RecordChange(client Client, userID, action, websiteID, route string, changes map[string]Change) (string, error) {
+	return instance.record(client, entry{
+		UserID:    userID,
+		Action:    action,
+		WebsiteID: websiteID,
+		Route:     route,
+		Changes:   changes,
+	})
+}
+
+// Diff fields of the JSON of before and after whose values differ, updated_at
+// and version left out since every update changes them
+func Diff(before, after interface{}) (map[string]Change, error) {
+	from, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := map[string]Change{}
+	for key, value := range from {
+		if !reflect.DeepEqual(value, to[key]) {
+			changes[key] = Change{From: value, To: to[key]}
+		}
+	}
+	for key, value := range to {
+		if _, ok := from[key]; !ok {
+			changes[key] = Change{To: value}
+		}
+	}
+	delete(changes, "updated_at")
+	delete(changes, "version")
+	return changes, nil
+}
+
+// fields of the JSON of value
+func fields(value interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]interface{}{}
+	err = json.Unmarshal(raw, &values)
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func (instance *useCase) record(client Client, anEntry entry) (string, error) {
+	anEntry.ID = uuid.New().String()
+	anEntry.IP = client.IP
+	anEntry.UserAgent = client.UserAgent
+	anEntry.CreatedAt = time.Now()
+	err := instance.repo.InsertEntry(anEntry)
+	if err != nil {
+		return "", err
+	}
+	siem.Publish(siem.Event{
+		Time:      anEntry.CreatedAt,
+		Action:    anEntry.Action,
+		Outcome:   siem.OutcomeSuccess,
+		TenantID:  instance.tenantID,
+		UserID:    anEntry.UserID,
+		WebsiteID: anEntry.WebsiteID,
+		SessionID: anEntry.SessionID,
+		VisitorID: anEntry.VisitorID,
+		Reason:    anEntry.Reason,
+		IP:        anEntry.IP,
+		UserAgent: anEntry.UserAgent,
+	})
+	return anEntry.ID, nil
+}
+
+func (instance *useCase) GetEntries(userID string, limit int64) ([]entry, error) {
+	entries, err := instance.repo.GetEntries(userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetWebsiteEntries latest entries of user about website, newest first
+func (instance *useCase) GetWebsiteEntries(userID, websiteID string, limit int64) ([]entry, error) {
+	entries, err := instance.repo.GetWebsiteEntries(userID, websiteID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+)
+
+// refreshCookiePath the refresh token is only sent to the auth routes
+const refreshCookiePath = "/auth"
+
+// cookieDomain domain of the token cookies, tenants are served on their own
+// hostnames
+func cookieDomain(tenantID string) string {
+	if tenantID != "" {
+		return ""
+	}
+	return "theodoiweb.fly.dev"
+}
+
+// SetCookies give the client the access and refresh token of tokenDetails
+func SetCookies(c *gin.Context, tokenDetails *security.TokenDetails) {
+	domain := cookieDomain(tokenDetails.TenantID)
+	c.SetCookie("access_token", tokenDetails.AccessToken, 86400, "/", domain, false, true)
+	if tokenDetails.RefreshToken != "" {
+		maxAge := int(time.Until(time.Unix(tokenDetails.RtExpires, 0)).Seconds())
+		c.SetCookie("refresh_token", tokenDetails.RefreshToken, maxAge, refreshCookiePath, domain, false, true)
+	}
+}
+
+// RespondTokens set the cookies of tokenDetails and answer its tokens, for
+// clients without cookies
+func RespondTokens(c *gin.Context, tokenDetails *security.TokenDetails) {
+	SetCookies(c, tokenDetails)
+	c.JSON(http.StatusOK, tokenResponse{
+		AccessToken:  tokenDetails.AccessToken,
+		RefreshToken: tokenDetails.RefreshToken,
+		AtExpires:    tokenDetails.AtExpires,
+		RtExpires:    tokenDetails.RtExpires,
+	})
+}
+
+// ClearCookies remove the token cookies of the client
+func ClearCookies(c *gin.Context) {
+	c.SetCookie("access_token", "", -1, "", "", false, true)
+	c.SetCookie("refresh_token", "", -1, refreshCookiePath, "", false, true)
+}
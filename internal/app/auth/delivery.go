@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"analytics-api/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPDelivery tokens of signed in users
+type HTTPDelivery interface {
+	// This function is required for every HTTPDelivery
+	InitRoutes(r *gin.RouterGroup)
+
+	// Other functions to handle HTTP requests
+	Refresh(c *gin.Context)
+	LogoutAll(c *gin.Context)
+	OAuth(c *gin.Context)
+	OAuthCallback(c *gin.Context)
+	CreatePersonalToken(c *gin.Context)
+	ListPersonalTokens(c *gin.Context)
+	RevokePersonalToken(c *gin.Context)
+	ShowForgotPasswordPage(c *gin.Context)
+	ForgotPassword(c *gin.Context)
+	ShowResetPasswordPage(c *gin.Context)
+	ResetPassword(c *gin.Context)
+}
+
+// NewHTTPDelivery accounts sign in the users of identity providers
+func NewHTTPDelivery(store *db.Store, accounts Accounts) HTTPDelivery {
+	return &httpDelivery{
+		store:       store,
+		authUsecase: NewUseCase(store),
+		accounts:    accounts,
+	}
+}
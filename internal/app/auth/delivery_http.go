@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"net/http"
+
+	"analytics-api/db"
+	"analytics-api/internal/pkg/httperr"
+	"analytics-api/internal/pkg/middleware"
+	"analytics-api/internal/pkg/security"
+	"analytics-api/internal/pkg/siem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// CodeRefreshTokenReused ...
+const CodeRefreshTokenReused = "refresh_token_reused"
+
+// Actions on tokens exported to the SIEM
+const (
+	// ActionRefreshReuse use of a refresh token used already
+	ActionRefreshReuse = "auth.refresh_reuse"
+	ActionLogoutAll    = "auth.logout_all"
+)
+
+type httpDelivery struct {
+	store       *db.Store
+	authUsecase UseCase
+	accounts    Accounts
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	authRoutes := r.Group("auth")
+	{
+		// the refresh token is the credential, the access token may be expired
+		authRoutes.POST("/refresh", instance.Refresh)
+		authRoutes.POST("/logout/all", middleware.JWTMiddleware(), instance.LogoutAll)
+		authRoutes.GET("/oauth/:provider", instance.OAuth)
+		authRoutes.GET("/oauth/:provider/callback", instance.OAuthCallback)
+		authRoutes.POST("/tokens", middleware.JWTMiddleware(), instance.CreatePersonalToken)
+		authRoutes.GET("/tokens", middleware.JWTMiddleware(), instance.ListPersonalTokens)
+		authRoutes.DELETE("/tokens/:token_id", middleware.JWTMiddleware(), instance.RevokePersonalToken)
+		authRoutes.GET("/password/forgot", instance.ShowForgotPasswordPage)
+		authRoutes.POST("/password/forgot", instance.ForgotPassword)
+		authRoutes.GET("/password/reset", instance.ShowResetPasswordPage)
+		authRoutes.POST("/password/reset", instance.ResetPassword)
+	}
+}
+
+// Refresh exchange the refresh token of the cookie, or of the body for
+// clients without cookies, for a new access and refresh token
+func (instance *httpDelivery) Refresh(c *gin.Context) {
+	refreshToken := security.ExtractRefreshToken(c.Request)
+	if refreshToken == "" {
+		var request RequestRefresh
+		// the body is optional, a missing token is answered below
+		_ = c.ShouldBindJSON(&request)
+		refreshToken = request.RefreshToken
+	}
+	if refreshToken == "" {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, security.ErrInvalidRefreshToken.Error())
+		return
+	}
+
+	tokenDetails, err := instance.authUsecase.Refresh(refreshToken, instance.store.TenantID)
+	switch err {
+	case nil:
+	case security.ErrInvalidRefreshToken:
+		ClearCookies(c)
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, err.Error())
+		return
+	case ErrRefreshTokenReused:
+		ClearCookies(c)
+		// Refresh verified it already
+		replayed, _ := security.VerifyRefreshToken(refreshToken)
+		siem.Publish(siem.Event{
+			Action:    ActionRefreshReuse,
+			Outcome:   siem.OutcomeFailure,
+			TenantID:  instance.store.TenantID,
+			UserID:    replayed.UserID,
+			Reason:    CodeRefreshTokenReused,
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		})
+		httperr.Abort(c, http.StatusUnauthorized, CodeRefreshTokenReused, err.Error())
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "refresh token failed")
+		return
+	}
+
+	RespondTokens(c, tokenDetails)
+}
+
+// LogoutAll sign the user out of every device, this one included, for a
+// lost laptop or phone
+func (instance *httpDelivery) LogoutAll(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetUser(tokenAuth)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	count, err := instance.authUsecase.LogoutAll(userID)
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "logout all devices failed")
+		return
+	}
+	// a session of before refresh tokens is in no family
+	if tokenAuth.FamilyID == "" {
+		if err := instance.authUsecase.DeleteAccessToken(tokenAuth.AccessUUID); err != nil {
+			logrus.Error(c, err)
+		}
+	}
+	siem.Publish(siem.Event{
+		Action:    ActionLogoutAll,
+		Outcome:   siem.OutcomeSuccess,
+		TenantID:  instance.store.TenantID,
+		UserID:    userID,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+
+	ClearCookies(c)
+	c.JSON(http.StatusOK, gin.H{"sessions": count})
+}
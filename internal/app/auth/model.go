@@ -0,0 +1,15 @@
+package auth
+
+// RequestRefresh refresh token of a client without cookies
+type RequestRefresh struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// tokenResponse tokens of a refresh or a switch of organization, expiries
+// as unix seconds
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	AtExpires    int64  `json:"access_token_expires"`
+	RtExpires    int64  `json:"refresh_token_expires"`
+}
@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/internal/pkg/httperr"
+	"analytics-api/internal/pkg/keystore"
+	"analytics-api/internal/pkg/oauth"
+	"analytics-api/internal/pkg/security"
+	"analytics-api/internal/pkg/siem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Identity providers users sign in with
+const (
+	ProviderGoogle = "google"
+	ProviderGitHub = "github"
+)
+
+// ActionOAuthSignIn sign in with an identity provider, exported to the SIEM
+const ActionOAuthSignIn = "auth.oauth_signin"
+
+// Codes of the error responses of the sign in with a provider
+const (
+	CodeOAuthProviderNotFound = "oauth_provider_not_found"
+	CodeOAuthStateInvalid     = "oauth_state_invalid"
+	CodeOAuthEmailUnverified  = "oauth_email_unverified"
+	CodeOAuthFailed           = "oauth_failed"
+)
+
+var (
+	// ErrUnknownProvider ...
+	ErrUnknownProvider = errors.New("this sign in provider is not configured")
+	// ErrInvalidOAuthState ...
+	ErrInvalidOAuthState = errors.New("the sign in expired or was started in another browser, sign in again")
+)
+
+// oauthStateTTL time the user has to sign in with the provider
+const oauthStateTTL = 10 * time.Minute
+
+// oauthStateCookie binds the sign in to the browser that started it, so a
+// callback of somebody else cannot sign this browser in
+const (
+	oauthStateCookie = "oauth_state"
+	oauthCookiePath  = "/auth/oauth"
+)
+
+// Accounts local users signing in with a provider, the user usecase
+type Accounts interface {
+	// SignInOAuth user of the identity with provider, linked to the account
+	// of its email or signed up when there is none
+	SignInOAuth(provider, subject, email, fullName string) (string, error)
+	// TwoFactorEnabled whether user gives a code of their second factor at
+	// every sign in, with a provider too
+	TwoFactorEnabled(userID string) (bool, error)
+	// FindUserID id of the user signed up with email, mongo.ErrNoDocuments
+	// when there is none
+	FindUserID(email string) (string, error)
+	// SetPassword hash password and set it as the password of user
+	SetPassword(userID, password string) error
+	// GetLocale locale of messages user chose, empty when they have not
+	// chosen one
+	GetLocale(userID string) (string, error)
+}
+
+// provider built from the configured oauth app
+func provider(name string) (oauth.Provider, error) {
+	switch name {
+	case ProviderGoogle:
+		if configs.OAuth.GoogleClientID != "" {
+			return oauth.NewGoogle(configs.OAuth.GoogleClientID, configs.OAuth.GoogleClientSecret), nil
+		}
+	case ProviderGitHub:
+		if configs.OAuth.GitHubClientID != "" {
+			return oauth.NewGitHub(configs.OAuth.GitHubClientID, configs.OAuth.GitHubClientSecret), nil
+		}
+	}
+	return nil, ErrUnknownProvider
+}
+
+// oauthRedirectURL where the provider sends the user back, registered with
+// the oauth app
+func oauthRedirectURL(name string) string {
+	return strings.TrimSuffix(configs.AppURL, "/") + "/auth/oauth/" + name + "/callback"
+}
+
+// OAuthURL page of provider where the user signs in, with the state the
+// callback must bring back
+func (instance *useCase) OAuthURL(name string) (string, string, error) {
+	aProvider, err := provider(name)
+	if err != nil {
+		return "", "", err
+	}
+	state := uuid.New().String()
+	err = instance.repo.InsertOAuthState(state, name)
+	if err != nil {
+		return "", "", err
+	}
+	return aProvider.AuthorizeURL(state, oauthRedirectURL(name)), state, nil
+}
+
+// OAuthIdentify identity of the user provider redirected back with code,
+// for a sign in started with state only
+func (instance *useCase) OAuthIdentify(name, state, code string) (*oauth.Identity, error) {
+	aProvider, err := provider(name)
+	if err != nil {
+		return nil, err
+	}
+	if state == "" || code == "" {
+		return nil, ErrInvalidOAuthState
+	}
+	started, err := instance.repo.TakeOAuthState(state)
+	if err != nil {
+		return nil, err
+	}
+	if started != name {
+		return nil, ErrInvalidOAuthState
+	}
+	return aProvider.Identify(code, oauthRedirectURL(name))
+}
+
+// InsertOAuthState remember the state of a sign in until the provider
+// redirects back
+func (instance *repository) InsertOAuthState(state, name string) error {
+	return instance.tokens.Set(instance.store.Key("oauth_state:"+state), name, oauthStateTTL)
+}
+
+// TakeOAuthState provider of a sign in state, which can only be used once:
+// it is read and deleted at once so two callbacks racing with the same
+// state cannot both get it
+func (instance *repository) TakeOAuthState(state string) (string, error) {
+	value, err := instance.tokens.Take(instance.store.Key("oauth_state:" + state))
+	if err == keystore.ErrNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// OAuth send the browser to the sign in page of the provider
+func (instance *httpDelivery) OAuth(c *gin.Context) {
+	authorizeURL, state, err := instance.authUsecase.OAuthURL(c.Param("provider"))
+	switch err {
+	case nil:
+	case ErrUnknownProvider:
+		httperr.Abort(c, http.StatusNotFound, CodeOAuthProviderNotFound, err.Error())
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "start sign in failed")
+		return
+	}
+	c.SetCookie(oauthStateCookie, state, int(oauthStateTTL.Seconds()), oauthCookiePath, cookieDomain(instance.store.TenantID), false, true)
+	c.Redirect(http.StatusFound, authorizeURL)
+}
+
+// OAuthCallback sign in the user the provider redirected back, linking the
+// local account of their email or signing them up
+func (instance *httpDelivery) OAuthCallback(c *gin.Context) {
+	name := c.Param("provider")
+	state, _ := c.Cookie(oauthStateCookie)
+	c.SetCookie(oauthStateCookie, "", -1, oauthCookiePath, cookieDomain(instance.store.TenantID), false, true)
+	// the user declined on the page of the provider
+	if c.Query("error") != "" {
+		c.Redirect(http.StatusFound, "/signin")
+		return
+	}
+	if state == "" || state != c.Query("state") {
+		instance.publishOAuth(c, siem.OutcomeFailure, "", "", CodeOAuthStateInvalid)
+		httperr.Abort(c, http.StatusBadRequest, CodeOAuthStateInvalid, ErrInvalidOAuthState.Error())
+		return
+	}
+
+	anIdentity, err := instance.authUsecase.OAuthIdentify(name, state, c.Query("code"))
+	switch err {
+	case nil:
+	case ErrUnknownProvider:
+		httperr.Abort(c, http.StatusNotFound, CodeOAuthProviderNotFound, err.Error())
+		return
+	case ErrInvalidOAuthState:
+		instance.publishOAuth(c, siem.OutcomeFailure, "", "", CodeOAuthStateInvalid)
+		httperr.Abort(c, http.StatusBadRequest, CodeOAuthStateInvalid, err.Error())
+		return
+	case oauth.ErrUnverifiedEmail:
+		instance.publishOAuth(c, siem.OutcomeFailure, "", "", CodeOAuthEmailUnverified)
+		httperr.Abort(c, http.StatusForbidden, CodeOAuthEmailUnverified, "verify your email with the provider before signing in with it")
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusBadGateway, CodeOAuthFailed, "sign in with the provider failed")
+		return
+	}
+
+	userID, err := instance.accounts.SignInOAuth(name, anIdentity.Subject, anIdentity.Email, anIdentity.Name)
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "sign in failed")
+		return
+	}
+	twoFactor, err := instance.accounts.TwoFactorEnabled(userID)
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "sign in failed")
+		return
+	}
+	if twoFactor {
+		preAuthToken, err := instance.authUsecase.InsertPreAuth(userID)
+		if err != nil {
+			logrus.Error(c, err)
+			httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "sign in failed")
+			return
+		}
+		AskSecondFactor(c, preAuthToken)
+		return
+	}
+	token, err := security.CreateToken(userID, instance.store.TenantID)
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "sign in failed")
+		return
+	}
+	if err := instance.authUsecase.InsertAuth(userID, token); err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "sign in failed")
+		return
+	}
+	instance.publishOAuth(c, siem.OutcomeSuccess, userID, anIdentity.Email, "")
+
+	SetCookies(c, token)
+	c.Redirect(http.StatusFound, "/profile/details")
+}
+
+// publishOAuth export a sign in with the provider of c, failures tell why in
+// reason
+func (instance *httpDelivery) publishOAuth(c *gin.Context, outcome, userID, email, reason string) {
+	siem.Publish(siem.Event{
+		Action:    ActionOAuthSignIn,
+		Outcome:   outcome,
+		TenantID:  instance.store.TenantID,
+		UserID:    userID,
+		Email:     email,
+		Reason:    reason,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"testing"
+
+	"analytics-api/db"
+	"analytics-api/internal/pkg/keystore"
+)
+
+func TestTakeOAuthState(t *testing.T) {
+	instance := &repository{store: &db.Store{}, tokens: keystore.NewMemory()}
+	if err := instance.InsertOAuthState("state", "github"); err != nil {
+		t.Fatalf("InsertOAuthState() error = %v", err)
+	}
+	tests := []struct {
+		name  string
+		state string
+		want  string
+	}{
+		{name: "should take the provider of the state", state: "state", want: "github"},
+		{name: "should not take a state used already", state: "state"},
+		{name: "should not take an unknown state", state: "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := instance.TakeOAuthState(tt.state)
+			if err != nil {
+				t.Fatalf("TakeOAuthState() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("TakeOAuthState() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/internal/pkg/httperr"
+	"analytics-api/internal/pkg/i18n"
+	"analytics-api/internal/pkg/keystore"
+	"analytics-api/internal/pkg/mail"
+	req "analytics-api/internal/pkg/request"
+	"analytics-api/internal/pkg/siem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PasswordResetTTL time the link of a password reset works
+const PasswordResetTTL = time.Hour
+
+// passwordResetInterval time between two emails of a password reset to the
+// same user, so the form cannot flood a mailbox
+const passwordResetInterval = time.Minute
+
+// Codes of password resets
+const (
+	CodePasswordResetInvalid = "password_reset_invalid"
+	CodeMailNotConfigured    = "mail_not_configured"
+)
+
+// Actions on passwords exported to the SIEM
+const (
+	ActionPasswordResetRequest = "auth.password_reset_request"
+	ActionPasswordReset        = "auth.password_reset"
+)
+
+// ErrPasswordResetInvalid ...
+var ErrPasswordResetInvalid = errors.New("this link expired or was already used, ask for a new one")
+
+// Keys of password resets, by the hash of their token and by user for the
+// latest one
+const (
+	passwordResetPrefix     = "password_reset:"
+	passwordResetUserPrefix = "password_reset_user:"
+	passwordResetSentPrefix = "password_reset_sent:"
+)
+
+// RequestForgotPassword ...
+type RequestForgotPassword struct {
+	Email string `json:"email" form:"email" validate:"required,email"`
+}
+
+// RequestResetPassword token of the emailed link and the new password
+type RequestResetPassword struct {
+	Token    string `json:"token" form:"token" validate:"required,max=100"`
+	Password string `json:"password" form:"password" validate:"required,min=8"`
+}
+
+// InsertPasswordReset token of a password reset of userID, good once for
+// PasswordResetTTL. Only the latest token of a user works. Empty when one
+// was sent to the user less than a minute ago
+func (instance *useCase) InsertPasswordReset(userID string) (string, error) {
+	first, err := instance.repo.ThrottlePasswordReset(userID)
+	if err != nil {
+		return "", err
+	}
+	if !first {
+		return "", nil
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(random)
+	err = instance.repo.InsertPasswordReset(hashPasswordReset(token), userID)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// TakePasswordReset user of the password reset token, which cannot be used
+// again. ErrPasswordResetInvalid when it expired, was used or a newer one
+// was asked for
+func (instance *useCase) TakePasswordReset(token string) (string, error) {
+	userID, err := instance.repo.TakePasswordReset(hashPasswordReset(token))
+	if err != nil {
+		return "", err
+	}
+	if userID == "" {
+		return "", ErrPasswordResetInvalid
+	}
+	return userID, nil
+}
+
+// hashPasswordReset the tokens are stored hashed, a leak of the store does
+// not give working links
+func hashPasswordReset(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ThrottlePasswordReset false when an email of a password reset was sent to
+// userID less than passwordResetInterval ago
+func (instance *repository) ThrottlePasswordReset(userID string) (bool, error) {
+	return instance.tokens.SetNX(instance.store.Key(passwordResetSentPrefix+userID), "1", passwordResetInterval)
+}
+
+// InsertPasswordReset store the hash of a token of userID, replacing the
+// previous one of the user
+func (instance *repository) InsertPasswordReset(hash, userID string) error {
+	err := instance.tokens.Set(instance.store.Key(passwordResetPrefix+hash), userID, PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+	return instance.tokens.Set(instance.store.Key(passwordResetUserPrefix+userID), hash, PasswordResetTTL)
+}
+
+// TakePasswordReset user of the token of hash when it is the latest of its
+// user, empty otherwise. Of two requests taking it at once one gets it
+func (instance *repository) TakePasswordReset(hash string) (string, error) {
+	key := instance.store.Key(passwordResetPrefix + hash)
+	userID, err := instance.tokens.Get(key)
+	if err == keystore.ErrNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	userKey := instance.store.Key(passwordResetUserPrefix + userID)
+	latest, err := instance.tokens.Get(userKey)
+	if err == keystore.ErrNotFound || (err == nil && latest != hash) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	first, err := instance.tokens.SetNX(key+usedSuffix, "1", PasswordResetTTL)
+	if err != nil {
+		return "", err
+	}
+	if !first {
+		return "", nil
+	}
+	if err := instance.tokens.Del(key, userKey); err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+// passwordResetURL page the emailed link opens
+func passwordResetURL(token string) string {
+	return strings.TrimSuffix(configs.AppURL, "/") + "/auth/password/reset?token=" + url.QueryEscape(token)
+}
+
+// ShowForgotPasswordPage ...
+func (instance *httpDelivery) ShowForgotPasswordPage(c *gin.Context) {
+	c.HTML(http.StatusOK, "forgot_password.html", gin.H{})
+}
+
+// ForgotPassword email a link resetting the password of the account of the
+// email. The answer is the same whether there is an account or not, so the
+// form does not tell which emails are signed up
+func (instance *httpDelivery) ForgotPassword(c *gin.Context) {
+	request, form, err := req.BindEitherAndValidate[RequestForgotPassword](c)
+	if err != nil {
+		req.BadRequest(c, "invalid email", err)
+		return
+	}
+	if !mail.Enabled() {
+		httperr.Abort(c, http.StatusServiceUnavailable, CodeMailNotConfigured, "password reset is not available, ask an administrator")
+		return
+	}
+
+	userID, err := instance.accounts.FindUserID(request.Email)
+	switch err {
+	case nil:
+		token, err := instance.authUsecase.InsertPasswordReset(userID)
+		if err != nil {
+			logrus.Error(c, err)
+			httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "reset password failed")
+			return
+		}
+		if token != "" {
+			// sent in the background, an account found answers as fast as none
+			go sendPasswordReset(request.Email, token, instance.mailLocale(c, userID))
+		}
+		instance.publishPasswordReset(c, ActionPasswordResetRequest, siem.OutcomeSuccess, userID, request.Email, "")
+	case mongo.ErrNoDocuments:
+		instance.publishPasswordReset(c, ActionPasswordResetRequest, siem.OutcomeFailure, "", request.Email, "email_not_found")
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "reset password failed")
+		return
+	}
+
+	if form {
+		c.HTML(http.StatusOK, "forgot_password.html", gin.H{"Sent": true})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"sent": true})
+}
+
+// sendPasswordReset email the link of token to email
+func sendPasswordReset(email, token, locale string) {
+	err := mail.Send(passwordResetMessage(email, token, locale))
+	if err != nil {
+		logrus.Error("send password reset email error ", err)
+	}
+}
+
+// passwordResetMessage email of the link of token to email, in locale
+func passwordResetMessage(email, token, locale string) mail.Message {
+	return mail.Message{
+		To:      email,
+		Subject: i18n.T(locale, "Reset your password"),
+		Body: fmt.Sprintf(i18n.T(locale, "Someone asked to reset the password of your account.\n\n"+
+			"Open this link within an hour to choose a new one:\n%s\n\n"+
+			"If it was not you, ignore this email, your password stays as it is.\n"), passwordResetURL(token)),
+	}
+}
+
+// ShowResetPasswordPage form of the emailed link
+func (instance *httpDelivery) ShowResetPasswordPage(c *gin.Context) {
+	c.HTML(http.StatusOK, "reset_password.html", gin.H{"Token": c.Query("token")})
+}
+
+// ResetPassword set the password of the user of the token, sign them out of
+// every device and revoke their personal tokens
+func (instance *httpDelivery) ResetPassword(c *gin.Context) {
+	request, form, err := req.BindEitherAndValidate[RequestResetPassword](c)
+	if err != nil {
+		req.BadRequest(c, "invalid password reset", err)
+		return
+	}
+
+	userID, err := instance.authUsecase.TakePasswordReset(request.Token)
+	switch err {
+	case nil:
+	case ErrPasswordResetInvalid:
+		instance.publishPasswordReset(c, ActionPasswordReset, siem.OutcomeFailure, "", "", CodePasswordResetInvalid)
+		httperr.Abort(c, http.StatusBadRequest, CodePasswordResetInvalid, err.Error())
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "reset password failed")
+		return
+	}
+	if err := instance.accounts.SetPassword(userID, request.Password); err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "reset password failed")
+		return
+	}
+	// whoever knew the old password is signed out too, and loses the
+	// personal tokens they could have created with it
+	if _, err := instance.authUsecase.LogoutAll(userID); err != nil {
+		logrus.Error(c, err)
+	}
+	if _, err := instance.authUsecase.RevokePersonalTokens(userID); err != nil {
+		logrus.Error(c, err)
+	}
+	instance.publishPasswordReset(c, ActionPasswordReset, siem.OutcomeSuccess, userID, "", "")
+
+	ClearCookies(c)
+	if form {
+		c.Redirect(http.StatusFound, "/signin")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reset": true})
+}
+
+// mailLocale locale of the emails to userID, the one they chose or else the
+// best match of the Accept-Language of the request
+func (instance *httpDelivery) mailLocale(c *gin.Context, userID string) string {
+	if locale, err := instance.accounts.GetLocale(userID); err == nil && locale != "" {
+		return locale
+	}
+	return i18n.Match(c.GetHeader("Accept-Language"))
+}
+
+// publishPasswordReset export a step of a password reset of c, failures tell
+// why in reason
+func (instance *httpDelivery) publishPasswordReset(c *gin.Context, action, outcome, userID, email, reason string) {
+	siem.Publish(siem.Event{
+		Action:    action,
+		Outcome:   outcome,
+		TenantID:  instance.store.TenantID,
+		UserID:    userID,
+		Email:     email,
+		Reason:    reason,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"analytics-api/db"
+	"analytics-api/internal/pkg/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeResets the token "valid" resets the password of user-1, and keeps
+// what was revoked for it
+type fakeResets struct {
+	UseCase
+	loggedOut []string
+	revoked   []string
+}
+
+func (instance *fakeResets) TakePasswordReset(token string) (string, error) {
+	if token != "valid" {
+		return "", ErrPasswordResetInvalid
+	}
+	return "user-1", nil
+}
+
+func (instance *fakeResets) LogoutAll(userID string) (int, error) {
+	instance.loggedOut = append(instance.loggedOut, userID)
+	return 1, nil
+}
+
+func (instance *fakeResets) RevokePersonalTokens(userID string) (int64, error) {
+	instance.revoked = append(instance.revoked, userID)
+	return 2, nil
+}
+
+// fakeAccounts keeps the passwords set
+type fakeAccounts struct {
+	Accounts
+	passwords map[string]string
+}
+
+func (instance *fakeAccounts) SetPassword(userID, password string) error {
+	instance.passwords[userID] = password
+	return nil
+}
+
+func TestResetPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name        string
+		token       string
+		wantStatus  int
+		wantRevoked int
+	}{
+		{name: "should set the password, sign out and revoke the personal tokens", token: "valid", wantStatus: http.StatusOK, wantRevoked: 1},
+		{name: "should change nothing with an invalid token", token: "used", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resets := &fakeResets{}
+			accounts := &fakeAccounts{passwords: map[string]string{}}
+			instance := &httpDelivery{store: &db.Store{}, authUsecase: resets, accounts: accounts}
+			router := gin.New()
+			router.POST("/auth/password/reset", instance.ResetPassword)
+
+			body := `{"token":"` + tt.token + `","password":"new password"}`
+			r := httptest.NewRequest(http.MethodPost, "/auth/password/reset", strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("ResetPassword() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if len(resets.loggedOut) != tt.wantRevoked || len(resets.revoked) != tt.wantRevoked || len(accounts.passwords) != tt.wantRevoked {
+				t.Errorf("ResetPassword() logged out %v, revoked tokens of %v, set passwords %v", resets.loggedOut, resets.revoked, accounts.passwords)
+			}
+		})
+	}
+}
+
+func TestPasswordResetMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		locale      string
+		wantSubject string
+		wantBody    string
+	}{
+		{name: "should write in english by default", locale: i18n.English, wantSubject: "Reset your password", wantBody: "Open this link within an hour"},
+		{name: "should write in the locale of the user", locale: i18n.Vietnamese, wantSubject: "Đặt lại mật khẩu của bạn", wantBody: "Mở liên kết này trong vòng một giờ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := passwordResetMessage("a@example.com", "token", tt.locale)
+			if got.Subject != tt.wantSubject {
+				t.Errorf("passwordResetMessage() subject = %v, want %v", got.Subject, tt.wantSubject)
+			}
+			if !strings.Contains(got.Body, tt.wantBody) || !strings.Contains(got.Body, "/auth/password/reset?token=token") {
+				t.Errorf("passwordResetMessage() body = %v, want the link and %v", got.Body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/internal/pkg/httperr"
+	req "analytics-api/internal/pkg/request"
+	"analytics-api/internal/pkg/security"
+	"analytics-api/internal/pkg/siem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// MaxPersonalTokens personal tokens of a user
+const MaxPersonalTokens = 10
+
+// PersonalTokenDays days a personal token lives when none are asked for,
+// up to MaxPersonalTokenDays
+const (
+	PersonalTokenDays    = 90
+	MaxPersonalTokenDays = 365
+)
+
+// Codes of personal tokens
+const (
+	CodePersonalTokenNotFound = "personal_token_not_found"
+	CodeTooManyPersonalTokens = "too_many_personal_tokens"
+)
+
+// Actions on personal tokens exported to the SIEM
+const (
+	ActionPersonalTokenCreate = "auth.personal_token_create"
+	ActionPersonalTokenRevoke = "auth.personal_token_revoke"
+)
+
+var (
+	// ErrPersonalTokenNotFound ...
+	ErrPersonalTokenNotFound = errors.New("this personal token not exists")
+	// ErrTooManyPersonalTokens ...
+	ErrTooManyPersonalTokens = errors.New("at most 10 personal tokens per user")
+)
+
+// personalToken long lived access token of a user for scripts, limited to
+// its scopes. The token itself is only shown when it is created
+type personalToken struct {
+	ID     string `json:"id" bson:"id"`
+	UserID string `json:"-" bson:"user_id"`
+	// OrgID organization the token acts for, empty for the user
+	OrgID      string   `json:"org_id,omitempty" bson:"org_id,omitempty"`
+	Name       string   `json:"name" bson:"name"`
+	Scopes     []string `json:"scopes" bson:"scopes"`
+	Token      string   `json:"token,omitempty" bson:"-"`
+	CreatedAt  string   `json:"created_at" bson:"created_at"`
+	ExpiresAt  string   `json:"expires_at" bson:"expires_at"`
+	LastUsedAt string   `json:"last_used_at,omitempty" bson:"last_used_at,omitempty"`
+}
+
+// RequestPersonalToken scopes are permissions of the role, the token never
+// gets more than the role of its user
+type RequestPersonalToken struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,max=4,dive,oneof=read replay write manage"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+// CreatePersonalToken new token of the user signed in with tokenAuth, acting
+// for the same account, good for days. The returned token carries its jwt
+func (instance *useCase) CreatePersonalToken(tokenAuth *security.TokenDetails, userID, name string, scopes []string, days int) (*personalToken, error) {
+	count, err := instance.repo.CountPersonalTokens(userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= MaxPersonalTokens {
+		return nil, ErrTooManyPersonalTokens
+	}
+	if days == 0 {
+		days = PersonalTokenDays
+	}
+
+	now := time.Now()
+	expires := now.AddDate(0, 0, days)
+	aToken := personalToken{
+		ID:        security.PersonalPrefix + uuid.New().String(),
+		UserID:    userID,
+		OrgID:     tokenAuth.OrgID,
+		Name:      strings.TrimSpace(name),
+		Scopes:    scopes,
+		CreatedAt: now.Format("2006-01-02, 15:04:05"),
+		ExpiresAt: expires.Format("2006-01-02, 15:04:05"),
+	}
+	aToken.Token, err = security.CreatePersonalToken(aToken.ID, userID, tokenAuth.TenantID, aToken.OrgID, scopes, expires)
+	if err != nil {
+		return nil, err
+	}
+	err = instance.repo.InsertPersonalToken(aToken)
+	if err != nil {
+		return nil, err
+	}
+	return &aToken, nil
+}
+
+// ListPersonalTokens tokens of user without their jwt, expired ones too
+func (instance *useCase) ListPersonalTokens(userID string) ([]personalToken, error) {
+	return instance.repo.ListPersonalTokens(userID)
+}
+
+// RevokePersonalToken delete a token of user, requests with it are rejected
+// at once
+func (instance *useCase) RevokePersonalToken(userID, tokenID string) error {
+	deleted, err := instance.repo.DeletePersonalToken(userID, tokenID)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrPersonalTokenNotFound
+	}
+	return nil
+}
+
+// RevokePersonalTokens delete every token of user, those acting for its
+// organizations too. Returns how many there were
+func (instance *useCase) RevokePersonalTokens(userID string) (int64, error) {
+	return instance.repo.DeletePersonalTokens(userID)
+}
+
+// personalAccount account the personal token of tokenID acts for, like
+// GetAuth for the tokens of sign ins. mongo.ErrNoDocuments once it is
+// revoked or expired
+func (instance *useCase) personalAccount(tokenID string) (string, error) {
+	now := time.Now().Format("2006-01-02, 15:04:05")
+	var aToken personalToken
+	err := instance.repo.GetPersonalToken(tokenID, now, &aToken)
+	if err != nil {
+		return "", err
+	}
+	if err := instance.repo.UpdatePersonalTokenUsed(tokenID, now); err != nil {
+		logrus.Error("update personal token last used error ", err)
+	}
+	if aToken.OrgID != "" {
+		return aToken.OrgID, nil
+	}
+	return aToken.UserID, nil
+}
+
+// InsertPersonalToken ...
+func (instance *repository) InsertPersonalToken(aToken personalToken) error {
+	tokenCollection := instance.store.Mongo.Collection(configs.MongoDB.PersonalTokenCollection)
+	_, err := tokenCollection.InsertOne(context.TODO(), aToken)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListPersonalTokens tokens of user, oldest first
+func (instance *repository) ListPersonalTokens(userID string) ([]personalToken, error) {
+	tokens := []personalToken{}
+	tokenCollection := instance.store.Mongo.Collection(configs.MongoDB.PersonalTokenCollection)
+	opts := options.Find().SetSort(primitive.D{{Key: "created_at", Value: 1}})
+	cursor, err := tokenCollection.Find(context.TODO(), bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// CountPersonalTokens ...
+func (instance *repository) CountPersonalTokens(userID string) (int64, error) {
+	tokenCollection := instance.store.Mongo.Collection(configs.MongoDB.PersonalTokenCollection)
+	count, err := tokenCollection.CountDocuments(context.TODO(), bson.M{"user_id": userID})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetPersonalToken token of tokenID expiring after now, mongo.ErrNoDocuments
+// when it is revoked or expired
+func (instance *repository) GetPersonalToken(tokenID, now string, aToken *personalToken) error {
+	tokenCollection := instance.store.Mongo.Collection(configs.MongoDB.PersonalTokenCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"id": tokenID},
+		{"expires_at": bson.M{"$gt": now}},
+	}}
+	return tokenCollection.FindOne(context.TODO(), filter).Decode(aToken)
+}
+
+// UpdatePersonalTokenUsed ...
+func (instance *repository) UpdatePersonalTokenUsed(tokenID, lastUsedAt string) error {
+	tokenCollection := instance.store.Mongo.Collection(configs.MongoDB.PersonalTokenCollection)
+	_, err := tokenCollection.UpdateOne(context.TODO(), bson.M{"id": tokenID}, bson.M{"$set": bson.M{"last_used_at": lastUsedAt}})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeletePersonalToken ...
+func (instance *repository) DeletePersonalToken(userID, tokenID string) (int64, error) {
+	tokenCollection := instance.store.Mongo.Collection(configs.MongoDB.PersonalTokenCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": tokenID},
+	}}
+	result, err := tokenCollection.DeleteOne(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// DeletePersonalTokens ...
+func (instance *repository) DeletePersonalTokens(userID string) (int64, error) {
+	tokenCollection := instance.store.Mongo.Collection(configs.MongoDB.PersonalTokenCollection)
+	result, err := tokenCollection.DeleteMany(context.TODO(), bson.M{"user_id": userID})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// signedInUser user signed in on c with a sign in, personal tokens cannot
+// manage personal tokens. Aborts and returns false otherwise
+func (instance *httpDelivery) signedInUser(c *gin.Context) (*security.TokenDetails, string, bool) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return nil, "", false
+	}
+	userID, err := instance.authUsecase.GetUser(tokenAuth)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return nil, "", false
+	}
+	if tokenAuth.Personal() {
+		httperr.Abort(c, http.StatusForbidden, httperr.CodeForbidden, "personal tokens cannot manage personal tokens")
+		return nil, "", false
+	}
+	return tokenAuth, userID, true
+}
+
+// CreatePersonalToken add a personal token, its jwt is in this response only
+func (instance *httpDelivery) CreatePersonalToken(c *gin.Context) {
+	tokenAuth, userID, ok := instance.signedInUser(c)
+	if !ok {
+		return
+	}
+	request, err := req.BindAndValidate[RequestPersonalToken](c)
+	if err != nil {
+		req.BadRequest(c, "invalid personal token", err)
+		return
+	}
+
+	aToken, err := instance.authUsecase.CreatePersonalToken(tokenAuth, userID, request.Name, request.Scopes, request.ExpiresInDays)
+	switch err {
+	case nil:
+	case ErrTooManyPersonalTokens:
+		httperr.Abort(c, http.StatusBadRequest, CodeTooManyPersonalTokens, err.Error())
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "create personal token failed")
+		return
+	}
+	siem.Publish(siem.Event{
+		Action:    ActionPersonalTokenCreate,
+		Outcome:   siem.OutcomeSuccess,
+		TenantID:  instance.store.TenantID,
+		UserID:    userID,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	c.JSON(http.StatusCreated, aToken)
+}
+
+// ListPersonalTokens ...
+func (instance *httpDelivery) ListPersonalTokens(c *gin.Context) {
+	_, userID, ok := instance.signedInUser(c)
+	if !ok {
+		return
+	}
+	tokens, err := instance.authUsecase.ListPersonalTokens(userID)
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get personal tokens failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+// RevokePersonalToken ...
+func (instance *httpDelivery) RevokePersonalToken(c *gin.Context) {
+	_, userID, ok := instance.signedInUser(c)
+	if !ok {
+		return
+	}
+	err := instance.authUsecase.RevokePersonalToken(userID, c.Param("token_id"))
+	switch err {
+	case nil:
+	case ErrPersonalTokenNotFound:
+		httperr.Abort(c, http.StatusNotFound, CodePersonalTokenNotFound, err.Error())
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "revoke personal token failed")
+		return
+	}
+	siem.Publish(siem.Event{
+		Action:    ActionPersonalTokenRevoke,
+		Outcome:   siem.OutcomeSuccess,
+		TenantID:  instance.store.TenantID,
+		UserID:    userID,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	c.JSON(http.StatusOK, gin.H{"deleted": true})
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"analytics-api/internal/pkg/keystore"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PreAuthTTL time a user who gave their password has to give the code of
+// their second factor
+const PreAuthTTL = 5 * time.Minute
+
+// MaxPreAuthAttempts wrong codes a pre-auth token takes before it is revoked,
+// the user then signs in with their password again
+const MaxPreAuthAttempts = 5
+
+// CodePreAuthExpired ...
+const CodePreAuthExpired = "pre_auth_expired"
+
+// ErrPreAuthExpired ...
+var ErrPreAuthExpired = errors.New("the sign in expired, sign in with your password again")
+
+const (
+	preAuthPrefix = "pre_auth:"
+	failsSuffix   = ":fails"
+)
+
+// InsertPreAuth token of userID signed in with their first factor only, good
+// for PreAuthTTL to give the second
+func (instance *useCase) InsertPreAuth(userID string) (string, error) {
+	token := uuid.New().String()
+	err := instance.repo.InsertPreAuth(token, userID)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetPreAuth user of the pre-auth token, ErrPreAuthExpired when it expired
+// or was revoked
+func (instance *useCase) GetPreAuth(token string) (string, error) {
+	userID, err := instance.repo.GetPreAuth(token)
+	if err != nil {
+		return "", err
+	}
+	if userID == "" {
+		return "", ErrPreAuthExpired
+	}
+	return userID, nil
+}
+
+// FailPreAuth count a wrong code given with token, revoked at the
+// MaxPreAuthAttempts one so codes cannot be guessed
+func (instance *useCase) FailPreAuth(token string) error {
+	fails, err := instance.repo.FailPreAuth(token)
+	if err != nil {
+		return err
+	}
+	if fails >= MaxPreAuthAttempts {
+		return instance.repo.DeletePreAuth(token)
+	}
+	return nil
+}
+
+// DeletePreAuth revoke token once the sign in is done, it works once
+func (instance *useCase) DeletePreAuth(token string) error {
+	return instance.repo.DeletePreAuth(token)
+}
+
+// InsertPreAuth store the pre-auth token of userID
+func (instance *repository) InsertPreAuth(token, userID string) error {
+	return instance.tokens.Set(instance.store.Key(preAuthPrefix+token), userID, PreAuthTTL)
+}
+
+// GetPreAuth user of the pre-auth token, empty when there is none
+func (instance *repository) GetPreAuth(token string) (string, error) {
+	userID, err := instance.tokens.Get(instance.store.Key(preAuthPrefix + token))
+	if err == keystore.ErrNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+// FailPreAuth count a wrong code of the pre-auth token, returns the count
+func (instance *repository) FailPreAuth(token string) (int64, error) {
+	key := instance.store.Key(preAuthPrefix + token + failsSuffix)
+	return instance.tokens.Incr(key, PreAuthTTL)
+}
+
+// DeletePreAuth remove the pre-auth token with its count of wrong codes
+func (instance *repository) DeletePreAuth(token string) error {
+	key := instance.store.Key(preAuthPrefix + token)
+	return instance.tokens.Del(key, key+failsSuffix)
+}
+
+// AskSecondFactor answer a sign in waiting for the code of the second factor
+// of its user with preAuthToken, a form for browsers and JSON for the other
+// clients. Both post it to /signin/2fa
+func AskSecondFactor(c *gin.Context, preAuthToken string) {
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEJSON {
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"pre_auth_token":      preAuthToken,
+			"expires_in":          int(PreAuthTTL.Seconds()),
+		})
+		return
+	}
+	c.HTML(http.StatusOK, "login_2fa.html", gin.H{"PreAuthToken": preAuthToken})
+}
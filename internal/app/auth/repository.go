@@ -2,6 +2,8 @@ package auth
 
 import (
 	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/keystore"
 	"analytics-api/internal/pkg/security"
 
 	"time"
@@ -15,34 +17,93 @@ type Repository interface {
 	GetAuth(accessUUID string) (string, error)
 	DeleteAccessToken(accessUUID string) error
 	DeleteRefreshToken(refresUUID string) error
+	UseRefreshToken(tokenDetails *security.TokenDetails) (bool, error)
+	RevokeFamily(familyID string) error
+	FamilyRevoked(familyID string) (bool, error)
+	RevokeUser(userID string) (int, error)
+	InsertOAuthState(state, name string) error
+	TakeOAuthState(state string) (string, error)
+	InsertPreAuth(token, userID string) error
+	GetPreAuth(token string) (string, error)
+	FailPreAuth(token string) (int64, error)
+	DeletePreAuth(token string) error
+	InsertPersonalToken(aToken personalToken) error
+	ListPersonalTokens(userID string) ([]personalToken, error)
+	CountPersonalTokens(userID string) (int64, error)
+	GetPersonalToken(tokenID, now string, aToken *personalToken) error
+	UpdatePersonalTokenUsed(tokenID, lastUsedAt string) error
+	DeletePersonalToken(userID, tokenID string) (int64, error)
+	DeletePersonalTokens(userID string) (int64, error)
+	ThrottlePasswordReset(userID string) (bool, error)
+	InsertPasswordReset(hash, userID string) error
+	TakePasswordReset(hash string) (string, error)
+	CountSignIn(ip string, window time.Time) (int64, error)
+	FailSignIn(subject string) (int64, error)
+	LockSignIn(subject string, until time.Time, ttl time.Duration) error
+	GetSignInLock(subject string) (time.Time, error)
+	ClearSignIn(subject string) error
 }
 
-type repository struct{}
+// Keys of refresh tokens and of their families, next to the access tokens
+// stored by their uuid
+const (
+	refreshPrefix = "refresh:"
+	familyPrefix  = "token_family:"
+	// userPrefix families of the sign ins of a user
+	userPrefix    = "token_user:"
+	usedSuffix    = ":used"
+	revokedSuffix = ":revoked"
+)
+
+type repository struct {
+	store  *db.Store
+	tokens keystore.Store
+}
 
 // NewRepository ...
-func NewRepository() Repository {
-	return &repository{}
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store:  store,
+		tokens: configs.KeyStore.Client,
+	}
 }
 
+// InsertAuth store the access and refresh token of tokenDetails, both are
+// listed in their family so reusing a refresh token revokes them all, and
+// the family in those of the user. The access token gives the account it
+// acts for, the organization of tokenDetails if any
 func (instance *repository) InsertAuth(userID string, tokenDetails *security.TokenDetails) error {
 	at := time.Unix(tokenDetails.AtExpires, 0)
-	// rt := time.Unix(tokenDetails.RtExpires, 0)
+	rt := time.Unix(tokenDetails.RtExpires, 0)
 	now := time.Now()
 
-	errAccess := configs.Redis.Client.Set(tokenDetails.AccessUUID, userID, at.Sub(now)).Err()
+	accountID := userID
+	if tokenDetails.OrgID != "" {
+		accountID = tokenDetails.OrgID
+	}
+	errAccess := instance.tokens.Set(instance.store.Key(tokenDetails.AccessUUID), accountID, at.Sub(now))
 	if errAccess != nil {
-		logrus.Error("Redis set at error ", errAccess)
+		logrus.Error("token store set at error ", errAccess)
 		return errAccess
 	}
-	// errRefresh := configs.Redis.Client.Set(tokenDetails.RefreshUUID, userID, rt.Sub(now)).Err()
-	// if errRefresh != nil {
-	// 	return errRefresh
-	// }
-	return nil
+	if tokenDetails.RefreshUUID == "" {
+		return nil
+	}
+	refreshKey := refreshPrefix + tokenDetails.RefreshUUID
+	errRefresh := instance.tokens.Set(instance.store.Key(refreshKey), userID, rt.Sub(now))
+	if errRefresh != nil {
+		return errRefresh
+	}
+	familyKey := instance.store.Key(familyPrefix + tokenDetails.FamilyID)
+	if err := instance.tokens.Add(familyKey, security.RefreshLifetime, tokenDetails.AccessUUID, refreshKey); err != nil {
+		return err
+	}
+	userKey := instance.store.Key(userPrefix + userID)
+	return instance.tokens.Add(userKey, security.RefreshLifetime, tokenDetails.FamilyID)
 }
 
 func (instance *repository) GetAuth(accessUUID string) (string, error) {
-	userID, err := configs.Redis.Client.Get(accessUUID).Result()
+	userID, err := instance.tokens.Get(instance.store.Key(accessUUID))
 	if err != nil {
 		return "", err
 	}
@@ -50,17 +111,75 @@ func (instance *repository) GetAuth(accessUUID string) (string, error) {
 }
 
 func (instance *repository) DeleteAccessToken(accessUUID string) error {
-	deleteAt, err := configs.Redis.Client.Del(accessUUID).Result()
-	if err != nil || deleteAt != 1 {
-		return err
-	}
-	return nil
+	return instance.tokens.Del(instance.store.Key(accessUUID))
 }
 
 func (instance *repository) DeleteRefreshToken(refresUUID string) error {
-	deleteAt, err := configs.Redis.Client.Del(refresUUID).Result()
-	if err != nil || deleteAt != 1 {
+	return instance.tokens.Del(instance.store.Key(refreshPrefix + refresUUID))
+}
+
+// UseRefreshToken mark the refresh token of tokenDetails used, false when it
+// was used already. ErrInvalidRefreshToken when it is not stored, expired or
+// revoked
+func (instance *repository) UseRefreshToken(tokenDetails *security.TokenDetails) (bool, error) {
+	refreshKey := instance.store.Key(refreshPrefix + tokenDetails.RefreshUUID)
+	exists, err := instance.tokens.Exists(refreshKey)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, security.ErrInvalidRefreshToken
+	}
+	// the token stays until it expires, so a replay is told from a token
+	// never issued
+	ttl := time.Until(time.Unix(tokenDetails.RtExpires, 0))
+	first, err := instance.tokens.SetNX(refreshKey+usedSuffix, "1", ttl)
+	if err != nil {
+		return false, err
+	}
+	return first, nil
+}
+
+// RevokeFamily delete every access and refresh token of familyID, and keep
+// it revoked as long as its refresh tokens could live
+func (instance *repository) RevokeFamily(familyID string) error {
+	familyKey := instance.store.Key(familyPrefix + familyID)
+	members, err := instance.tokens.Members(familyKey)
+	if err != nil {
 		return err
 	}
-	return nil
+	keys := []string{familyKey}
+	for _, member := range members {
+		keys = append(keys, instance.store.Key(member))
+	}
+	// revoked first, a refresh racing the deletion is turned away
+	if err := instance.tokens.Set(familyKey+revokedSuffix, "1", security.RefreshLifetime); err != nil {
+		return err
+	}
+	return instance.tokens.Del(keys...)
+}
+
+// FamilyRevoked ...
+func (instance *repository) FamilyRevoked(familyID string) (bool, error) {
+	return instance.tokens.Exists(instance.store.Key(familyPrefix + familyID + revokedSuffix))
+}
+
+// RevokeUser revoke every family of userID, return how many there were.
+// Families expired meanwhile are counted too
+func (instance *repository) RevokeUser(userID string) (int, error) {
+	userKey := instance.store.Key(userPrefix + userID)
+	familyIDs, err := instance.tokens.Members(userKey)
+	if err != nil {
+		return 0, err
+	}
+	for _, familyID := range familyIDs {
+		if err := instance.RevokeFamily(familyID); err != nil {
+			return 0, err
+		}
+	}
+	err = instance.tokens.Del(userKey)
+	if err != nil {
+		return 0, err
+	}
+	return len(familyIDs), nil
 }
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"analytics-api/internal/pkg/httperr"
+	"analytics-api/internal/pkg/keystore"
+	"analytics-api/internal/pkg/lockout"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SignInRate sign ins an address may try a minute, failed or not
+const SignInRate = 30
+
+// signInFailsTTL time the failed sign ins of an account or address are
+// counted for after the last one
+const signInFailsTTL = 24 * time.Hour
+
+// Lockouts of accounts and addresses failing to sign in. An address trying
+// many accounts gets more free failures than an account, but is locked
+// longer
+var (
+	accountLockout = lockout.Policy{Free: 5, Base: 30 * time.Second, Max: 15 * time.Minute}
+	addressLockout = lockout.Policy{Free: 20, Base: time.Minute, Max: time.Hour}
+)
+
+// CodeSignInLocked ...
+const CodeSignInLocked = "sign_in_locked"
+
+// ErrSignInLocked ...
+var ErrSignInLocked = errors.New("too many sign ins, try again later")
+
+// Keys of sign in throttling, by account or address subject
+const (
+	signInRatePrefix  = "sign_in_rate:"
+	signInFailsPrefix = "sign_in_fails:"
+	signInLockPrefix  = "sign_in_lock:"
+)
+
+// CheckSignIn time a sign in of email from ip has to wait, 0 when it may go
+// on. Counts it against the rate of ip. While locked out even the right
+// password waits, or the lockout would tell when it is found
+func (instance *useCase) CheckSignIn(ip, email string) (time.Duration, error) {
+	now := time.Now()
+	var wait time.Duration
+	for _, subject := range []string{accountSubject(email), addressSubject(ip)} {
+		until, err := instance.repo.GetSignInLock(subject)
+		if err != nil {
+			return 0, err
+		}
+		if left := until.Sub(now); left > wait {
+			wait = left
+		}
+	}
+	if wait > 0 {
+		return wait, nil
+	}
+
+	window := now.Truncate(time.Minute)
+	count, err := instance.repo.CountSignIn(ip, window)
+	if err != nil {
+		return 0, err
+	}
+	if count > SignInRate {
+		return window.Add(time.Minute).Sub(now), nil
+	}
+	return 0, nil
+}
+
+// FailSignIn count a failed sign in of email from ip, both are locked out
+// once past their free failures, longer with each next failure
+func (instance *useCase) FailSignIn(ip, email string) error {
+	now := time.Now()
+	subjects := map[string]lockout.Policy{
+		accountSubject(email): accountLockout,
+		addressSubject(ip):    addressLockout,
+	}
+	for subject, aPolicy := range subjects {
+		fails, err := instance.repo.FailSignIn(subject)
+		if err != nil {
+			return err
+		}
+		wait := aPolicy.Duration(fails)
+		if wait == 0 {
+			continue
+		}
+		if err := instance.repo.LockSignIn(subject, now.Add(wait), wait); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClearSignIn forget the failed sign ins of the account of email once it
+// signs in. Those of its address are kept, signing in to an account of its
+// own would clear them otherwise
+func (instance *useCase) ClearSignIn(email string) error {
+	return instance.repo.ClearSignIn(accountSubject(email))
+}
+
+// accountSubject emails are counted whether an account has them or not, so
+// the lockout does not tell which are signed up
+func accountSubject(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func addressSubject(ip string) string {
+	return "ip:" + ip
+}
+
+// CountSignIn count a sign in of ip in the minute of window, returns the count
+func (instance *repository) CountSignIn(ip string, window time.Time) (int64, error) {
+	key := instance.store.Key(signInRatePrefix + ip + ":" + strconv.FormatInt(window.Unix(), 10))
+	return instance.tokens.Incr(key, time.Minute)
+}
+
+// FailSignIn count a failed sign in of subject, returns the count
+func (instance *repository) FailSignIn(subject string) (int64, error) {
+	return instance.tokens.Incr(instance.store.Key(signInFailsPrefix+subject), signInFailsTTL)
+}
+
+// LockSignIn lock subject out until, for ttl
+func (instance *repository) LockSignIn(subject string, until time.Time, ttl time.Duration) error {
+	return instance.tokens.Set(instance.store.Key(signInLockPrefix+subject), strconv.FormatInt(until.UnixMilli(), 10), ttl)
+}
+
+// GetSignInLock time subject is locked out until, zero when it is not
+func (instance *repository) GetSignInLock(subject string) (time.Time, error) {
+	value, err := instance.tokens.Get(instance.store.Key(signInLockPrefix + subject))
+	if err == keystore.ErrNotFound {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	until, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(until), nil
+}
+
+// ClearSignIn remove the failed sign ins and the lockout of subject
+func (instance *repository) ClearSignIn(subject string) error {
+	return instance.tokens.Del(instance.store.Key(signInFailsPrefix+subject), instance.store.Key(signInLockPrefix+subject))
+}
+
+// AbortSignInLocked answer a sign in that has to wait, with the seconds in
+// Retry-After and the details for clients reading the body
+func AbortSignInLocked(c *gin.Context, wait time.Duration) {
+	seconds := lockout.RetryAfter(wait)
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	httperr.AbortWithDetails(c, http.StatusTooManyRequests, CodeSignInLocked, ErrSignInLocked.Error(), gin.H{"retry_after": seconds})
+}
@@ -1,13 +1,43 @@
 package auth
 
-import "analytics-api/internal/pkg/security"
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/pkg/oauth"
+	"analytics-api/internal/pkg/security"
+)
+
+// ErrRefreshTokenReused ...
+var ErrRefreshTokenReused = errors.New("refresh token used twice, every session of its sign in is revoked")
 
 // UseCase ...
 type UseCase interface {
 	InsertAuth(userID string, tokenDetails *security.TokenDetails) error
 	GetAuth(accessUUID string) (string, error)
+	GetUser(tokenAuth *security.TokenDetails) (string, error)
 	DeleteAccessToken(accessUUID string) error
 	DeleteRefreshToken(refresUUID string) error
+	Refresh(refreshToken, tenantID string) (*security.TokenDetails, error)
+	RevokeFamily(familyID string) error
+	LogoutAll(userID string) (int, error)
+	OAuthURL(name string) (string, string, error)
+	OAuthIdentify(name, state, code string) (*oauth.Identity, error)
+	InsertPreAuth(userID string) (string, error)
+	GetPreAuth(token string) (string, error)
+	FailPreAuth(token string) error
+	DeletePreAuth(token string) error
+	CreatePersonalToken(tokenAuth *security.TokenDetails, userID, name string, scopes []string, days int) (*personalToken, error)
+	ListPersonalTokens(userID string) ([]personalToken, error)
+	RevokePersonalToken(userID, tokenID string) error
+	RevokePersonalTokens(userID string) (int64, error)
+	InsertPasswordReset(userID string) (string, error)
+	TakePasswordReset(token string) (string, error)
+	CheckSignIn(ip, email string) (time.Duration, error)
+	FailSignIn(ip, email string) error
+	ClearSignIn(email string) error
 }
 
 type useCase struct {
@@ -15,9 +45,9 @@ type useCase struct {
 }
 
 // NewUseCase ...
-func NewUseCase() UseCase {
+func NewUseCase(store *db.Store) UseCase {
 	return &useCase{
-		repo: NewRepository(),
+		repo: NewRepository(store),
 	}
 }
 
@@ -29,7 +59,12 @@ func (instance *useCase) InsertAuth(userID string, tokenDetails *security.TokenD
 	return nil
 }
 
+// GetAuth account the access token of accessUUID acts for, personal tokens
+// included
 func (instance *useCase) GetAuth(accessUUID string) (string, error) {
+	if strings.HasPrefix(accessUUID, security.PersonalPrefix) {
+		return instance.personalAccount(accessUUID)
+	}
 	userID, err := instance.repo.GetAuth(accessUUID)
 	if err != nil {
 		return "", err
@@ -37,6 +72,20 @@ func (instance *useCase) GetAuth(accessUUID string) (string, error) {
 	return userID, nil
 }
 
+// GetUser user signed in with tokenAuth, whichever account it acts for. The
+// routes of the user itself, like its profile, use it instead of GetAuth
+func (instance *useCase) GetUser(tokenAuth *security.TokenDetails) (string, error) {
+	accountID, err := instance.repo.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		return "", err
+	}
+	// tokens issued before organizations are stored under their user
+	if tokenAuth.OrgID == "" {
+		return accountID, nil
+	}
+	return tokenAuth.UserID, nil
+}
+
 func (instance *useCase) DeleteAccessToken(accessUUID string) error {
 	err := instance.repo.DeleteAccessToken(accessUUID)
 	if err != nil {
@@ -52,3 +101,71 @@ func (instance *useCase) DeleteRefreshToken(refresUUID string) error {
 	}
 	return nil
 }
+
+// Refresh new access and refresh token for refreshToken of tenantID, which is
+// used up. A refresh token used a second time is taken for stolen: its whole
+// family is revoked and ErrRefreshTokenReused returned
+func (instance *useCase) Refresh(refreshToken, tenantID string) (*security.TokenDetails, error) {
+	tokenDetails, err := security.VerifyRefreshToken(refreshToken)
+	if err != nil || tokenDetails.TenantID != tenantID {
+		return nil, security.ErrInvalidRefreshToken
+	}
+	revoked, err := instance.repo.FamilyRevoked(tokenDetails.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, security.ErrInvalidRefreshToken
+	}
+
+	first, err := instance.repo.UseRefreshToken(tokenDetails)
+	if err != nil {
+		return nil, err
+	}
+	if !first {
+		if err := instance.repo.RevokeFamily(tokenDetails.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	rotated, err := security.RotateToken(tokenDetails.UserID, tenantID, tokenDetails.OrgID, tokenDetails.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	err = instance.repo.InsertAuth(tokenDetails.UserID, rotated)
+	if err != nil {
+		return nil, err
+	}
+	// a replay revoking the family meanwhile must not leave these tokens
+	revoked, err = instance.repo.FamilyRevoked(tokenDetails.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		if err := instance.repo.RevokeFamily(tokenDetails.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, security.ErrInvalidRefreshToken
+	}
+	return rotated, nil
+}
+
+// RevokeFamily sign out every session refreshed from the sign in of familyID
+func (instance *useCase) RevokeFamily(familyID string) error {
+	err := instance.repo.RevokeFamily(familyID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// LogoutAll sign userID out of every device, revoking the family of each of
+// its sign ins, return how many there were
+func (instance *useCase) LogoutAll(userID string) (int, error) {
+	count, err := instance.repo.RevokeUser(userID)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
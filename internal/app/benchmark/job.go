@@ -0,0 +1,37 @@
+package benchmark
+
+import (
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runAt time of day, in UTC, the benchmarks are computed
+const runAt = 2 * time.Hour
+
+// RunBenchmarks compute the benchmarks of store every night, and once on
+// start, until the process exits. Nights with benchmarks off are skipped so
+// a reload may turn them on
+func RunBenchmarks(store *db.Store) {
+	useCase := NewUseCase(store)
+	for {
+		now := time.Now().UTC()
+		if configs.Current().Benchmarks.Enabled {
+			count, err := useCase.ComputeBenchmarks()
+			if err != nil {
+				logrus.Error("compute benchmarks error ", err)
+			} else {
+				logrus.Info("computed benchmarks of ", count, " categories")
+			}
+		}
+
+		next := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).Add(runAt)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		time.Sleep(time.Until(next))
+	}
+}
@@ -0,0 +1,31 @@
+package benchmark
+
+import "time"
+
+// benchmark medians of the websites of a category, nothing tells which
+// websites they were computed from
+type benchmark struct {
+	Category string `json:"category" bson:"category"`
+	Websites int    `json:"websites" bson:"websites"`
+	// BounceRate share of the sessions viewing at most one page, in percent
+	BounceRate float64 `json:"bounce_rate" bson:"bounce_rate"`
+	// VisitDuration average duration of the sessions, in seconds
+	VisitDuration float64   `json:"visit_duration" bson:"visit_duration"`
+	From          time.Time `json:"from" bson:"from"`
+	To            time.Time `json:"to" bson:"to"`
+	ComputedAt    time.Time `json:"computed_at" bson:"computed_at"`
+}
+
+// Comparison bounce rate and visit duration of a website next to the median
+// of its category, over the days the benchmark was computed on
+type Comparison struct {
+	Category string `json:"category"`
+	// Websites of the category the medians are computed from
+	Websites              int     `json:"websites"`
+	BounceRate            float64 `json:"bounce_rate"`
+	CategoryBounceRate    float64 `json:"category_bounce_rate"`
+	VisitDuration         float64 `json:"visit_duration"`
+	CategoryVisitDuration float64 `json:"category_visit_duration"`
+	From                  string  `json:"from"`
+	To                    string  `json:"to"`
+}
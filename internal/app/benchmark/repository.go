@@ -0,0 +1,62 @@
+package benchmark
+
+import (
+	"context"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Repository ...
+type Repository interface {
+	UpsertBenchmark(aBenchmark benchmark) error
+	DeleteOtherBenchmark(categories []string) error
+	GetBenchmark(category string, aBenchmark *benchmark) error
+}
+
+type repository struct {
+	store *db.Store
+}
+
+// NewRepository ...
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store: store,
+	}
+}
+
+// UpsertBenchmark replace the benchmark of its category
+func (instance *repository) UpsertBenchmark(aBenchmark benchmark) error {
+	benchmarkCollection := instance.store.Mongo.Collection(configs.MongoDB.BenchmarkCollection)
+	_, err := benchmarkCollection.ReplaceOne(context.TODO(), bson.M{"category": aBenchmark.Category}, aBenchmark, options.Replace().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteOtherBenchmark remove the benchmarks of the categories not in
+// categories, those that no longer have enough websites
+func (instance *repository) DeleteOtherBenchmark(categories []string) error {
+	benchmarkCollection := instance.store.Mongo.Collection(configs.MongoDB.BenchmarkCollection)
+	deleteResult, err := benchmarkCollection.DeleteMany(context.TODO(), bson.M{"category": bson.M{"$nin": categories}})
+	if err != nil {
+		return err
+	}
+	logrus.Printf("deleted %v documents in the benchmark collection\n", deleteResult.DeletedCount)
+	return nil
+}
+
+// GetBenchmark get benchmark of category
+func (instance *repository) GetBenchmark(category string, aBenchmark *benchmark) error {
+	benchmarkCollection := instance.store.Mongo.Collection(configs.MongoDB.BenchmarkCollection)
+	err := benchmarkCollection.FindOne(context.TODO(), bson.M{"category": category}).Decode(aBenchmark)
+	if err != nil {
+		return err
+	}
+	return nil
+}
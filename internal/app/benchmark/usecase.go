@@ -0,0 +1,187 @@
+package benchmark
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/app/session"
+	"analytics-api/internal/app/website"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrDisabled ...
+var ErrDisabled = errors.New("benchmarks are off, set BENCHMARKS=true")
+
+// Window days before today the benchmarks are computed on
+const Window = 28
+
+// MinSessions sessions a website needs in the window to count in the
+// benchmark of its category, so a website barely tracked does not pull the
+// median
+const MinSessions = 30
+
+const dateLayout = "2006-01-02"
+
+// UseCase ...
+type UseCase interface {
+	ComputeBenchmarks() (int, error)
+	Compare(userID, websiteID, category string) (*Comparison, error)
+}
+
+type useCase struct {
+	repo           Repository
+	sessionUseCase session.UseCase
+	websiteUseCase website.UseCase
+}
+
+// NewUseCase ...
+func NewUseCase(store *db.Store) UseCase {
+	return &useCase{
+		repo:           NewRepository(store),
+		sessionUseCase: session.NewUseCase(store),
+		websiteUseCase: website.NewUseCase(store),
+	}
+}
+
+// CategoryKey category websites declare, compared without case and spaces
+// around it
+func CategoryKey(category string) string {
+	return strings.ToLower(strings.TrimSpace(category))
+}
+
+// median of values, sorted in place
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	middle := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[middle-1] + values[middle]) / 2
+	}
+	return values[middle]
+}
+
+// rates bounce rate in percent and average duration in seconds of visits
+func rates(aVisits *session.Visits) (float64, float64) {
+	bounceRate := float64(aVisits.Bounces) * 100 / float64(aVisits.Sessions)
+	visitDuration := float64(aVisits.DurationSeconds) / float64(aVisits.Sessions)
+	return math.Round(bounceRate*10) / 10, math.Round(visitDuration*10) / 10
+}
+
+// benchmarks medians of the rates of the websites of each category, by
+// category, for the categories with minWebsites or more
+func benchmarks(bounceRates, visitDurations map[string][]float64, minWebsites int) []benchmark {
+	list := []benchmark{}
+	for category, values := range bounceRates {
+		if len(values) < minWebsites {
+			continue
+		}
+		list = append(list, benchmark{
+			Category:      category,
+			Websites:      len(values),
+			BounceRate:    math.Round(median(values)*10) / 10,
+			VisitDuration: math.Round(median(visitDurations[category])*10) / 10,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Category < list[j].Category
+	})
+	return list
+}
+
+// ComputeBenchmarks compute the median bounce rate and visit duration of the
+// websites of each category over the Window days before today, in UTC.
+// Categories with fewer websites than the tunable minimum get no benchmark
+// and lose the one they had. Returns the count of benchmarks stored
+func (instance *useCase) ComputeBenchmarks() (int, error) {
+	aTunables := configs.Current()
+	if !aTunables.Benchmarks.Enabled {
+		return 0, ErrDisabled
+	}
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(0, 0, -Window)
+
+	websites, err := instance.websiteUseCase.ListWebsite()
+	if err != nil {
+		return 0, err
+	}
+	bounceRates := map[string][]float64{}
+	visitDurations := map[string][]float64{}
+	for _, aWebsite := range *websites {
+		category := CategoryKey(aWebsite.Category)
+		if category == "" || aWebsite.HostName == website.InternalHostName {
+			continue
+		}
+		aVisits, err := instance.sessionUseCase.Visits(aWebsite.UserID, aWebsite.ID, session.BreakdownFilter{From: from, To: to})
+		if err != nil {
+			return 0, err
+		}
+		if aVisits.Sessions < MinSessions {
+			continue
+		}
+		bounceRate, visitDuration := rates(aVisits)
+		bounceRates[category] = append(bounceRates[category], bounceRate)
+		visitDurations[category] = append(visitDurations[category], visitDuration)
+	}
+
+	categories := []string{}
+	for _, aBenchmark := range benchmarks(bounceRates, visitDurations, aTunables.Benchmarks.MinWebsites) {
+		aBenchmark.From = from
+		aBenchmark.To = to
+		aBenchmark.ComputedAt = now
+		if err := instance.repo.UpsertBenchmark(aBenchmark); err != nil {
+			return 0, err
+		}
+		categories = append(categories, aBenchmark.Category)
+	}
+	err = instance.repo.DeleteOtherBenchmark(categories)
+	if err != nil {
+		return 0, err
+	}
+	return len(categories), nil
+}
+
+// Compare bounce rate and visit duration of website next to the benchmark
+// of category, over the same days. nil when benchmarks are off, the category
+// has no benchmark or the website had no session in these days
+func (instance *useCase) Compare(userID, websiteID, category string) (*Comparison, error) {
+	if !configs.Current().Benchmarks.Enabled {
+		return nil, nil
+	}
+	category = CategoryKey(category)
+	if category == "" {
+		return nil, nil
+	}
+	var aBenchmark benchmark
+	err := instance.repo.GetBenchmark(category, &aBenchmark)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	aVisits, err := instance.sessionUseCase.Visits(userID, websiteID, session.BreakdownFilter{From: aBenchmark.From, To: aBenchmark.To})
+	if err != nil {
+		return nil, err
+	}
+	if aVisits.Sessions == 0 {
+		return nil, nil
+	}
+	bounceRate, visitDuration := rates(aVisits)
+	return &Comparison{
+		Category:              category,
+		Websites:              aBenchmark.Websites,
+		BounceRate:            bounceRate,
+		CategoryBounceRate:    aBenchmark.BounceRate,
+		VisitDuration:         visitDuration,
+		CategoryVisitDuration: aBenchmark.VisitDuration,
+		From:                  aBenchmark.From.Format(dateLayout),
+		To:                    aBenchmark.To.AddDate(0, 0, -1).Format(dateLayout),
+	}, nil
+}
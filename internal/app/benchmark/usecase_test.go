@@ -0,0 +1,99 @@
+package benchmark
+
+import (
+	"reflect"
+	"testing"
+
+	"analytics-api/internal/app/session"
+)
+
+func TestCategoryKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		category string
+		want     string
+	}{
+		{name: "should compare categories without case", category: "E-Commerce", want: "e-commerce"},
+		{name: "should trim the spaces around", category: "  news ", want: "news"},
+		{name: "should keep no category empty", category: " ", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CategoryKey(tt.category); got != tt.want {
+				t.Errorf("CategoryKey(%q) = %q, want %q", tt.category, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{name: "should take the middle of an odd count", values: []float64{9, 1, 5}, want: 5},
+		{name: "should average the middle two of an even count", values: []float64{40, 10, 30, 20}, want: 25},
+		{name: "should take the only value", values: []float64{7}, want: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := median(tt.values); got != tt.want {
+				t.Errorf("median() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRates(t *testing.T) {
+	tests := []struct {
+		name              string
+		visits            session.Visits
+		wantBounceRate    float64
+		wantVisitDuration float64
+	}{
+		{name: "should rate bounces in percent", visits: session.Visits{Sessions: 40, Bounces: 10, DurationSeconds: 4000}, wantBounceRate: 25, wantVisitDuration: 100},
+		{name: "should round to a tenth", visits: session.Visits{Sessions: 3, Bounces: 1, DurationSeconds: 100}, wantBounceRate: 33.3, wantVisitDuration: 33.3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bounceRate, visitDuration := rates(&tt.visits)
+			if bounceRate != tt.wantBounceRate || visitDuration != tt.wantVisitDuration {
+				t.Errorf("rates() = %v, %v, want %v, %v", bounceRate, visitDuration, tt.wantBounceRate, tt.wantVisitDuration)
+			}
+		})
+	}
+}
+
+func TestBenchmarks(t *testing.T) {
+	tests := []struct {
+		name           string
+		bounceRates    map[string][]float64
+		visitDurations map[string][]float64
+		want           []benchmark
+	}{
+		{name: "should compute no benchmark without websites", want: []benchmark{}},
+		{
+			name:           "should leave out the categories with too few websites",
+			bounceRates:    map[string][]float64{"news": {10, 20}},
+			visitDurations: map[string][]float64{"news": {30, 60}},
+			want:           []benchmark{},
+		},
+		{
+			name:           "should take the medians of each category",
+			bounceRates:    map[string][]float64{"shop": {50, 10, 30}, "news": {20, 40, 60, 80}},
+			visitDurations: map[string][]float64{"shop": {120, 60, 90}, "news": {15, 25, 35, 45}},
+			want: []benchmark{
+				{Category: "news", Websites: 4, BounceRate: 50, VisitDuration: 30},
+				{Category: "shop", Websites: 3, BounceRate: 30, VisitDuration: 90},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := benchmarks(tt.bounceRates, tt.visitDurations, 3); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("benchmarks() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
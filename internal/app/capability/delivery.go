@@ -0,0 +1,29 @@
+package capability
+
+import (
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/app/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPDelivery what the current token can use, so clients adapt to the server
+type HTTPDelivery interface {
+	// This function is required for every HTTPDelivery
+	InitRoutes(r *gin.RouterGroup)
+
+	// Other functions to handle HTTP requests
+	GetCapabilities(c *gin.Context)
+}
+
+// NewHTTPDelivery routes of the engine are read on each request, so the
+// routes registered after capabilities are listed too
+func NewHTTPDelivery(store *db.Store, routes func() gin.RoutesInfo) HTTPDelivery {
+	return &httpDelivery{
+		capabilityUseCase: NewUseCase(store),
+		authUsecase:       auth.NewUseCase(store),
+		userUseCase:       user.NewUseCase(store),
+		routes:            routes,
+	}
+}
@@ -0,0 +1,56 @@
+package capability
+
+import (
+	"net/http"
+
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/app/user"
+	"analytics-api/internal/pkg/middleware"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type httpDelivery struct {
+	capabilityUseCase UseCase
+	authUsecase       auth.UseCase
+	userUseCase       user.UseCase
+	routes            func() gin.RoutesInfo
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	r.GET("/capabilities", middleware.JWTMiddleware(), instance.GetCapabilities)
+}
+
+// GetCapabilities features, limits and endpoints available to the token
+func (instance *httpDelivery) GetCapabilities(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	role, err := instance.userUseCase.AccountRole(tokenAuth)
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get user failed"})
+		return
+	}
+
+	plan, err := instance.userUseCase.GetPlan(userID)
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get user failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, instance.capabilityUseCase.GetCapabilities(instance.routes(), role, plan))
+}
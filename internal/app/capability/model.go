@@ -0,0 +1,58 @@
+package capability
+
+// capabilities what the current token can use on this server
+type capabilities struct {
+	// Role user.RoleOwner, user.RoleAdmin or user.RoleViewer, every account owns its websites
+	// and its Plan limits how many
+	Role     string   `json:"role"`
+	Plan     string   `json:"plan"`
+	Features features `json:"features"`
+	Limits   limits   `json:"limits"`
+	// SignedWrites writes must be signed with an api key
+	SignedWrites bool `json:"signed_writes"`
+	// ReadOnly maintenance is on, writes other than collecting events and
+	// signing in are rejected
+	ReadOnly  bool       `json:"read_only"`
+	Endpoints []endpoint `json:"endpoints"`
+}
+
+// features of the server, off when not configured
+type features struct {
+	// Tracker features each website toggles
+	Tracker             []string `json:"tracker"`
+	RecordingEncryption bool     `json:"recording_encryption"`
+	ClickHouse          bool     `json:"clickhouse"`
+	Archive             bool     `json:"archive"`
+	// ReplayWatermark recordings are played with the identity of the viewer
+	ReplayWatermark bool `json:"replay_watermark"`
+	// VisitorStream the live events of identified visitors may be watched
+	VisitorStream bool `json:"visitor_stream"`
+	// CRM providers with an oauth app registered
+	CRM []string `json:"crm"`
+	// OAuth identity providers users sign in with
+	OAuth []string `json:"oauth"`
+	// Push services with credentials
+	Push []string `json:"push"`
+	// PasswordReset users who forgot their password get a link by email
+	PasswordReset bool `json:"password_reset"`
+	// EmailChange users change their email through a link sent to the new one
+	EmailChange bool `json:"email_change"`
+}
+
+// limits enforced by the server
+type limits struct {
+	RetentionDays    int   `json:"retention_days"`
+	Destinations     int   `json:"destinations"`
+	ContentGroups    int   `json:"content_groups"`
+	VisitorIDLength  int   `json:"visitor_id_length"`
+	CityMinSessions  int64 `json:"city_min_sessions"`
+	EventSkewSeconds int   `json:"event_skew_seconds"`
+	// Websites the plan allows, 0 for no limit
+	Websites int64 `json:"websites"`
+}
+
+// endpoint route of the API
+type endpoint struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
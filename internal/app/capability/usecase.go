@@ -0,0 +1,134 @@
+package capability
+
+import (
+	"sort"
+	"strings"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/app/crm"
+	"analytics-api/internal/app/firehose"
+	"analytics-api/internal/app/user"
+	"analytics-api/internal/app/visitor"
+	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/eventtime"
+	"analytics-api/internal/pkg/mail"
+	"analytics-api/internal/pkg/maintenance"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UseCase ...
+type UseCase interface {
+	GetCapabilities(routes gin.RoutesInfo, role, plan string) *capabilities
+}
+
+// useCase capabilities come from the configuration of the server and the
+// store, plans only change the limits
+type useCase struct {
+	store *db.Store
+}
+
+// NewUseCase ...
+func NewUseCase(store *db.Store) UseCase {
+	return &useCase{
+		store: store,
+	}
+}
+
+// GetCapabilities features, limits of plan and the endpoints among routes a
+// token of the store with role can call
+func (instance *useCase) GetCapabilities(routes gin.RoutesInfo, role, plan string) *capabilities {
+	aFeatures := features{
+		Tracker:             website.FeatureNames,
+		RecordingEncryption: instance.store.Keys != nil,
+		ClickHouse:          configs.UsesClickHouse(),
+		Archive:             configs.ArchiveEnabled(),
+		ReplayWatermark:     instance.store.ReplayWatermark.Load(),
+		VisitorStream:       instance.store.VisitorStream.Load(),
+		CRM:                 []string{},
+		OAuth:               []string{},
+		Push:                []string{},
+		PasswordReset:       mail.Enabled(),
+		EmailChange:         mail.Enabled(),
+	}
+	if configs.CRM.HubSpotClientID != "" {
+		aFeatures.CRM = append(aFeatures.CRM, crm.ProviderHubSpot)
+	}
+	if configs.CRM.SalesforceClientID != "" {
+		aFeatures.CRM = append(aFeatures.CRM, crm.ProviderSalesforce)
+	}
+	if configs.OAuth.GoogleClientID != "" {
+		aFeatures.OAuth = append(aFeatures.OAuth, auth.ProviderGoogle)
+	}
+	if configs.OAuth.GitHubClientID != "" {
+		aFeatures.OAuth = append(aFeatures.OAuth, auth.ProviderGitHub)
+	}
+	if configs.Push.FCMCredentialsFile != "" {
+		aFeatures.Push = append(aFeatures.Push, "fcm")
+	}
+	if configs.Push.APNsKeyFile != "" {
+		aFeatures.Push = append(aFeatures.Push, "apns")
+	}
+
+	aCapabilities := &capabilities{
+		Role:     role,
+		Plan:     plan,
+		Features: aFeatures,
+		Limits: limits{
+			RetentionDays:    db.RetentionDays,
+			Destinations:     firehose.MaxDestinations,
+			ContentGroups:    website.MaxContentGroups,
+			VisitorIDLength:  visitor.MaxVisitorID,
+			CityMinSessions:  configs.Current().CityMinSessions,
+			EventSkewSeconds: int(eventtime.MaxSkew.Seconds()),
+			Websites:         user.WebsiteLimit(plan),
+		},
+		SignedWrites: instance.store.SignedWrites.Load(),
+		ReadOnly:     maintenance.Enabled(),
+		Endpoints:    []endpoint{},
+	}
+	for _, route := range routes {
+		if !available(route, aFeatures) {
+			continue
+		}
+		if permission := user.RoutePermission(route.Method, route.Path); permission != "" && !user.Can(role, permission) {
+			continue
+		}
+		aCapabilities.Endpoints = append(aCapabilities.Endpoints, endpoint{Method: route.Method, Path: route.Path})
+	}
+	sort.Slice(aCapabilities.Endpoints, func(i, j int) bool {
+		if aCapabilities.Endpoints[i].Path != aCapabilities.Endpoints[j].Path {
+			return aCapabilities.Endpoints[i].Path < aCapabilities.Endpoints[j].Path
+		}
+		return aCapabilities.Endpoints[i].Method < aCapabilities.Endpoints[j].Method
+	})
+	return aCapabilities
+}
+
+// available tell if a token can use route, static files and the admin API
+// are left out, and the endpoints of features the server lacks
+func available(route gin.RouteInfo, aFeatures features) bool {
+	switch {
+	case route.Method == "HEAD", strings.Contains(route.Path, "*"), route.Path == "/":
+		return false
+	case strings.HasPrefix(route.Path, "/admin"):
+		return false
+	case strings.HasPrefix(route.Path, "/archive"):
+		return aFeatures.Archive
+	case strings.HasPrefix(route.Path, "/crm"):
+		return len(aFeatures.CRM) > 0
+	case strings.HasPrefix(route.Path, "/auth/oauth"):
+		return len(aFeatures.OAuth) > 0
+	case strings.HasPrefix(route.Path, "/auth/password"):
+		return aFeatures.PasswordReset
+	case strings.HasPrefix(route.Path, "/profile/email"):
+		return aFeatures.EmailChange
+	case route.Path == "/mobile/devices/test":
+		return len(aFeatures.Push) > 0
+	case route.Path == "/visitor/:website_id/:visitor_id/live":
+		return aFeatures.VisitorStream
+	}
+	return true
+}
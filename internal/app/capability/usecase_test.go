@@ -0,0 +1,67 @@
+package capability
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/app/user"
+	"analytics-api/internal/pkg/keystore"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetCapabilities(t *testing.T) {
+	// maintenance mode is read from the key store, an empty one reads it off
+	client := configs.KeyStore.Client
+	configs.KeyStore.Client = keystore.NewMemory()
+	defer func() { configs.KeyStore.Client = client }()
+
+	routes := gin.RoutesInfo{
+		{Method: "GET", Path: "/stats/:website_id/pages"},
+		{Method: "GET", Path: "/session/:session_id"},
+		{Method: "GET", Path: "/session/event/:session_id"},
+		{Method: "GET", Path: "/session/event/:session_id/page"},
+		{Method: "POST", Path: "/website/update"},
+	}
+	tests := []struct {
+		name string
+		role string
+		want []endpoint
+	}{
+		{
+			name: "should list the replay routes to owners",
+			role: user.RoleOwner,
+			want: []endpoint{
+				{Method: "GET", Path: "/session/:session_id"},
+				{Method: "GET", Path: "/session/event/:session_id"},
+				{Method: "GET", Path: "/session/event/:session_id/page"},
+				{Method: "GET", Path: "/stats/:website_id/pages"},
+				{Method: "POST", Path: "/website/update"},
+			},
+		},
+		{
+			name: "should leave the replay and write routes out for viewers",
+			role: user.RoleViewer,
+			want: []endpoint{{Method: "GET", Path: "/stats/:website_id/pages"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aUseCase := &useCase{store: &db.Store{SignedWrites: &atomic.Bool{}, ReplayWatermark: &atomic.Bool{}, VisitorStream: &atomic.Bool{}}}
+			got := aUseCase.GetCapabilities(routes, tt.role, "")
+			if got.Role != tt.role {
+				t.Errorf("Role = %q, want %q", got.Role, tt.role)
+			}
+			if len(got.Endpoints) != len(tt.want) {
+				t.Fatalf("Endpoints = %v, want %v", got.Endpoints, tt.want)
+			}
+			for i := range tt.want {
+				if got.Endpoints[i] != tt.want[i] {
+					t.Errorf("Endpoints[%d] = %v, want %v", i, got.Endpoints[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
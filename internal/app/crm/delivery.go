@@ -0,0 +1,33 @@
+package crm
+
+import (
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPDelivery CRM connections of users and the CRM mappings of websites
+type HTTPDelivery interface {
+	// This function is required for every HTTPDelivery
+	InitRoutes(r *gin.RouterGroup)
+
+	// Other functions to handle HTTP requests
+	Connect(c *gin.Context)
+	Callback(c *gin.Context)
+	GetAllConnection(c *gin.Context)
+	DeleteConnection(c *gin.Context)
+
+	GetMapping(c *gin.Context)
+	UpdateMapping(c *gin.Context)
+	DeleteMapping(c *gin.Context)
+	GetQueue(c *gin.Context)
+}
+
+// NewHTTPDelivery ...
+func NewHTTPDelivery(store *db.Store) HTTPDelivery {
+	return &httpDelivery{
+		crmUseCase:  NewUseCase(store),
+		authUsecase: auth.NewUseCase(store),
+	}
+}
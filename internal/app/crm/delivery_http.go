@@ -0,0 +1,258 @@
+package crm
+
+import (
+	"net/http"
+
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/pkg/cursor"
+	"analytics-api/internal/pkg/middleware"
+	req "analytics-api/internal/pkg/request"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type httpDelivery struct {
+	crmUseCase  UseCase
+	authUsecase auth.UseCase
+}
+
+// RequestMapping ...
+type RequestMapping struct {
+	Provider   string            `json:"provider"`
+	IDProperty string            `json:"id_property"`
+	Fields     map[string]string `json:"fields"`
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	crmRoutes := r.Group("crm")
+	{
+		crmRoutes.GET("/connect/:provider", middleware.JWTMiddleware(), instance.Connect)
+		crmRoutes.GET("/callback/:provider", middleware.JWTMiddleware(), instance.Callback)
+		crmRoutes.GET("/connections", middleware.JWTMiddleware(), instance.GetAllConnection)
+		crmRoutes.DELETE("/connections/:provider", middleware.JWTMiddleware(), instance.DeleteConnection)
+
+		crmRoutes.GET("/mapping/:website_id", middleware.JWTMiddleware(), instance.GetMapping)
+		crmRoutes.POST("/mapping/:website_id", middleware.JWTMiddleware(), instance.UpdateMapping)
+		crmRoutes.DELETE("/mapping/:website_id", middleware.JWTMiddleware(), instance.DeleteMapping)
+		crmRoutes.GET("/queue/:website_id", middleware.JWTMiddleware(), instance.GetQueue)
+	}
+}
+
+// Connect redirect the user to the CRM to approve the connection
+func (instance *httpDelivery) Connect(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	url, err := instance.crmUseCase.AuthorizeURL(userID, c.Param("provider"))
+	switch err {
+	case nil:
+		c.Redirect(http.StatusFound, url)
+	case ErrUnknownProvider:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "connect crm failed"})
+	}
+}
+
+// Callback finish the connection when the CRM redirects the user back
+func (instance *httpDelivery) Callback(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	if denied := c.Query("error"); denied != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the CRM refused the connection: " + denied})
+		return
+	}
+
+	provider := c.Param("provider")
+	err = instance.crmUseCase.Connect(userID, provider, c.Query("state"), c.Query("code"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{"provider": provider, "connected": true})
+	case ErrUnknownProvider, ErrInvalidState:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "exchange crm code failed"})
+	}
+}
+
+func (instance *httpDelivery) GetAllConnection(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	connections, err := instance.crmUseCase.GetAllConnection(userID)
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get crm connections failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"connections": connections})
+}
+
+// DeleteConnection disconnect a CRM, websites mapped to it stop pushing
+func (instance *httpDelivery) DeleteConnection(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	err = instance.crmUseCase.DeleteConnection(userID, c.Param("provider"))
+	switch err {
+	case nil:
+		c.Status(http.StatusNoContent)
+	case ErrConnectionNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete crm connection failed"})
+	}
+}
+
+func (instance *httpDelivery) GetMapping(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	aMapping, err := instance.crmUseCase.GetMapping(userID, c.Param("website_id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, aMapping)
+	case ErrMappingNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get crm mapping failed"})
+	}
+}
+
+// UpdateMapping set the CRM website pushes to and the properties it writes
+func (instance *httpDelivery) UpdateMapping(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	request, err := req.BindAndValidate[RequestMapping](c)
+	if err != nil {
+		req.BadRequest(c, "invalid mapping", err)
+		return
+	}
+
+	aMapping, err := instance.crmUseCase.UpdateMapping(userID, c.Param("website_id"), request.Provider, request.IDProperty, request.Fields)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, aMapping)
+	case ErrInvalidMapping, ErrUnknownProvider, ErrNotConnected:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case mongo.ErrNoDocuments:
+		c.JSON(http.StatusNotFound, gin.H{"error": "this website not exists"})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update crm mapping failed"})
+	}
+}
+
+func (instance *httpDelivery) DeleteMapping(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	err = instance.crmUseCase.DeleteMapping(userID, c.Param("website_id"))
+	switch err {
+	case nil:
+		c.Status(http.StatusNoContent)
+	case ErrMappingNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete crm mapping failed"})
+	}
+}
+
+// GetQueue latest failed pushes of website waiting for retry or given up, 50
+// by default and at most 500
+func (instance *httpDelivery) GetQueue(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	limit := cursor.Limit(c.Query("limit"), 50, 500)
+	pushes, err := instance.crmUseCase.GetAllPush(userID, c.Param("website_id"), int64(limit))
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get crm queue failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pushes": pushes})
+}
@@ -0,0 +1,93 @@
+package crm
+
+import (
+	"time"
+
+	"analytics-api/internal/pkg/crmapi"
+)
+
+const (
+	// ProviderHubSpot ...
+	ProviderHubSpot = "hubspot"
+	// ProviderSalesforce ...
+	ProviderSalesforce = "salesforce"
+)
+
+// Sources of the acquisition data a website maps to properties of CRM contacts
+const (
+	SourceLandingPage  = "landing_page"
+	SourceReferrer     = "referrer"
+	SourceUTMSource    = "utm_source"
+	SourceUTMMedium    = "utm_medium"
+	SourceUTMCampaign  = "utm_campaign"
+	SourceUTMTerm      = "utm_term"
+	SourceUTMContent   = "utm_content"
+	SourceFirstTouchAt = "first_touch_at"
+	SourceLastGoal     = "last_goal"
+	SourceLastGoalAt   = "last_goal_at"
+)
+
+var sources = map[string]bool{
+	SourceLandingPage:  true,
+	SourceReferrer:     true,
+	SourceUTMSource:    true,
+	SourceUTMMedium:    true,
+	SourceUTMCampaign:  true,
+	SourceUTMTerm:      true,
+	SourceUTMContent:   true,
+	SourceFirstTouchAt: true,
+	SourceLastGoal:     true,
+	SourceLastGoalAt:   true,
+}
+
+const (
+	// StatusPending push waiting for its next attempt
+	StatusPending = "pending"
+	// StatusFailed push given up after maxAttempts
+	StatusFailed = "failed"
+)
+
+// connection CRM account connected by a user, tokens are never replied
+type connection struct {
+	UserID   string       `json:"-" bson:"user_id"`
+	Provider string       `json:"provider" bson:"provider"`
+	Token    crmapi.Token `json:"-" bson:"token"`
+	// SealedToken Token sealed with the store secrets, it is kept in the
+	// clear when DATA_MASTER_KEY is unset
+	SealedToken string `json:"-" bson:"sealed_token,omitempty"`
+	CreatedAt   string `json:"created_at" bson:"created_at"`
+	UpdatedAt   string `json:"updated_at" bson:"updated_at"`
+}
+
+// mapping contacts of website in the CRM of provider, Fields map sources to
+// the CRM properties they are written to
+type mapping struct {
+	UserID     string            `json:"-" bson:"user_id"`
+	WebsiteID  string            `json:"website_id" bson:"website_id"`
+	Provider   string            `json:"provider" bson:"provider"`
+	IDProperty string            `json:"id_property" bson:"id_property"`
+	Fields     map[string]string `json:"fields" bson:"fields"`
+	CreatedAt  string            `json:"created_at" bson:"created_at"`
+	UpdatedAt  string            `json:"updated_at" bson:"updated_at"`
+}
+
+// queuedPush contact update that failed, retried with a growing delay
+type queuedPush struct {
+	ID         string            `json:"id" bson:"id"`
+	UserID     string            `json:"-" bson:"user_id"`
+	WebsiteID  string            `json:"website_id" bson:"website_id"`
+	Provider   string            `json:"provider" bson:"provider"`
+	IDProperty string            `json:"id_property" bson:"id_property"`
+	RecordID   string            `json:"record_id" bson:"record_id"`
+	Properties map[string]string `json:"properties" bson:"properties"`
+	Attempts   int               `json:"attempts" bson:"attempts"`
+	Status     string            `json:"status" bson:"status"`
+	LastError  string            `json:"last_error" bson:"last_error"`
+	// NextAttemptAt and CreatedAt dates so mongo can sort and expire pushes
+	NextAttemptAt time.Time `json:"next_attempt_at" bson:"next_attempt_at"`
+	CreatedAt     time.Time `json:"created_at" bson:"created_at"`
+}
+
+func (instance queuedPush) record() crmapi.Record {
+	return crmapi.Record{IDProperty: instance.IDProperty, ID: instance.RecordID, Properties: instance.Properties}
+}
@@ -0,0 +1,328 @@
+package crm
+
+import (
+	"context"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/crmapi"
+	"analytics-api/internal/pkg/keystore"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// stateTTL time the user has to approve the connection in the CRM
+const stateTTL = 10 * time.Minute
+
+// Repository ...
+type Repository interface {
+	InsertState(state, userID, provider string) error
+	TakeState(state string) (string, error)
+
+	UpsertConnection(aConnection connection) error
+	GetConnection(userID, provider string, aConnection *connection) error
+	GetAllConnection(userID string) ([]connection, error)
+	UpdateToken(userID, provider string, token crmapi.Token) error
+	DeleteConnection(userID, provider string) (int64, error)
+
+	UpsertMapping(aMapping mapping) error
+	GetMapping(userID, websiteID string, aMapping *mapping) error
+	DeleteMapping(userID, websiteID string) (int64, error)
+	DeleteMappingOfProvider(userID, provider string) error
+
+	InsertPush(aPush queuedPush) error
+	GetDuePush(now time.Time, limit int64) ([]queuedPush, error)
+	GetAllPush(userID, websiteID string, limit int64) ([]queuedPush, error)
+	UpdatePush(aPush queuedPush) error
+	DeletePush(pushID string) error
+}
+
+type repository struct {
+	store *db.Store
+}
+
+// NewRepository ...
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store: store,
+	}
+}
+
+// InsertState remember the oauth state of a connection until the CRM redirects back
+func (instance *repository) InsertState(state, userID, provider string) error {
+	return configs.KeyStore.Client.Set(instance.store.Key("crm_state:"+state), userID+"|"+provider, stateTTL)
+}
+
+// TakeState user and provider of an oauth state, which can only be used
+// once: it is read and deleted at once so two callbacks racing with the
+// same state cannot both get it
+func (instance *repository) TakeState(state string) (string, error) {
+	value, err := configs.KeyStore.Client.Take(instance.store.Key("crm_state:" + state))
+	if err == keystore.ErrNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// UpsertConnection replace the tokens of an account connected again
+func (instance *repository) UpsertConnection(aConnection connection) error {
+	connectionCollection := instance.store.Mongo.Collection(configs.MongoDB.CRMConnectionCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": aConnection.UserID},
+		{"provider": aConnection.Provider},
+	}}
+	update, err := instance.setToken(aConnection.Token, aConnection.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	update["$setOnInsert"] = bson.M{
+		"created_at": aConnection.CreatedAt,
+	}
+	_, err = connectionCollection.UpdateOne(context.TODO(), filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) GetConnection(userID, provider string, aConnection *connection) error {
+	connectionCollection := instance.store.Mongo.Collection(configs.MongoDB.CRMConnectionCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"provider": provider},
+	}}
+	err := connectionCollection.FindOne(context.TODO(), filter).Decode(aConnection)
+	if err != nil {
+		return err
+	}
+	return instance.openToken(aConnection)
+}
+
+func (instance *repository) GetAllConnection(userID string) ([]connection, error) {
+	connections := []connection{}
+	connectionCollection := instance.store.Mongo.Collection(configs.MongoDB.CRMConnectionCollection)
+	cursor, err := connectionCollection.Find(context.TODO(), bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &connections); err != nil {
+		return nil, err
+	}
+	for i := range connections {
+		if err := instance.openToken(&connections[i]); err != nil {
+			return nil, err
+		}
+	}
+	return connections, nil
+}
+
+// UpdateToken store refreshed tokens of a connection
+func (instance *repository) UpdateToken(userID, provider string, token crmapi.Token) error {
+	connectionCollection := instance.store.Mongo.Collection(configs.MongoDB.CRMConnectionCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"provider": provider},
+	}}
+	update, err := instance.setToken(token, time.Now().Format("2006-01-02, 15:04:05"))
+	if err != nil {
+		return err
+	}
+	_, err = connectionCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// setToken update storing token, sealed with the store secrets when
+// DATA_MASTER_KEY is set
+func (instance *repository) setToken(token crmapi.Token, updatedAt string) (bson.M, error) {
+	if instance.store.Secrets == nil {
+		return bson.M{
+			"$set":   bson.M{"token": token, "updated_at": updatedAt},
+			"$unset": bson.M{"sealed_token": ""},
+		}, nil
+	}
+	sealed, err := instance.store.Seal(token)
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{
+		"$set":   bson.M{"sealed_token": sealed, "updated_at": updatedAt},
+		"$unset": bson.M{"token": ""},
+	}, nil
+}
+
+// openToken open the sealed token of aConnection
+func (instance *repository) openToken(aConnection *connection) error {
+	if aConnection.SealedToken == "" {
+		return nil
+	}
+	if err := instance.store.Open(aConnection.SealedToken, &aConnection.Token); err != nil {
+		return err
+	}
+	aConnection.SealedToken = ""
+	return nil
+}
+
+func (instance *repository) DeleteConnection(userID, provider string) (int64, error) {
+	connectionCollection := instance.store.Mongo.Collection(configs.MongoDB.CRMConnectionCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"provider": provider},
+	}}
+	result, err := connectionCollection.DeleteOne(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// UpsertMapping replace the mapping of website, a website pushes to one CRM
+func (instance *repository) UpsertMapping(aMapping mapping) error {
+	mappingCollection := instance.store.Mongo.Collection(configs.MongoDB.CRMMappingCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": aMapping.UserID},
+		{"website_id": aMapping.WebsiteID},
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"provider":    aMapping.Provider,
+			"id_property": aMapping.IDProperty,
+			"fields":      aMapping.Fields,
+			"updated_at":  aMapping.UpdatedAt,
+		},
+		"$setOnInsert": bson.M{
+			"created_at": aMapping.CreatedAt,
+		},
+	}
+	_, err := mappingCollection.UpdateOne(context.TODO(), filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) GetMapping(userID, websiteID string, aMapping *mapping) error {
+	mappingCollection := instance.store.Mongo.Collection(configs.MongoDB.CRMMappingCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+	}}
+	err := mappingCollection.FindOne(context.TODO(), filter).Decode(aMapping)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) DeleteMapping(userID, websiteID string) (int64, error) {
+	mappingCollection := instance.store.Mongo.Collection(configs.MongoDB.CRMMappingCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+	}}
+	result, err := mappingCollection.DeleteOne(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// DeleteMappingOfProvider remove mappings of websites to a disconnected CRM
+func (instance *repository) DeleteMappingOfProvider(userID, provider string) error {
+	mappingCollection := instance.store.Mongo.Collection(configs.MongoDB.CRMMappingCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"provider": provider},
+	}}
+	_, err := mappingCollection.DeleteMany(context.TODO(), filter)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) InsertPush(aPush queuedPush) error {
+	queueCollection := instance.store.Mongo.Collection(configs.MongoDB.CRMQueueCollection)
+	_, err := queueCollection.InsertOne(context.TODO(), aPush)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetDuePush pending pushes whose next attempt is due, oldest first
+func (instance *repository) GetDuePush(now time.Time, limit int64) ([]queuedPush, error) {
+	pushes := []queuedPush{}
+	queueCollection := instance.store.Mongo.Collection(configs.MongoDB.CRMQueueCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"status": StatusPending},
+		{"next_attempt_at": bson.M{"$lte": now}},
+	}}
+	opts := options.Find().SetSort(primitive.D{{Key: "next_attempt_at", Value: 1}}).SetLimit(limit)
+	cursor, err := queueCollection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &pushes); err != nil {
+		return nil, err
+	}
+	return pushes, nil
+}
+
+// GetAllPush queued pushes of website, latest first
+func (instance *repository) GetAllPush(userID, websiteID string, limit int64) ([]queuedPush, error) {
+	pushes := []queuedPush{}
+	queueCollection := instance.store.Mongo.Collection(configs.MongoDB.CRMQueueCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+	}}
+	opts := options.Find().SetSort(primitive.D{{Key: "created_at", Value: -1}}).SetLimit(limit)
+	cursor, err := queueCollection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &pushes); err != nil {
+		return nil, err
+	}
+	return pushes, nil
+}
+
+// UpdatePush record the outcome of an attempt
+func (instance *repository) UpdatePush(aPush queuedPush) error {
+	queueCollection := instance.store.Mongo.Collection(configs.MongoDB.CRMQueueCollection)
+	update := bson.M{
+		"$set": bson.M{
+			"attempts":        aPush.Attempts,
+			"status":          aPush.Status,
+			"last_error":      aPush.LastError,
+			"next_attempt_at": aPush.NextAttemptAt,
+		},
+	}
+	result, err := queueCollection.UpdateOne(context.TODO(), bson.M{"id": aPush.ID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (instance *repository) DeletePush(pushID string) error {
+	queueCollection := instance.store.Mongo.Collection(configs.MongoDB.CRMQueueCollection)
+	_, err := queueCollection.DeleteOne(context.TODO(), bson.M{"id": pushID})
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package crm
+
+import (
+	"time"
+
+	"analytics-api/db"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RunRetry retry the queued pushes of store every interval, until the process exits
+func RunRetry(store *db.Store, interval time.Duration) {
+	useCase := NewUseCase(store)
+	for range time.Tick(interval) {
+		sent, failed, err := useCase.Retry()
+		if err != nil {
+			logrus.Error("retry crm pushes error ", err)
+			continue
+		}
+		if sent+failed > 0 {
+			logrus.Info("retried crm pushes, sent ", sent, " failed ", failed)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package crm
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/crmapi"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrUnknownProvider ...
+	ErrUnknownProvider = errors.New("provider must be hubspot or salesforce with its oauth app configured")
+	// ErrInvalidState ...
+	ErrInvalidState = errors.New("the connection expired or was started by another user, connect again")
+	// ErrNotConnected ...
+	ErrNotConnected = errors.New("connect this CRM before mapping fields to it")
+	// ErrConnectionNotFound ...
+	ErrConnectionNotFound = errors.New("this CRM is not connected")
+	// ErrInvalidMapping ...
+	ErrInvalidMapping = errors.New("mapping needs an id property and fields mapping known sources to CRM properties")
+	// ErrMappingNotFound ...
+	ErrMappingNotFound = errors.New("this website has no CRM mapping")
+)
+
+// maxAttempts pushes failing this often are given up
+const maxAttempts = 8
+
+// retryBatch pushes retried per run
+const retryBatch = 100
+
+// UseCase ...
+type UseCase interface {
+	AuthorizeURL(userID, provider string) (string, error)
+	Connect(userID, provider, state, code string) error
+	GetAllConnection(userID string) ([]connection, error)
+	DeleteConnection(userID, provider string) error
+
+	UpdateMapping(userID, websiteID, provider, idProperty string, fields map[string]string) (*mapping, error)
+	GetMapping(userID, websiteID string) (*mapping, error)
+	DeleteMapping(userID, websiteID string) error
+
+	Push(userID, websiteID, recordID string, values map[string]string)
+	GetAllPush(userID, websiteID string, limit int64) ([]queuedPush, error)
+	Retry() (int, int, error)
+}
+
+type useCase struct {
+	repo           Repository
+	websiteUseCase website.UseCase
+}
+
+// NewUseCase ...
+func NewUseCase(store *db.Store) UseCase {
+	return &useCase{
+		repo:           NewRepository(store),
+		websiteUseCase: website.NewUseCase(store),
+	}
+}
+
+// client of provider built from the configured oauth app
+func client(provider string) (crmapi.Client, error) {
+	switch provider {
+	case ProviderHubSpot:
+		if configs.CRM.HubSpotClientID != "" {
+			return crmapi.NewHubSpot(configs.CRM.HubSpotClientID, configs.CRM.HubSpotClientSecret), nil
+		}
+	case ProviderSalesforce:
+		if configs.CRM.SalesforceClientID != "" {
+			return crmapi.NewSalesforce(configs.CRM.SalesforceClientID, configs.CRM.SalesforceClientSecret), nil
+		}
+	}
+	return nil, ErrUnknownProvider
+}
+
+// redirectURL where the CRM sends the user back, registered with the oauth app
+func redirectURL(provider string) string {
+	return strings.TrimSuffix(configs.AppURL, "/") + "/crm/callback/" + provider
+}
+
+// AuthorizeURL page of provider where the user approves the connection
+func (instance *useCase) AuthorizeURL(userID, provider string) (string, error) {
+	aClient, err := client(provider)
+	if err != nil {
+		return "", err
+	}
+	state := uuid.New().String()
+	err = instance.repo.InsertState(state, userID, provider)
+	if err != nil {
+		return "", err
+	}
+	return aClient.AuthorizeURL(state, redirectURL(provider)), nil
+}
+
+// Connect exchange the code the CRM redirected back with, for the user who
+// started the connection only
+func (instance *useCase) Connect(userID, provider, state, code string) error {
+	aClient, err := client(provider)
+	if err != nil {
+		return err
+	}
+	if state == "" {
+		return ErrInvalidState
+	}
+	started, err := instance.repo.TakeState(state)
+	if err != nil {
+		return err
+	}
+	if started != userID+"|"+provider {
+		return ErrInvalidState
+	}
+	token, err := aClient.Exchange(code, redirectURL(provider))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Format("2006-01-02, 15:04:05")
+	err = instance.repo.UpsertConnection(connection{
+		UserID:    userID,
+		Provider:  provider,
+		Token:     token,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *useCase) GetAllConnection(userID string) ([]connection, error) {
+	connections, err := instance.repo.GetAllConnection(userID)
+	if err != nil {
+		return nil, err
+	}
+	return connections, nil
+}
+
+// DeleteConnection forget the tokens of provider and the mappings using it
+func (instance *useCase) DeleteConnection(userID, provider string) error {
+	count, err := instance.repo.DeleteConnection(userID, provider)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrConnectionNotFound
+	}
+	err = instance.repo.DeleteMappingOfProvider(userID, provider)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateMapping set which sources website pushes to which properties of the
+// contacts of provider, mongo.ErrNoDocuments when user has no such website
+func (instance *useCase) UpdateMapping(userID, websiteID, provider, idProperty string, fields map[string]string) (*mapping, error) {
+	if crmapi.ValidateProperty(idProperty) != nil || len(fields) == 0 {
+		return nil, ErrInvalidMapping
+	}
+	for source, property := range fields {
+		if !sources[source] || crmapi.ValidateProperty(property) != nil {
+			return nil, ErrInvalidMapping
+		}
+	}
+	if _, err := client(provider); err != nil {
+		return nil, err
+	}
+	exists, err := instance.websiteUseCase.HasWebsite(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, mongo.ErrNoDocuments
+	}
+	var aConnection connection
+	err = instance.repo.GetConnection(userID, provider, &aConnection)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotConnected
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Format("2006-01-02, 15:04:05")
+	aMapping := mapping{
+		UserID:     userID,
+		WebsiteID:  websiteID,
+		Provider:   provider,
+		IDProperty: idProperty,
+		Fields:     fields,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	err = instance.repo.UpsertMapping(aMapping)
+	if err != nil {
+		return nil, err
+	}
+	return &aMapping, nil
+}
+
+func (instance *useCase) GetMapping(userID, websiteID string) (*mapping, error) {
+	var aMapping mapping
+	err := instance.repo.GetMapping(userID, websiteID, &aMapping)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrMappingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &aMapping, nil
+}
+
+func (instance *useCase) DeleteMapping(userID, websiteID string) error {
+	count, err := instance.repo.DeleteMapping(userID, websiteID)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrMappingNotFound
+	}
+	return nil
+}
+
+// Push write the mapped values to the contact recordID of the CRM of website,
+// queueing it for retry when the CRM fails. Errors are logged, never returned,
+// since tracking must not fail because a CRM does
+func (instance *useCase) Push(userID, websiteID, recordID string, values map[string]string) {
+	var aMapping mapping
+	err := instance.repo.GetMapping(userID, websiteID, &aMapping)
+	if err == mongo.ErrNoDocuments {
+		return
+	}
+	if err != nil {
+		logrus.Error("get crm mapping error ", err)
+		return
+	}
+	properties := map[string]string{}
+	for source, property := range aMapping.Fields {
+		if values[source] != "" {
+			properties[property] = values[source]
+		}
+	}
+	if len(properties) == 0 {
+		return
+	}
+
+	aPush := queuedPush{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		WebsiteID:  websiteID,
+		Provider:   aMapping.Provider,
+		IDProperty: aMapping.IDProperty,
+		RecordID:   recordID,
+		Properties: properties,
+		Attempts:   1,
+		Status:     StatusPending,
+		CreatedAt:  time.Now(),
+	}
+	err = instance.send(aPush)
+	if err == nil {
+		return
+	}
+	logrus.Error("push to ", aMapping.Provider, " error ", err)
+	aPush.LastError = err.Error()
+	aPush.NextAttemptAt = nextAttempt(aPush.Attempts)
+	err = instance.repo.InsertPush(aPush)
+	if err != nil {
+		logrus.Error("queue crm push error ", err)
+	}
+}
+
+func (instance *useCase) GetAllPush(userID, websiteID string, limit int64) ([]queuedPush, error) {
+	pushes, err := instance.repo.GetAllPush(userID, websiteID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return pushes, nil
+}
+
+// Retry attempt the due pushes once more, reporting how many were sent and
+// how many failed again
+func (instance *useCase) Retry() (int, int, error) {
+	pushes, err := instance.repo.GetDuePush(time.Now(), retryBatch)
+	if err != nil {
+		return 0, 0, err
+	}
+	sent, failed := 0, 0
+	for _, aPush := range pushes {
+		err := instance.send(aPush)
+		if err == nil {
+			sent++
+			if err := instance.repo.DeletePush(aPush.ID); err != nil {
+				return sent, failed, err
+			}
+			continue
+		}
+		failed++
+		aPush.Attempts++
+		aPush.LastError = err.Error()
+		aPush.NextAttemptAt = nextAttempt(aPush.Attempts)
+		if aPush.Attempts >= maxAttempts {
+			aPush.Status = StatusFailed
+		}
+		if err := instance.repo.UpdatePush(aPush); err != nil {
+			return sent, failed, err
+		}
+	}
+	return sent, failed, nil
+}
+
+// send write aPush with the tokens of its connection, refreshed when expired
+func (instance *useCase) send(aPush queuedPush) error {
+	aClient, err := client(aPush.Provider)
+	if err != nil {
+		return err
+	}
+	var aConnection connection
+	err = instance.repo.GetConnection(aPush.UserID, aPush.Provider, &aConnection)
+	if err == mongo.ErrNoDocuments {
+		return ErrConnectionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	token := aConnection.Token
+	if token.Expired(time.Now()) {
+		token, err = aClient.Refresh(token)
+		if err != nil {
+			return err
+		}
+		err = instance.repo.UpdateToken(aPush.UserID, aPush.Provider, token)
+		if err != nil {
+			return err
+		}
+	}
+	return aClient.Upsert(token, aPush.record())
+}
+
+// nextAttempt wait 2, 4, 8... minutes after each failed attempt
+func nextAttempt(attempts int) time.Time {
+	return time.Now().Add(time.Duration(1<<uint(attempts)) * time.Minute)
+}
@@ -0,0 +1,30 @@
+package firehose
+
+import (
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPDelivery firehose destinations of users
+type HTTPDelivery interface {
+	// This function is required for every HTTPDelivery
+	InitRoutes(r *gin.RouterGroup)
+
+	// Other functions to handle HTTP requests
+	GetAllDestination(c *gin.Context)
+	CreateDestination(c *gin.Context)
+	UpdateEnabled(c *gin.Context)
+	UpdateFilter(c *gin.Context)
+	DeleteDestination(c *gin.Context)
+	GetMetric(c *gin.Context)
+}
+
+// NewHTTPDelivery ...
+func NewHTTPDelivery(store *db.Store) HTTPDelivery {
+	return &httpDelivery{
+		firehoseUseCase: NewUseCase(store),
+		authUsecase:     auth.NewUseCase(store),
+	}
+}
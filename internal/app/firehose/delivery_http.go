@@ -0,0 +1,227 @@
+package firehose
+
+import (
+	"net/http"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/pkg/cursor"
+	"analytics-api/internal/pkg/middleware"
+	req "analytics-api/internal/pkg/request"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type httpDelivery struct {
+	firehoseUseCase UseCase
+	authUsecase     auth.UseCase
+}
+
+// RequestDestination ...
+type RequestDestination struct {
+	Name    string         `json:"name"`
+	Type    string         `json:"type"`
+	Filter  filter         `json:"filter"`
+	Kafka   *kafkaConfig   `json:"kafka"`
+	Kinesis *kinesisConfig `json:"kinesis"`
+	Webhook *webhookConfig `json:"webhook"`
+}
+
+// RequestEnabled ...
+type RequestEnabled struct {
+	Enabled bool `json:"enabled"`
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	firehoseRoutes := r.Group("firehose")
+	{
+		firehoseRoutes.GET("/destinations", middleware.JWTMiddleware(), instance.GetAllDestination)
+		firehoseRoutes.POST("/destinations", middleware.JWTMiddleware(), instance.CreateDestination)
+		firehoseRoutes.POST("/destinations/:destination_id/enabled", middleware.JWTMiddleware(), instance.UpdateEnabled)
+		firehoseRoutes.POST("/destinations/:destination_id/filter", middleware.JWTMiddleware(), instance.UpdateFilter)
+		firehoseRoutes.DELETE("/destinations/:destination_id", middleware.JWTMiddleware(), instance.DeleteDestination)
+		firehoseRoutes.GET("/destinations/:destination_id/metrics", middleware.JWTMiddleware(), instance.GetMetric)
+	}
+}
+
+func (instance *httpDelivery) GetAllDestination(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	destinations, err := instance.firehoseUseCase.GetAllDestination(userID)
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get destinations failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"destinations": destinations})
+}
+
+func (instance *httpDelivery) CreateDestination(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	request, err := req.BindAndValidate[RequestDestination](c)
+	if err != nil {
+		req.BadRequest(c, "invalid destination", err)
+		return
+	}
+
+	aDestination, err := instance.firehoseUseCase.CreateDestination(userID, destination{
+		Name:    request.Name,
+		Type:    request.Type,
+		Filter:  request.Filter,
+		Kafka:   request.Kafka,
+		Kinesis: request.Kinesis,
+		Webhook: request.Webhook,
+	})
+	switch err {
+	case nil:
+		c.JSON(http.StatusCreated, aDestination)
+	case ErrInvalidDestination, ErrUnknownWebsite, ErrTooManyDestinations, ErrPrivateDestination:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create destination failed"})
+	}
+}
+
+// UpdateEnabled switch streaming to a destination on or off, keeping its configuration
+func (instance *httpDelivery) UpdateEnabled(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	request, err := req.BindAndValidate[RequestEnabled](c)
+	if err != nil {
+		req.BadRequest(c, "invalid enabled", err)
+		return
+	}
+
+	err = instance.firehoseUseCase.UpdateEnabled(userID, c.Param("destination_id"), request.Enabled)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, request)
+	case ErrDestinationNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update destination failed"})
+	}
+}
+
+// UpdateFilter replace the filter of a destination
+func (instance *httpDelivery) UpdateFilter(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	request, err := req.BindAndValidate[filter](c)
+	if err != nil {
+		req.BadRequest(c, "invalid filter", err)
+		return
+	}
+
+	err = instance.firehoseUseCase.UpdateFilter(userID, c.Param("destination_id"), request)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, normalize(request))
+	case ErrUnknownWebsite:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case ErrDestinationNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update filter failed"})
+	}
+}
+
+func (instance *httpDelivery) DeleteDestination(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	err = instance.firehoseUseCase.DeleteDestination(userID, c.Param("destination_id"))
+	switch err {
+	case nil:
+		c.Status(http.StatusNoContent)
+	case ErrDestinationNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete destination failed"})
+	}
+}
+
+// GetMetric hourly delivery metrics of a destination, the last 24 hours by
+// default and at most FirehoseMetricDays
+func (instance *httpDelivery) GetMetric(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	hours := cursor.Limit(c.Query("hours"), 24, db.FirehoseMetricDays*24)
+	metrics, err := instance.firehoseUseCase.GetMetric(userID, c.Param("destination_id"), hours)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{"metrics": metrics})
+	case ErrDestinationNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get metrics failed"})
+	}
+}
@@ -0,0 +1,163 @@
+package firehose
+
+import (
+	"time"
+)
+
+const (
+	// TypeKafka topic behind a Kafka REST Proxy
+	TypeKafka = "kafka"
+	// TypeKinesis AWS Kinesis data stream
+	TypeKinesis = "kinesis"
+	// TypeWebhook endpoint receiving signed JSON batches
+	TypeWebhook = "webhook"
+)
+
+// customEventType rrweb custom event, the only events with a tag
+const customEventType = 5
+
+// destination stream of the events of the websites of a user
+type destination struct {
+	ID      string `json:"id" bson:"id"`
+	UserID  string `json:"user_id" bson:"user_id"`
+	Name    string `json:"name" bson:"name"`
+	Type    string `json:"type" bson:"type"`
+	Enabled bool   `json:"enabled" bson:"enabled"`
+	Filter  filter `json:"filter" bson:"filter"`
+
+	Kafka   *kafkaConfig   `json:"kafka,omitempty" bson:"kafka,omitempty"`
+	Kinesis *kinesisConfig `json:"kinesis,omitempty" bson:"kinesis,omitempty"`
+	Webhook *webhookConfig `json:"webhook,omitempty" bson:"webhook,omitempty"`
+
+	LastDeliveredAt *time.Time `json:"last_delivered_at,omitempty" bson:"last_delivered_at,omitempty"`
+	LastError       string     `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	LastErrorAt     *time.Time `json:"last_error_at,omitempty" bson:"last_error_at,omitempty"`
+	CreatedAt       string     `json:"created_at" bson:"created_at"`
+	UpdatedAt       string     `json:"updated_at" bson:"updated_at"`
+}
+
+// filter events streamed, an empty list lets everything through
+type filter struct {
+	WebsiteIDs []string `json:"website_ids" bson:"website_ids"`
+	// EventTypes rrweb event types, like 4 for page loads and 5 for custom events
+	EventTypes []int64 `json:"event_types" bson:"event_types"`
+	// Tags of the custom events streamed, other events are not affected
+	Tags []string `json:"tags" bson:"tags"`
+}
+
+type kafkaConfig struct {
+	URL      string `json:"url" bson:"url"`
+	Topic    string `json:"topic" bson:"topic"`
+	Username string `json:"username,omitempty" bson:"username,omitempty"`
+	Password string `json:"password,omitempty" bson:"password,omitempty"`
+}
+
+type kinesisConfig struct {
+	Region          string `json:"region" bson:"region"`
+	Stream          string `json:"stream" bson:"stream"`
+	AccessKeyID     string `json:"access_key_id" bson:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key,omitempty" bson:"secret_access_key"`
+}
+
+type webhookConfig struct {
+	URL string `json:"url" bson:"url"`
+	// Secret generated on creation, returned only then
+	Secret string `json:"secret,omitempty" bson:"secret"`
+}
+
+// url the destination is delivered to, empty for kinesis whose endpoint is
+// the one of its region
+func (instance destination) url() string {
+	switch {
+	case instance.Kafka != nil:
+		return instance.Kafka.URL
+	case instance.Webhook != nil:
+		return instance.Webhook.URL
+	}
+	return ""
+}
+
+// redact copy of destination without its credentials, as returned by the API
+func (instance destination) redact() destination {
+	if instance.Kafka != nil {
+		aKafka := *instance.Kafka
+		aKafka.Password = ""
+		instance.Kafka = &aKafka
+	}
+	if instance.Kinesis != nil {
+		aKinesis := *instance.Kinesis
+		aKinesis.SecretAccessKey = ""
+		instance.Kinesis = &aKinesis
+	}
+	if instance.Webhook != nil {
+		aWebhook := *instance.Webhook
+		aWebhook.Secret = ""
+		instance.Webhook = &aWebhook
+	}
+	return instance
+}
+
+// matches report whether filter lets anEvent through
+func (instance filter) matches(anEvent Event) bool {
+	if len(instance.WebsiteIDs) > 0 && !contains(instance.WebsiteIDs, anEvent.WebsiteID) {
+		return false
+	}
+	if len(instance.EventTypes) > 0 {
+		found := false
+		for _, eventType := range instance.EventTypes {
+			found = found || eventType == anEvent.Type
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(instance.Tags) > 0 && anEvent.Type == customEventType && !contains(instance.Tags, anEvent.Tag) {
+		return false
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// metric deliveries to a destination during an hour
+type metric struct {
+	DestinationID string    `json:"-" bson:"destination_id"`
+	UserID        string    `json:"-" bson:"user_id"`
+	Hour          time.Time `json:"hour" bson:"hour"`
+	Events        int64     `json:"events" bson:"events"`
+	FailedEvents  int64     `json:"failed_events" bson:"failed_events"`
+	Batches       int64     `json:"batches" bson:"batches"`
+	FailedBatches int64     `json:"failed_batches" bson:"failed_batches"`
+	// LatencyMs total of the batches, AvgLatencyMs is computed when read
+	LatencyMs    int64 `json:"-" bson:"latency_ms"`
+	AvgLatencyMs int64 `json:"avg_latency_ms" bson:"-"`
+}
+
+// Event recorded event with the session it belongs to, as streamed
+type Event struct {
+	WebsiteID string                 `json:"website_id"`
+	SessionID string                 `json:"session_id"`
+	Type      int64                  `json:"type"`
+	Tag       string                 `json:"tag,omitempty"`
+	Data      map[string]interface{} `json:"data"`
+	// Timestamp milliseconds of the event
+	Timestamp   int64  `json:"timestamp"`
+	Platform    string `json:"platform,omitempty"`
+	Country     string `json:"country,omitempty"`
+	CountryCode string `json:"country_code,omitempty"`
+	Region      string `json:"region,omitempty"`
+	RegionCode  string `json:"region_code,omitempty"`
+	City        string `json:"city,omitempty"`
+	Device      string `json:"device,omitempty"`
+	OS          string `json:"os,omitempty"`
+	OSVersion   string `json:"os_version,omitempty"`
+	Browser     string `json:"browser,omitempty"`
+	AppVersion  string `json:"app_version,omitempty"`
+}
@@ -0,0 +1,198 @@
+package firehose
+
+import (
+	"context"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Repository ...
+type Repository interface {
+	InsertDestination(aDestination destination) error
+	GetAllDestination(userID string) ([]destination, error)
+	GetEnabledDestination(userID string) ([]destination, error)
+	UpdateEnabled(userID, destinationID string, enabled bool) error
+	UpdateFilter(userID, destinationID string, aFilter filter) error
+	UpdateDelivery(destinationID string, at time.Time, deliveryErr string) error
+	DeleteDestination(userID, destinationID string) (int64, error)
+	CountDestination(userID, destinationID string) (int64, error)
+
+	IncMetric(aMetric metric) error
+	GetMetric(userID, destinationID string, from time.Time) ([]metric, error)
+	DeleteMetric(userID, destinationID string) error
+}
+
+type repository struct {
+	store *db.Store
+}
+
+// NewRepository ...
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store: store,
+	}
+}
+
+func (instance *repository) InsertDestination(aDestination destination) error {
+	destinationCollection := instance.store.Mongo.Collection(configs.MongoDB.FirehoseCollection)
+	_, err := destinationCollection.InsertOne(context.TODO(), aDestination)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) GetAllDestination(userID string) ([]destination, error) {
+	return instance.findDestination(bson.M{"user_id": userID})
+}
+
+func (instance *repository) GetEnabledDestination(userID string) ([]destination, error) {
+	return instance.findDestination(bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"enabled": true},
+	}})
+}
+
+func (instance *repository) findDestination(filter bson.M) ([]destination, error) {
+	destinations := []destination{}
+	destinationCollection := instance.store.Mongo.Collection(configs.MongoDB.FirehoseCollection)
+	cursor, err := destinationCollection.Find(context.TODO(), filter)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &destinations); err != nil {
+		return nil, err
+	}
+	return destinations, nil
+}
+
+// UpdateEnabled switch streaming to destination on or off
+func (instance *repository) UpdateEnabled(userID, destinationID string, enabled bool) error {
+	return instance.updateDestination(userID, destinationID, bson.M{"enabled": enabled})
+}
+
+func (instance *repository) UpdateFilter(userID, destinationID string, aFilter filter) error {
+	return instance.updateDestination(userID, destinationID, bson.M{"filter": aFilter})
+}
+
+func (instance *repository) updateDestination(userID, destinationID string, set bson.M) error {
+	destinationCollection := instance.store.Mongo.Collection(configs.MongoDB.FirehoseCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": destinationID},
+	}}
+	set["updated_at"] = time.Now().Format("2006-01-02, 15:04:05")
+	result, err := destinationCollection.UpdateOne(context.TODO(), filter, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// UpdateDelivery record the outcome of the latest delivery to destination,
+// the last error is kept until the next one
+func (instance *repository) UpdateDelivery(destinationID string, at time.Time, deliveryErr string) error {
+	destinationCollection := instance.store.Mongo.Collection(configs.MongoDB.FirehoseCollection)
+	set := bson.M{"last_delivered_at": at}
+	if deliveryErr != "" {
+		set = bson.M{"last_error": deliveryErr, "last_error_at": at}
+	}
+	_, err := destinationCollection.UpdateOne(context.TODO(), bson.M{"id": destinationID}, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) DeleteDestination(userID, destinationID string) (int64, error) {
+	destinationCollection := instance.store.Mongo.Collection(configs.MongoDB.FirehoseCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": destinationID},
+	}}
+	result, err := destinationCollection.DeleteOne(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (instance *repository) CountDestination(userID, destinationID string) (int64, error) {
+	destinationCollection := instance.store.Mongo.Collection(configs.MongoDB.FirehoseCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": destinationID},
+	}}
+	count, err := destinationCollection.CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// IncMetric add the counters of aMetric to those of its destination and hour
+func (instance *repository) IncMetric(aMetric metric) error {
+	metricCollection := instance.store.Mongo.Collection(configs.MongoDB.FirehoseMetricCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"destination_id": aMetric.DestinationID},
+		{"hour": aMetric.Hour},
+	}}
+	update := bson.M{
+		"$setOnInsert": bson.M{"user_id": aMetric.UserID},
+		"$inc": bson.M{
+			"events":         aMetric.Events,
+			"failed_events":  aMetric.FailedEvents,
+			"batches":        aMetric.Batches,
+			"failed_batches": aMetric.FailedBatches,
+			"latency_ms":     aMetric.LatencyMs,
+		},
+	}
+	_, err := metricCollection.UpdateOne(context.TODO(), filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetMetric hourly metrics of destination since from, oldest first
+func (instance *repository) GetMetric(userID, destinationID string, from time.Time) ([]metric, error) {
+	metrics := []metric{}
+	metricCollection := instance.store.Mongo.Collection(configs.MongoDB.FirehoseMetricCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"destination_id": destinationID},
+		{"hour": bson.M{"$gte": from}},
+	}}
+	opts := options.Find().SetSort(primitive.D{{Key: "hour", Value: 1}})
+	cursor, err := metricCollection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &metrics); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+func (instance *repository) DeleteMetric(userID, destinationID string) error {
+	metricCollection := instance.store.Mongo.Collection(configs.MongoDB.FirehoseMetricCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"destination_id": destinationID},
+	}}
+	_, err := metricCollection.DeleteMany(context.TODO(), filter)
+	if err != nil {
+		return err
+	}
+	return nil
+}
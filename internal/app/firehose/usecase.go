@@ -0,0 +1,336 @@
+package firehose
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/eventsink"
+	"analytics-api/internal/pkg/request"
+	"analytics-api/internal/pkg/webhook"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrInvalidDestination ...
+	ErrInvalidDestination = errors.New("destination needs a name and type kafka with url and topic, kinesis with region, stream, access_key_id and secret_access_key, or webhook with an http or https url")
+	// ErrUnknownWebsite ...
+	ErrUnknownWebsite = errors.New("website_ids must be websites of the user")
+	// ErrTooManyDestinations ...
+	ErrTooManyDestinations = errors.New("at most 10 destinations per user")
+	// ErrDestinationNotFound ...
+	ErrDestinationNotFound = errors.New("this destination not exists")
+	// ErrPrivateDestination ...
+	ErrPrivateDestination = errors.New("the url of a destination must resolve to public addresses only")
+)
+
+const (
+	// MaxDestinations event stream destinations of a user
+	MaxDestinations = 10
+	// deliverAttempts tries of a batch before it is counted as failed, after
+	// 1s then 2s
+	deliverAttempts = 3
+)
+
+var (
+	awsRegion  = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-[0-9]$`)
+	streamName = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,128}$`)
+)
+
+// UseCase ...
+type UseCase interface {
+	CreateDestination(userID string, aDestination destination) (*destination, error)
+	GetAllDestination(userID string) ([]destination, error)
+	UpdateEnabled(userID, destinationID string, enabled bool) error
+	UpdateFilter(userID, destinationID string, aFilter filter) error
+	DeleteDestination(userID, destinationID string) error
+	GetMetric(userID, destinationID string, hours int) ([]metric, error)
+	Publish(userID string, events []Event)
+}
+
+type useCase struct {
+	repo           Repository
+	websiteUseCase website.UseCase
+}
+
+// NewUseCase ...
+func NewUseCase(store *db.Store) UseCase {
+	return &useCase{
+		repo:           NewRepository(store),
+		websiteUseCase: website.NewUseCase(store),
+	}
+}
+
+// CreateDestination add a destination to user, enabled. Returned without
+// credentials except the generated secret of a webhook
+func (instance *useCase) CreateDestination(userID string, aDestination destination) (*destination, error) {
+	switch {
+	case aDestination.Name == "":
+		return nil, ErrInvalidDestination
+	case aDestination.Type == TypeKafka && aDestination.Kafka != nil && aDestination.Kafka.Topic != "" &&
+		webhook.ValidateURL(aDestination.Kafka.URL) == nil:
+		aDestination.Kinesis, aDestination.Webhook = nil, nil
+	case aDestination.Type == TypeKinesis && aDestination.Kinesis != nil && awsRegion.MatchString(aDestination.Kinesis.Region) &&
+		streamName.MatchString(aDestination.Kinesis.Stream) && aDestination.Kinesis.AccessKeyID != "" && aDestination.Kinesis.SecretAccessKey != "":
+		aDestination.Kafka, aDestination.Webhook = nil, nil
+	case aDestination.Type == TypeWebhook && aDestination.Webhook != nil && webhook.ValidateURL(aDestination.Webhook.URL) == nil:
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			return nil, err
+		}
+		aDestination.Webhook.Secret = secret
+		aDestination.Kafka, aDestination.Kinesis = nil, nil
+	default:
+		return nil, ErrInvalidDestination
+	}
+	// checked again when delivering, a name resolving privately later is
+	// not connected to
+	if rawURL := aDestination.url(); rawURL != "" && !configs.AllowPrivateURLs && !request.PublicHost(rawURL) {
+		return nil, ErrPrivateDestination
+	}
+
+	err := instance.checkFilter(userID, aDestination.Filter)
+	if err != nil {
+		return nil, err
+	}
+	destinations, err := instance.repo.GetAllDestination(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(destinations) >= MaxDestinations {
+		return nil, ErrTooManyDestinations
+	}
+
+	now := time.Now().Format("2006-01-02, 15:04:05")
+	aDestination.ID = uuid.New().String()
+	aDestination.UserID = userID
+	aDestination.Enabled = true
+	aDestination.Filter = normalize(aDestination.Filter)
+	aDestination.LastDeliveredAt, aDestination.LastError, aDestination.LastErrorAt = nil, "", nil
+	aDestination.CreatedAt = now
+	aDestination.UpdatedAt = now
+	err = instance.repo.InsertDestination(aDestination)
+	if err != nil {
+		return nil, err
+	}
+
+	created := aDestination.redact()
+	if aDestination.Webhook != nil {
+		created.Webhook.Secret = aDestination.Webhook.Secret
+	}
+	return &created, nil
+}
+
+// checkFilter report ErrUnknownWebsite when the filter names a website the
+// user does not own
+func (instance *useCase) checkFilter(userID string, aFilter filter) error {
+	for _, websiteID := range aFilter.WebsiteIDs {
+		exists, err := instance.websiteUseCase.HasWebsite(userID, websiteID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrUnknownWebsite
+		}
+	}
+	return nil
+}
+
+// normalize empty lists of aFilter so they are stored and returned as []
+func normalize(aFilter filter) filter {
+	if aFilter.WebsiteIDs == nil {
+		aFilter.WebsiteIDs = []string{}
+	}
+	if aFilter.EventTypes == nil {
+		aFilter.EventTypes = []int64{}
+	}
+	if aFilter.Tags == nil {
+		aFilter.Tags = []string{}
+	}
+	return aFilter
+}
+
+// GetAllDestination destinations of user without credentials
+func (instance *useCase) GetAllDestination(userID string) ([]destination, error) {
+	destinations, err := instance.repo.GetAllDestination(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range destinations {
+		destinations[i] = destinations[i].redact()
+	}
+	return destinations, nil
+}
+
+func (instance *useCase) UpdateEnabled(userID, destinationID string, enabled bool) error {
+	err := instance.repo.UpdateEnabled(userID, destinationID, enabled)
+	if err == mongo.ErrNoDocuments {
+		return ErrDestinationNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *useCase) UpdateFilter(userID, destinationID string, aFilter filter) error {
+	err := instance.checkFilter(userID, aFilter)
+	if err != nil {
+		return err
+	}
+	err = instance.repo.UpdateFilter(userID, destinationID, normalize(aFilter))
+	if err == mongo.ErrNoDocuments {
+		return ErrDestinationNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteDestination remove destination and its metrics
+func (instance *useCase) DeleteDestination(userID, destinationID string) error {
+	count, err := instance.repo.DeleteDestination(userID, destinationID)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrDestinationNotFound
+	}
+	err = instance.repo.DeleteMetric(userID, destinationID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetMetric hourly delivery metrics of destination over the last hours
+func (instance *useCase) GetMetric(userID, destinationID string, hours int) ([]metric, error) {
+	count, err := instance.repo.CountDestination(userID, destinationID)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrDestinationNotFound
+	}
+	from := time.Now().UTC().Truncate(time.Hour).Add(-time.Duration(hours-1) * time.Hour)
+	metrics, err := instance.repo.GetMetric(userID, destinationID, from)
+	if err != nil {
+		return nil, err
+	}
+	for i := range metrics {
+		if metrics[i].Batches > 0 {
+			metrics[i].AvgLatencyMs = metrics[i].LatencyMs / metrics[i].Batches
+		}
+	}
+	return metrics, nil
+}
+
+// Publish stream events to the enabled destinations of user whose filter lets
+// them through. Errors are counted and logged, never returned, since tracking
+// must not fail because a destination does
+func (instance *useCase) Publish(userID string, events []Event) {
+	destinations, err := instance.repo.GetEnabledDestination(userID)
+	if err != nil {
+		logrus.Error("get firehose destinations error ", err)
+		return
+	}
+	for _, aDestination := range destinations {
+		var records []eventsink.Record
+		for _, anEvent := range events {
+			if !aDestination.Filter.matches(anEvent) {
+				continue
+			}
+			value, err := json.Marshal(anEvent)
+			if err != nil {
+				logrus.Error("marshal firehose event error ", err)
+				continue
+			}
+			records = append(records, eventsink.Record{Key: anEvent.SessionID, Value: value})
+		}
+		if len(records) > 0 {
+			instance.deliver(aDestination, records)
+		}
+	}
+}
+
+// deliver records to aDestination in batches of eventsink.MaxBatch, counting
+// them in the metrics of the current hour
+func (instance *useCase) deliver(aDestination destination, records []eventsink.Record) {
+	now := time.Now()
+	aMetric := metric{
+		DestinationID: aDestination.ID,
+		UserID:        aDestination.UserID,
+		Hour:          now.UTC().Truncate(time.Hour),
+	}
+	aSink, sinkErr := sink(aDestination)
+	var lastErr error
+	for start := 0; start < len(records); start += eventsink.MaxBatch {
+		end := start + eventsink.MaxBatch
+		if end > len(records) {
+			end = len(records)
+		}
+		batch := records[start:end]
+		began := time.Now()
+		err := sinkErr
+		if err == nil {
+			err = send(aSink, batch)
+		}
+		aMetric.LatencyMs += time.Since(began).Milliseconds()
+		aMetric.Batches++
+		aMetric.Events += int64(len(batch))
+		if err != nil {
+			aMetric.FailedBatches++
+			aMetric.FailedEvents += int64(len(batch))
+			lastErr = err
+		}
+	}
+
+	if err := instance.repo.IncMetric(aMetric); err != nil {
+		logrus.Error("inc firehose metric error ", err)
+	}
+	deliveryErr := ""
+	if lastErr != nil {
+		deliveryErr = lastErr.Error()
+	}
+	if err := instance.repo.UpdateDelivery(aDestination.ID, now, deliveryErr); err != nil {
+		logrus.Error("update firehose delivery error ", err)
+	}
+}
+
+// send batch to aSink, retrying failures
+func send(aSink eventsink.Sink, batch []eventsink.Record) error {
+	var err error
+	for attempt := 1; attempt <= deliverAttempts; attempt++ {
+		err = aSink.Send(batch)
+		if err == nil {
+			return nil
+		}
+		if attempt < deliverAttempts {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+	return err
+}
+
+// sink of aDestination
+func sink(aDestination destination) (eventsink.Sink, error) {
+	switch {
+	case aDestination.Type == TypeKafka && aDestination.Kafka != nil:
+		config := aDestination.Kafka
+		return eventsink.NewKafka(config.URL, config.Topic, config.Username, config.Password), nil
+	case aDestination.Type == TypeKinesis && aDestination.Kinesis != nil:
+		config := aDestination.Kinesis
+		return eventsink.NewKinesis(config.Region, config.Stream, config.AccessKeyID, config.SecretAccessKey), nil
+	case aDestination.Type == TypeWebhook && aDestination.Webhook != nil:
+		return eventsink.NewWebhook(aDestination.Webhook.URL, aDestination.Webhook.Secret), nil
+	}
+	return nil, ErrInvalidDestination
+}
@@ -0,0 +1,33 @@
+package firehose
+
+import (
+	"testing"
+
+	"analytics-api/configs"
+)
+
+func TestCreateDestination(t *testing.T) {
+	allowPrivateURLs := configs.AllowPrivateURLs
+	defer func() { configs.AllowPrivateURLs = allowPrivateURLs }()
+	configs.AllowPrivateURLs = false
+
+	tests := []struct {
+		name        string
+		destination destination
+		wantErr     error
+	}{
+		{name: "should refuse a destination without name", destination: destination{Type: TypeWebhook, Webhook: &webhookConfig{URL: "https://93.184.216.34/hook"}}, wantErr: ErrInvalidDestination},
+		{name: "should refuse a webhook on loopback", destination: destination{Name: "hook", Type: TypeWebhook, Webhook: &webhookConfig{URL: "http://127.0.0.1:8080/hook"}}, wantErr: ErrPrivateDestination},
+		{name: "should refuse a webhook on cloud metadata", destination: destination{Name: "hook", Type: TypeWebhook, Webhook: &webhookConfig{URL: "http://169.254.169.254/latest"}}, wantErr: ErrPrivateDestination},
+		{name: "should refuse kafka on a private network", destination: destination{Name: "kafka", Type: TypeKafka, Kafka: &kafkaConfig{URL: "http://10.0.0.5:8082", Topic: "events"}}, wantErr: ErrPrivateDestination},
+		{name: "should refuse kafka on a name not resolving", destination: destination{Name: "kafka", Type: TypeKafka, Kafka: &kafkaConfig{URL: "http://kafka.invalid:8082", Topic: "events"}}, wantErr: ErrPrivateDestination},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := &useCase{}
+			if _, err := instance.CreateDestination("user", tt.destination); err != tt.wantErr {
+				t.Errorf("CreateDestination() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
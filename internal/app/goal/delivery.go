@@ -0,0 +1,29 @@
+package goal
+
+import (
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPDelivery goals of websites
+type HTTPDelivery interface {
+	// This function is required for every HTTPDelivery
+	InitRoutes(r *gin.RouterGroup)
+
+	// Other functions to handle HTTP requests
+	GetAllGoal(c *gin.Context)
+	CreateGoal(c *gin.Context)
+	DeleteGoal(c *gin.Context)
+	GetTrash(c *gin.Context)
+	RestoreGoal(c *gin.Context)
+}
+
+// NewHTTPDelivery ...
+func NewHTTPDelivery(store *db.Store) HTTPDelivery {
+	return &httpDelivery{
+		goalUseCase: NewUseCase(store),
+		authUsecase: auth.NewUseCase(store),
+	}
+}
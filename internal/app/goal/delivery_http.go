@@ -0,0 +1,167 @@
+package goal
+
+import (
+	"net/http"
+
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/pkg/middleware"
+	req "analytics-api/internal/pkg/request"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type httpDelivery struct {
+	goalUseCase UseCase
+	authUsecase auth.UseCase
+}
+
+// RequestGoal ...
+type RequestGoal struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Target string `json:"target"`
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	goalRoutes := r.Group("goal")
+	{
+		goalRoutes.GET("/:website_id", middleware.JWTMiddleware(), instance.GetAllGoal)
+		goalRoutes.POST("/:website_id", middleware.JWTMiddleware(), instance.CreateGoal)
+		goalRoutes.DELETE("/:website_id/:goal_id", middleware.JWTMiddleware(), instance.DeleteGoal)
+		goalRoutes.GET("/:website_id/trash", middleware.JWTMiddleware(), instance.GetTrash)
+		goalRoutes.POST("/:website_id/:goal_id/restore", middleware.JWTMiddleware(), instance.RestoreGoal)
+	}
+}
+
+func (instance *httpDelivery) GetAllGoal(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	goals, err := instance.goalUseCase.GetAllGoal(userID, c.Param("website_id"))
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get goals failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"goals": goals})
+}
+
+func (instance *httpDelivery) CreateGoal(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	request, err := req.BindAndValidate[RequestGoal](c)
+	if err != nil {
+		req.BadRequest(c, "invalid goal", err)
+		return
+	}
+
+	aGoal, err := instance.goalUseCase.CreateGoal(userID, c.Param("website_id"), request.Name, request.Type, request.Target)
+	switch err {
+	case nil:
+		c.JSON(http.StatusCreated, aGoal)
+	case ErrInvalidGoal:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case mongo.ErrNoDocuments:
+		c.JSON(http.StatusNotFound, gin.H{"error": "this website not exists"})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create goal failed"})
+	}
+}
+
+func (instance *httpDelivery) DeleteGoal(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	err = instance.goalUseCase.DeleteGoal(userID, c.Param("website_id"), c.Param("goal_id"))
+	switch err {
+	case nil:
+		c.Status(http.StatusNoContent)
+	case ErrGoalNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete goal failed"})
+	}
+}
+
+// GetTrash goals of website deleted and still restorable
+func (instance *httpDelivery) GetTrash(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	goals, err := instance.goalUseCase.GetTrash(userID, c.Param("website_id"))
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get goal trash failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"goals": goals})
+}
+
+// RestoreGoal bring back a goal of the trash
+func (instance *httpDelivery) RestoreGoal(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	aGoal, err := instance.goalUseCase.RestoreGoal(userID, c.Param("website_id"), c.Param("goal_id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, aGoal)
+	case ErrNotInTrash:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "restore goal failed"})
+	}
+}
@@ -0,0 +1,25 @@
+package goal
+
+import (
+	"time"
+
+	"analytics-api/db"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RunPurge remove the goals past their time in the trash of store every
+// interval, until the process exits
+func RunPurge(store *db.Store, interval time.Duration) {
+	useCase := NewUseCase(store)
+	for range time.Tick(interval) {
+		purged, err := useCase.PurgeTrash()
+		if err != nil {
+			logrus.Error("purge goal trash error ", err)
+			continue
+		}
+		if purged > 0 {
+			logrus.Info("purged goals of the trash ", purged)
+		}
+	}
+}
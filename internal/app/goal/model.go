@@ -0,0 +1,23 @@
+package goal
+
+import "time"
+
+// goal conversion of a website, reached by a session doing Target
+type goal struct {
+	ID        string `json:"id" bson:"id"`
+	UserID    string `json:"user_id" bson:"user_id"`
+	WebsiteID string `json:"website_id" bson:"website_id"`
+	Name      string `json:"name" bson:"name"`
+	Type      string `json:"type" bson:"type"`
+	// Target form id of form goals
+	Target    string `json:"target" bson:"target"`
+	CreatedAt string `json:"created_at" bson:"created_at"`
+	UpdatedAt string `json:"updated_at" bson:"updated_at"`
+	// DeletedAt set while the goal is in the trash, it counts no conversion
+	// until restored or purged after PurgeAfter
+	DeletedAt  string     `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	PurgeAfter *time.Time `json:"purge_after,omitempty" bson:"purge_after,omitempty"`
+}
+
+// TypeForm goal reached by submitting the form of Target
+const TypeForm = "form"
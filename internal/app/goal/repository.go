@@ -0,0 +1,132 @@
+package goal
+
+import (
+	"context"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Repository ...
+type Repository interface {
+	InsertGoal(aGoal goal) error
+	GetAllGoal(userID, websiteID string) ([]goal, error)
+	DeleteGoal(userID, websiteID, goalID string, purgeAfter time.Time) (int64, error)
+	GetTrash(userID, websiteID string) ([]goal, error)
+	RestoreGoal(userID, websiteID, goalID string, aGoal *goal) error
+	PurgeTrash() (int64, error)
+}
+
+type repository struct {
+	store *db.Store
+}
+
+// NewRepository ...
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store: store,
+	}
+}
+
+func (instance *repository) InsertGoal(aGoal goal) error {
+	goalCollection := instance.store.Mongo.Collection(configs.MongoDB.GoalCollection)
+	_, err := goalCollection.InsertOne(context.TODO(), aGoal)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) GetAllGoal(userID, websiteID string) ([]goal, error) {
+	goals := []goal{}
+	goalCollection := instance.store.Mongo.Collection(configs.MongoDB.GoalCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"deleted_at": nil},
+	}}
+	cursor, err := goalCollection.Find(context.TODO(), filter)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &goals); err != nil {
+		return nil, err
+	}
+	return goals, nil
+}
+
+// DeleteGoal move goal to the trash until purgeAfter
+func (instance *repository) DeleteGoal(userID, websiteID, goalID string, purgeAfter time.Time) (int64, error) {
+	goalCollection := instance.store.Mongo.Collection(configs.MongoDB.GoalCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"id": goalID},
+		{"deleted_at": nil},
+	}}
+	now := time.Now().Format("2006-01-02, 15:04:05")
+	update := bson.M{
+		"$set": bson.M{
+			"deleted_at":  now,
+			"purge_after": purgeAfter,
+			"updated_at":  now,
+		},
+	}
+	result, err := goalCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.MatchedCount, nil
+}
+
+// GetTrash goals of website in the trash, the most recently deleted first
+func (instance *repository) GetTrash(userID, websiteID string) ([]goal, error) {
+	goals := []goal{}
+	goalCollection := instance.store.Mongo.Collection(configs.MongoDB.GoalCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"purge_after": bson.M{"$gt": time.Now()}},
+	}}
+	opts := options.Find().SetSort(bson.M{"purge_after": -1})
+	cursor, err := goalCollection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &goals); err != nil {
+		return nil, err
+	}
+	return goals, nil
+}
+
+// RestoreGoal take goal out of the trash and decode it in aGoal,
+// mongo.ErrNoDocuments when it is not in the trash or its time is over
+func (instance *repository) RestoreGoal(userID, websiteID, goalID string, aGoal *goal) error {
+	goalCollection := instance.store.Mongo.Collection(configs.MongoDB.GoalCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"id": goalID},
+		{"purge_after": bson.M{"$gt": time.Now()}},
+	}}
+	update := bson.M{
+		"$unset": bson.M{"deleted_at": "", "purge_after": ""},
+		"$set":   bson.M{"updated_at": time.Now().Format("2006-01-02, 15:04:05")},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	return goalCollection.FindOneAndUpdate(context.TODO(), filter, update, opts).Decode(aGoal)
+}
+
+// PurgeTrash remove for good the goals in the trash past their purge_after
+func (instance *repository) PurgeTrash() (int64, error) {
+	goalCollection := instance.store.Mongo.Collection(configs.MongoDB.GoalCollection)
+	result, err := goalCollection.DeleteMany(context.TODO(), bson.M{"purge_after": bson.M{"$lte": time.Now()}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,128 @@
+package goal
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/website"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrInvalidGoal ...
+	ErrInvalidGoal = errors.New("goal needs a name, type form and the id of the form as target")
+	// ErrGoalNotFound ...
+	ErrGoalNotFound = errors.New("this goal not exists")
+	// ErrNotInTrash ...
+	ErrNotInTrash = errors.New("this goal is not in the trash")
+)
+
+// TrashWindow time a deleted goal stays in the trash and can be restored,
+// it is purged after
+const TrashWindow = 30 * 24 * time.Hour
+
+// UseCase ...
+type UseCase interface {
+	CreateGoal(userID, websiteID, name, goalType, target string) (*goal, error)
+	GetAllGoal(userID, websiteID string) ([]goal, error)
+	DeleteGoal(userID, websiteID, goalID string) error
+	GetTrash(userID, websiteID string) ([]goal, error)
+	RestoreGoal(userID, websiteID, goalID string) (*goal, error)
+	PurgeTrash() (int64, error)
+}
+
+type useCase struct {
+	repo           Repository
+	websiteUseCase website.UseCase
+}
+
+// NewUseCase ...
+func NewUseCase(store *db.Store) UseCase {
+	return &useCase{
+		repo:           NewRepository(store),
+		websiteUseCase: website.NewUseCase(store),
+	}
+}
+
+// CreateGoal add goal to website, mongo.ErrNoDocuments when user has no such website
+func (instance *useCase) CreateGoal(userID, websiteID, name, goalType, target string) (*goal, error) {
+	name = strings.TrimSpace(name)
+	target = strings.TrimSpace(target)
+	if name == "" || goalType != TypeForm || target == "" {
+		return nil, ErrInvalidGoal
+	}
+	exists, err := instance.websiteUseCase.HasWebsite(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	now := time.Now().Format("2006-01-02, 15:04:05")
+	aGoal := goal{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		WebsiteID: websiteID,
+		Name:      name,
+		Type:      goalType,
+		Target:    target,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = instance.repo.InsertGoal(aGoal)
+	if err != nil {
+		return nil, err
+	}
+	instance.websiteUseCase.MarkOnboarding(websiteID, website.StepGoalCreated)
+	return &aGoal, nil
+}
+
+func (instance *useCase) GetAllGoal(userID, websiteID string) ([]goal, error) {
+	goals, err := instance.repo.GetAllGoal(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	return goals, nil
+}
+
+// DeleteGoal move goal to the trash for TrashWindow
+func (instance *useCase) DeleteGoal(userID, websiteID, goalID string) error {
+	count, err := instance.repo.DeleteGoal(userID, websiteID, goalID, time.Now().Add(TrashWindow))
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrGoalNotFound
+	}
+	return nil
+}
+
+// GetTrash goals of website deleted less than TrashWindow ago
+func (instance *useCase) GetTrash(userID, websiteID string) ([]goal, error) {
+	return instance.repo.GetTrash(userID, websiteID)
+}
+
+// RestoreGoal bring back a goal of the trash, ErrNotInTrash when it is not
+// there or was purged
+func (instance *useCase) RestoreGoal(userID, websiteID, goalID string) (*goal, error) {
+	var aGoal goal
+	err := instance.repo.RestoreGoal(userID, websiteID, goalID, &aGoal)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotInTrash
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &aGoal, nil
+}
+
+// PurgeTrash remove the goals in the trash for longer than TrashWindow,
+// returns the count removed
+func (instance *useCase) PurgeTrash() (int64, error) {
+	return instance.repo.PurgeTrash()
+}
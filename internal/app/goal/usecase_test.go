@@ -0,0 +1,79 @@
+package goal
+
+import (
+	"testing"
+
+	"analytics-api/internal/app/website"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// fakeRepository keeps the goals inserted
+type fakeRepository struct {
+	Repository
+	inserted []goal
+}
+
+func (instance *fakeRepository) InsertGoal(aGoal goal) error {
+	instance.inserted = append(instance.inserted, aGoal)
+	return nil
+}
+
+// fakeWebsites user-1 owns website-1 only
+type fakeWebsites struct {
+	website.UseCase
+}
+
+func (instance *fakeWebsites) HasWebsite(userID, websiteID string) (bool, error) {
+	return userID == "user-1" && websiteID == "website-1", nil
+}
+
+func (instance *fakeWebsites) MarkOnboarding(websiteID, step string) {}
+
+func TestCreateGoal(t *testing.T) {
+	tests := []struct {
+		name      string
+		websiteID string
+		goalName  string
+		goalType  string
+		target    string
+		wantErr   error
+		want      goal
+	}{
+		{
+			name:      "should add a form goal trimmed",
+			websiteID: "website-1",
+			goalName:  " Signup ",
+			goalType:  TypeForm,
+			target:    " signup-form ",
+			want:      goal{UserID: "user-1", WebsiteID: "website-1", Name: "Signup", Type: TypeForm, Target: "signup-form"},
+		},
+		{name: "should reject an empty name", websiteID: "website-1", goalName: " ", goalType: TypeForm, target: "signup-form", wantErr: ErrInvalidGoal},
+		{name: "should reject an empty target", websiteID: "website-1", goalName: "Signup", goalType: TypeForm, target: "", wantErr: ErrInvalidGoal},
+		{name: "should reject an unknown type", websiteID: "website-1", goalName: "Signup", goalType: "page", target: "/thanks", wantErr: ErrInvalidGoal},
+		{name: "should reject a website of someone else", websiteID: "website-2", goalName: "Signup", goalType: TypeForm, target: "signup-form", wantErr: mongo.ErrNoDocuments},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{}
+			instance := &useCase{repo: repo, websiteUseCase: &fakeWebsites{}}
+			got, err := instance.CreateGoal("user-1", tt.websiteID, tt.goalName, tt.goalType, tt.target)
+			if err != tt.wantErr {
+				t.Fatalf("CreateGoal() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(repo.inserted) != 0 {
+					t.Errorf("CreateGoal() inserted %v", repo.inserted)
+				}
+				return
+			}
+			if got.ID == "" || got.CreatedAt == "" {
+				t.Errorf("CreateGoal() = %+v, want an id and a creation time", got)
+			}
+			got.ID, got.CreatedAt, got.UpdatedAt = "", "", ""
+			if *got != tt.want || len(repo.inserted) != 1 {
+				t.Errorf("CreateGoal() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
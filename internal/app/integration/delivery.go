@@ -0,0 +1,29 @@
+package integration
+
+import (
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPDelivery ad platform integrations of websites
+type HTTPDelivery interface {
+	// This function is required for every HTTPDelivery
+	InitRoutes(r *gin.RouterGroup)
+
+	// Other functions to handle HTTP requests
+	GetAllIntegration(c *gin.Context)
+	CreateIntegration(c *gin.Context)
+	UpdateEnabled(c *gin.Context)
+	DeleteIntegration(c *gin.Context)
+	GetDeliveryLog(c *gin.Context)
+}
+
+// NewHTTPDelivery ...
+func NewHTTPDelivery(store *db.Store) HTTPDelivery {
+	return &httpDelivery{
+		integrationUseCase: NewUseCase(store),
+		authUsecase:        auth.NewUseCase(store),
+	}
+}
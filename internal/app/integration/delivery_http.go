@@ -0,0 +1,181 @@
+package integration
+
+import (
+	"net/http"
+
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/pkg/cursor"
+	"analytics-api/internal/pkg/middleware"
+	req "analytics-api/internal/pkg/request"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type httpDelivery struct {
+	integrationUseCase UseCase
+	authUsecase        auth.UseCase
+}
+
+// RequestIntegration ...
+type RequestIntegration struct {
+	Provider  string           `json:"provider"`
+	GoalIDs   []string         `json:"goal_ids"`
+	Meta      *metaConfig      `json:"meta"`
+	GoogleAds *googleAdsConfig `json:"google_ads"`
+}
+
+// RequestEnabled ...
+type RequestEnabled struct {
+	Enabled bool `json:"enabled"`
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	integrationRoutes := r.Group("integration")
+	{
+		integrationRoutes.GET("/:website_id", middleware.JWTMiddleware(), instance.GetAllIntegration)
+		integrationRoutes.POST("/:website_id", middleware.JWTMiddleware(), instance.CreateIntegration)
+		integrationRoutes.POST("/:website_id/:integration_id/enabled", middleware.JWTMiddleware(), instance.UpdateEnabled)
+		integrationRoutes.DELETE("/:website_id/:integration_id", middleware.JWTMiddleware(), instance.DeleteIntegration)
+		integrationRoutes.GET("/:website_id/:integration_id/logs", middleware.JWTMiddleware(), instance.GetDeliveryLog)
+	}
+}
+
+func (instance *httpDelivery) GetAllIntegration(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	integrations, err := instance.integrationUseCase.GetAllIntegration(userID, c.Param("website_id"))
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get integrations failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"integrations": integrations})
+}
+
+func (instance *httpDelivery) CreateIntegration(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	request, err := req.BindAndValidate[RequestIntegration](c)
+	if err != nil {
+		req.BadRequest(c, "invalid integration", err)
+		return
+	}
+
+	anIntegration, err := instance.integrationUseCase.CreateIntegration(userID, c.Param("website_id"), request.Provider, request.GoalIDs, request.Meta, request.GoogleAds)
+	switch err {
+	case nil:
+		c.JSON(http.StatusCreated, anIntegration)
+	case ErrInvalidIntegration, ErrUnknownGoal:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case mongo.ErrNoDocuments:
+		c.JSON(http.StatusNotFound, gin.H{"error": "this website not exists"})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create integration failed"})
+	}
+}
+
+// UpdateEnabled switch forwarding of an integration on or off, keeping its configuration
+func (instance *httpDelivery) UpdateEnabled(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	request, err := req.BindAndValidate[RequestEnabled](c)
+	if err != nil {
+		req.BadRequest(c, "invalid enabled", err)
+		return
+	}
+
+	err = instance.integrationUseCase.UpdateEnabled(userID, c.Param("website_id"), c.Param("integration_id"), request.Enabled)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, request)
+	case ErrIntegrationNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update integration failed"})
+	}
+}
+
+func (instance *httpDelivery) DeleteIntegration(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	err = instance.integrationUseCase.DeleteIntegration(userID, c.Param("website_id"), c.Param("integration_id"))
+	switch err {
+	case nil:
+		c.Status(http.StatusNoContent)
+	case ErrIntegrationNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete integration failed"})
+	}
+}
+
+// GetDeliveryLog latest deliveries of an integration, 50 by default and at most 500
+func (instance *httpDelivery) GetDeliveryLog(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	limit := cursor.Limit(c.Query("limit"), 50, 500)
+	logs, err := instance.integrationUseCase.GetDeliveryLog(userID, c.Param("website_id"), c.Param("integration_id"), int64(limit))
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get delivery logs failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"logs": logs})
+}
@@ -0,0 +1,133 @@
+package integration
+
+import (
+	"time"
+
+	"analytics-api/internal/pkg/adconv"
+)
+
+const (
+	// ProviderMeta Meta Conversions API
+	ProviderMeta = "meta"
+	// ProviderGoogleAds Google Ads click conversions
+	ProviderGoogleAds = "google_ads"
+)
+
+// integration forward conversions of the goals of a website to an ad platform
+type integration struct {
+	ID        string `json:"id" bson:"id"`
+	UserID    string `json:"user_id" bson:"user_id"`
+	WebsiteID string `json:"website_id" bson:"website_id"`
+	Provider  string `json:"provider" bson:"provider"`
+	Enabled   bool   `json:"enabled" bson:"enabled"`
+	// GoalIDs goals forwarded, all goals of the website when empty
+	GoalIDs   []string         `json:"goal_ids" bson:"goal_ids"`
+	Meta      *metaConfig      `json:"meta,omitempty" bson:"meta,omitempty"`
+	GoogleAds *googleAdsConfig `json:"google_ads,omitempty" bson:"google_ads,omitempty"`
+	// Sealed credentials of Meta and GoogleAds sealed with the store secrets,
+	// they are kept in the clear when DATA_MASTER_KEY is unset
+	Sealed    string `json:"-" bson:"sealed,omitempty"`
+	CreatedAt string `json:"created_at" bson:"created_at"`
+	UpdatedAt string `json:"updated_at" bson:"updated_at"`
+}
+
+// credentials secrets of an integration, sealed together
+type credentials struct {
+	AccessToken    string `json:"access_token,omitempty"`
+	DeveloperToken string `json:"developer_token,omitempty"`
+	ClientSecret   string `json:"client_secret,omitempty"`
+	RefreshToken   string `json:"refresh_token,omitempty"`
+}
+
+// metaConfig pixel receiving the events
+type metaConfig struct {
+	PixelID     string `json:"pixel_id" bson:"pixel_id"`
+	AccessToken string `json:"access_token,omitempty" bson:"access_token"`
+}
+
+// googleAdsConfig conversion action receiving the conversions
+type googleAdsConfig struct {
+	CustomerID       string `json:"customer_id" bson:"customer_id"`
+	ConversionAction string `json:"conversion_action" bson:"conversion_action"`
+	LoginCustomerID  string `json:"login_customer_id,omitempty" bson:"login_customer_id,omitempty"`
+	DeveloperToken   string `json:"developer_token,omitempty" bson:"developer_token"`
+	ClientID         string `json:"client_id" bson:"client_id"`
+	ClientSecret     string `json:"client_secret,omitempty" bson:"client_secret"`
+	RefreshToken     string `json:"refresh_token,omitempty" bson:"refresh_token"`
+}
+
+// credentials of integration, taken out of its configs when clear is set
+func (instance *integration) credentials(clear bool) credentials {
+	var aCredentials credentials
+	if instance.Meta != nil {
+		aCredentials.AccessToken = instance.Meta.AccessToken
+	}
+	if instance.GoogleAds != nil {
+		aCredentials.DeveloperToken = instance.GoogleAds.DeveloperToken
+		aCredentials.ClientSecret = instance.GoogleAds.ClientSecret
+		aCredentials.RefreshToken = instance.GoogleAds.RefreshToken
+	}
+	if clear {
+		*instance = instance.redact()
+	}
+	return aCredentials
+}
+
+// setCredentials put aCredentials back in the configs of integration
+func (instance *integration) setCredentials(aCredentials credentials) {
+	if instance.Meta != nil {
+		instance.Meta.AccessToken = aCredentials.AccessToken
+	}
+	if instance.GoogleAds != nil {
+		instance.GoogleAds.DeveloperToken = aCredentials.DeveloperToken
+		instance.GoogleAds.ClientSecret = aCredentials.ClientSecret
+		instance.GoogleAds.RefreshToken = aCredentials.RefreshToken
+	}
+}
+
+// redact copy of integration without its credentials, as returned by the API
+func (instance integration) redact() integration {
+	if instance.Meta != nil {
+		aMeta := *instance.Meta
+		aMeta.AccessToken = ""
+		instance.Meta = &aMeta
+	}
+	if instance.GoogleAds != nil {
+		aGoogleAds := *instance.GoogleAds
+		aGoogleAds.DeveloperToken = ""
+		aGoogleAds.ClientSecret = ""
+		aGoogleAds.RefreshToken = ""
+		instance.GoogleAds = &aGoogleAds
+	}
+	return instance
+}
+
+// deliveryLog outcome of forwarding a conversion to an integration
+type deliveryLog struct {
+	IntegrationID string `json:"integration_id" bson:"integration_id"`
+	UserID        string `json:"-" bson:"user_id"`
+	WebsiteID     string `json:"website_id" bson:"website_id"`
+	GoalID        string `json:"goal_id" bson:"goal_id"`
+	EventID       string `json:"event_id" bson:"event_id"`
+	Status        string `json:"status" bson:"status"`
+	Error         string `json:"error,omitempty" bson:"error,omitempty"`
+	// CreatedAt a date so mongo can expire logs
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+const (
+	// StatusSent conversion accepted by the ad platform
+	StatusSent = "sent"
+	// StatusFailed conversion rejected or not delivered
+	StatusFailed = "failed"
+)
+
+// Conversion form submitted by a consenting visitor, reported by the tracker
+type Conversion struct {
+	SessionID string
+	FormID    string
+	// Timestamp milliseconds of the submission
+	Timestamp int64
+	SourceURL string
+	User      adconv.UserData
+}
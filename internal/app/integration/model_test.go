@@ -0,0 +1,53 @@
+package integration
+
+import (
+	"testing"
+)
+
+func TestCredentials(t *testing.T) {
+	tests := []struct {
+		name        string
+		integration integration
+		want        credentials
+	}{
+		{
+			name:        "should take the meta access token",
+			integration: integration{Meta: &metaConfig{PixelID: "123", AccessToken: "token"}},
+			want:        credentials{AccessToken: "token"},
+		},
+		{
+			name: "should take the google ads secrets",
+			integration: integration{GoogleAds: &googleAdsConfig{
+				CustomerID:     "42",
+				DeveloperToken: "developer",
+				ClientID:       "client",
+				ClientSecret:   "secret",
+				RefreshToken:   "refresh",
+			}},
+			want: credentials{DeveloperToken: "developer", ClientSecret: "secret", RefreshToken: "refresh"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			anIntegration := tt.integration
+			got := anIntegration.credentials(true)
+			if got != tt.want {
+				t.Fatalf("credentials() = %+v, want %+v", got, tt.want)
+			}
+			if anIntegration.credentials(false) != (credentials{}) {
+				t.Errorf("credentials() left secrets in %+v", anIntegration)
+			}
+			if anIntegration.Meta != nil && anIntegration.Meta.PixelID != tt.integration.Meta.PixelID {
+				t.Errorf("credentials() cleared the pixel id")
+			}
+			if anIntegration.GoogleAds != nil && anIntegration.GoogleAds.ClientID != tt.integration.GoogleAds.ClientID {
+				t.Errorf("credentials() cleared the client id")
+			}
+
+			anIntegration.setCredentials(got)
+			if anIntegration.credentials(false) != tt.want {
+				t.Errorf("setCredentials() = %+v, want %+v", anIntegration.credentials(false), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package integration
+
+import (
+	"context"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Repository ...
+type Repository interface {
+	InsertIntegration(anIntegration integration) error
+	GetAllIntegration(userID, websiteID string) ([]integration, error)
+	GetEnabledIntegration(userID, websiteID string) ([]integration, error)
+	UpdateEnabled(userID, websiteID, integrationID string, enabled bool) error
+	DeleteIntegration(userID, websiteID, integrationID string) (int64, error)
+
+	InsertDeliveryLog(aLog deliveryLog) error
+	GetDeliveryLog(userID, websiteID, integrationID string, limit int64) ([]deliveryLog, error)
+}
+
+type repository struct {
+	store *db.Store
+}
+
+// NewRepository ...
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store: store,
+	}
+}
+
+// InsertIntegration save anIntegration, its credentials sealed with the
+// secrets of the store
+func (instance *repository) InsertIntegration(anIntegration integration) error {
+	if instance.store.Secrets != nil {
+		sealed, err := instance.store.Seal(anIntegration.credentials(true))
+		if err != nil {
+			return err
+		}
+		anIntegration.Sealed = sealed
+	}
+	integrationCollection := instance.store.Mongo.Collection(configs.MongoDB.IntegrationCollection)
+	_, err := integrationCollection.InsertOne(context.TODO(), anIntegration)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) GetAllIntegration(userID, websiteID string) ([]integration, error) {
+	return instance.findIntegration(bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+	}})
+}
+
+func (instance *repository) GetEnabledIntegration(userID, websiteID string) ([]integration, error) {
+	return instance.findIntegration(bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"enabled": true},
+	}})
+}
+
+func (instance *repository) findIntegration(filter bson.M) ([]integration, error) {
+	integrations := []integration{}
+	integrationCollection := instance.store.Mongo.Collection(configs.MongoDB.IntegrationCollection)
+	cursor, err := integrationCollection.Find(context.TODO(), filter)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &integrations); err != nil {
+		return nil, err
+	}
+	for i := range integrations {
+		if integrations[i].Sealed == "" {
+			continue
+		}
+		var aCredentials credentials
+		if err := instance.store.Open(integrations[i].Sealed, &aCredentials); err != nil {
+			return nil, err
+		}
+		integrations[i].setCredentials(aCredentials)
+		integrations[i].Sealed = ""
+	}
+	return integrations, nil
+}
+
+// UpdateEnabled switch forwarding of integration on or off
+func (instance *repository) UpdateEnabled(userID, websiteID, integrationID string, enabled bool) error {
+	integrationCollection := instance.store.Mongo.Collection(configs.MongoDB.IntegrationCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"id": integrationID},
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"enabled":    enabled,
+			"updated_at": time.Now().Format("2006-01-02, 15:04:05"),
+		},
+	}
+	result, err := integrationCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (instance *repository) DeleteIntegration(userID, websiteID, integrationID string) (int64, error) {
+	integrationCollection := instance.store.Mongo.Collection(configs.MongoDB.IntegrationCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"id": integrationID},
+	}}
+	result, err := integrationCollection.DeleteOne(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (instance *repository) InsertDeliveryLog(aLog deliveryLog) error {
+	logCollection := instance.store.Mongo.Collection(configs.MongoDB.DeliveryLogCollection)
+	_, err := logCollection.InsertOne(context.TODO(), aLog)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetDeliveryLog latest deliveries of integration first
+func (instance *repository) GetDeliveryLog(userID, websiteID, integrationID string, limit int64) ([]deliveryLog, error) {
+	logs := []deliveryLog{}
+	logCollection := instance.store.Mongo.Collection(configs.MongoDB.DeliveryLogCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"integration_id": integrationID},
+	}}
+	opts := options.Find().SetSort(primitive.D{{Key: "created_at", Value: -1}}).SetLimit(limit)
+	cursor, err := logCollection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &logs); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
@@ -0,0 +1,255 @@
+package integration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/goal"
+	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/adconv"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrInvalidIntegration ...
+	ErrInvalidIntegration = errors.New("integration needs provider meta with pixel_id and access_token, or google_ads with customer_id, conversion_action, developer_token, client_id, client_secret and refresh_token")
+	// ErrUnknownGoal ...
+	ErrUnknownGoal = errors.New("goal_ids must be goals of the website")
+	// ErrIntegrationNotFound ...
+	ErrIntegrationNotFound = errors.New("this integration not exists")
+)
+
+// senders cached by integration id so oauth tokens are reused between deliveries
+var senders sync.Map
+
+// UseCase ...
+type UseCase interface {
+	CreateIntegration(userID, websiteID, provider string, goalIDs []string, aMeta *metaConfig, aGoogleAds *googleAdsConfig) (*integration, error)
+	GetAllIntegration(userID, websiteID string) ([]integration, error)
+	UpdateEnabled(userID, websiteID, integrationID string, enabled bool) error
+	DeleteIntegration(userID, websiteID, integrationID string) error
+	GetDeliveryLog(userID, websiteID, integrationID string, limit int64) ([]deliveryLog, error)
+	Forward(userID, websiteID string, conversions []Conversion)
+}
+
+type useCase struct {
+	repo           Repository
+	websiteUseCase website.UseCase
+	goalUseCase    goal.UseCase
+}
+
+// NewUseCase ...
+func NewUseCase(store *db.Store) UseCase {
+	return &useCase{
+		repo:           NewRepository(store),
+		websiteUseCase: website.NewUseCase(store),
+		goalUseCase:    goal.NewUseCase(store),
+	}
+}
+
+// CreateIntegration add an integration to website, enabled. Returned without
+// credentials
+func (instance *useCase) CreateIntegration(userID, websiteID, provider string, goalIDs []string, aMeta *metaConfig, aGoogleAds *googleAdsConfig) (*integration, error) {
+	switch {
+	case provider == ProviderMeta && aMeta != nil && aMeta.PixelID != "" && aMeta.AccessToken != "":
+		aGoogleAds = nil
+	case provider == ProviderGoogleAds && aGoogleAds != nil && aGoogleAds.CustomerID != "" && aGoogleAds.ConversionAction != "" &&
+		aGoogleAds.DeveloperToken != "" && aGoogleAds.ClientID != "" && aGoogleAds.ClientSecret != "" && aGoogleAds.RefreshToken != "":
+		aMeta = nil
+	default:
+		return nil, ErrInvalidIntegration
+	}
+
+	exists, err := instance.websiteUseCase.HasWebsite(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, mongo.ErrNoDocuments
+	}
+	goals, err := instance.goalUseCase.GetAllGoal(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, aGoal := range goals {
+		known[aGoal.ID] = true
+	}
+	if goalIDs == nil {
+		goalIDs = []string{}
+	}
+	for _, goalID := range goalIDs {
+		if !known[goalID] {
+			return nil, ErrUnknownGoal
+		}
+	}
+
+	now := time.Now().Format("2006-01-02, 15:04:05")
+	anIntegration := integration{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		WebsiteID: websiteID,
+		Provider:  provider,
+		Enabled:   true,
+		GoalIDs:   goalIDs,
+		Meta:      aMeta,
+		GoogleAds: aGoogleAds,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = instance.repo.InsertIntegration(anIntegration)
+	if err != nil {
+		return nil, err
+	}
+	anIntegration = anIntegration.redact()
+	return &anIntegration, nil
+}
+
+// GetAllIntegration integrations of website without credentials
+func (instance *useCase) GetAllIntegration(userID, websiteID string) ([]integration, error) {
+	integrations, err := instance.repo.GetAllIntegration(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range integrations {
+		integrations[i] = integrations[i].redact()
+	}
+	return integrations, nil
+}
+
+func (instance *useCase) UpdateEnabled(userID, websiteID, integrationID string, enabled bool) error {
+	err := instance.repo.UpdateEnabled(userID, websiteID, integrationID, enabled)
+	if err == mongo.ErrNoDocuments {
+		return ErrIntegrationNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *useCase) DeleteIntegration(userID, websiteID, integrationID string) error {
+	count, err := instance.repo.DeleteIntegration(userID, websiteID, integrationID)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrIntegrationNotFound
+	}
+	senders.Delete(integrationID)
+	return nil
+}
+
+func (instance *useCase) GetDeliveryLog(userID, websiteID, integrationID string, limit int64) ([]deliveryLog, error) {
+	logs, err := instance.repo.GetDeliveryLog(userID, websiteID, integrationID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// Forward send conversions of the form goals of website to its enabled
+// integrations, logging every delivery. Errors are logged, never returned,
+// since tracking must not fail because an ad platform does
+func (instance *useCase) Forward(userID, websiteID string, conversions []Conversion) {
+	integrations, err := instance.repo.GetEnabledIntegration(userID, websiteID)
+	if err != nil {
+		logrus.Error("get integrations error ", err)
+		return
+	}
+	if len(integrations) == 0 {
+		return
+	}
+	goals, err := instance.goalUseCase.GetAllGoal(userID, websiteID)
+	if err != nil {
+		logrus.Error("get goals error ", err)
+		return
+	}
+
+	for _, aConversion := range conversions {
+		for _, aGoal := range goals {
+			if aGoal.Type != goal.TypeForm || aGoal.Target != aConversion.FormID {
+				continue
+			}
+			conversion := adconv.Conversion{
+				EventID:   eventID(aConversion, aGoal.ID),
+				EventName: aGoal.Name,
+				Time:      time.UnixMilli(aConversion.Timestamp),
+				SourceURL: aConversion.SourceURL,
+				User:      aConversion.User,
+			}
+			for _, anIntegration := range integrations {
+				if !forwards(anIntegration, aGoal.ID) {
+					continue
+				}
+				aLog := deliveryLog{
+					IntegrationID: anIntegration.ID,
+					UserID:        userID,
+					WebsiteID:     websiteID,
+					GoalID:        aGoal.ID,
+					EventID:       conversion.EventID,
+					Status:        StatusSent,
+					CreatedAt:     time.Now(),
+				}
+				aSender, err := sender(anIntegration)
+				if err == nil {
+					err = aSender.Send(conversion)
+				}
+				if err != nil {
+					aLog.Status = StatusFailed
+					aLog.Error = err.Error()
+				}
+				if err := instance.repo.InsertDeliveryLog(aLog); err != nil {
+					logrus.Error("insert delivery log error ", err)
+				}
+			}
+		}
+	}
+}
+
+// forwards report whether integration forwards conversions of goalID
+func forwards(anIntegration integration, goalID string) bool {
+	if len(anIntegration.GoalIDs) == 0 {
+		return true
+	}
+	for _, id := range anIntegration.GoalIDs {
+		if id == goalID {
+			return true
+		}
+	}
+	return false
+}
+
+// eventID same for a conversion sent twice, so ad platforms count it once
+func eventID(aConversion Conversion, goalID string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d", aConversion.SessionID, goalID, aConversion.Timestamp)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// sender of integration, cached
+func sender(anIntegration integration) (adconv.Sender, error) {
+	if cached, ok := senders.Load(anIntegration.ID); ok {
+		return cached.(adconv.Sender), nil
+	}
+	var aSender adconv.Sender
+	switch {
+	case anIntegration.Provider == ProviderGoogleAds && anIntegration.GoogleAds != nil:
+		config := anIntegration.GoogleAds
+		aSender = adconv.NewGoogleAds(config.CustomerID, config.ConversionAction, config.LoginCustomerID,
+			config.DeveloperToken, config.ClientID, config.ClientSecret, config.RefreshToken)
+	case anIntegration.Provider == ProviderMeta && anIntegration.Meta != nil:
+		aSender = adconv.NewMeta(anIntegration.Meta.PixelID, anIntegration.Meta.AccessToken)
+	default:
+		return nil, ErrInvalidIntegration
+	}
+	cached, _ := senders.LoadOrStore(anIntegration.ID, aSender)
+	return cached.(adconv.Sender), nil
+}
@@ -0,0 +1,28 @@
+package metric
+
+import (
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPDelivery calculated metrics of websites
+type HTTPDelivery interface {
+	// This function is required for every HTTPDelivery
+	InitRoutes(r *gin.RouterGroup)
+
+	// Other functions to handle HTTP requests
+	GetAllMetric(c *gin.Context)
+	GetVariables(c *gin.Context)
+	CreateMetric(c *gin.Context)
+	DeleteMetric(c *gin.Context)
+}
+
+// NewHTTPDelivery ...
+func NewHTTPDelivery(store *db.Store) HTTPDelivery {
+	return &httpDelivery{
+		metricUseCase: NewUseCase(store),
+		authUsecase:   auth.NewUseCase(store),
+	}
+}
@@ -0,0 +1,149 @@
+package metric
+
+import (
+	"errors"
+	"net/http"
+
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/httperr"
+	"analytics-api/internal/pkg/middleware"
+	req "analytics-api/internal/pkg/request"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type httpDelivery struct {
+	metricUseCase UseCase
+	authUsecase   auth.UseCase
+}
+
+// RequestMetric ...
+type RequestMetric struct {
+	Name       string `json:"name" validate:"required,max=100"`
+	Expression string `json:"expression" validate:"required,max=200"`
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	metricRoutes := r.Group("metric")
+	{
+		metricRoutes.GET("/:website_id", middleware.JWTMiddleware(), instance.GetAllMetric)
+		metricRoutes.GET("/:website_id/variables", middleware.JWTMiddleware(), instance.GetVariables)
+		metricRoutes.POST("/:website_id", middleware.JWTMiddleware(), instance.CreateMetric)
+		metricRoutes.DELETE("/:website_id/:metric_id", middleware.JWTMiddleware(), instance.DeleteMetric)
+	}
+}
+
+func (instance *httpDelivery) GetAllMetric(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	metrics, err := instance.metricUseCase.GetAllMetric(userID, c.Param("website_id"))
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get metrics failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"metrics": metrics})
+}
+
+// GetVariables base metrics and goals the expression of a metric of the
+// website may refer to
+func (instance *httpDelivery) GetVariables(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	variables, err := instance.metricUseCase.GetVariables(userID, c.Param("website_id"))
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get metric variables failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"variables": variables})
+}
+
+// CreateMetric add a calculated metric, its expression is checked against
+// the base metrics and goals of the website
+func (instance *httpDelivery) CreateMetric(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	request, err := req.BindAndValidate[RequestMetric](c)
+	if err != nil {
+		req.BadRequest(c, "invalid metric", err)
+		return
+	}
+
+	aMetric, err := instance.metricUseCase.CreateMetric(userID, c.Param("website_id"), request.Name, request.Expression)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, aMetric)
+	case errors.Is(err, ErrInvalidMetric):
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+	case err == ErrMetricExists:
+		httperr.Abort(c, http.StatusConflict, CodeMetricExists, err.Error())
+	case err == ErrTooManyMetrics:
+		httperr.Abort(c, http.StatusConflict, CodeMetricQuota, err.Error())
+	case err == mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, website.CodeWebsiteNotFound, "this website not exists")
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "create metric failed")
+	}
+}
+
+func (instance *httpDelivery) DeleteMetric(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	err = instance.metricUseCase.DeleteMetric(userID, c.Param("website_id"), c.Param("metric_id"))
+	switch err {
+	case nil:
+		c.Status(http.StatusNoContent)
+	case ErrMetricNotFound:
+		httperr.Abort(c, http.StatusNotFound, CodeMetricNotFound, err.Error())
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "delete metric failed")
+	}
+}
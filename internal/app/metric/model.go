@@ -0,0 +1,32 @@
+package metric
+
+// metric calculated metric of a website, Expression evaluated over the base
+// metrics and the goals of the website like a built-in metric
+type metric struct {
+	ID        string `json:"id" bson:"id"`
+	UserID    string `json:"user_id" bson:"user_id"`
+	WebsiteID string `json:"website_id" bson:"website_id"`
+	Name      string `json:"name" bson:"name"`
+	// Key name as an identifier, how breakdowns and alert templates refer
+	// to the metric
+	Key        string `json:"key" bson:"key"`
+	Expression string `json:"expression" bson:"expression"`
+	CreatedAt  string `json:"created_at" bson:"created_at"`
+	UpdatedAt  string `json:"updated_at" bson:"updated_at"`
+}
+
+// Value of a calculated metric over a range, nil when a divisor of its
+// expression is zero
+type Value struct {
+	Key   string   `json:"key"`
+	Name  string   `json:"name"`
+	Value *float64 `json:"value"`
+}
+
+// variable base metric or goal a calculated metric may refer to
+type variable struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+	// Form id of the goal, empty for a base metric
+	Form string `json:"-"`
+}
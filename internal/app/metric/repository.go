@@ -0,0 +1,84 @@
+package metric
+
+import (
+	"context"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Repository ...
+type Repository interface {
+	InsertMetric(aMetric metric) error
+	GetAllMetric(userID, websiteID string) ([]metric, error)
+	GetMetric(userID, websiteID, key string, aMetric *metric) error
+	DeleteMetric(userID, websiteID, metricID string) (int64, error)
+}
+
+type repository struct {
+	store *db.Store
+}
+
+// NewRepository ...
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store: store,
+	}
+}
+
+func (instance *repository) InsertMetric(aMetric metric) error {
+	metricCollection := instance.store.Mongo.Collection(configs.MongoDB.MetricCollection)
+	_, err := metricCollection.InsertOne(context.TODO(), aMetric)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetAllMetric calculated metrics of website, in the order they were defined
+func (instance *repository) GetAllMetric(userID, websiteID string) ([]metric, error) {
+	metrics := []metric{}
+	metricCollection := instance.store.Mongo.Collection(configs.MongoDB.MetricCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+	}}
+	opts := options.Find().SetSort(bson.M{"created_at": 1})
+	cursor, err := metricCollection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &metrics); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+// GetMetric calculated metric of website by key, mongo.ErrNoDocuments when
+// there is none
+func (instance *repository) GetMetric(userID, websiteID, key string, aMetric *metric) error {
+	metricCollection := instance.store.Mongo.Collection(configs.MongoDB.MetricCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"key": key},
+	}}
+	return metricCollection.FindOne(context.TODO(), filter).Decode(aMetric)
+}
+
+func (instance *repository) DeleteMetric(userID, websiteID, metricID string) (int64, error) {
+	metricCollection := instance.store.Mongo.Collection(configs.MongoDB.MetricCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"id": metricID},
+	}}
+	result, err := metricCollection.DeleteOne(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
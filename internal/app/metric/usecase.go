@@ -0,0 +1,304 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/goal"
+	"analytics-api/internal/app/session"
+	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/formula"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MaxMetrics calculated metrics of a website
+const MaxMetrics = 20
+
+// Codes of the error responses of metrics
+const (
+	CodeMetricNotFound = "metric_not_found"
+	CodeMetricExists   = "metric_exists"
+	CodeMetricQuota    = "metric_quota_exceeded"
+)
+
+var (
+	// ErrInvalidMetric ...
+	ErrInvalidMetric = errors.New("metric needs a name and an expression over the base metrics and goals of the website")
+	// ErrMetricExists ...
+	ErrMetricExists = errors.New("the website has a metric or goal of this name already")
+	// ErrTooManyMetrics ...
+	ErrTooManyMetrics = errors.New("a website has at most 20 calculated metrics")
+	// ErrMetricNotFound ...
+	ErrMetricNotFound = errors.New("this metric not exists")
+)
+
+// baseMetrics built-in metrics expressions refer to. The tracker keeps no id
+// across sessions, so visitors are counted as sessions
+var baseMetrics = []variable{
+	{Key: "visitors", Name: "Visitors"},
+	{Key: "sessions", Name: "Sessions"},
+	{Key: "pageviews", Name: "Pageviews"},
+	{Key: "bounces", Name: "Bounces"},
+}
+
+// UseCase ...
+type UseCase interface {
+	CreateMetric(userID, websiteID, name, expression string) (*metric, error)
+	GetAllMetric(userID, websiteID string) ([]metric, error)
+	GetVariables(userID, websiteID string) ([]variable, error)
+	DeleteMetric(userID, websiteID, metricID string) error
+	Evaluate(userID, websiteID string, filter session.BreakdownFilter) ([]Value, error)
+	Get(userID, websiteID, key string, filter session.BreakdownFilter) (*Value, error)
+	Breakdown(userID, websiteID, key, dimension string, filter session.BreakdownFilter) (map[string]*float64, error)
+}
+
+type useCase struct {
+	repo           Repository
+	websiteUseCase website.UseCase
+	goalUseCase    goal.UseCase
+	sessionUseCase session.UseCase
+}
+
+// NewUseCase ...
+func NewUseCase(store *db.Store) UseCase {
+	return &useCase{
+		repo:           NewRepository(store),
+		websiteUseCase: website.NewUseCase(store),
+		goalUseCase:    goal.NewUseCase(store),
+		sessionUseCase: session.NewUseCase(store),
+	}
+}
+
+// CreateMetric add a calculated metric to website. Its expression may only
+// refer to base metrics and goals of the website, and its name must not
+// shadow one of them. mongo.ErrNoDocuments when user has no such website
+func (instance *useCase) CreateMetric(userID, websiteID, name, expression string) (*metric, error) {
+	name = strings.TrimSpace(name)
+	expression = strings.TrimSpace(expression)
+	key := formula.Identifier(name)
+	if key == "" || expression == "" {
+		return nil, ErrInvalidMetric
+	}
+	exists, err := instance.websiteUseCase.HasWebsite(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	aFormula, err := formula.Parse(expression)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMetric, err)
+	}
+	variables, err := instance.GetVariables(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, aVariable := range variables {
+		known[aVariable.Key] = true
+	}
+	for _, name := range aFormula.Variables() {
+		if !known[name] {
+			return nil, fmt.Errorf("%w: unknown metric %q", ErrInvalidMetric, name)
+		}
+	}
+	if known[key] {
+		return nil, ErrMetricExists
+	}
+	metrics, err := instance.repo.GetAllMetric(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	if len(metrics) >= MaxMetrics {
+		return nil, ErrTooManyMetrics
+	}
+	for _, aMetric := range metrics {
+		if aMetric.Key == key {
+			return nil, ErrMetricExists
+		}
+	}
+
+	now := time.Now().Format("2006-01-02, 15:04:05")
+	aMetric := metric{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		WebsiteID:  websiteID,
+		Name:       name,
+		Key:        key,
+		Expression: expression,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	err = instance.repo.InsertMetric(aMetric)
+	if err != nil {
+		return nil, err
+	}
+	return &aMetric, nil
+}
+
+func (instance *useCase) GetAllMetric(userID, websiteID string) ([]metric, error) {
+	metrics, err := instance.repo.GetAllMetric(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+// GetVariables base metrics and goals of website expressions may refer to,
+// a goal by its name as an identifier. A goal named like a base metric is
+// left out
+func (instance *useCase) GetVariables(userID, websiteID string) ([]variable, error) {
+	goals, err := instance.goalUseCase.GetAllGoal(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	variables := append([]variable{}, baseMetrics...)
+	known := map[string]bool{}
+	for _, aVariable := range baseMetrics {
+		known[aVariable.Key] = true
+	}
+	for _, aGoal := range goals {
+		key := formula.Identifier(aGoal.Name)
+		if key == "" || known[key] {
+			continue
+		}
+		known[key] = true
+		variables = append(variables, variable{Key: key, Name: aGoal.Name, Form: aGoal.Target})
+	}
+	return variables, nil
+}
+
+// DeleteMetric remove a calculated metric, alert templates on it stop
+// checking the website
+func (instance *useCase) DeleteMetric(userID, websiteID, metricID string) error {
+	count, err := instance.repo.DeleteMetric(userID, websiteID, metricID)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrMetricNotFound
+	}
+	return nil
+}
+
+// Evaluate every calculated metric of website over filter
+func (instance *useCase) Evaluate(userID, websiteID string, filter session.BreakdownFilter) ([]Value, error) {
+	metrics, err := instance.repo.GetAllMetric(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	values := []Value{}
+	if len(metrics) == 0 {
+		return values, nil
+	}
+	buckets, err := instance.evaluate(userID, websiteID, metrics, "", filter)
+	if err != nil {
+		return nil, err
+	}
+	for i, aMetric := range metrics {
+		values = append(values, Value{Key: aMetric.Key, Name: aMetric.Name, Value: buckets[""][i]})
+	}
+	return values, nil
+}
+
+// Get calculated metric key of website over filter, ErrMetricNotFound when
+// the website has no such metric
+func (instance *useCase) Get(userID, websiteID, key string, filter session.BreakdownFilter) (*Value, error) {
+	var aMetric metric
+	err := instance.repo.GetMetric(userID, websiteID, key, &aMetric)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrMetricNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	buckets, err := instance.evaluate(userID, websiteID, []metric{aMetric}, "", filter)
+	if err != nil {
+		return nil, err
+	}
+	return &Value{Key: aMetric.Key, Name: aMetric.Name, Value: buckets[""][0]}, nil
+}
+
+// Breakdown calculated metric key of website by value of dimension, a
+// session counting under the value of its first event
+func (instance *useCase) Breakdown(userID, websiteID, key, dimension string, filter session.BreakdownFilter) (map[string]*float64, error) {
+	var aMetric metric
+	err := instance.repo.GetMetric(userID, websiteID, key, &aMetric)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrMetricNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	buckets, err := instance.evaluate(userID, websiteID, []metric{aMetric}, dimension, filter)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]*float64{}
+	for key, bucket := range buckets {
+		values[key] = bucket[0]
+	}
+	return values, nil
+}
+
+// evaluate metrics for each value of dimension, in the order of metrics.
+// Goals deleted since a metric was defined count no conversion
+func (instance *useCase) evaluate(userID, websiteID string, metrics []metric, dimension string, filter session.BreakdownFilter) (map[string][]*float64, error) {
+	variables, err := instance.GetVariables(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	var forms []string
+	var goals []variable
+	for _, aVariable := range variables {
+		if aVariable.Form != "" {
+			forms = append(forms, aVariable.Form)
+			goals = append(goals, aVariable)
+		}
+	}
+	formulas := make([]*formula.Formula, len(metrics))
+	for i, aMetric := range metrics {
+		formulas[i], err = formula.Parse(aMetric.Expression)
+		if err != nil {
+			return nil, err
+		}
+	}
+	listTotals, err := instance.sessionUseCase.Totals(userID, websiteID, dimension, forms, filter)
+	if err != nil {
+		return nil, err
+	}
+	// a range without sessions still evaluates, over zeros
+	if dimension == "" && len(listTotals) == 0 {
+		listTotals = []session.Totals{{}}
+	}
+
+	buckets := map[string][]*float64{}
+	for _, aTotals := range listTotals {
+		values := map[string]float64{
+			"visitors":  float64(aTotals.Sessions),
+			"sessions":  float64(aTotals.Sessions),
+			"pageviews": float64(aTotals.Pageviews),
+			"bounces":   float64(aTotals.Bounces),
+		}
+		for i, aGoal := range goals {
+			if i < len(aTotals.Conversions) {
+				values[aGoal.Key] = float64(aTotals.Conversions[i])
+			}
+		}
+		results := make([]*float64, len(formulas))
+		for i, aFormula := range formulas {
+			if value, ok := aFormula.Eval(values); ok {
+				results[i] = &value
+			}
+		}
+		buckets[aTotals.Key] = results
+	}
+	return buckets, nil
+}
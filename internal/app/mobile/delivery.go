@@ -0,0 +1,28 @@
+package mobile
+
+import (
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPDelivery API of the companion mobile app
+type HTTPDelivery interface {
+	// This function is required for every HTTPDelivery
+	InitRoutes(r *gin.RouterGroup)
+
+	// Other functions to handle HTTP requests
+	GetOverview(c *gin.Context)
+	RegisterDevice(c *gin.Context)
+	DeleteDevice(c *gin.Context)
+	TestNotification(c *gin.Context)
+}
+
+// NewHTTPDelivery ...
+func NewHTTPDelivery(store *db.Store) HTTPDelivery {
+	return &httpDelivery{
+		mobileUseCase: NewUseCase(store),
+		authUsecase:   auth.NewUseCase(store),
+	}
+}
@@ -0,0 +1,142 @@
+package mobile
+
+import (
+	"net/http"
+
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/pkg/middleware"
+	"analytics-api/internal/pkg/push"
+	req "analytics-api/internal/pkg/request"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type httpDelivery struct {
+	mobileUseCase UseCase
+	authUsecase   auth.UseCase
+}
+
+// RequestDevice ...
+type RequestDevice struct {
+	Token    string `json:"token"`
+	Platform string `json:"platform"`
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	mobileRoutes := r.Group("mobile")
+	{
+		mobileRoutes.GET("/overview", middleware.JWTMiddleware(), instance.GetOverview)
+		mobileRoutes.POST("/devices", middleware.JWTMiddleware(), instance.RegisterDevice)
+		mobileRoutes.DELETE("/devices/:token", middleware.JWTMiddleware(), instance.DeleteDevice)
+		mobileRoutes.POST("/devices/test", middleware.JWTMiddleware(), instance.TestNotification)
+	}
+}
+
+// GetOverview headline numbers of all websites in one call
+func (instance *httpDelivery) GetOverview(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	// ?tag= sums the websites of a tag only, like those of a client
+	anOverview, err := instance.mobileUseCase.GetOverview(userID, c.Query("tag"))
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get overview failed"})
+		return
+	}
+	c.JSON(http.StatusOK, anOverview)
+}
+
+func (instance *httpDelivery) RegisterDevice(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	request, err := req.BindAndValidate[RequestDevice](c)
+	if err != nil {
+		req.BadRequest(c, "invalid device", err)
+		return
+	}
+
+	err = instance.mobileUseCase.RegisterDevice(userID, request.Token, request.Platform)
+	switch err {
+	case nil:
+		c.JSON(http.StatusCreated, gin.H{"token": request.Token, "platform": request.Platform})
+	case ErrInvalidToken, ErrInvalidPlatform:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "register device failed"})
+	}
+}
+
+func (instance *httpDelivery) DeleteDevice(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	err = instance.mobileUseCase.DeleteDevice(userID, c.Param("token"))
+	switch err {
+	case nil:
+		c.Status(http.StatusNoContent)
+	case ErrDeviceNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete device failed"})
+	}
+}
+
+// TestNotification push a test notification to all devices of user
+func (instance *httpDelivery) TestNotification(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	sent, err := instance.mobileUseCase.Notify(userID, push.Notification{
+		Title: "Test notification",
+		Body:  "Push notifications are working",
+	})
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "send notification failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sent": sent})
+}
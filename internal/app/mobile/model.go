@@ -0,0 +1,35 @@
+package mobile
+
+import (
+	"analytics-api/internal/app/benchmark"
+	"analytics-api/internal/app/metric"
+)
+
+// device push token of the mobile app registered by an user
+type device struct {
+	Token     string `json:"token" bson:"token"`
+	UserID    string `json:"user_id" bson:"user_id"`
+	Platform  string `json:"platform" bson:"platform"`
+	CreatedAt string `json:"created_at" bson:"created_at"`
+	UpdatedAt string `json:"updated_at" bson:"updated_at"`
+}
+
+// websiteOverview headline numbers of a website
+type websiteOverview struct {
+	ID            string `json:"id"`
+	HostName      string `json:"host_name"`
+	SessionsToday int64  `json:"sessions_today"`
+	SessionsWeek  int64  `json:"sessions_week"`
+	// Metrics calculated metrics of the website over the last 7 days
+	Metrics []metric.Value `json:"metrics"`
+	// Benchmark the website next to the median of its category, when the
+	// instance computes benchmarks
+	Benchmark *benchmark.Comparison `json:"benchmark,omitempty"`
+}
+
+// overview headline numbers of all websites of an user
+type overview struct {
+	SessionsToday int64             `json:"sessions_today"`
+	SessionsWeek  int64             `json:"sessions_week"`
+	Websites      []websiteOverview `json:"websites"`
+}
@@ -0,0 +1,51 @@
+package mobile
+
+import (
+	"os"
+	"sync"
+
+	"analytics-api/configs"
+	"analytics-api/internal/pkg/push"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// PlatformAndroid devices notified through FCM
+	PlatformAndroid = "android"
+	// PlatformIOS devices notified through APNs
+	PlatformIOS = "ios"
+)
+
+var (
+	sendersOnce sync.Once
+	senders     map[string]push.Sender
+)
+
+// platformSenders push senders configured for each platform, shared by all tenants
+func platformSenders() map[string]push.Sender {
+	sendersOnce.Do(func() {
+		senders = map[string]push.Sender{}
+		if configs.Push.FCMCredentialsFile != "" {
+			credentials, err := os.ReadFile(configs.Push.FCMCredentialsFile)
+			if err != nil {
+				logrus.Error("read fcm credentials error ", err)
+			} else if fcm, err := push.NewFCM(credentials); err != nil {
+				logrus.Error("load fcm credentials error ", err)
+			} else {
+				senders[PlatformAndroid] = fcm
+			}
+		}
+		if configs.Push.APNsKeyFile != "" {
+			key, err := os.ReadFile(configs.Push.APNsKeyFile)
+			if err != nil {
+				logrus.Error("read apns key error ", err)
+			} else if apns, err := push.NewAPNs(key, configs.Push.APNsKeyID, configs.Push.APNsTeamID, configs.Push.APNsTopic, configs.Push.APNsSandbox); err != nil {
+				logrus.Error("load apns key error ", err)
+			} else {
+				senders[PlatformIOS] = apns
+			}
+		}
+	})
+	return senders
+}
@@ -0,0 +1,90 @@
+package mobile
+
+import (
+	"context"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Repository ...
+type Repository interface {
+	UpsertDevice(aDevice device) error
+	DeleteDevice(userID, token string) (int64, error)
+	DeleteDeviceByToken(token string) error
+	GetAllDevice(userID string) ([]device, error)
+}
+
+type repository struct {
+	store *db.Store
+}
+
+// NewRepository ...
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store: store,
+	}
+}
+
+// UpsertDevice register token, a token registered again moves to the new user
+func (instance *repository) UpsertDevice(aDevice device) error {
+	deviceCollection := instance.store.Mongo.Collection(configs.MongoDB.DeviceCollection)
+	update := bson.M{
+		"$set": bson.M{
+			"user_id":    aDevice.UserID,
+			"platform":   aDevice.Platform,
+			"updated_at": aDevice.UpdatedAt,
+		},
+		"$setOnInsert": bson.M{
+			"created_at": aDevice.CreatedAt,
+		},
+	}
+	opts := options.Update().SetUpsert(true)
+	_, err := deviceCollection.UpdateOne(context.TODO(), bson.M{"token": aDevice.Token}, update, opts)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) DeleteDevice(userID, token string) (int64, error) {
+	deviceCollection := instance.store.Mongo.Collection(configs.MongoDB.DeviceCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"token": token},
+	}}
+	result, err := deviceCollection.DeleteOne(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// DeleteDeviceByToken remove a token the push service no longer accepts
+func (instance *repository) DeleteDeviceByToken(token string) error {
+	deviceCollection := instance.store.Mongo.Collection(configs.MongoDB.DeviceCollection)
+	_, err := deviceCollection.DeleteOne(context.TODO(), bson.M{"token": token})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) GetAllDevice(userID string) ([]device, error) {
+	var devices []device
+	deviceCollection := instance.store.Mongo.Collection(configs.MongoDB.DeviceCollection)
+	cursor, err := deviceCollection.Find(context.TODO(), bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	err = cursor.All(context.TODO(), &devices)
+	if err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
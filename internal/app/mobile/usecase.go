@@ -0,0 +1,167 @@
+package mobile
+
+import (
+	"errors"
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/benchmark"
+	"analytics-api/internal/app/metric"
+	"analytics-api/internal/app/session"
+	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/push"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrInvalidPlatform ...
+	ErrInvalidPlatform = errors.New("platform must be android or ios")
+	// ErrInvalidToken ...
+	ErrInvalidToken = errors.New("device token is required")
+	// ErrDeviceNotFound ...
+	ErrDeviceNotFound = errors.New("this device not exists")
+)
+
+// UseCase ...
+type UseCase interface {
+	GetOverview(userID, tag string) (*overview, error)
+	RegisterDevice(userID, token, platform string) error
+	DeleteDevice(userID, token string) error
+	Notify(userID string, notification push.Notification) (int, error)
+}
+
+type useCase struct {
+	repo             Repository
+	websiteUseCase   website.UseCase
+	sessionUseCase   session.UseCase
+	benchmarkUseCase benchmark.UseCase
+	metricUseCase    metric.UseCase
+	senders          map[string]push.Sender
+}
+
+// NewUseCase ...
+func NewUseCase(store *db.Store) UseCase {
+	return &useCase{
+		repo:             NewRepository(store),
+		websiteUseCase:   website.NewUseCase(store),
+		sessionUseCase:   session.NewUseCase(store),
+		benchmarkUseCase: benchmark.NewUseCase(store),
+		metricUseCase:    metric.NewUseCase(store),
+		senders:          platformSenders(),
+	}
+}
+
+// GetOverview count sessions of today and of the last 7 days of each website
+// carrying tag, all of them when empty, with its calculated metrics over the
+// 7 days and the benchmark of its category when the instance computes them
+func (instance *useCase) GetOverview(userID, tag string) (*overview, error) {
+	websites, err := instance.websiteUseCase.GetTaggedWebsite(userID, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	today := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 0, 0, 0, 0, time.UTC)
+	week := today.AddDate(0, 0, -6)
+
+	// the sessions of all websites are counted in one query
+	var websiteIDs []string
+	for _, aWebsite := range *websites {
+		websiteIDs = append(websiteIDs, aWebsite.ID)
+	}
+	counts, err := instance.sessionUseCase.CountSessionsSince(userID, websiteIDs, []time.Time{today, week})
+	if err != nil {
+		return nil, err
+	}
+
+	anOverview := &overview{Websites: []websiteOverview{}}
+	for _, aWebsite := range *websites {
+		var sessionsToday, sessionsWeek int64
+		if count, ok := counts[aWebsite.ID]; ok {
+			sessionsToday, sessionsWeek = count[0], count[1]
+		}
+		metrics, err := instance.metricUseCase.Evaluate(userID, aWebsite.ID, session.BreakdownFilter{From: week, To: time.Now()})
+		if err != nil {
+			return nil, err
+		}
+		aComparison, err := instance.benchmarkUseCase.Compare(userID, aWebsite.ID, aWebsite.Category)
+		if err != nil {
+			return nil, err
+		}
+		anOverview.SessionsToday += sessionsToday
+		anOverview.SessionsWeek += sessionsWeek
+		anOverview.Websites = append(anOverview.Websites, websiteOverview{
+			ID:            aWebsite.ID,
+			HostName:      aWebsite.HostName,
+			SessionsToday: sessionsToday,
+			SessionsWeek:  sessionsWeek,
+			Metrics:       metrics,
+			Benchmark:     aComparison,
+		})
+	}
+	return anOverview, nil
+}
+
+func (instance *useCase) RegisterDevice(userID, token, platform string) error {
+	if token == "" {
+		return ErrInvalidToken
+	}
+	if platform != PlatformAndroid && platform != PlatformIOS {
+		return ErrInvalidPlatform
+	}
+
+	now := time.Now().Format("2006-01-02, 15:04:05")
+	err := instance.repo.UpsertDevice(device{
+		Token:     token,
+		UserID:    userID,
+		Platform:  platform,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *useCase) DeleteDevice(userID, token string) error {
+	count, err := instance.repo.DeleteDevice(userID, token)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+// Notify push notification to all devices of user, tokens rejected by the
+// push service are removed. Returns the number of devices notified
+func (instance *useCase) Notify(userID string, notification push.Notification) (int, error) {
+	devices, err := instance.repo.GetAllDevice(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, aDevice := range devices {
+		sender, ok := instance.senders[aDevice.Platform]
+		if !ok {
+			logrus.Debug("push is not configured for platform ", aDevice.Platform)
+			continue
+		}
+		err := sender.Send(aDevice.Token, notification)
+		if errors.Is(err, push.ErrUnregistered) {
+			if err := instance.repo.DeleteDeviceByToken(aDevice.Token); err != nil {
+				logrus.Error("delete unregistered device error ", err)
+			}
+			continue
+		}
+		if err != nil {
+			logrus.Error("push to ", aDevice.Platform, " device error ", err)
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
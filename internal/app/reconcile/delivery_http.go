@@ -0,0 +1,56 @@
+package reconcile
+
+import (
+	"net/http"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/pkg/cursor"
+	"analytics-api/internal/pkg/middleware"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type httpDelivery struct {
+	reconcileUseCase UseCase
+	authUsecase      auth.UseCase
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	reconcileRoutes := r.Group("reconciliation")
+	{
+		reconcileRoutes.GET("/:website_id", middleware.JWTMiddleware(), instance.GetReport)
+	}
+}
+
+// GetReport daily counts of events sent and stored of a website, the last 7
+// days by default and at most RetentionDays
+func (instance *httpDelivery) GetReport(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	days := cursor.Limit(c.Query("days"), 7, db.RetentionDays)
+	aReport, err := instance.reconcileUseCase.GetReport(userID, c.Param("website_id"), days)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, aReport)
+	case mongo.ErrNoDocuments:
+		c.JSON(http.StatusNotFound, gin.H{"error": "this website not exists"})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get reconciliation failed"})
+	}
+}
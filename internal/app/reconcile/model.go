@@ -0,0 +1,65 @@
+package reconcile
+
+import "time"
+
+// day ingestion counts of a website during a UTC day, by the time batches
+// arrived
+type day struct {
+	UserID    string    `json:"-" bson:"user_id"`
+	WebsiteID string    `json:"-" bson:"website_id"`
+	Day       time.Time `json:"day" bson:"day"`
+	Batches   int64     `json:"batches" bson:"batches"`
+	// Sent events the SDKs said their batches hold, Received those found in
+	// the batches. Batches without an event count sent what they hold
+	Sent     int64 `json:"sent" bson:"sent"`
+	Received int64 `json:"received" bson:"received"`
+	// Rejected events outside the accepted time window or dropped past the
+	// monthly quota, not stored on purpose
+	Rejected int64 `json:"rejected" bson:"rejected"`
+	Stored   int64 `json:"stored" bson:"stored"`
+	// CorruptBatches batches refused for a wrong checksum or event count, the
+	// SDK is told to send them again so their events are not counted
+	CorruptBatches int64 `json:"corrupt_batches" bson:"corrupt_batches"`
+	// Lost sent events neither stored nor rejected, computed when read
+	Lost int64 `json:"lost" bson:"-"`
+	// Late events of the day, by when they happened unlike the counts
+	// above, that arrived after it ended within the late arrival window and
+	// were merged into its rollups. LateFlagged those arriving past the
+	// window, stored flagged but left out
+	Late        int64 `json:"late" bson:"late"`
+	LateFlagged int64 `json:"late_flagged" bson:"late_flagged"`
+	// LateAt when the last late event merged into the day arrived
+	LateAt string `json:"-" bson:"late_at,omitempty"`
+}
+
+// report daily counts of a website, oldest day first
+type report struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Days  []day  `json:"days"`
+	Total day    `json:"total"`
+}
+
+// Late events of a day arriving after it ended, Merged within the late
+// arrival window and Flagged past it
+type Late struct {
+	Merged  int64
+	Flagged int64
+}
+
+// LateDay day of a website with late events merged at LateAt
+type LateDay struct {
+	UserID    string    `bson:"user_id"`
+	WebsiteID string    `bson:"website_id"`
+	Day       time.Time `bson:"day"`
+	LateAt    string    `bson:"late_at"`
+}
+
+// Batch outcome of an ingestion batch of a website
+type Batch struct {
+	Sent     int64
+	Received int64
+	Rejected int64
+	Stored   int64
+	Corrupt  bool
+}
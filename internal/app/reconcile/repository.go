@@ -0,0 +1,112 @@
+package reconcile
+
+import (
+	"context"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Repository ...
+type Repository interface {
+	IncDay(userID, websiteID string, aDay time.Time, aBatch Batch) error
+	GetDay(userID, websiteID string, from time.Time) ([]day, error)
+	IncLate(userID, websiteID string, aDay time.Time, aLate Late, lateAt string) error
+	GetLateDays(since string) ([]LateDay, error)
+}
+
+type repository struct {
+	store *db.Store
+}
+
+// NewRepository ...
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store: store,
+	}
+}
+
+// IncDay add the counts of aBatch to those of its website and day
+func (instance *repository) IncDay(userID, websiteID string, aDay time.Time, aBatch Batch) error {
+	reconciliationCollection := instance.store.Mongo.Collection(configs.MongoDB.ReconciliationCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"day": aDay},
+	}}
+	inc := bson.M{"batches": 1}
+	if aBatch.Corrupt {
+		inc["corrupt_batches"] = 1
+	} else {
+		inc["sent"] = aBatch.Sent
+		inc["received"] = aBatch.Received
+		inc["rejected"] = aBatch.Rejected
+		inc["stored"] = aBatch.Stored
+	}
+	update := bson.M{"$inc": inc}
+	_, err := reconciliationCollection.UpdateOne(context.TODO(), filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetDay daily counts of website since from, oldest first
+func (instance *repository) GetDay(userID, websiteID string, from time.Time) ([]day, error) {
+	days := []day{}
+	reconciliationCollection := instance.store.Mongo.Collection(configs.MongoDB.ReconciliationCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"day": bson.M{"$gte": from}},
+	}}
+	opts := options.Find().SetSort(primitive.D{{Key: "day", Value: 1}})
+	cursor, err := reconciliationCollection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &days); err != nil {
+		return nil, err
+	}
+	return days, nil
+}
+
+// IncLate add the late events of aLate to those of their website and day,
+// merged ones set when the day last changed to lateAt
+func (instance *repository) IncLate(userID, websiteID string, aDay time.Time, aLate Late, lateAt string) error {
+	reconciliationCollection := instance.store.Mongo.Collection(configs.MongoDB.ReconciliationCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"day": aDay},
+	}}
+	update := bson.M{"$inc": bson.M{"late": aLate.Merged, "late_flagged": aLate.Flagged}}
+	if aLate.Merged > 0 {
+		update["$set"] = bson.M{"late_at": lateAt}
+	}
+	_, err := reconciliationCollection.UpdateOne(context.TODO(), filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetLateDays days of every website of the store with late events merged at
+// since or after
+func (instance *repository) GetLateDays(since string) ([]LateDay, error) {
+	days := []LateDay{}
+	reconciliationCollection := instance.store.Mongo.Collection(configs.MongoDB.ReconciliationCollection)
+	cursor, err := reconciliationCollection.Find(context.TODO(), bson.M{"late_at": bson.M{"$gte": since}})
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &days); err != nil {
+		return nil, err
+	}
+	return days, nil
+}
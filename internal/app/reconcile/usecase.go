@@ -0,0 +1,127 @@
+package reconcile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/website"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrChecksumMismatch ...
+var ErrChecksumMismatch = errors.New("checksum does not match the events of the batch")
+
+// ErrCountMismatch ...
+var ErrCountMismatch = errors.New("event_count does not match the events of the batch")
+
+const dateLayout = "2006-01-02"
+
+// UseCase ...
+type UseCase interface {
+	Record(userID, websiteID string, aBatch Batch)
+	GetReport(userID, websiteID string, days int) (*report, error)
+	RecordLate(userID, websiteID string, days map[time.Time]Late)
+	GetLateDays(since time.Time) ([]LateDay, error)
+}
+
+type useCase struct {
+	repo           Repository
+	websiteUseCase website.UseCase
+}
+
+// NewUseCase ...
+func NewUseCase(store *db.Store) UseCase {
+	return &useCase{
+		repo:           NewRepository(store),
+		websiteUseCase: website.NewUseCase(store),
+	}
+}
+
+// Verify check a batch holds what the SDK says it sent. events is the json
+// array of the batch as received, checksum the hex SHA-256 of it. Both the
+// count and the checksum are optional
+func Verify(events []byte, received int, eventCount *int, checksum string) error {
+	if eventCount != nil && *eventCount != received {
+		return ErrCountMismatch
+	}
+	if checksum == "" {
+		return nil
+	}
+	sum := sha256.Sum256(events)
+	if !strings.EqualFold(checksum, hex.EncodeToString(sum[:])) {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// Record count aBatch in the day it arrived. Errors are logged, never
+// returned, since tracking must not fail because counting does
+func (instance *useCase) Record(userID, websiteID string, aBatch Batch) {
+	aDay := time.Now().UTC().Truncate(24 * time.Hour)
+	err := instance.repo.IncDay(userID, websiteID, aDay, aBatch)
+	if err != nil {
+		logrus.Error("record reconciliation error ", err)
+	}
+}
+
+// RecordLate count the late events of website in the days they happened in.
+// Errors are logged, never returned, like Record
+func (instance *useCase) RecordLate(userID, websiteID string, days map[time.Time]Late) {
+	lateAt := time.Now().Format("2006-01-02, 15:04:05")
+	for aDay, aLate := range days {
+		err := instance.repo.IncLate(userID, websiteID, aDay.UTC().Truncate(24*time.Hour), aLate, lateAt)
+		if err != nil {
+			logrus.Error("record late events error ", err)
+		}
+	}
+}
+
+// GetLateDays days of every website with late events merged since, for
+// the rollups kept apart from the events to follow them
+func (instance *useCase) GetLateDays(since time.Time) ([]LateDay, error) {
+	return instance.repo.GetLateDays(since.Format("2006-01-02, 15:04:05"))
+}
+
+// GetReport daily counts of website over the last days, today included
+func (instance *useCase) GetReport(userID, websiteID string, days int) (*report, error) {
+	count, err := instance.websiteUseCase.FindWebsiteByID(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, -(days - 1))
+	listDay, err := instance.repo.GetDay(userID, websiteID, from)
+	if err != nil {
+		return nil, err
+	}
+
+	aReport := &report{
+		From: from.Format(dateLayout),
+		To:   today.Format(dateLayout),
+		Days: listDay,
+	}
+	for i := range aReport.Days {
+		aDay := &aReport.Days[i]
+		aDay.Lost = aDay.Sent - aDay.Stored - aDay.Rejected
+		aReport.Total.Batches += aDay.Batches
+		aReport.Total.Sent += aDay.Sent
+		aReport.Total.Received += aDay.Received
+		aReport.Total.Rejected += aDay.Rejected
+		aReport.Total.Stored += aDay.Stored
+		aReport.Total.CorruptBatches += aDay.CorruptBatches
+		aReport.Total.Lost += aDay.Lost
+		aReport.Total.Late += aDay.Late
+		aReport.Total.LateFlagged += aDay.LateFlagged
+	}
+	return aReport, nil
+}
@@ -0,0 +1,27 @@
+package reingest
+
+import (
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPDelivery corrections of the stored events of websites
+type HTTPDelivery interface {
+	// This function is required for every HTTPDelivery
+	InitRoutes(r *gin.RouterGroup)
+
+	// Other functions to handle HTTP requests
+	CreateJob(c *gin.Context)
+	GetAllJob(c *gin.Context)
+	GetJob(c *gin.Context)
+}
+
+// NewHTTPDelivery ...
+func NewHTTPDelivery(store *db.Store) HTTPDelivery {
+	return &httpDelivery{
+		reingestUseCase: NewUseCase(store),
+		authUsecase:     auth.NewUseCase(store),
+	}
+}
@@ -0,0 +1,120 @@
+package reingest
+
+import (
+	"net/http"
+
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/app/session"
+	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/httperr"
+	"analytics-api/internal/pkg/middleware"
+	req "analytics-api/internal/pkg/request"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type httpDelivery struct {
+	reingestUseCase UseCase
+	authUsecase     auth.UseCase
+}
+
+// RequestReingest corrected versions of stored events, matched by session
+// and idempotency key
+type RequestReingest struct {
+	Events []session.Correction `json:"events" validate:"required,min=1,max=1000,dive"`
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	reingestRoutes := r.Group("reingest")
+	{
+		reingestRoutes.POST("/:website_id", middleware.JWTMiddleware(), instance.CreateJob)
+		reingestRoutes.GET("/:website_id", middleware.JWTMiddleware(), instance.GetAllJob)
+		reingestRoutes.GET("/:website_id/:job_id", middleware.JWTMiddleware(), instance.GetJob)
+	}
+}
+
+// CreateJob queue corrected events, they replace the stored ones in the
+// background
+func (instance *httpDelivery) CreateJob(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	request, err := req.BindAndValidate[RequestReingest](c)
+	if err != nil {
+		req.BadRequest(c, "invalid corrected events", err)
+		return
+	}
+
+	aJob, err := instance.reingestUseCase.CreateJob(userID, c.Param("website_id"), request.Events)
+	switch err {
+	case nil:
+		c.JSON(http.StatusAccepted, aJob)
+	case ErrDuplicateKey, ErrInvalidTimestamp:
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, website.CodeWebsiteNotFound, "this website not exists")
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "create re-ingestion job failed")
+	}
+}
+
+func (instance *httpDelivery) GetAllJob(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	jobs, err := instance.reingestUseCase.GetAllJob(userID, c.Param("website_id"))
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get re-ingestion jobs failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+func (instance *httpDelivery) GetJob(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	aJob, err := instance.reingestUseCase.GetJob(userID, c.Param("website_id"), c.Param("job_id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, aJob)
+	case ErrJobNotFound:
+		httperr.Abort(c, http.StatusNotFound, CodeJobNotFound, err.Error())
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get re-ingestion job failed")
+	}
+}
@@ -0,0 +1,20 @@
+package reingest
+
+import (
+	"time"
+
+	"analytics-api/db"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RunReingest run the pending jobs of store every interval until the
+// process exits
+func RunReingest(store *db.Store, interval time.Duration) {
+	useCase := NewUseCase(store)
+	for range time.Tick(interval) {
+		if _, err := useCase.RunJobs(); err != nil {
+			logrus.Error("run re-ingestion jobs error ", err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package reingest
+
+import (
+	"testing"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/db/dbtest"
+)
+
+func TestLitePendingWebsites(t *testing.T) {
+	mongoStore, liteStore := dbtest.Stores(t)
+	collection := configs.MongoDB.ReingestCollection
+	configs.MongoDB.ReingestCollection = "reingest"
+	t.Cleanup(func() { configs.MongoDB.ReingestCollection = collection })
+
+	dbtest.Insert(t, configs.MongoDB.ReingestCollection, []interface{}{
+		job{ID: "j1", UserID: "u1", WebsiteID: "w1", Status: StatusPending, CreatedAt: "2024-03-15T10:00:00Z"},
+		job{ID: "j2", UserID: "u1", WebsiteID: "w1", Status: StatusPending, CreatedAt: "2024-03-15T10:05:00Z"},
+		job{ID: "j3", UserID: "u1", WebsiteID: "w2", Status: StatusRunning, CreatedAt: "2024-03-15T09:00:00Z", StartedAt: "2024-03-15T09:01:00Z"},
+		job{ID: "j4", UserID: "u2", WebsiteID: "w3", Status: StatusRunning, CreatedAt: "2024-03-15T11:00:00Z", StartedAt: "2024-03-15T11:50:00Z"},
+		job{ID: "j5", UserID: "u2", WebsiteID: "w3", Status: StatusPending, CreatedAt: "2024-03-15T11:55:00Z"},
+		job{ID: "j6", UserID: "u2", WebsiteID: "w4", Status: StatusDone, CreatedAt: "2024-03-15T08:00:00Z"},
+	}, mongoStore, liteStore)
+
+	tests := []struct {
+		name        string
+		staleBefore string
+	}{
+		{name: "should count pending jobs and those left running by a worker that stopped", staleBefore: "2024-03-15T11:00:00Z"},
+		{name: "should count every running job once stale", staleBefore: "2024-03-15T12:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbtest.Compare(t, mongoStore, liteStore, true, func(store *db.Store) (interface{}, error) {
+				return (&repository{store: store}).PendingWebsites(tt.staleBefore)
+			})
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package reingest
+
+import (
+	"analytics-api/internal/app/session"
+)
+
+// Statuses of a job
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// job corrected events of a website waiting to replace the stored ones, and
+// once done what they replaced
+type job struct {
+	ID          string               `json:"id" bson:"id"`
+	UserID      string               `json:"-" bson:"user_id"`
+	WebsiteID   string               `json:"website_id" bson:"website_id"`
+	Status      string               `json:"status" bson:"status"`
+	Corrections []session.Correction `json:"-" bson:"corrections"`
+	Events      int                  `json:"events" bson:"events"`
+	// Replaced events matched by key, Missing the keys of those not stored
+	Replaced int      `json:"replaced" bson:"replaced"`
+	Missing  []string `json:"missing,omitempty" bson:"missing,omitempty"`
+	// Days reported on before or after the corrections, in UTC, whose
+	// archive is marked dirty
+	Days       []string `json:"days,omitempty" bson:"days,omitempty"`
+	Error      string   `json:"error,omitempty" bson:"error,omitempty"`
+	CreatedAt  string   `json:"created_at" bson:"created_at"`
+	StartedAt  string   `json:"started_at,omitempty" bson:"started_at,omitempty"`
+	FinishedAt string   `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+}
+
+// pendingWebsite website with jobs waiting for a worker
+type pendingWebsite struct {
+	UserID    string `bson:"user_id"`
+	WebsiteID string `bson:"website_id"`
+	Jobs      int    `bson:"jobs"`
+}
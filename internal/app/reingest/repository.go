@@ -0,0 +1,146 @@
+package reingest
+
+import (
+	"context"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Repository ...
+type Repository interface {
+	InsertJob(aJob job) error
+	GetJob(userID, websiteID, jobID string, aJob *job) error
+	GetAllJob(userID, websiteID string, limit int64) ([]job, error)
+	PendingWebsites(staleBefore string) ([]pendingWebsite, error)
+	ClaimJob(userID, websiteID, startedAt, staleBefore string, aJob *job) error
+	FinishJob(aJob job) error
+}
+
+type repository struct {
+	store *db.Store
+}
+
+// NewRepository ...
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store: store,
+	}
+}
+
+func (instance *repository) InsertJob(aJob job) error {
+	jobCollection := instance.store.Mongo.Collection(configs.MongoDB.ReingestCollection)
+	_, err := jobCollection.InsertOne(context.TODO(), aJob)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) GetJob(userID, websiteID, jobID string, aJob *job) error {
+	jobCollection := instance.store.Mongo.Collection(configs.MongoDB.ReingestCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"id": jobID},
+	}}
+	err := jobCollection.FindOne(context.TODO(), filter).Decode(aJob)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetAllJob latest jobs of website, newest first
+func (instance *repository) GetAllJob(userID, websiteID string, limit int64) ([]job, error) {
+	jobs := []job{}
+	jobCollection := instance.store.Mongo.Collection(configs.MongoDB.ReingestCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+	}}
+	opts := options.Find().SetSort(primitive.D{{Key: "created_at", Value: -1}}).SetLimit(limit).
+		SetProjection(bson.M{"corrections": 0})
+	cursor, err := jobCollection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// runnable jobs pending, or left running since before staleBefore by a
+// worker that stopped
+func runnable(staleBefore string) bson.M {
+	return bson.M{"$or": []bson.M{
+		{"status": StatusPending},
+		{"status": StatusRunning, "started_at": bson.M{"$lt": staleBefore}},
+	}}
+}
+
+// PendingWebsites websites with jobs to run and how many
+func (instance *repository) PendingWebsites(staleBefore string) ([]pendingWebsite, error) {
+	jobCollection := instance.store.Mongo.Collection(configs.MongoDB.ReingestCollection)
+	pipeline := []bson.M{
+		{"$match": runnable(staleBefore)},
+		{"$group": bson.M{
+			"_id":  bson.M{"user_id": "$user_id", "website_id": "$website_id"},
+			"jobs": bson.M{"$sum": 1},
+		}},
+		{"$project": bson.M{"_id": 0, "user_id": "$_id.user_id", "website_id": "$_id.website_id", "jobs": 1}},
+	}
+	cursor, err := db.Aggregate(context.TODO(), jobCollection, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var websites []pendingWebsite
+	if err = cursor.All(context.TODO(), &websites); err != nil {
+		return nil, err
+	}
+	return websites, nil
+}
+
+// ClaimJob mark the oldest runnable job of website running from startedAt.
+// mongo.ErrNoDocuments when there is none
+func (instance *repository) ClaimJob(userID, websiteID, startedAt, staleBefore string, aJob *job) error {
+	jobCollection := instance.store.Mongo.Collection(configs.MongoDB.ReingestCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		runnable(staleBefore),
+	}}
+	update := bson.M{"$set": bson.M{"status": StatusRunning, "started_at": startedAt}}
+	opts := options.FindOneAndUpdate().SetSort(primitive.D{{Key: "created_at", Value: 1}}).SetReturnDocument(options.After)
+	err := jobCollection.FindOneAndUpdate(context.TODO(), filter, update, opts).Decode(aJob)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// FinishJob store the outcome of aJob, its corrections are dropped
+func (instance *repository) FinishJob(aJob job) error {
+	jobCollection := instance.store.Mongo.Collection(configs.MongoDB.ReingestCollection)
+	update := bson.M{
+		"$set": bson.M{
+			"status":      aJob.Status,
+			"replaced":    aJob.Replaced,
+			"missing":     aJob.Missing,
+			"days":        aJob.Days,
+			"error":       aJob.Error,
+			"finished_at": aJob.FinishedAt,
+		},
+		"$unset": bson.M{"corrections": ""},
+	}
+	_, err := jobCollection.UpdateOne(context.TODO(), bson.M{"id": aJob.ID}, update)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package reingest
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/app/archive"
+	"analytics-api/internal/app/session"
+	"analytics-api/internal/app/user"
+	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/eventtime"
+	"analytics-api/internal/pkg/fairqueue"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MaxEvents corrected events of a job
+const MaxEvents = 1000
+
+// staleAfter time after which a job still running is taken for one whose
+// worker stopped, and run again. Replacing an event twice is harmless
+const staleAfter = time.Hour
+
+// listLimit jobs listed
+const listLimit = 50
+
+// CodeJobNotFound ...
+const CodeJobNotFound = "reingest_job_not_found"
+
+var (
+	// ErrJobNotFound ...
+	ErrJobNotFound = errors.New("this re-ingestion job not exists")
+	// ErrDuplicateKey ...
+	ErrDuplicateKey = errors.New("an event is corrected twice in the job")
+	// ErrInvalidTimestamp ...
+	ErrInvalidTimestamp = errors.New("a corrected event is in the future or older than retention")
+)
+
+// UseCase ...
+type UseCase interface {
+	CreateJob(userID, websiteID string, corrections []session.Correction) (*job, error)
+	GetJob(userID, websiteID, jobID string) (*job, error)
+	GetAllJob(userID, websiteID string) ([]job, error)
+	RunJobs() (int, error)
+}
+
+type useCase struct {
+	repo           Repository
+	sessionUseCase session.UseCase
+	websiteUseCase website.UseCase
+	archiveUseCase archive.UseCase
+	userUseCase    user.UseCase
+	pool           *fairqueue.Pool
+}
+
+// NewUseCase ...
+func NewUseCase(store *db.Store) UseCase {
+	return &useCase{
+		repo:           NewRepository(store),
+		sessionUseCase: session.NewUseCase(store),
+		websiteUseCase: website.NewUseCase(store),
+		archiveUseCase: archive.NewUseCase(store),
+		userUseCase:    user.NewUseCase(store),
+		pool:           fairqueue.NewOrdered("reingest", configs.Workers),
+	}
+}
+
+// CreateJob queue corrections of events of website, mongo.ErrNoDocuments
+// when the user has no such website. Each event is corrected once a job
+func (instance *useCase) CreateJob(userID, websiteID string, corrections []session.Correction) (*job, error) {
+	exists, err := instance.websiteUseCase.HasWebsite(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, mongo.ErrNoDocuments
+	}
+	now := time.Now()
+	retention := time.Duration(db.RetentionDays) * 24 * time.Hour
+	keys := map[string]bool{}
+	for _, aCorrection := range corrections {
+		key := aCorrection.SessionID + "\n" + aCorrection.ID
+		if keys[key] {
+			return nil, ErrDuplicateKey
+		}
+		keys[key] = true
+		if !eventtime.Valid(aCorrection.Timestamp, now, retention) {
+			return nil, ErrInvalidTimestamp
+		}
+	}
+
+	aJob := job{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		WebsiteID:   websiteID,
+		Status:      StatusPending,
+		Corrections: corrections,
+		Events:      len(corrections),
+		CreatedAt:   now.Format("2006-01-02, 15:04:05"),
+	}
+	err = instance.repo.InsertJob(aJob)
+	if err != nil {
+		return nil, err
+	}
+	return &aJob, nil
+}
+
+// GetJob ErrJobNotFound when website has no such job
+func (instance *useCase) GetJob(userID, websiteID, jobID string) (*job, error) {
+	var aJob job
+	err := instance.repo.GetJob(userID, websiteID, jobID, &aJob)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &aJob, nil
+}
+
+// GetAllJob latest jobs of website, newest first
+func (instance *useCase) GetAllJob(userID, websiteID string) ([]job, error) {
+	return instance.repo.GetAllJob(userID, websiteID, listLimit)
+}
+
+// RunJobs run the pending jobs until none is left, on the workers of the
+// pool: the jobs of a website in order, websites fairly by plan. Returns how
+// many ran
+func (instance *useCase) RunJobs() (int, error) {
+	aRun := &run{}
+	for {
+		now := time.Now()
+		websites, err := instance.repo.PendingWebsites(now.Add(-staleAfter).Format("2006-01-02, 15:04:05"))
+		if err != nil {
+			return aRun.count, err
+		}
+		if len(websites) == 0 {
+			return aRun.count, aRun.lastErr
+		}
+		for _, aWebsite := range websites {
+			aWebsite := aWebsite
+			weight, err := instance.userUseCase.WorkerWeight(aWebsite.UserID)
+			if err != nil {
+				logrus.Error("get plan of ", aWebsite.UserID, " error ", err)
+				weight = 1
+			}
+			for i := 0; i < aWebsite.Jobs; i++ {
+				instance.pool.Submit(aWebsite.WebsiteID, weight, func() error {
+					ran, err := instance.runJob(aWebsite.UserID, aWebsite.WebsiteID)
+					aRun.done(ran, err)
+					return err
+				})
+			}
+		}
+		instance.pool.Wait()
+		// a job whose outcome cannot be stored would be claimed again
+		if aRun.lastErr != nil {
+			return aRun.count, aRun.lastErr
+		}
+	}
+}
+
+// run outcome of the jobs of one RunJobs
+type run struct {
+	mu      sync.Mutex
+	count   int
+	lastErr error
+}
+
+func (instance *run) done(ran bool, err error) {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	if err != nil {
+		instance.lastErr = err
+	}
+	if ran {
+		instance.count++
+	}
+}
+
+// runJob replace the events of the oldest pending job of website and mark
+// the archived days they were or are now reported on dirty, false when
+// there was none. A job failing is recorded failed, the error is only
+// returned when its outcome cannot be stored
+func (instance *useCase) runJob(userID, websiteID string) (bool, error) {
+	now := time.Now()
+	var aJob job
+	err := instance.repo.ClaimJob(userID, websiteID, now.Format("2006-01-02, 15:04:05"), now.Add(-staleAfter).Format("2006-01-02, 15:04:05"), &aJob)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	aJob.Status = StatusDone
+	replaced, err := instance.sessionUseCase.ReplaceEvents(aJob.UserID, aJob.WebsiteID, aJob.Corrections)
+	if err == nil {
+		aJob.Replaced = len(replaced)
+		aJob.Missing = missing(aJob.Corrections, replaced)
+		aJob.Days = days(replaced)
+		err = instance.archiveUseCase.MarkDirty(aJob.UserID, aJob.WebsiteID, dayTimes(replaced))
+	}
+	if err != nil {
+		logrus.Error("re-ingest job ", aJob.ID, " error ", err)
+		aJob.Status = StatusFailed
+		aJob.Error = err.Error()
+	}
+	aJob.FinishedAt = time.Now().Format("2006-01-02, 15:04:05")
+	return true, instance.repo.FinishJob(aJob)
+}
+
+// missing keys of corrections no stored event matched
+func missing(corrections []session.Correction, replaced []session.Replaced) []string {
+	found := map[string]bool{}
+	for _, aReplaced := range replaced {
+		found[aReplaced.SessionID+"\n"+aReplaced.ID] = true
+	}
+	var keys []string
+	for _, aCorrection := range corrections {
+		if !found[aCorrection.SessionID+"\n"+aCorrection.ID] {
+			keys = append(keys, aCorrection.ID)
+		}
+	}
+	return keys
+}
+
+// dayTimes times the replaced events were and are now reported at
+func dayTimes(replaced []session.Replaced) []time.Time {
+	times := make([]time.Time, 0, 2*len(replaced))
+	for _, aReplaced := range replaced {
+		times = append(times, aReplaced.From, aReplaced.To)
+	}
+	return times
+}
+
+// days distinct UTC days of dayTimes, sorted
+func days(replaced []session.Replaced) []string {
+	seen := map[string]bool{}
+	var listDay []string
+	for _, at := range dayTimes(replaced) {
+		day := at.UTC().Format("2006-01-02")
+		if !seen[day] {
+			seen[day] = true
+			listDay = append(listDay, day)
+		}
+	}
+	sort.Strings(listDay)
+	return listDay
+}
@@ -0,0 +1,63 @@
+package session
+
+import (
+	"net/url"
+	"time"
+
+	"analytics-api/internal/app/aggregate"
+)
+
+// aggregateBatch what a batch of a website in aggregate-only mode adds to its
+// counters: pageviews by path, custom events by tag and the client described
+// by aMetaData. The session id and the events themselves are left out
+func aggregateBatch(request RequestSession, aMetaData metaData) aggregate.Batch {
+	aBatch := aggregate.Batch{
+		Day:      time.Now(),
+		First:    request.First && len(request.Events) > 0,
+		Country:  aMetaData.CountryCode,
+		Device:   aMetaData.Device,
+		Browser:  aMetaData.Browser,
+		Platform: aMetaData.Platform,
+		Pages:    map[string]int64{},
+		Events:   map[string]int64{},
+	}
+	// the tracker keeps first until a batch holding events is sent
+	if len(request.Events) > 0 {
+		aBatch.Day = time.UnixMilli(request.Events[0].Timestamp)
+	}
+	for _, anEvent := range request.Events {
+		switch anEvent.Type {
+		case metaEventType:
+			// the tracker only reports the canonical url of pages when the
+			// website counts them under it
+			href, _ := anEvent.Data["href"].(string)
+			if canonical, ok := anEvent.Data["canonical"].(string); ok && canonical != "" {
+				href = canonical
+			}
+			aBatch.Pages[hrefToPath(href)]++
+		case customEventType:
+			tag, _ := anEvent.Data["tag"].(string)
+			if tag == "" {
+				continue
+			}
+			if tag == ScreenViewTag {
+				payload, _ := anEvent.Data["payload"].(map[string]interface{})
+				screen, _ := payload["screen"].(string)
+				aBatch.Pages[screen]++
+				continue
+			}
+			aBatch.Events[tag]++
+		}
+	}
+	return aBatch
+}
+
+// hrefToPath path of an absolute url, / for the root of sites, query and
+// fragment left out
+func hrefToPath(href string) string {
+	u, err := url.Parse(href)
+	if err != nil || u.Path == "" {
+		return "/"
+	}
+	return u.Path
+}
@@ -0,0 +1,77 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestAggregateBatch(t *testing.T) {
+	at := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	aMetaData := metaData{CountryCode: "VN", Device: "mobile", Browser: "Chrome", Platform: "web"}
+	tests := []struct {
+		name       string
+		request    RequestSession
+		wantFirst  bool
+		wantPages  map[string]int64
+		wantEvents map[string]int64
+	}{
+		{
+			name:       "should not count a session without events",
+			request:    RequestSession{First: true},
+			wantPages:  map[string]int64{},
+			wantEvents: map[string]int64{},
+		},
+		{
+			name: "should count pages by path and events by tag",
+			request: RequestSession{First: true, Events: []event{
+				{Type: metaEventType, Data: bson.M{"href": "https://example.com/pricing?plan=pro#faq"}, Timestamp: at.UnixMilli()},
+				{Type: metaEventType, Data: bson.M{"href": "https://example.com"}, Timestamp: at.UnixMilli()},
+				{Type: customEventType, Data: bson.M{"tag": "signup"}, Timestamp: at.UnixMilli()},
+				{Type: customEventType, Data: bson.M{"tag": "signup"}, Timestamp: at.UnixMilli()},
+				{Type: customEventType, Data: bson.M{}, Timestamp: at.UnixMilli()},
+			}},
+			wantFirst:  true,
+			wantPages:  map[string]int64{"/pricing": 1, "/": 1},
+			wantEvents: map[string]int64{"signup": 2},
+		},
+		{
+			name: "should count a page under its canonical url",
+			request: RequestSession{Events: []event{
+				{Type: metaEventType, Data: bson.M{"href": "https://example.com/p?id=1", "canonical": "https://example.com/product"}, Timestamp: at.UnixMilli()},
+			}},
+			wantPages:  map[string]int64{"/product": 1},
+			wantEvents: map[string]int64{},
+		},
+		{
+			name: "should count screen views of apps as pages",
+			request: RequestSession{Events: []event{
+				{Type: customEventType, Data: bson.M{"tag": ScreenViewTag, "payload": map[string]interface{}{"screen": "Home"}}, Timestamp: at.UnixMilli()},
+			}},
+			wantPages:  map[string]int64{"Home": 1},
+			wantEvents: map[string]int64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := aggregateBatch(tt.request, aMetaData)
+			if got.First != tt.wantFirst {
+				t.Errorf("First = %v, want %v", got.First, tt.wantFirst)
+			}
+			if len(tt.request.Events) > 0 && !got.Day.Equal(at) {
+				t.Errorf("Day = %s, want %s", got.Day, at)
+			}
+			if got.Country != "VN" || got.Device != "mobile" || got.Browser != "Chrome" || got.Platform != "web" {
+				t.Errorf("client = %s %s %s %s, want VN mobile Chrome web", got.Country, got.Device, got.Browser, got.Platform)
+			}
+			if !reflect.DeepEqual(got.Pages, tt.wantPages) {
+				t.Errorf("Pages = %v, want %v", got.Pages, tt.wantPages)
+			}
+			if !reflect.DeepEqual(got.Events, tt.wantEvents) {
+				t.Errorf("Events = %v, want %v", got.Events, tt.wantEvents)
+			}
+		})
+	}
+}
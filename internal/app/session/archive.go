@@ -0,0 +1,136 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/clickhouse"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// RawEvent stored event with the metadata of its session, as archived. Data
+// is left sealed when the tenant encrypts recordings
+type RawEvent struct {
+	SessionID   string
+	UserID      string
+	WebsiteID   string
+	Type        int64
+	Data        string
+	Sealed      string
+	Timestamp   int64
+	TimeReport  time.Time
+	Platform    string
+	Country     string
+	CountryCode string
+	Region      string
+	RegionCode  string
+	City        string
+	Device      string
+	OS          string
+	OSVersion   string
+	Browser     string
+	Version     string
+	AppVersion  string
+	DeviceModel string
+	Late        bool
+}
+
+func newRawEvent(aSession session) (RawEvent, error) {
+	data := ""
+	if aSession.Event.Data != nil {
+		raw, err := json.Marshal(aSession.Event.Data)
+		if err != nil {
+			return RawEvent{}, err
+		}
+		data = string(raw)
+	}
+	metaData := aSession.MetaData
+	return RawEvent{
+		SessionID:   metaData.ID,
+		UserID:      metaData.UserID,
+		WebsiteID:   metaData.WebsiteID,
+		Type:        aSession.Event.Type,
+		Data:        data,
+		Sealed:      aSession.Event.Sealed,
+		Timestamp:   aSession.Event.Timestamp,
+		TimeReport:  aSession.TimeReport,
+		Platform:    metaData.Platform,
+		Country:     metaData.Country,
+		CountryCode: metaData.CountryCode,
+		Region:      metaData.Region,
+		RegionCode:  metaData.RegionCode,
+		City:        metaData.City,
+		Device:      metaData.Device,
+		OS:          metaData.OS,
+		OSVersion:   metaData.OSVersion,
+		Browser:     metaData.Browser,
+		Version:     metaData.Version,
+		AppVersion:  metaData.AppVersion,
+		DeviceModel: metaData.DeviceModel,
+		Late:        aSession.Event.Late,
+	}, nil
+}
+
+// StreamRawEvent call fn with each event of website reported in [from, to), oldest first
+func (instance *repository) StreamRawEvent(userID, websiteID string, from, to time.Time, fn func(RawEvent) error) error {
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"meta_data.user_id": userID},
+		{"meta_data.website_id": websiteID},
+		{"time_report": bson.M{"$gte": from, "$lt": to}},
+	}}
+	opts := options.Find().SetSort(primitive.D{{Key: "time_report", Value: 1}})
+	cur, err := sessionCollection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(context.TODO())
+
+	for cur.Next(context.TODO()) {
+		var aSession session
+		if err := cur.Decode(&aSession); err != nil {
+			return err
+		}
+		anEvent, err := newRawEvent(aSession)
+		if err != nil {
+			return err
+		}
+		if err := fn(anEvent); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+// StreamRawEvent call fn with each event of website reported in [from, to), oldest first
+func (instance *clickHouseRepository) StreamRawEvent(userID, websiteID string, from, to time.Time, fn func(RawEvent) error) error {
+	params := instance.params(userID)
+	params["website"] = websiteID
+	params["from"] = clickhouse.TimeParam(from)
+	params["to"] = clickhouse.TimeParam(to)
+	query := "SELECT * FROM " + db.ClickHouseEventTable +
+		" WHERE " + clickHouseFilter + " AND website_id = {website:String}" +
+		" AND time_report >= {from:DateTime64(3)} AND time_report < {to:DateTime64(3)}" +
+		" ORDER BY time_report"
+	return configs.ClickHouse.Client.Query(query, params, func(line []byte) error {
+		var row clickHouseEvent
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		aSession, err := row.toSession()
+		if err != nil {
+			return err
+		}
+		anEvent, err := newRawEvent(aSession)
+		if err != nil {
+			return err
+		}
+		return fn(anEvent)
+	})
+}
@@ -0,0 +1,180 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/clickhouse"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ErrInvalidDimension ...
+var ErrInvalidDimension = errors.New("invalid breakdown dimension")
+
+// breakdownFields dimensions of a breakdown, named like the meta data fields
+// in mongo and the columns in clickhouse
+var breakdownFields = map[string]bool{
+	"platform":     true,
+	"os":           true,
+	"os_version":   true,
+	"device":       true,
+	"device_model": true,
+	"browser":      true,
+	"app_version":  true,
+	"country":      true,
+	"country_code": true,
+	"region":       true,
+	"region_code":  true,
+	"city":         true,
+}
+
+// BreakdownFilter sessions counted by a breakdown
+type BreakdownFilter struct {
+	From time.Time
+	To   time.Time
+	// Platform count only sessions of this platform when set
+	Platform string
+	// CountryCode and RegionCode drill down into a country or region when set
+	CountryCode string
+	RegionCode  string
+	// Canonical count pages under the canonical url the tracker reported for
+	// them, their own url when there is none
+	Canonical bool
+}
+
+// Bucket number of sessions with a value of the dimension
+type Bucket struct {
+	Key      string `json:"key"`
+	Sessions int64  `json:"sessions"`
+}
+
+// Breakdown count sessions of website by value of dimension, most frequent first
+func (instance *repository) Breakdown(userID, websiteID, dimension string, filter BreakdownFilter) ([]Bucket, error) {
+	match := []bson.M{
+		{"meta_data.user_id": userID},
+		{"meta_data.website_id": websiteID},
+		onTime,
+		{"time_report": bson.M{"$gte": filter.From, "$lt": filter.To}},
+	}
+	switch filter.Platform {
+	case "":
+	case PlatformWeb:
+		match = append(match, bson.M{"meta_data.platform": bson.M{"$in": []interface{}{PlatformWeb, nil}}})
+	default:
+		match = append(match, bson.M{"meta_data.platform": filter.Platform})
+	}
+	if filter.CountryCode != "" {
+		match = append(match, bson.M{"meta_data.country_code": filter.CountryCode})
+	}
+	if filter.RegionCode != "" {
+		match = append(match, bson.M{"meta_data.region_code": filter.RegionCode})
+	}
+	pipeline := []bson.M{
+		{"$match": bson.M{"$and": match}},
+		{"$group": bson.M{"_id": bson.M{"key": "$meta_data." + dimension, "id": "$meta_data.id"}}},
+		{"$group": bson.M{"_id": "$_id.key", "sessions": bson.M{"$sum": 1}}},
+	}
+
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	cur, err := db.Aggregate(context.TODO(), sessionCollection, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	var buckets []Bucket
+	for cur.Next(context.TODO()) {
+		var row struct {
+			Key      *string `bson:"_id"`
+			Sessions int64   `bson:"sessions"`
+		}
+		if err := cur.Decode(&row); err != nil {
+			return nil, err
+		}
+		bucket := Bucket{Sessions: row.Sessions}
+		if row.Key != nil {
+			bucket.Key = *row.Key
+		}
+		buckets = append(buckets, bucket)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return sortBuckets(dimension, buckets), nil
+}
+
+// Breakdown count sessions of website by value of dimension, most frequent first
+func (instance *clickHouseRepository) Breakdown(userID, websiteID, dimension string, filter BreakdownFilter) ([]Bucket, error) {
+	params := instance.params(userID)
+	params["website"] = websiteID
+	params["from"] = clickhouse.TimeParam(filter.From)
+	params["to"] = clickhouse.TimeParam(filter.To)
+	query := "SELECT " + dimension + " AS key, uniqExact(id) AS sessions FROM " + db.ClickHouseEventTable +
+		" WHERE " + clickHouseReportFilter + " AND website_id = {website:String}" +
+		" AND time_report >= {from:DateTime64(3)} AND time_report < {to:DateTime64(3)}"
+	switch filter.Platform {
+	case "":
+	case PlatformWeb:
+		query += " AND platform IN ('web', '')"
+	default:
+		params["platform"] = filter.Platform
+		query += " AND platform = {platform:String}"
+	}
+	if filter.CountryCode != "" {
+		params["country_code"] = filter.CountryCode
+		query += " AND country_code = {country_code:String}"
+	}
+	if filter.RegionCode != "" {
+		params["region_code"] = filter.RegionCode
+		query += " AND region_code = {region_code:String}"
+	}
+	query += " GROUP BY key"
+
+	var buckets []Bucket
+	err := configs.ClickHouse.Client.Query(query, params, func(line []byte) error {
+		var row Bucket
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		buckets = append(buckets, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sortBuckets(dimension, buckets), nil
+}
+
+// sortBuckets merge sessions collected before apps were supported into web,
+// then sort by sessions
+func sortBuckets(dimension string, buckets []Bucket) []Bucket {
+	if dimension == "platform" {
+		merged := map[string]int64{}
+		for _, bucket := range buckets {
+			if bucket.Key == "" {
+				bucket.Key = PlatformWeb
+			}
+			merged[bucket.Key] += bucket.Sessions
+		}
+		buckets = buckets[:0]
+		for key, sessions := range merged {
+			buckets = append(buckets, Bucket{Key: key, Sessions: sessions})
+		}
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Sessions != buckets[j].Sessions {
+			return buckets[i].Sessions > buckets[j].Sessions
+		}
+		return buckets[i].Key < buckets[j].Key
+	})
+	if buckets == nil {
+		buckets = []Bucket{}
+	}
+	return buckets
+}
@@ -0,0 +1,539 @@
+package session
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/clickhouse"
+	"analytics-api/internal/pkg/cursor"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// clickHouseRepository session events stored in ClickHouse. Only the key store
+// backed session timestamps are inherited from the mongo repository.
+type clickHouseRepository struct {
+	*repository
+}
+
+// clickHouseEvent row of the session event table, one per event like the mongo documents
+type clickHouseEvent struct {
+	TenantID    string `json:"tenant_id"`
+	ID          string `json:"id"`
+	UserID      string `json:"user_id"`
+	WebsiteID   string `json:"website_id"`
+	Country     string `json:"country"`
+	City        string `json:"city"`
+	Device      string `json:"device"`
+	OS          string `json:"os"`
+	Browser     string `json:"browser"`
+	Version     string `json:"version"`
+	CreatedAt   string `json:"created_at"`
+	Platform    string `json:"platform"`
+	AppVersion  string `json:"app_version"`
+	OSVersion   string `json:"os_version"`
+	DeviceModel string `json:"device_model"`
+	CountryCode string `json:"country_code"`
+	Region      string `json:"region"`
+	RegionCode  string `json:"region_code"`
+	Overage     bool   `json:"overage"`
+	Referrer    string `json:"referrer"`
+	Duration    string `json:"duration"`
+	EventID     string `json:"event_id"`
+	Type        int64  `json:"type"`
+	Data        string `json:"data"`
+	Sealed      string `json:"sealed"`
+	Late        bool   `json:"late"`
+	Timestamp   int64  `json:"timestamp"`
+	TimeReport  string `json:"time_report"`
+}
+
+const clickHouseFilter = "tenant_id = {tenant:String} AND user_id = {user:String}"
+
+// clickHouseReportFilter clickHouseFilter of the reports, which leave out the
+// events flagged late like onTime
+const clickHouseReportFilter = clickHouseFilter + " AND NOT late"
+
+func (instance *clickHouseRepository) params(userID string) map[string]string {
+	return map[string]string{
+		"tenant": instance.store.TenantID,
+		"user":   userID,
+	}
+}
+
+func (instance *clickHouseRepository) querySessions(query string, params map[string]string) ([]session, error) {
+	var listSession []session
+	err := configs.ClickHouse.Client.Query(query, params, func(line []byte) error {
+		var row clickHouseEvent
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		aSession, err := row.toSession()
+		if err != nil {
+			return err
+		}
+		listSession = append(listSession, aSession)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return listSession, nil
+}
+
+func (instance *clickHouseRepository) queryIDs(query string, params map[string]string) ([]string, error) {
+	listSessionID := []string{}
+	err := configs.ClickHouse.Client.Query(query, params, func(line []byte) error {
+		var row struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		listSessionID = append(listSessionID, row.ID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return listSessionID, nil
+}
+
+// GetSession get session by session id
+func (instance *clickHouseRepository) GetSession(userID, sessionID string, aSession *session) error {
+	params := instance.params(userID)
+	params["id"] = sessionID
+	listSession, err := instance.querySessions("SELECT * FROM "+db.ClickHouseEventTable+
+		" WHERE "+clickHouseFilter+" AND id = {id:String} ORDER BY timestamp LIMIT 1", params)
+	if err != nil {
+		return err
+	}
+	if len(listSession) == 0 {
+		return mongo.ErrNoDocuments
+	}
+	*aSession = listSession[0]
+	return nil
+}
+
+// GetAllSession get the last event of each session, in the order of listSessionID
+func (instance *clickHouseRepository) GetAllSession(userID, websiteID string, listSessionID []string, aSession session) ([]session, error) {
+	var listSession []session
+	err := instance.StreamSession(userID, websiteID, listSessionID, func(aSession session) error {
+		listSession = append(listSession, aSession)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return listSession, nil
+}
+
+// clickHouseSessionBatch number of sessions read per query while streaming
+const clickHouseSessionBatch = 100
+
+// StreamSession call fn with the last event of each session, in the order of
+// listSessionID. Sessions are queried in batches to keep that order
+func (instance *clickHouseRepository) StreamSession(userID, websiteID string, listSessionID []string, fn func(session) error) error {
+	for start := 0; start < len(listSessionID); start += clickHouseSessionBatch {
+		end := start + clickHouseSessionBatch
+		if end > len(listSessionID) {
+			end = len(listSessionID)
+		}
+		batch := listSessionID[start:end]
+
+		params := instance.params(userID)
+		params["website"] = websiteID
+		params["ids"] = clickhouse.ArrayParam(batch)
+		rows, err := instance.querySessions("SELECT * FROM "+db.ClickHouseEventTable+
+			" WHERE "+clickHouseFilter+" AND website_id = {website:String} AND id IN {ids:Array(String)}"+
+			" ORDER BY timestamp DESC LIMIT 1 BY id", params)
+		if err != nil {
+			return err
+		}
+
+		byID := map[string]session{}
+		for _, row := range rows {
+			byID[row.MetaData.ID] = row
+		}
+		for _, sessionID := range batch {
+			if row, ok := byID[sessionID]; ok {
+				if err := fn(row); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// GetAllSessionID get all id of session all time
+func (instance *clickHouseRepository) GetAllSessionID(userID, websiteID string, aSession session) ([]string, error) {
+	params := instance.params(userID)
+	params["website"] = websiteID
+	return instance.queryIDs("SELECT id FROM "+db.ClickHouseEventTable+
+		" WHERE "+clickHouseFilter+" AND website_id = {website:String}"+
+		" GROUP BY id ORDER BY min(timestamp)", params)
+}
+
+// GetSessionIDToday get all id of session today
+func (instance *clickHouseRepository) GetSessionIDToday(userID, websiteID string, aSession session) ([]string, error) {
+	fromDate := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 0, 0, 0, 0, time.UTC)
+	toDate := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 24, 0, 0, 0, time.UTC)
+
+	params := instance.params(userID)
+	params["website"] = websiteID
+	params["from"] = clickhouse.TimeParam(fromDate)
+	params["to"] = clickhouse.TimeParam(toDate)
+	return instance.queryIDs("SELECT id FROM "+db.ClickHouseEventTable+
+		" WHERE "+clickHouseFilter+" AND website_id = {website:String}"+
+		" AND time_report > {from:DateTime64(3)} AND time_report < {to:DateTime64(3)}"+
+		" GROUP BY id ORDER BY min(timestamp)", params)
+}
+
+// InsertSession insert session
+func (instance *clickHouseRepository) InsertSession(aSession session, anEvent event) error {
+	if err := sealEvent(instance.store, &anEvent); err != nil {
+		return err
+	}
+	row, err := newClickHouseEvent(instance.store.TenantID, aSession, anEvent)
+	if err != nil {
+		return err
+	}
+	return configs.ClickHouse.Client.Insert(db.ClickHouseEventTable, []interface{}{row})
+}
+
+// GetCountSession get count session of session id
+func (instance *clickHouseRepository) GetCountSession(userID, sessionID string) (int64, error) {
+	var count int64
+	params := instance.params(userID)
+	params["id"] = sessionID
+	err := configs.ClickHouse.Client.Query("SELECT count() AS count FROM "+db.ClickHouseEventTable+
+		" WHERE "+clickHouseFilter+" AND id = {id:String}", params, func(line []byte) error {
+		var row struct {
+			Count int64 `json:"count"`
+		}
+		err := json.Unmarshal(line, &row)
+		count = row.Count
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountSessionSince count sessions of website reported since time
+func (instance *clickHouseRepository) CountSessionSince(userID, websiteID string, since time.Time) (int64, error) {
+	var count int64
+	params := instance.params(userID)
+	params["website"] = websiteID
+	params["since"] = clickhouse.TimeParam(since)
+	err := configs.ClickHouse.Client.Query("SELECT uniqExact(id) AS count FROM "+db.ClickHouseEventTable+
+		" WHERE "+clickHouseReportFilter+" AND website_id = {website:String} AND time_report >= {since:DateTime64(3)}", params, func(line []byte) error {
+		var row struct {
+			Count int64 `json:"count"`
+		}
+		err := json.Unmarshal(line, &row)
+		count = row.Count
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountSessionsSince count sessions of each website reported since each time
+func (instance *clickHouseRepository) CountSessionsSince(userID string, websiteIDs []string, since []time.Time) (map[string][]int64, error) {
+	params := instance.params(userID)
+	params["websites"] = clickhouse.ArrayParam(websiteIDs)
+	earliest := since[0]
+	var counts []string
+	for i, t := range since {
+		if t.Before(earliest) {
+			earliest = t
+		}
+		name := "since" + strconv.Itoa(i)
+		params[name] = clickhouse.TimeParam(t)
+		counts = append(counts, "uniqExactIf(id, time_report >= {"+name+":DateTime64(3)})")
+	}
+	params["earliest"] = clickhouse.TimeParam(earliest)
+
+	result := map[string][]int64{}
+	err := configs.ClickHouse.Client.Query("SELECT website_id, ["+strings.Join(counts, ", ")+"] AS counts FROM "+db.ClickHouseEventTable+
+		" WHERE "+clickHouseReportFilter+" AND website_id IN {websites:Array(String)} AND time_report >= {earliest:DateTime64(3)}"+
+		" GROUP BY website_id", params, func(line []byte) error {
+		var row struct {
+			WebsiteID string  `json:"website_id"`
+			Counts    []int64 `json:"counts"`
+		}
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		result[row.WebsiteID] = row.Counts
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetEventByLimitSkip get limit event of session by session id
+func (instance *clickHouseRepository) GetEventByLimitSkip(userID, sessionID string, limit, skip int) ([]*event, error) {
+	params := instance.params(userID)
+	params["id"] = sessionID
+	params["limit"] = strconv.Itoa(limit)
+	params["skip"] = strconv.Itoa(skip)
+	listSession, err := instance.querySessions("SELECT * FROM "+db.ClickHouseEventTable+
+		" WHERE "+clickHouseFilter+" AND id = {id:String}"+
+		" ORDER BY timestamp LIMIT {limit:UInt32} OFFSET {skip:UInt32}", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*event
+	for i := range listSession {
+		if listSession[i].Event.Sealed != "" {
+			if err := openEvent(instance.store, &listSession[i].Event); err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, &listSession[i].Event)
+	}
+	return events, nil
+}
+
+// StreamEvent call fn with each event of session as rows arrive from ClickHouse
+func (instance *clickHouseRepository) StreamEvent(userID, sessionID string, fn func(*event) error) error {
+	params := instance.params(userID)
+	params["id"] = sessionID
+	return configs.ClickHouse.Client.Query("SELECT * FROM "+db.ClickHouseEventTable+
+		" WHERE "+clickHouseFilter+" AND id = {id:String} ORDER BY timestamp", params, func(line []byte) error {
+		var row clickHouseEvent
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		aSession, err := row.toSession()
+		if err != nil {
+			return err
+		}
+		if aSession.Event.Sealed != "" {
+			if err := openEvent(instance.store, &aSession.Event); err != nil {
+				return err
+			}
+		}
+		return fn(&aSession.Event)
+	})
+}
+
+// GetSessionPage get id of sessions sorted by their first event, after the cursor
+func (instance *clickHouseRepository) GetSessionPage(userID, websiteID string, today bool, after *cursor.Cursor, limit int) ([]string, *cursor.Cursor, error) {
+	params := instance.params(userID)
+	params["website"] = websiteID
+	params["limit"] = strconv.Itoa(limit + 1)
+	query := "SELECT id, min(timestamp) AS start FROM " + db.ClickHouseEventTable +
+		" WHERE " + clickHouseFilter + " AND website_id = {website:String}"
+	if today {
+		fromDate := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 0, 0, 0, 0, time.UTC)
+		toDate := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 24, 0, 0, 0, time.UTC)
+		params["from"] = clickhouse.TimeParam(fromDate)
+		params["to"] = clickhouse.TimeParam(toDate)
+		query += " AND time_report > {from:DateTime64(3)} AND time_report < {to:DateTime64(3)}"
+	}
+	query += " GROUP BY id"
+	if after != nil {
+		params["after_key"] = strconv.FormatInt(after.Key, 10)
+		params["after_id"] = after.ID
+		query += " HAVING (start, id) > ({after_key:Int64}, {after_id:String})"
+	}
+	query += " ORDER BY start, id LIMIT {limit:UInt32}"
+
+	var keys []cursor.Cursor
+	err := configs.ClickHouse.Client.Query(query, params, func(line []byte) error {
+		var row struct {
+			ID    string `json:"id"`
+			Start int64  `json:"start"`
+		}
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		keys = append(keys, cursor.Cursor{Key: row.Start, ID: row.ID})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	listSessionID, next := sessionPage(keys, limit)
+	return listSessionID, next, nil
+}
+
+// GetEventPage get events of session sorted by timestamp, after the cursor. Rows
+// have no id in ClickHouse, a hash of the event breaks ties of timestamp
+func (instance *clickHouseRepository) GetEventPage(userID, sessionID string, after *cursor.Cursor, limit int) ([]*event, *cursor.Cursor, error) {
+	params := instance.params(userID)
+	params["id"] = sessionID
+	params["limit"] = strconv.Itoa(limit + 1)
+	query := "SELECT *, cityHash64(type, data, sealed) AS row_id FROM " + db.ClickHouseEventTable +
+		" WHERE " + clickHouseFilter + " AND id = {id:String}"
+	if after != nil {
+		if _, err := strconv.ParseUint(after.ID, 10, 64); err != nil {
+			return nil, nil, cursor.ErrInvalidCursor
+		}
+		params["after_key"] = strconv.FormatInt(after.Key, 10)
+		params["after_id"] = after.ID
+		query += " AND (timestamp, row_id) > ({after_key:Int64}, {after_id:UInt64})"
+	}
+	query += " ORDER BY timestamp, row_id LIMIT {limit:UInt32}"
+
+	var events []*event
+	var keys []cursor.Cursor
+	err := configs.ClickHouse.Client.Query(query, params, func(line []byte) error {
+		var row struct {
+			clickHouseEvent
+			RowID uint64 `json:"row_id"`
+		}
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		aSession, err := row.toSession()
+		if err != nil {
+			return err
+		}
+		if aSession.Event.Sealed != "" {
+			if err := openEvent(instance.store, &aSession.Event); err != nil {
+				return err
+			}
+		}
+		events = append(events, &aSession.Event)
+		keys = append(keys, cursor.Cursor{Key: aSession.Event.Timestamp, ID: strconv.FormatUint(row.RowID, 10)})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(events) > limit {
+		return events[:limit], &keys[limit-1], nil
+	}
+	return events, nil, nil
+}
+
+// DeleteSessionBefore delete all session reported before time but those of
+// the websites of keep, the delete runs asynchronously in ClickHouse
+func (instance *clickHouseRepository) DeleteSessionBefore(before time.Time, keep []string) (int64, error) {
+	var count int64
+	filter := "tenant_id = {tenant:String} AND time_report < {before:DateTime64(3)} AND website_id NOT IN {keep:Array(String)}"
+	params := map[string]string{
+		"tenant": instance.store.TenantID,
+		"before": clickhouse.TimeParam(before),
+		"keep":   clickhouse.ArrayParam(keep),
+	}
+	err := configs.ClickHouse.Client.Query("SELECT count() AS count FROM "+db.ClickHouseEventTable+" WHERE "+filter, params, func(line []byte) error {
+		var row struct {
+			Count int64 `json:"count"`
+		}
+		err := json.Unmarshal(line, &row)
+		count = row.Count
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	err = configs.ClickHouse.Client.Exec("ALTER TABLE "+db.ClickHouseEventTable+" DELETE WHERE "+filter, params)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func newClickHouseEvent(tenantID string, aSession session, anEvent event) (*clickHouseEvent, error) {
+	data := ""
+	if anEvent.Data != nil {
+		raw, err := json.Marshal(anEvent.Data)
+		if err != nil {
+			return nil, err
+		}
+		data = string(raw)
+	}
+	return &clickHouseEvent{
+		TenantID:    tenantID,
+		ID:          aSession.MetaData.ID,
+		UserID:      aSession.MetaData.UserID,
+		WebsiteID:   aSession.MetaData.WebsiteID,
+		Country:     aSession.MetaData.Country,
+		City:        aSession.MetaData.City,
+		Device:      aSession.MetaData.Device,
+		OS:          aSession.MetaData.OS,
+		Browser:     aSession.MetaData.Browser,
+		Version:     aSession.MetaData.Version,
+		CreatedAt:   aSession.MetaData.CreatedAt,
+		Platform:    aSession.MetaData.Platform,
+		AppVersion:  aSession.MetaData.AppVersion,
+		OSVersion:   aSession.MetaData.OSVersion,
+		DeviceModel: aSession.MetaData.DeviceModel,
+		CountryCode: aSession.MetaData.CountryCode,
+		Region:      aSession.MetaData.Region,
+		RegionCode:  aSession.MetaData.RegionCode,
+		Overage:     aSession.MetaData.Overage,
+		Referrer:    aSession.MetaData.Referrer,
+		Duration:    aSession.Duration,
+		EventID:     anEvent.ID,
+		Type:        anEvent.Type,
+		Data:        data,
+		Sealed:      anEvent.Sealed,
+		Late:        anEvent.Late,
+		Timestamp:   anEvent.Timestamp,
+		TimeReport:  clickhouse.TimeParam(aSession.TimeReport),
+	}, nil
+}
+
+func (instance *clickHouseEvent) toSession() (session, error) {
+	aSession := session{
+		MetaData: metaData{
+			ID:        instance.ID,
+			UserID:    instance.UserID,
+			WebsiteID: instance.WebsiteID,
+			Country:   instance.Country,
+			City:      instance.City,
+			Device:    instance.Device,
+			OS:        instance.OS,
+			Browser:   instance.Browser,
+			Version:   instance.Version,
+			CreatedAt: instance.CreatedAt,
+
+			Platform:    instance.Platform,
+			AppVersion:  instance.AppVersion,
+			OSVersion:   instance.OSVersion,
+			DeviceModel: instance.DeviceModel,
+			CountryCode: instance.CountryCode,
+			Region:      instance.Region,
+			RegionCode:  instance.RegionCode,
+			Overage:     instance.Overage,
+			Referrer:    instance.Referrer,
+		},
+		Duration: instance.Duration,
+		Event: event{
+			ID:        instance.EventID,
+			Type:      instance.Type,
+			Sealed:    instance.Sealed,
+			Late:      instance.Late,
+			Timestamp: instance.Timestamp,
+		},
+	}
+	if instance.Data != "" {
+		if err := json.Unmarshal([]byte(instance.Data), &aSession.Event.Data); err != nil {
+			return aSession, err
+		}
+	}
+	timeReport, err := time.Parse(clickhouse.TimeLayout, instance.TimeReport)
+	if err != nil {
+		return aSession, err
+	}
+	aSession.TimeReport = timeReport
+	return aSession, nil
+}
@@ -0,0 +1,143 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/clickhouse"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Divergence event count of a website on a day that differs between backends
+type Divergence struct {
+	WebsiteID  string `json:"website_id"`
+	Day        string `json:"day"`
+	Mongo      int64  `json:"mongo"`
+	ClickHouse int64  `json:"clickhouse"`
+}
+
+type dailyCount map[string]map[string]int64
+
+func (instance dailyCount) add(websiteID, day string, count int64) {
+	if instance[websiteID] == nil {
+		instance[websiteID] = map[string]int64{}
+	}
+	instance[websiteID][day] += count
+}
+
+// CheckConsistency compare the event count per website per day of mongo and
+// clickhouse between from and to, both backends must be connected
+func CheckConsistency(store *db.Store, from, to time.Time) ([]Divergence, error) {
+	mongoCount, err := mongoDailyCount(store, from, to)
+	if err != nil {
+		return nil, err
+	}
+	clickHouseCount, err := clickHouseDailyCount(store, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var listDivergence []Divergence
+	for websiteID, days := range mongoCount {
+		for day, count := range days {
+			if clickHouseCount[websiteID][day] != count {
+				listDivergence = append(listDivergence, Divergence{
+					WebsiteID:  websiteID,
+					Day:        day,
+					Mongo:      count,
+					ClickHouse: clickHouseCount[websiteID][day],
+				})
+			}
+		}
+	}
+	for websiteID, days := range clickHouseCount {
+		for day, count := range days {
+			if _, ok := mongoCount[websiteID][day]; !ok {
+				listDivergence = append(listDivergence, Divergence{
+					WebsiteID:  websiteID,
+					Day:        day,
+					ClickHouse: count,
+				})
+			}
+		}
+	}
+
+	sort.Slice(listDivergence, func(i, j int) bool {
+		if listDivergence[i].Day != listDivergence[j].Day {
+			return listDivergence[i].Day < listDivergence[j].Day
+		}
+		return listDivergence[i].WebsiteID < listDivergence[j].WebsiteID
+	})
+	return listDivergence, nil
+}
+
+func mongoDailyCount(store *db.Store, from, to time.Time) (dailyCount, error) {
+	sessionCollection := store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	pipeline := []bson.M{
+		{"$match": bson.M{"time_report": bson.M{"$gte": from, "$lt": to}}},
+		{"$group": bson.M{
+			"_id": bson.M{
+				"website_id": "$meta_data.website_id",
+				"day":        bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$time_report"}},
+			},
+			"count": bson.M{"$sum": 1},
+		}},
+	}
+	cursor, err := db.Aggregate(context.TODO(), sessionCollection, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	counts := dailyCount{}
+	for cursor.Next(context.TODO()) {
+		var row struct {
+			ID struct {
+				WebsiteID string `bson:"website_id"`
+				Day       string `bson:"day"`
+			} `bson:"_id"`
+			Count int64 `bson:"count"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return nil, err
+		}
+		counts.add(row.ID.WebsiteID, row.ID.Day, row.Count)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+func clickHouseDailyCount(store *db.Store, from, to time.Time) (dailyCount, error) {
+	params := map[string]string{
+		"tenant": store.TenantID,
+		"from":   clickhouse.TimeParam(from),
+		"to":     clickhouse.TimeParam(to),
+	}
+	counts := dailyCount{}
+	err := configs.ClickHouse.Client.Query("SELECT website_id, toString(toDate(time_report)) AS day, count() AS count"+
+		" FROM "+db.ClickHouseEventTable+
+		" WHERE tenant_id = {tenant:String} AND time_report >= {from:DateTime64(3)} AND time_report < {to:DateTime64(3)}"+
+		" GROUP BY website_id, day", params, func(line []byte) error {
+		var row struct {
+			WebsiteID string `json:"website_id"`
+			Day       string `json:"day"`
+			Count     int64  `json:"count"`
+		}
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		counts.add(row.WebsiteID, row.Day, row.Count)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
@@ -0,0 +1,52 @@
+package session
+
+import (
+	"encoding/json"
+
+	"analytics-api/internal/app/integration"
+	"analytics-api/internal/pkg/adconv"
+)
+
+// customEventType rrweb custom event sent with window.recorder.track
+const customEventType = 5
+
+// adConversions take the hashed identifiers out of the form_submit events of
+// visitors who consented to ad forwarding, so they are forwarded but never
+// stored with the recording. Identifiers that are not hashed are dropped
+func adConversions(sessionID string, events []event) []integration.Conversion {
+	var conversions []integration.Conversion
+	href := ""
+	for _, anEvent := range events {
+		if anEvent.Type == metaEventType {
+			href, _ = anEvent.Data["href"].(string)
+			continue
+		}
+		if anEvent.Type != customEventType || anEvent.Data["tag"] != FormSubmitTag {
+			continue
+		}
+		payload, ok := anEvent.Data["payload"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ad, ok := payload["ad"]
+		if !ok {
+			continue
+		}
+		delete(payload, "ad")
+
+		var user adconv.UserData
+		raw, err := json.Marshal(ad)
+		if err != nil || json.Unmarshal(raw, &user) != nil || user.Validate() != nil {
+			continue
+		}
+		formID, _ := payload["form_id"].(string)
+		conversions = append(conversions, integration.Conversion{
+			SessionID: sessionID,
+			FormID:    formID,
+			Timestamp: anEvent.Timestamp,
+			SourceURL: href,
+			User:      user,
+		})
+	}
+	return conversions
+}
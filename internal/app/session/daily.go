@@ -0,0 +1,141 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/clickhouse"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// dayLayout of the days of Daily
+const dayLayout = "2006-01-02"
+
+// Day activity of a day, a session active across midnight is counted in both
+// days
+type Day struct {
+	Day       string `json:"day"`
+	Sessions  int64  `json:"sessions"`
+	Pageviews int64  `json:"pageviews"`
+}
+
+// Daily sessions and pageviews of website by day in location, days without
+// activity are left out
+func (instance *repository) Daily(userID, websiteID string, filter BreakdownFilter, location *time.Location) ([]Day, error) {
+	match := []bson.M{
+		{"meta_data.user_id": userID},
+		{"meta_data.website_id": websiteID},
+		onTime,
+		{"time_report": bson.M{"$gte": filter.From, "$lt": filter.To}},
+	}
+	pageview := bson.M{"$cond": []interface{}{
+		bson.M{"$or": []bson.M{
+			{"$eq": []interface{}{"$event.type", metaEventType}},
+			{"$eq": []interface{}{"$event.data.tag", ScreenViewTag}},
+		}},
+		1,
+		0,
+	}}
+	pipeline := []bson.M{
+		{"$match": bson.M{"$and": match}},
+		{"$group": bson.M{
+			"_id": bson.M{
+				"day": bson.M{"$dateToString": bson.M{"date": "$time_report", "format": "%Y-%m-%d", "timezone": location.String()}},
+				"id":  "$meta_data.id",
+			},
+			"pageviews": bson.M{"$sum": pageview},
+		}},
+		{"$group": bson.M{
+			"_id":       "$_id.day",
+			"sessions":  bson.M{"$sum": 1},
+			"pageviews": bson.M{"$sum": "$pageviews"},
+		}},
+	}
+
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	cur, err := db.Aggregate(context.TODO(), sessionCollection, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	var days []Day
+	for cur.Next(context.TODO()) {
+		var row struct {
+			Day       string `bson:"_id"`
+			Sessions  int64  `bson:"sessions"`
+			Pageviews int64  `bson:"pageviews"`
+		}
+		if err := cur.Decode(&row); err != nil {
+			return nil, err
+		}
+		days = append(days, Day{Day: row.Day, Sessions: row.Sessions, Pageviews: row.Pageviews})
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return days, nil
+}
+
+// Daily sessions and pageviews of website by day in location
+func (instance *clickHouseRepository) Daily(userID, websiteID string, filter BreakdownFilter, location *time.Location) ([]Day, error) {
+	params := instance.params(userID)
+	params["website"] = websiteID
+	params["from"] = clickhouse.TimeParam(filter.From)
+	params["to"] = clickhouse.TimeParam(filter.To)
+	params["timezone"] = location.String()
+	query := "SELECT toString(toDate(time_report, {timezone:String})) AS day," +
+		" uniqExact(id) AS sessions," +
+		" countIf(type = 4 OR JSONExtractString(data, 'tag') = '" + ScreenViewTag + "') AS pageviews" +
+		" FROM " + db.ClickHouseEventTable +
+		" WHERE " + clickHouseReportFilter + " AND website_id = {website:String}" +
+		" AND time_report >= {from:DateTime64(3)} AND time_report < {to:DateTime64(3)}" +
+		" GROUP BY day"
+
+	var days []Day
+	err := configs.ClickHouse.Client.Query(query, params, func(line []byte) error {
+		var row Day
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		days = append(days, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return days, nil
+}
+
+// ArchivedDaily sessions and pageviews by day in location of the archived
+// events of scan, like Daily
+func ArchivedDaily(location *time.Location, scan ArchiveScan) ([]Day, error) {
+	seen := map[[2]string]bool{}
+	days := map[string]*Day{}
+	err := scan(func(anEvent RawEvent) error {
+		day := anEvent.TimeReport.In(location).Format(dayLayout)
+		if days[day] == nil {
+			days[day] = &Day{Day: day}
+		}
+		if !seen[[2]string{day, anEvent.SessionID}] {
+			seen[[2]string{day, anEvent.SessionID}] = true
+			days[day].Sessions++
+		}
+		if anEvent.Type == metaEventType || anEvent.tag() == ScreenViewTag {
+			days[day].Pageviews++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var listDay []Day
+	for _, aDay := range days {
+		listDay = append(listDay, *aDay)
+	}
+	return listDay, nil
+}
@@ -1,7 +1,16 @@
 package session
 
 import (
+	"analytics-api/db"
+	"analytics-api/internal/app/aggregate"
+	"analytics-api/internal/app/audit"
 	"analytics-api/internal/app/auth"
+	"analytics-api/internal/app/firehose"
+	"analytics-api/internal/app/integration"
+	"analytics-api/internal/app/reconcile"
+	"analytics-api/internal/app/usage"
+	"analytics-api/internal/app/user"
+	"analytics-api/internal/app/visitor"
 	"analytics-api/internal/app/website"
 
 	"github.com/gin-gonic/gin"
@@ -15,18 +24,32 @@ type HTTPDelivery interface {
 	// Other functions to handle HTTP requests
 	ShowHeatmaps(c *gin.Context)
 	GetEventBySessionID(c *gin.Context)
+	GetEventPage(c *gin.Context)
 	SessionReplay(c *gin.Context)
 
 	ListWebsiteOfSessionRecord(c *gin.Context)
 	ListSessionRecord(c *gin.Context)
+	ListSessionPage(c *gin.Context)
 	ReceiveSession(c *gin.Context)
+	ReceiveSegment(c *gin.Context)
+	GenerateFakeData(c *gin.Context)
 }
 
 // NewHTTPDelivery ...
-func NewHTTPDelivery() HTTPDelivery {
+func NewHTTPDelivery(store *db.Store) HTTPDelivery {
 	return &httpDelivery{
-		sessionUseCase: NewUseCase(),
-		websiteUseCase: website.NewUseCase(),
-		authUsecase:    auth.NewUseCase(),
+		store:          store,
+		sessionUseCase: NewUseCase(store),
+		websiteUseCase: website.NewUseCase(store),
+		authUsecase:    auth.NewUseCase(store),
+		userUseCase:    user.NewUseCase(store),
+		auditUseCase:   audit.NewUseCase(store),
+
+		integrationUseCase: integration.NewUseCase(store),
+		visitorUseCase:     visitor.NewUseCase(store),
+		firehoseUseCase:    firehose.NewUseCase(store),
+		reconcileUseCase:   reconcile.NewUseCase(store),
+		usageUseCase:       usage.NewUseCase(store),
+		aggregateUseCase:   aggregate.NewUseCase(store),
 	}
 }
@@ -2,29 +2,80 @@ package session
 
 import (
 	"encoding/json"
+	"errors"
 	"net"
 	"net/http"
+	"sort"
+	"strconv"
 	"time"
 
 	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/app/aggregate"
+	"analytics-api/internal/app/audit"
 	"analytics-api/internal/app/auth"
+	"analytics-api/internal/app/firehose"
+	"analytics-api/internal/app/integration"
+	"analytics-api/internal/app/reconcile"
+	"analytics-api/internal/app/usage"
+	"analytics-api/internal/app/user"
+	"analytics-api/internal/app/visitor"
 	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/cursor"
 	dur "analytics-api/internal/pkg/duration"
+	"analytics-api/internal/pkg/eventtime"
+	"analytics-api/internal/pkg/fields"
 	"analytics-api/internal/pkg/geodb"
+	"analytics-api/internal/pkg/httperr"
+	"analytics-api/internal/pkg/maintenance"
 	"analytics-api/internal/pkg/middleware"
+	"analytics-api/internal/pkg/ndjson"
 	"analytics-api/internal/pkg/security"
+	"analytics-api/internal/pkg/spam"
 	str "analytics-api/internal/pkg/string"
 
 	"github.com/gin-gonic/gin"
 	ua "github.com/mileusna/useragent"
 	"github.com/sirupsen/logrus"
 	"github.com/tomasen/realip"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// HeaderServerKey server key of a website, sent by backends in place of
+// the user and website of the batch
+const HeaderServerKey = "X-Server-Key"
+
+// ErrExcluded batch dropped by the settings of its website, sent from an
+// excluded IP or country, by a bot or from a spam referrer
+var ErrExcluded = errors.New("traffic excluded by the settings of the website")
+
+// Codes of the error responses of tracking and sessions
+const (
+	CodeSessionNotFound      = "session_not_found"
+	CodeInvalidWriteKey      = "invalid_write_key"
+	CodeInvalidServerKey     = "invalid_server_key"
+	CodeUnknownSegmentMethod = "unknown_segment_method"
+	// CodeEventsRejected every event of the batch is outside the accepted
+	// time window
+	CodeEventsRejected = "events_rejected"
+	// CodeQuotaExceeded batch dropped past the monthly event quota
+	CodeQuotaExceeded = "event_quota_exceeded"
 )
 
 type httpDelivery struct {
+	store          *db.Store
 	sessionUseCase UseCase
 	websiteUseCase website.UseCase
 	authUsecase    auth.UseCase
+	userUseCase    user.UseCase
+	auditUseCase   audit.UseCase
+
+	integrationUseCase integration.UseCase
+	visitorUseCase     visitor.UseCase
+	firehoseUseCase    firehose.UseCase
+	reconcileUseCase   reconcile.UseCase
+	usageUseCase       usage.UseCase
+	aggregateUseCase   aggregate.UseCase
 }
 
 // RequestSession website tracking send to server
@@ -33,6 +84,42 @@ type RequestSession struct {
 	WebsiteID string  `json:"website_id"`
 	SessionID string  `json:"session_id"`
 	Events    []event `json:"events"`
+	// HostName page host the tracker runs on, an alias of the website resolves
+	// the batch to it
+	HostName string `json:"host_name"`
+
+	// Platform web when empty, mobile SDKs send ios or android with the app details
+	// below since their user agent does not describe the device
+	Platform    string `json:"platform"`
+	AppVersion  string `json:"app_version"`
+	OS          string `json:"os"`
+	OSVersion   string `json:"os_version"`
+	DeviceModel string `json:"device_model"`
+	Tablet      bool   `json:"tablet"`
+
+	// SentAt client time in milliseconds when the batch was sent, lets SDKs that
+	// buffer events offline be corrected for a wrong device clock
+	SentAt int64 `json:"sent_at"`
+
+	// EventCount and Checksum, the hex SHA-256 of the events array as sent,
+	// let SDKs prove the batch arrived whole. Both are optional
+	EventCount *int   `json:"event_count"`
+	Checksum   string `json:"checksum"`
+
+	// ReceivedAt time the collector received the batch, earlier than now for
+	// batches spooled during maintenance. Zero means now
+	ReceivedAt time.Time `json:"-"`
+
+	// First set on the first batch of a session, lets websites in
+	// aggregate-only mode count sessions without keeping their ids
+	First bool `json:"first"`
+
+	// IP and UserAgent of the visitor, used in place of those of the request
+	// when it is sent with a server key since it comes from a backend
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	// Referrer of the page, batches from referrer spam domains are dropped
+	Referrer string `json:"referrer"`
 }
 
 // InitRoutes ...
@@ -44,9 +131,22 @@ func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
 		sessionRoutes.GET("/heatmaps", middleware.JWTMiddleware(), instance.ShowHeatmaps)
 		sessionRoutes.GET("/record", middleware.JWTMiddleware(), instance.ListWebsiteOfSessionRecord)
 		sessionRoutes.GET("/record/:website_id", middleware.JWTMiddleware(), instance.ListSessionRecord)
+		sessionRoutes.GET("/list/:website_id", middleware.JWTMiddleware(), instance.ListSessionPage)
 		sessionRoutes.POST("/receive", instance.ReceiveSession)
 		sessionRoutes.GET("/:session_id", middleware.JWTMiddleware(), instance.SessionReplay)
 		sessionRoutes.GET("/event/:session_id", middleware.JWTMiddleware(), instance.GetEventBySessionID)
+		sessionRoutes.GET("/event/:session_id/page", middleware.JWTMiddleware(), instance.GetEventPage)
+	}
+
+	// synthetic traffic for sandboxes, never served in production
+	if configs.FakeData {
+		r.POST("/website/:website_id/fake-data", middleware.JWTMiddleware(), instance.GenerateFakeData)
+	}
+
+	// Register routes segment, the write key of a website authenticates calls
+	segmentRoutes := r.Group("segment/v1")
+	{
+		segmentRoutes.POST("/:method", instance.ReceiveSegment)
 	}
 }
 
@@ -56,22 +156,38 @@ func (instance *httpDelivery) ShowHeatmaps(c *gin.Context) {
 
 // GetEventBySessionID streaming all event of session by session id
 func (instance *httpDelivery) GetEventBySessionID(c *gin.Context) {
-	c.Writer.Header().Set("Content-Type", "application/json")
-	c.Writer.WriteHeader(200)
-
 	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
 		return
 	}
 
 	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
 		return
 	}
 
 	sessionID := c.Param("session_id")
+	aWatermark, ok := instance.viewReplay(c, userID, sessionID, true)
+	if !ok {
+		return
+	}
+	if aWatermark != nil {
+		value, err := encodeWatermark(aWatermark)
+		if err != nil {
+			httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "encode watermark failed")
+			return
+		}
+		c.Writer.Header().Set(HeaderWatermark, value)
+	}
+
+	if c.Query("stream") == "true" {
+		instance.streamEvent(c, userID, sessionID)
+		return
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(200)
 	limit := 10
 	skip := 0
 
@@ -136,6 +252,144 @@ func (instance *httpDelivery) GetEventBySessionID(c *gin.Context) {
 	}
 }
 
+// streamEvent write events of session as newline delimited JSON while they are read
+func (instance *httpDelivery) streamEvent(c *gin.Context, userID, sessionID string) {
+	c.Writer.Header().Set("Content-Type", ndjson.ContentType)
+	writer := ndjson.NewWriter(c.Writer)
+	list := fields.Parse(c.Query("fields"))
+	err := instance.sessionUseCase.StreamEvent(userID, sessionID, func(anEvent *event) error {
+		if err := c.Request.Context().Err(); err != nil {
+			return err
+		}
+		response, err := fields.Select(anEvent, list)
+		if err != nil {
+			return err
+		}
+		return writer.Write(response)
+	})
+	if err != nil {
+		logrus.Error("stream events of session ", sessionID, " stopped after ", writer.Count(), " events ", err)
+	}
+}
+
+// streamSession write sessions as newline delimited JSON while they are read
+func (instance *httpDelivery) streamSession(c *gin.Context, userID, websiteID string, listSessionID []string) {
+	c.Writer.Header().Set("Content-Type", ndjson.ContentType)
+	c.Writer.WriteHeader(http.StatusOK)
+	writer := ndjson.NewWriter(c.Writer)
+	list := fields.Parse(c.Query("fields"))
+	err := instance.sessionUseCase.StreamSession(userID, websiteID, listSessionID, func(aSession session) error {
+		if err := c.Request.Context().Err(); err != nil {
+			return err
+		}
+		response, err := fields.Select(aSession, list)
+		if err != nil {
+			return err
+		}
+		return writer.Write(response)
+	})
+	if err != nil {
+		logrus.Error("stream sessions of website ", websiteID, " stopped after ", writer.Count(), " sessions ", err)
+	}
+}
+
+// ListSessionPage list sessions of website by page, sorted by start time
+func (instance *httpDelivery) ListSessionPage(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	after, err := cursor.Decode(c.Query("cursor"))
+	if err != nil {
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+		return
+	}
+	limit := cursor.Limit(c.Query("limit"), 20, 100)
+
+	websiteID := c.Param("website_id")
+	listSession, next, err := instance.sessionUseCase.GetSessionPage(userID, websiteID, c.Query("time") == "today", after, limit)
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get sessions failed")
+		return
+	}
+	response, err := fields.Select(listSession, fields.Parse(c.Query("fields")))
+	if err != nil {
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "select fields failed")
+		return
+	}
+	if listSession == nil {
+		response = []session{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"sessions":    response,
+		"next_cursor": cursor.Next(next),
+	})
+}
+
+// GetEventPage list events of session by page, sorted by timestamp
+func (instance *httpDelivery) GetEventPage(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	after, err := cursor.Decode(c.Query("cursor"))
+	if err != nil {
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+		return
+	}
+	limit := cursor.Limit(c.Query("limit"), 100, 1000)
+
+	// the first page starts a view, the next ones continue it
+	aWatermark, ok := instance.viewReplay(c, userID, c.Param("session_id"), after == nil)
+	if !ok {
+		return
+	}
+
+	events, next, err := instance.sessionUseCase.GetEventPage(userID, c.Param("session_id"), after, limit)
+	if errors.Is(err, cursor.ErrInvalidCursor) {
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+		return
+	}
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get events failed")
+		return
+	}
+	response, err := fields.Select(events, fields.Parse(c.Query("fields")))
+	if err != nil {
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "select fields failed")
+		return
+	}
+	if events == nil {
+		response = []*event{}
+	}
+	page := gin.H{
+		"events":      response,
+		"next_cursor": cursor.Next(next),
+	}
+	if aWatermark != nil {
+		page["watermark"] = aWatermark
+	}
+	c.JSON(http.StatusOK, page)
+}
+
 // SessionReplay replay session by session id
 func (instance *httpDelivery) SessionReplay(c *gin.Context) {
 	sessionID := c.Param("session_id")
@@ -143,13 +397,19 @@ func (instance *httpDelivery) SessionReplay(c *gin.Context) {
 
 	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
 		return
 	}
 
 	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	// the view is recorded when the page loads the events
+	aWatermark, ok := instance.viewReplay(c, userID, sessionID, false)
+	if !ok {
 		return
 	}
 
@@ -161,6 +421,7 @@ func (instance *httpDelivery) SessionReplay(c *gin.Context) {
 	c.HTML(http.StatusOK, "video.html", gin.H{
 		"SessionID": sessionID,
 		"Session":   aSession.MetaData,
+		"Watermark": aWatermark,
 	})
 }
 
@@ -168,13 +429,13 @@ func (instance *httpDelivery) SessionReplay(c *gin.Context) {
 func (instance *httpDelivery) ListWebsiteOfSessionRecord(c *gin.Context) {
 	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
 		return
 	}
 
 	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
 		return
 	}
 
@@ -198,13 +459,13 @@ func (instance *httpDelivery) ListSessionRecord(c *gin.Context) {
 
 	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
 		return
 	}
 
 	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
 		return
 	}
 
@@ -238,6 +499,11 @@ func (instance *httpDelivery) ListSessionRecord(c *gin.Context) {
 		}
 	}
 
+	if c.Query("stream") == "true" {
+		instance.streamSession(c, userID, websiteID, listSessionID)
+		return
+	}
+
 	if len(listSessionID) != 0 {
 		listSession, err := instance.sessionUseCase.GetAllSession(userID, websiteID, listSessionID, aSession)
 		if err != nil {
@@ -266,47 +532,114 @@ func (instance *httpDelivery) ListSessionRecord(c *gin.Context) {
 	}
 }
 
-// ReceiveSession receive session from request client
-func (instance *httpDelivery) ReceiveSession(c *gin.Context) {
-	var request RequestSession
-	var aSession session
+// acceptEvents correct timestamps of events for the client clock and drop
+// events outside the accepted time window, sorted by timestamp since offline
+// buffers may be flushed out of order
+func acceptEvents(events []event, sentAt int64, now time.Time) []event {
+	offset := eventtime.Offset(sentAt, now)
+	retention := time.Duration(db.RetentionDays) * 24 * time.Hour
+
+	accepted := events[:0]
+	for _, anEvent := range events {
+		anEvent.Timestamp += offset
+		if eventtime.Valid(anEvent.Timestamp, now, retention) {
+			accepted = append(accepted, anEvent)
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return accepted[i].Timestamp < accepted[j].Timestamp
+	})
+	return accepted
+}
 
-	err := c.ShouldBindJSON(&request)
-	if err != nil {
-		c.AbortWithStatus(http.StatusBadRequest)
-		return
+// lateEvents flag the events arriving at now once the day they happened in
+// is settled, days being those of location like in the reports. Counts the
+// events arriving after their UTC day ended by that day, the days of the
+// reconciliation and of the archive
+func lateEvents(events []event, now time.Time, location *time.Location) map[time.Time]reconcile.Late {
+	window := time.Duration(configs.LateArrivalHours) * time.Hour
+	today := now.UTC().Truncate(24 * time.Hour)
+	days := map[time.Time]reconcile.Late{}
+	for i := range events {
+		aDay := eventtime.Time(events[i].Timestamp).Truncate(24 * time.Hour)
+		aLate := days[aDay]
+		switch {
+		case eventtime.Classify(events[i].Timestamp, now, window, location) == eventtime.TooLate:
+			events[i].Late = true
+			aLate.Flagged++
+		case aDay.Before(today):
+			aLate.Merged++
+		default:
+			continue
+		}
+		days[aDay] = aLate
 	}
+	return days
+}
 
-	countSites, err := instance.websiteUseCase.FindWebsiteByID(request.UserID, request.WebsiteID)
+// mergedEvents events not flagged late, those the rollups of their day count
+func mergedEvents(events []event) []event {
+	merged := make([]event, 0, len(events))
+	for _, anEvent := range events {
+		if !anEvent.Late {
+			merged = append(merged, anEvent)
+		}
+	}
+	return merged
+}
+
+// storeSession save the events of request as a session of the device with
+// userAgent at clientIP, then hand conversions, identified visitors and
+// submitted forms over to their modules
+func (instance *httpDelivery) storeSession(request RequestSession, userAgent string, clientIP net.IP) (session, error) {
+	var aSession session
+	ua := ua.Parse(userAgent)
+	// excluded traffic is dropped before it counts against the quota
+	aSettings, err := instance.websiteUseCase.GetSettings(request.WebsiteID)
 	if err != nil {
-		c.HTML(http.StatusInternalServerError, "500.html", gin.H{})
-		return
+		return aSession, err
+	}
+	if aSettings.Excludes(clientIP, ua.Bot, request.Referrer) {
+		return aSession, ErrExcluded
 	}
 
-	if countSites > 0 {
-		logrus.Info("receive session from website id ", request.WebsiteID)
-		ua := ua.Parse(c.Request.UserAgent())
-		clientIP := net.ParseIP(realip.FromRequest(c.Request))
+	geoDB, err := geodb.Open(configs.Current().PathGeoDB)
+	if err != nil {
+		return aSession, err
+	}
+	defer geoDB.Close()
 
-		geoDB, err := geodb.Open(configs.PathGeoDB)
-		if err != nil {
-			logrus.Error(c, err)
-			return
-		}
-		defer geoDB.Close()
+	geoData, err := geoDB.City(clientIP)
+	if err != nil {
+		return aSession, err
+	}
+	// located before anything is kept, websites that cannot process the
+	// data of some countries do not store it even briefly
+	if aSettings.ExcludesCountry(geoData.Country.IsoCode) {
+		return aSession, ErrExcluded
+	}
+	// counted before anything is stored, past the quota batches may be dropped
+	aDecision := instance.usageUseCase.Check(request.UserID, request.WebsiteID, len(request.Events))
+	if aDecision.Drop {
+		return aSession, usage.ErrQuotaExceeded
+	}
+	aggregateOnly, err := instance.websiteUseCase.GetAggregateOnly(request.WebsiteID)
+	if err != nil {
+		return aSession, err
+	}
 
-		geoData, err := geoDB.City(clientIP)
-		if err != nil {
-			logrus.Error(c, err)
-			return
-		}
+	aSession.MetaData.UserID = request.UserID
+	aSession.MetaData.ID = request.SessionID
+	aSession.MetaData.WebsiteID = request.WebsiteID
+	aSession.MetaData.Platform = request.Platform
+	aSession.MetaData.Overage = aDecision.Overage
 
-		aSession.MetaData.UserID = request.UserID
-		aSession.MetaData.ID = request.SessionID
-		aSession.MetaData.WebsiteID = request.WebsiteID
+	if request.Platform == PlatformWeb {
 		aSession.MetaData.OS = ua.OS
+		aSession.MetaData.OSVersion = ua.OSVersion
 		aSession.MetaData.Browser = ua.Name
 		aSession.MetaData.Version = ua.Version
+		aSession.MetaData.Referrer = spam.Host(request.Referrer)
 
 		if ua.Mobile {
 			aSession.MetaData.Device = "Mobile"
@@ -317,82 +650,261 @@ func (instance *httpDelivery) ReceiveSession(c *gin.Context) {
 		if ua.Desktop {
 			aSession.MetaData.Device = "Desktop"
 		}
+	} else {
+		aSession.MetaData.OS = request.OS
+		aSession.MetaData.OSVersion = request.OSVersion
+		aSession.MetaData.AppVersion = request.AppVersion
+		aSession.MetaData.DeviceModel = request.DeviceModel
+		aSession.MetaData.Device = "Mobile"
+		if request.Tablet {
+			aSession.MetaData.Device = "Tablet"
+		}
+	}
 
-		aSession.MetaData.Country = geoData.Country.Names["en"]
-		aSession.MetaData.City = str.RemoveSubstring(geoData.City.Names["en"], "City")
-
-		events := request.Events
+	aSession.MetaData.Country = geoData.Country.Names["en"]
+	aSession.MetaData.City = str.RemoveSubstring(geoData.City.Names["en"], "City")
+	aSession.MetaData.CountryCode = geoData.Country.IsoCode
+	if len(geoData.Subdivisions) > 0 && geoData.Country.IsoCode != "" && geoData.Subdivisions[0].IsoCode != "" {
+		aSession.MetaData.Region = geoData.Subdivisions[0].Names["en"]
+		aSession.MetaData.RegionCode = geoData.Country.IsoCode + "-" + geoData.Subdivisions[0].IsoCode
+	}
 
-		countSession, err := instance.sessionUseCase.GetCountSession(request.UserID, request.SessionID)
+	now := request.ReceivedAt
+	if now.IsZero() {
+		now = time.Now()
+	}
+	late := lateEvents(request.Events, now, aSettings.Location())
+
+	if aggregateOnly {
+		// nothing of the batch is kept but what it adds to the counters, the
+		// counters of days settled are left as they are
+		merged := request
+		merged.Events = mergedEvents(request.Events)
+		err = instance.aggregateUseCase.Record(request.UserID, request.WebsiteID, aggregateBatch(merged, aSession.MetaData))
 		if err != nil {
-			logrus.Error(c, err)
-			return
+			return aSession, err
 		}
-		if countSession == 0 {
-			if len(events) != 0 {
-				time1 := events[0].Timestamp / 1000
-				time2 := events[len(events)-1].Timestamp / 1000
-				duration := dur.Duration(time1, time2)
+		instance.markTracked(request.WebsiteID)
+		instance.recordLate(request.UserID, request.WebsiteID, late)
+		return aSession, nil
+	}
 
-				aSession.Duration = duration
+	events := request.Events
 
-				timeReport, err := dur.ParseTime(time.Unix(time1, 0).Format("2006-01-02, 15:04:05"))
-				if err != nil {
-					logrus.Error(c, err)
-					return
-				}
-				aSession.TimeReport = timeReport
-				aSession.MetaData.CreatedAt = time.Unix(time1, 0).Format("2006-01-02, 15:04:05")
+	countSession, err := instance.sessionUseCase.GetCountSession(request.UserID, request.SessionID)
+	if err != nil {
+		return aSession, err
+	}
+	if countSession == 0 {
+		if len(events) != 0 {
+			time1 := events[0].Timestamp / 1000
+			time2 := events[len(events)-1].Timestamp / 1000
+			duration := dur.Duration(time1, time2)
 
-				// save time1 of session id to redis
-				err = instance.sessionUseCase.InsertSessionTimestamp(request.SessionID, time1)
-				if err != nil {
-					logrus.Error(c, err)
-					return
-				}
-			} else {
-				aSession.Duration = "00:00:00"
+			aSession.Duration = duration
 
-				timeReport, err := dur.ParseTime(time.Now().Format("2006-01-02, 15:04:05"))
-				if err != nil {
-					logrus.Error(c, err)
-					return
-				}
-				aSession.TimeReport = timeReport
-				aSession.MetaData.CreatedAt = time.Now().Format("2006-01-02, 15:04:05")
+			timeReport, err := dur.ParseTime(time.Unix(time1, 0).Format("2006-01-02, 15:04:05"))
+			if err != nil {
+				return aSession, err
+			}
+			aSession.TimeReport = timeReport
+			aSession.MetaData.CreatedAt = time.Unix(time1, 0).Format("2006-01-02, 15:04:05")
+
+			// save time1 of session id to the key store
+			err = instance.sessionUseCase.InsertSessionTimestamp(request.SessionID, time1)
+			if err != nil {
+				return aSession, err
 			}
 		} else {
-			if len(events) != 0 {
-				// get time1 by session id from redis
-				time1, err := instance.sessionUseCase.GetSessionTimestamp(request.SessionID)
-				if err != nil {
-					logrus.Error(c, err)
-					return
-				}
-				time2 := events[len(events)-1].Timestamp / 1000
-				duration := dur.Duration(time1, time2)
-				aSession.Duration = duration
+			aSession.Duration = "00:00:00"
 
-				timeReport, err := dur.ParseTime(time.Now().Format("2006-01-02, 15:04:05"))
-				if err != nil {
-					logrus.Error(c, err)
-					return
-				}
-				aSession.TimeReport = timeReport
-				aSession.MetaData.CreatedAt = time.Unix(time1, 0).Format("2006-01-02, 15:04:05")
+			timeReport, err := dur.ParseTime(now.Format("2006-01-02, 15:04:05"))
+			if err != nil {
+				return aSession, err
 			}
+			aSession.TimeReport = timeReport
+			aSession.MetaData.CreatedAt = now.Format("2006-01-02, 15:04:05")
 		}
+	} else {
+		if len(events) != 0 {
+			// get time1 by session id from the key store
+			time1, err := instance.sessionUseCase.GetSessionTimestamp(request.SessionID)
+			if err != nil {
+				return aSession, err
+			}
+			time2 := events[len(events)-1].Timestamp / 1000
+			duration := dur.Duration(time1, time2)
+			aSession.Duration = duration
+			aSession.TimeReport = eventtime.Time(events[len(events)-1].Timestamp)
+			aSession.MetaData.CreatedAt = time.Unix(time1, 0).Format("2006-01-02, 15:04:05")
+		}
+	}
 
-		// save session
-		err = instance.sessionUseCase.InsertSession(aSession, events)
-		if err != nil {
-			logrus.Error(c, err)
+	conversions := adConversions(request.SessionID, events)
+	identified := identifies(request.SessionID, events)
+	submits := formSubmits(request.SessionID, events)
+	live := liveEvents(request.SessionID, events)
+
+	// save session
+	err = instance.sessionUseCase.InsertSession(aSession, events)
+	if err != nil {
+		return aSession, err
+	}
+	instance.markTracked(request.WebsiteID)
+	instance.recordLate(request.UserID, request.WebsiteID, late)
+	if len(events) > 0 {
+		go instance.firehoseUseCase.Publish(request.UserID, firehoseEvents(aSession, events))
+	}
+	if len(conversions) > 0 {
+		go instance.integrationUseCase.Forward(request.UserID, request.WebsiteID, conversions)
+	}
+	if len(identified) > 0 || len(submits) > 0 || len(live) > 0 {
+		// identify first so goals reached and events watched in the same
+		// batch are attributed
+		go func() {
+			instance.visitorUseCase.Identify(request.UserID, request.WebsiteID, identified)
+			instance.visitorUseCase.Reach(request.UserID, request.WebsiteID, submits)
+			instance.visitorUseCase.PublishLive(request.WebsiteID, request.SessionID, live)
+		}()
+	}
+	return aSession, nil
+}
+
+// recordLate count the late events of a batch of website stored, in the
+// background since the batch is stored already
+func (instance *httpDelivery) recordLate(userID, websiteID string, late map[time.Time]reconcile.Late) {
+	if len(late) > 0 {
+		go instance.reconcileUseCase.RecordLate(userID, websiteID, late)
+	}
+}
+
+// markTracked record the batch of website as its last event, errors are
+// logged since the batch is stored already
+func (instance *httpDelivery) markTracked(websiteID string) {
+	if err := instance.websiteUseCase.MarkTracked(websiteID); err != nil {
+		logrus.Error("mark website tracked error ", err)
+	}
+}
+
+// ReceiveSession receive session from request client
+func (instance *httpDelivery) ReceiveSession(c *gin.Context) {
+	var request RequestSession
+	// the events as sent, the checksum is over their exact bytes
+	var raw struct {
+		Events json.RawMessage `json:"events"`
+	}
+
+	body, err := c.GetRawData()
+	if err == nil {
+		err = json.Unmarshal(body, &request)
+	}
+	if err == nil && request.Checksum != "" {
+		err = json.Unmarshal(body, &raw)
+	}
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if request.Platform == "" {
+		request.Platform = PlatformWeb
+	}
+	if request.Platform != PlatformWeb && request.Platform != PlatformIOS && request.Platform != PlatformAndroid {
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, "platform must be web, ios or android")
+		return
+	}
+
+	userAgent, ip := c.Request.UserAgent(), net.ParseIP(realip.FromRequest(c.Request))
+	if serverKey := c.GetHeader(HeaderServerKey); serverKey != "" {
+		request.UserID, request.WebsiteID, err = instance.websiteUseCase.FindServerKey(serverKey)
+		if err == mongo.ErrNoDocuments {
+			httperr.Abort(c, http.StatusUnauthorized, CodeInvalidServerKey, "invalid server key")
 			return
 		}
-		c.JSON(http.StatusOK, aSession)
+		if request.UserAgent != "" {
+			userAgent = request.UserAgent
+		}
+		if request.IP != "" {
+			ip = net.ParseIP(request.IP)
+			if ip == nil {
+				httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, "ip of the visitor is invalid")
+				return
+			}
+		}
 	} else {
+		request.UserID, request.WebsiteID, err = instance.websiteUseCase.ResolveWebsite(request.UserID, request.WebsiteID, request.HostName)
+	}
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "resolve website failed")
+		return
+	}
+	err = instance.websiteUseCase.Accepts(request.UserID, request.WebsiteID)
+	switch err {
+	case nil:
+	case mongo.ErrNoDocuments:
 		logrus.Info("this site id not exists ", request.WebsiteID)
-		c.JSON(http.StatusConflict, gin.H{"msg": "this website not exists"})
+		httperr.Abort(c, http.StatusConflict, website.CodeWebsiteNotFound, "this website not exists")
+		return
+	case website.ErrNotVerified:
+		httperr.Abort(c, http.StatusForbidden, website.CodeNotVerified, err.Error())
+		return
+	case website.ErrArchived:
+		httperr.Abort(c, http.StatusGone, website.CodeArchived, err.Error())
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "check website failed")
+		return
+	}
+
+	// batches are counted once the website is known, so unknown ids leave no trace
+	received := len(request.Events)
+	aBatch := reconcile.Batch{Sent: int64(received), Received: int64(received)}
+	if request.EventCount != nil {
+		aBatch.Sent = int64(*request.EventCount)
+	}
+	err = reconcile.Verify(raw.Events, received, request.EventCount, request.Checksum)
+	if err != nil {
+		logrus.Info("corrupt batch of session ", request.SessionID, ": ", err)
+		go instance.reconcileUseCase.Record(request.UserID, request.WebsiteID, reconcile.Batch{Corrupt: true})
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	request.ReceivedAt = maintenance.ReceivedAt(c.Request)
+	request.Events = acceptEvents(request.Events, request.SentAt, request.ReceivedAt)
+	if rejected := received - len(request.Events); rejected > 0 {
+		aBatch.Rejected = int64(rejected)
+		logrus.Info("rejected ", rejected, " events outside the accepted time window of session ", request.SessionID)
+		c.Header("X-Events-Rejected", strconv.Itoa(rejected))
+		if len(request.Events) == 0 {
+			go instance.reconcileUseCase.Record(request.UserID, request.WebsiteID, aBatch)
+			httperr.Abort(c, http.StatusUnprocessableEntity, CodeEventsRejected, "events are in the future or older than retention")
+			return
+		}
+	}
+
+	logrus.Info("receive session from website id ", request.WebsiteID)
+	aSession, err := instance.storeSession(request, userAgent, ip)
+	switch err {
+	case nil:
+		aBatch.Stored = int64(len(request.Events))
+	case usage.ErrQuotaExceeded, ErrExcluded:
+		aBatch.Rejected += int64(len(request.Events))
+	}
+	go instance.reconcileUseCase.Record(request.UserID, request.WebsiteID, aBatch)
+	if err == usage.ErrQuotaExceeded {
+		httperr.Abort(c, http.StatusTooManyRequests, CodeQuotaExceeded, err.Error())
+		return
+	}
+	if err == ErrExcluded {
+		// acknowledged so the tracker does not send the batch again
+		c.JSON(http.StatusAccepted, gin.H{"excluded": true})
+		return
+	}
+	if err != nil {
+		logrus.Error(c, err)
 		return
 	}
+	c.JSON(http.StatusOK, aSession)
 }
@@ -0,0 +1,150 @@
+package session
+
+import (
+	"time"
+
+	"analytics-api/internal/pkg/cursor"
+
+	"github.com/sirupsen/logrus"
+)
+
+// dualRepository write events to both backends and read from the primary one,
+// used while migrating event storage. Write failures of the secondary backend
+// are logged and left to the consistency checker.
+type dualRepository struct {
+	primary   Repository
+	secondary Repository
+}
+
+func (instance *dualRepository) GetAllSession(userID, websiteID string, listSessionID []string, aSession session) ([]session, error) {
+	return instance.primary.GetAllSession(userID, websiteID, listSessionID, aSession)
+}
+
+func (instance *dualRepository) GetAllSessionID(userID, websiteID string, aSession session) ([]string, error) {
+	return instance.primary.GetAllSessionID(userID, websiteID, aSession)
+}
+
+func (instance *dualRepository) GetSessionIDToday(userID, websiteID string, aSession session) ([]string, error) {
+	return instance.primary.GetSessionIDToday(userID, websiteID, aSession)
+}
+
+func (instance *dualRepository) GetSession(userID, sessionID string, aSession *session) error {
+	return instance.primary.GetSession(userID, sessionID, aSession)
+}
+
+func (instance *dualRepository) GetCountSession(userID, sessionID string) (int64, error) {
+	return instance.primary.GetCountSession(userID, sessionID)
+}
+
+func (instance *dualRepository) CountSessionSince(userID, websiteID string, since time.Time) (int64, error) {
+	return instance.primary.CountSessionSince(userID, websiteID, since)
+}
+
+func (instance *dualRepository) CountSessionsSince(userID string, websiteIDs []string, since []time.Time) (map[string][]int64, error) {
+	return instance.primary.CountSessionsSince(userID, websiteIDs, since)
+}
+
+func (instance *dualRepository) Breakdown(userID, websiteID, dimension string, filter BreakdownFilter) ([]Bucket, error) {
+	return instance.primary.Breakdown(userID, websiteID, dimension, filter)
+}
+
+func (instance *dualRepository) HeatTable(userID, websiteID string, filter BreakdownFilter, location *time.Location) ([]HeatCell, error) {
+	return instance.primary.HeatTable(userID, websiteID, filter, location)
+}
+
+func (instance *dualRepository) Daily(userID, websiteID string, filter BreakdownFilter, location *time.Location) ([]Day, error) {
+	return instance.primary.Daily(userID, websiteID, filter, location)
+}
+
+func (instance *dualRepository) Pages(userID, websiteID string, filter BreakdownFilter) ([]Page, error) {
+	return instance.primary.Pages(userID, websiteID, filter)
+}
+
+func (instance *dualRepository) PageBreakdown(userID, websiteID, dimension string, filter BreakdownFilter) ([]PageBucket, error) {
+	return instance.primary.PageBreakdown(userID, websiteID, dimension, filter)
+}
+
+func (instance *dualRepository) Engagement(userID, websiteID string, filter BreakdownFilter) ([]PageEngagement, error) {
+	return instance.primary.Engagement(userID, websiteID, filter)
+}
+
+func (instance *dualRepository) Visits(userID, websiteID string, filter BreakdownFilter) (*Visits, error) {
+	return instance.primary.Visits(userID, websiteID, filter)
+}
+
+func (instance *dualRepository) Totals(userID, websiteID, dimension string, forms []string, filter BreakdownFilter) ([]Totals, error) {
+	return instance.primary.Totals(userID, websiteID, dimension, forms, filter)
+}
+
+func (instance *dualRepository) Forms(userID, websiteID string, filter BreakdownFilter) ([]FormStats, error) {
+	return instance.primary.Forms(userID, websiteID, filter)
+}
+
+func (instance *dualRepository) FormSubmissions(userID, websiteID, formID string, filter BreakdownFilter) (int64, error) {
+	return instance.primary.FormSubmissions(userID, websiteID, formID, filter)
+}
+
+func (instance *dualRepository) FormFunnel(userID, websiteID, formID string, filter BreakdownFilter) (*FormFunnel, error) {
+	return instance.primary.FormFunnel(userID, websiteID, formID, filter)
+}
+
+func (instance *dualRepository) InsertSession(aSession session, anEvent event) error {
+	err := instance.primary.InsertSession(aSession, anEvent)
+	if err != nil {
+		return err
+	}
+	if err := instance.secondary.InsertSession(aSession, anEvent); err != nil {
+		logrus.Error("dual write to secondary storage error ", err)
+	}
+	return nil
+}
+
+func (instance *dualRepository) GetEventByLimitSkip(userID, sessionID string, limit, skip int) ([]*event, error) {
+	return instance.primary.GetEventByLimitSkip(userID, sessionID, limit, skip)
+}
+
+func (instance *dualRepository) StreamSession(userID, websiteID string, listSessionID []string, fn func(session) error) error {
+	return instance.primary.StreamSession(userID, websiteID, listSessionID, fn)
+}
+
+func (instance *dualRepository) StreamEvent(userID, sessionID string, fn func(*event) error) error {
+	return instance.primary.StreamEvent(userID, sessionID, fn)
+}
+
+func (instance *dualRepository) GetSessionPage(userID, websiteID string, today bool, after *cursor.Cursor, limit int) ([]string, *cursor.Cursor, error) {
+	return instance.primary.GetSessionPage(userID, websiteID, today, after, limit)
+}
+
+// GetEventPage cursors are specific to each backend, they stay valid until the
+// primary backend changes
+func (instance *dualRepository) GetEventPage(userID, sessionID string, after *cursor.Cursor, limit int) ([]*event, *cursor.Cursor, error) {
+	return instance.primary.GetEventPage(userID, sessionID, after, limit)
+}
+
+// session timestamps live in the key store, shared by both backends
+func (instance *dualRepository) GetSessionTimestamp(sessionID string) (int64, error) {
+	return instance.primary.GetSessionTimestamp(sessionID)
+}
+
+func (instance *dualRepository) InsertSessionTimestamp(sessionID string, timeStart int64) error {
+	return instance.primary.InsertSessionTimestamp(sessionID, timeStart)
+}
+
+func (instance *dualRepository) StreamRawEvent(userID, websiteID string, from, to time.Time, fn func(RawEvent) error) error {
+	return instance.primary.StreamRawEvent(userID, websiteID, from, to, fn)
+}
+
+func (instance *dualRepository) SegmentSessionID(websiteID, anonymousID string) (string, error) {
+	return instance.primary.SegmentSessionID(websiteID, anonymousID)
+}
+
+func (instance *dualRepository) DeleteSessionBefore(before time.Time, keep []string) (int64, error) {
+	count, err := instance.primary.DeleteSessionBefore(before, keep)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := instance.secondary.DeleteSessionBefore(before, keep); err != nil {
+		logrus.Error("dual delete on secondary storage error ", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,132 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/clickhouse"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// HeartbeatTag tag of the custom event record.js sends every 15 seconds with
+// the engaged time since the previous one and the deepest scroll of the page
+const HeartbeatTag = "heartbeat"
+
+const (
+	// readMinDepth scroll depth in percent a view must reach to count as read
+	readMinDepth = 90
+	// readMinEngaged engaged milliseconds a view must last to count as read, so
+	// scrolling straight to the footer is not reading
+	readMinEngaged = 15 * 1000
+)
+
+// PageEngagement engaged time and reads of the views of a path with heartbeats
+type PageEngagement struct {
+	Path      string `json:"path"`
+	Views     int64  `json:"views"`
+	EngagedMs int64  `json:"engaged_ms"`
+	Reads     int64  `json:"reads"`
+}
+
+// Engagement engaged time and reads of website by path
+func (instance *repository) Engagement(userID, websiteID string, filter BreakdownFilter) ([]PageEngagement, error) {
+	match := []bson.M{
+		{"meta_data.user_id": userID},
+		{"meta_data.website_id": websiteID},
+		onTime,
+		{"time_report": bson.M{"$gte": filter.From, "$lt": filter.To}},
+		{"event.data.tag": HeartbeatTag},
+	}
+	read := bson.M{"$cond": []interface{}{
+		bson.M{"$and": []bson.M{
+			{"$gte": []interface{}{"$depth", readMinDepth}},
+			{"$gte": []interface{}{"$engaged", readMinEngaged}},
+		}},
+		1,
+		0,
+	}}
+	pipeline := []bson.M{
+		{"$match": bson.M{"$and": match}},
+		{"$group": bson.M{
+			"_id": bson.M{
+				"path": "$event.data.payload.path",
+				"view": "$event.data.payload.view_id",
+				"id":   "$meta_data.id",
+			},
+			"engaged": bson.M{"$sum": "$event.data.payload.engaged_ms"},
+			"depth":   bson.M{"$max": "$event.data.payload.scroll_depth"},
+		}},
+		{"$group": bson.M{
+			"_id":        "$_id.path",
+			"views":      bson.M{"$sum": 1},
+			"engaged_ms": bson.M{"$sum": "$engaged"},
+			"reads":      bson.M{"$sum": read},
+		}},
+	}
+
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	cur, err := db.Aggregate(context.TODO(), sessionCollection, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	var pages []PageEngagement
+	for cur.Next(context.TODO()) {
+		var row struct {
+			Path      *string `bson:"_id"`
+			Views     int64   `bson:"views"`
+			EngagedMs int64   `bson:"engaged_ms"`
+			Reads     int64   `bson:"reads"`
+		}
+		if err := cur.Decode(&row); err != nil {
+			return nil, err
+		}
+		aPage := PageEngagement{Views: row.Views, EngagedMs: row.EngagedMs, Reads: row.Reads}
+		if row.Path != nil {
+			aPage.Path = *row.Path
+		}
+		pages = append(pages, aPage)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// Engagement engaged time and reads of website by path
+func (instance *clickHouseRepository) Engagement(userID, websiteID string, filter BreakdownFilter) ([]PageEngagement, error) {
+	params := instance.params(userID)
+	params["website"] = websiteID
+	params["from"] = clickhouse.TimeParam(filter.From)
+	params["to"] = clickhouse.TimeParam(filter.To)
+	params["tag"] = HeartbeatTag
+	views := "SELECT JSONExtractString(data, 'payload', 'path') AS path," +
+		" sum(JSONExtractInt(data, 'payload', 'engaged_ms')) AS engaged," +
+		" max(JSONExtractInt(data, 'payload', 'scroll_depth')) AS depth FROM " + db.ClickHouseEventTable +
+		" WHERE " + clickHouseReportFilter + " AND website_id = {website:String}" +
+		" AND time_report >= {from:DateTime64(3)} AND time_report < {to:DateTime64(3)}" +
+		" AND JSONExtractString(data, 'tag') = {tag:String}" +
+		" GROUP BY path, id, JSONExtractString(data, 'payload', 'view_id')"
+	query := "SELECT path, count() AS views, sum(engaged) AS engaged_ms," +
+		" countIf(depth >= " + strconv.Itoa(readMinDepth) + " AND engaged >= " + strconv.Itoa(readMinEngaged) + ") AS reads" +
+		" FROM (" + views + ") GROUP BY path"
+
+	var pages []PageEngagement
+	err := configs.ClickHouse.Client.Query(query, params, func(line []byte) error {
+		var row PageEngagement
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		pages = append(pages, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
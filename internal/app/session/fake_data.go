@@ -0,0 +1,154 @@
+package session
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"analytics-api/internal/app/usage"
+	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/faker"
+	"analytics-api/internal/pkg/httperr"
+	req "analytics-api/internal/pkg/request"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// maxFakeVisits visits are stored one by one, like tracked sessions
+	maxFakeVisits = 1000
+	maxFakeDays   = 30
+)
+
+// RequestFakeData visits over the last days, a seed gives the same visits
+// every time and a random one is used when it is 0
+type RequestFakeData struct {
+	Visits int   `json:"visits"`
+	Days   int   `json:"days"`
+	Seed   int64 `json:"seed"`
+}
+
+// fakeEvents events the tracker would send for aVisit to the website at url
+func fakeEvents(url string, aVisit faker.Visit) []event {
+	var events []event
+	for i, view := range aVisit.Pages {
+		at := view.At.UnixMilli()
+		path := faker.PathOf(view)
+		viewID := strings.ReplaceAll(uuid.New().String(), "-", "")[:12]
+		events = append(events,
+			event{Type: metaEventType, Timestamp: at, Data: bson.M{"href": url + view.Path, "width": 1440, "height": 900}},
+			event{Type: customEventType, Timestamp: at + view.EngagedMs, Data: bson.M{"tag": HeartbeatTag, "payload": map[string]interface{}{
+				"path": path, "view_id": viewID, "engaged_ms": view.EngagedMs, "scroll_depth": view.ScrollDepth,
+			}}},
+		)
+		if aForm := aVisit.Form; aForm != nil && aForm.Path == path && (i == len(aVisit.Pages)-1 || aForm.At.Before(aVisit.Pages[i+1].At)) {
+			tag := FormAbandonTag
+			if aForm.Submitted {
+				tag = FormSubmitTag
+			}
+			events = append(events,
+				event{Type: customEventType, Timestamp: aForm.At.UnixMilli(), Data: bson.M{"tag": FormStartTag, "payload": map[string]interface{}{"path": path, "form_id": aForm.ID}}},
+				event{Type: customEventType, Timestamp: aForm.At.UnixMilli() + 20000, Data: bson.M{"tag": tag, "payload": map[string]interface{}{"path": path, "form_id": aForm.ID}}},
+			)
+		}
+	}
+	return events
+}
+
+// GenerateFakeData store synthetic visits into a website, so new accounts
+// see populated reports and SDK developers can test them. Only served when
+// FAKE_DATA is set, never in production
+func (instance *httpDelivery) GenerateFakeData(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	// the body is optional, every field has a default
+	request, err := req.BindAndValidate[RequestFakeData](c)
+	if err != nil && err != io.EOF {
+		req.BadRequest(c, "invalid fake data request", err)
+		return
+	}
+	if request.Visits <= 0 {
+		request.Visits = 200
+	}
+	if request.Visits > maxFakeVisits {
+		request.Visits = maxFakeVisits
+	}
+	if request.Days <= 0 {
+		request.Days = 7
+	}
+	if request.Days > maxFakeDays {
+		request.Days = maxFakeDays
+	}
+	if request.Seed == 0 {
+		request.Seed = time.Now().UnixNano()
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	url, err := instance.websiteUseCase.GetURL(userID, websiteID)
+	switch err {
+	case nil:
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, website.CodeWebsiteNotFound, "this website not exists")
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get website failed")
+		return
+	}
+	location, err := instance.websiteUseCase.GetLocation(userID, websiteID)
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get website failed")
+		return
+	}
+
+	now := time.Now()
+	visits := faker.Generate(rand.New(rand.NewSource(request.Seed)), request.Visits, now.AddDate(0, 0, -request.Days), now, location)
+	stored := 0
+	events := 0
+	for _, aVisit := range visits {
+		aRequest := RequestSession{
+			UserID:    userID,
+			WebsiteID: websiteID,
+			SessionID: strings.ReplaceAll(uuid.New().String()+uuid.New().String(), "-", ""),
+			Platform:  PlatformWeb,
+			Events:    acceptEvents(fakeEvents(url, aVisit), 0, now),
+		}
+		if len(aRequest.Events) == 0 {
+			continue
+		}
+		_, err := instance.storeSession(aRequest, aVisit.UserAgent, aVisit.IP)
+		if err == ErrExcluded {
+			continue
+		}
+		if err == usage.ErrQuotaExceeded {
+			httperr.AbortWithDetails(c, http.StatusTooManyRequests, CodeQuotaExceeded, err.Error(), gin.H{"visits": stored, "events": events})
+			return
+		}
+		if err != nil {
+			logrus.Error(c, err)
+			httperr.AbortWithDetails(c, http.StatusInternalServerError, httperr.CodeInternal, "store fake data failed", gin.H{"visits": stored, "events": events})
+			return
+		}
+		stored++
+		events += len(aRequest.Events)
+	}
+	c.JSON(http.StatusOK, gin.H{"visits": stored, "events": events, "seed": request.Seed})
+}
@@ -0,0 +1,212 @@
+package session
+
+import (
+	"encoding/json"
+	"regexp"
+	"time"
+)
+
+// ArchiveScan call fn with each archived event of a range, stats hands the
+// scan of the archive module over so session does not depend on it
+type ArchiveScan func(fn func(RawEvent) error) error
+
+var hrefPathRegexp = regexp.MustCompile(hrefPath)
+
+// ArchivedBreakdown count sessions of the archived events of scan by value of
+// dimension, filtered like Breakdown
+func ArchivedBreakdown(dimension string, filter BreakdownFilter, scan ArchiveScan) ([]Bucket, error) {
+	if !breakdownFields[dimension] {
+		return nil, ErrInvalidDimension
+	}
+	seen := map[[2]string]bool{}
+	counts := map[string]int64{}
+	err := scan(func(anEvent RawEvent) error {
+		if !filter.matches(anEvent) {
+			return nil
+		}
+		key := anEvent.field(dimension)
+		if seen[[2]string{key, anEvent.SessionID}] {
+			return nil
+		}
+		seen[[2]string{key, anEvent.SessionID}] = true
+		counts[key]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var buckets []Bucket
+	for key, sessions := range counts {
+		buckets = append(buckets, Bucket{Key: key, Sessions: sessions})
+	}
+	return sortBuckets(dimension, buckets), nil
+}
+
+// MergeBuckets add up the buckets of the hot store and of the archive. A
+// session reported on both sides of the retention boundary counts twice
+func MergeBuckets(dimension string, hot, archived []Bucket) []Bucket {
+	counts := map[string]int64{}
+	for _, bucket := range append(hot, archived...) {
+		counts[bucket.Key] += bucket.Sessions
+	}
+	buckets := []Bucket{}
+	for key, sessions := range counts {
+		buckets = append(buckets, Bucket{Key: key, Sessions: sessions})
+	}
+	return sortBuckets(dimension, buckets)
+}
+
+// ArchivedHeatTable sessions and pageviews of the archived events of scan by
+// weekday and hour in location, like HeatTable
+func ArchivedHeatTable(filter BreakdownFilter, location *time.Location, scan ArchiveScan) ([]HeatCell, error) {
+	type hour struct {
+		weekday, hour int
+	}
+	seen := map[hour]map[string]bool{}
+	cells := map[hour]*HeatCell{}
+	err := scan(func(anEvent RawEvent) error {
+		if !filter.matches(anEvent) {
+			return nil
+		}
+		local := anEvent.TimeReport.In(location)
+		at := hour{int(local.Weekday()), local.Hour()}
+		if cells[at] == nil {
+			cells[at] = &HeatCell{Weekday: at.weekday, Hour: at.hour}
+			seen[at] = map[string]bool{}
+		}
+		if !seen[at][anEvent.SessionID] {
+			seen[at][anEvent.SessionID] = true
+			cells[at].Sessions++
+		}
+		if anEvent.Type == metaEventType || anEvent.tag() == ScreenViewTag {
+			cells[at].Pageviews++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var listCell []HeatCell
+	for _, cell := range cells {
+		listCell = append(listCell, *cell)
+	}
+	return listCell, nil
+}
+
+// ArchivedPages sessions and pageviews by path of the archived events of
+// scan, like Pages, under the canonical url of pages with canonical. Sealed
+// events have no href and count for no page
+func ArchivedPages(scan ArchiveScan, canonical bool) ([]Page, error) {
+	seen := map[[2]string]bool{}
+	pages := map[string]*Page{}
+	err := scan(func(anEvent RawEvent) error {
+		if anEvent.Type != metaEventType || anEvent.Data == "" {
+			return nil
+		}
+		var data struct {
+			Href      string `json:"href"`
+			Canonical string `json:"canonical"`
+		}
+		if err := json.Unmarshal([]byte(anEvent.Data), &data); err != nil {
+			return nil
+		}
+		if canonical && data.Canonical != "" {
+			data.Href = data.Canonical
+		}
+		path := ""
+		if match := hrefPathRegexp.FindStringSubmatch(data.Href); match != nil {
+			path = match[1]
+		}
+		if pages[path] == nil {
+			pages[path] = &Page{Path: path}
+		}
+		pages[path].Pageviews++
+		if !seen[[2]string{path, anEvent.SessionID}] {
+			seen[[2]string{path, anEvent.SessionID}] = true
+			pages[path].Sessions++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var listPage []Page
+	for _, aPage := range pages {
+		listPage = append(listPage, *aPage)
+	}
+	return sortPages(listPage), nil
+}
+
+// MergePages add up the pages of the hot store and of the archive
+func MergePages(hot, archived []Page) []Page {
+	return sortPages(append(append([]Page{}, hot...), archived...))
+}
+
+// matches tell if the session of anEvent passes filter, the range is left to
+// the scan. Events flagged late are left out like in the hot store
+func (instance BreakdownFilter) matches(anEvent RawEvent) bool {
+	if anEvent.Late {
+		return false
+	}
+	switch instance.Platform {
+	case "":
+	case PlatformWeb:
+		if anEvent.Platform != PlatformWeb && anEvent.Platform != "" {
+			return false
+		}
+	default:
+		if anEvent.Platform != instance.Platform {
+			return false
+		}
+	}
+	if instance.CountryCode != "" && anEvent.CountryCode != instance.CountryCode {
+		return false
+	}
+	if instance.RegionCode != "" && anEvent.RegionCode != instance.RegionCode {
+		return false
+	}
+	return true
+}
+
+// field value of a breakdown dimension
+func (instance RawEvent) field(dimension string) string {
+	switch dimension {
+	case "platform":
+		return instance.Platform
+	case "os":
+		return instance.OS
+	case "os_version":
+		return instance.OSVersion
+	case "device":
+		return instance.Device
+	case "device_model":
+		return instance.DeviceModel
+	case "browser":
+		return instance.Browser
+	case "app_version":
+		return instance.AppVersion
+	case "country":
+		return instance.Country
+	case "country_code":
+		return instance.CountryCode
+	case "region":
+		return instance.Region
+	case "region_code":
+		return instance.RegionCode
+	case "city":
+		return instance.City
+	}
+	return ""
+}
+
+// tag of a custom event, empty for the others and sealed ones
+func (instance RawEvent) tag() string {
+	if instance.Type != customEventType || instance.Data == "" {
+		return ""
+	}
+	var data struct {
+		Tag string `json:"tag"`
+	}
+	json.Unmarshal([]byte(instance.Data), &data)
+	return data.Tag
+}
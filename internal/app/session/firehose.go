@@ -0,0 +1,38 @@
+package session
+
+import (
+	"analytics-api/internal/app/firehose"
+)
+
+// firehoseEvents events of aSession enriched with its device and location, as
+// streamed to firehose destinations
+func firehoseEvents(aSession session, events []event) []firehose.Event {
+	metaData := aSession.MetaData
+	result := make([]firehose.Event, 0, len(events))
+	for _, anEvent := range events {
+		tag := ""
+		if anEvent.Type == customEventType {
+			tag, _ = anEvent.Data["tag"].(string)
+		}
+		result = append(result, firehose.Event{
+			WebsiteID:   metaData.WebsiteID,
+			SessionID:   metaData.ID,
+			Type:        anEvent.Type,
+			Tag:         tag,
+			Data:        anEvent.Data,
+			Timestamp:   anEvent.Timestamp,
+			Platform:    metaData.Platform,
+			Country:     metaData.Country,
+			CountryCode: metaData.CountryCode,
+			Region:      metaData.Region,
+			RegionCode:  metaData.RegionCode,
+			City:        metaData.City,
+			Device:      metaData.Device,
+			OS:          metaData.OS,
+			OSVersion:   metaData.OSVersion,
+			Browser:     metaData.Browser,
+			AppVersion:  metaData.AppVersion,
+		})
+	}
+	return result
+}
@@ -0,0 +1,175 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/clickhouse"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// FormFieldTag custom event of a field left after focus, with the time spent
+// in it and whether an error was shown. Values are never sent
+const FormFieldTag = "form_field"
+
+// FormField sessions interacting with a field of a form
+type FormField struct {
+	Field string `json:"field"`
+	// Index position of the field in the form
+	Index     int64 `json:"index"`
+	Sessions  int64 `json:"sessions"`
+	AvgTimeMs int64 `json:"avg_time_ms"`
+	// Errors sessions shown an error on the field
+	Errors int64 `json:"errors"`
+}
+
+// FormFunnel sessions starting a form, interacting with each field and submitting it
+type FormFunnel struct {
+	Starts  int64       `json:"starts"`
+	Submits int64       `json:"submits"`
+	Fields  []FormField `json:"fields"`
+}
+
+// formFieldRow sessions sending a form event, field is empty but for FormFieldTag
+type formFieldRow struct {
+	Tag      string `json:"tag"`
+	Field    string `json:"field"`
+	Index    int64  `json:"index"`
+	Sessions int64  `json:"sessions"`
+	TimeMs   int64  `json:"time_ms"`
+	Errors   int64  `json:"errors"`
+}
+
+// FormFunnel sessions of website going through the fields of formID on any page
+func (instance *repository) FormFunnel(userID, websiteID, formID string, filter BreakdownFilter) (*FormFunnel, error) {
+	match := []bson.M{
+		{"meta_data.user_id": userID},
+		{"meta_data.website_id": websiteID},
+		onTime,
+		{"time_report": bson.M{"$gte": filter.From, "$lt": filter.To}},
+		{"event.data.tag": bson.M{"$in": []interface{}{FormStartTag, FormSubmitTag, FormFieldTag}}},
+		{"event.data.payload.form_id": formID},
+	}
+	pipeline := []bson.M{
+		{"$match": bson.M{"$and": match}},
+		{"$group": bson.M{
+			"_id": bson.M{
+				"tag":   "$event.data.tag",
+				"field": "$event.data.payload.field",
+				"id":    "$meta_data.id",
+			},
+			"index":   bson.M{"$min": "$event.data.payload.index"},
+			"time_ms": bson.M{"$sum": "$event.data.payload.time_ms"},
+			"error":   bson.M{"$max": bson.M{"$cond": []interface{}{"$event.data.payload.error", 1, 0}}},
+		}},
+		{"$group": bson.M{
+			"_id":      bson.M{"tag": "$_id.tag", "field": "$_id.field"},
+			"index":    bson.M{"$min": "$index"},
+			"sessions": bson.M{"$sum": 1},
+			"time_ms":  bson.M{"$sum": "$time_ms"},
+			"errors":   bson.M{"$sum": "$error"},
+		}},
+	}
+
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	cur, err := db.Aggregate(context.TODO(), sessionCollection, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	var rows []formFieldRow
+	for cur.Next(context.TODO()) {
+		var row struct {
+			ID struct {
+				Tag   string `bson:"tag"`
+				Field string `bson:"field"`
+			} `bson:"_id"`
+			Index    int64 `bson:"index"`
+			Sessions int64 `bson:"sessions"`
+			TimeMs   int64 `bson:"time_ms"`
+			Errors   int64 `bson:"errors"`
+		}
+		if err := cur.Decode(&row); err != nil {
+			return nil, err
+		}
+		rows = append(rows, formFieldRow{
+			Tag:      row.ID.Tag,
+			Field:    row.ID.Field,
+			Index:    row.Index,
+			Sessions: row.Sessions,
+			TimeMs:   row.TimeMs,
+			Errors:   row.Errors,
+		})
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return mergeFormFunnel(rows), nil
+}
+
+// FormFunnel sessions of website going through the fields of formID on any page
+func (instance *clickHouseRepository) FormFunnel(userID, websiteID, formID string, filter BreakdownFilter) (*FormFunnel, error) {
+	params := instance.params(userID)
+	params["website"] = websiteID
+	params["from"] = clickhouse.TimeParam(filter.From)
+	params["to"] = clickhouse.TimeParam(filter.To)
+	params["tags"] = clickhouse.ArrayParam([]string{FormStartTag, FormSubmitTag, FormFieldTag})
+	params["form"] = formID
+	perSession := "SELECT id, JSONExtractString(data, 'tag') AS tag," +
+		" JSONExtractString(data, 'payload', 'field') AS field," +
+		" min(JSONExtractInt(data, 'payload', 'index')) AS position," +
+		" sum(JSONExtractInt(data, 'payload', 'time_ms')) AS time_ms," +
+		" max(JSONExtractBool(data, 'payload', 'error')) AS error FROM " + db.ClickHouseEventTable +
+		" WHERE " + clickHouseReportFilter + " AND website_id = {website:String}" +
+		" AND time_report >= {from:DateTime64(3)} AND time_report < {to:DateTime64(3)}" +
+		" AND type = 5 AND tag IN {tags:Array(String)}" +
+		" AND JSONExtractString(data, 'payload', 'form_id') = {form:String}" +
+		" GROUP BY id, tag, field"
+	query := "SELECT tag, field, min(position) AS `index`, count() AS sessions," +
+		" sum(time_ms) AS time_ms, sum(error) AS errors FROM (" + perSession + ") GROUP BY tag, field"
+
+	var rows []formFieldRow
+	err := configs.ClickHouse.Client.Query(query, params, func(line []byte) error {
+		var row formFieldRow
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mergeFormFunnel(rows), nil
+}
+
+// mergeFormFunnel fields in the order of the form
+func mergeFormFunnel(rows []formFieldRow) *FormFunnel {
+	aFunnel := &FormFunnel{Fields: []FormField{}}
+	for _, row := range rows {
+		switch row.Tag {
+		case FormStartTag:
+			aFunnel.Starts += row.Sessions
+		case FormSubmitTag:
+			aFunnel.Submits += row.Sessions
+		case FormFieldTag:
+			aField := FormField{Field: row.Field, Index: row.Index, Sessions: row.Sessions, Errors: row.Errors}
+			if row.Sessions > 0 {
+				aField.AvgTimeMs = row.TimeMs / row.Sessions
+			}
+			aFunnel.Fields = append(aFunnel.Fields, aField)
+		}
+	}
+	sort.Slice(aFunnel.Fields, func(i, j int) bool {
+		if aFunnel.Fields[i].Index != aFunnel.Fields[j].Index {
+			return aFunnel.Fields[i].Index < aFunnel.Fields[j].Index
+		}
+		return aFunnel.Fields[i].Field < aFunnel.Fields[j].Field
+	})
+	return aFunnel
+}
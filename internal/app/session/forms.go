@@ -0,0 +1,207 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/clickhouse"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// FormStartTag custom event of the first focus in a form of the page
+	FormStartTag = "form_start"
+	// FormSubmitTag custom event of a submitted form
+	FormSubmitTag = "form_submit"
+	// FormAbandonTag custom event of a started form left unsubmitted, sent
+	// when the page is hidden so it may not reach us
+	FormAbandonTag = "form_abandon"
+	// CartAbandonTag custom event sites send themselves with track()
+	CartAbandonTag = "cart_abandon"
+)
+
+// formTags custom events counted by form reports
+var formTags = []interface{}{FormStartTag, FormSubmitTag, FormAbandonTag, CartAbandonTag}
+
+// FormStats sessions reaching each step of a form on a page
+type FormStats struct {
+	FormID   string `json:"form_id"`
+	Path     string `json:"path"`
+	Starts   int64  `json:"starts"`
+	Submits  int64  `json:"submits"`
+	Abandons int64  `json:"abandons"`
+}
+
+// Forms sessions starting, submitting and abandoning each form of website by page
+func (instance *repository) Forms(userID, websiteID string, filter BreakdownFilter) ([]FormStats, error) {
+	match := []bson.M{
+		{"meta_data.user_id": userID},
+		{"meta_data.website_id": websiteID},
+		onTime,
+		{"time_report": bson.M{"$gte": filter.From, "$lt": filter.To}},
+		{"event.data.tag": bson.M{"$in": formTags}},
+	}
+	pipeline := []bson.M{
+		{"$match": bson.M{"$and": match}},
+		{"$group": bson.M{"_id": bson.M{
+			"form": "$event.data.payload.form_id",
+			"path": "$event.data.payload.path",
+			"tag":  "$event.data.tag",
+			"id":   "$meta_data.id",
+		}}},
+		{"$group": bson.M{
+			"_id":      bson.M{"form": "$_id.form", "path": "$_id.path", "tag": "$_id.tag"},
+			"sessions": bson.M{"$sum": 1},
+		}},
+	}
+
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	cur, err := db.Aggregate(context.TODO(), sessionCollection, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	var rows []formRow
+	for cur.Next(context.TODO()) {
+		var row struct {
+			ID struct {
+				Form string `bson:"form"`
+				Path string `bson:"path"`
+				Tag  string `bson:"tag"`
+			} `bson:"_id"`
+			Sessions int64 `bson:"sessions"`
+		}
+		if err := cur.Decode(&row); err != nil {
+			return nil, err
+		}
+		rows = append(rows, formRow{FormID: row.ID.Form, Path: row.ID.Path, Tag: row.ID.Tag, Sessions: row.Sessions})
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return mergeForms(rows), nil
+}
+
+// FormSubmissions sessions of website submitting formID on any page
+func (instance *repository) FormSubmissions(userID, websiteID, formID string, filter BreakdownFilter) (int64, error) {
+	match := []bson.M{
+		{"meta_data.user_id": userID},
+		{"meta_data.website_id": websiteID},
+		onTime,
+		{"time_report": bson.M{"$gte": filter.From, "$lt": filter.To}},
+		{"event.data.tag": FormSubmitTag},
+		{"event.data.payload.form_id": formID},
+	}
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	ids, err := sessionCollection.Distinct(context.TODO(), "meta_data.id", bson.M{"$and": match})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(ids)), nil
+}
+
+// Forms sessions starting, submitting and abandoning each form of website by page
+func (instance *clickHouseRepository) Forms(userID, websiteID string, filter BreakdownFilter) ([]FormStats, error) {
+	params := instance.params(userID)
+	params["website"] = websiteID
+	params["from"] = clickhouse.TimeParam(filter.From)
+	params["to"] = clickhouse.TimeParam(filter.To)
+	params["tags"] = clickhouse.ArrayParam([]string{FormStartTag, FormSubmitTag, FormAbandonTag, CartAbandonTag})
+	query := "SELECT JSONExtractString(data, 'payload', 'form_id') AS form_id," +
+		" JSONExtractString(data, 'payload', 'path') AS path," +
+		" JSONExtractString(data, 'tag') AS tag, uniqExact(id) AS sessions FROM " + db.ClickHouseEventTable +
+		" WHERE " + clickHouseReportFilter + " AND website_id = {website:String}" +
+		" AND time_report >= {from:DateTime64(3)} AND time_report < {to:DateTime64(3)}" +
+		" AND type = 5 AND tag IN {tags:Array(String)} GROUP BY form_id, path, tag"
+
+	var rows []formRow
+	err := configs.ClickHouse.Client.Query(query, params, func(line []byte) error {
+		var row formRow
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mergeForms(rows), nil
+}
+
+// FormSubmissions sessions of website submitting formID on any page
+func (instance *clickHouseRepository) FormSubmissions(userID, websiteID, formID string, filter BreakdownFilter) (int64, error) {
+	params := instance.params(userID)
+	params["website"] = websiteID
+	params["from"] = clickhouse.TimeParam(filter.From)
+	params["to"] = clickhouse.TimeParam(filter.To)
+	params["tag"] = FormSubmitTag
+	params["form"] = formID
+	query := "SELECT uniqExact(id) AS sessions FROM " + db.ClickHouseEventTable +
+		" WHERE " + clickHouseReportFilter + " AND website_id = {website:String}" +
+		" AND time_report >= {from:DateTime64(3)} AND time_report < {to:DateTime64(3)}" +
+		" AND type = 5 AND JSONExtractString(data, 'tag') = {tag:String}" +
+		" AND JSONExtractString(data, 'payload', 'form_id') = {form:String}"
+
+	var sessions int64
+	err := configs.ClickHouse.Client.Query(query, params, func(line []byte) error {
+		var row struct {
+			Sessions int64 `json:"sessions"`
+		}
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		sessions = row.Sessions
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return sessions, nil
+}
+
+// formRow sessions sending a form event on a page
+type formRow struct {
+	FormID   string `json:"form_id"`
+	Path     string `json:"path"`
+	Tag      string `json:"tag"`
+	Sessions int64  `json:"sessions"`
+}
+
+// mergeForms one FormStats per form and page, most started first
+func mergeForms(rows []formRow) []FormStats {
+	index := map[[2]string]int{}
+	forms := []FormStats{}
+	for _, row := range rows {
+		key := [2]string{row.FormID, row.Path}
+		i, ok := index[key]
+		if !ok {
+			i = len(forms)
+			index[key] = i
+			forms = append(forms, FormStats{FormID: row.FormID, Path: row.Path})
+		}
+		switch row.Tag {
+		case FormStartTag:
+			forms[i].Starts += row.Sessions
+		case FormSubmitTag:
+			forms[i].Submits += row.Sessions
+		case FormAbandonTag, CartAbandonTag:
+			forms[i].Abandons += row.Sessions
+		}
+	}
+	sort.Slice(forms, func(i, j int) bool {
+		if forms[i].Starts != forms[j].Starts {
+			return forms[i].Starts > forms[j].Starts
+		}
+		if forms[i].FormID != forms[j].FormID {
+			return forms[i].FormID < forms[j].FormID
+		}
+		return forms[i].Path < forms[j].Path
+	})
+	return forms
+}
@@ -0,0 +1,69 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeForms(t *testing.T) {
+	tests := []struct {
+		name string
+		rows []formRow
+		want []FormStats
+	}{
+		{name: "should return no form without rows", rows: nil, want: []FormStats{}},
+		{
+			name: "should merge the steps of a form on a page",
+			rows: []formRow{
+				{FormID: "signup", Path: "/", Tag: FormStartTag, Sessions: 10},
+				{FormID: "signup", Path: "/", Tag: FormSubmitTag, Sessions: 4},
+				{FormID: "signup", Path: "/", Tag: FormAbandonTag, Sessions: 5},
+			},
+			want: []FormStats{{FormID: "signup", Path: "/", Starts: 10, Submits: 4, Abandons: 5}},
+		},
+		{
+			name: "should count cart abandons as abandons",
+			rows: []formRow{
+				{FormID: "cart", Path: "/checkout", Tag: CartAbandonTag, Sessions: 3},
+				{FormID: "cart", Path: "/checkout", Tag: FormAbandonTag, Sessions: 2},
+			},
+			want: []FormStats{{FormID: "cart", Path: "/checkout", Abandons: 5}},
+		},
+		{
+			name: "should keep a form apart on each page",
+			rows: []formRow{
+				{FormID: "signup", Path: "/", Tag: FormStartTag, Sessions: 2},
+				{FormID: "signup", Path: "/pricing", Tag: FormStartTag, Sessions: 2},
+			},
+			want: []FormStats{
+				{FormID: "signup", Path: "/", Starts: 2},
+				{FormID: "signup", Path: "/pricing", Starts: 2},
+			},
+		},
+		{
+			name: "should list the most started first",
+			rows: []formRow{
+				{FormID: "contact", Path: "/", Tag: FormStartTag, Sessions: 1},
+				{FormID: "signup", Path: "/", Tag: FormStartTag, Sessions: 7},
+				{FormID: "newsletter", Path: "/", Tag: FormSubmitTag, Sessions: 3},
+			},
+			want: []FormStats{
+				{FormID: "signup", Path: "/", Starts: 7},
+				{FormID: "contact", Path: "/", Starts: 1},
+				{FormID: "newsletter", Path: "/", Submits: 3},
+			},
+		},
+		{
+			name: "should ignore other tags",
+			rows: []formRow{{FormID: "signup", Path: "/", Tag: "purchase", Sessions: 9}},
+			want: []FormStats{{FormID: "signup", Path: "/"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeForms(tt.rows); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeForms() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
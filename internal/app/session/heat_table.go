@@ -0,0 +1,140 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/clickhouse"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// HeatCell activity of an hour of a weekday, a session active across several
+// hours is counted in each of them
+type HeatCell struct {
+	// Weekday 0 is sunday like time.Weekday
+	Weekday   int   `json:"weekday"`
+	Hour      int   `json:"hour"`
+	Sessions  int64 `json:"sessions"`
+	Pageviews int64 `json:"pageviews"`
+}
+
+// metaEventType rrweb meta event, recorded on every page load
+const metaEventType = 4
+
+// HeatTable sessions and pageviews of website by weekday and hour in location
+func (instance *repository) HeatTable(userID, websiteID string, filter BreakdownFilter, location *time.Location) ([]HeatCell, error) {
+	match := []bson.M{
+		{"meta_data.user_id": userID},
+		{"meta_data.website_id": websiteID},
+		onTime,
+		{"time_report": bson.M{"$gte": filter.From, "$lt": filter.To}},
+	}
+	switch filter.Platform {
+	case "":
+	case PlatformWeb:
+		match = append(match, bson.M{"meta_data.platform": bson.M{"$in": []interface{}{PlatformWeb, nil}}})
+	default:
+		match = append(match, bson.M{"meta_data.platform": filter.Platform})
+	}
+	pageview := bson.M{"$cond": []interface{}{
+		bson.M{"$or": []bson.M{
+			{"$eq": []interface{}{"$event.type", metaEventType}},
+			{"$eq": []interface{}{"$event.data.tag", ScreenViewTag}},
+		}},
+		1,
+		0,
+	}}
+	pipeline := []bson.M{
+		{"$match": bson.M{"$and": match}},
+		{"$group": bson.M{
+			"_id": bson.M{
+				"weekday": bson.M{"$dayOfWeek": bson.M{"date": "$time_report", "timezone": location.String()}},
+				"hour":    bson.M{"$hour": bson.M{"date": "$time_report", "timezone": location.String()}},
+				"id":      "$meta_data.id",
+			},
+			"pageviews": bson.M{"$sum": pageview},
+		}},
+		{"$group": bson.M{
+			"_id":       bson.M{"weekday": "$_id.weekday", "hour": "$_id.hour"},
+			"sessions":  bson.M{"$sum": 1},
+			"pageviews": bson.M{"$sum": "$pageviews"},
+		}},
+	}
+
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	cur, err := db.Aggregate(context.TODO(), sessionCollection, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	var cells []HeatCell
+	for cur.Next(context.TODO()) {
+		var row struct {
+			ID struct {
+				Weekday int `bson:"weekday"`
+				Hour    int `bson:"hour"`
+			} `bson:"_id"`
+			Sessions  int64 `bson:"sessions"`
+			Pageviews int64 `bson:"pageviews"`
+		}
+		if err := cur.Decode(&row); err != nil {
+			return nil, err
+		}
+		// $dayOfWeek counts from 1 for sunday
+		cells = append(cells, HeatCell{
+			Weekday:   row.ID.Weekday - 1,
+			Hour:      row.ID.Hour,
+			Sessions:  row.Sessions,
+			Pageviews: row.Pageviews,
+		})
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return cells, nil
+}
+
+// HeatTable sessions and pageviews of website by weekday and hour in location
+func (instance *clickHouseRepository) HeatTable(userID, websiteID string, filter BreakdownFilter, location *time.Location) ([]HeatCell, error) {
+	params := instance.params(userID)
+	params["website"] = websiteID
+	params["from"] = clickhouse.TimeParam(filter.From)
+	params["to"] = clickhouse.TimeParam(filter.To)
+	params["timezone"] = location.String()
+	// toDayOfWeek counts from 1 for monday to 7 for sunday
+	query := "SELECT toDayOfWeek(toTimeZone(time_report, {timezone:String})) % 7 AS weekday," +
+		" toHour(toTimeZone(time_report, {timezone:String})) AS hour," +
+		" uniqExact(id) AS sessions," +
+		" countIf(type = 4 OR JSONExtractString(data, 'tag') = '" + ScreenViewTag + "') AS pageviews" +
+		" FROM " + db.ClickHouseEventTable +
+		" WHERE " + clickHouseReportFilter + " AND website_id = {website:String}" +
+		" AND time_report >= {from:DateTime64(3)} AND time_report < {to:DateTime64(3)}"
+	switch filter.Platform {
+	case "":
+	case PlatformWeb:
+		query += " AND platform IN ('web', '')"
+	default:
+		params["platform"] = filter.Platform
+		query += " AND platform = {platform:String}"
+	}
+	query += " GROUP BY weekday, hour"
+
+	var cells []HeatCell
+	err := configs.ClickHouse.Client.Query(query, params, func(line []byte) error {
+		var row HeatCell
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		cells = append(cells, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cells, nil
+}
@@ -0,0 +1,92 @@
+package session
+
+import (
+	"encoding/json"
+
+	"analytics-api/internal/app/visitor"
+)
+
+// IdentifyTag custom event of window.recorder.identify naming the visitor
+const IdentifyTag = "identify"
+
+// identifies visitors named in events with the first touch the tracker kept,
+// the first page of the batch stands in for a tracker without one
+func identifies(sessionID string, events []event) []visitor.Identify {
+	var result []visitor.Identify
+	href := ""
+	for _, anEvent := range events {
+		if anEvent.Type == metaEventType && href == "" {
+			href, _ = anEvent.Data["href"].(string)
+			continue
+		}
+		if anEvent.Type != customEventType || anEvent.Data["tag"] != IdentifyTag {
+			continue
+		}
+		raw, err := json.Marshal(anEvent.Data["payload"])
+		if err != nil {
+			continue
+		}
+		var payload struct {
+			VisitorID  string             `json:"visitor_id"`
+			FirstTouch visitor.FirstTouch `json:"first_touch"`
+		}
+		if err := json.Unmarshal(raw, &payload); err != nil || payload.VisitorID == "" {
+			continue
+		}
+		if payload.FirstTouch.LandingPage == "" {
+			payload.FirstTouch.LandingPage = href
+			payload.FirstTouch.Timestamp = anEvent.Timestamp
+		}
+		result = append(result, visitor.Identify{
+			VisitorID:  payload.VisitorID,
+			SessionID:  sessionID,
+			FirstTouch: payload.FirstTouch,
+		})
+	}
+	return result
+}
+
+// formSubmits forms submitted in events, attributed to the visitor identified
+// in the session if any
+func formSubmits(sessionID string, events []event) []visitor.FormSubmit {
+	var result []visitor.FormSubmit
+	for _, anEvent := range events {
+		if anEvent.Type != customEventType || anEvent.Data["tag"] != FormSubmitTag {
+			continue
+		}
+		payload, ok := anEvent.Data["payload"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		formID, _ := payload["form_id"].(string)
+		if formID == "" {
+			continue
+		}
+		result = append(result, visitor.FormSubmit{SessionID: sessionID, FormID: formID, Timestamp: anEvent.Timestamp})
+	}
+	return result
+}
+
+// liveEvents page views and custom events of events, streamed to the
+// consoles watching the visitor identified in the session. The recording of
+// the page is left out, it is only played through the replay
+func liveEvents(sessionID string, events []event) []visitor.LiveEvent {
+	var result []visitor.LiveEvent
+	for _, anEvent := range events {
+		if anEvent.Type != metaEventType && anEvent.Type != customEventType {
+			continue
+		}
+		tag := ""
+		if anEvent.Type == customEventType {
+			tag, _ = anEvent.Data["tag"].(string)
+		}
+		result = append(result, visitor.LiveEvent{
+			SessionID: sessionID,
+			Type:      anEvent.Type,
+			Tag:       tag,
+			Data:      anEvent.Data,
+			Timestamp: anEvent.Timestamp,
+		})
+	}
+	return result
+}
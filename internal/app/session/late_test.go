@@ -0,0 +1,99 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/internal/app/reconcile"
+)
+
+func TestLateEvents(t *testing.T) {
+	hours := configs.LateArrivalHours
+	configs.LateArrivalHours = 48
+	t.Cleanup(func() { configs.LateArrivalHours = hours })
+	saigon, err := time.LoadLocation("Asia/Ho_Chi_Minh")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+	at := func(value string) int64 {
+		parsed, _ := time.Parse(time.RFC3339, value)
+		return parsed.UnixMilli()
+	}
+	day := func(value string) time.Time {
+		parsed, _ := time.Parse("2006-01-02", value)
+		return parsed
+	}
+	tests := []struct {
+		name     string
+		location *time.Location
+		now      string
+		events   []string
+		wantLate []bool
+		wantDays map[time.Time]reconcile.Late
+	}{
+		{
+			name:     "should flag events past the window after their day in UTC",
+			location: time.UTC,
+			now:      "2024-03-04T10:00:00Z",
+			events:   []string{"2024-03-01T20:00:00Z", "2024-03-02T12:00:00Z", "2024-03-04T08:00:00Z"},
+			wantLate: []bool{true, false, false},
+			wantDays: map[time.Time]reconcile.Late{day("2024-03-01"): {Flagged: 1}, day("2024-03-02"): {Merged: 1}},
+		},
+		{
+			name:     "should merge an event whose day in the timezone of the website is within the window",
+			location: saigon,
+			now:      "2024-03-04T10:00:00Z",
+			events:   []string{"2024-03-01T20:00:00Z", "2024-03-02T12:00:00Z", "2024-03-04T08:00:00Z"},
+			wantLate: []bool{false, false, false},
+			wantDays: map[time.Time]reconcile.Late{day("2024-03-01"): {Merged: 1}, day("2024-03-02"): {Merged: 1}},
+		},
+		{
+			name:     "should flag an event whose day in the timezone of the website is settled, counted on its UTC day",
+			location: saigon,
+			now:      "2024-03-04T18:00:00Z",
+			events:   []string{"2024-03-02T16:30:00Z"},
+			wantLate: []bool{true},
+			wantDays: map[time.Time]reconcile.Late{day("2024-03-02"): {Flagged: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := make([]event, len(tt.events))
+			for i, timestamp := range tt.events {
+				events[i] = event{Type: customEventType, Timestamp: at(timestamp)}
+			}
+			now, _ := time.Parse(time.RFC3339, tt.now)
+			got := lateEvents(events, now, tt.location)
+			if !reflect.DeepEqual(got, tt.wantDays) {
+				t.Errorf("lateEvents() = %v, want %v", got, tt.wantDays)
+			}
+			for i, anEvent := range events {
+				if anEvent.Late != tt.wantLate[i] {
+					t.Errorf("lateEvents() event %v late = %v, want %v", i, anEvent.Late, tt.wantLate[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBreakdownFilterMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  BreakdownFilter
+		anEvent RawEvent
+		want    bool
+	}{
+		{name: "should match an archived event of the filter", filter: BreakdownFilter{CountryCode: "VN"}, anEvent: RawEvent{CountryCode: "VN"}, want: true},
+		{name: "should leave out an event of another country", filter: BreakdownFilter{CountryCode: "VN"}, anEvent: RawEvent{CountryCode: "US"}, want: false},
+		{name: "should leave out an event flagged late", filter: BreakdownFilter{}, anEvent: RawEvent{Late: true}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.matches(tt.anEvent); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
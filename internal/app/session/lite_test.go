@@ -0,0 +1,165 @@
+package session
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/db/dbtest"
+	"analytics-api/internal/pkg/cursor"
+	"analytics-api/internal/pkg/faker"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// liteSessions events of visits to two websites over the ten days before
+// now, from every platform and a few regions, with forms, page meta,
+// canonical urls and events flagged late
+func liteSessions(now time.Time, location *time.Location) []interface{} {
+	platforms := []string{PlatformWeb, "", PlatformIOS, PlatformAndroid}
+	regions := [][2]string{{"VN", "VN-HN"}, {"VN", "VN-SG"}, {"US", "US-CA"}}
+	referrers := []string{"", "google.com", "news.ycombinator.com", "", "facebook.com"}
+	authors := []string{"an", "binh", "chi"}
+	fields := []string{"email", "name", "password"}
+
+	rng := rand.New(rand.NewSource(1))
+	var documents []interface{}
+	for i, aVisit := range faker.Generate(rng, 120, now.AddDate(0, 0, -10), now, location) {
+		websiteID := "w1"
+		if i%7 == 0 {
+			websiteID = "w2"
+		}
+		aMetaData := metaData{
+			ID:          fmt.Sprintf("s%03d", i),
+			UserID:      "u1",
+			WebsiteID:   websiteID,
+			Browser:     []string{"Chrome", "Safari"}[i%2],
+			Platform:    platforms[i%len(platforms)],
+			CountryCode: regions[i%len(regions)][0],
+			RegionCode:  regions[i%len(regions)][1],
+			Referrer:    referrers[i%len(referrers)],
+		}
+		events := fakeEvents("https://example.com", aVisit)
+		for _, view := range aVisit.Pages {
+			at := view.At.UnixMilli() + 1
+			events = append(events, event{Type: customEventType, Timestamp: at, Data: bson.M{"tag": PageMetaTag, "payload": map[string]interface{}{
+				"path": view.Path, "author": authors[rng.Intn(len(authors))], "category": view.Path,
+			}}})
+			if aMetaData.Platform == PlatformIOS || aMetaData.Platform == PlatformAndroid {
+				events = append(events, event{Type: customEventType, Timestamp: at, Data: bson.M{"tag": ScreenViewTag}})
+			}
+		}
+		if aForm := aVisit.Form; aForm != nil {
+			for j, field := range fields {
+				events = append(events, event{Type: customEventType, Timestamp: aForm.At.UnixMilli() + int64(j), Data: bson.M{"tag": FormFieldTag, "payload": map[string]interface{}{
+					"path": aForm.Path, "form_id": aForm.ID, "field": field, "index": j, "time_ms": rng.Intn(5000), "error": j == 2 && i%2 == 0,
+				}}})
+			}
+		}
+		start := events[0].Timestamp
+		for j, anEvent := range events {
+			if href, ok := anEvent.Data["href"].(string); ok && i%3 == 0 {
+				anEvent.Data["canonical"] = href + "/amp"
+			}
+			anEvent.Late = i%9 == 0 && j == len(events)-1
+			elapsed := time.Duration(anEvent.Timestamp-start) * time.Millisecond
+			documents = append(documents, session{
+				MetaData:   aMetaData,
+				Duration:   fmt.Sprintf("%02d:%02d:%02d", int(elapsed.Hours()), int(elapsed.Minutes())%60, int(elapsed.Seconds())%60),
+				Event:      anEvent,
+				TimeReport: time.UnixMilli(anEvent.Timestamp).UTC(),
+			})
+		}
+	}
+	return documents
+}
+
+func TestLiteReports(t *testing.T) {
+	mongoStore, liteStore := dbtest.Stores(t)
+	collection := configs.MongoDB.SessionCollection
+	configs.MongoDB.SessionCollection = "session"
+	t.Cleanup(func() { configs.MongoDB.SessionCollection = collection })
+
+	saigon, err := time.LoadLocation("Asia/Ho_Chi_Minh")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+	now, _ := time.Parse(time.RFC3339, "2024-03-15T12:00:00Z")
+	dbtest.Insert(t, configs.MongoDB.SessionCollection, liteSessions(now, saigon), mongoStore, liteStore)
+
+	all := BreakdownFilter{From: now.AddDate(0, 0, -10), To: now}
+	web := BreakdownFilter{From: all.From, To: all.To, Platform: PlatformWeb}
+	ios := BreakdownFilter{From: all.From, To: all.To, Platform: PlatformIOS}
+	vietnam := BreakdownFilter{From: all.From, To: all.To, CountryCode: "VN"}
+	hanoi := BreakdownFilter{From: all.From, To: all.To, CountryCode: "VN", RegionCode: "VN-HN"}
+	canonical := BreakdownFilter{From: all.From, To: all.To, Canonical: true}
+	lastDays := BreakdownFilter{From: now.AddDate(0, 0, -3), To: now}
+
+	tests := []struct {
+		name      string
+		unordered bool
+		report    func(*repository) (interface{}, error)
+	}{
+		{name: "should count visits", report: func(r *repository) (interface{}, error) { return r.Visits("u1", "w1", all) }},
+		{name: "should count visits of the last days", report: func(r *repository) (interface{}, error) { return r.Visits("u1", "w1", lastDays) }},
+		{name: "should break down by platform", unordered: true, report: func(r *repository) (interface{}, error) { return r.Breakdown("u1", "w1", "platform", all) }},
+		{name: "should break down web sessions by browser", unordered: true, report: func(r *repository) (interface{}, error) { return r.Breakdown("u1", "w1", "browser", web) }},
+		{name: "should break down ios sessions by country", unordered: true, report: func(r *repository) (interface{}, error) { return r.Breakdown("u1", "w1", "country_code", ios) }},
+		{name: "should break down a country by region", unordered: true, report: func(r *repository) (interface{}, error) { return r.Breakdown("u1", "w1", "region_code", vietnam) }},
+		{name: "should break down by a field no session has", unordered: true, report: func(r *repository) (interface{}, error) { return r.Breakdown("u1", "w1", "device_model", hanoi) }},
+		{name: "should count forms", unordered: true, report: func(r *repository) (interface{}, error) { return r.Forms("u1", "w1", all) }},
+		{name: "should count sessions submitting a form", report: func(r *repository) (interface{}, error) { return r.FormSubmissions("u1", "w1", "signup", all) }},
+		{name: "should go through the fields of a form", report: func(r *repository) (interface{}, error) { return r.FormFunnel("u1", "w1", "signup", all) }},
+		{name: "should go through the fields of a form in a region", report: func(r *repository) (interface{}, error) { return r.FormFunnel("u1", "w1", "checkout", hanoi) }},
+		{name: "should measure engagement", unordered: true, report: func(r *repository) (interface{}, error) { return r.Engagement("u1", "w1", all) }},
+		{name: "should break down pages by author", unordered: true, report: func(r *repository) (interface{}, error) { return r.PageBreakdown("u1", "w1", "author", all) }},
+		{name: "should break down pages by category", unordered: true, report: func(r *repository) (interface{}, error) { return r.PageBreakdown("u1", "w1", "category", lastDays) }},
+		{name: "should count pages", unordered: true, report: func(r *repository) (interface{}, error) { return r.Pages("u1", "w1", all) }},
+		{name: "should count pages under their canonical url", unordered: true, report: func(r *repository) (interface{}, error) { return r.Pages("u1", "w1", canonical) }},
+		{name: "should total the website", unordered: true, report: func(r *repository) (interface{}, error) { return r.Totals("u1", "w1", "", []string{"signup"}, all) }},
+		{name: "should total web sessions by region", unordered: true, report: func(r *repository) (interface{}, error) {
+			return r.Totals("u1", "w1", "region_code", []string{"signup", "checkout"}, web)
+		}},
+		{name: "should total by platform", unordered: true, report: func(r *repository) (interface{}, error) { return r.Totals("u1", "w2", "platform", nil, all) }},
+		{name: "should build the heat table in the timezone of the website", unordered: true, report: func(r *repository) (interface{}, error) { return r.HeatTable("u1", "w1", all, saigon) }},
+		{name: "should build the heat table at a negative offset", unordered: true, report: func(r *repository) (interface{}, error) { return r.HeatTable("u1", "w1", ios, newYork) }},
+		{name: "should count days in the timezone of the website", unordered: true, report: func(r *repository) (interface{}, error) { return r.Daily("u1", "w1", all, saigon) }},
+		{name: "should count days at a negative offset", unordered: true, report: func(r *repository) (interface{}, error) { return r.Daily("u1", "w1", vietnam, newYork) }},
+		{name: "should count referrals", report: func(r *repository) (interface{}, error) { return r.Referrals("u1", "w1", all) }},
+		{name: "should count sessions since a time", report: func(r *repository) (interface{}, error) {
+			return r.CountSessionSince("u1", "w1", now.AddDate(0, 0, -3))
+		}},
+		{name: "should count sessions of each website since each time", report: func(r *repository) (interface{}, error) {
+			return r.CountSessionsSince("u1", []string{"w1", "w2", "w3"}, []time.Time{now.AddDate(0, 0, -1), now.AddDate(0, 0, -7)})
+		}},
+		{name: "should page through sessions", report: func(r *repository) (interface{}, error) {
+			first, next, err := r.GetSessionPage("u1", "w1", false, nil, 10)
+			if err != nil {
+				return nil, err
+			}
+			second, last, err := r.GetSessionPage("u1", "w1", false, next, 10)
+			return []interface{}{first, next, second, last}, err
+		}},
+		{name: "should page through sessions after a cursor between sessions", report: func(r *repository) (interface{}, error) {
+			page, next, err := r.GetSessionPage("u1", "w2", false, &cursor.Cursor{Key: now.AddDate(0, 0, -5).UnixMilli(), ID: "s000"}, 5)
+			return []interface{}{page, next}, err
+		}},
+		{name: "should count sessions of each website by day", report: func(r *repository) (interface{}, error) {
+			return mongoDailyCount(r.store, all.From, all.To)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbtest.Compare(t, mongoStore, liteStore, tt.unordered, func(store *db.Store) (interface{}, error) {
+				return tt.report(&repository{store: store})
+			})
+		})
+	}
+}
@@ -26,11 +26,44 @@ type metaData struct {
 	Browser   string `json:"browser" bson:"browser"`
 	Version   string `json:"version" bson:"version"`
 	CreatedAt string `json:"created_at" bson:"created_at"`
+	// Platform web, ios or android, empty for sessions collected before apps were supported
+	Platform    string `json:"platform,omitempty" bson:"platform,omitempty"`
+	AppVersion  string `json:"app_version,omitempty" bson:"app_version,omitempty"`
+	OSVersion   string `json:"os_version,omitempty" bson:"os_version,omitempty"`
+	DeviceModel string `json:"device_model,omitempty" bson:"device_model,omitempty"`
+	// CountryCode ISO 3166-1 alpha-2 code, RegionCode ISO 3166-2 code like VN-HN
+	CountryCode string `json:"country_code,omitempty" bson:"country_code,omitempty"`
+	Region      string `json:"region,omitempty" bson:"region,omitempty"`
+	RegionCode  string `json:"region_code,omitempty" bson:"region_code,omitempty"`
+	// Referrer host of the page the visitor came from, empty when not set
+	Referrer string `json:"referrer,omitempty" bson:"referrer,omitempty"`
+	// Overage events received once the monthly quota of the website was used up
+	Overage bool `json:"overage,omitempty" bson:"overage,omitempty"`
 }
 
+const (
+	// PlatformWeb sessions recorded by record.js
+	PlatformWeb = "web"
+	// PlatformIOS sessions sent by the ios SDK
+	PlatformIOS = "ios"
+	// PlatformAndroid sessions sent by the android SDK
+	PlatformAndroid = "android"
+)
+
+// ScreenViewTag tag of the custom event mobile SDKs send instead of a page view
+const ScreenViewTag = "screen_view"
+
 // event ...
 type event struct {
+	// ID idempotency key the tracker gave the event, a corrected version
+	// sent to the re-ingestion API replaces the event of the same key
+	ID        string `json:"id,omitempty" bson:"id,omitempty"`
 	Type      int64  `json:"type" bson:"type"`
 	Data      bson.M `json:"data" bson:"data"`
 	Timestamp int64  `json:"timestamp" bson:"timestamp"`
+	// Sealed encrypted data when the tenant encrypts recordings
+	Sealed string `json:"-" bson:"sealed,omitempty"`
+	// Late arrived past the late arrival window, stored but left out of the
+	// rollups and reports of its day
+	Late bool `json:"late,omitempty" bson:"late,omitempty"`
 }
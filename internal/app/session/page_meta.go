@@ -0,0 +1,125 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/clickhouse"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// PageMetaTag tag of the custom event record.js sends on page load with the
+// author, category and published date read from meta tags of the page
+const PageMetaTag = "page_meta"
+
+// pageMetaFields dimensions of a page breakdown, named like the payload fields
+// of page meta events
+var pageMetaFields = map[string]bool{
+	"author":   true,
+	"category": true,
+}
+
+// PageBucket sessions and pageviews of pages with a value of the dimension
+type PageBucket struct {
+	Key       string `json:"key"`
+	Sessions  int64  `json:"sessions"`
+	Pageviews int64  `json:"pageviews"`
+}
+
+// PageBreakdown count pageviews of website by page meta dimension, most viewed first
+func (instance *repository) PageBreakdown(userID, websiteID, dimension string, filter BreakdownFilter) ([]PageBucket, error) {
+	match := []bson.M{
+		{"meta_data.user_id": userID},
+		{"meta_data.website_id": websiteID},
+		onTime,
+		{"time_report": bson.M{"$gte": filter.From, "$lt": filter.To}},
+		{"event.data.tag": PageMetaTag},
+	}
+	pipeline := []bson.M{
+		{"$match": bson.M{"$and": match}},
+		{"$group": bson.M{
+			"_id":       bson.M{"key": "$event.data.payload." + dimension, "id": "$meta_data.id"},
+			"pageviews": bson.M{"$sum": 1},
+		}},
+		{"$group": bson.M{
+			"_id":       "$_id.key",
+			"sessions":  bson.M{"$sum": 1},
+			"pageviews": bson.M{"$sum": "$pageviews"},
+		}},
+	}
+
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	cur, err := db.Aggregate(context.TODO(), sessionCollection, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	var buckets []PageBucket
+	for cur.Next(context.TODO()) {
+		var row struct {
+			Key       *string `bson:"_id"`
+			Sessions  int64   `bson:"sessions"`
+			Pageviews int64   `bson:"pageviews"`
+		}
+		if err := cur.Decode(&row); err != nil {
+			return nil, err
+		}
+		bucket := PageBucket{Sessions: row.Sessions, Pageviews: row.Pageviews}
+		if row.Key != nil {
+			bucket.Key = *row.Key
+		}
+		buckets = append(buckets, bucket)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return sortPageBuckets(buckets), nil
+}
+
+// PageBreakdown count pageviews of website by page meta dimension, most viewed first
+func (instance *clickHouseRepository) PageBreakdown(userID, websiteID, dimension string, filter BreakdownFilter) ([]PageBucket, error) {
+	params := instance.params(userID)
+	params["website"] = websiteID
+	params["from"] = clickhouse.TimeParam(filter.From)
+	params["to"] = clickhouse.TimeParam(filter.To)
+	params["tag"] = PageMetaTag
+	params["dimension"] = dimension
+	query := "SELECT JSONExtractString(data, 'payload', {dimension:String}) AS key," +
+		" uniqExact(id) AS sessions, count() AS pageviews FROM " + db.ClickHouseEventTable +
+		" WHERE " + clickHouseReportFilter + " AND website_id = {website:String}" +
+		" AND time_report >= {from:DateTime64(3)} AND time_report < {to:DateTime64(3)}" +
+		" AND JSONExtractString(data, 'tag') = {tag:String} GROUP BY key"
+
+	var buckets []PageBucket
+	err := configs.ClickHouse.Client.Query(query, params, func(line []byte) error {
+		var row PageBucket
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		buckets = append(buckets, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sortPageBuckets(buckets), nil
+}
+
+// sortPageBuckets sort by pageviews, pages without the meta tag have an empty key
+func sortPageBuckets(buckets []PageBucket) []PageBucket {
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Pageviews != buckets[j].Pageviews {
+			return buckets[i].Pageviews > buckets[j].Pageviews
+		}
+		return buckets[i].Key < buckets[j].Key
+	})
+	if buckets == nil {
+		buckets = []PageBucket{}
+	}
+	return buckets
+}
@@ -0,0 +1,144 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/clickhouse"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Page views of a path, read from the href of rrweb meta events so only web
+// sessions have pages
+type Page struct {
+	Path      string `json:"path"`
+	Sessions  int64  `json:"sessions"`
+	Pageviews int64  `json:"pageviews"`
+}
+
+// hrefPath capture the path of an absolute url, query and fragment left out
+const hrefPath = "^[a-zA-Z][a-zA-Z0-9+.-]*://[^/?#]*([^?#]*)"
+
+// Pages sessions and pageviews of website by path, most viewed first
+func (instance *repository) Pages(userID, websiteID string, filter BreakdownFilter) ([]Page, error) {
+	var href interface{} = "$event.data.href"
+	if filter.Canonical {
+		href = bson.M{"$ifNull": []interface{}{"$event.data.canonical", "$event.data.href"}}
+	}
+	match := []bson.M{
+		{"meta_data.user_id": userID},
+		{"meta_data.website_id": websiteID},
+		onTime,
+		{"time_report": bson.M{"$gte": filter.From, "$lt": filter.To}},
+		{"event.type": metaEventType},
+	}
+	pipeline := []bson.M{
+		{"$match": bson.M{"$and": match}},
+		{"$project": bson.M{
+			"id":   "$meta_data.id",
+			"path": bson.M{"$regexFind": bson.M{"input": href, "regex": hrefPath}},
+		}},
+		{"$group": bson.M{
+			"_id":       bson.M{"path": bson.M{"$arrayElemAt": []interface{}{"$path.captures", 0}}, "id": "$id"},
+			"pageviews": bson.M{"$sum": 1},
+		}},
+		{"$group": bson.M{
+			"_id":       "$_id.path",
+			"sessions":  bson.M{"$sum": 1},
+			"pageviews": bson.M{"$sum": "$pageviews"},
+		}},
+	}
+
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	cur, err := db.Aggregate(context.TODO(), sessionCollection, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	var pages []Page
+	for cur.Next(context.TODO()) {
+		var row struct {
+			Path      *string `bson:"_id"`
+			Sessions  int64   `bson:"sessions"`
+			Pageviews int64   `bson:"pageviews"`
+		}
+		if err := cur.Decode(&row); err != nil {
+			return nil, err
+		}
+		aPage := Page{Sessions: row.Sessions, Pageviews: row.Pageviews}
+		if row.Path != nil {
+			aPage.Path = *row.Path
+		}
+		pages = append(pages, aPage)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return sortPages(pages), nil
+}
+
+// Pages sessions and pageviews of website by path, most viewed first
+func (instance *clickHouseRepository) Pages(userID, websiteID string, filter BreakdownFilter) ([]Page, error) {
+	params := instance.params(userID)
+	params["website"] = websiteID
+	params["from"] = clickhouse.TimeParam(filter.From)
+	params["to"] = clickhouse.TimeParam(filter.To)
+	href := "JSONExtractString(data, 'href')"
+	if filter.Canonical {
+		href = "if(JSONHas(data, 'canonical'), JSONExtractString(data, 'canonical'), " + href + ")"
+	}
+	query := "SELECT extract(" + href + ", '" + hrefPath + "') AS path," +
+		" uniqExact(id) AS sessions, count() AS pageviews FROM " + db.ClickHouseEventTable +
+		" WHERE " + clickHouseReportFilter + " AND website_id = {website:String}" +
+		" AND time_report >= {from:DateTime64(3)} AND time_report < {to:DateTime64(3)}" +
+		" AND type = 4 GROUP BY path"
+
+	var pages []Page
+	err := configs.ClickHouse.Client.Query(query, params, func(line []byte) error {
+		var row Page
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		pages = append(pages, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sortPages(pages), nil
+}
+
+// sortPages count the root of sites like / then sort by pageviews
+func sortPages(pages []Page) []Page {
+	for i := range pages {
+		if pages[i].Path == "" {
+			pages[i].Path = "/"
+		}
+	}
+	merged := pages[:0]
+	index := map[string]int{}
+	for _, aPage := range pages {
+		if i, ok := index[aPage.Path]; ok {
+			merged[i].Sessions += aPage.Sessions
+			merged[i].Pageviews += aPage.Pageviews
+			continue
+		}
+		index[aPage.Path] = len(merged)
+		merged = append(merged, aPage)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].Pageviews != merged[j].Pageviews {
+			return merged[i].Pageviews > merged[j].Pageviews
+		}
+		return merged[i].Path < merged[j].Path
+	})
+	if merged == nil {
+		merged = []Page{}
+	}
+	return merged
+}
@@ -0,0 +1,92 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/clickhouse"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Referrals web sessions of a website and those arriving without referrer,
+// reported as (not set). A session counts under the referrer of its first
+// event, sessions stored before referrers were kept have none
+type Referrals struct {
+	Sessions int64 `json:"sessions" bson:"sessions"`
+	NotSet   int64 `json:"not_set" bson:"not_set"`
+}
+
+// Referrals web sessions of website and those without referrer
+func (instance *repository) Referrals(userID, websiteID string, filter BreakdownFilter) (*Referrals, error) {
+	match := bson.M{"$and": []bson.M{
+		{"meta_data.user_id": userID},
+		{"meta_data.website_id": websiteID},
+		onTime,
+		{"time_report": bson.M{"$gte": filter.From, "$lt": filter.To}},
+		{"meta_data.platform": bson.M{"$in": []interface{}{PlatformWeb, nil}}},
+	}}
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$group": bson.M{
+			"_id":      "$meta_data.id",
+			"referrer": bson.M{"$first": bson.M{"$ifNull": []interface{}{"$meta_data.referrer", ""}}},
+		}},
+		{"$group": bson.M{
+			"_id":      nil,
+			"sessions": bson.M{"$sum": 1},
+			"not_set":  bson.M{"$sum": bson.M{"$cond": []interface{}{bson.M{"$eq": []interface{}{"$referrer", ""}}, 1, 0}}},
+		}},
+	}
+
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	cur, err := db.Aggregate(context.TODO(), sessionCollection, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	aReferrals := &Referrals{}
+	if cur.Next(context.TODO()) {
+		if err := cur.Decode(aReferrals); err != nil {
+			return nil, err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return aReferrals, nil
+}
+
+// Referrals web sessions of website and those without referrer
+func (instance *clickHouseRepository) Referrals(userID, websiteID string, filter BreakdownFilter) (*Referrals, error) {
+	params := instance.params(userID)
+	params["website"] = websiteID
+	params["from"] = clickhouse.TimeParam(filter.From)
+	params["to"] = clickhouse.TimeParam(filter.To)
+	query := "SELECT count() AS sessions, countIf(referrer = '') AS not_set FROM (" +
+		"SELECT argMin(referrer, time_report) AS referrer FROM " + db.ClickHouseEventTable +
+		" WHERE " + clickHouseReportFilter + " AND website_id = {website:String}" +
+		" AND time_report >= {from:DateTime64(3)} AND time_report < {to:DateTime64(3)}" +
+		" AND platform IN ('web', '') GROUP BY id)"
+
+	aReferrals := &Referrals{}
+	err := configs.ClickHouse.Client.Query(query, params, func(line []byte) error {
+		return json.Unmarshal(line, aReferrals)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return aReferrals, nil
+}
+
+func (instance *dualRepository) Referrals(userID, websiteID string, filter BreakdownFilter) (*Referrals, error) {
+	return instance.primary.Referrals(userID, websiteID, filter)
+}
+
+// Referrals web sessions of website and those arriving without referrer
+func (instance *useCase) Referrals(userID, websiteID string, filter BreakdownFilter) (*Referrals, error) {
+	return instance.repo.Referrals(userID, websiteID, filter)
+}
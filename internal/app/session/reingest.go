@@ -0,0 +1,176 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/clickhouse"
+	"analytics-api/internal/pkg/eventtime"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Correction corrected version of the stored event of session with the
+// idempotency key ID
+type Correction struct {
+	SessionID string `json:"session_id" bson:"session_id" validate:"required,max=100"`
+	ID        string `json:"id" bson:"id" validate:"required,max=100"`
+	Type      int64  `json:"type" bson:"type"`
+	Data      bson.M `json:"data" bson:"data"`
+	Timestamp int64  `json:"timestamp" bson:"timestamp" validate:"required"`
+}
+
+// Replaced event replaced by a correction, From the time it was reported at
+// and To the time it is now
+type Replaced struct {
+	SessionID string
+	ID        string
+	From      time.Time
+	To        time.Time
+}
+
+func (instance *Correction) event() event {
+	return event{ID: instance.ID, Type: instance.Type, Data: instance.Data, Timestamp: instance.Timestamp}
+}
+
+// ReplaceEvents replace the stored events of website matching corrections,
+// those matching none are left out of the result
+func (instance *useCase) ReplaceEvents(userID, websiteID string, corrections []Correction) ([]Replaced, error) {
+	replaced, err := instance.repo.ReplaceEvents(userID, websiteID, corrections)
+	if err != nil {
+		return nil, err
+	}
+	return replaced, nil
+}
+
+// ReplaceEvents replace each event matching a correction, keeping the
+// metadata of its session. The corrected event is stored before the old one
+// is deleted, so a failure leaves the old one or both but never none, and
+// replacing it again is harmless
+func (instance *repository) ReplaceEvents(userID, websiteID string, corrections []Correction) ([]Replaced, error) {
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	var listReplaced []Replaced
+	for _, aCorrection := range corrections {
+		filter := bson.M{"$and": []bson.M{
+			{"meta_data.user_id": userID},
+			{"meta_data.website_id": websiteID},
+			{"meta_data.id": aCorrection.SessionID},
+			{"event.id": aCorrection.ID},
+		}}
+		var stored session
+		err := sessionCollection.FindOne(context.TODO(), filter).Decode(&stored)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		corrected := stored
+		corrected.Event = aCorrection.event()
+		corrected.TimeReport = eventtime.Time(aCorrection.Timestamp)
+		if err := sealEvent(instance.store, &corrected.Event); err != nil {
+			return nil, err
+		}
+		inserted, err := sessionCollection.InsertOne(context.TODO(), corrected)
+		if err != nil {
+			return nil, err
+		}
+		// a key stored twice is left with its corrected event only
+		stale := bson.M{"$and": []bson.M{filter, {"_id": bson.M{"$ne": inserted.InsertedID}}}}
+		if _, err := sessionCollection.DeleteMany(context.TODO(), stale); err != nil {
+			return nil, err
+		}
+		listReplaced = append(listReplaced, Replaced{
+			SessionID: aCorrection.SessionID,
+			ID:        aCorrection.ID,
+			From:      stored.TimeReport,
+			To:        corrected.TimeReport,
+		})
+	}
+	return listReplaced, nil
+}
+
+// ReplaceEvents replace the rows matching corrections. The old rows are
+// deleted by a mutation submitted before the corrected rows are inserted,
+// mutations only apply to the rows there when they are submitted
+func (instance *clickHouseRepository) ReplaceEvents(userID, websiteID string, corrections []Correction) ([]Replaced, error) {
+	byKey := map[string]Correction{}
+	sessionIDs := make([]string, 0, len(corrections))
+	ids := make([]string, 0, len(corrections))
+	for _, aCorrection := range corrections {
+		byKey[aCorrection.SessionID+"\n"+aCorrection.ID] = aCorrection
+		sessionIDs = append(sessionIDs, aCorrection.SessionID)
+		ids = append(ids, aCorrection.ID)
+	}
+	params := instance.params(userID)
+	params["website"] = websiteID
+	params["sessions"] = clickhouse.ArrayParam(sessionIDs)
+	params["ids"] = clickhouse.ArrayParam(ids)
+	filter := clickHouseFilter + " AND website_id = {website:String}" +
+		" AND has(arrayZip({sessions:Array(String)}, {ids:Array(String)}), (id, event_id))"
+
+	var rows []interface{}
+	var listReplaced []Replaced
+	err := configs.ClickHouse.Client.Query("SELECT * FROM "+db.ClickHouseEventTable+" WHERE "+filter, params, func(line []byte) error {
+		var row clickHouseEvent
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		aCorrection, ok := byKey[row.ID+"\n"+row.EventID]
+		if !ok {
+			return nil
+		}
+		// a key stored twice is replaced by one row
+		delete(byKey, row.ID+"\n"+row.EventID)
+		aSession, err := row.toSession()
+		if err != nil {
+			return err
+		}
+		from := aSession.TimeReport
+		aSession.TimeReport = eventtime.Time(aCorrection.Timestamp)
+		anEvent := aCorrection.event()
+		if err := sealEvent(instance.store, &anEvent); err != nil {
+			return err
+		}
+		corrected, err := newClickHouseEvent(instance.store.TenantID, aSession, anEvent)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, corrected)
+		listReplaced = append(listReplaced, Replaced{SessionID: row.ID, ID: row.EventID, From: from, To: aSession.TimeReport})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	// keys stored twice are left with their corrected row only
+	err = configs.ClickHouse.Client.Exec("ALTER TABLE "+db.ClickHouseEventTable+" DELETE WHERE "+filter, params)
+	if err != nil {
+		return nil, err
+	}
+	if err := configs.ClickHouse.Client.Insert(db.ClickHouseEventTable, rows); err != nil {
+		return nil, err
+	}
+	return listReplaced, nil
+}
+
+func (instance *dualRepository) ReplaceEvents(userID, websiteID string, corrections []Correction) ([]Replaced, error) {
+	replaced, err := instance.primary.ReplaceEvents(userID, websiteID, corrections)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := instance.secondary.ReplaceEvents(userID, websiteID, corrections); err != nil {
+		logrus.Error("dual write to secondary storage error ", err)
+	}
+	return replaced, nil
+}
@@ -0,0 +1,122 @@
+package session
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"analytics-api/internal/app/audit"
+	"analytics-api/internal/app/user"
+	"analytics-api/internal/pkg/httperr"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrReplayForbidden ...
+var ErrReplayForbidden = errors.New("viewers cannot watch session recordings")
+
+// HeaderWatermark carries the watermark of the event stream, base64 of its
+// JSON since the body is a stream of event arrays
+const HeaderWatermark = "X-Watermark"
+
+// watermark who is watching a recording, overlaid on the player so a leaked
+// screenshot or screen recording points to its viewer
+type watermark struct {
+	// ViewID id of the audit entry of the view
+	ViewID   string    `json:"view_id,omitempty"`
+	UserID   string    `json:"user_id"`
+	Email    string    `json:"email"`
+	IP       string    `json:"ip"`
+	ViewedAt time.Time `json:"viewed_at"`
+	// Text line the player shows over the recording
+	Text string `json:"text"`
+}
+
+// viewReplay let userID watch the recording of sessionID, recording the view in
+// the audit log unless it continues a view already recorded. Return the
+// watermark to overlay, with the person signed in when userID is an
+// organization, nil when the store does not watermark recordings. False when
+// the response was written with an error
+func (instance *httpDelivery) viewReplay(c *gin.Context, userID, sessionID string, record bool) (*watermark, bool) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return nil, false
+	}
+	role, err := instance.userUseCase.AccountRole(tokenAuth)
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get user failed")
+		return nil, false
+	}
+	if !user.Can(role, user.PermissionReplay) {
+		httperr.Abort(c, http.StatusForbidden, httperr.CodeForbidden, ErrReplayForbidden.Error())
+		return nil, false
+	}
+
+	viewID := ""
+	if record {
+		var aSession session
+		err := instance.sessionUseCase.GetSession(userID, sessionID, &aSession)
+		switch err {
+		case nil:
+		case mongo.ErrNoDocuments:
+			httperr.Abort(c, http.StatusNotFound, CodeSessionNotFound, "this session not exists")
+			return nil, false
+		default:
+			logrus.Error(c, err)
+			httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get session failed")
+			return nil, false
+		}
+		// a view that cannot be traced is not served
+		viewID, err = instance.auditUseCase.Record(audit.ClientOf(c), userID, audit.ActionReplayView, aSession.MetaData.WebsiteID, sessionID)
+		if err != nil {
+			logrus.Error(c, err)
+			httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "record replay view failed")
+			return nil, false
+		}
+	}
+
+	if !instance.store.ReplayWatermark.Load() {
+		return nil, true
+	}
+	viewerID, err := instance.authUsecase.GetUser(tokenAuth)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return nil, false
+	}
+	email, err := instance.userUseCase.GetEmail(viewerID)
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get user failed")
+		return nil, false
+	}
+	aWatermark := &watermark{
+		ViewID:   viewID,
+		UserID:   viewerID,
+		Email:    email,
+		IP:       c.ClientIP(),
+		ViewedAt: time.Now().UTC(),
+	}
+	parts := []string{aWatermark.Email, aWatermark.IP, aWatermark.ViewedAt.Format("2006-01-02 15:04 UTC")}
+	if viewID != "" {
+		parts = append(parts, viewID)
+	}
+	aWatermark.Text = strings.Join(parts, " · ")
+	return aWatermark, true
+}
+
+// encodeWatermark value of HeaderWatermark
+func encodeWatermark(aWatermark *watermark) (string, error) {
+	raw, err := json.Marshal(aWatermark)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,44 @@
+package session
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestEncodeWatermark(t *testing.T) {
+	viewedAt := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		watermark watermark
+	}{
+		{
+			name:      "should carry the viewer and the view",
+			watermark: watermark{ViewID: "v1", UserID: "u1", Email: "a@example.com", IP: "203.0.113.7", ViewedAt: viewedAt, Text: "a@example.com · 203.0.113.7"},
+		},
+		{
+			name:      "should carry a view not recorded",
+			watermark: watermark{UserID: "u1", Email: "Hồ@example.com", IP: "::1", ViewedAt: viewedAt},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := encodeWatermark(&tt.watermark)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				t.Fatalf("header value %q is not base64: %v", encoded, err)
+			}
+			var got watermark
+			if err := json.Unmarshal(raw, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.watermark {
+				t.Errorf("decoded %+v, want %+v", got, tt.watermark)
+			}
+		})
+	}
+}
@@ -2,16 +2,28 @@ package session
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"strconv"
+	"strings"
 	"time"
 
 	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/cursor"
+	"analytics-api/internal/pkg/keystore"
 	str "analytics-api/internal/pkg/string"
 
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"gopkg.in/mgo.v2/bson"
 )
 
+// onTime filter of the reports leaving out the events flagged late, they
+// arrived once their day was settled
+var onTime = bson.M{"event.late": bson.M{"$ne": true}}
+
 // Repository ...
 type Repository interface {
 	GetAllSession(userID, websiteID string, listSessionID []string, session session) ([]session, error)
@@ -21,24 +33,68 @@ type Repository interface {
 	GetSession(userID, sessionID string, session *session) error
 
 	GetCountSession(userID, sessionID string) (int64, error)
+	CountSessionSince(userID, websiteID string, since time.Time) (int64, error)
+	CountSessionsSince(userID string, websiteIDs []string, since []time.Time) (map[string][]int64, error)
+	Breakdown(userID, websiteID, dimension string, filter BreakdownFilter) ([]Bucket, error)
+	HeatTable(userID, websiteID string, filter BreakdownFilter, location *time.Location) ([]HeatCell, error)
+	Daily(userID, websiteID string, filter BreakdownFilter, location *time.Location) ([]Day, error)
+	Pages(userID, websiteID string, filter BreakdownFilter) ([]Page, error)
+	PageBreakdown(userID, websiteID, dimension string, filter BreakdownFilter) ([]PageBucket, error)
+	Engagement(userID, websiteID string, filter BreakdownFilter) ([]PageEngagement, error)
+	Visits(userID, websiteID string, filter BreakdownFilter) (*Visits, error)
+	Totals(userID, websiteID, dimension string, forms []string, filter BreakdownFilter) ([]Totals, error)
+	Referrals(userID, websiteID string, filter BreakdownFilter) (*Referrals, error)
+	Forms(userID, websiteID string, filter BreakdownFilter) ([]FormStats, error)
+	FormSubmissions(userID, websiteID, formID string, filter BreakdownFilter) (int64, error)
+	FormFunnel(userID, websiteID, formID string, filter BreakdownFilter) (*FormFunnel, error)
 	InsertSession(session session, event event) error
 
 	GetEventByLimitSkip(userID, sessionID string, limit, skip int) ([]*event, error)
 
+	StreamSession(userID, websiteID string, listSessionID []string, fn func(session) error) error
+	StreamEvent(userID, sessionID string, fn func(*event) error) error
+	StreamRawEvent(userID, websiteID string, from, to time.Time, fn func(RawEvent) error) error
+
+	GetSessionPage(userID, websiteID string, today bool, after *cursor.Cursor, limit int) ([]string, *cursor.Cursor, error)
+	GetEventPage(userID, sessionID string, after *cursor.Cursor, limit int) ([]*event, *cursor.Cursor, error)
+
 	GetSessionTimestamp(sessionID string) (int64, error)
 	InsertSessionTimestamp(sessionID string, timeStart int64) error
+	SegmentSessionID(websiteID, anonymousID string) (string, error)
+
+	DeleteSessionBefore(before time.Time, keep []string) (int64, error)
+	ReplaceEvents(userID, websiteID string, corrections []Correction) ([]Replaced, error)
+}
+
+type repository struct {
+	store *db.Store
 }
 
-type repository struct{}
+// NewRepository events are read from the primary backend, and written to
+// both Mongo and ClickHouse while dual write is on
+func NewRepository(store *db.Store) Repository {
+	mongoRepo := &repository{
+		store: store,
+	}
+	if !configs.UsesClickHouse() {
+		return mongoRepo
+	}
 
-// NewRepository ...
-func NewRepository() Repository {
-	return &repository{}
+	clickHouseRepo := &clickHouseRepository{
+		repository: mongoRepo,
+	}
+	if !configs.Storage.DualWrite {
+		return clickHouseRepo
+	}
+	if configs.Storage.Primary == "clickhouse" {
+		return &dualRepository{primary: clickHouseRepo, secondary: mongoRepo}
+	}
+	return &dualRepository{primary: mongoRepo, secondary: clickHouseRepo}
 }
 
 // GetSession get session by session id
 func (instance *repository) GetSession(userID, sessionID string, aSession *session) error {
-	sessionCollection := configs.MongoDB.Client.Collection(configs.MongoDB.SessionCollection)
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
 	filter := bson.M{"$and": []bson.M{
 		{"meta_data.user_id": userID},
 		{"meta_data.id": sessionID},
@@ -53,17 +109,30 @@ func (instance *repository) GetSession(userID, sessionID string, aSession *sessi
 // GetAllSession get all session
 func (instance *repository) GetAllSession(userID, websiteID string, listSessionID []string, aSession session) ([]session, error) {
 	var listSession []session
+	err := instance.StreamSession(userID, websiteID, listSessionID, func(aSession session) error {
+		listSession = append(listSession, aSession)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return listSession, nil
+}
+
+// StreamSession call fn with the last event of each session, in the order of listSessionID
+func (instance *repository) StreamSession(userID, websiteID string, listSessionID []string, fn func(session) error) error {
 	opt := options.FindOne()
-	sessionCollection := configs.MongoDB.Client.Collection(configs.MongoDB.SessionCollection)
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
 
 	for _, sessionID := range listSessionID {
+		var aSession session
 		count, err := sessionCollection.CountDocuments(context.TODO(), bson.M{"$and": []bson.M{
 			{"meta_data.id": sessionID},
 			{"meta_data.website_id": websiteID},
 			{"meta_data.user_id": userID},
 		}})
 		if err != nil {
-			return nil, err
+			return err
 		}
 		opt.SetSkip(count - 1)
 		err = sessionCollection.FindOne(context.TODO(), bson.M{"$and": []bson.M{
@@ -72,11 +141,13 @@ func (instance *repository) GetAllSession(userID, websiteID string, listSessionI
 			{"meta_data.user_id": userID},
 		}}, opt).Decode(&aSession)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := fn(aSession); err != nil {
+			return err
 		}
-		listSession = append(listSession, aSession)
 	}
-	return listSession, nil
+	return nil
 }
 
 // GetAllSessionID get all id of session all time
@@ -87,7 +158,7 @@ func (instance *repository) GetAllSessionID(userID, websiteID string, aSession s
 		{"meta_data.website_id": websiteID},
 	}}
 
-	sessionCollection := configs.MongoDB.Client.Collection(configs.MongoDB.SessionCollection)
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
 	cursor, err := sessionCollection.Find(context.TODO(), filter)
 	if err != nil {
 		return nil, err
@@ -119,7 +190,7 @@ func (instance *repository) GetSessionIDToday(userID, websiteID string, aSession
 		}},
 	}}
 
-	sessionCollection := configs.MongoDB.Client.Collection(configs.MongoDB.SessionCollection)
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
 	cursor, err := sessionCollection.Find(context.TODO(), filter)
 	if err != nil {
 		return nil, err
@@ -137,7 +208,11 @@ func (instance *repository) GetSessionIDToday(userID, websiteID string, aSession
 
 // InsertSession insert session
 func (instance *repository) InsertSession(aSession session, event event) error {
-	sessionCollection := configs.MongoDB.Client.Collection(configs.MongoDB.SessionCollection)
+	if err := sealEvent(instance.store, &event); err != nil {
+		return err
+	}
+
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
 	docs := session{
 		MetaData: metaData{
 			ID:        aSession.MetaData.ID,
@@ -150,6 +225,15 @@ func (instance *repository) InsertSession(aSession session, event event) error {
 			Browser:   aSession.MetaData.Browser,
 			Version:   aSession.MetaData.Version,
 			CreatedAt: aSession.MetaData.CreatedAt,
+
+			Platform:    aSession.MetaData.Platform,
+			AppVersion:  aSession.MetaData.AppVersion,
+			OSVersion:   aSession.MetaData.OSVersion,
+			DeviceModel: aSession.MetaData.DeviceModel,
+			CountryCode: aSession.MetaData.CountryCode,
+			Region:      aSession.MetaData.Region,
+			RegionCode:  aSession.MetaData.RegionCode,
+			Referrer:    aSession.MetaData.Referrer,
 		},
 		Duration:   aSession.Duration,
 		Event:      event,
@@ -164,7 +248,7 @@ func (instance *repository) InsertSession(aSession session, event event) error {
 
 // GetCountSession get count session of session id
 func (instance *repository) GetCountSession(userID, sessionID string) (int64, error) {
-	sessionCollection := configs.MongoDB.Client.Collection(configs.MongoDB.SessionCollection)
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
 	filter := bson.M{"$and": []bson.M{
 		{"meta_data.user_id": userID},
 		{"meta_data.id": sessionID},
@@ -176,8 +260,96 @@ func (instance *repository) GetCountSession(userID, sessionID string) (int64, er
 	return count, nil
 }
 
+// CountSessionSince count sessions of website reported since time
+func (instance *repository) CountSessionSince(userID, websiteID string, since time.Time) (int64, error) {
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	pipeline := []bson.M{
+		{"$match": bson.M{"$and": []bson.M{
+			{"meta_data.user_id": userID},
+			{"meta_data.website_id": websiteID},
+			onTime,
+			{"time_report": bson.M{"$gte": since}},
+		}}},
+		{"$group": bson.M{"_id": "$meta_data.id"}},
+		{"$count": "count"},
+	}
+	cur, err := db.Aggregate(context.TODO(), sessionCollection, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(context.TODO())
+
+	var row struct {
+		Count int64 `bson:"count"`
+	}
+	if cur.Next(context.TODO()) {
+		if err := cur.Decode(&row); err != nil {
+			return 0, err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return 0, err
+	}
+	return row.Count, nil
+}
+
+// CountSessionsSince count sessions of each website reported since each time,
+// a session counts from its last event
+func (instance *repository) CountSessionsSince(userID string, websiteIDs []string, since []time.Time) (map[string][]int64, error) {
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	earliest := since[0]
+	counts := bson.M{"_id": "$_id.website_id"}
+	for i, t := range since {
+		if t.Before(earliest) {
+			earliest = t
+		}
+		counts["c"+strconv.Itoa(i)] = bson.M{"$sum": bson.M{"$cond": []interface{}{bson.M{"$gte": []interface{}{"$last", t}}, 1, 0}}}
+	}
+	pipeline := []bson.M{
+		{"$match": bson.M{"$and": []bson.M{
+			{"meta_data.user_id": userID},
+			{"meta_data.website_id": bson.M{"$in": websiteIDs}},
+			onTime,
+			{"time_report": bson.M{"$gte": earliest}},
+		}}},
+		{"$group": bson.M{
+			"_id":  bson.M{"website_id": "$meta_data.website_id", "id": "$meta_data.id"},
+			"last": bson.M{"$max": "$time_report"},
+		}},
+		{"$group": counts},
+	}
+	cur, err := db.Aggregate(context.TODO(), sessionCollection, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	result := map[string][]int64{}
+	for cur.Next(context.TODO()) {
+		var row bson.M
+		if err := cur.Decode(&row); err != nil {
+			return nil, err
+		}
+		websiteID, _ := row["_id"].(string)
+		values := make([]int64, len(since))
+		for i := range since {
+			switch count := row["c"+strconv.Itoa(i)].(type) {
+			case int32:
+				values[i] = int64(count)
+			case int64:
+				values[i] = count
+			}
+		}
+		result[websiteID] = values
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 func (instance *repository) GetEventByLimitSkip(userID, sessionID string, limit, skip int) ([]*event, error) {
-	sessionCollection := configs.MongoDB.Client.Collection(configs.MongoDB.SessionCollection)
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
 
 	filter := bson.M{"$and": []bson.M{
 		{"meta_data.user_id": userID},
@@ -198,6 +370,11 @@ func (instance *repository) GetEventByLimitSkip(userID, sessionID string, limit,
 		if err != nil {
 			return nil, err
 		}
+		if session.Event.Sealed != "" {
+			if err := openEvent(instance.store, &session.Event); err != nil {
+				return nil, err
+			}
+		}
 		events = append(events, &session.Event)
 	}
 	if err := cur.Err(); err != nil {
@@ -208,9 +385,160 @@ func (instance *repository) GetEventByLimitSkip(userID, sessionID string, limit,
 	return events, nil
 }
 
+// StreamEvent call fn with each event of session as it is read from the cursor
+func (instance *repository) StreamEvent(userID, sessionID string, fn func(*event) error) error {
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"meta_data.user_id": userID},
+		{"meta_data.id": sessionID},
+	}}
+	cur, err := sessionCollection.Find(context.TODO(), filter)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(context.TODO())
+
+	for cur.Next(context.TODO()) {
+		var session session
+		err := cur.Decode(&session)
+		if err != nil {
+			return err
+		}
+		if session.Event.Sealed != "" {
+			if err := openEvent(instance.store, &session.Event); err != nil {
+				return err
+			}
+		}
+		if err := fn(&session.Event); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+// GetSessionPage get id of sessions sorted by their first event, after the cursor
+func (instance *repository) GetSessionPage(userID, websiteID string, today bool, after *cursor.Cursor, limit int) ([]string, *cursor.Cursor, error) {
+	match := []bson.M{
+		{"meta_data.user_id": userID},
+		{"meta_data.website_id": websiteID},
+	}
+	if today {
+		fromDate := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 0, 0, 0, 0, time.UTC)
+		toDate := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 24, 0, 0, 0, time.UTC)
+		match = append(match, bson.M{"time_report": bson.M{"$gt": fromDate, "$lt": toDate}})
+	}
+	pipeline := []bson.M{
+		{"$match": bson.M{"$and": match}},
+		{"$group": bson.M{"_id": "$meta_data.id", "start": bson.M{"$min": "$event.timestamp"}}},
+	}
+	if after != nil {
+		pipeline = append(pipeline, bson.M{"$match": bson.M{"$or": []bson.M{
+			{"start": bson.M{"$gt": after.Key}},
+			{"start": after.Key, "_id": bson.M{"$gt": after.ID}},
+		}}})
+	}
+	pipeline = append(pipeline,
+		bson.M{"$sort": primitive.D{{Key: "start", Value: 1}, {Key: "_id", Value: 1}}},
+		bson.M{"$limit": limit + 1},
+	)
+
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	cur, err := db.Aggregate(context.TODO(), sessionCollection, pipeline)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	var keys []cursor.Cursor
+	for cur.Next(context.TODO()) {
+		var row struct {
+			ID    string `bson:"_id"`
+			Start int64  `bson:"start"`
+		}
+		if err := cur.Decode(&row); err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, cursor.Cursor{Key: row.Start, ID: row.ID})
+	}
+	if err := cur.Err(); err != nil {
+		return nil, nil, err
+	}
+	listSessionID, next := sessionPage(keys, limit)
+	return listSessionID, next, nil
+}
+
+// GetEventPage get events of session sorted by timestamp, after the cursor
+func (instance *repository) GetEventPage(userID, sessionID string, after *cursor.Cursor, limit int) ([]*event, *cursor.Cursor, error) {
+	match := []bson.M{
+		{"meta_data.user_id": userID},
+		{"meta_data.id": sessionID},
+	}
+	if after != nil {
+		afterID, err := primitive.ObjectIDFromHex(after.ID)
+		if err != nil {
+			return nil, nil, cursor.ErrInvalidCursor
+		}
+		match = append(match, bson.M{"$or": []bson.M{
+			{"event.timestamp": bson.M{"$gt": after.Key}},
+			{"event.timestamp": after.Key, "_id": bson.M{"$gt": afterID}},
+		}})
+	}
+	findOptions := options.Find().
+		SetSort(primitive.D{{Key: "event.timestamp", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(limit + 1))
+
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	cur, err := sessionCollection.Find(context.TODO(), bson.M{"$and": match}, findOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	var events []*event
+	var keys []cursor.Cursor
+	for cur.Next(context.TODO()) {
+		var row struct {
+			ID    primitive.ObjectID `bson:"_id"`
+			Event event              `bson:"event"`
+		}
+		if err := cur.Decode(&row); err != nil {
+			return nil, nil, err
+		}
+		if row.Event.Sealed != "" {
+			if err := openEvent(instance.store, &row.Event); err != nil {
+				return nil, nil, err
+			}
+		}
+		events = append(events, &row.Event)
+		keys = append(keys, cursor.Cursor{Key: row.Event.Timestamp, ID: row.ID.Hex()})
+	}
+	if err := cur.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(events) > limit {
+		return events[:limit], &keys[limit-1], nil
+	}
+	return events, nil, nil
+}
+
+// sessionPage id of sessions of a page read with one extra key, and the cursor
+// of the next page when that extra key exists
+func sessionPage(keys []cursor.Cursor, limit int) ([]string, *cursor.Cursor) {
+	var next *cursor.Cursor
+	if len(keys) > limit {
+		keys = keys[:limit]
+		next = &keys[limit-1]
+	}
+	listSessionID := []string{}
+	for _, key := range keys {
+		listSessionID = append(listSessionID, key.ID)
+	}
+	return listSessionID, next
+}
+
 // InsertSessionTimestamp insert first timestamp by session id
 func (instance *repository) InsertSessionTimestamp(sessionID string, timeStart int64) error {
-	err := configs.Redis.Client.Set(sessionID, timeStart, 24*time.Hour).Err()
+	err := configs.KeyStore.Client.Set(instance.store.Key(sessionID), strconv.FormatInt(timeStart, 10), 24*time.Hour)
 	if err != nil {
 		return err
 	}
@@ -219,7 +547,7 @@ func (instance *repository) InsertSessionTimestamp(sessionID string, timeStart i
 
 // GetSessionTimestamp get first timestamp by session id
 func (instance *repository) GetSessionTimestamp(sessionID string) (int64, error) {
-	timeStartStr, err := configs.Redis.Client.Get(sessionID).Result()
+	timeStartStr, err := configs.KeyStore.Client.Get(instance.store.Key(sessionID))
 	if err != nil {
 		return 0, err
 	}
@@ -229,3 +557,68 @@ func (instance *repository) GetSessionTimestamp(sessionID string) (int64, error)
 	}
 	return timeStart, nil
 }
+
+// SegmentSessionID session of the Segment calls of anonymousID, a new one once
+// the visitor was inactive for segmentSessionTimeout
+func (instance *repository) SegmentSessionID(websiteID, anonymousID string) (string, error) {
+	key := instance.store.Key("segment:" + websiteID + ":" + anonymousID)
+	sessionID, err := configs.KeyStore.Client.Get(key)
+	if err == keystore.ErrNotFound {
+		sessionID = strings.ReplaceAll(uuid.New().String()+uuid.New().String(), "-", "")
+	} else if err != nil {
+		return "", err
+	}
+	err = configs.KeyStore.Client.Set(key, sessionID, segmentSessionTimeout)
+	if err != nil {
+		return "", err
+	}
+	return sessionID, nil
+}
+
+// DeleteSessionBefore delete all session reported before time, but those of
+// the websites of keep
+func (instance *repository) DeleteSessionBefore(before time.Time, keep []string) (int64, error) {
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	and := []bson.M{{"time_report": bson.M{"$lt": before}}}
+	if len(keep) > 0 {
+		and = append(and, bson.M{"meta_data.website_id": bson.M{"$nin": keep}})
+	}
+	filter := bson.M{"$and": and}
+	deleteResult, err := sessionCollection.DeleteMany(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return deleteResult.DeletedCount, nil
+}
+
+// sealEvent encrypt data of event when the tenant encrypts recordings. Only
+// the replay payload is sealed, meta and custom events hold what reports
+// aggregate and stay in the clear
+func sealEvent(store *db.Store, anEvent *event) error {
+	if store.Keys == nil || anEvent.Type == metaEventType || anEvent.Type == customEventType {
+		return nil
+	}
+	data, err := json.Marshal(anEvent.Data)
+	if err != nil {
+		return err
+	}
+	anEvent.Sealed, err = store.Keys.Seal(data)
+	if err != nil {
+		return err
+	}
+	anEvent.Data = nil
+	return nil
+}
+
+// openEvent decrypt sealed data of event
+func openEvent(store *db.Store, anEvent *event) error {
+	if store.Keys == nil {
+		return errors.New("event is sealed but data encryption is off")
+	}
+	data, err := store.Keys.Open(anEvent.Sealed)
+	if err != nil {
+		return err
+	}
+	anEvent.Sealed = ""
+	return json.Unmarshal(data, &anEvent.Data)
+}
@@ -0,0 +1,317 @@
+package session
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"analytics-api/internal/app/usage"
+	"analytics-api/internal/app/visitor"
+	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/httperr"
+	"analytics-api/internal/pkg/maintenance"
+	req "analytics-api/internal/pkg/request"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/tomasen/realip"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// segmentSessionTimeout inactivity after which the next Segment call of an
+// anonymous id starts a new session, like the default of web analytics
+const segmentSessionTimeout = 30 * time.Minute
+
+// segmentMethods type of message by path of the Segment HTTP tracking API,
+// the single letter paths are the short forms of analytics.js
+var segmentMethods = map[string]string{
+	"track": "track", "t": "track",
+	"page": "page", "p": "page",
+	"identify": "identify", "i": "identify",
+	"screen": "screen", "s": "screen",
+	"group": "group", "g": "group",
+	"alias": "alias", "a": "alias",
+	"batch": "batch", "b": "batch", "import": "batch",
+}
+
+// segmentMessage call of the Segment spec, fields we do not map are dropped
+type segmentMessage struct {
+	Type        string                 `json:"type"`
+	MessageID   string                 `json:"messageId"`
+	AnonymousID string                 `json:"anonymousId"`
+	UserID      string                 `json:"userId"`
+	Event       string                 `json:"event"`
+	Name        string                 `json:"name"`
+	Properties  map[string]interface{} `json:"properties"`
+	Traits      map[string]interface{} `json:"traits"`
+	Timestamp   time.Time              `json:"timestamp"`
+	SentAt      time.Time              `json:"sentAt"`
+	Context     segmentContext         `json:"context"`
+	WriteKey    string                 `json:"writeKey"`
+}
+
+type segmentContext struct {
+	IP        string `json:"ip"`
+	UserAgent string `json:"userAgent"`
+	Page      struct {
+		URL      string `json:"url"`
+		Path     string `json:"path"`
+		Referrer string `json:"referrer"`
+		Title    string `json:"title"`
+		Search   string `json:"search"`
+	} `json:"page"`
+	Campaign struct {
+		Source  string `json:"source"`
+		Medium  string `json:"medium"`
+		Name    string `json:"name"`
+		Term    string `json:"term"`
+		Content string `json:"content"`
+	} `json:"campaign"`
+	OS struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"os"`
+	App struct {
+		Version string `json:"version"`
+	} `json:"app"`
+	Device struct {
+		Model string `json:"model"`
+	} `json:"device"`
+}
+
+type segmentBatch struct {
+	Batch    []segmentMessage `json:"batch"`
+	SentAt   time.Time        `json:"sentAt"`
+	WriteKey string           `json:"writeKey"`
+}
+
+// segmentEvent event of message, false for calls without a counterpart in
+// our event model like group and alias
+func segmentEvent(message segmentMessage, now time.Time) (event, bool) {
+	anEvent := event{Timestamp: now.UnixMilli()}
+	if !message.Timestamp.IsZero() {
+		anEvent.Timestamp = message.Timestamp.UnixMilli()
+	}
+
+	switch message.Type {
+	case "page":
+		href := message.Context.Page.URL
+		if url, ok := message.Properties["url"].(string); ok && url != "" {
+			href = url
+		}
+		if href == "" {
+			return anEvent, false
+		}
+		anEvent.Type = metaEventType
+		anEvent.Data = bson.M{"href": href}
+	case "track":
+		if message.Event == "" {
+			return anEvent, false
+		}
+		payload := map[string]interface{}{}
+		for key, value := range message.Properties {
+			payload[key] = value
+		}
+		if message.Context.Page.Path != "" {
+			payload["path"] = message.Context.Page.Path
+		}
+		anEvent.Type = customEventType
+		anEvent.Data = bson.M{"tag": message.Event, "payload": payload}
+	case "identify":
+		if message.UserID == "" {
+			return anEvent, false
+		}
+		page := message.Context.Page
+		campaign := message.Context.Campaign
+		anEvent.Type = customEventType
+		anEvent.Data = bson.M{"tag": IdentifyTag, "payload": map[string]interface{}{
+			"visitor_id": message.UserID,
+			"first_touch": visitor.FirstTouch{
+				LandingPage: page.URL,
+				Referrer:    page.Referrer,
+				UTMSource:   campaign.Source,
+				UTMMedium:   campaign.Medium,
+				UTMCampaign: campaign.Name,
+				UTMTerm:     campaign.Term,
+				UTMContent:  campaign.Content,
+				Timestamp:   anEvent.Timestamp,
+			},
+		}}
+	case "screen":
+		if message.Name == "" {
+			return anEvent, false
+		}
+		anEvent.Type = customEventType
+		anEvent.Data = bson.M{"tag": ScreenViewTag, "payload": map[string]interface{}{"screen": message.Name}}
+	default:
+		return anEvent, false
+	}
+	return anEvent, true
+}
+
+// segmentPlatform platform of a message by the os Segment mobile libraries
+// report, web for the rest
+func segmentPlatform(message segmentMessage) string {
+	switch strings.ToLower(message.Context.OS.Name) {
+	case "ios", "ipados":
+		return PlatformIOS
+	case "android":
+		return PlatformAndroid
+	}
+	return PlatformWeb
+}
+
+// ReceiveSegment accept calls of the Segment HTTP tracking API authenticated
+// by the write key of a website, each anonymous id is a visitor whose calls
+// are split in sessions of segmentSessionTimeout inactivity
+func (instance *httpDelivery) ReceiveSegment(c *gin.Context) {
+	method, ok := segmentMethods[c.Param("method")]
+	if !ok {
+		httperr.Abort(c, http.StatusNotFound, CodeUnknownSegmentMethod, "unknown segment method")
+		return
+	}
+
+	var batch segmentBatch
+	if method == "batch" {
+		var err error
+		batch, err = req.BindAndValidate[segmentBatch](c)
+		if err != nil {
+			req.BadRequest(c, "invalid segment batch", err)
+			return
+		}
+	} else {
+		message, err := req.BindAndValidate[segmentMessage](c)
+		if err != nil {
+			req.BadRequest(c, "invalid segment message", err)
+			return
+		}
+		message.Type = method
+		batch = segmentBatch{Batch: []segmentMessage{message}, SentAt: message.SentAt, WriteKey: message.WriteKey}
+	}
+
+	writeKey, _, ok := c.Request.BasicAuth()
+	if !ok {
+		writeKey = batch.WriteKey
+	}
+	userID, websiteID, err := instance.websiteUseCase.FindSegmentWriteKey(writeKey)
+	switch err {
+	case nil:
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusUnauthorized, CodeInvalidWriteKey, "invalid write key")
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "find write key failed")
+		return
+	}
+	err = instance.websiteUseCase.Accepts(userID, websiteID)
+	switch err {
+	case nil:
+	case website.ErrNotVerified:
+		httperr.Abort(c, http.StatusForbidden, website.CodeNotVerified, err.Error())
+		return
+	case website.ErrArchived:
+		httperr.Abort(c, http.StatusGone, website.CodeArchived, err.Error())
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "find write key failed")
+		return
+	}
+
+	// calls of aggregate-only websites are not tied to sessions, which would
+	// keep the anonymous ids
+	aggregateOnly, err := instance.websiteUseCase.GetAggregateOnly(websiteID)
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "find write key failed")
+		return
+	}
+
+	now := maintenance.ReceivedAt(c.Request)
+	var requests []*RequestSession
+	bySession := map[string]*RequestSession{}
+	agents := map[string]segmentContext{}
+	for _, message := range batch.Batch {
+		anEvent, ok := segmentEvent(message, now)
+		if !ok {
+			continue
+		}
+		anonymousID := message.AnonymousID
+		if anonymousID == "" {
+			anonymousID = message.UserID
+		}
+		if anonymousID == "" {
+			continue
+		}
+		sessionID := anonymousID
+		if !aggregateOnly {
+			sessionID, err = instance.sessionUseCase.SegmentSessionID(websiteID, anonymousID)
+			if err != nil {
+				logrus.Error(c, err)
+				httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get session failed")
+				return
+			}
+		}
+
+		request, ok := bySession[sessionID]
+		if !ok {
+			request = &RequestSession{
+				UserID:      userID,
+				WebsiteID:   websiteID,
+				SessionID:   sessionID,
+				Platform:    segmentPlatform(message),
+				AppVersion:  message.Context.App.Version,
+				OS:          message.Context.OS.Name,
+				OSVersion:   message.Context.OS.Version,
+				DeviceModel: message.Context.Device.Model,
+				Referrer:    message.Context.Page.Referrer,
+			}
+			sentAt := batch.SentAt
+			if sentAt.IsZero() {
+				sentAt = message.SentAt
+			}
+			if !sentAt.IsZero() {
+				request.SentAt = sentAt.UnixMilli()
+			}
+			bySession[sessionID] = request
+			agents[sessionID] = message.Context
+			requests = append(requests, request)
+		}
+		request.Events = append(request.Events, anEvent)
+	}
+
+	for _, request := range requests {
+		request.Events = acceptEvents(request.Events, request.SentAt, now)
+		request.ReceivedAt = now
+		if len(request.Events) == 0 {
+			continue
+		}
+		userAgent := agents[request.SessionID].UserAgent
+		if userAgent == "" {
+			userAgent = c.Request.UserAgent()
+		}
+		clientIP := net.ParseIP(agents[request.SessionID].IP)
+		if clientIP == nil {
+			clientIP = net.ParseIP(realip.FromRequest(c.Request))
+		}
+		logrus.Info("receive segment calls from website id ", websiteID)
+		_, err := instance.storeSession(*request, userAgent, clientIP)
+		if err == usage.ErrQuotaExceeded {
+			// Segment libraries retry any error, dropped calls are acknowledged
+			logrus.Info("drop segment calls over the quota of website id ", websiteID)
+			continue
+		}
+		if err == ErrExcluded {
+			continue
+		}
+		if err != nil {
+			logrus.Error(c, err)
+			httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "store session failed")
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
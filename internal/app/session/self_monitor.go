@@ -0,0 +1,91 @@
+package session
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/security"
+	str "analytics-api/internal/pkg/string"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tomasen/realip"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// DashboardRequestTag custom event of a request of a signed in user to the
+// dashboard or the management API
+const DashboardRequestTag = "dashboard_request"
+
+// selfMonitorSkipped routes of the collectors, their calls are not usage
+var selfMonitorSkipped = map[string]bool{
+	"/session/receive":    true,
+	"/segment/v1/:method": true,
+}
+
+// SelfMonitor record use of the dashboard as sessions of the internal website,
+// stored like any tracked session. Each signed in user is a visitor whose
+// requests are split in sessions like Segment calls are
+type SelfMonitor struct {
+	delivery  *httpDelivery
+	userID    string
+	websiteID string
+}
+
+// NewSelfMonitor record usage in the internal website websiteID of userID
+func NewSelfMonitor(store *db.Store, userID, websiteID string) *SelfMonitor {
+	return &SelfMonitor{
+		delivery:  NewHTTPDelivery(store).(*httpDelivery),
+		userID:    userID,
+		websiteID: websiteID,
+	}
+}
+
+// RecordUsage store a page view of route, the route pattern so every website
+// id views the same report, and a custom event with the feature, method and
+// status. Stored in the background, requests never wait for it
+func (instance *SelfMonitor) RecordUsage(r *http.Request, route string, status int) {
+	if selfMonitorSkipped[route] {
+		return
+	}
+	tokenAuth, err := security.ExtractAccessTokenMetadata(r)
+	if err != nil {
+		return
+	}
+	feature, _, _ := strings.Cut(strings.TrimPrefix(route, "/"), "/")
+	now := time.Now().UnixMilli()
+	events := []event{
+		{Type: metaEventType, Timestamp: now, Data: bson.M{"href": "https://" + website.InternalHostName + route}},
+		{Type: customEventType, Timestamp: now, Data: bson.M{"tag": DashboardRequestTag, "payload": map[string]interface{}{
+			"path":    route,
+			"feature": feature,
+			"method":  r.Method,
+			"status":  status,
+		}}},
+	}
+	userAgent := r.UserAgent()
+	clientIP := net.ParseIP(realip.FromRequest(r))
+	// users of the dashboard are not named in its own analytics
+	visitor := str.GetMD5Hash(tokenAuth.TenantID + ":" + tokenAuth.UserID)
+
+	go func() {
+		sessionID, err := instance.delivery.sessionUseCase.SegmentSessionID(instance.websiteID, visitor)
+		if err != nil {
+			logrus.Error("self monitoring session error ", err)
+			return
+		}
+		request := RequestSession{
+			UserID:    instance.userID,
+			WebsiteID: instance.websiteID,
+			SessionID: sessionID,
+			Platform:  PlatformWeb,
+			Events:    events,
+		}
+		if _, err := instance.delivery.storeSession(request, userAgent, clientIP); err != nil {
+			logrus.Error("self monitoring store error ", err)
+		}
+	}()
+}
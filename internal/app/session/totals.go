@@ -0,0 +1,180 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/clickhouse"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Totals base metrics of the sessions with a value of a dimension, those
+// calculated metrics are evaluated over
+type Totals struct {
+	Key       string `json:"key"`
+	Sessions  int64  `json:"sessions"`
+	Pageviews int64  `json:"pageviews"`
+	Bounces   int64  `json:"bounces"`
+	// Conversions sessions submitting each of the forms asked for, in order
+	Conversions []int64 `json:"conversions"`
+}
+
+// Totals base metrics of website by value of dimension, a single bucket
+// with an empty key when dimension is empty. A session counts under the
+// value of its first event
+func (instance *repository) Totals(userID, websiteID, dimension string, forms []string, filter BreakdownFilter) ([]Totals, error) {
+	match := []bson.M{
+		{"meta_data.user_id": userID},
+		{"meta_data.website_id": websiteID},
+		onTime,
+		{"time_report": bson.M{"$gte": filter.From, "$lt": filter.To}},
+	}
+	switch filter.Platform {
+	case "":
+	case PlatformWeb:
+		match = append(match, bson.M{"meta_data.platform": bson.M{"$in": []interface{}{PlatformWeb, nil}}})
+	default:
+		match = append(match, bson.M{"meta_data.platform": filter.Platform})
+	}
+	if filter.CountryCode != "" {
+		match = append(match, bson.M{"meta_data.country_code": filter.CountryCode})
+	}
+	if filter.RegionCode != "" {
+		match = append(match, bson.M{"meta_data.region_code": filter.RegionCode})
+	}
+	var key interface{} = ""
+	if dimension != "" {
+		key = "$meta_data." + dimension
+	}
+	pageview := bson.M{"$cond": []interface{}{
+		bson.M{"$or": []bson.M{
+			{"$eq": []interface{}{"$event.type", metaEventType}},
+			{"$eq": []interface{}{"$event.data.tag", ScreenViewTag}},
+		}},
+		1,
+		0,
+	}}
+	submitted := bson.M{"$cond": []interface{}{
+		bson.M{"$eq": []interface{}{"$event.data.tag", FormSubmitTag}},
+		"$event.data.payload.form_id",
+		nil,
+	}}
+	totals := bson.M{
+		"_id":       "$key",
+		"sessions":  bson.M{"$sum": 1},
+		"pageviews": bson.M{"$sum": "$pageviews"},
+		"bounces":   bson.M{"$sum": bson.M{"$cond": []interface{}{bson.M{"$lte": []interface{}{"$pageviews", 1}}, 1, 0}}},
+	}
+	conversions := []interface{}{}
+	for i, formID := range forms {
+		field := "form_" + strconv.Itoa(i)
+		totals[field] = bson.M{"$sum": bson.M{"$cond": []interface{}{bson.M{"$in": []interface{}{formID, "$forms"}}, 1, 0}}}
+		conversions = append(conversions, "$"+field)
+	}
+	pipeline := []bson.M{
+		{"$match": bson.M{"$and": match}},
+		{"$group": bson.M{
+			"_id":       "$meta_data.id",
+			"key":       bson.M{"$first": key},
+			"pageviews": bson.M{"$sum": pageview},
+			"forms":     bson.M{"$addToSet": submitted},
+		}},
+		{"$group": totals},
+		{"$project": bson.M{"sessions": 1, "pageviews": 1, "bounces": 1, "conversions": conversions}},
+	}
+
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	cur, err := db.Aggregate(context.TODO(), sessionCollection, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	var listTotals []Totals
+	for cur.Next(context.TODO()) {
+		var row struct {
+			Key         *string `bson:"_id"`
+			Sessions    int64   `bson:"sessions"`
+			Pageviews   int64   `bson:"pageviews"`
+			Bounces     int64   `bson:"bounces"`
+			Conversions []int64 `bson:"conversions"`
+		}
+		if err := cur.Decode(&row); err != nil {
+			return nil, err
+		}
+		aTotals := Totals{Sessions: row.Sessions, Pageviews: row.Pageviews, Bounces: row.Bounces, Conversions: row.Conversions}
+		if row.Key != nil {
+			aTotals.Key = *row.Key
+		}
+		listTotals = append(listTotals, aTotals)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return listTotals, nil
+}
+
+// Totals base metrics of website by value of dimension
+func (instance *clickHouseRepository) Totals(userID, websiteID, dimension string, forms []string, filter BreakdownFilter) ([]Totals, error) {
+	params := instance.params(userID)
+	params["website"] = websiteID
+	params["from"] = clickhouse.TimeParam(filter.From)
+	params["to"] = clickhouse.TimeParam(filter.To)
+	params["tag"] = FormSubmitTag
+	key := "''"
+	if dimension != "" {
+		key = "argMin(" + dimension + ", time_report)"
+	}
+	sessions := "SELECT " + key + " AS key," +
+		" countIf(type = 4 OR JSONExtractString(data, 'tag') = '" + ScreenViewTag + "') AS pageviews," +
+		" groupUniqArrayIf(JSONExtractString(data, 'payload', 'form_id'), type = 5 AND JSONExtractString(data, 'tag') = {tag:String}) AS forms" +
+		" FROM " + db.ClickHouseEventTable +
+		" WHERE " + clickHouseReportFilter + " AND website_id = {website:String}" +
+		" AND time_report >= {from:DateTime64(3)} AND time_report < {to:DateTime64(3)}"
+	switch filter.Platform {
+	case "":
+	case PlatformWeb:
+		sessions += " AND platform IN ('web', '')"
+	default:
+		params["platform"] = filter.Platform
+		sessions += " AND platform = {platform:String}"
+	}
+	if filter.CountryCode != "" {
+		params["country_code"] = filter.CountryCode
+		sessions += " AND country_code = {country_code:String}"
+	}
+	if filter.RegionCode != "" {
+		params["region_code"] = filter.RegionCode
+		sessions += " AND region_code = {region_code:String}"
+	}
+	sessions += " GROUP BY id"
+	conversions := ""
+	for i, formID := range forms {
+		param := "form_" + strconv.Itoa(i)
+		params[param] = formID
+		if i > 0 {
+			conversions += ", "
+		}
+		conversions += "toInt64(countIf(has(forms, {" + param + ":String})))"
+	}
+	query := "SELECT key, count() AS sessions, sum(pageviews) AS pageviews, countIf(pageviews <= 1) AS bounces," +
+		" [" + conversions + "] AS conversions FROM (" + sessions + ") GROUP BY key"
+
+	var listTotals []Totals
+	err := configs.ClickHouse.Client.Query(query, params, func(line []byte) error {
+		var row Totals
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		listTotals = append(listTotals, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return listTotals, nil
+}
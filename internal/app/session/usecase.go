@@ -1,5 +1,13 @@
 package session
 
+import (
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/pkg/cursor"
+	"analytics-api/internal/pkg/eventtime"
+)
+
 // UseCase ...
 type UseCase interface {
 	GetAllSession(userID, websiteID string, listSessionID []string, session session) ([]session, error)
@@ -8,12 +16,37 @@ type UseCase interface {
 	GetSessionIDToday(userID, websiteID string, session session) ([]string, error)
 	GetSession(userID, sessionID string, session *session) error
 	GetCountSession(userID, sessionID string) (int64, error)
+	CountSessionSince(userID, websiteID string, since time.Time) (int64, error)
+	CountSessionsSince(userID string, websiteIDs []string, since []time.Time) (map[string][]int64, error)
+	Breakdown(userID, websiteID, dimension string, filter BreakdownFilter) ([]Bucket, error)
+	HeatTable(userID, websiteID string, filter BreakdownFilter, location *time.Location) ([]HeatCell, error)
+	Daily(userID, websiteID string, filter BreakdownFilter, location *time.Location) ([]Day, error)
+	Pages(userID, websiteID string, filter BreakdownFilter) ([]Page, error)
+	PageBreakdown(userID, websiteID, dimension string, filter BreakdownFilter) ([]PageBucket, error)
+	Engagement(userID, websiteID string, filter BreakdownFilter) ([]PageEngagement, error)
+	Visits(userID, websiteID string, filter BreakdownFilter) (*Visits, error)
+	Totals(userID, websiteID, dimension string, forms []string, filter BreakdownFilter) ([]Totals, error)
+	Referrals(userID, websiteID string, filter BreakdownFilter) (*Referrals, error)
+	Forms(userID, websiteID string, filter BreakdownFilter) ([]FormStats, error)
+	FormSubmissions(userID, websiteID, formID string, filter BreakdownFilter) (int64, error)
+	FormFunnel(userID, websiteID, formID string, filter BreakdownFilter) (*FormFunnel, error)
 	InsertSession(session session, events []event) error
 
 	GetEventByLimitSkip(userID, sessionID string, limit, skip int) ([]*event, error)
 
+	StreamSession(userID, websiteID string, listSessionID []string, fn func(session) error) error
+	StreamEvent(userID, sessionID string, fn func(*event) error) error
+	StreamRawEvent(userID, websiteID string, from, to time.Time, fn func(RawEvent) error) error
+
+	GetSessionPage(userID, websiteID string, today bool, after *cursor.Cursor, limit int) ([]session, *cursor.Cursor, error)
+	GetEventPage(userID, sessionID string, after *cursor.Cursor, limit int) ([]*event, *cursor.Cursor, error)
+
 	GetSessionTimestamp(sessionID string) (int64, error)
 	InsertSessionTimestamp(sessionID string, timeStart int64) error
+	SegmentSessionID(websiteID, anonymousID string) (string, error)
+
+	DeleteSessionBefore(before time.Time, keep []string) (int64, error)
+	ReplaceEvents(userID, websiteID string, corrections []Correction) ([]Replaced, error)
 }
 
 type useCase struct {
@@ -21,9 +54,9 @@ type useCase struct {
 }
 
 // NewUseCase ...
-func NewUseCase() UseCase {
+func NewUseCase(store *db.Store) UseCase {
 	return &useCase{
-		repo: NewRepository(),
+		repo: NewRepository(store),
 	}
 }
 
@@ -66,6 +99,8 @@ func (instance *useCase) GetSessionIDToday(userID, websiteID string, aSession se
 // InsertSession insert session
 func (instance *useCase) InsertSession(aSession session, events []event) error {
 	for _, event := range events {
+		// reports count events at the time they happened, not when they arrived
+		aSession.TimeReport = eventtime.Time(event.Timestamp)
 		err := instance.repo.InsertSession(aSession, event)
 		if err != nil {
 			return err
@@ -83,6 +118,133 @@ func (instance *useCase) GetCountSession(userID, sessionID string) (int64, error
 	return count, nil
 }
 
+// CountSessionSince count sessions of website since time
+func (instance *useCase) CountSessionSince(userID, websiteID string, since time.Time) (int64, error) {
+	count, err := instance.repo.CountSessionSince(userID, websiteID, since)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountSessionsSince count sessions of each of websiteIDs since each time of
+// since in one query, the counts of a website follow the order of since
+func (instance *useCase) CountSessionsSince(userID string, websiteIDs []string, since []time.Time) (map[string][]int64, error) {
+	if len(websiteIDs) == 0 || len(since) == 0 {
+		return map[string][]int64{}, nil
+	}
+	return instance.repo.CountSessionsSince(userID, websiteIDs, since)
+}
+
+// Breakdown count sessions of website by value of dimension
+func (instance *useCase) Breakdown(userID, websiteID, dimension string, filter BreakdownFilter) ([]Bucket, error) {
+	if !breakdownFields[dimension] {
+		return nil, ErrInvalidDimension
+	}
+	buckets, err := instance.repo.Breakdown(userID, websiteID, dimension, filter)
+	if err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// HeatTable sessions and pageviews of website by weekday and hour in location
+func (instance *useCase) HeatTable(userID, websiteID string, filter BreakdownFilter, location *time.Location) ([]HeatCell, error) {
+	cells, err := instance.repo.HeatTable(userID, websiteID, filter, location)
+	if err != nil {
+		return nil, err
+	}
+	return cells, nil
+}
+
+// Daily sessions and pageviews of website by day in location
+func (instance *useCase) Daily(userID, websiteID string, filter BreakdownFilter, location *time.Location) ([]Day, error) {
+	days, err := instance.repo.Daily(userID, websiteID, filter, location)
+	if err != nil {
+		return nil, err
+	}
+	return days, nil
+}
+
+// Pages sessions and pageviews of website by path
+func (instance *useCase) Pages(userID, websiteID string, filter BreakdownFilter) ([]Page, error) {
+	pages, err := instance.repo.Pages(userID, websiteID, filter)
+	if err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// PageBreakdown count pageviews of website by author or category of the pages
+func (instance *useCase) PageBreakdown(userID, websiteID, dimension string, filter BreakdownFilter) ([]PageBucket, error) {
+	if !pageMetaFields[dimension] {
+		return nil, ErrInvalidDimension
+	}
+	buckets, err := instance.repo.PageBreakdown(userID, websiteID, dimension, filter)
+	if err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// Engagement engaged time and reads of website by path
+func (instance *useCase) Engagement(userID, websiteID string, filter BreakdownFilter) ([]PageEngagement, error) {
+	pages, err := instance.repo.Engagement(userID, websiteID, filter)
+	if err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// Visits sessions, bounces and duration of website
+func (instance *useCase) Visits(userID, websiteID string, filter BreakdownFilter) (*Visits, error) {
+	aVisits, err := instance.repo.Visits(userID, websiteID, filter)
+	if err != nil {
+		return nil, err
+	}
+	return aVisits, nil
+}
+
+// Totals base metrics of website by value of dimension, all sessions in a
+// single bucket when dimension is empty. Conversions are counted for forms
+func (instance *useCase) Totals(userID, websiteID, dimension string, forms []string, filter BreakdownFilter) ([]Totals, error) {
+	if dimension != "" && !breakdownFields[dimension] {
+		return nil, ErrInvalidDimension
+	}
+	listTotals, err := instance.repo.Totals(userID, websiteID, dimension, forms, filter)
+	if err != nil {
+		return nil, err
+	}
+	return listTotals, nil
+}
+
+// Forms sessions starting, submitting and abandoning each form of website by page
+func (instance *useCase) Forms(userID, websiteID string, filter BreakdownFilter) ([]FormStats, error) {
+	forms, err := instance.repo.Forms(userID, websiteID, filter)
+	if err != nil {
+		return nil, err
+	}
+	return forms, nil
+}
+
+// FormSubmissions sessions of website submitting formID
+func (instance *useCase) FormSubmissions(userID, websiteID, formID string, filter BreakdownFilter) (int64, error) {
+	count, err := instance.repo.FormSubmissions(userID, websiteID, formID, filter)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// FormFunnel sessions of website going through the fields of formID
+func (instance *useCase) FormFunnel(userID, websiteID, formID string, filter BreakdownFilter) (*FormFunnel, error) {
+	aFunnel, err := instance.repo.FormFunnel(userID, websiteID, formID, filter)
+	if err != nil {
+		return nil, err
+	}
+	return aFunnel, nil
+}
+
 // GetEventByLimitSkip get limit event of session by session id
 func (instance *useCase) GetEventByLimitSkip(userID, sessionID string, limit, skip int) ([]*event, error) {
 	events, err := instance.repo.GetEventByLimitSkip(userID, sessionID, limit, skip)
@@ -92,6 +254,55 @@ func (instance *useCase) GetEventByLimitSkip(userID, sessionID string, limit, sk
 	return events, nil
 }
 
+// StreamSession call fn with each session of listSessionID
+func (instance *useCase) StreamSession(userID, websiteID string, listSessionID []string, fn func(session) error) error {
+	err := instance.repo.StreamSession(userID, websiteID, listSessionID, fn)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// StreamEvent call fn with each event of session
+func (instance *useCase) StreamEvent(userID, sessionID string, fn func(*event) error) error {
+	err := instance.repo.StreamEvent(userID, sessionID, fn)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// StreamRawEvent call fn with each event of website reported in [from, to)
+func (instance *useCase) StreamRawEvent(userID, websiteID string, from, to time.Time, fn func(RawEvent) error) error {
+	err := instance.repo.StreamRawEvent(userID, websiteID, from, to, fn)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetSessionPage get a page of sessions sorted by start time and the cursor of the next page
+func (instance *useCase) GetSessionPage(userID, websiteID string, today bool, after *cursor.Cursor, limit int) ([]session, *cursor.Cursor, error) {
+	listSessionID, next, err := instance.repo.GetSessionPage(userID, websiteID, today, after, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	listSession, err := instance.repo.GetAllSession(userID, websiteID, listSessionID, session{})
+	if err != nil {
+		return nil, nil, err
+	}
+	return listSession, next, nil
+}
+
+// GetEventPage get a page of events of session and the cursor of the next page
+func (instance *useCase) GetEventPage(userID, sessionID string, after *cursor.Cursor, limit int) ([]*event, *cursor.Cursor, error) {
+	events, next, err := instance.repo.GetEventPage(userID, sessionID, after, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	return events, next, nil
+}
+
 // GetSessionTimestamp get first timestamp of session by id
 func (instance *useCase) GetSessionTimestamp(sessionID string) (int64, error) {
 	timeStart, err := instance.repo.GetSessionTimestamp(sessionID)
@@ -109,3 +320,22 @@ func (instance *useCase) InsertSessionTimestamp(sessionID string, timeStart int6
 	}
 	return nil
 }
+
+// SegmentSessionID session of the Segment calls of anonymousID
+func (instance *useCase) SegmentSessionID(websiteID, anonymousID string) (string, error) {
+	sessionID, err := instance.repo.SegmentSessionID(websiteID, anonymousID)
+	if err != nil {
+		return "", err
+	}
+	return sessionID, nil
+}
+
+// DeleteSessionBefore prune session reported before time, but those of the
+// websites of keep
+func (instance *useCase) DeleteSessionBefore(before time.Time, keep []string) (int64, error) {
+	count, err := instance.repo.DeleteSessionBefore(before, keep)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
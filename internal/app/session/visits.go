@@ -0,0 +1,115 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/clickhouse"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Visits sessions of a website with those that bounced, viewing at most one
+// page, and the time they lasted
+type Visits struct {
+	Sessions int64 `json:"sessions"`
+	Bounces  int64 `json:"bounces"`
+	// DurationSeconds sum of the durations of the sessions
+	DurationSeconds int64 `json:"duration_seconds"`
+}
+
+// Visits sessions, bounces and duration of website. The duration of a
+// session is the longest stored with its events, in HH:MM:SS
+func (instance *repository) Visits(userID, websiteID string, filter BreakdownFilter) (*Visits, error) {
+	match := []bson.M{
+		{"meta_data.user_id": userID},
+		{"meta_data.website_id": websiteID},
+		onTime,
+		{"time_report": bson.M{"$gte": filter.From, "$lt": filter.To}},
+	}
+	pageview := bson.M{"$cond": []interface{}{
+		bson.M{"$or": []bson.M{
+			{"$eq": []interface{}{"$event.type", metaEventType}},
+			{"$eq": []interface{}{"$event.data.tag", ScreenViewTag}},
+		}},
+		1,
+		0,
+	}}
+	part := func(i int) bson.M {
+		return bson.M{"$toInt": bson.M{"$arrayElemAt": []interface{}{"$$parts", i}}}
+	}
+	seconds := bson.M{"$let": bson.M{
+		"vars": bson.M{"parts": bson.M{"$split": []interface{}{"$duration", ":"}}},
+		"in": bson.M{"$add": []interface{}{
+			bson.M{"$multiply": []interface{}{part(0), 3600}},
+			bson.M{"$multiply": []interface{}{part(1), 60}},
+			part(2),
+		}},
+	}}
+	pipeline := []bson.M{
+		{"$match": bson.M{"$and": match}},
+		{"$group": bson.M{
+			"_id":       "$meta_data.id",
+			"pageviews": bson.M{"$sum": pageview},
+			"duration":  bson.M{"$max": "$duration"},
+		}},
+		{"$group": bson.M{
+			"_id":              nil,
+			"sessions":         bson.M{"$sum": 1},
+			"bounces":          bson.M{"$sum": bson.M{"$cond": []interface{}{bson.M{"$lte": []interface{}{"$pageviews", 1}}, 1, 0}}},
+			"duration_seconds": bson.M{"$sum": seconds},
+		}},
+	}
+
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	cur, err := db.Aggregate(context.TODO(), sessionCollection, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	aVisits := &Visits{}
+	if cur.Next(context.TODO()) {
+		var row struct {
+			Sessions        int64 `bson:"sessions"`
+			Bounces         int64 `bson:"bounces"`
+			DurationSeconds int64 `bson:"duration_seconds"`
+		}
+		if err := cur.Decode(&row); err != nil {
+			return nil, err
+		}
+		aVisits = &Visits{Sessions: row.Sessions, Bounces: row.Bounces, DurationSeconds: row.DurationSeconds}
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return aVisits, nil
+}
+
+// Visits sessions, bounces and duration of website
+func (instance *clickHouseRepository) Visits(userID, websiteID string, filter BreakdownFilter) (*Visits, error) {
+	params := instance.params(userID)
+	params["website"] = websiteID
+	params["from"] = clickhouse.TimeParam(filter.From)
+	params["to"] = clickhouse.TimeParam(filter.To)
+	sessions := "SELECT countIf(type = 4 OR JSONExtractString(data, 'tag') = '" + ScreenViewTag + "') AS pageviews," +
+		" max(duration) AS duration FROM " + db.ClickHouseEventTable +
+		" WHERE " + clickHouseReportFilter + " AND website_id = {website:String}" +
+		" AND time_report >= {from:DateTime64(3)} AND time_report < {to:DateTime64(3)}" +
+		" GROUP BY id"
+	query := "SELECT count() AS sessions, countIf(pageviews <= 1) AS bounces," +
+		" sum(toInt64OrZero(splitByChar(':', duration)[1]) * 3600 + toInt64OrZero(splitByChar(':', duration)[2]) * 60" +
+		" + toInt64OrZero(splitByChar(':', duration)[3])) AS duration_seconds" +
+		" FROM (" + sessions + ")"
+
+	aVisits := &Visits{}
+	err := configs.ClickHouse.Client.Query(query, params, func(line []byte) error {
+		return json.Unmarshal(line, aVisits)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return aVisits, nil
+}
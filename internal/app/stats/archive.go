@@ -0,0 +1,74 @@
+package stats
+
+import (
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/archive"
+	"analytics-api/internal/app/session"
+)
+
+// hotStart first day fully kept by the hot store, events before it are read
+// from the archive
+func hotStart(now time.Time) time.Time {
+	expired := now.UTC().AddDate(0, 0, -db.RetentionDays)
+	return time.Date(expired.Year(), expired.Month(), expired.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+// split filter at the start of the hot store, ok when a part of its range
+// is before it
+func split(filter session.BreakdownFilter) (session.BreakdownFilter, session.BreakdownFilter, bool) {
+	start := hotStart(time.Now())
+	if !filter.From.Before(start) {
+		return filter, filter, false
+	}
+	hot, archived := filter, filter
+	if filter.To.After(start) {
+		hot.From = start
+		archived.To = start
+	} else {
+		hot.From = filter.To
+	}
+	return hot, archived, true
+}
+
+// scan of the archived events of website in the range of filter, the
+// coverage is set once the scan ran
+func (instance *useCase) scan(userID, websiteID string, filter session.BreakdownFilter, aCoverage **archive.Coverage) session.ArchiveScan {
+	return func(fn func(session.RawEvent) error) error {
+		scanned, err := instance.archiveUseCase.Scan(userID, websiteID, filter.From, filter.To, fn)
+		*aCoverage = scanned
+		return err
+	}
+}
+
+// breakdown buckets of dimension in the hot store and the archive, the
+// coverage is nil when no day of filter is past the retention
+func (instance *useCase) breakdown(userID, websiteID, dimension string, filter session.BreakdownFilter) ([]session.Bucket, *archive.Coverage, error) {
+	hot, archived, ok := split(filter)
+	buckets, err := instance.sessionUseCase.Breakdown(userID, websiteID, dimension, hot)
+	if err != nil || !ok {
+		return buckets, nil, err
+	}
+	var aCoverage *archive.Coverage
+	archivedBuckets, err := session.ArchivedBreakdown(dimension, archived, instance.scan(userID, websiteID, archived, &aCoverage))
+	if err != nil {
+		return nil, nil, err
+	}
+	return session.MergeBuckets(dimension, buckets, archivedBuckets), aCoverage, nil
+}
+
+// pages of the hot store and the archive
+func (instance *useCase) pages(userID, websiteID string, filter session.BreakdownFilter) ([]session.Page, *archive.Coverage, error) {
+	hot, archived, ok := split(filter)
+	listPage, err := instance.sessionUseCase.Pages(userID, websiteID, hot)
+	if err != nil || !ok {
+		return listPage, nil, err
+	}
+	var aCoverage *archive.Coverage
+	archivedPages, err := session.ArchivedPages(instance.scan(userID, websiteID, archived, &aCoverage), filter.Canonical)
+	if err != nil {
+		return nil, nil, err
+	}
+	return session.MergePages(listPage, archivedPages), aCoverage, nil
+}
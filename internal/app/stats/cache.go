@@ -0,0 +1,164 @@
+package stats
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/internal/pkg/keystore"
+	"analytics-api/internal/pkg/querykey"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// cacheTTL how long a report is served from the cache, new sessions show up
+// in reports after at most this long
+const cacheTTL = time.Minute
+
+// rangeQuery parameters of every report, from and to are filled in by cached
+var rangeQuery = querykey.Spec{
+	"from": {},
+	"to":   {},
+}
+
+// withRange rangeQuery and the parameters of spec
+func withRange(spec querykey.Spec) querykey.Spec {
+	merged := querykey.Spec{}
+	for name, param := range rangeQuery {
+		merged[name] = param
+	}
+	for name, param := range spec {
+		merged[name] = param
+	}
+	return merged
+}
+
+var (
+	breakdownQuery = withRange(querykey.Spec{
+		"by":       {Aliases: []string{"dimension"}, Default: "platform", Fold: strings.ToLower},
+		"platform": {Fold: strings.ToLower},
+		"country":  {Aliases: []string{"country_code"}, Fold: strings.ToUpper},
+		"region":   {Aliases: []string{"region_code"}, Fold: strings.ToUpper},
+		"metric":   {},
+	})
+	mapQuery = withRange(querykey.Spec{
+		"level": {Default: "country", Fold: strings.ToLower},
+	})
+	heatTableQuery = withRange(querykey.Spec{
+		"platform": {Fold: strings.ToLower},
+	})
+	pagesQuery = withRange(querykey.Spec{
+		"group": {Aliases: []string{"content_group"}},
+	})
+	pageBreakdownQuery = withRange(querykey.Spec{
+		"by": {Aliases: []string{"dimension"}, Default: "author", Fold: strings.ToLower},
+	})
+)
+
+// cacheWriter hold back the response so it can be cached and tagged once
+// the handler is done
+type cacheWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (instance *cacheWriter) Write(data []byte) (int, error) {
+	return instance.body.Write(data)
+}
+
+func (instance *cacheWriter) WriteString(s string) (int, error) {
+	return instance.body.Write([]byte(s))
+}
+
+// etag strong validator of body
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// cached serve reports from the key store with an ETag. The query is rewritten to
+// its canonical form under spec before the report handler reads it, so
+// equivalent queries share a key: the user, the route and its params, the
+// timezone of the website and the canonical query with from and to resolved
+// to dates. Only successful reports are cached, and clients revalidate them
+// with If-None-Match
+func (instance *httpDelivery) cached(spec querykey.Spec) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		values := c.Request.URL.Query()
+		// dates are resolved so "the last 7 days" is a key of its own each day
+		if from, to, err := rangeOf(values.Get("from"), values.Get("to")); err == nil {
+			values.Set("from", from.Format(dateLayout))
+			values.Set("to", to.AddDate(0, 0, -1).Format(dateLayout))
+		}
+		c.Request.URL.RawQuery = querykey.Canonical(values, spec)
+
+		// the report handler answers requests that cannot be keyed
+		tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+		if err != nil {
+			return
+		}
+		userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+		if err != nil {
+			return
+		}
+		location, err := instance.websiteUseCase.GetLocation(userID, c.Param("website_id"))
+		if err != nil {
+			return
+		}
+
+		parts := []string{userID, c.FullPath()}
+		for _, param := range c.Params {
+			parts = append(parts, param.Key+"="+param.Value)
+		}
+		parts = append(parts, location.String(), c.Request.URL.RawQuery)
+		sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+		key := instance.store.Key("stats:" + hex.EncodeToString(sum[:]))
+
+		c.Header("Cache-Control", "private, no-cache")
+		c.Header("Vary", "Authorization")
+		cached, err := configs.KeyStore.Client.Get(key)
+		if err == nil {
+			c.Header("X-Cache", "HIT")
+			respond(c, []byte(cached))
+			c.Abort()
+			return
+		}
+		if err != keystore.ErrNotFound {
+			logrus.Error("get cached report error ", err)
+		}
+
+		writer := &cacheWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		body := writer.body.Bytes()
+		if c.Writer.Status() != http.StatusOK {
+			c.Writer.Write(body)
+			return
+		}
+		err = configs.KeyStore.Client.Set(key, string(body), cacheTTL)
+		if err != nil {
+			logrus.Error("cache report error ", err)
+		}
+		c.Header("X-Cache", "MISS")
+		respond(c, body)
+	}
+}
+
+// respond write report body, or 304 when the client holds it already
+func respond(c *gin.Context, body []byte) {
+	tag := etag(body)
+	c.Header("ETag", tag)
+	if strings.Contains(c.GetHeader("If-None-Match"), tag) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	c.Data(http.StatusOK, gin.MIMEJSON+"; charset=utf-8", body)
+}
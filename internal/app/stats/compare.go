@@ -0,0 +1,189 @@
+package stats
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"analytics-api/internal/app/archive"
+	"analytics-api/internal/app/session"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MaxCompareWebsites websites compared in one call, each costs a report
+const MaxCompareWebsites = 10
+
+// ErrInvalidMetric ...
+var ErrInvalidMetric = errors.New("metric must be visitors, sessions or pageviews")
+
+// ErrCompareWebsites ...
+var ErrCompareWebsites = errors.New("website_ids must hold 1 to 10 website ids, or tag name 1 to 10 websites")
+
+// compareMetrics value of a day for each metric. The tracker keeps no id
+// across sessions, so visitors are counted as sessions
+var compareMetrics = map[string]func(session.Day) int64{
+	"visitors":  func(aDay session.Day) int64 { return aDay.Sessions },
+	"sessions":  func(aDay session.Day) int64 { return aDay.Sessions },
+	"pageviews": func(aDay session.Day) int64 { return aDay.Pageviews },
+}
+
+// comparison metric of several websites on the same days
+type comparison struct {
+	Metric   string            `json:"metric"`
+	From     string            `json:"from"`
+	To       string            `json:"to"`
+	Days     []string          `json:"days"`
+	Websites []comparedWebsite `json:"websites"`
+}
+
+// comparedWebsite value of the metric of a website for each day of the
+// comparison, days are in the timezone of the website
+type comparedWebsite struct {
+	WebsiteID string  `json:"website_id"`
+	URL       string  `json:"url"`
+	Timezone  string  `json:"timezone"`
+	Series    []int64 `json:"series"`
+	// Total sum of the series, a session across midnight counts in both days
+	Total   int64             `json:"total"`
+	Archive *archive.Coverage `json:"archive,omitempty"`
+}
+
+// Compare metric of websites of user by day, in the order of websiteIDs.
+// mongo.ErrNoDocuments when user does not own one of them
+func (instance *useCase) Compare(userID string, websiteIDs []string, metric string, filter session.BreakdownFilter) (*comparison, error) {
+	value, ok := compareMetrics[metric]
+	if !ok {
+		return nil, ErrInvalidMetric
+	}
+	var unique []string
+	for _, websiteID := range websiteIDs {
+		if !slices.Contains(unique, websiteID) {
+			unique = append(unique, websiteID)
+		}
+	}
+	websiteIDs = unique
+	if len(websiteIDs) == 0 || len(websiteIDs) > MaxCompareWebsites {
+		return nil, ErrCompareWebsites
+	}
+
+	aComparison := &comparison{
+		Metric:   metric,
+		From:     filter.From.Format(dateLayout),
+		To:       filter.To.AddDate(0, 0, -1).Format(dateLayout),
+		Days:     []string{},
+		Websites: []comparedWebsite{},
+	}
+	index := map[string]int{}
+	for day := filter.From; day.Before(filter.To); day = day.AddDate(0, 0, 1) {
+		index[day.Format(dateLayout)] = len(aComparison.Days)
+		aComparison.Days = append(aComparison.Days, day.Format(dateLayout))
+	}
+
+	for _, websiteID := range websiteIDs {
+		url, err := instance.websiteUseCase.GetURL(userID, websiteID)
+		if err != nil {
+			return nil, err
+		}
+		location, err := instance.websiteUseCase.GetLocation(userID, websiteID)
+		if err != nil {
+			return nil, err
+		}
+		local := filter
+		local.From = time.Date(filter.From.Year(), filter.From.Month(), filter.From.Day(), 0, 0, 0, 0, location)
+		local.To = time.Date(filter.To.Year(), filter.To.Month(), filter.To.Day(), 0, 0, 0, 0, location)
+
+		hot, archived, ok := split(local)
+		days, err := instance.sessionUseCase.Daily(userID, websiteID, hot, location)
+		if err != nil {
+			return nil, err
+		}
+		var aCoverage *archive.Coverage
+		if ok {
+			archivedDays, err := session.ArchivedDaily(location, instance.scan(userID, websiteID, archived, &aCoverage))
+			if err != nil {
+				return nil, err
+			}
+			days = append(days, archivedDays...)
+		}
+
+		aWebsite := comparedWebsite{
+			WebsiteID: websiteID,
+			URL:       url,
+			Timezone:  location.String(),
+			Series:    make([]int64, len(aComparison.Days)),
+			Archive:   aCoverage,
+		}
+		for _, aDay := range days {
+			i, ok := index[aDay.Day]
+			if !ok {
+				continue
+			}
+			aWebsite.Series[i] += value(aDay)
+			aWebsite.Total += value(aDay)
+		}
+		aComparison.Websites = append(aComparison.Websites, aWebsite)
+	}
+	return aComparison, nil
+}
+
+// Compare a metric of several websites of the user by day, so they can be
+// benchmarked side by side
+func (instance *httpDelivery) Compare(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	from, to, err := parseRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var websiteIDs []string
+	for _, websiteID := range strings.Split(c.Query("website_ids"), ",") {
+		if websiteID = strings.TrimSpace(websiteID); websiteID != "" {
+			websiteIDs = append(websiteIDs, websiteID)
+		}
+	}
+	// ?tag= compares the websites of a tag, when no id is given
+	if tag := c.Query("tag"); tag != "" && len(websiteIDs) == 0 {
+		websites, err := instance.websiteUseCase.GetTaggedWebsite(userID, tag)
+		if err != nil {
+			logrus.Error(c, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "compare websites failed"})
+			return
+		}
+		for _, aWebsite := range *websites {
+			websiteIDs = append(websiteIDs, aWebsite.ID)
+		}
+	}
+
+	aComparison, err := instance.statsUseCase.Compare(userID, websiteIDs, c.DefaultQuery("metric", "visitors"), session.BreakdownFilter{From: from, To: to})
+	switch err {
+	case nil:
+	case ErrInvalidMetric, ErrCompareWebsites:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case mongo.ErrNoDocuments:
+		c.JSON(http.StatusNotFound, gin.H{"error": "this website not exists"})
+		return
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "compare websites failed"})
+		return
+	}
+	c.JSON(http.StatusOK, aComparison)
+}
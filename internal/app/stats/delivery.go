@@ -0,0 +1,44 @@
+package stats
+
+import (
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/app/website"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPDelivery reports of the stats API
+type HTTPDelivery interface {
+	// This function is required for every HTTPDelivery
+	InitRoutes(r *gin.RouterGroup)
+
+	// Other functions to handle HTTP requests
+	Breakdown(c *gin.Context)
+	GetMap(c *gin.Context)
+	GetHeatTable(c *gin.Context)
+	GetPages(c *gin.Context)
+	GetContentGroups(c *gin.Context)
+	PageBreakdown(c *gin.Context)
+	GetForms(c *gin.Context)
+	GetFormFunnel(c *gin.Context)
+	GetGoals(c *gin.Context)
+	Compare(c *gin.Context)
+	GetSharedBreakdown(c *gin.Context)
+	GetSharedPages(c *gin.Context)
+	CreateEmbedToken(c *gin.Context)
+	GetEmbedBreakdown(c *gin.Context)
+	GetEmbedMap(c *gin.Context)
+	GetEmbedHeatTable(c *gin.Context)
+	GetEmbedPages(c *gin.Context)
+}
+
+// NewHTTPDelivery ...
+func NewHTTPDelivery(store *db.Store) HTTPDelivery {
+	return &httpDelivery{
+		store:          store,
+		statsUseCase:   NewUseCase(store),
+		authUsecase:    auth.NewUseCase(store),
+		websiteUseCase: website.NewUseCase(store),
+	}
+}
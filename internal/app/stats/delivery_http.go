@@ -0,0 +1,417 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/app/metric"
+	"analytics-api/internal/app/session"
+	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/middleware"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// errInvalidRange ...
+var errInvalidRange = errors.New("from and to must be dates like 2024-01-31, from before to")
+
+// errRangeTooLong ...
+var errRangeTooLong = fmt.Errorf("from and to must be at most %d days apart", maxRangeDays)
+
+// maxRangeDays days a report covers at most, the days before the hot store are
+// read from the archive within the request
+const maxRangeDays = 366
+
+type httpDelivery struct {
+	store          *db.Store
+	statsUseCase   UseCase
+	authUsecase    auth.UseCase
+	websiteUseCase website.UseCase
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	statsRoutes := r.Group("stats")
+	{
+		statsRoutes.GET("/:website_id/breakdown", middleware.JWTMiddleware(), instance.cached(breakdownQuery), instance.Breakdown)
+		statsRoutes.GET("/:website_id/map", middleware.JWTMiddleware(), instance.cached(mapQuery), instance.GetMap)
+		statsRoutes.GET("/:website_id/heat-table", middleware.JWTMiddleware(), instance.cached(heatTableQuery), instance.GetHeatTable)
+		statsRoutes.GET("/:website_id/pages", middleware.JWTMiddleware(), instance.cached(pagesQuery), instance.GetPages)
+		statsRoutes.GET("/:website_id/pages/breakdown", middleware.JWTMiddleware(), instance.cached(pageBreakdownQuery), instance.PageBreakdown)
+		statsRoutes.GET("/:website_id/content-groups", middleware.JWTMiddleware(), instance.cached(rangeQuery), instance.GetContentGroups)
+		statsRoutes.GET("/:website_id/forms", middleware.JWTMiddleware(), instance.cached(rangeQuery), instance.GetForms)
+		statsRoutes.GET("/:website_id/forms/:form_id", middleware.JWTMiddleware(), instance.cached(rangeQuery), instance.GetFormFunnel)
+		statsRoutes.GET("/:website_id/goals", middleware.JWTMiddleware(), instance.cached(rangeQuery), instance.GetGoals)
+		statsRoutes.GET("/compare", middleware.JWTMiddleware(), instance.Compare)
+	}
+
+	// public links of shared websites, the token stands in for the login
+	shareRoutes := r.Group("share")
+	{
+		shareRoutes.GET("/:token/breakdown", instance.GetSharedBreakdown)
+		shareRoutes.GET("/:token/pages", instance.GetSharedPages)
+	}
+
+	// dashboards embedded in the product of a customer, the embed token
+	// reads one website from the origins it names
+	embedRoutes := r.Group("embed")
+	{
+		embedRoutes.POST("/token", middleware.JWTMiddleware(), instance.CreateEmbedToken)
+		embedRoutes.GET("/breakdown", instance.embedded(), instance.GetEmbedBreakdown)
+		embedRoutes.GET("/map", instance.embedded(), instance.GetEmbedMap)
+		embedRoutes.GET("/heat-table", instance.embedded(), instance.GetEmbedHeatTable)
+		embedRoutes.GET("/pages", instance.embedded(), instance.GetEmbedPages)
+	}
+}
+
+// Breakdown sessions by platform, os, device, app version... within a date range,
+// optionally within a country or region to drill down to cities
+func (instance *httpDelivery) Breakdown(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	from, to, err := parseRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	dimension := c.DefaultQuery("by", "platform")
+	filter := session.BreakdownFilter{
+		From:        from,
+		To:          to,
+		Platform:    c.Query("platform"),
+		CountryCode: c.Query("country"),
+		RegionCode:  c.Query("region"),
+	}
+
+	var aBreakdown *breakdown
+	// ?metric= adds the value of a calculated metric of the website to each bucket
+	if key := c.Query("metric"); key != "" {
+		aBreakdown, err = instance.statsUseCase.MetricBreakdown(userID, c.Param("website_id"), key, dimension, filter)
+	} else {
+		aBreakdown, err = instance.statsUseCase.Breakdown(userID, c.Param("website_id"), dimension, filter)
+	}
+	if err == session.ErrInvalidDimension {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err == metric.ErrMetricNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get breakdown failed"})
+		return
+	}
+	c.JSON(http.StatusOK, aBreakdown)
+}
+
+// GetMap sessions by country or region with deltas against the previous period
+func (instance *httpDelivery) GetMap(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	from, to, err := parseRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	aMap, err := instance.statsUseCase.GetMap(userID, c.Param("website_id"), c.DefaultQuery("level", "country"), from, to)
+	if err == ErrInvalidLevel {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get map failed"})
+		return
+	}
+	c.JSON(http.StatusOK, aMap)
+}
+
+// GetHeatTable sessions and pageviews by weekday and hour in the timezone of the
+// website, to find when the audience is around
+func (instance *httpDelivery) GetHeatTable(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	from, to, err := parseRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter := session.BreakdownFilter{
+		From:     from,
+		To:       to,
+		Platform: c.Query("platform"),
+	}
+
+	aHeatTable, err := instance.statsUseCase.GetHeatTable(userID, c.Param("website_id"), filter)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "this website not exists"})
+		return
+	}
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get heat table failed"})
+		return
+	}
+	c.JSON(http.StatusOK, aHeatTable)
+}
+
+// GetPages sessions and pageviews by path, optionally of one content group
+func (instance *httpDelivery) GetPages(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	from, to, err := parseRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	aPages, err := instance.statsUseCase.GetPages(userID, c.Param("website_id"), c.Query("group"), session.BreakdownFilter{From: from, To: to})
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "this website not exists"})
+		return
+	}
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get pages failed"})
+		return
+	}
+	c.JSON(http.StatusOK, aPages)
+}
+
+// PageBreakdown pageviews by author or category of the pages
+func (instance *httpDelivery) PageBreakdown(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	from, to, err := parseRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	aBreakdown, err := instance.statsUseCase.PageBreakdown(userID, c.Param("website_id"), c.DefaultQuery("by", "author"), session.BreakdownFilter{From: from, To: to})
+	if err == session.ErrInvalidDimension {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get page breakdown failed"})
+		return
+	}
+	c.JSON(http.StatusOK, aBreakdown)
+}
+
+// GetContentGroups pageviews by content group
+func (instance *httpDelivery) GetContentGroups(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	from, to, err := parseRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	aContentGroups, err := instance.statsUseCase.GetContentGroups(userID, c.Param("website_id"), session.BreakdownFilter{From: from, To: to})
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "this website not exists"})
+		return
+	}
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get content groups failed"})
+		return
+	}
+	c.JSON(http.StatusOK, aContentGroups)
+}
+
+// GetForms abandonment of each form by page
+func (instance *httpDelivery) GetForms(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	from, to, err := parseRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	aForms, err := instance.statsUseCase.GetForms(userID, c.Param("website_id"), session.BreakdownFilter{From: from, To: to})
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get forms failed"})
+		return
+	}
+	c.JSON(http.StatusOK, aForms)
+}
+
+// GetFormFunnel field by field drop-off of a form
+func (instance *httpDelivery) GetFormFunnel(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	from, to, err := parseRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	aFormFunnel, err := instance.statsUseCase.GetFormFunnel(userID, c.Param("website_id"), c.Param("form_id"), session.BreakdownFilter{From: from, To: to})
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get form funnel failed"})
+		return
+	}
+	c.JSON(http.StatusOK, aFormFunnel)
+}
+
+// GetGoals conversions of each goal
+func (instance *httpDelivery) GetGoals(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	from, to, err := parseRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	aGoals, err := instance.statsUseCase.GetGoals(userID, c.Param("website_id"), session.BreakdownFilter{From: from, To: to})
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get goals failed"})
+		return
+	}
+	c.JSON(http.StatusOK, aGoals)
+}
+
+// parseRange read the from and to dates of the query, both included. The last
+// 7 days are reported by default
+func parseRange(c *gin.Context) (time.Time, time.Time, error) {
+	return rangeOf(c.Query("from"), c.Query("to"))
+}
+
+// rangeOf dates from and to, either may be empty, as the start of from and
+// the end of to
+func rangeOf(fromValue, toValue string) (time.Time, time.Time, error) {
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -7)
+
+	var err error
+	if toValue != "" {
+		if to, err = time.Parse(dateLayout, toValue); err != nil {
+			return from, to, errInvalidRange
+		}
+		to = to.AddDate(0, 0, 1)
+		from = to.AddDate(0, 0, -7)
+	}
+	if fromValue != "" {
+		if from, err = time.Parse(dateLayout, fromValue); err != nil {
+			return from, to, errInvalidRange
+		}
+	}
+	if !from.Before(to) {
+		return from, to, errInvalidRange
+	}
+	if to.Sub(from) > maxRangeDays*24*time.Hour {
+		return from, to, errRangeTooLong
+	}
+	return from, to, nil
+}
@@ -0,0 +1,39 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRangeOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		wantFrom time.Time
+		wantTo   time.Time
+		wantErr  error
+	}{
+		{name: "should include both days", from: "2024-01-01", to: "2024-01-31", wantFrom: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), wantTo: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "should report the 7 days up to to", to: "2024-01-31", wantFrom: time.Date(2024, 1, 25, 0, 0, 0, 0, time.UTC), wantTo: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "should accept a range of the longest length", from: "2024-01-01", to: "2024-12-31", wantFrom: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), wantTo: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "should reject a longer range", from: "2023-01-01", to: "2024-01-02", wantErr: errRangeTooLong},
+		{name: "should reject a range of years", from: "2000-01-01", to: "2024-01-01", wantErr: errRangeTooLong},
+		{name: "should reject from after to", from: "2024-02-01", to: "2024-01-01", wantErr: errInvalidRange},
+		{name: "should reject an invalid date", from: "2024-1-1", to: "2024-01-31", wantErr: errInvalidRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := rangeOf(tt.from, tt.to)
+			if err != tt.wantErr {
+				t.Fatalf("rangeOf() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !from.Equal(tt.wantFrom) || !to.Equal(tt.wantTo) {
+				t.Errorf("rangeOf() = %v, %v, want %v, %v", from, to, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
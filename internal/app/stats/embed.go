@@ -0,0 +1,227 @@
+package stats
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"analytics-api/internal/app/session"
+	req "analytics-api/internal/pkg/request"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// defaultEmbedTTL lifetime of an embed token asked without ttl
+const defaultEmbedTTL = 15 * time.Minute
+
+// embedKey context key of the *security.EmbedDetails of an embedded request
+const embedKey = "embed"
+
+// CreateEmbedToken token reading the stats of websiteID of userID from pages
+// of origins for ttl, mongo.ErrNoDocuments when userID has no such website
+func (instance *useCase) CreateEmbedToken(userID, tenantID, websiteID string, origins []string, ttl time.Duration) (*embedToken, error) {
+	count, err := instance.websiteUseCase.FindWebsiteByID(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	details := &security.EmbedDetails{
+		UserID:    userID,
+		TenantID:  tenantID,
+		WebsiteID: websiteID,
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+	}
+	for _, origin := range origins {
+		anOrigin, err := security.NormalizeOrigin(origin)
+		if err != nil {
+			return nil, err
+		}
+		details.Origins = append(details.Origins, anOrigin)
+	}
+	token, err := security.CreateEmbedToken(details)
+	if err != nil {
+		return nil, err
+	}
+	return &embedToken{
+		Token:     token,
+		WebsiteID: websiteID,
+		Origins:   details.Origins,
+		ExpiresAt: details.Expires().Format("2006-01-02, 15:04:05"),
+		ExpiresIn: int(ttl.Seconds()),
+	}, nil
+}
+
+// CreateEmbedToken short lived token for an iframe of the customer product
+// showing the stats of one website, read only and from the origins given
+func (instance *httpDelivery) CreateEmbedToken(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	request, err := req.BindAndValidate[RequestEmbedToken](c)
+	if err != nil {
+		req.BadRequest(c, "invalid embed token", err)
+		return
+	}
+	ttl := defaultEmbedTTL
+	if request.TTL > 0 {
+		ttl = time.Duration(request.TTL) * time.Second
+	}
+
+	anEmbedToken, err := instance.statsUseCase.CreateEmbedToken(userID, instance.store.TenantID, request.WebsiteID, request.Origins, ttl)
+	switch err {
+	case nil:
+		c.JSON(http.StatusCreated, anEmbedToken)
+	case mongo.ErrNoDocuments:
+		c.JSON(http.StatusNotFound, gin.H{"error": "this website not exists"})
+	case security.ErrInvalidOrigin:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create embed token failed"})
+	}
+}
+
+// embedded check the embed token of the request and the page calling it,
+// then let that page read the answer and frame it. Tokens of a website
+// deleted or transferred since stop working
+func (instance *httpDelivery) embedded() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		details, err := security.VerifyEmbedToken(security.ExtractEmbedToken(c.Request))
+		if err != nil || details.TenantID != instance.store.TenantID {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": security.ErrInvalidEmbedToken.Error()})
+			return
+		}
+		origin, ok := details.Allows(c.Request)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "origin not allowed by the embed token"})
+			return
+		}
+		count, err := instance.websiteUseCase.FindWebsiteByID(details.UserID, details.WebsiteID)
+		if err != nil {
+			logrus.Error(c, err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "get website failed"})
+			return
+		}
+		if count == 0 {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "this website not exists"})
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Vary", "Origin")
+		c.Header("Content-Security-Policy", "frame-ancestors "+strings.Join(details.Origins, " "))
+		c.Header("Cache-Control", "no-store")
+		c.Set(embedKey, details)
+		c.Next()
+	}
+}
+
+// embedDetails details set by embedded
+func embedDetails(c *gin.Context) *security.EmbedDetails {
+	return c.MustGet(embedKey).(*security.EmbedDetails)
+}
+
+// GetEmbedBreakdown Breakdown of the website of the embed token
+func (instance *httpDelivery) GetEmbedBreakdown(c *gin.Context) {
+	details := embedDetails(c)
+	from, to, err := parseRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter := session.BreakdownFilter{
+		From:        from,
+		To:          to,
+		Platform:    c.Query("platform"),
+		CountryCode: c.Query("country"),
+		RegionCode:  c.Query("region"),
+	}
+
+	aBreakdown, err := instance.statsUseCase.Breakdown(details.UserID, details.WebsiteID, c.DefaultQuery("by", "platform"), filter)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, aBreakdown)
+	case session.ErrInvalidDimension:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get breakdown failed"})
+	}
+}
+
+// GetEmbedMap GetMap of the website of the embed token
+func (instance *httpDelivery) GetEmbedMap(c *gin.Context) {
+	details := embedDetails(c)
+	from, to, err := parseRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	aMap, err := instance.statsUseCase.GetMap(details.UserID, details.WebsiteID, c.DefaultQuery("level", "country"), from, to)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, aMap)
+	case ErrInvalidLevel:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get map failed"})
+	}
+}
+
+// GetEmbedHeatTable GetHeatTable of the website of the embed token
+func (instance *httpDelivery) GetEmbedHeatTable(c *gin.Context) {
+	details := embedDetails(c)
+	from, to, err := parseRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter := session.BreakdownFilter{
+		From:     from,
+		To:       to,
+		Platform: c.Query("platform"),
+	}
+
+	aHeatTable, err := instance.statsUseCase.GetHeatTable(details.UserID, details.WebsiteID, filter)
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get heat table failed"})
+		return
+	}
+	c.JSON(http.StatusOK, aHeatTable)
+}
+
+// GetEmbedPages GetPages of the website of the embed token
+func (instance *httpDelivery) GetEmbedPages(c *gin.Context) {
+	details := embedDetails(c)
+	from, to, err := parseRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	aPages, err := instance.statsUseCase.GetPages(details.UserID, details.WebsiteID, c.Query("group"), session.BreakdownFilter{From: from, To: to})
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get pages failed"})
+		return
+	}
+	c.JSON(http.StatusOK, aPages)
+}
@@ -0,0 +1,199 @@
+package stats
+
+import (
+	"analytics-api/internal/app/archive"
+	"analytics-api/internal/app/session"
+	"analytics-api/internal/app/website"
+)
+
+const dateLayout = "2006-01-02"
+
+// breakdown sessions by value of a dimension
+type breakdown struct {
+	By      string           `json:"by"`
+	From    string           `json:"from"`
+	To      string           `json:"to"`
+	Buckets []session.Bucket `json:"buckets"`
+	// Suppressed sessions of cities below Threshold, counted together so no small city is named
+	Suppressed int64 `json:"suppressed,omitempty"`
+	Threshold  int64 `json:"threshold,omitempty"`
+	// Archive days read from the archive, set when the range starts before
+	// the retention of the hot store
+	Archive *archive.Coverage `json:"archive,omitempty"`
+	// Metric calculated metric asked for, with its Values by bucket key.
+	// They are computed from the hot store only
+	Metric string              `json:"metric,omitempty"`
+	Values map[string]*float64 `json:"values,omitempty"`
+	// Format dates and revenue of the report are shown in, from the website
+	Format *website.Format `json:"format"`
+}
+
+// mapArea sessions of a country or region compared with the previous period
+type mapArea struct {
+	Code     string `json:"code"`
+	Sessions int64  `json:"sessions"`
+	Previous int64  `json:"previous"`
+	Delta    int64  `json:"delta"`
+	// Change relative to the previous period, nil when the area had no session before
+	Change *float64 `json:"change"`
+}
+
+// geoMap sessions by area keyed by ISO code, shaped for choropleth maps
+type geoMap struct {
+	Level        string    `json:"level"`
+	From         string    `json:"from"`
+	To           string    `json:"to"`
+	PreviousFrom string    `json:"previous_from"`
+	PreviousTo   string    `json:"previous_to"`
+	Areas        []mapArea `json:"areas"`
+	// Unknown sessions whose location could not be resolved
+	Unknown         int64 `json:"unknown"`
+	PreviousUnknown int64 `json:"previous_unknown"`
+	// Archive days of both periods read from the archive
+	Archive *archive.Coverage `json:"archive,omitempty"`
+	Format  *website.Format   `json:"format"`
+}
+
+// heatTable sessions and pageviews by weekday and hour in the timezone of the
+// website, indexed [weekday][hour] with sunday first
+type heatTable struct {
+	Timezone  string       `json:"timezone"`
+	From      string       `json:"from"`
+	To        string       `json:"to"`
+	Sessions  [7][24]int64 `json:"sessions"`
+	Pageviews [7][24]int64 `json:"pageviews"`
+	// Archive days read from the archive
+	Archive *archive.Coverage `json:"archive,omitempty"`
+	Format  *website.Format   `json:"format"`
+}
+
+// page sessions and pageviews of a path, with the content group it falls in
+type page struct {
+	session.Page
+	Group string `json:"group"`
+	// TimeOnPage average engaged seconds of the views sending heartbeats, and
+	// ReadCompletion the share of them read to the end. Both are nil when the
+	// engagement feature sent nothing for the page
+	TimeOnPage     *float64 `json:"time_on_page"`
+	ReadCompletion *float64 `json:"read_completion"`
+}
+
+// pages report, optionally of one content group
+type pages struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Group string `json:"group,omitempty"`
+	Pages []page `json:"pages"`
+	// Suppressed pageviews of the pages left out of a private share, seen by
+	// fewer than Threshold sessions
+	Suppressed int64 `json:"suppressed,omitempty"`
+	Threshold  int64 `json:"threshold,omitempty"`
+	// Archive days read from the archive, engagement of their pages is not
+	// archived
+	Archive *archive.Coverage `json:"archive,omitempty"`
+	Format  *website.Format   `json:"format"`
+}
+
+// contentGroup pageviews of the pages matching a content group. Sessions are
+// not summed across pages, a session reading two posts would count twice
+type contentGroup struct {
+	Name      string `json:"name"`
+	Pages     int    `json:"pages"`
+	Pageviews int64  `json:"pageviews"`
+}
+
+// contentGroups pageviews by content group, in the order the rules are defined
+type contentGroups struct {
+	From      string         `json:"from"`
+	To        string         `json:"to"`
+	Groups    []contentGroup `json:"groups"`
+	Ungrouped contentGroup   `json:"ungrouped"`
+	// Archive days read from the archive
+	Archive *archive.Coverage `json:"archive,omitempty"`
+	Format  *website.Format   `json:"format"`
+}
+
+// pageBreakdown pageviews by author or category of the pages
+type pageBreakdown struct {
+	By      string               `json:"by"`
+	From    string               `json:"from"`
+	To      string               `json:"to"`
+	Buckets []session.PageBucket `json:"buckets"`
+	Format  *website.Format      `json:"format"`
+}
+
+// form sessions starting, submitting and abandoning a form on a page
+type form struct {
+	session.FormStats
+	// AbandonmentRate share of the sessions starting the form without
+	// submitting it, nil when no session started it
+	AbandonmentRate *float64 `json:"abandonment_rate"`
+}
+
+// forms abandonment of the forms of a website
+type forms struct {
+	From   string          `json:"from"`
+	To     string          `json:"to"`
+	Forms  []form          `json:"forms"`
+	Format *website.Format `json:"format"`
+}
+
+// formField step of a form funnel
+type formField struct {
+	session.FormField
+	// ErrorRate share of the sessions of the field shown an error
+	ErrorRate float64 `json:"error_rate"`
+	// DropOff share of the sessions of the field not reaching the next field,
+	// or not submitting the form after the last one
+	DropOff float64 `json:"drop_off"`
+}
+
+// formFunnel field by field drop-off of a form
+type formFunnel struct {
+	FormID  string          `json:"form_id"`
+	From    string          `json:"from"`
+	To      string          `json:"to"`
+	Starts  int64           `json:"starts"`
+	Submits int64           `json:"submits"`
+	Fields  []formField     `json:"fields"`
+	Format  *website.Format `json:"format"`
+}
+
+// goalConversion sessions reaching a goal
+type goalConversion struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Target      string `json:"target"`
+	Conversions int64  `json:"conversions"`
+	// ConversionRate share of all sessions of the range reaching the goal
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+// goals conversions of the goals of a website
+type goals struct {
+	From     string           `json:"from"`
+	To       string           `json:"to"`
+	Sessions int64            `json:"sessions"`
+	Goals    []goalConversion `json:"goals"`
+	Format   *website.Format  `json:"format"`
+}
+
+// RequestEmbedToken website whose stats pages of origins may read, for ttl
+// seconds
+type RequestEmbedToken struct {
+	WebsiteID string   `json:"website_id" validate:"required"`
+	Origins   []string `json:"origins" validate:"required,min=1,max=10"`
+	TTL       int      `json:"ttl" validate:"omitempty,min=60,max=3600"`
+}
+
+// embedToken token of an embedded dashboard, it reads the stats of one
+// website from its origins only
+type embedToken struct {
+	Token     string   `json:"token"`
+	WebsiteID string   `json:"website_id"`
+	Origins   []string `json:"origins"`
+	ExpiresAt string   `json:"expires_at"`
+	// ExpiresIn seconds the token is valid for
+	ExpiresIn int `json:"expires_in"`
+}
@@ -0,0 +1,140 @@
+package stats
+
+import (
+	"fmt"
+	"net/http"
+
+	"analytics-api/internal/app/session"
+	"analytics-api/internal/pkg/privacy"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// filterKey names the filter in the keys of the noise. The dates are left
+// out: the noise follows the data, so shifting a range over the same sessions
+// draws nothing new
+func filterKey(filter session.BreakdownFilter) string {
+	return fmt.Sprintf("%s|%s|%s", filter.Platform, filter.CountryCode, filter.RegionCode)
+}
+
+// SharedBreakdown Breakdown of the website shared with token, buckets of a
+// private share are noised and those below its MinCount suppressed
+func (instance *useCase) SharedBreakdown(token, dimension string, filter session.BreakdownFilter) (*breakdown, error) {
+	userID, websiteID, aShare, err := instance.websiteUseCase.FindShareToken(token)
+	if err != nil {
+		return nil, err
+	}
+	aBreakdown, err := instance.Breakdown(userID, websiteID, dimension, filter)
+	if err != nil || !aShare.Private {
+		return aBreakdown, err
+	}
+
+	seed := []byte(aShare.Seed)
+	key := "breakdown|" + dimension + "|" + filterKey(filter) + "|"
+	buckets := aBreakdown.Buckets
+	aBreakdown.Buckets = []session.Bucket{}
+	for _, bucket := range buckets {
+		bucket.Sessions = privacy.Count(seed, key+bucket.Key, bucket.Sessions)
+		if bucket.Sessions < aShare.MinCount {
+			aBreakdown.Suppressed += bucket.Sessions
+			continue
+		}
+		aBreakdown.Buckets = append(aBreakdown.Buckets, bucket)
+	}
+	if aBreakdown.Suppressed > 0 {
+		// the sum holds cities under the instance threshold too, not noised yet
+		aBreakdown.Suppressed = privacy.Count(seed, key+"suppressed", aBreakdown.Suppressed)
+	}
+	if aShare.MinCount > aBreakdown.Threshold {
+		aBreakdown.Threshold = aShare.MinCount
+	}
+	return aBreakdown, nil
+}
+
+// SharedPages GetPages of the website shared with token, pages of a private
+// share are noised and those seen by fewer than its MinCount sessions left out
+func (instance *useCase) SharedPages(token, group string, filter session.BreakdownFilter) (*pages, error) {
+	userID, websiteID, aShare, err := instance.websiteUseCase.FindShareToken(token)
+	if err != nil {
+		return nil, err
+	}
+	aPages, err := instance.GetPages(userID, websiteID, group, filter)
+	if err != nil || !aShare.Private {
+		return aPages, err
+	}
+
+	seed := []byte(aShare.Seed)
+	key := "pages|" + filterKey(filter) + "|"
+	listPage := aPages.Pages
+	aPages.Pages = []page{}
+	for _, aPage := range listPage {
+		aPage.Sessions = privacy.Count(seed, key+aPage.Path+"|sessions", aPage.Sessions)
+		aPage.Pageviews = privacy.Count(seed, key+aPage.Path+"|pageviews", aPage.Pageviews)
+		if aPage.Sessions < aShare.MinCount {
+			aPages.Suppressed += aPage.Pageviews
+			continue
+		}
+		if aPage.Pageviews < aPage.Sessions {
+			aPage.Pageviews = aPage.Sessions
+		}
+		aPages.Pages = append(aPages.Pages, aPage)
+	}
+	aPages.Threshold = aShare.MinCount
+	return aPages, nil
+}
+
+// GetSharedBreakdown Breakdown of a publicly shared website, no login needed
+func (instance *httpDelivery) GetSharedBreakdown(c *gin.Context) {
+	from, to, err := parseRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter := session.BreakdownFilter{
+		From:        from,
+		To:          to,
+		Platform:    c.Query("platform"),
+		CountryCode: c.Query("country"),
+		RegionCode:  c.Query("region"),
+	}
+
+	aBreakdown, err := instance.statsUseCase.SharedBreakdown(c.Param("token"), c.DefaultQuery("by", "platform"), filter)
+	switch err {
+	case nil:
+	case mongo.ErrNoDocuments:
+		c.JSON(http.StatusNotFound, gin.H{"error": "this share not exists"})
+		return
+	case session.ErrInvalidDimension:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get breakdown failed"})
+		return
+	}
+	c.JSON(http.StatusOK, aBreakdown)
+}
+
+// GetSharedPages GetPages of a publicly shared website, no login needed
+func (instance *httpDelivery) GetSharedPages(c *gin.Context) {
+	from, to, err := parseRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	aPages, err := instance.statsUseCase.SharedPages(c.Param("token"), c.Query("group"), session.BreakdownFilter{From: from, To: to})
+	switch err {
+	case nil:
+	case mongo.ErrNoDocuments:
+		c.JSON(http.StatusNotFound, gin.H{"error": "this share not exists"})
+		return
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get pages failed"})
+		return
+	}
+	c.JSON(http.StatusOK, aPages)
+}
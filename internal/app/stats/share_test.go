@@ -0,0 +1,89 @@
+package stats
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"analytics-api/internal/app/session"
+	"analytics-api/internal/app/website"
+)
+
+// fakeBreakdown answers every breakdown with buckets, whatever the range
+type fakeBreakdown struct {
+	session.UseCase
+	buckets []session.Bucket
+}
+
+func (instance *fakeBreakdown) Breakdown(userID, websiteID, dimension string, filter session.BreakdownFilter) ([]session.Bucket, error) {
+	return append([]session.Bucket{}, instance.buckets...), nil
+}
+
+// fakeShare shares website-1 with share
+type fakeShare struct {
+	fakeWebsites
+	share website.Share
+}
+
+func (instance *fakeShare) FindShareToken(token string) (string, string, *website.Share, error) {
+	aShare := instance.share
+	return "user-1", "website-1", &aShare, nil
+}
+
+func TestSharedBreakdown(t *testing.T) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	buckets := []session.Bucket{{Key: "web", Sessions: 400}, {Key: "ios", Sessions: 30}, {Key: "android", Sessions: 1}}
+	private := website.Share{Token: "t", Private: true, MinCount: 5, Seed: "seed"}
+	tests := []struct {
+		name  string
+		share website.Share
+		check func(t *testing.T, first, second *breakdown)
+	}{
+		{
+			name:  "should return the counts of a public share as they are",
+			share: website.Share{Token: "t"},
+			check: func(t *testing.T, first, second *breakdown) {
+				if !reflect.DeepEqual(first.Buckets, buckets) {
+					t.Errorf("Buckets = %v, want %v", first.Buckets, buckets)
+				}
+			},
+		},
+		{
+			name:  "should noise the same data the same over another range",
+			share: private,
+			check: func(t *testing.T, first, second *breakdown) {
+				if !reflect.DeepEqual(first.Buckets, second.Buckets) || first.Suppressed != second.Suppressed {
+					t.Errorf("ranges over the same sessions gave %v and %v", first.Buckets, second.Buckets)
+				}
+			},
+		},
+		{
+			name:  "should suppress the buckets below the min count",
+			share: private,
+			check: func(t *testing.T, first, second *breakdown) {
+				for _, bucket := range first.Buckets {
+					if bucket.Sessions < private.MinCount {
+						t.Errorf("bucket %s of %d sessions is shown", bucket.Key, bucket.Sessions)
+					}
+				}
+				if first.Threshold != private.MinCount {
+					t.Errorf("Threshold = %d, want %d", first.Threshold, private.MinCount)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := &useCase{sessionUseCase: &fakeBreakdown{buckets: buckets}, websiteUseCase: &fakeShare{share: tt.share}}
+			first, err := instance.SharedBreakdown("t", "platform", session.BreakdownFilter{From: today.AddDate(0, 0, -7), To: today.AddDate(0, 0, 1)})
+			if err != nil {
+				t.Fatalf("SharedBreakdown() error = %v", err)
+			}
+			second, err := instance.SharedBreakdown("t", "platform", session.BreakdownFilter{From: today.AddDate(0, 0, -6), To: today.AddDate(0, 0, 1)})
+			if err != nil {
+				t.Fatalf("SharedBreakdown() error = %v", err)
+			}
+			tt.check(t, first, second)
+		})
+	}
+}
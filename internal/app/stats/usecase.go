@@ -0,0 +1,462 @@
+package stats
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/app/archive"
+	"analytics-api/internal/app/goal"
+	"analytics-api/internal/app/metric"
+	"analytics-api/internal/app/session"
+	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/pathgroup"
+)
+
+// ErrInvalidLevel ...
+var ErrInvalidLevel = errors.New("level must be country or region")
+
+// mapLevels breakdown dimension of each map level
+var mapLevels = map[string]string{
+	"country": "country_code",
+	"region":  "region_code",
+}
+
+// UseCase ...
+type UseCase interface {
+	Breakdown(userID, websiteID, dimension string, filter session.BreakdownFilter) (*breakdown, error)
+	MetricBreakdown(userID, websiteID, key, dimension string, filter session.BreakdownFilter) (*breakdown, error)
+	GetMap(userID, websiteID, level string, from, to time.Time) (*geoMap, error)
+	GetHeatTable(userID, websiteID string, filter session.BreakdownFilter) (*heatTable, error)
+	GetPages(userID, websiteID, group string, filter session.BreakdownFilter) (*pages, error)
+	GetContentGroups(userID, websiteID string, filter session.BreakdownFilter) (*contentGroups, error)
+	PageBreakdown(userID, websiteID, dimension string, filter session.BreakdownFilter) (*pageBreakdown, error)
+	GetForms(userID, websiteID string, filter session.BreakdownFilter) (*forms, error)
+	GetFormFunnel(userID, websiteID, formID string, filter session.BreakdownFilter) (*formFunnel, error)
+	GetGoals(userID, websiteID string, filter session.BreakdownFilter) (*goals, error)
+	SharedBreakdown(token, dimension string, filter session.BreakdownFilter) (*breakdown, error)
+	SharedPages(token, group string, filter session.BreakdownFilter) (*pages, error)
+	Compare(userID string, websiteIDs []string, metric string, filter session.BreakdownFilter) (*comparison, error)
+	CreateEmbedToken(userID, tenantID, websiteID string, origins []string, ttl time.Duration) (*embedToken, error)
+}
+
+// useCase reports are computed from the session events, stats has no storage
+// of its own. Ranges past the retention of the hot store read the archive too
+type useCase struct {
+	sessionUseCase session.UseCase
+	websiteUseCase website.UseCase
+	goalUseCase    goal.UseCase
+	archiveUseCase archive.UseCase
+	metricUseCase  metric.UseCase
+}
+
+// NewUseCase ...
+func NewUseCase(store *db.Store) UseCase {
+	return &useCase{
+		sessionUseCase: session.NewUseCase(store),
+		websiteUseCase: website.NewUseCase(store),
+		goalUseCase:    goal.NewUseCase(store),
+		archiveUseCase: archive.NewUseCase(store),
+		metricUseCase:  metric.NewUseCase(store),
+	}
+}
+
+// Breakdown count sessions of website by value of dimension, cities below the
+// privacy threshold are never named
+func (instance *useCase) Breakdown(userID, websiteID, dimension string, filter session.BreakdownFilter) (*breakdown, error) {
+	buckets, aCoverage, err := instance.breakdown(userID, websiteID, dimension, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	aBreakdown := &breakdown{
+		By:      dimension,
+		From:    filter.From.Format(dateLayout),
+		To:      filter.To.AddDate(0, 0, -1).Format(dateLayout),
+		Buckets: buckets,
+		Archive: aCoverage,
+	}
+	cityMinSessions := configs.Current().CityMinSessions
+	if dimension == "city" && cityMinSessions > 1 {
+		aBreakdown.Threshold = cityMinSessions
+		aBreakdown.Buckets = []session.Bucket{}
+		for _, bucket := range buckets {
+			if bucket.Sessions < cityMinSessions {
+				aBreakdown.Suppressed += bucket.Sessions
+				continue
+			}
+			aBreakdown.Buckets = append(aBreakdown.Buckets, bucket)
+		}
+	}
+	aBreakdown.Format, err = instance.websiteUseCase.GetFormat(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	return aBreakdown, nil
+}
+
+// MetricBreakdown breakdown of website by dimension with the value of the
+// calculated metric key in each bucket, suppressed cities get none.
+// metric.ErrMetricNotFound when the website has no such metric
+func (instance *useCase) MetricBreakdown(userID, websiteID, key, dimension string, filter session.BreakdownFilter) (*breakdown, error) {
+	aBreakdown, err := instance.Breakdown(userID, websiteID, dimension, filter)
+	if err != nil {
+		return nil, err
+	}
+	values, err := instance.metricUseCase.Breakdown(userID, websiteID, key, dimension, filter)
+	if err != nil {
+		return nil, err
+	}
+	aBreakdown.Metric = key
+	aBreakdown.Values = map[string]*float64{}
+	for _, bucket := range aBreakdown.Buckets {
+		aBreakdown.Values[bucket.Key] = values[bucket.Key]
+	}
+	return aBreakdown, nil
+}
+
+// GetMap count sessions by country or region between from and to, and in the
+// period of the same length right before
+func (instance *useCase) GetMap(userID, websiteID, level string, from, to time.Time) (*geoMap, error) {
+	dimension, ok := mapLevels[level]
+	if !ok {
+		return nil, ErrInvalidLevel
+	}
+
+	current, currentCoverage, err := instance.breakdown(userID, websiteID, dimension, session.BreakdownFilter{From: from, To: to})
+	if err != nil {
+		return nil, err
+	}
+	previousFrom := from.Add(-to.Sub(from))
+	previous, previousCoverage, err := instance.breakdown(userID, websiteID, dimension, session.BreakdownFilter{
+		From: previousFrom,
+		To:   from,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// to is exclusive, reported dates are both included
+	aMap := &geoMap{
+		Level:        level,
+		From:         from.Format(dateLayout),
+		To:           to.AddDate(0, 0, -1).Format(dateLayout),
+		PreviousFrom: previousFrom.Format(dateLayout),
+		PreviousTo:   from.AddDate(0, 0, -1).Format(dateLayout),
+		Areas:        []mapArea{},
+		Archive:      previousCoverage.Add(currentCoverage),
+	}
+	areas := map[string]*mapArea{}
+	area := func(code string) *mapArea {
+		if areas[code] == nil {
+			areas[code] = &mapArea{Code: code}
+		}
+		return areas[code]
+	}
+	for _, bucket := range current {
+		if bucket.Key == "" {
+			aMap.Unknown += bucket.Sessions
+			continue
+		}
+		area(bucket.Key).Sessions = bucket.Sessions
+	}
+	for _, bucket := range previous {
+		if bucket.Key == "" {
+			aMap.PreviousUnknown += bucket.Sessions
+			continue
+		}
+		area(bucket.Key).Previous = bucket.Sessions
+	}
+
+	for _, anArea := range areas {
+		anArea.Delta = anArea.Sessions - anArea.Previous
+		if anArea.Previous > 0 {
+			change := float64(anArea.Delta) / float64(anArea.Previous)
+			anArea.Change = &change
+		}
+		aMap.Areas = append(aMap.Areas, *anArea)
+	}
+	sort.Slice(aMap.Areas, func(i, j int) bool {
+		if aMap.Areas[i].Sessions != aMap.Areas[j].Sessions {
+			return aMap.Areas[i].Sessions > aMap.Areas[j].Sessions
+		}
+		return aMap.Areas[i].Code < aMap.Areas[j].Code
+	})
+	aMap.Format, err = instance.websiteUseCase.GetFormat(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	return aMap, nil
+}
+
+// GetHeatTable sessions and pageviews by weekday and hour, the dates of filter
+// are days in the timezone of the website
+func (instance *useCase) GetHeatTable(userID, websiteID string, filter session.BreakdownFilter) (*heatTable, error) {
+	location, err := instance.websiteUseCase.GetLocation(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	filter.From = time.Date(filter.From.Year(), filter.From.Month(), filter.From.Day(), 0, 0, 0, 0, location)
+	filter.To = time.Date(filter.To.Year(), filter.To.Month(), filter.To.Day(), 0, 0, 0, 0, location)
+
+	hot, archived, ok := split(filter)
+	cells, err := instance.sessionUseCase.HeatTable(userID, websiteID, hot, location)
+	if err != nil {
+		return nil, err
+	}
+	var aCoverage *archive.Coverage
+	if ok {
+		archivedCells, err := session.ArchivedHeatTable(archived, location, instance.scan(userID, websiteID, archived, &aCoverage))
+		if err != nil {
+			return nil, err
+		}
+		cells = append(cells, archivedCells...)
+	}
+
+	aHeatTable := &heatTable{
+		Timezone: location.String(),
+		From:     filter.From.Format(dateLayout),
+		To:       filter.To.AddDate(0, 0, -1).Format(dateLayout),
+		Archive:  aCoverage,
+	}
+	for _, cell := range cells {
+		if cell.Weekday < 0 || cell.Weekday > 6 || cell.Hour < 0 || cell.Hour > 23 {
+			continue
+		}
+		aHeatTable.Sessions[cell.Weekday][cell.Hour] += cell.Sessions
+		aHeatTable.Pageviews[cell.Weekday][cell.Hour] += cell.Pageviews
+	}
+	aHeatTable.Format, err = instance.websiteUseCase.GetFormat(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	return aHeatTable, nil
+}
+
+// GetPages sessions and pageviews by path, each labelled with its content
+// group. Only pages of group are kept when set
+func (instance *useCase) GetPages(userID, websiteID, group string, filter session.BreakdownFilter) (*pages, error) {
+	rules, err := instance.websiteUseCase.GetContentGroups(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	aFeatures, err := instance.websiteUseCase.GetFeatures(websiteID)
+	if err != nil {
+		return nil, err
+	}
+	filter.Canonical = aFeatures.CanonicalURLs
+	listPage, aCoverage, err := instance.pages(userID, websiteID, filter)
+	if err != nil {
+		return nil, err
+	}
+	listEngagement, err := instance.sessionUseCase.Engagement(userID, websiteID, filter)
+	if err != nil {
+		return nil, err
+	}
+	engagement := map[string]session.PageEngagement{}
+	for _, anEngagement := range listEngagement {
+		engagement[anEngagement.Path] = anEngagement
+	}
+
+	aPages := &pages{
+		From:    filter.From.Format(dateLayout),
+		To:      filter.To.AddDate(0, 0, -1).Format(dateLayout),
+		Group:   group,
+		Pages:   []page{},
+		Archive: aCoverage,
+	}
+	for _, aPage := range listPage {
+		name := pathgroup.Group(rules, aPage.Path)
+		if group != "" && name != group {
+			continue
+		}
+		row := page{Page: aPage, Group: name}
+		if anEngagement, ok := engagement[aPage.Path]; ok && anEngagement.Views > 0 {
+			timeOnPage := float64(anEngagement.EngagedMs) / 1000 / float64(anEngagement.Views)
+			readCompletion := float64(anEngagement.Reads) / float64(anEngagement.Views)
+			row.TimeOnPage = &timeOnPage
+			row.ReadCompletion = &readCompletion
+		}
+		aPages.Pages = append(aPages.Pages, row)
+	}
+	aPages.Format, err = instance.websiteUseCase.GetFormat(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	return aPages, nil
+}
+
+// GetContentGroups pageviews by content group, grouping pages at query time so
+// rules apply to data collected before they were defined
+func (instance *useCase) GetContentGroups(userID, websiteID string, filter session.BreakdownFilter) (*contentGroups, error) {
+	rules, err := instance.websiteUseCase.GetContentGroups(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	listPage, aCoverage, err := instance.pages(userID, websiteID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	aContentGroups := &contentGroups{
+		From:    filter.From.Format(dateLayout),
+		To:      filter.To.AddDate(0, 0, -1).Format(dateLayout),
+		Groups:  []contentGroup{},
+		Archive: aCoverage,
+	}
+	index := map[string]int{}
+	for _, rule := range rules {
+		if _, ok := index[rule.Name]; ok {
+			continue
+		}
+		index[rule.Name] = len(aContentGroups.Groups)
+		aContentGroups.Groups = append(aContentGroups.Groups, contentGroup{Name: rule.Name})
+	}
+	for _, aPage := range listPage {
+		aContentGroup := &aContentGroups.Ungrouped
+		if i, ok := index[pathgroup.Group(rules, aPage.Path)]; ok {
+			aContentGroup = &aContentGroups.Groups[i]
+		}
+		aContentGroup.Pages++
+		aContentGroup.Pageviews += aPage.Pageviews
+	}
+	aContentGroups.Format, err = instance.websiteUseCase.GetFormat(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	return aContentGroups, nil
+}
+
+// PageBreakdown pageviews by author or category sent by the page meta feature
+func (instance *useCase) PageBreakdown(userID, websiteID, dimension string, filter session.BreakdownFilter) (*pageBreakdown, error) {
+	buckets, err := instance.sessionUseCase.PageBreakdown(userID, websiteID, dimension, filter)
+	if err != nil {
+		return nil, err
+	}
+	aPageBreakdown := &pageBreakdown{
+		By:      dimension,
+		From:    filter.From.Format(dateLayout),
+		To:      filter.To.AddDate(0, 0, -1).Format(dateLayout),
+		Buckets: buckets,
+	}
+	aPageBreakdown.Format, err = instance.websiteUseCase.GetFormat(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	return aPageBreakdown, nil
+}
+
+// GetForms abandonment of each form of website by page
+func (instance *useCase) GetForms(userID, websiteID string, filter session.BreakdownFilter) (*forms, error) {
+	listForm, err := instance.sessionUseCase.Forms(userID, websiteID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	aForms := &forms{
+		From:  filter.From.Format(dateLayout),
+		To:    filter.To.AddDate(0, 0, -1).Format(dateLayout),
+		Forms: []form{},
+	}
+	for _, aForm := range listForm {
+		row := form{FormStats: aForm}
+		if aForm.Starts > 0 {
+			abandoned := aForm.Starts - aForm.Submits
+			if abandoned < 0 {
+				abandoned = 0
+			}
+			rate := float64(abandoned) / float64(aForm.Starts)
+			row.AbandonmentRate = &rate
+		}
+		aForms.Forms = append(aForms.Forms, row)
+	}
+	aForms.Format, err = instance.websiteUseCase.GetFormat(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	return aForms, nil
+}
+
+// GetFormFunnel sessions going through each field of a form, in the order of
+// the fields in the form
+func (instance *useCase) GetFormFunnel(userID, websiteID, formID string, filter session.BreakdownFilter) (*formFunnel, error) {
+	aFunnel, err := instance.sessionUseCase.FormFunnel(userID, websiteID, formID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	aFormFunnel := &formFunnel{
+		FormID:  formID,
+		From:    filter.From.Format(dateLayout),
+		To:      filter.To.AddDate(0, 0, -1).Format(dateLayout),
+		Starts:  aFunnel.Starts,
+		Submits: aFunnel.Submits,
+		Fields:  []formField{},
+	}
+	for i, aField := range aFunnel.Fields {
+		next := aFunnel.Submits
+		if i+1 < len(aFunnel.Fields) {
+			next = aFunnel.Fields[i+1].Sessions
+		}
+		step := formField{FormField: aField}
+		if aField.Sessions > 0 {
+			step.ErrorRate = float64(aField.Errors) / float64(aField.Sessions)
+			if next < aField.Sessions {
+				step.DropOff = float64(aField.Sessions-next) / float64(aField.Sessions)
+			}
+		}
+		aFormFunnel.Fields = append(aFormFunnel.Fields, step)
+	}
+	aFormFunnel.Format, err = instance.websiteUseCase.GetFormat(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	return aFormFunnel, nil
+}
+
+// GetGoals sessions reaching each goal of website
+func (instance *useCase) GetGoals(userID, websiteID string, filter session.BreakdownFilter) (*goals, error) {
+	listGoal, err := instance.goalUseCase.GetAllGoal(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	// every session has a single platform, so the platform buckets add up to
+	// all sessions of the range
+	buckets, err := instance.sessionUseCase.Breakdown(userID, websiteID, "platform", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	aGoals := &goals{
+		From:  filter.From.Format(dateLayout),
+		To:    filter.To.AddDate(0, 0, -1).Format(dateLayout),
+		Goals: []goalConversion{},
+	}
+	for _, bucket := range buckets {
+		aGoals.Sessions += bucket.Sessions
+	}
+	for _, aGoal := range listGoal {
+		conversions, err := instance.sessionUseCase.FormSubmissions(userID, websiteID, aGoal.Target, filter)
+		if err != nil {
+			return nil, err
+		}
+		aConversion := goalConversion{
+			ID:          aGoal.ID,
+			Name:        aGoal.Name,
+			Type:        aGoal.Type,
+			Target:      aGoal.Target,
+			Conversions: conversions,
+		}
+		if aGoals.Sessions > 0 {
+			aConversion.ConversionRate = float64(conversions) / float64(aGoals.Sessions)
+		}
+		aGoals.Goals = append(aGoals.Goals, aConversion)
+	}
+	aGoals.Format, err = instance.websiteUseCase.GetFormat(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	return aGoals, nil
+}
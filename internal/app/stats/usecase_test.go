@@ -0,0 +1,77 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"analytics-api/internal/app/session"
+	"analytics-api/internal/app/website"
+)
+
+// fakeSessions answers the form report with forms
+type fakeSessions struct {
+	session.UseCase
+	forms []session.FormStats
+}
+
+func (instance *fakeSessions) Forms(userID, websiteID string, filter session.BreakdownFilter) ([]session.FormStats, error) {
+	return instance.forms, nil
+}
+
+type fakeWebsites struct {
+	website.UseCase
+}
+
+func (instance *fakeWebsites) GetFormat(userID, websiteID string) (*website.Format, error) {
+	return &website.Format{Timezone: "UTC"}, nil
+}
+
+func TestGetForms(t *testing.T) {
+	rate := func(value float64) *float64 {
+		return &value
+	}
+	tests := []struct {
+		name  string
+		forms []session.FormStats
+		want  []*float64
+	}{
+		{name: "should report no form", forms: nil, want: nil},
+		{
+			name:  "should rate the starts left unsubmitted",
+			forms: []session.FormStats{{FormID: "signup", Path: "/", Starts: 8, Submits: 2, Abandons: 3}},
+			want:  []*float64{rate(0.75)},
+		},
+		{
+			name:  "should leave the rate out without starts",
+			forms: []session.FormStats{{FormID: "cart", Path: "/checkout", Abandons: 4}},
+			want:  []*float64{nil},
+		},
+		{
+			name:  "should not rate below zero when submits outnumber starts",
+			forms: []session.FormStats{{FormID: "signup", Path: "/", Starts: 2, Submits: 3}},
+			want:  []*float64{rate(0)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := &useCase{sessionUseCase: &fakeSessions{forms: tt.forms}, websiteUseCase: &fakeWebsites{}}
+			from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+			got, err := instance.GetForms("user-1", "website-1", session.BreakdownFilter{From: from, To: from.AddDate(0, 0, 7)})
+			if err != nil {
+				t.Fatalf("GetForms() error = %v", err)
+			}
+			if got.From != "2024-03-01" || got.To != "2024-03-07" {
+				t.Errorf("GetForms() range = %v to %v", got.From, got.To)
+			}
+			if len(got.Forms) != len(tt.want) {
+				t.Fatalf("GetForms() = %d forms, want %d", len(got.Forms), len(tt.want))
+			}
+			for i, want := range tt.want {
+				gotRate := got.Forms[i].AbandonmentRate
+				if (gotRate == nil) != (want == nil) || (want != nil && *gotRate != *want) {
+					t.Errorf("GetForms() rate of %s = %v, want %v", got.Forms[i].FormID, gotRate, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package tenant
+
+import (
+	"analytics-api/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPDelivery ...
+type HTTPDelivery interface {
+	// This function is required for every HTTPDelivery
+	InitRoutes(r *gin.RouterGroup)
+
+	// Other functions to handle HTTP requests
+	ProvisionTenant(c *gin.Context)
+	GetTenant(c *gin.Context)
+	GetAllTenant(c *gin.Context)
+	RotateKey(c *gin.Context)
+	UpdateAllowList(c *gin.Context)
+	UpdateSignedWrites(c *gin.Context)
+	UpdateReplayWatermark(c *gin.Context)
+	UpdateVisitorStream(c *gin.Context)
+}
+
+// NewHTTPDelivery ...
+func NewHTTPDelivery(store *db.Store) HTTPDelivery {
+	return &httpDelivery{
+		tenantUseCase: NewUseCase(store),
+	}
+}
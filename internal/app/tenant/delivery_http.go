@@ -0,0 +1,226 @@
+package tenant
+
+import (
+	"net/http"
+
+	"analytics-api/internal/pkg/middleware"
+	req "analytics-api/internal/pkg/request"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type httpDelivery struct {
+	tenantUseCase UseCase
+}
+
+// RequestTenant ...
+type RequestTenant struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	HostNames []string `json:"host_names"`
+}
+
+// RequestAllowList ...
+type RequestAllowList struct {
+	CIDRs     []string `json:"cidrs"`
+	ConfirmIP string   `json:"confirm_ip"`
+	Force     bool     `json:"force"`
+}
+
+// RequestSignedWrites ...
+type RequestSignedWrites struct {
+	Enabled bool `json:"enabled"`
+}
+
+// RequestReplayWatermark ...
+type RequestReplayWatermark struct {
+	Enabled bool `json:"enabled"`
+}
+
+// RequestVisitorStream ...
+type RequestVisitorStream struct {
+	Enabled bool `json:"enabled"`
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	tenantRoutes := r.Group("/admin/tenants", middleware.AdminMiddleware())
+	{
+		tenantRoutes.POST("", instance.ProvisionTenant)
+		tenantRoutes.GET("", instance.GetAllTenant)
+		tenantRoutes.GET("/:tenant_id", instance.GetTenant)
+		tenantRoutes.POST("/:tenant_id/keys/rotate", instance.RotateKey)
+		tenantRoutes.PUT("/:tenant_id/allow-list", instance.UpdateAllowList)
+		tenantRoutes.PUT("/:tenant_id/signed-writes", instance.UpdateSignedWrites)
+		tenantRoutes.PUT("/:tenant_id/replay-watermark", instance.UpdateReplayWatermark)
+		tenantRoutes.PUT("/:tenant_id/visitor-stream", instance.UpdateVisitorStream)
+	}
+}
+
+// ProvisionTenant create tenant and its database
+func (instance *httpDelivery) ProvisionTenant(c *gin.Context) {
+	request, err := req.BindAndValidate[RequestTenant](c)
+	if err != nil {
+		req.BadRequest(c, "invalid tenant", err)
+		return
+	}
+
+	aTenant := tenant{
+		ID:        request.ID,
+		Name:      request.Name,
+		HostNames: request.HostNames,
+	}
+	err = instance.tenantUseCase.ProvisionTenant(&aTenant)
+	switch err {
+	case nil:
+		c.JSON(http.StatusCreated, aTenant)
+	case ErrInvalidTenantID:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case ErrTenantExists, ErrHostNameExists:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "provision tenant failed"})
+	}
+}
+
+func (instance *httpDelivery) GetTenant(c *gin.Context) {
+	var aTenant tenant
+	err := instance.tenantUseCase.GetTenant(c.Param("tenant_id"), &aTenant)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "this tenant not exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get tenant failed"})
+		return
+	}
+	c.JSON(http.StatusOK, aTenant)
+}
+
+func (instance *httpDelivery) GetAllTenant(c *gin.Context) {
+	tenants, err := instance.tenantUseCase.GetAllTenant()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get tenants failed"})
+		return
+	}
+	c.JSON(http.StatusOK, tenants)
+}
+
+// RotateKey seal new data of tenant with a new key version
+func (instance *httpDelivery) RotateKey(c *gin.Context) {
+	var aTenant tenant
+	err := instance.tenantUseCase.RotateKey(c.Param("tenant_id"), &aTenant)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "this tenant not exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "rotate key failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":          aTenant.ID,
+		"key_version": aTenant.KeyVersion,
+	})
+}
+
+// UpdateAllowList replace networks allowed to reach the dashboard of tenant,
+// the admin API itself is never subject to the allow-list
+func (instance *httpDelivery) UpdateAllowList(c *gin.Context) {
+	var aTenant tenant
+	request, err := req.BindAndValidate[RequestAllowList](c)
+	if err != nil {
+		req.BadRequest(c, "invalid allow-list", err)
+		return
+	}
+
+	err = instance.tenantUseCase.UpdateAllowList(c.Param("tenant_id"), request.CIDRs, request.ConfirmIP, request.Force, &aTenant)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{
+			"id":            aTenant.ID,
+			"allowed_cidrs": aTenant.AllowedCIDRs,
+		})
+	case ErrInvalidCIDR, ErrConfirmIPRequired:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case ErrLockout:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case mongo.ErrNoDocuments:
+		c.JSON(http.StatusNotFound, gin.H{"error": "this tenant not exists"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update allow-list failed"})
+	}
+}
+
+// UpdateSignedWrites require writes of the management API of tenant to be
+// signed with an api key, or stop requiring it
+func (instance *httpDelivery) UpdateSignedWrites(c *gin.Context) {
+	var aTenant tenant
+	request, err := req.BindAndValidate[RequestSignedWrites](c)
+	if err != nil {
+		req.BadRequest(c, "invalid signed writes", err)
+		return
+	}
+
+	err = instance.tenantUseCase.UpdateSignedWrites(c.Param("tenant_id"), request.Enabled, &aTenant)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{
+			"id":            aTenant.ID,
+			"signed_writes": aTenant.SignedWrites,
+		})
+	case mongo.ErrNoDocuments:
+		c.JSON(http.StatusNotFound, gin.H{"error": "this tenant not exists"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update signed writes failed"})
+	}
+}
+
+// UpdateReplayWatermark overlay the identity of the viewer on recordings of
+// tenant, or stop doing it
+func (instance *httpDelivery) UpdateReplayWatermark(c *gin.Context) {
+	var aTenant tenant
+	request, err := req.BindAndValidate[RequestReplayWatermark](c)
+	if err != nil {
+		req.BadRequest(c, "invalid replay watermark", err)
+		return
+	}
+
+	err = instance.tenantUseCase.UpdateReplayWatermark(c.Param("tenant_id"), request.Enabled, &aTenant)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{
+			"id":               aTenant.ID,
+			"replay_watermark": aTenant.ReplayWatermark,
+		})
+	case mongo.ErrNoDocuments:
+		c.JSON(http.StatusNotFound, gin.H{"error": "this tenant not exists"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update replay watermark failed"})
+	}
+}
+
+// UpdateVisitorStream let the support agents of tenant watch the live events
+// of identified visitors, or stop it
+func (instance *httpDelivery) UpdateVisitorStream(c *gin.Context) {
+	var aTenant tenant
+	request, err := req.BindAndValidate[RequestVisitorStream](c)
+	if err != nil {
+		req.BadRequest(c, "invalid visitor stream", err)
+		return
+	}
+
+	err = instance.tenantUseCase.UpdateVisitorStream(c.Param("tenant_id"), request.Enabled, &aTenant)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{
+			"id":             aTenant.ID,
+			"visitor_stream": aTenant.VisitorStream,
+		})
+	case mongo.ErrNoDocuments:
+		c.JSON(http.StatusNotFound, gin.H{"error": "this tenant not exists"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update visitor stream failed"})
+	}
+}
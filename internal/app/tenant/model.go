@@ -0,0 +1,23 @@
+package tenant
+
+// tenant ...
+type tenant struct {
+	ID        string   `json:"id" bson:"id"`
+	Name      string   `json:"name" bson:"name"`
+	HostNames []string `json:"host_names" bson:"host_names"`
+	// KeyVersion version of the data key sealing new recordings
+	KeyVersion int `json:"key_version" bson:"key_version"`
+	// AllowedCIDRs networks allowed to reach the dashboard and management API, empty allows all
+	AllowedCIDRs []string `json:"allowed_cidrs" bson:"allowed_cidrs"`
+	// SignedWrites writes of the management API must be signed with an api key
+	SignedWrites bool `json:"signed_writes" bson:"signed_writes"`
+	// ReplayWatermark recordings are played with the identity of the viewer over them
+	ReplayWatermark bool `json:"replay_watermark" bson:"replay_watermark"`
+	// VisitorStream support agents may watch the live events of identified visitors
+	VisitorStream bool   `json:"visitor_stream" bson:"visitor_stream"`
+	CreatedAt     string `json:"created_at" bson:"created_at"`
+	UpdatedAt     string `json:"updated_at" bson:"updated_at"`
+}
+
+// tenants ...
+type tenants []tenant
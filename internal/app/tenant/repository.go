@@ -0,0 +1,181 @@
+package tenant
+
+import (
+	"context"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Repository ...
+type Repository interface {
+	FindTenant(tenantID string) (int64, error)
+	FindHostName(hostNames []string) (int64, error)
+	InsertTenant(aTenant tenant) error
+	GetTenant(tenantID string, aTenant *tenant) error
+	GetTenantByHostName(hostName string, aTenant *tenant) error
+	GetAllTenant() (*tenants, error)
+	IncrementKeyVersion(tenantID, updatedAt string, aTenant *tenant) error
+	UpdateAllowList(tenantID, updatedAt string, cidrs []string, aTenant *tenant) error
+	UpdateSignedWrites(tenantID, updatedAt string, enabled bool, aTenant *tenant) error
+	UpdateReplayWatermark(tenantID, updatedAt string, enabled bool, aTenant *tenant) error
+	UpdateVisitorStream(tenantID, updatedAt string, enabled bool, aTenant *tenant) error
+}
+
+// repository tenants are stored in the control database, never in a tenant store
+type repository struct {
+	store *db.Store
+}
+
+// NewRepository ...
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store: store,
+	}
+}
+
+func (instance *repository) FindTenant(tenantID string) (int64, error) {
+	tenantCollection := instance.store.Mongo.Collection(configs.MongoDB.TenantCollection)
+	count, err := tenantCollection.CountDocuments(context.TODO(), bson.M{"id": tenantID})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// FindHostName count tenants using any of host names
+func (instance *repository) FindHostName(hostNames []string) (int64, error) {
+	tenantCollection := instance.store.Mongo.Collection(configs.MongoDB.TenantCollection)
+	filter := bson.M{"host_names": bson.M{"$in": hostNames}}
+	count, err := tenantCollection.CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (instance *repository) InsertTenant(aTenant tenant) error {
+	tenantCollection := instance.store.Mongo.Collection(configs.MongoDB.TenantCollection)
+	_, err := tenantCollection.InsertOne(context.TODO(), aTenant)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) GetTenant(tenantID string, aTenant *tenant) error {
+	tenantCollection := instance.store.Mongo.Collection(configs.MongoDB.TenantCollection)
+	err := tenantCollection.FindOne(context.TODO(), bson.M{"id": tenantID}).Decode(&aTenant)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) GetTenantByHostName(hostName string, aTenant *tenant) error {
+	tenantCollection := instance.store.Mongo.Collection(configs.MongoDB.TenantCollection)
+	err := tenantCollection.FindOne(context.TODO(), bson.M{"host_names": hostName}).Decode(&aTenant)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) GetAllTenant() (*tenants, error) {
+	var tenants tenants
+	tenantCollection := instance.store.Mongo.Collection(configs.MongoDB.TenantCollection)
+	cursor, err := tenantCollection.Find(context.TODO(), bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &tenants); err != nil {
+		return nil, err
+	}
+	return &tenants, nil
+}
+
+// IncrementKeyVersion bump key version of tenant and decode the updated tenant
+func (instance *repository) IncrementKeyVersion(tenantID, updatedAt string, aTenant *tenant) error {
+	tenantCollection := instance.store.Mongo.Collection(configs.MongoDB.TenantCollection)
+	update := bson.M{
+		"$inc": bson.M{"key_version": 1},
+		"$set": bson.M{"updated_at": updatedAt},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := tenantCollection.FindOneAndUpdate(context.TODO(), bson.M{"id": tenantID}, update, opts).Decode(&aTenant)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateAllowList replace allowed networks of tenant and decode the updated tenant
+func (instance *repository) UpdateAllowList(tenantID, updatedAt string, cidrs []string, aTenant *tenant) error {
+	tenantCollection := instance.store.Mongo.Collection(configs.MongoDB.TenantCollection)
+	update := bson.M{
+		"$set": bson.M{
+			"allowed_cidrs": cidrs,
+			"updated_at":    updatedAt,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := tenantCollection.FindOneAndUpdate(context.TODO(), bson.M{"id": tenantID}, update, opts).Decode(&aTenant)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateSignedWrites set whether writes of tenant must be signed and decode the updated tenant
+func (instance *repository) UpdateSignedWrites(tenantID, updatedAt string, enabled bool, aTenant *tenant) error {
+	tenantCollection := instance.store.Mongo.Collection(configs.MongoDB.TenantCollection)
+	update := bson.M{
+		"$set": bson.M{
+			"signed_writes": enabled,
+			"updated_at":    updatedAt,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := tenantCollection.FindOneAndUpdate(context.TODO(), bson.M{"id": tenantID}, update, opts).Decode(&aTenant)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateReplayWatermark set whether recordings of tenant are watermarked and decode the updated tenant
+func (instance *repository) UpdateReplayWatermark(tenantID, updatedAt string, enabled bool, aTenant *tenant) error {
+	tenantCollection := instance.store.Mongo.Collection(configs.MongoDB.TenantCollection)
+	update := bson.M{
+		"$set": bson.M{
+			"replay_watermark": enabled,
+			"updated_at":       updatedAt,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := tenantCollection.FindOneAndUpdate(context.TODO(), bson.M{"id": tenantID}, update, opts).Decode(&aTenant)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateVisitorStream set whether the live events of identified visitors of tenant may be watched and decode the updated tenant
+func (instance *repository) UpdateVisitorStream(tenantID, updatedAt string, enabled bool, aTenant *tenant) error {
+	tenantCollection := instance.store.Mongo.Collection(configs.MongoDB.TenantCollection)
+	update := bson.M{
+		"$set": bson.M{
+			"visitor_stream": enabled,
+			"updated_at":     updatedAt,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := tenantCollection.FindOneAndUpdate(context.TODO(), bson.M{"id": tenantID}, update, opts).Decode(&aTenant)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package tenant
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// hostCacheTTL bound how long a host keeps resolving after its tenant changes
+const hostCacheTTL = time.Minute
+
+type hostEntry struct {
+	tenantID string
+	expires  time.Time
+}
+
+// tenantEntry routes of tenant, key version is refreshed so rotations take effect
+type tenantEntry struct {
+	handler http.Handler
+	store   *db.Store
+	expires time.Time
+}
+
+// Router dispatch requests to the handler of tenant resolved from hostname or token claim
+type Router struct {
+	control    http.Handler
+	newHandler func(store *db.Store) http.Handler
+	useCase    UseCase
+
+	mu       sync.Mutex
+	hosts    map[string]hostEntry
+	handlers map[string]*tenantEntry
+}
+
+// NewRouter control serves the admin API, newHandler builds the routes bound to a tenant store
+func NewRouter(control http.Handler, newHandler func(store *db.Store) http.Handler) *Router {
+	return &Router{
+		control:    control,
+		newHandler: newHandler,
+		useCase:    NewUseCase(db.DefaultStore()),
+		hosts:      map[string]hostEntry{},
+		handlers:   map[string]*tenantEntry{},
+	}
+}
+
+func (instance *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/admin/") {
+		instance.control.ServeHTTP(w, r)
+		return
+	}
+
+	tenantID := instance.resolve(r)
+	if tenantID == "" {
+		http.Error(w, "unknown tenant", http.StatusNotFound)
+		return
+	}
+	handler := instance.handler(tenantID)
+	if handler == nil {
+		http.Error(w, "tenant unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// resolve tenant by hostname first, then by tenant claim of the access
+// token, of the refresh cookie once it expired, or of the embed token for
+// embedded dashboards
+func (instance *Router) resolve(r *http.Request) string {
+	hostName, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		hostName = r.Host
+	}
+	hostName = strings.ToLower(hostName)
+
+	if tenantID := instance.tenantOfHost(hostName); tenantID != "" {
+		return tenantID
+	}
+
+	tenantID := ""
+	if tokenAuth, err := security.ExtractAccessTokenMetadata(r); err == nil {
+		tenantID = tokenAuth.TenantID
+	} else if tokenDetails, err := security.VerifyRefreshToken(security.ExtractRefreshToken(r)); err == nil {
+		tenantID = tokenDetails.TenantID
+	} else if details, err := security.VerifyEmbedToken(security.ExtractEmbedToken(r)); err == nil {
+		tenantID = details.TenantID
+	}
+	if tenantID == "" {
+		return ""
+	}
+	var aTenant tenant
+	if err := instance.useCase.GetTenant(tenantID, &aTenant); err != nil {
+		return ""
+	}
+	return aTenant.ID
+}
+
+func (instance *Router) tenantOfHost(hostName string) string {
+	instance.mu.Lock()
+	entry, ok := instance.hosts[hostName]
+	instance.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.tenantID
+	}
+
+	var aTenant tenant
+	err := instance.useCase.GetTenantByHostName(hostName, &aTenant)
+	if err != nil && err != mongo.ErrNoDocuments {
+		logrus.Error("get tenant by host name error ", err)
+		return ""
+	}
+
+	instance.mu.Lock()
+	instance.hosts[hostName] = hostEntry{tenantID: aTenant.ID, expires: time.Now().Add(hostCacheTTL)}
+	instance.mu.Unlock()
+	return aTenant.ID
+}
+
+// handler routes of tenant, nil when the tenant cannot be loaded
+func (instance *Router) handler(tenantID string) http.Handler {
+	instance.mu.Lock()
+	entry, ok := instance.handlers[tenantID]
+	if ok && time.Now().Before(entry.expires) {
+		instance.mu.Unlock()
+		return entry.handler
+	}
+	instance.mu.Unlock()
+
+	var aTenant tenant
+	err := instance.useCase.GetTenant(tenantID, &aTenant)
+	if err != nil {
+		logrus.Error("get tenant error ", err)
+		if ok {
+			return entry.handler
+		}
+		return nil
+	}
+
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	if !ok {
+		store := db.TenantStore(tenantID, aTenant.KeyVersion)
+		entry = &tenantEntry{
+			handler: instance.newHandler(store),
+			store:   store,
+		}
+		instance.handlers[tenantID] = entry
+	} else if entry.store.Keys != nil {
+		entry.store.Keys.SetVersion(aTenant.KeyVersion)
+	}
+	if err := entry.store.AllowList.Set(aTenant.AllowedCIDRs); err != nil {
+		logrus.Error("invalid allow-list of tenant ", tenantID, " ", err)
+	}
+	entry.store.SignedWrites.Store(aTenant.SignedWrites)
+	entry.store.ReplayWatermark.Store(aTenant.ReplayWatermark)
+	entry.store.VisitorStream.Store(aTenant.VisitorStream)
+	entry.expires = time.Now().Add(hostCacheTTL)
+	return entry.handler
+}
+
+// Warm build the routes of every tenant and cache their host names ahead of
+// their first request, return the count of tenants
+func (instance *Router) Warm() (int, error) {
+	aTenants, err := instance.useCase.GetAllTenant()
+	if err != nil {
+		return 0, err
+	}
+	for _, aTenant := range *aTenants {
+		instance.handler(aTenant.ID)
+		instance.mu.Lock()
+		for _, hostName := range aTenant.HostNames {
+			instance.hosts[strings.ToLower(hostName)] = hostEntry{tenantID: aTenant.ID, expires: time.Now().Add(hostCacheTTL)}
+		}
+		instance.mu.Unlock()
+	}
+	return len(*aTenants), nil
+}
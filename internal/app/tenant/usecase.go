@@ -0,0 +1,193 @@
+package tenant
+
+import (
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/pkg/ipallow"
+)
+
+var (
+	// ErrInvalidTenantID tenant id is used in database names and the keys of the key store
+	ErrInvalidTenantID = errors.New("tenant id must be 2-32 lowercase letters, digits or dashes")
+	// ErrTenantExists ...
+	ErrTenantExists = errors.New("this tenant already exists")
+	// ErrHostNameExists ...
+	ErrHostNameExists = errors.New("host name already used by another tenant")
+	// ErrInvalidCIDR ...
+	ErrInvalidCIDR = errors.New("invalid cidr in allow-list")
+	// ErrConfirmIPRequired a non empty allow-list must be confirmed against an address that keeps access
+	ErrConfirmIPRequired = errors.New("confirm_ip is required unless force is set")
+	// ErrLockout allow-list would lock out the confirmed address
+	ErrLockout = errors.New("allow-list does not contain confirm_ip")
+)
+
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,31}$`)
+
+// UseCase ...
+type UseCase interface {
+	ProvisionTenant(aTenant *tenant) error
+	GetTenant(tenantID string, aTenant *tenant) error
+	GetTenantByHostName(hostName string, aTenant *tenant) error
+	GetAllTenant() (*tenants, error)
+	RotateKey(tenantID string, aTenant *tenant) error
+	UpdateAllowList(tenantID string, cidrs []string, confirmIP string, force bool, aTenant *tenant) error
+	UpdateSignedWrites(tenantID string, enabled bool, aTenant *tenant) error
+	UpdateReplayWatermark(tenantID string, enabled bool, aTenant *tenant) error
+	UpdateVisitorStream(tenantID string, enabled bool, aTenant *tenant) error
+}
+
+type useCase struct {
+	repo Repository
+}
+
+// NewUseCase ...
+func NewUseCase(store *db.Store) UseCase {
+	return &useCase{
+		repo: NewRepository(store),
+	}
+}
+
+// ProvisionTenant validate and insert tenant then create collections of its database
+func (instance *useCase) ProvisionTenant(aTenant *tenant) error {
+	if !tenantIDPattern.MatchString(aTenant.ID) {
+		return ErrInvalidTenantID
+	}
+	for i, hostName := range aTenant.HostNames {
+		aTenant.HostNames[i] = strings.ToLower(strings.TrimSpace(hostName))
+	}
+
+	count, err := instance.repo.FindTenant(aTenant.ID)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrTenantExists
+	}
+
+	if len(aTenant.HostNames) > 0 {
+		count, err = instance.repo.FindHostName(aTenant.HostNames)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrHostNameExists
+		}
+	}
+
+	err = db.Migrate(db.TenantStore(aTenant.ID, 1).Mongo)
+	if err != nil {
+		return err
+	}
+
+	createdAt := time.Now().Format("2006-01-02, 15:04:05")
+	aTenant.KeyVersion = 1
+	aTenant.CreatedAt = createdAt
+	aTenant.UpdatedAt = createdAt
+	err = instance.repo.InsertTenant(*aTenant)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *useCase) GetTenant(tenantID string, aTenant *tenant) error {
+	err := instance.repo.GetTenant(tenantID, aTenant)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *useCase) GetTenantByHostName(hostName string, aTenant *tenant) error {
+	err := instance.repo.GetTenantByHostName(hostName, aTenant)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *useCase) GetAllTenant() (*tenants, error) {
+	tenants, err := instance.repo.GetAllTenant()
+	if err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
+
+// RotateKey switch tenant to a new data key version, older versions stay readable
+func (instance *useCase) RotateKey(tenantID string, aTenant *tenant) error {
+	updatedAt := time.Now().Format("2006-01-02, 15:04:05")
+	err := instance.repo.IncrementKeyVersion(tenantID, updatedAt, aTenant)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateAllowList replace allowed networks of tenant. To prevent lockout a non
+// empty list must contain confirmIP, an address known to need access, unless force is set.
+func (instance *useCase) UpdateAllowList(tenantID string, cidrs []string, confirmIP string, force bool, aTenant *tenant) error {
+	nets, err := ipallow.Parse(cidrs)
+	if err != nil {
+		return ErrInvalidCIDR
+	}
+
+	if len(nets) > 0 && !force {
+		if confirmIP == "" {
+			return ErrConfirmIPRequired
+		}
+		if !ipallow.Contains(nets, net.ParseIP(confirmIP)) {
+			return ErrLockout
+		}
+	}
+
+	normalized := make([]string, 0, len(nets))
+	for _, ipNet := range nets {
+		normalized = append(normalized, ipNet.String())
+	}
+
+	updatedAt := time.Now().Format("2006-01-02, 15:04:05")
+	err = instance.repo.UpdateAllowList(tenantID, updatedAt, normalized, aTenant)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateSignedWrites require signed writes of tenant or not, applied once the
+// router reloads the tenant
+func (instance *useCase) UpdateSignedWrites(tenantID string, enabled bool, aTenant *tenant) error {
+	updatedAt := time.Now().Format("2006-01-02, 15:04:05")
+	err := instance.repo.UpdateSignedWrites(tenantID, updatedAt, enabled, aTenant)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateReplayWatermark watermark recordings of tenant or not, applied once
+// the router reloads the tenant
+func (instance *useCase) UpdateReplayWatermark(tenantID string, enabled bool, aTenant *tenant) error {
+	updatedAt := time.Now().Format("2006-01-02, 15:04:05")
+	err := instance.repo.UpdateReplayWatermark(tenantID, updatedAt, enabled, aTenant)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateVisitorStream let tenant watch the live events of identified visitors
+// or not, applied once the router reloads the tenant
+func (instance *useCase) UpdateVisitorStream(tenantID string, enabled bool, aTenant *tenant) error {
+	updatedAt := time.Now().Format("2006-01-02, 15:04:05")
+	err := instance.repo.UpdateVisitorStream(tenantID, updatedAt, enabled, aTenant)
+	if err != nil {
+		return err
+	}
+	return nil
+}
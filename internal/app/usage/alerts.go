@@ -0,0 +1,24 @@
+package usage
+
+import (
+	"time"
+
+	"analytics-api/db"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RunAlerts send the quota alerts of store every interval, until the process exits
+func RunAlerts(store *db.Store, notifier Notifier, interval time.Duration) {
+	useCase := NewUseCase(store)
+	for range time.Tick(interval) {
+		sent, err := useCase.SendAlerts(notifier)
+		if err != nil {
+			logrus.Error("send usage alerts error ", err)
+			continue
+		}
+		if sent > 0 {
+			logrus.Info("sent usage alerts ", sent)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package usage
+
+import (
+	"net/http"
+
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/pkg/cursor"
+	"analytics-api/internal/pkg/middleware"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type httpDelivery struct {
+	usageUseCase UseCase
+	authUsecase  auth.UseCase
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	usageRoutes := r.Group("usage")
+	{
+		usageRoutes.GET("/:website_id", middleware.JWTMiddleware(), instance.GetReport)
+	}
+}
+
+// GetReport monthly events of a website with its overage, the current month
+// by default and at most the last 12
+func (instance *httpDelivery) GetReport(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	months := cursor.Limit(c.Query("months"), 1, 12)
+	aReport, err := instance.usageUseCase.GetReport(userID, c.Param("website_id"), months)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, aReport)
+	case mongo.ErrNoDocuments:
+		c.JSON(http.StatusNotFound, gin.H{"error": "this website not exists"})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get usage failed"})
+	}
+}
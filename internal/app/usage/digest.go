@@ -0,0 +1,73 @@
+package usage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"analytics-api/internal/pkg/push"
+)
+
+// DigestWindow how long alerts of a user are held for others to join them,
+// so an outage pushing many websites over a threshold sends one notification
+const DigestWindow = 5 * time.Minute
+
+// digestListed websites named in a digest, the others are counted
+const digestListed = 3
+
+// alert thresholds of a website to notify its owner of
+type alert struct {
+	Month      month
+	URL        string
+	Thresholds []int
+	Highest    int
+}
+
+// digestDue whether the alerts of months, all of one user, are sent at now.
+// Months alerted before alerts were held have no AlertedAt and are due
+func digestDue(months []month, now time.Time) bool {
+	for _, aMonth := range months {
+		if now.Sub(aMonth.AlertedAt) >= DigestWindow {
+			return true
+		}
+	}
+	return false
+}
+
+// digestNotification one notification summing up alerts of several websites,
+// the most used first
+func digestNotification(alerts []alert) push.Notification {
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return alerts[i].Highest > alerts[j].Highest
+	})
+
+	usedUp := 0
+	var named, websiteIDs []string
+	for i, anAlert := range alerts {
+		if anAlert.Highest >= 100 {
+			usedUp++
+		}
+		if i < digestListed {
+			named = append(named, fmt.Sprintf("%s %d%%", anAlert.URL, anAlert.Highest))
+		}
+		websiteIDs = append(websiteIDs, anAlert.Month.WebsiteID)
+	}
+	body := strings.Join(named, ", ")
+	if len(alerts) > digestListed {
+		body += fmt.Sprintf(" and %d more", len(alerts)-digestListed)
+	}
+
+	title := fmt.Sprintf("%d websites crossed an event quota threshold", len(alerts))
+	if usedUp > 0 {
+		title = fmt.Sprintf("%d websites crossed an event quota threshold, %d used it up", len(alerts), usedUp)
+	}
+	return push.Notification{
+		Title: title,
+		Body:  body,
+		Data: map[string]string{
+			"website_ids": strings.Join(websiteIDs, ","),
+			"month":       alerts[0].Month.Month.Format(monthLayout),
+		},
+	}
+}
@@ -0,0 +1,33 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/db/dbtest"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestLitePendingAlert(t *testing.T) {
+	mongoStore, liteStore := dbtest.Stores(t)
+	collection := configs.MongoDB.UsageCollection
+	configs.MongoDB.UsageCollection = "usage"
+	t.Cleanup(func() { configs.MongoDB.UsageCollection = collection })
+
+	aMonth, _ := time.Parse(time.RFC3339, "2024-03-01T00:00:00Z")
+	dbtest.Insert(t, configs.MongoDB.UsageCollection, []interface{}{
+		bson.M{"user_id": "u1", "website_id": "w1", "month": aMonth, "events": 850, "alerts": []int{80}},
+		bson.M{"user_id": "u1", "website_id": "w2", "month": aMonth, "events": 1200, "alerts": []int{80, 100}, "notified": []int{80}},
+		bson.M{"user_id": "u1", "website_id": "w3", "month": aMonth, "events": 900, "alerts": []int{80}, "notified": []int{80}},
+		bson.M{"user_id": "u2", "website_id": "w4", "month": aMonth, "events": 10},
+		bson.M{"user_id": "u2", "website_id": "w5", "month": aMonth, "events": 10, "alerts": nil, "notified": []int{80}},
+		bson.M{"user_id": "u2", "website_id": "w6", "month": aMonth.AddDate(0, -1, 0), "events": 990, "alerts": []int{80, 100, 100}, "notified": nil},
+	}, mongoStore, liteStore)
+
+	dbtest.Compare(t, mongoStore, liteStore, true, func(store *db.Store) (interface{}, error) {
+		return (&repository{store: store}).GetPendingAlert()
+	})
+}
@@ -0,0 +1,50 @@
+package usage
+
+import "time"
+
+const (
+	// PolicyDrop refuse events past the quota
+	PolicyDrop = "drop"
+	// PolicyOverage keep all events past the quota, marked as overage
+	PolicyOverage = "overage"
+	// PolicySample keep a share of the batches past the quota, marked as
+	// overage, and drop the others
+	PolicySample = "sample"
+)
+
+// Thresholds percents of the quota the owner of a website is alerted at
+var Thresholds = []int{80, 100}
+
+// month events of a website during a UTC month, by the time batches arrived
+type month struct {
+	UserID    string    `json:"-" bson:"user_id"`
+	WebsiteID string    `json:"-" bson:"website_id"`
+	Month     time.Time `json:"month" bson:"month"`
+	// Events every event received, overage and dropped included
+	Events int64 `json:"events" bson:"events"`
+	// Overage events past the quota that were stored, Dropped those refused
+	Overage int64 `json:"overage" bson:"overage"`
+	Dropped int64 `json:"dropped" bson:"dropped"`
+	// Alerts thresholds crossed, Notified those the owner was told of
+	Alerts   []int `json:"alerts" bson:"alerts"`
+	Notified []int `json:"-" bson:"notified"`
+	// AlertedAt when the first alert not notified yet was raised
+	AlertedAt time.Time `json:"-" bson:"alerted_at,omitempty"`
+	// UsedPercent events of the quota, computed when read
+	UsedPercent float64 `json:"used_percent" bson:"-"`
+}
+
+// report usage of a website, the current month first
+type report struct {
+	Quota  int64   `json:"quota"`
+	Policy string  `json:"policy"`
+	Months []month `json:"months"`
+}
+
+// Decision what to do with a batch of events
+type Decision struct {
+	// Overage the batch holds events past the quota
+	Overage bool
+	// Drop the batch must not be stored
+	Drop bool
+}
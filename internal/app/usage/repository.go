@@ -0,0 +1,128 @@
+package usage
+
+import (
+	"context"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Repository ...
+type Repository interface {
+	IncEvents(userID, websiteID string, aMonth time.Time, count int64) (int64, error)
+	IncOutcome(userID, websiteID string, aMonth time.Time, overage, dropped int64, alerts []int) error
+	GetMonth(userID, websiteID string, from time.Time) ([]month, error)
+	GetPendingAlert() ([]month, error)
+	SetNotified(userID, websiteID string, aMonth time.Time, thresholds []int) error
+}
+
+type repository struct {
+	store *db.Store
+}
+
+// NewRepository ...
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store: store,
+	}
+}
+
+func monthFilter(userID, websiteID string, aMonth time.Time) bson.M {
+	return bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"month": aMonth},
+	}}
+}
+
+// IncEvents add count events to the month of website, returns the events of
+// the month with them
+func (instance *repository) IncEvents(userID, websiteID string, aMonth time.Time, count int64) (int64, error) {
+	usageCollection := instance.store.Mongo.Collection(configs.MongoDB.UsageCollection)
+	update := bson.M{"$inc": bson.M{"events": count}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var aMonthUsage month
+	err := usageCollection.FindOneAndUpdate(context.TODO(), monthFilter(userID, websiteID, aMonth), update, opts).Decode(&aMonthUsage)
+	if err != nil {
+		return 0, err
+	}
+	return aMonthUsage.Events, nil
+}
+
+// IncOutcome add overage and dropped events to the month of website, with
+// the thresholds it crossed
+func (instance *repository) IncOutcome(userID, websiteID string, aMonth time.Time, overage, dropped int64, alerts []int) error {
+	usageCollection := instance.store.Mongo.Collection(configs.MongoDB.UsageCollection)
+	update := bson.M{"$inc": bson.M{"overage": overage, "dropped": dropped}}
+	if len(alerts) > 0 {
+		update["$addToSet"] = bson.M{"alerts": bson.M{"$each": alerts}}
+		update["$min"] = bson.M{"alerted_at": time.Now()}
+	}
+	_, err := usageCollection.UpdateOne(context.TODO(), monthFilter(userID, websiteID, aMonth), update)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetMonth months of website since from, the latest first
+func (instance *repository) GetMonth(userID, websiteID string, from time.Time) ([]month, error) {
+	months := []month{}
+	usageCollection := instance.store.Mongo.Collection(configs.MongoDB.UsageCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"month": bson.M{"$gte": from}},
+	}}
+	opts := options.Find().SetSort(primitive.D{{Key: "month", Value: -1}})
+	cursor, err := usageCollection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &months); err != nil {
+		return nil, err
+	}
+	return months, nil
+}
+
+// GetPendingAlert months with thresholds crossed but not notified yet
+func (instance *repository) GetPendingAlert() ([]month, error) {
+	months := []month{}
+	usageCollection := instance.store.Mongo.Collection(configs.MongoDB.UsageCollection)
+	filter := bson.M{"$expr": bson.M{"$gt": []interface{}{
+		bson.M{"$size": bson.M{"$setDifference": []interface{}{
+			bson.M{"$ifNull": []interface{}{"$alerts", []int{}}},
+			bson.M{"$ifNull": []interface{}{"$notified", []int{}}},
+		}}},
+		0,
+	}}}
+	// a $match rather than a find, embedded MongoDB of lite mode filters
+	// with $expr in the process only
+	cursor, err := db.Aggregate(context.TODO(), usageCollection, []bson.M{{"$match": filter}})
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &months); err != nil {
+		return nil, err
+	}
+	return months, nil
+}
+
+// SetNotified record the owner of website was told of thresholds
+func (instance *repository) SetNotified(userID, websiteID string, aMonth time.Time, thresholds []int) error {
+	usageCollection := instance.store.Mongo.Collection(configs.MongoDB.UsageCollection)
+	update := bson.M{
+		"$addToSet": bson.M{"notified": bson.M{"$each": thresholds}},
+		"$unset":    bson.M{"alerted_at": ""},
+	}
+	_, err := usageCollection.UpdateOne(context.TODO(), monthFilter(userID, websiteID, aMonth), update)
+	if err != nil {
+		return err
+	}
+	return nil
+}
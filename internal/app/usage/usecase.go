@@ -0,0 +1,267 @@
+package usage
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/push"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const monthLayout = "2006-01"
+
+// ErrQuotaExceeded batch dropped by the overage policy
+var ErrQuotaExceeded = errors.New("monthly event quota exceeded")
+
+// Notifier push notifications to the devices of a user, the mobile usecase
+type Notifier interface {
+	Notify(userID string, notification push.Notification) (int, error)
+}
+
+// UseCase ...
+type UseCase interface {
+	Check(userID, websiteID string, count int) Decision
+	GetReport(userID, websiteID string, months int) (*report, error)
+	SendAlerts(notifier Notifier) (int, error)
+}
+
+type useCase struct {
+	repo           Repository
+	websiteUseCase website.UseCase
+}
+
+// NewUseCase ...
+func NewUseCase(store *db.Store) UseCase {
+	return &useCase{
+		repo:           NewRepository(store),
+		websiteUseCase: website.NewUseCase(store),
+	}
+}
+
+// currentMonth first instant of the UTC month of t
+func currentMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Check count a batch of count events of website in the current month and
+// decide what happens to it. A batch arriving once the quota is used up is
+// overage, dropped or sampled by the policy, of a batch crossing the quota the
+// events past it are overage. Errors are logged and the batch kept, since
+// tracking must not fail because counting does
+func (instance *useCase) Check(userID, websiteID string, count int) Decision {
+	if count == 0 {
+		return Decision{}
+	}
+	aMonth := currentMonth(time.Now())
+	after, err := instance.repo.IncEvents(userID, websiteID, aMonth, int64(count))
+	if err != nil {
+		logrus.Error("count usage error ", err)
+		return Decision{}
+	}
+	aQuota := configs.Current().Quota
+	anOutcome := decide(after-int64(count), after, aQuota.MonthlyEvents, aQuota.Policy, rand.Intn(100) < aQuota.SamplePercent)
+	if anOutcome.overage == 0 && anOutcome.dropped == 0 && len(anOutcome.alerts) == 0 {
+		return anOutcome.Decision
+	}
+	err = instance.repo.IncOutcome(userID, websiteID, aMonth, anOutcome.overage, anOutcome.dropped, anOutcome.alerts)
+	if err != nil {
+		logrus.Error("count overage error ", err)
+	}
+	return anOutcome.Decision
+}
+
+// outcome of a batch with the events of its month going from before to after
+type outcome struct {
+	Decision
+	// overage events of the batch stored past the quota, dropped those refused
+	overage int64
+	dropped int64
+	// alerts thresholds the batch crossed
+	alerts []int
+}
+
+// decide what happens to a batch taking the events of its month from before
+// to after under quota and policy. A batch past the quota is overage, dropped
+// by drop and kept by sample when sampled. A batch crossing the quota is kept
+// whatever the policy, marked overage, and only its events past the quota are
+// counted as such
+func decide(before, after, quota int64, policy string, sampled bool) outcome {
+	var anOutcome outcome
+	if quota == 0 {
+		return anOutcome
+	}
+	for _, threshold := range Thresholds {
+		limit := quota * int64(threshold) / 100
+		if before < limit && after >= limit {
+			anOutcome.alerts = append(anOutcome.alerts, threshold)
+		}
+	}
+	if after <= quota {
+		return anOutcome
+	}
+
+	anOutcome.Overage = true
+	if before >= quota {
+		switch policy {
+		case PolicyDrop:
+			anOutcome.Drop = true
+		case PolicySample:
+			anOutcome.Drop = !sampled
+		}
+	}
+	if anOutcome.Drop {
+		anOutcome.dropped = after - before
+		return anOutcome
+	}
+	anOutcome.overage = after - max(before, quota)
+	return anOutcome
+}
+
+// GetReport usage of website over the last months, the current one included
+func (instance *useCase) GetReport(userID, websiteID string, months int) (*report, error) {
+	count, err := instance.websiteUseCase.FindWebsiteByID(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	from := currentMonth(time.Now()).AddDate(0, -(months - 1), 0)
+	listMonth, err := instance.repo.GetMonth(userID, websiteID, from)
+	if err != nil {
+		return nil, err
+	}
+
+	aQuota := configs.Current().Quota
+	aReport := &report{
+		Quota:  aQuota.MonthlyEvents,
+		Policy: aQuota.Policy,
+		Months: listMonth,
+	}
+	for i := range aReport.Months {
+		aMonth := &aReport.Months[i]
+		if aMonth.Alerts == nil {
+			aMonth.Alerts = []int{}
+		}
+		if aReport.Quota > 0 {
+			aMonth.UsedPercent = math.Round(float64(aMonth.Events)*1000/float64(aReport.Quota)) / 10
+		}
+	}
+	return aReport, nil
+}
+
+// SendAlerts tell the owners of websites of the thresholds crossed since the
+// last run, the highest for each month. Alerts of a user are held until the
+// first of them is DigestWindow old and then sent together, in a digest when
+// there are several. Returns the number of alerts sent
+func (instance *useCase) SendAlerts(notifier Notifier) (int, error) {
+	pending, err := instance.repo.GetPendingAlert()
+	if err != nil {
+		return 0, err
+	}
+
+	var users []string
+	byUser := map[string][]month{}
+	for _, aMonth := range pending {
+		if _, ok := byUser[aMonth.UserID]; !ok {
+			users = append(users, aMonth.UserID)
+		}
+		byUser[aMonth.UserID] = append(byUser[aMonth.UserID], aMonth)
+	}
+
+	sent := 0
+	now := time.Now()
+	for _, userID := range users {
+		months := byUser[userID]
+		if !digestDue(months, now) {
+			continue
+		}
+
+		var alerts []alert
+		for _, aMonth := range months {
+			anAlert := pendingAlert(aMonth)
+			anAlert.URL, err = instance.websiteUseCase.GetURL(aMonth.UserID, aMonth.WebsiteID)
+			if err != nil {
+				logrus.Error("get website of usage alert error ", err)
+				continue
+			}
+			alerts = append(alerts, anAlert)
+		}
+		if len(alerts) == 0 {
+			continue
+		}
+
+		notification := alertNotification(alerts[0].URL, alerts[0].Month, alerts[0].Highest)
+		if len(alerts) > 1 {
+			notification = digestNotification(alerts)
+		}
+		_, err = notifier.Notify(userID, notification)
+		if err != nil {
+			logrus.Error("send usage alert error ", err)
+			continue
+		}
+		for _, anAlert := range alerts {
+			err = instance.repo.SetNotified(userID, anAlert.Month.WebsiteID, anAlert.Month.Month, anAlert.Thresholds)
+			if err != nil {
+				return sent, err
+			}
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// pendingAlert thresholds of aMonth not notified yet and the highest of them
+func pendingAlert(aMonth month) alert {
+	notified := map[int]bool{}
+	for _, threshold := range aMonth.Notified {
+		notified[threshold] = true
+	}
+	anAlert := alert{Month: aMonth}
+	for _, threshold := range aMonth.Alerts {
+		if notified[threshold] {
+			continue
+		}
+		anAlert.Thresholds = append(anAlert.Thresholds, threshold)
+		if threshold > anAlert.Highest {
+			anAlert.Highest = threshold
+		}
+	}
+	return anAlert
+}
+
+// alertNotification notification of website at url reaching threshold
+// percent of its quota during aMonth
+func alertNotification(url string, aMonth month, threshold int) push.Notification {
+	title := fmt.Sprintf("%d%% of the event quota used", threshold)
+	if threshold >= 100 {
+		title = "Event quota used up"
+	}
+	aQuota := configs.Current().Quota
+	after := "events past it are kept as overage"
+	switch aQuota.Policy {
+	case PolicyDrop:
+		after = "events past it are dropped"
+	case PolicySample:
+		after = "only a sample of the events past it is kept"
+	}
+	return push.Notification{
+		Title: title,
+		Body:  fmt.Sprintf("%s received %d of its %d events this month, %s", url, aMonth.Events, aQuota.MonthlyEvents, after),
+		Data: map[string]string{
+			"website_id": aMonth.WebsiteID,
+			"month":      aMonth.Month.Format(monthLayout),
+			"threshold":  fmt.Sprint(threshold),
+		},
+	}
+}
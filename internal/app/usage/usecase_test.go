@@ -0,0 +1,38 @@
+package usage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecide(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  int64
+		after   int64
+		quota   int64
+		policy  string
+		sampled bool
+		want    outcome
+	}{
+		{name: "should keep everything without a quota", before: 900, after: 1200, quota: 0, policy: PolicyDrop, want: outcome{}},
+		{name: "should keep a batch within the quota", before: 10, after: 20, quota: 1000, policy: PolicyDrop, want: outcome{}},
+		{name: "should alert at 80%", before: 790, after: 810, quota: 1000, policy: PolicyOverage, want: outcome{alerts: []int{80}}},
+		{name: "should alert at 100% on reaching the quota exactly", before: 990, after: 1000, quota: 1000, policy: PolicyDrop, want: outcome{alerts: []int{100}}},
+		{name: "should count the part of a batch past the quota as overage", before: 990, after: 1010, quota: 1000, policy: PolicyOverage, want: outcome{Decision: Decision{Overage: true}, overage: 10, alerts: []int{100}}},
+		{name: "should keep a batch crossing the quota under drop", before: 990, after: 1010, quota: 1000, policy: PolicyDrop, want: outcome{Decision: Decision{Overage: true}, overage: 10, alerts: []int{100}}},
+		{name: "should keep a batch crossing the quota under sample", before: 990, after: 1010, quota: 1000, policy: PolicySample, want: outcome{Decision: Decision{Overage: true}, overage: 10, alerts: []int{100}}},
+		{name: "should cross both thresholds in one batch", before: 700, after: 1100, quota: 1000, policy: PolicyOverage, want: outcome{Decision: Decision{Overage: true}, overage: 100, alerts: []int{80, 100}}},
+		{name: "should count a whole batch past the quota as overage", before: 1000, after: 1020, quota: 1000, policy: PolicyOverage, want: outcome{Decision: Decision{Overage: true}, overage: 20}},
+		{name: "should drop a whole batch past the quota under drop", before: 1000, after: 1020, quota: 1000, policy: PolicyDrop, want: outcome{Decision: Decision{Overage: true, Drop: true}, dropped: 20}},
+		{name: "should keep a sampled batch past the quota", before: 1500, after: 1520, quota: 1000, policy: PolicySample, sampled: true, want: outcome{Decision: Decision{Overage: true}, overage: 20}},
+		{name: "should drop a batch past the quota not sampled", before: 1500, after: 1520, quota: 1000, policy: PolicySample, want: outcome{Decision: Decision{Overage: true, Drop: true}, dropped: 20}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decide(tt.before, tt.after, tt.quota, tt.policy, tt.sampled); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decide() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
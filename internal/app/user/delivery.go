@@ -1,6 +1,7 @@
 package user
 
 import (
+	"analytics-api/db"
 	"github.com/gin-gonic/gin"
 
 	"analytics-api/internal/app/auth"
@@ -20,12 +21,33 @@ type HTTPDelivery interface {
 	GetUser(c *gin.Context)
 	ShowDetailsUserPage(c *gin.Context)
 	UpdateUser(c *gin.Context)
+	SigninTwoFactor(c *gin.Context)
+	EnrollTwoFactor(c *gin.Context)
+	ConfirmTwoFactor(c *gin.Context)
+	DisableTwoFactor(c *gin.Context)
+	RegenerateRecoveryCodes(c *gin.Context)
+	CreateOrg(c *gin.Context)
+	ListOrgs(c *gin.Context)
+	ListMembers(c *gin.Context)
+	UpdateMember(c *gin.Context)
+	RemoveMember(c *gin.Context)
+	UpdateMembers(c *gin.Context)
+	Invite(c *gin.Context)
+	CancelInvitation(c *gin.Context)
+	ListInvitations(c *gin.Context)
+	AcceptInvitation(c *gin.Context)
+	DeclineInvitation(c *gin.Context)
+	SwitchOrg(c *gin.Context)
+	RequestEmailChange(c *gin.Context)
+	ShowConfirmEmailPage(c *gin.Context)
+	ConfirmEmailChange(c *gin.Context)
 }
 
 // NewHTTPDelivery ...
-func NewHTTPDelivery() HTTPDelivery {
+func NewHTTPDelivery(store *db.Store) HTTPDelivery {
 	return &httpDelivery{
-		userUseCase: NewUseCase(),
-		authUsecase: auth.NewUseCase(),
+		store:       store,
+		userUseCase: NewUseCase(store),
+		authUsecase: auth.NewUseCase(store),
 	}
 }
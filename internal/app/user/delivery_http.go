@@ -1,9 +1,12 @@
 package user
 
 import (
+	"analytics-api/db"
 	"analytics-api/internal/app/auth"
+	"analytics-api/internal/pkg/httperr"
+	req "analytics-api/internal/pkg/request"
 	"analytics-api/internal/pkg/security"
-	str "analytics-api/internal/pkg/string"
+	"analytics-api/internal/pkg/siem"
 	"net/http"
 	"time"
 
@@ -14,27 +17,31 @@ import (
 )
 
 type httpDelivery struct {
+	store       *db.Store
 	userUseCase UseCase
 	authUsecase auth.UseCase
 }
 
-// var validate = validator.New()
-
-// type RequestSignUp struct {
-// 	Email    string `json:"email" validate:"required,email"`
-// 	FullName string `json:"full_name" validate:"required,min=2,max=100"`
-// 	Password string `json:"password" validate:"required,min=8"`
-// }
+// RequestSignUp ...
+type RequestSignUp struct {
+	Email    string `form:"email" validate:"required,email"`
+	FullName string `form:"fullname" validate:"required,min=2,max=100"`
+	Password string `form:"password" validate:"required,min=8"`
+}
 
-// type RequestSignIn struct {
-// 	Email    string `json:"email" validate:"required,email"`
-// 	Password string `json:"password" validate:"required,min=8"`
-// }
+// RequestSignIn the length of passwords is not checked, accounts made before
+// it was required still sign in
+type RequestSignIn struct {
+	Email    string `form:"email" validate:"required,email"`
+	Password string `form:"password" validate:"required"`
+}
 
-// type RequestUpdateUser struct {
-// 	FullName string `json:"full_name"`
-// 	Password string `json:"password"`
-// }
+// RequestUpdateUser empty fields are left as they are
+type RequestUpdateUser struct {
+	FullName        string `form:"fullname" validate:"omitempty,min=2,max=100"`
+	Password        string `form:"password" validate:"omitempty,min=8"`
+	ConfirmPassword string `form:"confirmPassword"`
+}
 
 // InitRoutes ...
 func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
@@ -44,6 +51,7 @@ func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
 
 	r.GET("/signin", instance.ShowSigninPage)
 	r.POST("/signin", instance.Signin)
+	r.POST("/signin/2fa", instance.SigninTwoFactor)
 
 	r.GET("/logout", middleware.JWTMiddleware(), instance.Logout)
 
@@ -52,6 +60,33 @@ func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
 		profileRoutes.GET("/details", middleware.JWTMiddleware(), instance.GetUser)
 
 		profileRoutes.POST("/update", middleware.JWTMiddleware(), instance.UpdateUser)
+
+		profileRoutes.PUT("/locale", middleware.JWTMiddleware(), instance.UpdateLocale)
+
+		profileRoutes.POST("/email", middleware.JWTMiddleware(), instance.RequestEmailChange)
+		profileRoutes.GET("/email/confirm", instance.ShowConfirmEmailPage)
+		profileRoutes.POST("/email/confirm", instance.ConfirmEmailChange)
+
+		profileRoutes.POST("/2fa/enroll", middleware.JWTMiddleware(), instance.EnrollTwoFactor)
+		profileRoutes.POST("/2fa/confirm", middleware.JWTMiddleware(), instance.ConfirmTwoFactor)
+		profileRoutes.POST("/2fa/disable", middleware.JWTMiddleware(), instance.DisableTwoFactor)
+		profileRoutes.POST("/2fa/recovery-codes", middleware.JWTMiddleware(), instance.RegenerateRecoveryCodes)
+	}
+
+	orgRoutes := r.Group("orgs")
+	{
+		orgRoutes.POST("", middleware.JWTMiddleware(), instance.CreateOrg)
+		orgRoutes.GET("", middleware.JWTMiddleware(), instance.ListOrgs)
+		orgRoutes.POST("/switch", middleware.JWTMiddleware(), instance.SwitchOrg)
+		orgRoutes.GET("/invitations", middleware.JWTMiddleware(), instance.ListInvitations)
+		orgRoutes.POST("/invitations/:invitation_id/accept", middleware.JWTMiddleware(), instance.AcceptInvitation)
+		orgRoutes.POST("/invitations/:invitation_id/decline", middleware.JWTMiddleware(), instance.DeclineInvitation)
+		orgRoutes.GET("/:org_id/members", middleware.JWTMiddleware(), instance.ListMembers)
+		orgRoutes.PATCH("/:org_id/members", middleware.JWTMiddleware(), instance.UpdateMembers)
+		orgRoutes.PUT("/:org_id/members/:user_id", middleware.JWTMiddleware(), instance.UpdateMember)
+		orgRoutes.DELETE("/:org_id/members/:user_id", middleware.JWTMiddleware(), instance.RemoveMember)
+		orgRoutes.POST("/:org_id/invitations", middleware.JWTMiddleware(), instance.Invite)
+		orgRoutes.DELETE("/:org_id/invitations/:invitation_id", middleware.JWTMiddleware(), instance.CancelInvitation)
 	}
 }
 
@@ -64,21 +99,14 @@ func (instance *httpDelivery) ShowSignupPage(c *gin.Context) {
 }
 
 func (instance *httpDelivery) SignUp(c *gin.Context) {
-	// var request RequestSignUp
-	// if err := c.ShouldBindJSON(&request); err != nil {
-	// 	c.JSON(http.StatusBadRequest, gin.H{"msg": err})
-	// 	return
-	// }
-
-	// validationErr := validate.Struct(request)
-	// if validationErr != nil {
-	// 	c.JSON(http.StatusBadRequest, gin.H{"msg": validationErr.Error()})
-	// 	return
-	// }
-
-	email := c.PostForm("email")
-	fullname := c.PostForm("fullname")
-	password := c.PostForm("password")
+	request, err := req.BindFormAndValidate[RequestSignUp](c)
+	if err != nil {
+		req.BadRequest(c, "invalid sign up", err)
+		return
+	}
+	email := request.Email
+	fullname := request.FullName
+	password := request.Password
 
 	count, err := instance.userUseCase.FindUser(email)
 	if err != nil {
@@ -87,33 +115,16 @@ func (instance *httpDelivery) SignUp(c *gin.Context) {
 	}
 
 	if count > 0 {
-		c.JSON(http.StatusConflict, gin.H{"msg": "this email already exists"})
+		instance.publishAuth(c, ActionSignUp, siem.OutcomeFailure, "", email, CodeEmailExists)
+		httperr.Abort(c, http.StatusConflict, CodeEmailExists, "this email already exists")
 		return
 	} else {
-		hash, err := security.HashPassword(password)
-		if err != nil {
-			c.HTML(http.StatusInternalServerError, "500.html", gin.H{})
-			return
-		}
-
-		userID := str.GetMD5Hash(email)
-
-		createdAt := time.Now().Format("2006-01-02, 15:04:05")
-
-		anUser := user{
-			ID:        userID,
-			FullName:  fullname,
-			Email:     email,
-			Password:  hash,
-			CreatedAt: createdAt,
-			UpdatedAt: createdAt,
-		}
-
-		insertErr := instance.userUseCase.InsertUser(anUser)
-		if insertErr != nil {
+		userID, createErr := instance.userUseCase.CreateUser(email, fullname, password)
+		if createErr != nil {
 			c.HTML(http.StatusInternalServerError, "500.html", gin.H{})
 			return
 		}
+		instance.publishAuth(c, ActionSignUp, siem.OutcomeSuccess, userID, email, "")
 
 		c.Redirect(http.StatusMovedPermanently, "/signin")
 	}
@@ -125,24 +136,34 @@ func (instance *httpDelivery) ShowSigninPage(c *gin.Context) {
 
 func (instance *httpDelivery) Signin(c *gin.Context) {
 	var anUser user
-	// var request RequestSignIn
-	// if err := c.ShouldBindJSON(&request); err != nil {
-	// 	c.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
-	// 	return
-	// }
-
-	// validationErr := validate.Struct(request)
-	// if validationErr != nil {
-	// 	c.JSON(http.StatusBadRequest, gin.H{"msg": validationErr.Error()})
-	// 	return
-	// }
-
-	email := c.PostForm("email")
-	password := c.PostForm("password")
-
-	err := instance.userUseCase.GetUserByEmail(email, &anUser)
+	request, err := req.BindFormAndValidate[RequestSignIn](c)
+	if err != nil {
+		req.BadRequest(c, "invalid sign in", err)
+		return
+	}
+	email := request.Email
+	password := request.Password
+	// forwarding headers count from TRUSTED_PROXIES only, a client cannot
+	// pick a new address for every try
+	ip := c.ClientIP()
+
+	wait, err := instance.authUsecase.CheckSignIn(ip, email)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"msg": "email not exists"})
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "sign in failed")
+		return
+	}
+	if wait > 0 {
+		instance.publishAuth(c, ActionSignIn, siem.OutcomeFailure, "", email, auth.CodeSignInLocked)
+		auth.AbortSignInLocked(c, wait)
+		return
+	}
+
+	err = instance.userUseCase.GetUserByEmail(email, &anUser)
+	if err != nil {
+		instance.failSignIn(c, ip, email)
+		instance.publishAuth(c, ActionSignIn, siem.OutcomeFailure, "", email, CodeEmailNotFound)
+		httperr.Abort(c, http.StatusNotFound, CodeEmailNotFound, "email not exists")
 		// c.HTML(http.StatusNotFound, "404.html", gin.H{})
 		return
 	}
@@ -150,12 +171,27 @@ func (instance *httpDelivery) Signin(c *gin.Context) {
 	// check password
 	isTheSame := security.DoPasswordsMatch(anUser.Password, password)
 	if !isTheSame {
-		c.JSON(http.StatusUnauthorized, gin.H{"msg": "passowrd is incorrect"})
+		instance.failSignIn(c, ip, email)
+		instance.publishAuth(c, ActionSignIn, siem.OutcomeFailure, anUser.ID, email, CodeWrongPassword)
+		httperr.Abort(c, http.StatusUnauthorized, CodeWrongPassword, "passowrd is incorrect")
+		return
+	}
+
+	// the token is only issued once the second factor is given too, whose
+	// failures count against the account as well
+	if anUser.TwoFactor {
+		preAuthToken, err := instance.authUsecase.InsertPreAuth(anUser.ID)
+		if err != nil {
+			logrus.Error("Insert pre auth error ", err)
+			c.HTML(http.StatusInternalServerError, "500.html", gin.H{})
+			return
+		}
+		auth.AskSecondFactor(c, preAuthToken)
 		return
 	}
 
 	// create token
-	token, err := security.CreateToken(anUser.ID)
+	token, err := security.CreateToken(anUser.ID, instance.store.TenantID)
 	if err != nil {
 		logrus.Error("Create token error ", err)
 		c.HTML(http.StatusInternalServerError, "500.html", gin.H{})
@@ -169,47 +205,58 @@ func (instance *httpDelivery) Signin(c *gin.Context) {
 		return
 	}
 
+	instance.clearSignIn(c, email)
+	instance.publishAuth(c, ActionSignIn, siem.OutcomeSuccess, anUser.ID, email, "")
 	anUser.AccessToken = token.AccessToken
-	// user.RefreshToken = token.RefreshToken
+	anUser.RefreshToken = token.RefreshToken
 
-	// create cookie for client
-	c.SetCookie("access_token", token.AccessToken, 86400, "/", "theodoiweb.fly.dev", false, true)
-	// c.SetCookie("refresh_token", token.RefreshToken, 86400, "/", "localhost", false, true)
+	// create cookies for client
+	auth.SetCookies(c, token)
 
 	// c.JSON(http.StatusOK, user)
 	c.Redirect(http.StatusMovedPermanently, "/profile/details")
 }
 
+// failSignIn count a failed sign in of email from ip towards their lockout,
+// errors are logged since the sign in is refused anyway
+func (instance *httpDelivery) failSignIn(c *gin.Context, ip, email string) {
+	if err := instance.authUsecase.FailSignIn(ip, email); err != nil {
+		logrus.Error(c, err)
+	}
+}
+
+// clearSignIn forget the failed sign ins of the account of email, errors are
+// logged since the user is signed in
+func (instance *httpDelivery) clearSignIn(c *gin.Context, email string) {
+	if err := instance.authUsecase.ClearSignIn(email); err != nil {
+		logrus.Error(c, err)
+	}
+}
+
 func (instance *httpDelivery) Logout(c *gin.Context) {
 	accessToken, err := security.ExtractAccessTokenMetadata(c.Request)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"msg": "extract access token failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract access token failed")
 		return
 	}
 
 	delAtErr := instance.authUsecase.DeleteAccessToken(accessToken.AccessUUID)
 	if delAtErr != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"msg": "error occured while del access token"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "error occured while del access token")
 		return
 	}
+	// the refresh tokens of the sign in go too, tokens issued before
+	// refresh tokens have no family
+	if accessToken.FamilyID != "" {
+		delRtErr := instance.authUsecase.RevokeFamily(accessToken.FamilyID)
+		if delRtErr != nil {
+			httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "error occured while del refresh token")
+			return
+		}
+	}
+	instance.publishAuth(c, ActionLogout, siem.OutcomeSuccess, accessToken.UserID, "", "")
 
-	// refreshToken, err := security.ExtractRefreshTokenMetadata(c.Request)
-	// if err != nil {
-	// 	c.JSON(http.StatusUnauthorized, gin.H{"msg": "extract refresh token failed"})
-	// 	return
-	// }
-
-	// logrus.Info("delete refresh token")
-	// delRtErr := instance.authUsecase.DeleteRefreshToken(refreshToken.RefreshUUID)
-	// if delRtErr != nil {
-	// 	c.JSON(http.StatusUnauthorized, gin.H{"msg": "error occured while del refresh token"})
-	// 	return
-	// }
-
-	c.SetCookie("access_token", "", -1, "", "", false, true)
-
-	// logrus.Info("delete refresh from cookie token")
-	// c.SetCookie("refresh_token", "", -1, "", "", false, true)
+	auth.ClearCookies(c)
 
 	// c.JSON(http.StatusOK, gin.H{})
 	c.Redirect(http.StatusMovedPermanently, "/signin")
@@ -219,13 +266,13 @@ func (instance *httpDelivery) GetUser(c *gin.Context) {
 	var anUser user
 	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
 		return
 	}
 
-	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	userID, err := instance.authUsecase.GetUser(tokenAuth)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
 		return
 	}
 
@@ -241,19 +288,24 @@ func (instance *httpDelivery) GetUser(c *gin.Context) {
 }
 
 func (instance *httpDelivery) UpdateUser(c *gin.Context) {
-	fullName := c.PostForm("fullname")
-	password := c.PostForm("password")
-	confirmPassword := c.PostForm("confirmPassword")
+	request, err := req.BindFormAndValidate[RequestUpdateUser](c)
+	if err != nil {
+		req.BadRequest(c, "invalid profile", err)
+		return
+	}
+	fullName := request.FullName
+	password := request.Password
+	confirmPassword := request.ConfirmPassword
 
 	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
 		return
 	}
 
-	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	userID, err := instance.authUsecase.GetUser(tokenAuth)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
 		return
 	}
 
@@ -312,3 +364,35 @@ func (instance *httpDelivery) UpdateUser(c *gin.Context) {
 
 	c.Redirect(http.StatusMovedPermanently, "/profile/details")
 }
+
+// UpdateLocale set the language of messages for the user
+func (instance *httpDelivery) UpdateLocale(c *gin.Context) {
+	request, err := req.BindAndValidate[RequestLocale](c)
+	if err != nil {
+		req.BadRequest(c, "invalid locale", err)
+		return
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetUser(tokenAuth)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	err = instance.userUseCase.UpdateLocale(userID, request.Locale)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{"locale": request.Locale})
+	case ErrUnsupportedLocale:
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "update locale failed")
+	}
+}
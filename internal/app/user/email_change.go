@@ -0,0 +1,308 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/pkg/httperr"
+	"analytics-api/internal/pkg/i18n"
+	"analytics-api/internal/pkg/mail"
+	req "analytics-api/internal/pkg/request"
+	"analytics-api/internal/pkg/security"
+	"analytics-api/internal/pkg/siem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// EmailChangeTTL time the link confirming a new email works
+const EmailChangeTTL = 24 * time.Hour
+
+// Codes of email changes
+const (
+	CodeEmailChangeInvalid = "email_change_invalid"
+	CodeSameEmail          = "same_email"
+)
+
+var (
+	// ErrEmailChangeInvalid ...
+	ErrEmailChangeInvalid = errors.New("this link expired, was already used or a newer email was asked for")
+	// ErrSameEmail ...
+	ErrSameEmail = errors.New("this is the email of the account already")
+	// ErrEmailExists ...
+	ErrEmailExists = errors.New("this email already exists")
+	// ErrWrongPassword ...
+	ErrWrongPassword = errors.New("password is incorrect")
+)
+
+// RequestEmailChange new email, with the current password for accounts
+// having one
+type RequestEmailChange struct {
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password"`
+}
+
+// RequestConfirmEmailChange token of the link emailed to the new email
+type RequestConfirmEmailChange struct {
+	Token string `json:"token" form:"token" validate:"required,max=100"`
+}
+
+// RequestEmailChange token of the link confirming email as the new email of
+// user, with the current email. Asking again replaces the pending change
+func (instance *useCase) RequestEmailChange(userID, email, password string) (string, string, error) {
+	var anUser user
+	if err := instance.repo.GetUserByID(userID, &anUser); err != nil {
+		return "", "", err
+	}
+	// accounts signed up with an identity provider only have no password
+	if anUser.Password != "" && !security.DoPasswordsMatch(anUser.Password, password) {
+		return "", "", ErrWrongPassword
+	}
+	if strings.EqualFold(email, anUser.Email) {
+		return "", "", ErrSameEmail
+	}
+	count, err := instance.repo.FindUser(email)
+	if err != nil {
+		return "", "", err
+	}
+	if count > 0 {
+		return "", "", ErrEmailExists
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(random)
+	now := time.Now()
+	aChange := emailChange{
+		Email:     email,
+		TokenHash: hashEmailChange(token),
+		ExpiresAt: now.Add(EmailChangeTTL).Format("2006-01-02, 15:04:05"),
+	}
+	err = instance.repo.SetEmailChange(userID, aChange, now.Format("2006-01-02, 15:04:05"))
+	if err != nil {
+		return "", "", err
+	}
+	return token, anUser.Email, nil
+}
+
+// ConfirmEmailChange replace the email of the user of token by the one it
+// was sent to, returning the user, its old and its new email. The token
+// works once, ErrEmailChangeInvalid when it expired or was replaced
+func (instance *useCase) ConfirmEmailChange(token string) (string, string, string, error) {
+	hash := hashEmailChange(token)
+	now := time.Now().Format("2006-01-02, 15:04:05")
+	var anUser user
+	err := instance.repo.GetUserByEmailChange(hash, now, &anUser)
+	if err == mongo.ErrNoDocuments {
+		return "", "", "", ErrEmailChangeInvalid
+	}
+	if err != nil {
+		return "", "", "", err
+	}
+	// another account may have signed up with it since it was asked for
+	email := anUser.EmailChange.Email
+	count, err := instance.repo.FindUser(email)
+	if err != nil {
+		return "", "", "", err
+	}
+	if count > 0 {
+		return "", "", "", ErrEmailExists
+	}
+	err = instance.repo.ChangeEmail(anUser.ID, hash, email, now)
+	if err == mongo.ErrNoDocuments {
+		return "", "", "", ErrEmailChangeInvalid
+	}
+	if err != nil {
+		return "", "", "", err
+	}
+	return anUser.ID, anUser.Email, email, nil
+}
+
+// hashEmailChange the tokens are stored hashed, a leak of the users does not
+// give working links
+func hashEmailChange(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SetEmailChange store the pending email change of user, replacing any
+// previous one
+func (instance *repository) SetEmailChange(userID string, aChange emailChange, updatedAt string) error {
+	filter := bson.M{"id": userID}
+	update := bson.M{"$set": bson.M{"email_change": aChange, "updated_at": updatedAt}}
+	return instance.updateOne(filter, update)
+}
+
+// GetUserByEmailChange user with the pending email change of tokenHash
+// expiring after now
+func (instance *repository) GetUserByEmailChange(tokenHash, now string, anUser *user) error {
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"email_change.token_hash": tokenHash},
+		{"email_change.expires_at": bson.M{"$gt": now}},
+	}}
+	return userCollection.FindOne(context.TODO(), filter).Decode(anUser)
+}
+
+// ChangeEmail set email as the email of user and drop its pending change, in
+// one update matching only while the change of tokenHash is still pending.
+// Of two requests confirming it at once one gets it
+func (instance *repository) ChangeEmail(userID, tokenHash, email, updatedAt string) error {
+	filter := bson.M{"$and": []bson.M{
+		{"id": userID},
+		{"email_change.token_hash": tokenHash},
+		{"email_change.email": email},
+	}}
+	update := bson.M{
+		"$set":   bson.M{"email": email, "updated_at": updatedAt},
+		"$unset": bson.M{"email_change": ""},
+	}
+	return instance.updateOne(filter, update)
+}
+
+// emailChangeURL page the emailed link opens
+func emailChangeURL(token string) string {
+	return strings.TrimSuffix(configs.AppURL, "/") + "/profile/email/confirm?token=" + url.QueryEscape(token)
+}
+
+// RequestEmailChange email a link confirming the new email to it, and tell
+// the current email about it. The email only changes once the link is opened
+func (instance *httpDelivery) RequestEmailChange(c *gin.Context) {
+	request, err := req.BindAndValidate[RequestEmailChange](c)
+	if err != nil {
+		req.BadRequest(c, "invalid email", err)
+		return
+	}
+	if !mail.Enabled() {
+		httperr.Abort(c, http.StatusServiceUnavailable, auth.CodeMailNotConfigured, "email change is not available, ask an administrator")
+		return
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetUser(tokenAuth)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	token, oldEmail, err := instance.userUseCase.RequestEmailChange(userID, request.Email, request.Password)
+	switch err {
+	case nil:
+	case ErrWrongPassword:
+		instance.publishAuth(c, ActionEmailChangeRequest, siem.OutcomeFailure, userID, request.Email, CodeWrongPassword)
+		httperr.Abort(c, http.StatusForbidden, CodeWrongPassword, err.Error())
+		return
+	case ErrSameEmail:
+		httperr.Abort(c, http.StatusBadRequest, CodeSameEmail, err.Error())
+		return
+	case ErrEmailExists:
+		httperr.Abort(c, http.StatusConflict, CodeEmailExists, err.Error())
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "change email failed")
+		return
+	}
+
+	locale := instance.mailLocale(c, userID)
+	err = mail.Send(mail.Message{
+		To:      request.Email,
+		Subject: i18n.T(locale, "Confirm your new email"),
+		Body: fmt.Sprintf(i18n.T(locale, "You asked to sign in to your account with this email from now on.\n\n"+
+			"Open this link within a day to confirm it:\n%s\n\n"+
+			"If it was not you, ignore this email, the account keeps its email.\n"), emailChangeURL(token)),
+	})
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "send confirmation email failed")
+		return
+	}
+	go sendEmailNotice(oldEmail, i18n.T(locale, "Your email is about to change"),
+		fmt.Sprintf(i18n.T(locale, "Someone signed in to your account asked to change its email to %s.\n\n"+
+			"It changes once the link sent there is opened. If it was not you, change your password now.\n"), request.Email))
+	instance.publishAuth(c, ActionEmailChangeRequest, siem.OutcomeSuccess, userID, request.Email, "")
+
+	c.JSON(http.StatusAccepted, gin.H{"sent": true})
+}
+
+// ShowConfirmEmailPage form of the emailed link, the email only changes once
+// it is submitted so mail scanners opening links do not confirm it
+func (instance *httpDelivery) ShowConfirmEmailPage(c *gin.Context) {
+	c.HTML(http.StatusOK, "confirm_email.html", gin.H{"Token": c.Query("token")})
+}
+
+// ConfirmEmailChange replace the email of the user of the token, the old
+// email is told about it
+func (instance *httpDelivery) ConfirmEmailChange(c *gin.Context) {
+	request, form, err := req.BindEitherAndValidate[RequestConfirmEmailChange](c)
+	if err != nil {
+		req.BadRequest(c, "invalid email change", err)
+		return
+	}
+
+	userID, oldEmail, email, err := instance.userUseCase.ConfirmEmailChange(request.Token)
+	switch err {
+	case nil:
+	case ErrEmailChangeInvalid:
+		instance.publishAuth(c, ActionEmailChange, siem.OutcomeFailure, "", "", CodeEmailChangeInvalid)
+		httperr.Abort(c, http.StatusBadRequest, CodeEmailChangeInvalid, err.Error())
+		return
+	case ErrEmailExists:
+		instance.publishAuth(c, ActionEmailChange, siem.OutcomeFailure, "", "", CodeEmailExists)
+		httperr.Abort(c, http.StatusConflict, CodeEmailExists, err.Error())
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "change email failed")
+		return
+	}
+	locale := instance.mailLocale(c, userID)
+	go sendEmailNotice(oldEmail, i18n.T(locale, "Your email was changed"),
+		fmt.Sprintf(i18n.T(locale, "The email of your account is now %s, sign in with it from now on.\n\n"+
+			"If it was not you, contact an administrator to get your account back.\n"), email))
+	instance.publishAuth(c, ActionEmailChange, siem.OutcomeSuccess, userID, email, "")
+
+	if form {
+		c.Redirect(http.StatusFound, "/profile/details")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"email": email})
+}
+
+// mailLocale locale of the emails to userID, the one they chose or else the
+// best match of the Accept-Language of the request
+func (instance *httpDelivery) mailLocale(c *gin.Context, userID string) string {
+	var anUser user
+	if err := instance.userUseCase.GetUserByID(userID, &anUser); err == nil && anUser.Locale != "" {
+		return anUser.Locale
+	}
+	return i18n.Match(c.GetHeader("Accept-Language"))
+}
+
+// sendEmailNotice email the old email of an account about a change of it
+func sendEmailNotice(email, subject, body string) {
+	err := mail.Send(mail.Message{To: email, Subject: subject, Body: body})
+	if err != nil {
+		logrus.Error("send email change notice error ", err)
+	}
+}
@@ -0,0 +1,200 @@
+package user
+
+import (
+	"net/http"
+	"slices"
+
+	"analytics-api/internal/pkg/etag"
+	req "analytics-api/internal/pkg/request"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// MaxMemberChanges changes applied by one UpdateMembers
+const MaxMemberChanges = 100
+
+// Actions of a MemberChange
+const (
+	// MemberUpdate give the member a role and the tags it is limited to
+	MemberUpdate = "update"
+	// MemberRemove take the member out of the organization
+	MemberRemove = "remove"
+)
+
+// Statuses of the result of a MemberChange
+const (
+	RoleUpdated   = "updated"
+	MemberRemoved = "removed"
+	// RoleFailed the change cannot be applied, no change of the batch is
+	RoleFailed = "failed"
+	// RoleSkipped the change is valid but another of the batch failed
+	RoleSkipped = "skipped"
+)
+
+// MemberChange change of the member UserID in a batch: its role and the tags
+// limiting the websites it is granted, or its removal
+type MemberChange struct {
+	UserID string   `json:"user_id" validate:"required,max=100"`
+	Action string   `json:"action" validate:"required,oneof=update remove"`
+	Role   string   `json:"role" validate:"required_if=Action update,omitempty,oneof=owner admin viewer"`
+	Tags   []string `json:"tags" validate:"max=20"`
+}
+
+// MemberResult outcome of the change at Index of a batch
+type MemberResult struct {
+	Index        int      `json:"index"`
+	UserID       string   `json:"user_id"`
+	Action       string   `json:"action"`
+	Status       string   `json:"status"`
+	Role         string   `json:"role,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	PreviousRole string   `json:"previous_role,omitempty"`
+	PreviousTags []string `json:"previous_tags,omitempty"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// RequestMembers ...
+type RequestMembers struct {
+	Changes []MemberChange `json:"changes" validate:"required,min=1,max=100,dive"`
+}
+
+// ReplaceMembers set the members of orgID, as long as they are still
+// previous so a batch never overwrites a change made meanwhile
+func (instance *repository) ReplaceMembers(orgID string, previous, members []member) error {
+	filter := bson.M{"id": orgID, "kind": KindOrg, "members": previous}
+	return instance.updateOne(filter, bson.M{"$set": bson.M{"members": members}})
+}
+
+// UpdateMembers apply changes to the members of orgID all or none, on behalf
+// of its owner userID. Every change is checked against the members the
+// batch leaves, when a member is missing or named twice, a role or tags are
+// invalid, or no owner would be left the batch fails and no member changes.
+// The members are written at once, etag.ErrConflict when they changed
+// meanwhile. Removed members are signed out of every device. Returns
+// whether the batch applied
+func (instance *useCase) UpdateMembers(orgID, userID string, changes []MemberChange) ([]MemberResult, bool, error) {
+	anOrg, err := instance.getOrg(orgID, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if memberRole(anOrg, userID) != RoleOwner {
+		return nil, false, ErrOrgForbidden
+	}
+
+	results, members, applies := changeMembers(anOrg.Members, changes)
+	if !applies {
+		return results, false, nil
+	}
+	err = instance.repo.ReplaceMembers(orgID, anOrg.Members, members)
+	if err == mongo.ErrNoDocuments {
+		return nil, false, etag.ErrConflict
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	for _, aResult := range results {
+		if aResult.Status != MemberRemoved {
+			continue
+		}
+		if _, err := instance.authUseCase.LogoutAll(aResult.UserID); err != nil {
+			logrus.Error("logout removed member id ", aResult.UserID, " error ", err)
+		}
+	}
+	return results, true, nil
+}
+
+// changeMembers members once changes are applied to previous, the result
+// of each change and whether all of them apply
+func changeMembers(previous []member, changes []MemberChange) ([]MemberResult, []member, bool) {
+	members := slices.Clone(previous)
+	results := make([]MemberResult, len(changes))
+	changed := map[string]bool{}
+	applies := true
+	for i, aChange := range changes {
+		results[i] = MemberResult{Index: i, UserID: aChange.UserID, Action: aChange.Action, Status: RoleSkipped}
+		at := slices.IndexFunc(members, func(aMember member) bool { return aMember.UserID == aChange.UserID })
+		var err error
+		switch {
+		case changed[aChange.UserID]:
+			err = ErrDuplicateChange
+		case at < 0:
+			err = ErrMemberNotFound
+		case aChange.Action == MemberUpdate && !ValidRole(aChange.Role):
+			err = ErrInvalidRole
+		case aChange.Action != MemberUpdate && aChange.Action != MemberRemove:
+			err = ErrInvalidAction
+		}
+		changed[aChange.UserID] = true
+		if err == nil && aChange.Action == MemberUpdate {
+			results[i].Tags, err = scopeTags(aChange.Role, aChange.Tags)
+		}
+		if err != nil {
+			results[i].Status = RoleFailed
+			results[i].Error = err.Error()
+			applies = false
+			continue
+		}
+
+		results[i].PreviousRole = members[at].Role
+		results[i].PreviousTags = members[at].Tags
+		if aChange.Action == MemberRemove {
+			members = slices.Delete(members, at, at+1)
+			continue
+		}
+		results[i].Role = aChange.Role
+		members[at].Role = aChange.Role
+		members[at].Tags = results[i].Tags
+	}
+
+	if applies && owners(user{Members: members}) == 0 {
+		// the changes taking away an owner are those leaving none
+		for i := range results {
+			if results[i].PreviousRole == RoleOwner && results[i].Role != RoleOwner {
+				results[i].Status = RoleFailed
+				results[i].Error = ErrLastOwner.Error()
+			}
+		}
+		applies = false
+	}
+	if !applies {
+		return results, nil, false
+	}
+	for i := range results {
+		results[i].Status = RoleUpdated
+		if results[i].Action == MemberRemove {
+			results[i].Status = MemberRemoved
+		}
+	}
+	return results, members, true
+}
+
+// UpdateMembers change or remove members of an organization in one batch,
+// all of them or none. A batch that is not applied answers 422 with the
+// result of each change telling which failed
+func (instance *httpDelivery) UpdateMembers(c *gin.Context) {
+	request, err := req.BindAndValidate[RequestMembers](c)
+	if err != nil {
+		req.BadRequest(c, "invalid members", err)
+		return
+	}
+	_, userID, ok := instance.signedIn(c)
+	if !ok {
+		return
+	}
+	results, applied, err := instance.userUseCase.UpdateMembers(c.Param("org_id"), userID, request.Changes)
+	if err != nil {
+		abortOrg(c, err, "update members")
+		return
+	}
+	status := http.StatusOK
+	if !applied {
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, gin.H{
+		"applied": applied,
+		"results": results,
+	})
+}
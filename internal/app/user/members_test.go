@@ -0,0 +1,103 @@
+package user
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChangeMembers(t *testing.T) {
+	previous := []member{
+		{UserID: "a", Role: RoleOwner},
+		{UserID: "b", Role: RoleAdmin},
+		{UserID: "c", Role: RoleViewer, Tags: []string{"client-a"}},
+	}
+	tests := []struct {
+		name        string
+		changes     []MemberChange
+		wantApplies bool
+		wantStatus  []string
+		wantMembers []member
+	}{
+		{
+			name: "should apply role changes, grants and removals together",
+			changes: []MemberChange{
+				{UserID: "b", Action: MemberUpdate, Role: RoleViewer, Tags: []string{"Client-B"}},
+				{UserID: "c", Action: MemberRemove},
+			},
+			wantApplies: true,
+			wantStatus:  []string{RoleUpdated, MemberRemoved},
+			wantMembers: []member{{UserID: "a", Role: RoleOwner}, {UserID: "b", Role: RoleViewer, Tags: []string{"client-b"}}},
+		},
+		{
+			name: "should lift the tags of a member changed without tags",
+			changes: []MemberChange{
+				{UserID: "c", Action: MemberUpdate, Role: RoleAdmin},
+			},
+			wantApplies: true,
+			wantStatus:  []string{RoleUpdated},
+			wantMembers: []member{{UserID: "a", Role: RoleOwner}, {UserID: "b", Role: RoleAdmin}, {UserID: "c", Role: RoleAdmin}},
+		},
+		{
+			name: "should apply nothing when a member is missing",
+			changes: []MemberChange{
+				{UserID: "b", Action: MemberRemove},
+				{UserID: "z", Action: MemberRemove},
+			},
+			wantStatus: []string{RoleSkipped, RoleFailed},
+		},
+		{
+			name: "should apply nothing when a member is changed twice",
+			changes: []MemberChange{
+				{UserID: "b", Action: MemberUpdate, Role: RoleViewer},
+				{UserID: "b", Action: MemberRemove},
+			},
+			wantStatus: []string{RoleSkipped, RoleFailed},
+		},
+		{
+			name: "should apply nothing when an owner is given tags",
+			changes: []MemberChange{
+				{UserID: "b", Action: MemberUpdate, Role: RoleOwner, Tags: []string{"client-a"}},
+			},
+			wantStatus: []string{RoleFailed},
+		},
+		{
+			name: "should apply nothing when no owner is left",
+			changes: []MemberChange{
+				{UserID: "b", Action: MemberUpdate, Role: RoleViewer},
+				{UserID: "a", Action: MemberRemove},
+			},
+			wantStatus: []string{RoleSkipped, RoleFailed},
+		},
+		{
+			name: "should let the last owner leave once another is made",
+			changes: []MemberChange{
+				{UserID: "b", Action: MemberUpdate, Role: RoleOwner},
+				{UserID: "a", Action: MemberRemove},
+			},
+			wantApplies: true,
+			wantStatus:  []string{RoleUpdated, MemberRemoved},
+			wantMembers: []member{{UserID: "b", Role: RoleOwner}, {UserID: "c", Role: RoleViewer, Tags: []string{"client-a"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, members, applies := changeMembers(previous, tt.changes)
+			if applies != tt.wantApplies {
+				t.Fatalf("changeMembers() applies = %v, want %v", applies, tt.wantApplies)
+			}
+			var status []string
+			for _, aResult := range results {
+				status = append(status, aResult.Status)
+			}
+			if !reflect.DeepEqual(status, tt.wantStatus) {
+				t.Errorf("changeMembers() status = %v, want %v", status, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(members, tt.wantMembers) {
+				t.Errorf("changeMembers() members = %v, want %v", members, tt.wantMembers)
+			}
+			if len(previous) != 3 || previous[1].Role != RoleAdmin {
+				t.Errorf("changeMembers() changed the previous members %v", previous)
+			}
+		})
+	}
+}
@@ -1,13 +1,115 @@
 package user
 
+// RoleOwner manages its websites and sees all of their data
+const RoleOwner = "owner"
+
+// RoleAdmin changes its websites and sees all of their data, but cannot
+// delete or transfer them
+const RoleAdmin = "admin"
+
+// RoleViewer sees the reports of its websites but not the recordings of
+// their sessions
+const RoleViewer = "viewer"
+
+// KindOrg account of an organization, owning websites its members act on.
+// It has no email nor password, nobody signs in as it
+const KindOrg = "org"
+
+// PlanFree plan of accounts without one
+const PlanFree = "free"
+
+// PlanPro and PlanAgency plans with room for more websites
+const (
+	PlanPro    = "pro"
+	PlanAgency = "agency"
+)
+
+// planWebsites websites an account of each plan may have, 0 for no limit
+var planWebsites = map[string]int64{
+	PlanFree:   3,
+	PlanPro:    20,
+	PlanAgency: 0,
+}
+
+// WebsiteLimit websites an account of plan may have, 0 for no limit
+func WebsiteLimit(plan string) int64 {
+	return planWebsites[plan]
+}
+
+// planWeights share of the background workers the websites of an account
+// of each plan get while others wait
+var planWeights = map[string]int{
+	PlanFree:   1,
+	PlanPro:    2,
+	PlanAgency: 4,
+}
+
+// PlanWeight share of the background workers of a website of an account of
+// plan, 1 for plans without one
+func PlanWeight(plan string) int {
+	if weight, ok := planWeights[plan]; ok {
+		return weight
+	}
+	return 1
+}
+
 // user ...
 type user struct {
-	ID           string `json:"id" bson:"id"`
-	FullName     string `json:"full_name" bson:"full_name"`
-	Password     string `json:"password" bson:"password"`
-	Email        string `json:"email" bson:"email"`
+	ID       string `json:"id" bson:"id"`
+	FullName string `json:"full_name" bson:"full_name"`
+	Password string `json:"password" bson:"password"`
+	Email    string `json:"email" bson:"email"`
+	// Locale of messages for the user, i18n.Locales, empty goes by Accept-Language
+	Locale string `json:"locale" bson:"locale,omitempty"`
+	// Role RoleOwner when empty
+	Role         string `json:"role" bson:"role,omitempty"`
 	AccessToken  string `json:"-" bson:"-"`
 	RefreshToken string `json:"-" bson:"-"`
 	CreatedAt    string `json:"created_at" bson:"created_at"`
 	UpdatedAt    string `json:"updated_at" bson:"updated_at"`
+	// Plan PlanFree when empty
+	Plan string `json:"plan" bson:"plan,omitempty"`
+	// TwoFactor sign ins need a code of the authenticator app of the user,
+	// or a recovery code, after the first factor. Set once the enrollment is
+	// confirmed with a code
+	TwoFactor bool `json:"two_factor" bson:"two_factor,omitempty"`
+	// TOTPSecret base32 secret of the authenticator app, kept from enrollment
+	// until two factor authentication is disabled
+	TOTPSecret string `json:"-" bson:"totp_secret,omitempty"`
+	// TOTPStep step of the last code accepted, so a code works once
+	TOTPStep int64 `json:"-" bson:"totp_step,omitempty"`
+	// RecoveryCodes SHA-256 of the recovery codes not used yet
+	RecoveryCodes []string `json:"-" bson:"recovery_codes,omitempty"`
+	// Identities accounts with identity providers the user signs in with
+	Identities []identity `json:"identities" bson:"identities,omitempty"`
+	// Kind KindOrg for organizations, empty for people
+	Kind string `json:"kind,omitempty" bson:"kind,omitempty"`
+	// Members people acting for an organization, with their role in it
+	Members []member `json:"-" bson:"members,omitempty"`
+	// Invitations of an organization waiting for their answer
+	Invitations []invitation `json:"-" bson:"invitations,omitempty"`
+	// EmailChange new email waiting to be confirmed from its mailbox
+	EmailChange *emailChange `json:"-" bson:"email_change,omitempty"`
+}
+
+// emailChange new email of a user, which only replaces the current one once
+// the link emailed to it is opened
+type emailChange struct {
+	Email string `bson:"email"`
+	// TokenHash SHA-256 of the token of the link
+	TokenHash string `bson:"token_hash"`
+	ExpiresAt string `bson:"expires_at"`
+}
+
+// identity account of a user with an identity provider
+type identity struct {
+	Provider string `json:"provider" bson:"provider"`
+	// Subject id of the account with the provider, its email may change
+	Subject  string `json:"-" bson:"subject"`
+	LinkedAt string `json:"linked_at" bson:"linked_at"`
+}
+
+// RequestLocale ...
+type RequestLocale struct {
+	Locale string `json:"locale"`
 }
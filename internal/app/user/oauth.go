@@ -0,0 +1,81 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"analytics-api/configs"
+	str "analytics-api/internal/pkg/string"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// GetUserByIdentity user signing in with the account subject of provider
+func (instance *repository) GetUserByIdentity(provider, subject string, anUser *user) error {
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
+	filter := bson.M{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}}}
+	return userCollection.FindOne(context.TODO(), filter).Decode(anUser)
+}
+
+// AddIdentity link anIdentity to user
+func (instance *repository) AddIdentity(userID string, anIdentity identity) error {
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
+	filter := bson.M{"id": userID}
+	update := bson.M{
+		"$push": bson.M{"identities": anIdentity},
+		"$set":  bson.M{"updated_at": anIdentity.LinkedAt},
+	}
+	result, err := userCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// SignInOAuth id of the user signing in with the account subject of
+// provider. The first sign in links the account to the user of its email,
+// verified by the provider, or signs up a user without password
+func (instance *useCase) SignInOAuth(provider, subject, email, fullName string) (string, error) {
+	var anUser user
+	err := instance.repo.GetUserByIdentity(provider, subject, &anUser)
+	if err == nil {
+		return anUser.ID, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return "", err
+	}
+
+	now := time.Now().Format("2006-01-02, 15:04:05")
+	anIdentity := identity{Provider: provider, Subject: subject, LinkedAt: now}
+	err = instance.repo.GetUserByEmail(email, &anUser)
+	switch err {
+	case nil:
+		if err := instance.repo.AddIdentity(anUser.ID, anIdentity); err != nil {
+			return "", err
+		}
+		return anUser.ID, nil
+	case mongo.ErrNoDocuments:
+	default:
+		return "", err
+	}
+
+	if fullName == "" {
+		fullName = email
+	}
+	anUser = user{
+		ID:         str.GetMD5Hash(email),
+		FullName:   fullName,
+		Email:      email,
+		Identities: []identity{anIdentity},
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := instance.repo.InsertUser(anUser); err != nil {
+		return "", err
+	}
+	return anUser.ID, nil
+}
@@ -0,0 +1,867 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/pkg/etag"
+	"analytics-api/internal/pkg/httperr"
+	req "analytics-api/internal/pkg/request"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// InvitationTTL time an invitation to an organization can be accepted
+const InvitationTTL = 7 * 24 * time.Hour
+
+// Codes of the error responses of organizations
+const (
+	CodeOrgNotFound        = "org_not_found"
+	CodeInvitationNotFound = "invitation_not_found"
+	CodeMemberNotFound     = "member_not_found"
+	CodeAlreadyMember      = "already_member"
+	CodeLastOwner          = "last_owner"
+)
+
+var (
+	// ErrOrgNotFound ...
+	ErrOrgNotFound = errors.New("no organization with this id has you as a member")
+	// ErrInvitationNotFound ...
+	ErrInvitationNotFound = errors.New("no invitation with this id is waiting for you")
+	// ErrMemberNotFound ...
+	ErrMemberNotFound = errors.New("this account is not a member of the organization")
+	// ErrAlreadyMember ...
+	ErrAlreadyMember = errors.New("this account is a member already")
+	// ErrLastOwner ...
+	ErrLastOwner = errors.New("an organization keeps at least one owner")
+	// ErrOrgForbidden ...
+	ErrOrgForbidden = errors.New("only owners manage the members of an organization")
+	// ErrInvalidAction ...
+	ErrInvalidAction = errors.New("action must be update or remove")
+	// ErrInvalidScope ...
+	ErrInvalidScope = errors.New("owners act on every website, admins and viewers are limited to 1 to 20 tags of 1 to 30 characters")
+)
+
+// MaxScopeTags tags a member can be limited to at most
+const MaxScopeTags = 20
+
+// member person acting for an organization
+type member struct {
+	UserID   string `bson:"user_id"`
+	Role     string `bson:"role"`
+	JoinedAt string `bson:"joined_at"`
+	// Tags the member is limited to, the websites of the organization
+	// carrying one of them. Every website when empty
+	Tags []string `bson:"tags,omitempty"`
+}
+
+// invitation of an email to join an organization with a role
+type invitation struct {
+	ID        string    `bson:"id"`
+	Email     string    `bson:"email"`
+	Role      string    `bson:"role"`
+	Tags      []string  `bson:"tags,omitempty"`
+	InvitedBy string    `bson:"invited_by"`
+	CreatedAt string    `bson:"created_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// Org organization as one of its members sees it
+type Org struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Role of the member in the organization
+	Role string `json:"role"`
+	// Tags the member is limited to, none when it acts on every website
+	Tags      []string `json:"tags,omitempty"`
+	Plan      string   `json:"plan"`
+	CreatedAt string   `json:"created_at"`
+}
+
+// Member of an organization
+type Member struct {
+	UserID   string   `json:"user_id"`
+	Email    string   `json:"email"`
+	FullName string   `json:"full_name"`
+	Role     string   `json:"role"`
+	Tags     []string `json:"tags,omitempty"`
+	JoinedAt string   `json:"joined_at"`
+}
+
+// Invitation to join an organization
+type Invitation struct {
+	ID        string    `json:"id"`
+	OrgID     string    `json:"org_id"`
+	OrgName   string    `json:"org_name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	Tags      []string  `json:"tags,omitempty"`
+	CreatedAt string    `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RequestCreateOrg ...
+type RequestCreateOrg struct {
+	Name string `json:"name" validate:"required,min=2,max=100"`
+}
+
+// RequestInvite ...
+type RequestInvite struct {
+	Email string   `json:"email" validate:"required,email,max=254"`
+	Role  string   `json:"role" validate:"required,oneof=owner admin viewer"`
+	Tags  []string `json:"tags" validate:"max=20"`
+}
+
+// RequestMemberRole role of a member, and the tags it is limited to
+type RequestMemberRole struct {
+	Role string   `json:"role" validate:"required,oneof=owner admin viewer"`
+	Tags []string `json:"tags" validate:"max=20"`
+}
+
+// RequestSwitchOrg organization to act for, empty for the account of the user
+type RequestSwitchOrg struct {
+	OrgID string `json:"org_id" validate:"max=100"`
+}
+
+// orgFilter organization orgID having userID as a member
+func orgFilter(orgID, userID string) bson.M {
+	return bson.M{"id": orgID, "kind": KindOrg, "members.user_id": userID}
+}
+
+// invitationMatch invitation id for email not expired
+func invitationMatch(id, email string) bson.M {
+	return bson.M{"$elemMatch": bson.M{"id": id, "email": email, "expires_at": bson.M{"$gt": time.Now()}}}
+}
+
+// ListOrgs organizations userID is a member of
+func (instance *repository) ListOrgs(userID string) ([]user, error) {
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
+	cursor, err := userCollection.Find(context.TODO(), bson.M{"kind": KindOrg, "members.user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	var orgs []user
+	if err = cursor.All(context.TODO(), &orgs); err != nil {
+		return nil, err
+	}
+	return orgs, nil
+}
+
+// GetOrg organization orgID having userID as a member
+func (instance *repository) GetOrg(orgID, userID string, anOrg *user) error {
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
+	return userCollection.FindOne(context.TODO(), orgFilter(orgID, userID)).Decode(anOrg)
+}
+
+// AddInvitation invite the email of anInvitation to orgID, replacing the
+// invitation of the same email if any
+func (instance *repository) AddInvitation(orgID string, anInvitation invitation) error {
+	filter := bson.M{"id": orgID, "kind": KindOrg}
+	pull := bson.M{"$pull": bson.M{"invitations": bson.M{"email": anInvitation.Email}}}
+	if err := instance.updateOne(filter, pull); err != nil {
+		return err
+	}
+	return instance.updateOne(filter, bson.M{"$push": bson.M{"invitations": anInvitation}})
+}
+
+// DeleteInvitation withdraw invitationID of orgID
+func (instance *repository) DeleteInvitation(orgID, invitationID string) error {
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
+	filter := bson.M{"id": orgID, "kind": KindOrg, "invitations.id": invitationID}
+	update := bson.M{"$pull": bson.M{"invitations": bson.M{"id": invitationID}}}
+	result, err := userCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// ListInvitedOrgs organizations with an invitation of email not expired
+func (instance *repository) ListInvitedOrgs(email string) ([]user, error) {
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
+	filter := bson.M{"kind": KindOrg, "invitations": bson.M{"$elemMatch": bson.M{"email": email, "expires_at": bson.M{"$gt": time.Now()}}}}
+	cursor, err := userCollection.Find(context.TODO(), filter)
+	if err != nil {
+		return nil, err
+	}
+	var orgs []user
+	if err = cursor.All(context.TODO(), &orgs); err != nil {
+		return nil, err
+	}
+	return orgs, nil
+}
+
+// GetInvitedOrg organization with invitationID for email not expired
+func (instance *repository) GetInvitedOrg(invitationID, email string, anOrg *user) error {
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
+	filter := bson.M{"kind": KindOrg, "invitations": invitationMatch(invitationID, email)}
+	return userCollection.FindOne(context.TODO(), filter).Decode(anOrg)
+}
+
+// AcceptInvitation make aMember a member of the organization of invitationID
+// for email, in one write so the invitation works once
+func (instance *repository) AcceptInvitation(invitationID, email string, aMember member) error {
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
+	filter := bson.M{
+		"kind":            KindOrg,
+		"invitations":     invitationMatch(invitationID, email),
+		"members.user_id": bson.M{"$ne": aMember.UserID},
+	}
+	update := bson.M{
+		"$pull": bson.M{"invitations": bson.M{"id": invitationID}},
+		"$push": bson.M{"members": aMember},
+	}
+	result, err := userCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// DeclineInvitation drop invitationID for email
+func (instance *repository) DeclineInvitation(invitationID, email string) error {
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
+	filter := bson.M{"kind": KindOrg, "invitations": invitationMatch(invitationID, email)}
+	update := bson.M{"$pull": bson.M{"invitations": bson.M{"id": invitationID}}}
+	result, err := userCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// UpdateMemberRole set the role of memberID in orgID and the tags it is
+// limited to
+func (instance *repository) UpdateMemberRole(orgID, memberID, role string, tags []string) error {
+	if len(tags) == 0 {
+		return instance.updateOne(orgFilter(orgID, memberID), bson.M{
+			"$set":   bson.M{"members.$.role": role},
+			"$unset": bson.M{"members.$.tags": ""},
+		})
+	}
+	return instance.updateOne(orgFilter(orgID, memberID), bson.M{"$set": bson.M{"members.$.role": role, "members.$.tags": tags}})
+}
+
+// CountScopedWebsite count the websites websiteID of accountID carrying one
+// of tags
+func (instance *repository) CountScopedWebsite(accountID, websiteID string, tags []string) (int64, error) {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	return websiteCollection.CountDocuments(context.TODO(), bson.M{"user_id": accountID, "id": websiteID, "tags": bson.M{"$in": tags}})
+}
+
+// RemoveMember take memberID out of orgID
+func (instance *repository) RemoveMember(orgID, memberID string) error {
+	return instance.updateOne(orgFilter(orgID, memberID), bson.M{"$pull": bson.M{"members": bson.M{"user_id": memberID}}})
+}
+
+// CreateOrg add an organization named name with userID as its owner
+func (instance *useCase) CreateOrg(userID, name string) (*Org, error) {
+	now := time.Now().Format("2006-01-02, 15:04:05")
+	anOrg := user{
+		ID:        uuid.New().String(),
+		FullName:  name,
+		Kind:      KindOrg,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Members:   []member{{UserID: userID, Role: RoleOwner, JoinedAt: now}},
+	}
+	if err := instance.repo.InsertUser(anOrg); err != nil {
+		return nil, err
+	}
+	return orgOf(anOrg, userID), nil
+}
+
+// orgOf anOrg as userID sees it
+func orgOf(anOrg user, userID string) *Org {
+	plan := anOrg.Plan
+	if plan == "" {
+		plan = PlanFree
+	}
+	aMember := findMember(anOrg, userID)
+	return &Org{ID: anOrg.ID, Name: anOrg.FullName, Role: aMember.Role, Tags: aMember.Tags, Plan: plan, CreatedAt: anOrg.CreatedAt}
+}
+
+// findMember userID in anOrg, without role when it is not a member
+func findMember(anOrg user, userID string) member {
+	for _, aMember := range anOrg.Members {
+		if aMember.UserID == userID {
+			return aMember
+		}
+	}
+	return member{}
+}
+
+// memberRole role of userID in anOrg, empty when it is not a member
+func memberRole(anOrg user, userID string) string {
+	return findMember(anOrg, userID).Role
+}
+
+// scopeTags tags a member of role is limited to, lower cased and trimmed
+// like the tags of websites. ErrInvalidScope for an owner with tags, since
+// owners manage the organization, or for tags websites cannot carry
+func scopeTags(role string, tags []string) ([]string, error) {
+	scope := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len([]rune(tag)) > 30 {
+			return nil, ErrInvalidScope
+		}
+		if !slices.Contains(scope, tag) {
+			scope = append(scope, tag)
+		}
+	}
+	if len(scope) == 0 {
+		return nil, nil
+	}
+	if role == RoleOwner || len(scope) > MaxScopeTags {
+		return nil, ErrInvalidScope
+	}
+	return scope, nil
+}
+
+// owners count of the owners of anOrg
+func owners(anOrg user) int {
+	count := 0
+	for _, aMember := range anOrg.Members {
+		if aMember.Role == RoleOwner {
+			count++
+		}
+	}
+	return count
+}
+
+// getOrg organization orgID of member userID, ErrOrgNotFound when userID is
+// not a member
+func (instance *useCase) getOrg(orgID, userID string) (user, error) {
+	var anOrg user
+	err := instance.repo.GetOrg(orgID, userID, &anOrg)
+	if err == mongo.ErrNoDocuments {
+		return anOrg, ErrOrgNotFound
+	}
+	return anOrg, err
+}
+
+// ListOrgs organizations userID is a member of
+func (instance *useCase) ListOrgs(userID string) ([]Org, error) {
+	orgs, err := instance.repo.ListOrgs(userID)
+	if err != nil {
+		return nil, err
+	}
+	listOrg := []Org{}
+	for _, anOrg := range orgs {
+		listOrg = append(listOrg, *orgOf(anOrg, userID))
+	}
+	return listOrg, nil
+}
+
+// MemberRole role of userID in orgID, ErrOrgNotFound when it is not a member
+func (instance *useCase) MemberRole(orgID, userID string) (string, error) {
+	role, _, err := instance.memberScope(orgID, userID)
+	return role, err
+}
+
+// memberScope role of userID in orgID and the tags it is limited to,
+// ErrOrgNotFound when it is not a member
+func (instance *useCase) memberScope(orgID, userID string) (string, []string, error) {
+	anOrg, err := instance.getOrg(orgID, userID)
+	if err != nil {
+		return "", nil, err
+	}
+	aMember := findMember(anOrg, userID)
+	return aMember.Role, aMember.Tags, nil
+}
+
+// ListMembers members of orgID with their email, for its member userID
+func (instance *useCase) ListMembers(orgID, userID string) ([]Member, error) {
+	anOrg, err := instance.getOrg(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	members := []Member{}
+	for _, aMember := range anOrg.Members {
+		var anUser user
+		err := instance.repo.GetUserByID(aMember.UserID, &anUser)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return nil, err
+		}
+		members = append(members, Member{
+			UserID:   aMember.UserID,
+			Email:    anUser.Email,
+			FullName: anUser.FullName,
+			Role:     aMember.Role,
+			Tags:     aMember.Tags,
+			JoinedAt: aMember.JoinedAt,
+		})
+	}
+	return members, nil
+}
+
+// Invite email to orgID with role for InvitationTTL, limited to the websites
+// of tags when there are some, on behalf of its owner userID. The invitation
+// replaces the one pending for email
+func (instance *useCase) Invite(orgID, userID, email, role string, tags []string) (*Invitation, error) {
+	if !ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	tags, err := scopeTags(role, tags)
+	if err != nil {
+		return nil, err
+	}
+	anOrg, err := instance.getOrg(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if memberRole(anOrg, userID) != RoleOwner {
+		return nil, ErrOrgForbidden
+	}
+	email = strings.ToLower(email)
+	var invitee user
+	err = instance.repo.GetUserByEmail(email, &invitee)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	if err == nil && memberRole(anOrg, invitee.ID) != "" {
+		return nil, ErrAlreadyMember
+	}
+
+	now := time.Now()
+	anInvitation := invitation{
+		ID:        uuid.New().String(),
+		Email:     email,
+		Role:      role,
+		Tags:      tags,
+		InvitedBy: userID,
+		CreatedAt: now.Format("2006-01-02, 15:04:05"),
+		ExpiresAt: now.Add(InvitationTTL),
+	}
+	if err := instance.repo.AddInvitation(orgID, anInvitation); err != nil {
+		return nil, err
+	}
+	return invitationOf(anOrg, anInvitation), nil
+}
+
+func invitationOf(anOrg user, anInvitation invitation) *Invitation {
+	return &Invitation{
+		ID:        anInvitation.ID,
+		OrgID:     anOrg.ID,
+		OrgName:   anOrg.FullName,
+		Email:     anInvitation.Email,
+		Role:      anInvitation.Role,
+		Tags:      anInvitation.Tags,
+		CreatedAt: anInvitation.CreatedAt,
+		ExpiresAt: anInvitation.ExpiresAt,
+	}
+}
+
+// CancelInvitation withdraw invitationID of orgID, on behalf of its owner
+// userID
+func (instance *useCase) CancelInvitation(orgID, userID, invitationID string) error {
+	anOrg, err := instance.getOrg(orgID, userID)
+	if err != nil {
+		return err
+	}
+	if memberRole(anOrg, userID) != RoleOwner {
+		return ErrOrgForbidden
+	}
+	err = instance.repo.DeleteInvitation(orgID, invitationID)
+	if err == mongo.ErrNoDocuments {
+		return ErrInvitationNotFound
+	}
+	return err
+}
+
+// ListInvitations invitations waiting for the email of userID
+func (instance *useCase) ListInvitations(userID string) ([]Invitation, error) {
+	email, err := instance.GetEmail(userID)
+	if err != nil {
+		return nil, err
+	}
+	orgs, err := instance.repo.ListInvitedOrgs(strings.ToLower(email))
+	if err != nil {
+		return nil, err
+	}
+	invitations := []Invitation{}
+	now := time.Now()
+	for _, anOrg := range orgs {
+		for _, anInvitation := range anOrg.Invitations {
+			if strings.EqualFold(anInvitation.Email, email) && anInvitation.ExpiresAt.After(now) {
+				invitations = append(invitations, *invitationOf(anOrg, anInvitation))
+			}
+		}
+	}
+	return invitations, nil
+}
+
+// AcceptInvitation make userID a member of the organization inviting its
+// email with invitationID, in the role of the invitation
+func (instance *useCase) AcceptInvitation(userID, invitationID string) (*Org, error) {
+	email, err := instance.GetEmail(userID)
+	if err != nil {
+		return nil, err
+	}
+	email = strings.ToLower(email)
+	var anOrg user
+	err = instance.repo.GetInvitedOrg(invitationID, email, &anOrg)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if memberRole(anOrg, userID) != "" {
+		return nil, ErrAlreadyMember
+	}
+	aMember := member{UserID: userID, JoinedAt: time.Now().Format("2006-01-02, 15:04:05")}
+	for _, anInvitation := range anOrg.Invitations {
+		if anInvitation.ID == invitationID {
+			aMember.Role = anInvitation.Role
+			aMember.Tags = anInvitation.Tags
+		}
+	}
+	err = instance.repo.AcceptInvitation(invitationID, email, aMember)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	anOrg.Members = append(anOrg.Members, aMember)
+	return orgOf(anOrg, userID), nil
+}
+
+// DeclineInvitation drop invitationID waiting for the email of userID
+func (instance *useCase) DeclineInvitation(userID, invitationID string) error {
+	email, err := instance.GetEmail(userID)
+	if err != nil {
+		return err
+	}
+	err = instance.repo.DeclineInvitation(invitationID, strings.ToLower(email))
+	if err == mongo.ErrNoDocuments {
+		return ErrInvitationNotFound
+	}
+	return err
+}
+
+// UpdateMemberRole give memberID role in orgID, limited to the websites of
+// tags when there are some, on behalf of its owner userID. The last owner
+// cannot be given another role
+func (instance *useCase) UpdateMemberRole(orgID, userID, memberID, role string, tags []string) error {
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+	tags, err := scopeTags(role, tags)
+	if err != nil {
+		return err
+	}
+	anOrg, err := instance.getOrg(orgID, userID)
+	if err != nil {
+		return err
+	}
+	if memberRole(anOrg, userID) != RoleOwner {
+		return ErrOrgForbidden
+	}
+	previous := memberRole(anOrg, memberID)
+	if previous == "" {
+		return ErrMemberNotFound
+	}
+	if previous == RoleOwner && role != RoleOwner && owners(anOrg) == 1 {
+		return ErrLastOwner
+	}
+	return instance.repo.UpdateMemberRole(orgID, memberID, role, tags)
+}
+
+// RemoveMember take memberID out of orgID, on behalf of its owner userID or of
+// memberID leaving. The last owner cannot leave. The member is signed out of
+// every device, its sessions could act for the organization
+func (instance *useCase) RemoveMember(orgID, userID, memberID string) error {
+	anOrg, err := instance.getOrg(orgID, userID)
+	if err != nil {
+		return err
+	}
+	if userID != memberID && memberRole(anOrg, userID) != RoleOwner {
+		return ErrOrgForbidden
+	}
+	role := memberRole(anOrg, memberID)
+	if role == "" {
+		return ErrMemberNotFound
+	}
+	if role == RoleOwner && owners(anOrg) == 1 {
+		return ErrLastOwner
+	}
+	if err := instance.repo.RemoveMember(orgID, memberID); err != nil {
+		return err
+	}
+	_, err = instance.authUseCase.LogoutAll(memberID)
+	return err
+}
+
+// UpdateOrgPlan set the plan of orgID
+func (instance *useCase) UpdateOrgPlan(orgID, plan string) error {
+	if _, ok := planWebsites[plan]; !ok {
+		return ErrInvalidPlan
+	}
+	var anOrg user
+	err := instance.repo.GetUserByID(orgID, &anOrg)
+	if err == mongo.ErrNoDocuments || (err == nil && anOrg.Kind != KindOrg) {
+		return ErrOrgNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return instance.repo.UpdatePlan(orgID, plan, time.Now().Format("2006-01-02, 15:04:05"))
+}
+
+// signedIn user of the request, false when the response was written with an
+// error
+func (instance *httpDelivery) signedIn(c *gin.Context) (*security.TokenDetails, string, bool) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return nil, "", false
+	}
+	userID, err := instance.authUsecase.GetUser(tokenAuth)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return nil, "", false
+	}
+	return tokenAuth, userID, true
+}
+
+// abortOrg answer err of an organization
+func abortOrg(c *gin.Context, err error, action string) {
+	switch err {
+	case ErrOrgNotFound:
+		httperr.Abort(c, http.StatusNotFound, CodeOrgNotFound, err.Error())
+	case ErrInvitationNotFound:
+		httperr.Abort(c, http.StatusNotFound, CodeInvitationNotFound, err.Error())
+	case ErrMemberNotFound:
+		httperr.Abort(c, http.StatusNotFound, CodeMemberNotFound, err.Error())
+	case ErrInvalidRole, ErrInvalidScope:
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+	case ErrAlreadyMember:
+		httperr.Abort(c, http.StatusConflict, CodeAlreadyMember, err.Error())
+	case ErrLastOwner:
+		httperr.Abort(c, http.StatusConflict, CodeLastOwner, err.Error())
+	case ErrOrgForbidden:
+		httperr.Abort(c, http.StatusForbidden, httperr.CodeForbidden, err.Error())
+	case etag.ErrConflict:
+		httperr.Abort(c, http.StatusConflict, etag.CodeConflict, err.Error())
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, action+" failed")
+	}
+}
+
+// CreateOrg add an organization with the user as its owner
+func (instance *httpDelivery) CreateOrg(c *gin.Context) {
+	request, err := req.BindAndValidate[RequestCreateOrg](c)
+	if err != nil {
+		req.BadRequest(c, "invalid organization", err)
+		return
+	}
+	_, userID, ok := instance.signedIn(c)
+	if !ok {
+		return
+	}
+	anOrg, err := instance.userUseCase.CreateOrg(userID, request.Name)
+	if err != nil {
+		abortOrg(c, err, "create organization")
+		return
+	}
+	c.JSON(http.StatusCreated, anOrg)
+}
+
+// ListOrgs organizations of the user
+func (instance *httpDelivery) ListOrgs(c *gin.Context) {
+	_, userID, ok := instance.signedIn(c)
+	if !ok {
+		return
+	}
+	orgs, err := instance.userUseCase.ListOrgs(userID)
+	if err != nil {
+		abortOrg(c, err, "list organizations")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"orgs": orgs})
+}
+
+// ListMembers members of an organization of the user
+func (instance *httpDelivery) ListMembers(c *gin.Context) {
+	_, userID, ok := instance.signedIn(c)
+	if !ok {
+		return
+	}
+	members, err := instance.userUseCase.ListMembers(c.Param("org_id"), userID)
+	if err != nil {
+		abortOrg(c, err, "list members")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"members": members})
+}
+
+// UpdateMember change the role of a member
+func (instance *httpDelivery) UpdateMember(c *gin.Context) {
+	request, err := req.BindAndValidate[RequestMemberRole](c)
+	if err != nil {
+		req.BadRequest(c, "invalid role", err)
+		return
+	}
+	_, userID, ok := instance.signedIn(c)
+	if !ok {
+		return
+	}
+	err = instance.userUseCase.UpdateMemberRole(c.Param("org_id"), userID, c.Param("user_id"), request.Role, request.Tags)
+	if err != nil {
+		abortOrg(c, err, "update member")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RemoveMember take a member out of an organization, or the user itself
+func (instance *httpDelivery) RemoveMember(c *gin.Context) {
+	_, userID, ok := instance.signedIn(c)
+	if !ok {
+		return
+	}
+	err := instance.userUseCase.RemoveMember(c.Param("org_id"), userID, c.Param("user_id"))
+	if err != nil {
+		abortOrg(c, err, "remove member")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Invite an email to an organization
+func (instance *httpDelivery) Invite(c *gin.Context) {
+	request, err := req.BindAndValidate[RequestInvite](c)
+	if err != nil {
+		req.BadRequest(c, "invalid invitation", err)
+		return
+	}
+	_, userID, ok := instance.signedIn(c)
+	if !ok {
+		return
+	}
+	anInvitation, err := instance.userUseCase.Invite(c.Param("org_id"), userID, request.Email, request.Role, request.Tags)
+	if err != nil {
+		abortOrg(c, err, "invite")
+		return
+	}
+	c.JSON(http.StatusCreated, anInvitation)
+}
+
+// CancelInvitation withdraw an invitation of an organization
+func (instance *httpDelivery) CancelInvitation(c *gin.Context) {
+	_, userID, ok := instance.signedIn(c)
+	if !ok {
+		return
+	}
+	err := instance.userUseCase.CancelInvitation(c.Param("org_id"), userID, c.Param("invitation_id"))
+	if err != nil {
+		abortOrg(c, err, "cancel invitation")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListInvitations invitations waiting for the user
+func (instance *httpDelivery) ListInvitations(c *gin.Context) {
+	_, userID, ok := instance.signedIn(c)
+	if !ok {
+		return
+	}
+	invitations, err := instance.userUseCase.ListInvitations(userID)
+	if err != nil {
+		abortOrg(c, err, "list invitations")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+// AcceptInvitation join the organization inviting the user
+func (instance *httpDelivery) AcceptInvitation(c *gin.Context) {
+	_, userID, ok := instance.signedIn(c)
+	if !ok {
+		return
+	}
+	anOrg, err := instance.userUseCase.AcceptInvitation(userID, c.Param("invitation_id"))
+	if err != nil {
+		abortOrg(c, err, "accept invitation")
+		return
+	}
+	c.JSON(http.StatusOK, anOrg)
+}
+
+// DeclineInvitation drop an invitation waiting for the user
+func (instance *httpDelivery) DeclineInvitation(c *gin.Context) {
+	_, userID, ok := instance.signedIn(c)
+	if !ok {
+		return
+	}
+	err := instance.userUseCase.DeclineInvitation(userID, c.Param("invitation_id"))
+	if err != nil {
+		abortOrg(c, err, "decline invitation")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SwitchOrg sign the user in again acting for an organization it is a member
+// of, or for its own account when org_id is empty. The session it switches
+// from is signed out
+func (instance *httpDelivery) SwitchOrg(c *gin.Context) {
+	request, err := req.BindAndValidate[RequestSwitchOrg](c)
+	if err != nil {
+		req.BadRequest(c, "invalid organization", err)
+		return
+	}
+	tokenAuth, userID, ok := instance.signedIn(c)
+	if !ok {
+		return
+	}
+	if request.OrgID != "" {
+		if _, err := instance.userUseCase.MemberRole(request.OrgID, userID); err != nil {
+			abortOrg(c, err, "switch organization")
+			return
+		}
+	}
+
+	token, err := security.CreateOrgToken(userID, instance.store.TenantID, request.OrgID)
+	if err != nil {
+		abortOrg(c, err, "switch organization")
+		return
+	}
+	if err := instance.authUsecase.InsertAuth(userID, token); err != nil {
+		abortOrg(c, err, "switch organization")
+		return
+	}
+	if tokenAuth.FamilyID != "" {
+		if err := instance.authUsecase.RevokeFamily(tokenAuth.FamilyID); err != nil {
+			logrus.Error("revoke family on switch error ", err)
+		}
+	} else if err := instance.authUsecase.DeleteAccessToken(tokenAuth.AccessUUID); err != nil {
+		logrus.Error("delete access token on switch error ", err)
+	}
+	auth.RespondTokens(c, token)
+}
@@ -0,0 +1,41 @@
+package user
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScopeTags(t *testing.T) {
+	many := make([]string, MaxScopeTags+1)
+	for i := range many {
+		many[i] = strings.Repeat("t", i+1)
+	}
+	tests := []struct {
+		name    string
+		role    string
+		tags    []string
+		want    []string
+		wantErr error
+	}{
+		{name: "should leave members without tags unscoped", role: RoleViewer, tags: nil, want: nil},
+		{name: "should normalize and dedupe tags", role: RoleViewer, tags: []string{" Client-A ", "client-a", "Prod"}, want: []string{"client-a", "prod"}},
+		{name: "should scope admins", role: RoleAdmin, tags: []string{"client-a"}, want: []string{"client-a"}},
+		{name: "should refuse an empty tag", role: RoleViewer, tags: []string{"client-a", " "}, wantErr: ErrInvalidScope},
+		{name: "should refuse a tag too long", role: RoleViewer, tags: []string{strings.Repeat("a", 31)}, wantErr: ErrInvalidScope},
+		{name: "should refuse to scope owners", role: RoleOwner, tags: []string{"client-a"}, wantErr: ErrInvalidScope},
+		{name: "should leave owners without tags unscoped", role: RoleOwner, tags: []string{}, want: nil},
+		{name: "should refuse too many tags", role: RoleViewer, tags: many, wantErr: ErrInvalidScope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scopeTags(tt.role, tt.tags)
+			if err != tt.wantErr {
+				t.Fatalf("scopeTags() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scopeTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
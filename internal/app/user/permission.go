@@ -0,0 +1,175 @@
+package user
+
+import (
+	"net/http"
+	"strings"
+
+	"analytics-api/internal/pkg/security"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Permissions of the roles over websites and their reports
+const (
+	// PermissionRead see websites, their settings and their reports
+	PermissionRead = "read"
+	// PermissionReplay watch recordings and live events of visitors
+	PermissionReplay = "replay"
+	// PermissionWrite add websites, change their settings, keys, goals,
+	// metrics, alerts and destinations
+	PermissionWrite = "write"
+	// PermissionManage delete, restore and transfer websites
+	PermissionManage = "manage"
+)
+
+// rolePermissions what each role may do, a role missing is invalid
+var rolePermissions = map[string][]string{
+	RoleOwner:  {PermissionRead, PermissionReplay, PermissionWrite, PermissionManage},
+	RoleAdmin:  {PermissionRead, PermissionReplay, PermissionWrite},
+	RoleViewer: {PermissionRead},
+}
+
+// guardedPrefixes routes of websites and reports checked against the role,
+// those of the account, sign in and the admin API are not
+var guardedPrefixes = []string{
+	"/website", "/stats", "/embed", "/session", "/visitor", "/aggregate", "/goal", "/metric", "/alert",
+	"/integration", "/crm", "/firehose", "/archive", "/audit", "/usage", "/reconciliation", "/reingest", "/mobile/overview",
+}
+
+// routePermissions routes needing another permission than read for GET and
+// write for the other methods
+var routePermissions = map[string]string{
+	"GET /session/:session_id":                  PermissionReplay,
+	"GET /session/event/:session_id":            PermissionReplay,
+	"GET /session/event/:session_id/page":       PermissionReplay,
+	"GET /visitor/:website_id/:visitor_id/live": PermissionReplay,
+	// website delete is a GET
+	"GET /website/delete/:website_id":             PermissionManage,
+	"POST /website/restore":                       PermissionManage,
+	"POST /website/transfer/:website_id":          PermissionManage,
+	"DELETE /website/transfer/:website_id":        PermissionManage,
+	"POST /website/transfers/accept/:website_id":  PermissionManage,
+	"POST /website/transfers/decline/:website_id": PermissionManage,
+	// connecting a CRM goes through the provider with GETs
+	"GET /crm/connect/:provider":  PermissionWrite,
+	"GET /crm/callback/:provider": PermissionWrite,
+	// the collector is not signed in
+	"POST /session/receive": "",
+}
+
+// scopedRoutes routes without a website that members limited to tags may
+// call, they only show the websites of these tags. The other routes of
+// websites and reports need the website in their path
+var scopedRoutes = map[string]bool{
+	"GET /website/dashboard": true,
+	"GET /website/list":      true,
+	"GET /website/tags":      true,
+}
+
+// ValidRole ...
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Can tell if role has permission
+func Can(role, permission string) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RoutePermission permission needed to call route with method, empty for
+// the routes any account can call
+func RoutePermission(method, route string) string {
+	if permission, ok := routePermissions[method+" "+route]; ok {
+		return permission
+	}
+	guarded := false
+	for _, prefix := range guardedPrefixes {
+		if route == prefix || strings.HasPrefix(route, prefix+"/") {
+			guarded = true
+			break
+		}
+	}
+	switch {
+	case !guarded:
+		return ""
+	case method == http.MethodGet || method == http.MethodHead:
+		return PermissionRead
+	default:
+		return PermissionWrite
+	}
+}
+
+// Authorize tell if the user signed in on r has the permission route needs,
+// and its personal token the scope of it. Personal tokens only open the
+// routes of websites and reports. A member limited to tags only reaches
+// websiteID, the website in the path of route, when it carries one of them;
+// the tags are returned for the route to show their websites only. Requests
+// not signed in pass, the route answers them
+func (instance *useCase) Authorize(r *http.Request, method, route, websiteID string) ([]string, bool, error) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(r)
+	if err != nil {
+		return nil, true, nil
+	}
+	permission := RoutePermission(method, route)
+	if permission == "" {
+		return nil, !tokenAuth.Personal(), nil
+	}
+	if !tokenAuth.Allows(permission) {
+		return nil, false, nil
+	}
+	if _, err := instance.authUseCase.GetAuth(tokenAuth.AccessUUID); err != nil {
+		return nil, true, nil
+	}
+	role, tags, err := instance.accountScope(tokenAuth)
+	switch err {
+	case nil:
+	case ErrOrgNotFound:
+		return nil, false, nil
+	case mongo.ErrNoDocuments:
+		return nil, true, nil
+	default:
+		return nil, false, err
+	}
+	if !Can(role, permission) {
+		return nil, false, nil
+	}
+	if len(tags) == 0 {
+		return nil, true, nil
+	}
+	if websiteID == "" {
+		return tags, scopedRoutes[method+" "+route], nil
+	}
+	count, err := instance.repo.CountScopedWebsite(tokenAuth.OrgID, websiteID, tags)
+	if err != nil {
+		return nil, false, err
+	}
+	return tags, count > 0, nil
+}
+
+// AccountRole role of the user signed in with tokenAuth over the account it
+// acts for: its role in the organization of the token, or else its own.
+// ErrOrgNotFound once it is no longer a member
+func (instance *useCase) AccountRole(tokenAuth *security.TokenDetails) (string, error) {
+	role, _, err := instance.accountScope(tokenAuth)
+	return role, err
+}
+
+// accountScope AccountRole with the tags the user is limited to in the
+// organization of the token, none for its own account
+func (instance *useCase) accountScope(tokenAuth *security.TokenDetails) (string, []string, error) {
+	if tokenAuth.OrgID != "" {
+		return instance.memberScope(tokenAuth.OrgID, tokenAuth.UserID)
+	}
+	userID, err := instance.authUseCase.GetUser(tokenAuth)
+	if err != nil {
+		return "", nil, err
+	}
+	role, err := instance.GetRole(userID)
+	return role, nil, err
+}
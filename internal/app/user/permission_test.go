@@ -0,0 +1,55 @@
+package user
+
+import "testing"
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		name       string
+		role       string
+		permission string
+		want       bool
+	}{
+		{name: "should let owners watch recordings", role: RoleOwner, permission: PermissionReplay, want: true},
+		{name: "should let owners delete websites", role: RoleOwner, permission: PermissionManage, want: true},
+		{name: "should let admins watch recordings", role: RoleAdmin, permission: PermissionReplay, want: true},
+		{name: "should not let admins delete websites", role: RoleAdmin, permission: PermissionManage, want: false},
+		{name: "should let viewers see reports", role: RoleViewer, permission: PermissionRead, want: true},
+		{name: "should not let viewers watch recordings", role: RoleViewer, permission: PermissionReplay, want: false},
+		{name: "should not let viewers change websites", role: RoleViewer, permission: PermissionWrite, want: false},
+		{name: "should grant nothing to an unknown role", role: "guest", permission: PermissionRead, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Can(tt.role, tt.permission); got != tt.want {
+				t.Errorf("Can(%q, %q) = %v, want %v", tt.role, tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoutePermission(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		route  string
+		want   string
+	}{
+		{name: "should need replay to watch a session", method: "GET", route: "/session/:session_id", want: PermissionReplay},
+		{name: "should need replay for the events of a session", method: "GET", route: "/session/event/:session_id", want: PermissionReplay},
+		{name: "should need replay for the event pages of a session", method: "GET", route: "/session/event/:session_id/page", want: PermissionReplay},
+		{name: "should need replay for the live events of a visitor", method: "GET", route: "/visitor/:website_id/:visitor_id/live", want: PermissionReplay},
+		{name: "should need read for reports", method: "GET", route: "/stats/:website_id/pages", want: PermissionRead},
+		{name: "should need write to change a website", method: "POST", route: "/website/update", want: PermissionWrite},
+		{name: "should need manage to delete a website", method: "GET", route: "/website/delete/:website_id", want: PermissionManage},
+		{name: "should leave the collector open", method: "POST", route: "/session/receive", want: ""},
+		{name: "should leave the account routes open", method: "POST", route: "/auth/login", want: ""},
+		{name: "should not match a prefix inside a word", method: "GET", route: "/websites", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RoutePermission(tt.method, tt.route); got != tt.want {
+				t.Errorf("RoutePermission(%q, %q) = %q, want %q", tt.method, tt.route, got, tt.want)
+			}
+		})
+	}
+}
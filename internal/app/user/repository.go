@@ -4,8 +4,10 @@ import (
 	"context"
 
 	"analytics-api/configs"
+	"analytics-api/db"
 
 	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -18,17 +20,47 @@ type Repository interface {
 	UpdateUser(userID string, user *user) error
 	UpdateFullName(userID string, user *user) error
 	UpdatePassword(userID string, user *user) error
+	UpdateLocale(userID, locale, updatedAt string) error
+	UpdateRole(userID, role, updatedAt string) error
+	UpdatePlan(userID, plan, updatedAt string) error
+	GetUserByIdentity(provider, subject string, user *user) error
+	AddIdentity(userID string, anIdentity identity) error
+	SetTOTPSecret(userID, secret, updatedAt string) error
+	EnableTwoFactor(userID string, recoveryCodes []string, step int64, updatedAt string) error
+	DisableTwoFactor(userID, updatedAt string) error
+	SetRecoveryCodes(userID string, recoveryCodes []string, updatedAt string) error
+	UseTOTPStep(userID string, step int64) (bool, error)
+	UseRecoveryCode(userID, hash string) (bool, error)
+	ListOrgs(userID string) ([]user, error)
+	GetOrg(orgID, userID string, anOrg *user) error
+	AddInvitation(orgID string, anInvitation invitation) error
+	DeleteInvitation(orgID, invitationID string) error
+	ListInvitedOrgs(email string) ([]user, error)
+	GetInvitedOrg(invitationID, email string, anOrg *user) error
+	AcceptInvitation(invitationID, email string, aMember member) error
+	DeclineInvitation(invitationID, email string) error
+	UpdateMemberRole(orgID, memberID, role string, tags []string) error
+	CountScopedWebsite(accountID, websiteID string, tags []string) (int64, error)
+	RemoveMember(orgID, memberID string) error
+	ReplaceMembers(orgID string, previous, members []member) error
+	SetEmailChange(userID string, aChange emailChange, updatedAt string) error
+	GetUserByEmailChange(tokenHash, now string, anUser *user) error
+	ChangeEmail(userID, tokenHash, email, updatedAt string) error
 }
 
-type repository struct{}
+type repository struct {
+	store *db.Store
+}
 
 // NewRepository ...
-func NewRepository() Repository {
-	return &repository{}
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store: store,
+	}
 }
 
 func (instance *repository) FindUser(email string) (int64, error) {
-	userCollection := configs.MongoDB.Client.Collection(configs.MongoDB.UserCollection)
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
 	filter := bson.M{"email": email}
 	count, err := userCollection.CountDocuments(context.TODO(), filter)
 	if err != nil {
@@ -38,7 +70,7 @@ func (instance *repository) FindUser(email string) (int64, error) {
 }
 
 func (instance *repository) InsertUser(anUser user) error {
-	userCollection := configs.MongoDB.Client.Collection(configs.MongoDB.UserCollection)
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
 	_, err := userCollection.InsertOne(context.TODO(), anUser)
 	if err != nil {
 		return err
@@ -47,7 +79,7 @@ func (instance *repository) InsertUser(anUser user) error {
 }
 
 func (instance *repository) GetUserByEmail(email string, anUser *user) error {
-	userCollection := configs.MongoDB.Client.Collection(configs.MongoDB.UserCollection)
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
 	filter := bson.M{"email": email}
 	err := userCollection.FindOne(context.TODO(), filter).Decode(&anUser)
 	if err != nil {
@@ -57,7 +89,7 @@ func (instance *repository) GetUserByEmail(email string, anUser *user) error {
 }
 
 func (instance *repository) GetUserByID(userID string, anUser *user) error {
-	userCollection := configs.MongoDB.Client.Collection(configs.MongoDB.UserCollection)
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
 	filter := bson.M{"id": userID}
 	err := userCollection.FindOne(context.TODO(), filter).Decode(&anUser)
 	if err != nil {
@@ -67,7 +99,7 @@ func (instance *repository) GetUserByID(userID string, anUser *user) error {
 }
 
 func (instance *repository) UpdateFullName(userID string, anUser *user) error {
-	userCollection := configs.MongoDB.Client.Collection(configs.MongoDB.UserCollection)
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
 	filter := bson.M{"id": userID}
 	update := bson.M{
 		"$set": bson.M{
@@ -83,7 +115,7 @@ func (instance *repository) UpdateFullName(userID string, anUser *user) error {
 }
 
 func (instance *repository) UpdatePassword(userID string, anUser *user) error {
-	userCollection := configs.MongoDB.Client.Collection(configs.MongoDB.UserCollection)
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
 	filter := bson.M{"id": userID}
 	update := bson.M{
 		"$set": bson.M{
@@ -99,7 +131,7 @@ func (instance *repository) UpdatePassword(userID string, anUser *user) error {
 }
 
 func (instance *repository) UpdateUser(userID string, anUser *user) error {
-	userCollection := configs.MongoDB.Client.Collection(configs.MongoDB.UserCollection)
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
 	filter := bson.M{"id": userID}
 	update := bson.M{
 		"$set": bson.M{
@@ -114,3 +146,63 @@ func (instance *repository) UpdateUser(userID string, anUser *user) error {
 	}
 	return nil
 }
+
+// UpdateLocale set the locale of messages for user
+func (instance *repository) UpdateLocale(userID, locale, updatedAt string) error {
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
+	filter := bson.M{"id": userID}
+	update := bson.M{
+		"$set": bson.M{
+			"locale":     locale,
+			"updated_at": updatedAt,
+		},
+	}
+	result, err := userCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// UpdateRole set the role of user
+func (instance *repository) UpdateRole(userID, role, updatedAt string) error {
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
+	filter := bson.M{"id": userID}
+	update := bson.M{
+		"$set": bson.M{
+			"role":       role,
+			"updated_at": updatedAt,
+		},
+	}
+	result, err := userCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// UpdatePlan set the plan of user
+func (instance *repository) UpdatePlan(userID, plan, updatedAt string) error {
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
+	filter := bson.M{"id": userID}
+	update := bson.M{
+		"$set": bson.M{
+			"plan":       plan,
+			"updated_at": updatedAt,
+		},
+	}
+	result, err := userCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
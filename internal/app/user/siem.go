@@ -0,0 +1,37 @@
+package user
+
+import (
+	"analytics-api/internal/pkg/siem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authentication actions exported to the SIEM
+const (
+	ActionSignUp = "auth.signup"
+	ActionSignIn = "auth.signin"
+	ActionLogout = "auth.logout"
+	// ActionTwoFactorEnable and ActionTwoFactorDisable two factor
+	// authentication turned on and off
+	ActionTwoFactorEnable  = "auth.2fa_enable"
+	ActionTwoFactorDisable = "auth.2fa_disable"
+	// ActionEmailChangeRequest and ActionEmailChange new email asked for and
+	// confirmed from its mailbox
+	ActionEmailChangeRequest = "auth.email_change_request"
+	ActionEmailChange        = "auth.email_change"
+)
+
+// publishAuth export action of the client of c with its outcome, failures
+// tell why in reason. email is the one typed, userID empty when unknown
+func (instance *httpDelivery) publishAuth(c *gin.Context, action, outcome, userID, email, reason string) {
+	siem.Publish(siem.Event{
+		Action:    action,
+		Outcome:   outcome,
+		TenantID:  instance.store.TenantID,
+		UserID:    userID,
+		Email:     email,
+		Reason:    reason,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
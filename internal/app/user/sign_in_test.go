@@ -0,0 +1,64 @@
+package user
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeThrottle locks out every sign in, keeping the addresses checked
+type fakeThrottle struct {
+	auth.UseCase
+	ips []string
+}
+
+func (instance *fakeThrottle) CheckSignIn(ip, email string) (time.Duration, error) {
+	instance.ips = append(instance.ips, ip)
+	return time.Minute, nil
+}
+
+func TestSigninThrottleAddress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		proxies []string
+		want    string
+	}{
+		{name: "should ignore the forwarding headers of any client", want: "203.0.113.7"},
+		{name: "should read the forwarding headers of a trusted proxy", proxies: []string{"203.0.113.7"}, want: "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle := &fakeThrottle{}
+			instance := &httpDelivery{store: &db.Store{}, authUsecase: throttle}
+			router := gin.New()
+			if err := router.SetTrustedProxies(tt.proxies); err != nil {
+				t.Fatalf("SetTrustedProxies() error = %v", err)
+			}
+			router.POST("/signin", instance.Signin)
+
+			form := url.Values{"email": {"a@example.com"}, "password": {"password123"}}
+			r := httptest.NewRequest(http.MethodPost, "/signin", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.Header.Set("X-Forwarded-For", "198.51.100.1")
+			r.RemoteAddr = "203.0.113.7:4000"
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != http.StatusTooManyRequests {
+				t.Fatalf("Signin() status = %v, want %v", w.Code, http.StatusTooManyRequests)
+			}
+			if len(throttle.ips) != 1 || throttle.ips[0] != tt.want {
+				t.Errorf("CheckSignIn() addresses = %v, want [%v]", throttle.ips, tt.want)
+			}
+		})
+	}
+}
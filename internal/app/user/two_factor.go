@@ -0,0 +1,530 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/pkg/httperr"
+	req "analytics-api/internal/pkg/request"
+	"analytics-api/internal/pkg/security"
+	"analytics-api/internal/pkg/siem"
+	"analytics-api/internal/pkg/totp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Codes of the error responses of two factor authentication
+const (
+	CodeInvalidTOTPCode      = "invalid_totp_code"
+	CodeTwoFactorEnabled     = "two_factor_enabled"
+	CodeTwoFactorDisabled    = "two_factor_disabled"
+	CodeTwoFactorNotEnrolled = "two_factor_not_enrolled"
+)
+
+var (
+	// ErrInvalidTOTPCode ...
+	ErrInvalidTOTPCode = errors.New("the code is wrong or was used already")
+	// ErrTwoFactorEnabled ...
+	ErrTwoFactorEnabled = errors.New("two factor authentication is on already, disable it first")
+	// ErrTwoFactorDisabled ...
+	ErrTwoFactorDisabled = errors.New("two factor authentication is not on")
+	// ErrTwoFactorNotEnrolled ...
+	ErrTwoFactorNotEnrolled = errors.New("enroll two factor authentication first")
+)
+
+// totpIssuer name authenticator apps show the codes under
+const totpIssuer = "Theodoiweb"
+
+// RecoveryCodes count of recovery codes a user gets, each works once
+const RecoveryCodes = 10
+
+// recoveryAlphabet characters of recovery codes, without those read alike
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// RequestTOTPCode code of the authenticator app, or a recovery code where
+// one is accepted
+type RequestTOTPCode struct {
+	Code string `json:"code" validate:"required,max=20"`
+}
+
+// RequestSignInTwoFactor second step of a sign in
+type RequestSignInTwoFactor struct {
+	PreAuthToken string `form:"pre_auth_token" validate:"required,max=100"`
+	Code         string `form:"code" validate:"required,max=20"`
+}
+
+// SetTOTPSecret store the secret of an enrollment waiting to be confirmed,
+// any previous one is dropped
+func (instance *repository) SetTOTPSecret(userID, secret, updatedAt string) error {
+	filter := bson.M{"id": userID}
+	update := bson.M{
+		"$set":   bson.M{"totp_secret": secret, "updated_at": updatedAt},
+		"$unset": bson.M{"totp_step": "", "recovery_codes": ""},
+	}
+	return instance.updateOne(filter, update)
+}
+
+// EnableTwoFactor turn two factor authentication on for user, with step of
+// the code confirming it used
+func (instance *repository) EnableTwoFactor(userID string, recoveryCodes []string, step int64, updatedAt string) error {
+	filter := bson.M{"id": userID}
+	update := bson.M{"$set": bson.M{
+		"two_factor":     true,
+		"totp_step":      step,
+		"recovery_codes": recoveryCodes,
+		"updated_at":     updatedAt,
+	}}
+	return instance.updateOne(filter, update)
+}
+
+// DisableTwoFactor turn two factor authentication off for user, dropping
+// its secret and recovery codes
+func (instance *repository) DisableTwoFactor(userID, updatedAt string) error {
+	filter := bson.M{"id": userID}
+	update := bson.M{
+		"$set":   bson.M{"updated_at": updatedAt},
+		"$unset": bson.M{"two_factor": "", "totp_secret": "", "totp_step": "", "recovery_codes": ""},
+	}
+	return instance.updateOne(filter, update)
+}
+
+// SetRecoveryCodes replace the recovery codes of user
+func (instance *repository) SetRecoveryCodes(userID string, recoveryCodes []string, updatedAt string) error {
+	filter := bson.M{"id": userID}
+	update := bson.M{"$set": bson.M{"recovery_codes": recoveryCodes, "updated_at": updatedAt}}
+	return instance.updateOne(filter, update)
+}
+
+// UseTOTPStep record step as used by user, false when a code of this step or
+// a later one was used already
+func (instance *repository) UseTOTPStep(userID string, step int64) (bool, error) {
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"id": userID},
+		{"$or": []bson.M{
+			{"totp_step": bson.M{"$lt": step}},
+			{"totp_step": bson.M{"$exists": false}},
+		}},
+	}}
+	result, err := userCollection.UpdateOne(context.TODO(), filter, bson.M{"$set": bson.M{"totp_step": step}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// UseRecoveryCode remove the recovery code of hash from user, false when it
+// has no such code left
+func (instance *repository) UseRecoveryCode(userID, hash string) (bool, error) {
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
+	filter := bson.M{"id": userID, "recovery_codes": hash}
+	result, err := userCollection.UpdateOne(context.TODO(), filter, bson.M{"$pull": bson.M{"recovery_codes": hash}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// updateOne apply update to the user of filter
+func (instance *repository) updateOne(filter, update bson.M) error {
+	userCollection := instance.store.Mongo.Collection(configs.MongoDB.UserCollection)
+	result, err := userCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// EnrollTwoFactor new secret of user and its otpauth uri, to scan with an
+// authenticator app. Two factor authentication is only on once a code of
+// the secret confirms it
+func (instance *useCase) EnrollTwoFactor(userID string) (string, string, error) {
+	var anUser user
+	err := instance.repo.GetUserByID(userID, &anUser)
+	if err != nil {
+		return "", "", err
+	}
+	if anUser.TwoFactor {
+		return "", "", ErrTwoFactorEnabled
+	}
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return "", "", err
+	}
+	err = instance.repo.SetTOTPSecret(userID, secret, time.Now().Format("2006-01-02, 15:04:05"))
+	if err != nil {
+		return "", "", err
+	}
+	return secret, totp.URI(totpIssuer, anUser.Email, secret), nil
+}
+
+// ConfirmTwoFactor turn two factor authentication on with code of the
+// secret enrolled, returns the recovery codes, shown this time only
+func (instance *useCase) ConfirmTwoFactor(userID, code string) ([]string, error) {
+	var anUser user
+	err := instance.repo.GetUserByID(userID, &anUser)
+	if err != nil {
+		return nil, err
+	}
+	if anUser.TwoFactor {
+		return nil, ErrTwoFactorEnabled
+	}
+	if anUser.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	step, ok := totp.Validate(anUser.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTOTPCode
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = instance.repo.EnableTwoFactor(userID, hashes, step, time.Now().Format("2006-01-02, 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor turn two factor authentication off, proven with a code
+// of the authenticator app or a recovery code
+func (instance *useCase) DisableTwoFactor(userID, code string) error {
+	if err := instance.VerifyTwoFactor(userID, code); err != nil {
+		return err
+	}
+	return instance.repo.DisableTwoFactor(userID, time.Now().Format("2006-01-02, 15:04:05"))
+}
+
+// RegenerateRecoveryCodes replace the recovery codes of user, proven with a
+// code of the authenticator app or a recovery code
+func (instance *useCase) RegenerateRecoveryCodes(userID, code string) ([]string, error) {
+	if err := instance.VerifyTwoFactor(userID, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = instance.repo.SetRecoveryCodes(userID, hashes, time.Now().Format("2006-01-02, 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifyTwoFactor check code for the second factor of user, a code of the
+// authenticator app not used yet or a recovery code, used up then
+func (instance *useCase) VerifyTwoFactor(userID, code string) error {
+	var anUser user
+	err := instance.repo.GetUserByID(userID, &anUser)
+	if err != nil {
+		return err
+	}
+	if !anUser.TwoFactor {
+		return ErrTwoFactorDisabled
+	}
+	if step, ok := totp.Validate(anUser.TOTPSecret, code, time.Now()); ok {
+		used, err := instance.repo.UseTOTPStep(userID, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidTOTPCode
+		}
+		return nil
+	}
+	used, err := instance.repo.UseRecoveryCode(userID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTOTPCode
+	}
+	return nil
+}
+
+// TwoFactorEnabled whether user gives a second factor at sign in
+func (instance *useCase) TwoFactorEnabled(userID string) (bool, error) {
+	var anUser user
+	err := instance.repo.GetUserByID(userID, &anUser)
+	if err != nil {
+		return false, err
+	}
+	return anUser.TwoFactor, nil
+}
+
+// ResetTwoFactor turn two factor authentication off for the user signed up
+// with email without any code, for a user who lost both their app and their
+// recovery codes
+func (instance *useCase) ResetTwoFactor(email string) error {
+	var anUser user
+	err := instance.repo.GetUserByEmail(email, &anUser)
+	if err != nil {
+		return err
+	}
+	return instance.repo.DisableTwoFactor(anUser.ID, time.Now().Format("2006-01-02, 15:04:05"))
+}
+
+// newRecoveryCodes RecoveryCodes random codes like abcde-fghjk, with the
+// hashes stored in their place
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, RecoveryCodes)
+	hashes := make([]string, 0, RecoveryCodes)
+	random := make([]byte, 10)
+	for i := 0; i < RecoveryCodes; i++ {
+		if _, err := rand.Read(random); err != nil {
+			return nil, nil, err
+		}
+		var builder strings.Builder
+		for j, b := range random {
+			if j == 5 {
+				builder.WriteByte('-')
+			}
+			builder.WriteByte(recoveryAlphabet[int(b)%len(recoveryAlphabet)])
+		}
+		codes = append(codes, builder.String())
+		hashes = append(hashes, hashRecoveryCode(builder.String()))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode SHA-256 of code as typed, case and spaces aside
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// SigninTwoFactor second step of a sign in, the code of the user given the
+// pre-auth token by the first
+func (instance *httpDelivery) SigninTwoFactor(c *gin.Context) {
+	request, err := req.BindFormAndValidate[RequestSignInTwoFactor](c)
+	if err != nil {
+		req.BadRequest(c, "invalid sign in", err)
+		return
+	}
+
+	userID, err := instance.authUsecase.GetPreAuth(request.PreAuthToken)
+	switch err {
+	case nil:
+	case auth.ErrPreAuthExpired:
+		httperr.Abort(c, http.StatusUnauthorized, auth.CodePreAuthExpired, err.Error())
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "sign in failed")
+		return
+	}
+
+	email, err := instance.userUseCase.GetEmail(userID)
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "sign in failed")
+		return
+	}
+	ip := c.ClientIP()
+	wait, err := instance.authUsecase.CheckSignIn(ip, email)
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "sign in failed")
+		return
+	}
+	if wait > 0 {
+		instance.publishAuth(c, ActionSignIn, siem.OutcomeFailure, userID, "", auth.CodeSignInLocked)
+		auth.AbortSignInLocked(c, wait)
+		return
+	}
+
+	err = instance.userUseCase.VerifyTwoFactor(userID, request.Code)
+	switch err {
+	case nil:
+	case ErrInvalidTOTPCode:
+		if err := instance.authUsecase.FailPreAuth(request.PreAuthToken); err != nil {
+			logrus.Error(c, err)
+		}
+		instance.failSignIn(c, ip, email)
+		instance.publishAuth(c, ActionSignIn, siem.OutcomeFailure, userID, "", CodeInvalidTOTPCode)
+		httperr.Abort(c, http.StatusUnauthorized, CodeInvalidTOTPCode, err.Error())
+		return
+	case ErrTwoFactorDisabled:
+		// turned off since the password was given, it is enough now
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "sign in failed")
+		return
+	}
+	if err := instance.authUsecase.DeletePreAuth(request.PreAuthToken); err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "sign in failed")
+		return
+	}
+
+	token, err := security.CreateToken(userID, instance.store.TenantID)
+	if err != nil {
+		logrus.Error("Create token error ", err)
+		c.HTML(http.StatusInternalServerError, "500.html", gin.H{})
+		return
+	}
+	if err := instance.authUsecase.InsertAuth(userID, token); err != nil {
+		logrus.Error("Insert auth error ", err)
+		c.HTML(http.StatusInternalServerError, "500.html", gin.H{})
+		return
+	}
+	instance.clearSignIn(c, email)
+	instance.publishAuth(c, ActionSignIn, siem.OutcomeSuccess, userID, "", "")
+
+	auth.SetCookies(c, token)
+	c.Redirect(http.StatusFound, "/profile/details")
+}
+
+// EnrollTwoFactor new secret of the user, answered with its otpauth uri for
+// clients to show as a QR code
+func (instance *httpDelivery) EnrollTwoFactor(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetUser(tokenAuth)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	secret, uri, err := instance.userUseCase.EnrollTwoFactor(userID)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{"secret": secret, "otpauth_uri": uri})
+	case ErrTwoFactorEnabled:
+		httperr.Abort(c, http.StatusConflict, CodeTwoFactorEnabled, err.Error())
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "enroll two factor failed")
+	}
+}
+
+// ConfirmTwoFactor turn two factor authentication on with a code of the
+// secret enrolled, answered with the recovery codes
+func (instance *httpDelivery) ConfirmTwoFactor(c *gin.Context) {
+	request, err := req.BindAndValidate[RequestTOTPCode](c)
+	if err != nil {
+		req.BadRequest(c, "invalid code", err)
+		return
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetUser(tokenAuth)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	codes, err := instance.userUseCase.ConfirmTwoFactor(userID, request.Code)
+	switch err {
+	case nil:
+		instance.publishAuth(c, ActionTwoFactorEnable, siem.OutcomeSuccess, userID, "", "")
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+	case ErrInvalidTOTPCode:
+		httperr.Abort(c, http.StatusBadRequest, CodeInvalidTOTPCode, err.Error())
+	case ErrTwoFactorEnabled:
+		httperr.Abort(c, http.StatusConflict, CodeTwoFactorEnabled, err.Error())
+	case ErrTwoFactorNotEnrolled:
+		httperr.Abort(c, http.StatusConflict, CodeTwoFactorNotEnrolled, err.Error())
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "confirm two factor failed")
+	}
+}
+
+// DisableTwoFactor turn two factor authentication off with a code of the
+// authenticator app or a recovery code
+func (instance *httpDelivery) DisableTwoFactor(c *gin.Context) {
+	request, err := req.BindAndValidate[RequestTOTPCode](c)
+	if err != nil {
+		req.BadRequest(c, "invalid code", err)
+		return
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetUser(tokenAuth)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	err = instance.userUseCase.DisableTwoFactor(userID, request.Code)
+	switch err {
+	case nil:
+		instance.publishAuth(c, ActionTwoFactorDisable, siem.OutcomeSuccess, userID, "", "")
+		c.Status(http.StatusNoContent)
+	case ErrInvalidTOTPCode:
+		instance.publishAuth(c, ActionTwoFactorDisable, siem.OutcomeFailure, userID, "", CodeInvalidTOTPCode)
+		httperr.Abort(c, http.StatusBadRequest, CodeInvalidTOTPCode, err.Error())
+	case ErrTwoFactorDisabled:
+		httperr.Abort(c, http.StatusConflict, CodeTwoFactorDisabled, err.Error())
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "disable two factor failed")
+	}
+}
+
+// RegenerateRecoveryCodes replace the recovery codes of the user, the old
+// ones stop working
+func (instance *httpDelivery) RegenerateRecoveryCodes(c *gin.Context) {
+	request, err := req.BindAndValidate[RequestTOTPCode](c)
+	if err != nil {
+		req.BadRequest(c, "invalid code", err)
+		return
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetUser(tokenAuth)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	codes, err := instance.userUseCase.RegenerateRecoveryCodes(userID, request.Code)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+	case ErrInvalidTOTPCode:
+		httperr.Abort(c, http.StatusBadRequest, CodeInvalidTOTPCode, err.Error())
+	case ErrTwoFactorDisabled:
+		httperr.Abort(c, http.StatusConflict, CodeTwoFactorDisabled, err.Error())
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "regenerate recovery codes failed")
+	}
+}
@@ -1,7 +1,41 @@
 package user
 
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/pkg/i18n"
+	"analytics-api/internal/pkg/security"
+	str "analytics-api/internal/pkg/string"
+)
+
+// ErrUnsupportedLocale ...
+var ErrUnsupportedLocale = errors.New("locale must be en or vi")
+
+// ErrInvalidRole ...
+var ErrInvalidRole = errors.New("role must be owner, admin or viewer")
+
+// ErrDuplicateChange ...
+var ErrDuplicateChange = errors.New("this account is already changed by another item")
+
+// ErrInvalidPlan ...
+var ErrInvalidPlan = errors.New("plan must be free, pro or agency")
+
+// Codes of the error responses of accounts
+const (
+	CodeEmailExists   = "email_exists"
+	CodeEmailNotFound = "email_not_found"
+	CodeWrongPassword = "wrong_password"
+)
+
 // UseCase ...
 type UseCase interface {
+	CreateUser(email, fullName, password string) (string, error)
+	ResetPassword(email, password string) error
+	SetPassword(userID, password string) error
 	FindUser(email string) (int64, error)
 	InsertUser(user user) error
 	GetUserByEmail(email string, user *user) error
@@ -9,16 +43,53 @@ type UseCase interface {
 	UpdateUser(userID string, user *user) error
 	UpdateFullName(userID string, user *user) error
 	UpdatePassword(userID string, user *user) error
+	UpdateLocale(userID, locale string) error
+	FindUserID(email string) (string, error)
+	GetLocale(userID string) (string, error)
+	Locale(r *http.Request) string
+	GetRole(userID string) (string, error)
+	Authorize(r *http.Request, method, route, websiteID string) ([]string, bool, error)
+	GetEmail(userID string) (string, error)
+	UpdateRole(email, role string) error
+	GetPlan(userID string) (string, error)
+	WorkerWeight(userID string) (int, error)
+	UpdatePlan(email, plan string) error
+	SignInOAuth(provider, subject, email, fullName string) (string, error)
+	EnrollTwoFactor(userID string) (string, string, error)
+	ConfirmTwoFactor(userID, code string) ([]string, error)
+	DisableTwoFactor(userID, code string) error
+	RegenerateRecoveryCodes(userID, code string) ([]string, error)
+	VerifyTwoFactor(userID, code string) error
+	TwoFactorEnabled(userID string) (bool, error)
+	ResetTwoFactor(email string) error
+	AccountRole(tokenAuth *security.TokenDetails) (string, error)
+	CreateOrg(userID, name string) (*Org, error)
+	ListOrgs(userID string) ([]Org, error)
+	MemberRole(orgID, userID string) (string, error)
+	ListMembers(orgID, userID string) ([]Member, error)
+	Invite(orgID, userID, email, role string, tags []string) (*Invitation, error)
+	CancelInvitation(orgID, userID, invitationID string) error
+	ListInvitations(userID string) ([]Invitation, error)
+	AcceptInvitation(userID, invitationID string) (*Org, error)
+	DeclineInvitation(userID, invitationID string) error
+	UpdateMemberRole(orgID, userID, memberID, role string, tags []string) error
+	RemoveMember(orgID, userID, memberID string) error
+	UpdateMembers(orgID, userID string, changes []MemberChange) ([]MemberResult, bool, error)
+	UpdateOrgPlan(orgID, plan string) error
+	RequestEmailChange(userID, email, password string) (string, string, error)
+	ConfirmEmailChange(token string) (string, string, string, error)
 }
 
 type useCase struct {
-	repo Repository
+	repo        Repository
+	authUseCase auth.UseCase
 }
 
 // NewUseCase ...
-func NewUseCase() UseCase {
+func NewUseCase(store *db.Store) UseCase {
 	return &useCase{
-		repo: NewRepository(),
+		repo:        NewRepository(store),
+		authUseCase: auth.NewUseCase(store),
 	}
 }
 
@@ -77,3 +148,176 @@ func (instance *useCase) UpdateUser(userID string, anUser *user) error {
 	}
 	return nil
 }
+
+// CreateUser hash password and insert new user, return id of user
+func (instance *useCase) CreateUser(email, fullName, password string) (string, error) {
+	hash, err := security.HashPassword(password)
+	if err != nil {
+		return "", err
+	}
+
+	createdAt := time.Now().Format("2006-01-02, 15:04:05")
+	anUser := user{
+		ID:        str.GetMD5Hash(email),
+		FullName:  fullName,
+		Email:     email,
+		Password:  hash,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+
+	err = instance.repo.InsertUser(anUser)
+	if err != nil {
+		return "", err
+	}
+	return anUser.ID, nil
+}
+
+// ResetPassword set new password for user by email
+func (instance *useCase) ResetPassword(email, password string) error {
+	userID, err := instance.FindUserID(email)
+	if err != nil {
+		return err
+	}
+	return instance.SetPassword(userID, password)
+}
+
+// SetPassword hash password and set it as the password of user
+func (instance *useCase) SetPassword(userID, password string) error {
+	hash, err := security.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	anUser := user{
+		Password:  hash,
+		UpdatedAt: time.Now().Format("2006-01-02, 15:04:05"),
+	}
+	err = instance.repo.UpdatePassword(userID, &anUser)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateLocale set the locale of messages for user, empty goes back to the
+// Accept-Language of each request
+func (instance *useCase) UpdateLocale(userID, locale string) error {
+	if locale != "" && !i18n.Supported(locale) {
+		return ErrUnsupportedLocale
+	}
+	return instance.repo.UpdateLocale(userID, locale, time.Now().Format("2006-01-02, 15:04:05"))
+}
+
+// Locale the user of the access token of r chose, empty when r is not signed
+// in or the user has not chosen one
+func (instance *useCase) Locale(r *http.Request) string {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(r)
+	if err != nil {
+		return ""
+	}
+	userID, err := instance.authUseCase.GetUser(tokenAuth)
+	if err != nil {
+		return ""
+	}
+	var anUser user
+	if err := instance.repo.GetUserByID(userID, &anUser); err != nil {
+		return ""
+	}
+	return anUser.Locale
+}
+
+// FindUserID id of the user signed up with email, mongo.ErrNoDocuments when
+// there is none
+func (instance *useCase) FindUserID(email string) (string, error) {
+	var anUser user
+	err := instance.repo.GetUserByEmail(email, &anUser)
+	if err != nil {
+		return "", err
+	}
+	return anUser.ID, nil
+}
+
+// GetLocale locale of messages user chose, empty when they have not chosen
+// one
+func (instance *useCase) GetLocale(userID string) (string, error) {
+	var anUser user
+	err := instance.repo.GetUserByID(userID, &anUser)
+	if err != nil {
+		return "", err
+	}
+	return anUser.Locale, nil
+}
+
+// GetRole role of user, RoleOwner unless another was set
+func (instance *useCase) GetRole(userID string) (string, error) {
+	var anUser user
+	err := instance.repo.GetUserByID(userID, &anUser)
+	if err != nil {
+		return "", err
+	}
+	if anUser.Role == "" {
+		return RoleOwner, nil
+	}
+	return anUser.Role, nil
+}
+
+// GetEmail email user signed up with
+func (instance *useCase) GetEmail(userID string) (string, error) {
+	var anUser user
+	err := instance.repo.GetUserByID(userID, &anUser)
+	if err != nil {
+		return "", err
+	}
+	return anUser.Email, nil
+}
+
+// UpdateRole set the role of the user signed up with email
+func (instance *useCase) UpdateRole(email, role string) error {
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+	var anUser user
+	err := instance.repo.GetUserByEmail(email, &anUser)
+	if err != nil {
+		return err
+	}
+	return instance.repo.UpdateRole(anUser.ID, role, time.Now().Format("2006-01-02, 15:04:05"))
+}
+
+// GetPlan plan of user, PlanFree unless another was set
+func (instance *useCase) GetPlan(userID string) (string, error) {
+	var anUser user
+	err := instance.repo.GetUserByID(userID, &anUser)
+	if err != nil {
+		return "", err
+	}
+	if anUser.Plan == "" {
+		return PlanFree, nil
+	}
+	return anUser.Plan, nil
+}
+
+// WorkerWeight share of the background workers the websites of user get,
+// by its plan
+func (instance *useCase) WorkerWeight(userID string) (int, error) {
+	plan, err := instance.GetPlan(userID)
+	if err != nil {
+		return 0, err
+	}
+	return PlanWeight(plan), nil
+}
+
+// UpdatePlan set the plan of the user signed up with email, websites it has
+// past the limit of a smaller plan are kept
+func (instance *useCase) UpdatePlan(email, plan string) error {
+	if _, ok := planWebsites[plan]; !ok {
+		return ErrInvalidPlan
+	}
+	var anUser user
+	err := instance.repo.GetUserByEmail(email, &anUser)
+	if err != nil {
+		return err
+	}
+	return instance.repo.UpdatePlan(anUser.ID, plan, time.Now().Format("2006-01-02, 15:04:05"))
+}
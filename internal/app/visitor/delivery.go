@@ -0,0 +1,34 @@
+package visitor
+
+import (
+	"analytics-api/db"
+	"analytics-api/internal/app/audit"
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/app/user"
+	"analytics-api/internal/app/website"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPDelivery identified visitors of websites
+type HTTPDelivery interface {
+	// This function is required for every HTTPDelivery
+	InitRoutes(r *gin.RouterGroup)
+
+	// Other functions to handle HTTP requests
+	ExportVisitor(c *gin.Context)
+	GetVisitor(c *gin.Context)
+	WatchVisitor(c *gin.Context)
+}
+
+// NewHTTPDelivery ...
+func NewHTTPDelivery(store *db.Store) HTTPDelivery {
+	return &httpDelivery{
+		store:          store,
+		visitorUseCase: NewUseCase(store),
+		websiteUseCase: website.NewUseCase(store),
+		authUsecase:    auth.NewUseCase(store),
+		userUseCase:    user.NewUseCase(store),
+		auditUseCase:   audit.NewUseCase(store),
+	}
+}
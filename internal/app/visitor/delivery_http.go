@@ -0,0 +1,203 @@
+package visitor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/audit"
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/app/user"
+	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/httperr"
+	"analytics-api/internal/pkg/middleware"
+	"analytics-api/internal/pkg/ndjson"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Codes of the error responses of visitors
+const (
+	CodeVisitorNotFound       = "visitor_not_found"
+	CodeVisitorStreamDisabled = "visitor_stream_disabled"
+)
+
+const (
+	// LiveWatchLimit longest a console watches a visitor in one request, it
+	// reconnects to go on
+	LiveWatchLimit = time.Hour
+	// livePingInterval comments sent while the visitor is idle so proxies
+	// keep the stream open
+	livePingInterval = 15 * time.Second
+)
+
+type httpDelivery struct {
+	store          *db.Store
+	visitorUseCase UseCase
+	websiteUseCase website.UseCase
+	authUsecase    auth.UseCase
+	userUseCase    user.UseCase
+	auditUseCase   audit.UseCase
+}
+
+// InitRoutes ...
+func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
+	visitorRoutes := r.Group("visitor")
+	{
+		visitorRoutes.GET("/:website_id", middleware.JWTMiddleware(), instance.ExportVisitor)
+		visitorRoutes.GET("/:website_id/:visitor_id", middleware.JWTMiddleware(), instance.GetVisitor)
+		visitorRoutes.GET("/:website_id/:visitor_id/live", middleware.JWTMiddleware(), instance.WatchVisitor)
+	}
+}
+
+// ExportVisitor write identified visitors of website as newline delimited JSON
+// while they are read, oldest first
+func (instance *httpDelivery) ExportVisitor(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	websiteID := c.Param("website_id")
+	exists, err := instance.websiteUseCase.HasWebsite(userID, websiteID)
+	if err != nil {
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "export visitors failed"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "this website not exists"})
+		return
+	}
+
+	c.Writer.Header().Set("Content-Type", ndjson.ContentType)
+	c.Writer.WriteHeader(http.StatusOK)
+	writer := ndjson.NewWriter(c.Writer)
+	err = instance.visitorUseCase.StreamVisitor(userID, websiteID, func(aVisitor visitor) error {
+		if err := c.Request.Context().Err(); err != nil {
+			return err
+		}
+		return writer.Write(aVisitor)
+	})
+	if err != nil {
+		logrus.Error("stream visitors of website ", websiteID, " stopped after ", writer.Count(), " visitors ", err)
+	}
+}
+
+func (instance *httpDelivery) GetVisitor(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		return
+	}
+
+	aVisitor, err := instance.visitorUseCase.GetVisitor(userID, c.Param("website_id"), c.Param("visitor_id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, aVisitor)
+	case mongo.ErrNoDocuments:
+		c.JSON(http.StatusNotFound, gin.H{"error": "this visitor not exists"})
+	default:
+		logrus.Error(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get visitor failed"})
+	}
+}
+
+// WatchVisitor stream the page views and custom events of an identified
+// visitor as server-sent events while they arrive, so a support agent sees
+// what the customer is doing. Allowed when the store enables it and never to
+// viewers, every watch is written to the audit log before it starts
+func (instance *httpDelivery) WatchVisitor(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	if !instance.store.VisitorStream.Load() {
+		httperr.Abort(c, http.StatusForbidden, CodeVisitorStreamDisabled, ErrStreamDisabled.Error())
+		return
+	}
+	role, err := instance.userUseCase.AccountRole(tokenAuth)
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get user failed")
+		return
+	}
+	if !user.Can(role, user.PermissionReplay) {
+		httperr.Abort(c, http.StatusForbidden, httperr.CodeForbidden, ErrWatchForbidden.Error())
+		return
+	}
+
+	websiteID, visitorID := c.Param("website_id"), c.Param("visitor_id")
+	_, err = instance.visitorUseCase.GetVisitor(userID, websiteID, visitorID)
+	switch err {
+	case nil:
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeVisitorNotFound, "this visitor not exists")
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get visitor failed")
+		return
+	}
+
+	// a watch that cannot be traced is not served
+	_, err = instance.auditUseCase.RecordWatch(audit.ClientOf(c), userID, websiteID, visitorID)
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "record visitor watch failed")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), LiveWatchLimit)
+	defer cancel()
+	watched, err := instance.visitorUseCase.WatchLive(ctx, websiteID, visitorID)
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "watch visitor failed")
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	ping := time.NewTicker(livePingInterval)
+	defer ping.Stop()
+	c.SSEvent("ready", gin.H{"website_id": websiteID, "visitor_id": visitorID})
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case events, ok := <-watched:
+			if !ok {
+				return false
+			}
+			c.SSEvent("events", events)
+		case <-ping.C:
+			io.WriteString(w, ": ping\n\n")
+		}
+		return true
+	})
+}
@@ -0,0 +1,93 @@
+package visitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"analytics-api/configs"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrStreamDisabled ...
+var ErrStreamDisabled = errors.New("watching the live events of visitors is not enabled")
+
+// ErrWatchForbidden ...
+var ErrWatchForbidden = errors.New("viewers cannot watch the live events of visitors")
+
+// LiveEvent page view or custom event of an identified visitor, streamed to
+// the consoles watching them. Recordings of the page are never streamed
+type LiveEvent struct {
+	SessionID string                 `json:"session_id"`
+	Type      int64                  `json:"type"`
+	Tag       string                 `json:"tag,omitempty"`
+	Data      map[string]interface{} `json:"data"`
+	Timestamp int64                  `json:"timestamp"`
+}
+
+// PublishLive stream events of session to the consoles watching the visitor
+// identified in it, anonymous sessions are skipped. Errors are logged since
+// the events are stored already
+func (instance *useCase) PublishLive(websiteID, sessionID string, events []LiveEvent) {
+	if len(events) == 0 {
+		return
+	}
+	visitorID, err := instance.repo.GetSessionVisitor(sessionID)
+	if err != nil {
+		logrus.Error("get session visitor error ", err)
+		return
+	}
+	if visitorID == "" {
+		return
+	}
+	payload, err := json.Marshal(events)
+	if err != nil {
+		logrus.Error("encode live events error ", err)
+		return
+	}
+	if err := instance.repo.PublishLive(websiteID, visitorID, payload); err != nil {
+		logrus.Error("publish live events error ", err)
+	}
+}
+
+// WatchLive events of visitor of website published from now on by any
+// replica, until ctx is done. The channel is closed then
+func (instance *useCase) WatchLive(ctx context.Context, websiteID, visitorID string) (<-chan []LiveEvent, error) {
+	payloads, err := instance.repo.SubscribeLive(ctx, websiteID, visitorID)
+	if err != nil {
+		return nil, err
+	}
+	watched := make(chan []LiveEvent)
+	go func() {
+		defer close(watched)
+		for payload := range payloads {
+			var events []LiveEvent
+			if err := json.Unmarshal([]byte(payload), &events); err != nil {
+				logrus.Error("decode live events error ", err)
+				continue
+			}
+			select {
+			case watched <- events:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return watched, nil
+}
+
+// PublishLive send payload to the subscribers of visitor of website
+func (instance *repository) PublishLive(websiteID, visitorID string, payload []byte) error {
+	return configs.KeyStore.Client.Publish(instance.liveChannel(websiteID, visitorID), string(payload))
+}
+
+// SubscribeLive payloads published for visitor of website from now on, until
+// ctx is done. The channel is closed then
+func (instance *repository) SubscribeLive(ctx context.Context, websiteID, visitorID string) (<-chan string, error) {
+	return configs.KeyStore.Client.Subscribe(ctx, instance.liveChannel(websiteID, visitorID))
+}
+
+func (instance *repository) liveChannel(websiteID, visitorID string) string {
+	return instance.store.Key("visitor_live:" + websiteID + ":" + visitorID)
+}
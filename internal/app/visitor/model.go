@@ -0,0 +1,79 @@
+package visitor
+
+import (
+	"time"
+
+	"analytics-api/internal/app/crm"
+)
+
+// visitor identified by the site, with how they first reached it
+type visitor struct {
+	ID        string `json:"id" bson:"id"`
+	UserID    string `json:"-" bson:"user_id"`
+	WebsiteID string `json:"website_id" bson:"website_id"`
+	// SessionID of the session the visitor was first identified in
+	SessionID  string     `json:"session_id" bson:"session_id"`
+	FirstTouch FirstTouch `json:"first_touch" bson:"first_touch"`
+	// Sealed session id and first touch sealed with the store secrets, they
+	// are kept in the clear when DATA_MASTER_KEY is unset
+	Sealed    string `json:"-" bson:"sealed,omitempty"`
+	CreatedAt string `json:"created_at" bson:"created_at"`
+	UpdatedAt string `json:"updated_at" bson:"updated_at"`
+}
+
+// sealedVisitor what is sealed of a visitor
+type sealedVisitor struct {
+	SessionID  string     `json:"session_id"`
+	FirstTouch FirstTouch `json:"first_touch"`
+}
+
+// FirstTouch landing page, referrer and campaign of the first visit, kept by
+// the tracker until the visitor is identified and never changed afterwards
+type FirstTouch struct {
+	LandingPage string `json:"landing_page" bson:"landing_page"`
+	Referrer    string `json:"referrer,omitempty" bson:"referrer,omitempty"`
+	UTMSource   string `json:"utm_source,omitempty" bson:"utm_source,omitempty"`
+	UTMMedium   string `json:"utm_medium,omitempty" bson:"utm_medium,omitempty"`
+	UTMCampaign string `json:"utm_campaign,omitempty" bson:"utm_campaign,omitempty"`
+	UTMTerm     string `json:"utm_term,omitempty" bson:"utm_term,omitempty"`
+	UTMContent  string `json:"utm_content,omitempty" bson:"utm_content,omitempty"`
+	// Timestamp milliseconds of the first visit
+	Timestamp int64 `json:"timestamp" bson:"timestamp"`
+}
+
+// Identify visitor named by the site during a session
+type Identify struct {
+	VisitorID  string
+	SessionID  string
+	FirstTouch FirstTouch
+}
+
+// FormSubmit form submitted during a session, which reaches the form goals of
+// the visitor identified in it
+type FormSubmit struct {
+	SessionID string
+	FormID    string
+	// Timestamp milliseconds of the submission
+	Timestamp int64
+}
+
+// acquisition values of visitor pushed to CRMs
+func (instance visitor) acquisition() map[string]string {
+	touch := instance.FirstTouch
+	values := map[string]string{
+		crm.SourceLandingPage: touch.LandingPage,
+		crm.SourceReferrer:    touch.Referrer,
+		crm.SourceUTMSource:   touch.UTMSource,
+		crm.SourceUTMMedium:   touch.UTMMedium,
+		crm.SourceUTMCampaign: touch.UTMCampaign,
+		crm.SourceUTMTerm:     touch.UTMTerm,
+		crm.SourceUTMContent:  touch.UTMContent,
+	}
+	if touch.Timestamp > 0 {
+		values[crm.SourceFirstTouchAt] = time.UnixMilli(touch.Timestamp).UTC().Format(time.RFC3339)
+	}
+	return values
+}
+
+// IdentifiedEvent sent to the visitor webhook of the website
+const IdentifiedEvent = "visitor.identified"
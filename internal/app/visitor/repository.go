@@ -0,0 +1,149 @@
+package visitor
+
+import (
+	"context"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/keystore"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// sessionVisitorTTL keep the visitor of a session as long as its start time
+const sessionVisitorTTL = 24 * time.Hour
+
+// Repository ...
+type Repository interface {
+	UpsertVisitor(aVisitor visitor) (bool, error)
+	GetVisitor(userID, websiteID, visitorID string, aVisitor *visitor) error
+	StreamVisitor(userID, websiteID string, fn func(visitor) error) error
+	SetSessionVisitor(sessionID, visitorID string) error
+	GetSessionVisitor(sessionID string) (string, error)
+	PublishLive(websiteID, visitorID string, payload []byte) error
+	SubscribeLive(ctx context.Context, websiteID, visitorID string) (<-chan string, error)
+}
+
+type repository struct {
+	store *db.Store
+}
+
+// NewRepository ...
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store: store,
+	}
+}
+
+// UpsertVisitor insert visitor unless already identified, whose first touch is
+// then left as is. Reports whether the visitor is new
+func (instance *repository) UpsertVisitor(aVisitor visitor) (bool, error) {
+	visitorCollection := instance.store.Mongo.Collection(configs.MongoDB.VisitorCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": aVisitor.UserID},
+		{"website_id": aVisitor.WebsiteID},
+		{"id": aVisitor.ID},
+	}}
+	insert := bson.M{
+		"session_id":  aVisitor.SessionID,
+		"first_touch": aVisitor.FirstTouch,
+		"created_at":  aVisitor.CreatedAt,
+	}
+	if instance.store.Secrets != nil {
+		sealed, err := instance.store.Seal(sealedVisitor{SessionID: aVisitor.SessionID, FirstTouch: aVisitor.FirstTouch})
+		if err != nil {
+			return false, err
+		}
+		insert = bson.M{"sealed": sealed, "created_at": aVisitor.CreatedAt}
+	}
+	update := bson.M{
+		"$setOnInsert": insert,
+		"$set": bson.M{
+			"updated_at": time.Now().Format("2006-01-02, 15:04:05"),
+		},
+	}
+	result, err := visitorCollection.UpdateOne(context.TODO(), filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount > 0, nil
+}
+
+func (instance *repository) GetVisitor(userID, websiteID, visitorID string, aVisitor *visitor) error {
+	visitorCollection := instance.store.Mongo.Collection(configs.MongoDB.VisitorCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+		{"id": visitorID},
+	}}
+	err := visitorCollection.FindOne(context.TODO(), filter).Decode(aVisitor)
+	if err != nil {
+		return err
+	}
+	return instance.open(aVisitor)
+}
+
+// StreamVisitor call fn with each identified visitor of website, oldest first
+func (instance *repository) StreamVisitor(userID, websiteID string, fn func(visitor) error) error {
+	visitorCollection := instance.store.Mongo.Collection(configs.MongoDB.VisitorCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+	}}
+	opts := options.Find().SetSort(primitive.D{{Key: "created_at", Value: 1}, {Key: "id", Value: 1}})
+	cur, err := visitorCollection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(context.TODO())
+
+	for cur.Next(context.TODO()) {
+		var aVisitor visitor
+		if err := cur.Decode(&aVisitor); err != nil {
+			return err
+		}
+		if err := instance.open(&aVisitor); err != nil {
+			return err
+		}
+		if err := fn(aVisitor); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+// open the sealed session id and first touch of aVisitor
+func (instance *repository) open(aVisitor *visitor) error {
+	if aVisitor.Sealed == "" {
+		return nil
+	}
+	var aSealed sealedVisitor
+	if err := instance.store.Open(aVisitor.Sealed, &aSealed); err != nil {
+		return err
+	}
+	aVisitor.SessionID = aSealed.SessionID
+	aVisitor.FirstTouch = aSealed.FirstTouch
+	aVisitor.Sealed = ""
+	return nil
+}
+
+// SetSessionVisitor remember the visitor identified in session so its later
+// events are attributed to them
+func (instance *repository) SetSessionVisitor(sessionID, visitorID string) error {
+	return configs.KeyStore.Client.Set(instance.store.Key("visitor:"+sessionID), visitorID, sessionVisitorTTL)
+}
+
+// GetSessionVisitor visitor identified in session, empty when none
+func (instance *repository) GetSessionVisitor(sessionID string) (string, error) {
+	visitorID, err := configs.KeyStore.Client.Get(instance.store.Key("visitor:" + sessionID))
+	if err == keystore.ErrNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return visitorID, nil
+}
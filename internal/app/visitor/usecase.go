@@ -0,0 +1,152 @@
+package visitor
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"analytics-api/db"
+	"analytics-api/internal/app/crm"
+	"analytics-api/internal/app/goal"
+	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/webhook"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MaxVisitorID longer ids are not stored
+const MaxVisitorID = 200
+
+// UseCase ...
+type UseCase interface {
+	Identify(userID, websiteID string, identifies []Identify)
+	Reach(userID, websiteID string, submits []FormSubmit)
+	GetVisitor(userID, websiteID, visitorID string) (*visitor, error)
+	StreamVisitor(userID, websiteID string, fn func(visitor) error) error
+	PublishLive(websiteID, sessionID string, events []LiveEvent)
+	WatchLive(ctx context.Context, websiteID, visitorID string) (<-chan []LiveEvent, error)
+}
+
+type useCase struct {
+	repo           Repository
+	websiteUseCase website.UseCase
+	goalUseCase    goal.UseCase
+	crmUseCase     crm.UseCase
+	webhook        *webhook.Client
+}
+
+// NewUseCase ...
+func NewUseCase(store *db.Store) UseCase {
+	return &useCase{
+		repo:           NewRepository(store),
+		websiteUseCase: website.NewUseCase(store),
+		goalUseCase:    goal.NewUseCase(store),
+		crmUseCase:     crm.NewUseCase(store),
+		webhook:        webhook.NewClient(),
+	}
+}
+
+// Identify store visitors identified in a session with their first touch and
+// tell the visitor webhook and the CRM of website about new ones. Errors are
+// logged, never returned, since tracking must not fail because of them
+func (instance *useCase) Identify(userID, websiteID string, identifies []Identify) {
+	for _, anIdentify := range identifies {
+		visitorID := strings.TrimSpace(anIdentify.VisitorID)
+		if visitorID == "" || len(visitorID) > MaxVisitorID {
+			continue
+		}
+		now := time.Now().Format("2006-01-02, 15:04:05")
+		aVisitor := visitor{
+			ID:         visitorID,
+			UserID:     userID,
+			WebsiteID:  websiteID,
+			SessionID:  anIdentify.SessionID,
+			FirstTouch: anIdentify.FirstTouch,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		created, err := instance.repo.UpsertVisitor(aVisitor)
+		if err != nil {
+			logrus.Error("identify visitor error ", err)
+			continue
+		}
+		err = instance.repo.SetSessionVisitor(anIdentify.SessionID, visitorID)
+		if err != nil {
+			logrus.Error("set session visitor error ", err)
+		}
+		if !created {
+			continue
+		}
+		instance.crmUseCase.Push(userID, websiteID, visitorID, aVisitor.acquisition())
+
+		url, secret, err := instance.websiteUseCase.GetVisitorWebhook(userID, websiteID)
+		if err != nil {
+			logrus.Error("get visitor webhook error ", err)
+			continue
+		}
+		if url == "" {
+			continue
+		}
+		err = instance.webhook.Post(url, secret, IdentifiedEvent, aVisitor)
+		if err != nil {
+			logrus.Error("visitor webhook error ", err)
+		}
+	}
+}
+
+// Reach push the form goals reached by the identified visitors of submits to
+// the CRM of website. Submits of anonymous sessions are skipped
+func (instance *useCase) Reach(userID, websiteID string, submits []FormSubmit) {
+	// visitor of each submit, in order so the last goal pushed is the latest
+	visitorIDs := make([]string, len(submits))
+	identified := false
+	for i, aSubmit := range submits {
+		visitorID, err := instance.repo.GetSessionVisitor(aSubmit.SessionID)
+		if err != nil {
+			logrus.Error("get session visitor error ", err)
+			continue
+		}
+		visitorIDs[i] = visitorID
+		identified = identified || visitorID != ""
+	}
+	if !identified {
+		return
+	}
+	goals, err := instance.goalUseCase.GetAllGoal(userID, websiteID)
+	if err != nil {
+		logrus.Error("get goals error ", err)
+		return
+	}
+
+	for i, visitorID := range visitorIDs {
+		if visitorID == "" {
+			continue
+		}
+		for _, aGoal := range goals {
+			if aGoal.Type != goal.TypeForm || aGoal.Target != submits[i].FormID {
+				continue
+			}
+			instance.crmUseCase.Push(userID, websiteID, visitorID, map[string]string{
+				crm.SourceLastGoal:   aGoal.Name,
+				crm.SourceLastGoalAt: time.UnixMilli(submits[i].Timestamp).UTC().Format(time.RFC3339),
+			})
+		}
+	}
+}
+
+func (instance *useCase) GetVisitor(userID, websiteID, visitorID string) (*visitor, error) {
+	var aVisitor visitor
+	err := instance.repo.GetVisitor(userID, websiteID, visitorID, &aVisitor)
+	if err != nil {
+		return nil, err
+	}
+	return &aVisitor, nil
+}
+
+func (instance *useCase) StreamVisitor(userID, websiteID string, fn func(visitor) error) error {
+	err := instance.repo.StreamVisitor(userID, websiteID, fn)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package website
+
+import (
+	"net/http"
+
+	"analytics-api/internal/app/audit"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// audited record action of the handler after it on the website of the
+// route, with the fields it changed when the website is there before and
+// after. Requests answered with an error are not recorded
+func (instance *httpDelivery) audited(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// the handler answers requests not signed in
+		tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+		if err != nil {
+			return
+		}
+		accountID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+		if err != nil {
+			return
+		}
+		websiteID := c.Param("website_id")
+		var before, after website
+		beforeErr := instance.websiteUseCase.GetWebsite(accountID, websiteID, &before)
+
+		c.Next()
+		if c.Writer.Status() >= http.StatusBadRequest {
+			return
+		}
+		afterErr := instance.websiteUseCase.GetWebsite(accountID, websiteID, &after)
+		if beforeErr != nil && afterErr != nil {
+			return
+		}
+		var changes map[string]audit.Change
+		if beforeErr == nil && afterErr == nil {
+			changes, err = audit.Diff(before, after)
+			if err != nil {
+				logrus.Error("diff website error ", err)
+			}
+		}
+		instance.recordChange(c, tokenAuth, action, websiteID, changes)
+	}
+}
+
+// recordChange keep a trace of action on website by the user signed in with
+// tokenAuth, not the account it acts for, a failure is logged and the
+// request still succeeds
+func (instance *httpDelivery) recordChange(c *gin.Context, tokenAuth *security.TokenDetails, action, websiteID string, changes map[string]audit.Change) {
+	userID, err := instance.authUsecase.GetUser(tokenAuth)
+	if err != nil {
+		logrus.Error("get user of website change error ", err)
+		return
+	}
+	_, err = instance.auditUseCase.RecordChange(audit.ClientOf(c), userID, action, websiteID, c.Request.Method+" "+c.FullPath(), changes)
+	if err != nil {
+		logrus.Error("record website change error ", err)
+	}
+}
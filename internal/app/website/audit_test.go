@@ -0,0 +1,98 @@
+package website
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"analytics-api/internal/app/audit"
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// fakeAuth every token acts for the org-1 account
+type fakeAuth struct {
+	auth.UseCase
+}
+
+func (instance *fakeAuth) GetAuth(accessUUID string) (string, error) {
+	return "org-1", nil
+}
+
+func (instance *fakeAuth) GetUser(tokenAuth *security.TokenDetails) (string, error) {
+	return tokenAuth.UserID, nil
+}
+
+// fakeAuditLog keeps the users and addresses changes are recorded for
+type fakeAuditLog struct {
+	audit.UseCase
+	users []string
+	ips   []string
+}
+
+func (instance *fakeAuditLog) RecordChange(client audit.Client, userID, action, websiteID, route string, changes map[string]audit.Change) (string, error) {
+	instance.users = append(instance.users, userID)
+	instance.ips = append(instance.ips, client.IP)
+	return "", nil
+}
+
+// fakeAccountWebsites the org-1 account owns every website
+type fakeAccountWebsites struct {
+	UseCase
+}
+
+func (instance *fakeAccountWebsites) GetWebsite(userID, websiteID string, aWebsite *website) error {
+	if userID != "org-1" {
+		return mongo.ErrNoDocuments
+	}
+	aWebsite.ID = websiteID
+	return nil
+}
+
+func TestAudited(t *testing.T) {
+	t.Setenv("ACCESS_SECRET", "secret")
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		status  int
+		want    []string
+		wantIPs []string
+	}{
+		{name: "should record the member acting for the organization from the address of the connection", status: http.StatusOK, want: []string{"member-1"}, wantIPs: []string{"203.0.113.7"}},
+		{name: "should not record a request answered with an error", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := &fakeAuditLog{}
+			instance := &httpDelivery{websiteUseCase: &fakeAccountWebsites{}, authUsecase: &fakeAuth{}, auditUseCase: log}
+			router := gin.New()
+			if err := router.SetTrustedProxies(nil); err != nil {
+				t.Fatalf("SetTrustedProxies() error = %v", err)
+			}
+			router.PATCH("/website/:website_id", instance.audited(audit.ActionWebsiteUpdate), func(c *gin.Context) {
+				c.Status(tt.status)
+			})
+
+			token, err := security.CreateOrgToken("member-1", "", "org-1")
+			if err != nil {
+				t.Fatalf("CreateOrgToken() error = %v", err)
+			}
+			r := httptest.NewRequest(http.MethodPatch, "/website/w1", nil)
+			r.Header.Set("Authorization", "Bearer "+token.AccessToken)
+			r.Header.Set("X-Forwarded-For", "198.51.100.1")
+			r.RemoteAddr = "203.0.113.7:4000"
+			router.ServeHTTP(httptest.NewRecorder(), r)
+
+			if !reflect.DeepEqual(log.users, tt.want) {
+				t.Errorf("recorded users = %v, want %v", log.users, tt.want)
+			}
+			if !reflect.DeepEqual(log.ips, tt.wantIPs) {
+				t.Errorf("recorded addresses = %v, want %v", log.ips, tt.wantIPs)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package website
+
+import (
+	"context"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/s3"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// purgeBatch deletions handled by one run of PurgeDeleted
+const purgeBatch = 20
+
+// purgeAttempts failed purges after which a deletion is left for an operator
+// to retry, so a website that cannot be purged does not fail every run
+const purgeAttempts = 8
+
+// maxPurgeBackoff longest wait between two attempts of a failing purge
+const maxPurgeBackoff = 24 * time.Hour
+
+// RestoreWindow time a deleted website can be restored, its data is purged
+// once it is over
+const RestoreWindow = 30 * 24 * time.Hour
+
+// QueueDeletion queue removing the data of website once purgeAfter is past, a
+// website deleted twice is queued once
+func (instance *repository) QueueDeletion(userID, websiteID string, purgeAfter time.Time) error {
+	deletionCollection := instance.store.Mongo.Collection(configs.MongoDB.DeletionCollection)
+	update := bson.M{"$setOnInsert": bson.M{"created_at": time.Now(), "purge_after": purgeAfter, "next_attempt_at": purgeAfter, "attempts": 0}}
+	opts := options.Update().SetUpsert(true)
+	_, err := deletionCollection.UpdateOne(context.TODO(), deletionFilter(userID, websiteID), update, opts)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListDeletions queued deletions past their restore window and due for an
+// attempt, up to limit, the longest due first, but those of held websites and
+// those out of attempts. Deletions queued before the window or the retries
+// have no purge_after or next_attempt_at and are due
+func (instance *repository) ListDeletions(limit int64, held []string) ([]deletion, error) {
+	deletionCollection := instance.store.Mongo.Collection(configs.MongoDB.DeletionCollection)
+	now := time.Now()
+	filter := bson.M{"$and": []bson.M{
+		{"$or": []bson.M{
+			{"purge_after": bson.M{"$lte": now}},
+			{"purge_after": bson.M{"$exists": false}},
+		}},
+		{"$or": []bson.M{
+			{"next_attempt_at": bson.M{"$lte": now}},
+			{"next_attempt_at": bson.M{"$exists": false}},
+		}},
+		{"attempts": bson.M{"$lt": purgeAttempts}},
+		{"website_id": bson.M{"$nin": held}},
+	}}
+	opts := options.Find().SetSort(primitive.D{{Key: "next_attempt_at", Value: 1}, {Key: "created_at", Value: 1}}).SetLimit(limit)
+	cursor, err := deletionCollection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var deletions []deletion
+	if err = cursor.All(context.TODO(), &deletions); err != nil {
+		return nil, err
+	}
+	return deletions, nil
+}
+
+// GetDeletion get the queued deletion of website
+func (instance *repository) GetDeletion(userID, websiteID string, aDeletion *deletion) error {
+	deletionCollection := instance.store.Mongo.Collection(configs.MongoDB.DeletionCollection)
+	err := deletionCollection.FindOne(context.TODO(), deletionFilter(userID, websiteID)).Decode(aDeletion)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// HasDeletion report whether the data of website is still being removed
+func (instance *repository) HasDeletion(userID, websiteID string) (bool, error) {
+	deletionCollection := instance.store.Mongo.Collection(configs.MongoDB.DeletionCollection)
+	count, err := deletionCollection.CountDocuments(context.TODO(), deletionFilter(userID, websiteID))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// FailDeletion record a failed attempt, the deletion is tried again at
+// nextAttemptAt
+func (instance *repository) FailDeletion(userID, websiteID, lastError string, nextAttemptAt time.Time) error {
+	deletionCollection := instance.store.Mongo.Collection(configs.MongoDB.DeletionCollection)
+	update := bson.M{
+		"$inc": bson.M{"attempts": 1},
+		"$set": bson.M{"last_error": lastError, "next_attempt_at": nextAttemptAt},
+	}
+	_, err := deletionCollection.UpdateOne(context.TODO(), deletionFilter(userID, websiteID), update)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RetryDeletions give the deletions out of attempts their attempts back, due
+// now. Returns the deletions retried
+func (instance *repository) RetryDeletions() (int64, error) {
+	deletionCollection := instance.store.Mongo.Collection(configs.MongoDB.DeletionCollection)
+	filter := bson.M{"attempts": bson.M{"$gte": purgeAttempts}}
+	update := bson.M{"$set": bson.M{"attempts": 0, "next_attempt_at": time.Now()}}
+	result, err := deletionCollection.UpdateMany(context.TODO(), filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// RemoveDeletion drop a deletion done
+func (instance *repository) RemoveDeletion(userID, websiteID string) error {
+	deletionCollection := instance.store.Mongo.Collection(configs.MongoDB.DeletionCollection)
+	_, err := deletionCollection.DeleteOne(context.TODO(), deletionFilter(userID, websiteID))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func deletionFilter(userID, websiteID string) bson.M {
+	return bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+	}}
+}
+
+// PurgeDeleted remove the data of websites deleted for longer than
+// RestoreWindow: sessions and their events, goals, calculated metrics,
+// identified visitors, ad platform integrations and their delivery logs, the
+// CRM mapping, the counters of aggregate-only mode, usage and reconciliation
+// counts, the archive and its manifests, and at last the website itself.
+// Websites under legal hold wait for its release. A failing purge is tried
+// again after a backoff, up to purgeAttempts times. Return the deletions done
+// and those failing
+func (instance *useCase) PurgeDeleted() (int, int, error) {
+	held, err := instance.HeldWebsiteIDs()
+	if err != nil {
+		return 0, 0, err
+	}
+	deletions, err := instance.repo.ListDeletions(purgeBatch, held)
+	if err != nil {
+		return 0, 0, err
+	}
+	purged, failed := 0, 0
+	for _, aDeletion := range deletions {
+		if err := instance.purge(aDeletion.UserID, aDeletion.WebsiteID); err != nil {
+			logrus.Error("purge website id ", aDeletion.WebsiteID, " error ", err)
+			failed++
+			if aDeletion.Attempts+1 >= purgeAttempts {
+				logrus.Error("purge website id ", aDeletion.WebsiteID, " gave up after ", purgeAttempts, " attempts")
+			}
+			err := instance.repo.FailDeletion(aDeletion.UserID, aDeletion.WebsiteID, err.Error(), time.Now().Add(purgeBackoff(aDeletion.Attempts+1)))
+			if err != nil {
+				return purged, failed, err
+			}
+			continue
+		}
+		if err := instance.repo.RemoveDeletion(aDeletion.UserID, aDeletion.WebsiteID); err != nil {
+			return purged, failed, err
+		}
+		purged++
+	}
+	return purged, failed, nil
+}
+
+// RetryFailedDeletions queue again the deletions out of attempts, once what
+// made them fail is fixed
+func (instance *useCase) RetryFailedDeletions() (int64, error) {
+	return instance.repo.RetryDeletions()
+}
+
+// purgeBackoff wait after the failed attempt of a purge, doubling from a
+// minute up to maxPurgeBackoff
+func purgeBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > 20 {
+		return maxPurgeBackoff
+	}
+	return min(time.Minute<<(attempts-1), maxPurgeBackoff)
+}
+
+// purge every step deletes all that matches, so a deletion failing half way
+// is safe to run again. A website that is not deleted, restored or never
+// hidden, is left alone and only its deletion dropped
+func (instance *useCase) purge(userID, websiteID string) error {
+	var aWebsite website
+	err := instance.repo.GetDeletedWebsite(userID, websiteID, &aWebsite)
+	if err == mongo.ErrNoDocuments {
+		live, err := instance.repo.FindWebsiteByID(userID, websiteID)
+		if err != nil {
+			return err
+		}
+		if live > 0 {
+			logrus.Warn("skip purge of live website id ", websiteID)
+			return nil
+		}
+	} else if err != nil {
+		return err
+	}
+
+	steps := []func(userID, websiteID string) error{
+		instance.repo.DeleteSession,
+		instance.repo.DeleteEvents,
+		instance.repo.DeleteGoal,
+		instance.repo.DeleteMetric,
+		instance.repo.DeleteVisitor,
+		instance.repo.DeleteIntegrations,
+		instance.repo.DeleteCRMMapping,
+		instance.repo.DeleteAggregates,
+		instance.repo.DeleteUsage,
+		instance.repo.DeleteArchive,
+		instance.repo.RemoveWebsite,
+	}
+	for _, step := range steps {
+		if err := step(userID, websiteID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteUsage remove the usage and reconciliation counts of website
+func (instance *repository) DeleteUsage(userID, websiteID string) error {
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+	}}
+	for _, name := range []string{configs.MongoDB.UsageCollection, configs.MongoDB.ReconciliationCollection} {
+		deleteResult, err := instance.store.Mongo.Collection(name).DeleteMany(context.TODO(), filter)
+		if err != nil {
+			return err
+		}
+		logrus.Printf("deleted %v documents in the %s collection\n", deleteResult.DeletedCount, name)
+	}
+	return nil
+}
+
+// archivedDay files of a manifest of the archive, as much as a purge reads
+type archivedDay struct {
+	Day   string `bson:"day"`
+	Files []struct {
+		Key string `bson:"key"`
+	} `bson:"files"`
+}
+
+// DeleteArchive remove the archived files of website from S3 then their
+// manifests, a day at a time so a failure leaves the days not deleted yet
+// listed. Without the archive configured only the manifests go
+func (instance *repository) DeleteArchive(userID, websiteID string) error {
+	archiveCollection := instance.store.Mongo.Collection(configs.MongoDB.ArchiveCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+	}}
+	if !configs.ArchiveEnabled() {
+		deleteResult, err := archiveCollection.DeleteMany(context.TODO(), filter)
+		if err != nil {
+			return err
+		}
+		logrus.Printf("deleted %v documents in the archive collection\n", deleteResult.DeletedCount)
+		return nil
+	}
+
+	cursor, err := archiveCollection.Find(context.TODO(), filter)
+	if err != nil {
+		return err
+	}
+	var days []archivedDay
+	if err = cursor.All(context.TODO(), &days); err != nil {
+		return err
+	}
+	client := s3.NewClient(configs.Archive.Bucket, configs.Archive.Region, configs.Archive.Endpoint,
+		configs.Archive.AccessKeyID, configs.Archive.SecretAccessKey)
+	for _, aDay := range days {
+		for _, aFile := range aDay.Files {
+			if err := client.Delete(aFile.Key); err != nil {
+				return err
+			}
+		}
+		dayFilter := bson.M{"$and": []bson.M{
+			{"user_id": userID},
+			{"website_id": websiteID},
+			{"day": aDay.Day},
+		}}
+		if _, err := archiveCollection.DeleteOne(context.TODO(), dayFilter); err != nil {
+			return err
+		}
+	}
+	logrus.Printf("deleted %v days in the archive collection\n", len(days))
+	return nil
+}
+
+// RunPurge remove the data of the websites deleted in store every interval,
+// until the process exits
+func RunPurge(store *db.Store, interval time.Duration) {
+	useCase := NewUseCase(store)
+	for range time.Tick(interval) {
+		purged, failed, err := useCase.PurgeDeleted()
+		if err != nil {
+			logrus.Error("purge deleted websites error ", err)
+			continue
+		}
+		if purged+failed > 0 {
+			logrus.Info("purged deleted websites, done ", purged, " failed ", failed)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package website
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var errStep = errors.New("step failed")
+
+// fakeDeletions records the calls deleting and purging websites, failing
+// the one named in fail
+type fakeDeletions struct {
+	Repository
+	held      bool
+	deleted   bool
+	live      bool
+	fail      string
+	calls     []string
+	deletions []deletion
+	failedAt  time.Time
+}
+
+func (instance *fakeDeletions) call(name string) error {
+	instance.calls = append(instance.calls, name)
+	if instance.fail == name {
+		return errStep
+	}
+	return nil
+}
+
+func (instance *fakeDeletions) GetWebsite(userID, websiteID string, aWebsite *website) error {
+	if instance.held {
+		aWebsite.LegalHold = &legalHold{}
+	}
+	return nil
+}
+
+func (instance *fakeDeletions) DeleteWebsite(userID, websiteID string) error {
+	return instance.call("hide")
+}
+
+func (instance *fakeDeletions) QueueDeletion(userID, websiteID string, purgeAfter time.Time) error {
+	return instance.call("queue")
+}
+
+func (instance *fakeDeletions) RestoreWebsite(userID, websiteID string) error {
+	return instance.call("show")
+}
+
+func (instance *fakeDeletions) GetHeldWebsite() (*websites, error) {
+	return &websites{}, nil
+}
+
+func (instance *fakeDeletions) ListDeletions(limit int64, held []string) ([]deletion, error) {
+	return instance.deletions, nil
+}
+
+func (instance *fakeDeletions) GetDeletedWebsite(userID, websiteID string, aWebsite *website) error {
+	if !instance.deleted {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (instance *fakeDeletions) FindWebsiteByID(userID, websiteID string) (int64, error) {
+	if instance.live {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (instance *fakeDeletions) FailDeletion(userID, websiteID, lastError string, nextAttemptAt time.Time) error {
+	instance.failedAt = nextAttemptAt
+	return instance.call("fail")
+}
+
+func (instance *fakeDeletions) RemoveDeletion(userID, websiteID string) error {
+	return instance.call("done")
+}
+
+func (instance *fakeDeletions) DeleteSession(userID, websiteID string) error {
+	return instance.call("sessions")
+}
+
+func (instance *fakeDeletions) DeleteEvents(userID, websiteID string) error {
+	return instance.call("events")
+}
+
+func (instance *fakeDeletions) DeleteGoal(userID, websiteID string) error {
+	return instance.call("goals")
+}
+
+func (instance *fakeDeletions) DeleteMetric(userID, websiteID string) error {
+	return instance.call("metrics")
+}
+
+func (instance *fakeDeletions) DeleteVisitor(userID, websiteID string) error {
+	return instance.call("visitors")
+}
+
+func (instance *fakeDeletions) DeleteIntegrations(userID, websiteID string) error {
+	return instance.call("integrations")
+}
+
+func (instance *fakeDeletions) DeleteCRMMapping(userID, websiteID string) error {
+	return instance.call("crm")
+}
+
+func (instance *fakeDeletions) DeleteAggregates(userID, websiteID string) error {
+	return instance.call("aggregates")
+}
+
+func (instance *fakeDeletions) DeleteUsage(userID, websiteID string) error {
+	return instance.call("usage")
+}
+
+func (instance *fakeDeletions) DeleteArchive(userID, websiteID string) error {
+	return instance.call("archive")
+}
+
+func (instance *fakeDeletions) RemoveWebsite(userID, websiteID string) error {
+	return instance.call("website")
+}
+
+func TestDeleteWebsite(t *testing.T) {
+	tests := []struct {
+		name      string
+		held      bool
+		fail      string
+		wantCalls []string
+		wantErr   error
+	}{
+		{name: "should hide the website then queue its deletion", wantCalls: []string{"hide", "queue"}},
+		{name: "should show the website again when queueing fails", fail: "queue", wantCalls: []string{"hide", "queue", "show"}, wantErr: errStep},
+		{name: "should not queue a website it failed to hide", fail: "hide", wantCalls: []string{"hide"}, wantErr: errStep},
+		{name: "should refuse a website under legal hold", held: true, wantErr: ErrLegalHold},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeDeletions{held: tt.held, fail: tt.fail}
+			instance := &useCase{repo: repo}
+			if err := instance.DeleteWebsite("user-1", "website-1"); err != tt.wantErr {
+				t.Fatalf("DeleteWebsite() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(repo.calls, tt.wantCalls) {
+				t.Errorf("DeleteWebsite() calls = %v, want %v", repo.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	steps := []string{"sessions", "events", "goals", "metrics", "visitors", "integrations", "crm", "aggregates", "usage", "archive", "website"}
+	tests := []struct {
+		name        string
+		live        bool
+		deleted     bool
+		fail        string
+		attempts    int
+		wantCalls   []string
+		wantPurged  int
+		wantFailed  int
+		wantBackoff time.Duration
+	}{
+		{name: "should delete every data of the website then the deletion", deleted: true, wantCalls: append(append([]string{}, steps...), "done"), wantPurged: 1},
+		{name: "should finish a purge whose website is already removed", wantCalls: append(append([]string{}, steps...), "done"), wantPurged: 1},
+		{name: "should leave a live website alone and drop its deletion", live: true, wantCalls: []string{"done"}, wantPurged: 1},
+		{name: "should stop at a failing step and retry in a minute", deleted: true, fail: "archive", wantCalls: append(append([]string{}, steps[:10]...), "fail"), wantFailed: 1, wantBackoff: time.Minute},
+		{name: "should back off longer after each attempt", deleted: true, fail: "sessions", attempts: 3, wantCalls: []string{"sessions", "fail"}, wantFailed: 1, wantBackoff: 8 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeDeletions{live: tt.live, deleted: tt.deleted, fail: tt.fail,
+				deletions: []deletion{{UserID: "user-1", WebsiteID: "website-1", Attempts: tt.attempts}}}
+			instance := &useCase{repo: repo}
+			before := time.Now()
+			purged, failed, err := instance.PurgeDeleted()
+			if err != nil {
+				t.Fatalf("PurgeDeleted() error = %v", err)
+			}
+			if purged != tt.wantPurged || failed != tt.wantFailed {
+				t.Errorf("PurgeDeleted() = %d, %d, want %d, %d", purged, failed, tt.wantPurged, tt.wantFailed)
+			}
+			if !reflect.DeepEqual(repo.calls, tt.wantCalls) {
+				t.Errorf("PurgeDeleted() calls = %v, want %v", repo.calls, tt.wantCalls)
+			}
+			if tt.wantBackoff > 0 {
+				if backoff := repo.failedAt.Sub(before); backoff < tt.wantBackoff || backoff > tt.wantBackoff+time.Minute {
+					t.Errorf("PurgeDeleted() retries in %v, want %v", backoff, tt.wantBackoff)
+				}
+			}
+		})
+	}
+}
+
+func TestPurgeBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		want     time.Duration
+	}{
+		{name: "should wait a minute after the first attempt", attempts: 1, want: time.Minute},
+		{name: "should double after each attempt", attempts: 4, want: 8 * time.Minute},
+		{name: "should wait at most a day", attempts: 12, want: maxPurgeBackoff},
+		{name: "should not overflow", attempts: 80, want: maxPurgeBackoff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := purgeBackoff(tt.attempts); got != tt.want {
+				t.Errorf("purgeBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package website
 
 import (
+	"analytics-api/db"
+	"analytics-api/internal/app/audit"
 	"analytics-api/internal/app/auth"
+	"analytics-api/internal/app/user"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,13 +21,45 @@ type HTTPDelivery interface {
 	GetAllWebsite(c *gin.Context)
 	Tracking(c *gin.Context)
 	AddWebsite(c *gin.Context)
+	ImportWebsite(c *gin.Context)
+	UpdateWebsite(c *gin.Context)
 	DeleteWebsite(c *gin.Context)
+	RestoreWebsite(c *gin.Context)
+	TransferWebsite(c *gin.Context)
+	CancelTransfer(c *gin.Context)
+	ListTransfers(c *gin.Context)
+	AcceptTransfer(c *gin.Context)
+	DeclineTransfer(c *gin.Context)
+	UpdateFeatures(c *gin.Context)
+	UpdateTimezone(c *gin.Context)
+	UpdateContentGroups(c *gin.Context)
+	UpdateSettings(c *gin.Context)
+	UpdateAggregateOnly(c *gin.Context)
+	UpdateArchived(c *gin.Context)
+	UpdateTrackingID(c *gin.Context)
+	GetAudit(c *gin.Context)
+	GetServerKeys(c *gin.Context)
+	CreateServerKey(c *gin.Context)
+	RotateServerKey(c *gin.Context)
+	RevokeServerKey(c *gin.Context)
+	VerifyWebsite(c *gin.Context)
+	UpdateShare(c *gin.Context)
+	UpdateAliases(c *gin.Context)
+	GetTags(c *gin.Context)
+	AddTag(c *gin.Context)
+	RenameTag(c *gin.Context)
+	DeleteTag(c *gin.Context)
+	TrackerConfig(c *gin.Context)
+	GetOnboarding(c *gin.Context)
 }
 
 // NewHTTPDelivery ...
-func NewHTTPDelivery() HTTPDelivery {
+func NewHTTPDelivery(store *db.Store) HTTPDelivery {
 	return &httpDelivery{
-		websiteUseCase: NewUseCase(),
-		authUsecase:    auth.NewUseCase(),
+		store:          store,
+		websiteUseCase: NewUseCase(store),
+		authUsecase:    auth.NewUseCase(store),
+		userUseCase:    user.NewUseCase(store),
+		auditUseCase:   audit.NewUseCase(store),
 	}
 }
@@ -2,41 +2,83 @@ package website
 
 import (
 	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/app/audit"
 	"analytics-api/internal/app/auth"
+	"analytics-api/internal/app/user"
+	"analytics-api/internal/pkg/confirm"
+	"analytics-api/internal/pkg/cursor"
+	"analytics-api/internal/pkg/etag"
+	"analytics-api/internal/pkg/fields"
+	"analytics-api/internal/pkg/httperr"
 	"analytics-api/internal/pkg/middleware"
+	"analytics-api/internal/pkg/pathgroup"
+	req "analytics-api/internal/pkg/request"
 	"analytics-api/internal/pkg/security"
-	str "analytics-api/internal/pkg/string"
+	"analytics-api/internal/pkg/verify"
+	"analytics-api/internal/pkg/webhook"
 	"net/http"
-	"time"
+	"net/url"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type httpDelivery struct {
+	store          *db.Store
 	websiteUseCase UseCase
 	authUsecase    auth.UseCase
+	userUseCase    user.UseCase
+	auditUseCase   audit.UseCase
 }
 
-// var validate = validator.New()
-
-// type RequestWebsite struct {
-// 	Name string `json:"name" validate:"required,min=2,max=100"`
-// 	URL  string `json:"url" validate:"required,min=3"`
-// }
-
 // InitRoutes ...
 func (instance *httpDelivery) InitRoutes(r *gin.RouterGroup) {
 	websiteRoutes := r.Group("/website")
 	{
 		websiteRoutes.GET("/dashboard", middleware.JWTMiddleware(), instance.Dashboard)
 		websiteRoutes.GET("/:website_id", middleware.JWTMiddleware(), instance.GetWebsite)
+		websiteRoutes.PATCH("/:website_id", middleware.JWTMiddleware(), instance.audited(audit.ActionWebsiteUpdate), instance.UpdateWebsite)
+		websiteRoutes.GET("/:website_id/audit", middleware.JWTMiddleware(), instance.GetAudit)
+		websiteRoutes.PUT("/:website_id/settings", middleware.JWTMiddleware(), instance.audited(audit.ActionWebsiteUpdate), instance.UpdateSettings)
+		websiteRoutes.POST("/aliases/:website_id", middleware.JWTMiddleware(), instance.audited(audit.ActionWebsiteUpdate), instance.UpdateAliases)
 		websiteRoutes.GET("/list", middleware.JWTMiddleware(), instance.GetAllWebsite)
+		websiteRoutes.GET("/tags", middleware.JWTMiddleware(), instance.GetTags)
+		websiteRoutes.POST("/tags/:tag", middleware.JWTMiddleware(), instance.AddTag)
+		websiteRoutes.PUT("/tags/:tag", middleware.JWTMiddleware(), instance.RenameTag)
+		websiteRoutes.DELETE("/tags/:tag", middleware.JWTMiddleware(), instance.DeleteTag)
 		websiteRoutes.GET("/tracking/:website_id", middleware.JWTMiddleware(), instance.Tracking)
 
 		websiteRoutes.GET("/add", middleware.JWTMiddleware(), instance.ShowAddWebsite)
 		websiteRoutes.POST("/add", middleware.JWTMiddleware(), instance.AddWebsite)
+		websiteRoutes.POST("/import", middleware.JWTMiddleware(), instance.ImportWebsite)
+
+		websiteRoutes.GET("/delete/:website_id", middleware.JWTMiddleware(), instance.audited(audit.ActionWebsiteDelete), instance.DeleteWebsite)
+		websiteRoutes.POST("/restore", middleware.JWTMiddleware(), instance.RestoreWebsite)
+
+		websiteRoutes.POST("/transfer/:website_id", middleware.JWTMiddleware(), instance.audited(audit.ActionWebsiteTransfer), instance.TransferWebsite)
+		websiteRoutes.DELETE("/transfer/:website_id", middleware.JWTMiddleware(), instance.audited(audit.ActionWebsiteTransfer), instance.CancelTransfer)
+		websiteRoutes.GET("/transfers", middleware.JWTMiddleware(), instance.ListTransfers)
+		websiteRoutes.POST("/transfers/accept/:website_id", middleware.JWTMiddleware(), instance.audited(audit.ActionWebsiteTransfer), instance.AcceptTransfer)
+		websiteRoutes.POST("/transfers/decline/:website_id", middleware.JWTMiddleware(), instance.DeclineTransfer)
 
-		websiteRoutes.GET("/delete/:website_id", middleware.JWTMiddleware(), instance.DeleteWebsite)
+		websiteRoutes.POST("/features/:website_id", middleware.JWTMiddleware(), instance.audited(audit.ActionWebsiteUpdate), instance.UpdateFeatures)
+		websiteRoutes.POST("/timezone/:website_id", middleware.JWTMiddleware(), instance.audited(audit.ActionWebsiteUpdate), instance.UpdateTimezone)
+		websiteRoutes.POST("/content-groups/:website_id", middleware.JWTMiddleware(), instance.audited(audit.ActionWebsiteUpdate), instance.UpdateContentGroups)
+		websiteRoutes.POST("/visitor-webhook/:website_id", middleware.JWTMiddleware(), instance.audited(audit.ActionWebsiteUpdate), instance.UpdateVisitorWebhook)
+		websiteRoutes.POST("/segment-key/:website_id", middleware.JWTMiddleware(), instance.audited(audit.ActionWebsiteUpdate), instance.RotateSegmentWriteKey)
+		websiteRoutes.GET("/server-keys/:website_id", middleware.JWTMiddleware(), instance.GetServerKeys)
+		websiteRoutes.POST("/server-keys/:website_id", middleware.JWTMiddleware(), instance.audited(audit.ActionWebsiteUpdate), instance.CreateServerKey)
+		websiteRoutes.POST("/server-keys/:website_id/:key_id/rotate", middleware.JWTMiddleware(), instance.audited(audit.ActionWebsiteUpdate), instance.RotateServerKey)
+		websiteRoutes.DELETE("/server-keys/:website_id/:key_id", middleware.JWTMiddleware(), instance.audited(audit.ActionWebsiteUpdate), instance.RevokeServerKey)
+		websiteRoutes.POST("/aggregate-only/:website_id", middleware.JWTMiddleware(), instance.audited(audit.ActionWebsiteUpdate), instance.UpdateAggregateOnly)
+		websiteRoutes.POST("/archived/:website_id", middleware.JWTMiddleware(), instance.audited(audit.ActionWebsiteUpdate), instance.UpdateArchived)
+		websiteRoutes.POST("/tracking-id/:website_id", middleware.JWTMiddleware(), instance.audited(audit.ActionWebsiteUpdate), instance.UpdateTrackingID)
+		websiteRoutes.POST("/verify/:website_id", middleware.JWTMiddleware(), instance.VerifyWebsite)
+		websiteRoutes.POST("/share/:website_id", middleware.JWTMiddleware(), instance.audited(audit.ActionWebsiteUpdate), instance.UpdateShare)
+		websiteRoutes.GET("/config/:website_id", instance.TrackerConfig)
+		websiteRoutes.GET("/onboarding/:website_id", middleware.JWTMiddleware(), instance.GetOnboarding)
 	}
 }
 
@@ -54,20 +96,47 @@ func (instance *httpDelivery) Tracking(c *gin.Context) {
 
 	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
 		return
 	}
 
 	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	appURL := configs.AppURL
+	if instance.store.TenantID != "" {
+		// the tracker must post to the tenant hostname
+		appURL = "https://" + c.Request.Host
+	}
+
+	var aWebsite website
+	err = instance.websiteUseCase.GetWebsite(userID, websiteID, &aWebsite)
+	if err == mongo.ErrNoDocuments {
+		c.HTML(http.StatusNotFound, "404.html", gin.H{})
+		return
+	}
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "500.html", gin.H{})
 		return
 	}
+	var verification gin.H
+	if !aWebsite.Verified() {
+		verification = gin.H{
+			"TXTRecord": verify.TXTRecord(aWebsite.VerificationToken),
+			"MetaTag":   verify.MetaTag(aWebsite.VerificationToken),
+		}
+	}
 
 	c.HTML(http.StatusOK, "tracking.html", gin.H{
-		"URL":       configs.AppURL,
-		"UserID":    userID,
-		"WebsiteID": websiteID,
+		"URL":          appURL,
+		"UserID":       userID,
+		"WebsiteID":    websiteID,
+		"TrackingID":   aWebsite.TrackingID,
+		"HostName":     aWebsite.HostName,
+		"Verification": verification,
 	})
 }
 
@@ -76,139 +145,1540 @@ func (instance *httpDelivery) GetWebsite(c *gin.Context) {
 	var aWebsite website
 	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
 		return
 	}
 
 	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
 		return
 	}
 
 	getWebsiteErr := instance.websiteUseCase.GetWebsite(userID, websiteID, &aWebsite)
+	if getWebsiteErr == mongo.ErrNoDocuments {
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+		return
+	}
 	if getWebsiteErr != nil {
-		c.HTML(http.StatusInternalServerError, "500.html", gin.H{})
+		logrus.Error(c, getWebsiteErr)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get website failed")
 		return
 	}
 
-	c.JSON(http.StatusOK, aWebsite)
+	response, err := fields.Select(aWebsite, fields.Parse(c.Query("fields")))
+	if err != nil {
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "select fields failed")
+		return
+	}
+	etag.Set(c, aWebsite.Version)
+	c.JSON(http.StatusOK, response)
+}
+
+// tagLink tag of the list with the link adding it to the selected tags, or
+// taking it out when selected
+type tagLink struct {
+	tagCount
+	Selected bool
+	Link     string
+}
+
+// tagLinks links of tags toggling each in selected, keeping the other
+// parameters of query
+func tagLinks(tags []tagCount, selected []string, query url.Values) []tagLink {
+	links := make([]tagLink, len(tags))
+	for i, aTag := range tags {
+		toggled := []string{}
+		for _, tag := range selected {
+			if tag != aTag.Tag {
+				toggled = append(toggled, tag)
+			}
+		}
+		isSelected := len(toggled) < len(selected)
+		if !isSelected {
+			toggled = append(toggled, aTag.Tag)
+		}
+		values := url.Values{}
+		for key, value := range query {
+			values[key] = value
+		}
+		values["tag"] = toggled
+		links[i] = tagLink{tagCount: aTag, Selected: isSelected, Link: "/website/list?" + values.Encode()}
+	}
+	return links
 }
 
 func (instance *httpDelivery) GetAllWebsite(c *gin.Context) {
 	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
 		return
 	}
 
 	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
 		return
 	}
 
-	websites, err := instance.websiteUseCase.GetAllWebsite(userID)
+	// ?tag= lists the websites of a tag only, repeated those carrying all
+	// the tags, like a client and an environment
+	selected := NormalizeTags(c.QueryArray("tag"))
+	websites, err := instance.websiteUseCase.GetScopedWebsite(userID, middleware.Scope(c), selected...)
 	if err != nil {
-		c.HTML(http.StatusInternalServerError, "500.html", gin.H{})
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get websites failed")
+		return
+	}
+	// ?include=stats adds the rollups of every website
+	includeStats := c.Query("include") == "stats"
+	if includeStats {
+		err = instance.websiteUseCase.AttachStats(userID, websites)
+		if err != nil {
+			logrus.Error(c, err)
+			httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get website stats failed")
+			return
+		}
+	}
+
+	// ?fields= and json clients get the list itself, shaped like GetWebsite
+	list := fields.Parse(c.Query("fields"))
+	if len(list) > 0 || c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+		response, err := fields.Select(websites, list)
+		if err != nil {
+			httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "select fields failed")
+			return
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	tags, err := instance.websiteUseCase.GetTags(userID, middleware.Scope(c))
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get tags failed")
 		return
 	}
 
-	if len(*websites) == 0 {
+	if len(*websites) == 0 && len(selected) == 0 {
 		c.HTML(http.StatusOK, "website.html", gin.H{})
+		return
 	}
 
 	c.HTML(http.StatusOK, "websites.html", gin.H{
 		"Websites": websites,
+		"Tags":     tagLinks(tags, selected, c.Request.URL.Query()),
+		"Selected": selected,
+		"Stats":    includeStats,
 	})
 }
 
-func (instance *httpDelivery) AddWebsite(c *gin.Context) {
-	url := c.PostForm("url")
-	category := c.PostForm("category")
+// GetTags tags of the websites of the user with how many carry each
+func (instance *httpDelivery) GetTags(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
 
-	hostName, err := str.ParseURL(url)
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
 	if err != nil {
-		c.HTML(http.StatusInternalServerError, "500.html", gin.H{})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	tags, err := instance.websiteUseCase.GetTags(userID, middleware.Scope(c))
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get tags failed")
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
 
+// RequestAddTag websites to tag
+type RequestAddTag struct {
+	WebsiteIDs []string `json:"website_ids" validate:"required,min=1,max=200"`
+}
+
+// AddTag tag several websites at once
+func (instance *httpDelivery) AddTag(c *gin.Context) {
 	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
 		return
 	}
 
 	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
 		return
 	}
 
-	count, err := instance.websiteUseCase.FindWebsite(userID, hostName)
+	request, err := req.BindAndValidate[RequestAddTag](c)
 	if err != nil {
-		c.HTML(http.StatusInternalServerError, "500.html", gin.H{})
+		req.BadRequest(c, "invalid tag", err)
+		return
+	}
+
+	count, err := instance.websiteUseCase.AddTag(userID, c.Param("tag"), request.WebsiteIDs)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{"websites": count})
+	case ErrInvalidTag:
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "add tag failed")
+	}
+}
+
+// RequestRenameTag new name of a tag
+type RequestRenameTag struct {
+	Name string `json:"name" validate:"required,max=30"`
+}
+
+// RenameTag rename a tag on all the websites carrying it
+func (instance *httpDelivery) RenameTag(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
 		return
 	}
 
-	if count > 0 {
-		c.JSON(http.StatusConflict, gin.H{"msg": "this website already exists"})
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
 		return
-	} else {
+	}
 
-		websiteID := str.GetMD5Hash(hostName)
-		if err != nil {
-			c.HTML(http.StatusInternalServerError, "500.html", gin.H{})
-			return
-		}
+	request, err := req.BindAndValidate[RequestRenameTag](c)
+	if err != nil {
+		req.BadRequest(c, "invalid tag", err)
+		return
+	}
 
-		createdAt := time.Now().Format("2006-01-02, 15:04:05")
+	count, err := instance.websiteUseCase.RenameTag(userID, c.Param("tag"), request.Name)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{"websites": count})
+	case ErrInvalidTag:
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+	case ErrTagNotFound:
+		httperr.Abort(c, http.StatusNotFound, CodeTagNotFound, err.Error())
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "rename tag failed")
+	}
+}
 
-		aWebsite := website{
-			ID:        websiteID,
-			UserID:    userID,
-			Category:  category,
-			HostName:  hostName,
-			URL:       url,
-			CreatedAt: createdAt,
-			UpdatedAt: createdAt,
-		}
+// DeleteTag remove a tag from all the websites carrying it
+func (instance *httpDelivery) DeleteTag(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
 
-		insertErr := instance.websiteUseCase.InsertWebsite(userID, aWebsite)
-		if insertErr != nil {
-			c.HTML(http.StatusInternalServerError, "500.html", gin.H{})
-			return
-		}
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	_, err = instance.websiteUseCase.DeleteTag(userID, c.Param("tag"))
+	switch err {
+	case nil:
+		c.Status(http.StatusNoContent)
+	case ErrTagNotFound:
+		httperr.Abort(c, http.StatusNotFound, CodeTagNotFound, err.Error())
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "delete tag failed")
+	}
+}
+
+// RequestWebsite form adding a website
+type RequestWebsite struct {
+	Name     string `form:"name" validate:"max=100"`
+	URL      string `form:"url" validate:"required,website_url,public_host"`
+	Category string `form:"category"`
+	Preset   string `form:"preset"`
+	Timezone string `form:"timezone"`
+	// AggregateOnly keep only daily counters of the website from its first batch
+	AggregateOnly bool `form:"aggregate_only"`
+}
+
+func (instance *httpDelivery) AddWebsite(c *gin.Context) {
+	request, err := req.BindFormAndValidate[RequestWebsite](c)
+	if err != nil {
+		req.BadRequest(c, "invalid website", err)
+		return
+	}
+	url := request.URL
+	category := request.Category
+	if request.Preset == "" {
+		request.Preset = DefaultPreset
+	}
+	aPreset, ok := Presets[request.Preset]
+	if !ok {
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, ErrUnknownPreset.Error())
+		return
+	}
+	// a timezone given with the preset wins over the one of the preset
+	timezone := request.Timezone
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
 
+	plan, limit, ok := instance.websiteLimit(c, userID)
+	if !ok {
+		return
+	}
+	aWebsite, err := instance.websiteUseCase.AddWebsite(userID, limit, request.Name, url, category, timezone, aPreset, request.AggregateOnly)
+	switch err {
+	case nil:
+		instance.recordChange(c, tokenAuth, audit.ActionWebsiteCreate, aWebsite.ID, nil)
 		c.Redirect(http.StatusMovedPermanently, "/website/list")
+	case ErrWebsiteExists:
+		httperr.Abort(c, http.StatusConflict, CodeWebsiteExists, err.Error())
+	case ErrWebsiteQuota:
+		httperr.AbortWithDetails(c, http.StatusPaymentRequired, CodeWebsiteQuota, err.Error(), gin.H{"plan": plan, "limit": limit})
+	case ErrInvalidTimezone:
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "add website failed")
+	}
+}
+
+// ImportWebsite add the websites of a JSON array or a CSV, every row is tried
+// and reported, the import only fails as a whole when it cannot be read
+func (instance *httpDelivery) ImportWebsite(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	var rows []ImportRow
+	if c.ContentType() == "text/csv" {
+		rows, err = ReadImportCSV(c.Request.Body)
+	} else if err = c.ShouldBindJSON(&rows); err != nil || len(rows) > MaxImportRows {
+		err = ErrInvalidImport
+	}
+	if err != nil {
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	_, limit, ok := instance.websiteLimit(c, userID)
+	if !ok {
+		return
+	}
+	results := instance.websiteUseCase.ImportWebsite(userID, limit, rows)
+	created := 0
+	for _, aResult := range results {
+		if aResult.Status == ImportCreated {
+			instance.recordChange(c, tokenAuth, audit.ActionWebsiteCreate, aResult.WebsiteID, nil)
+			created++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"created": created,
+		"failed":  len(results) - created,
+		"results": results,
+	})
+}
+
+// RequestUpdateWebsite fields of a website to change, absent ones are kept
+type RequestUpdateWebsite struct {
+	Name     *string `json:"name" validate:"omitempty,min=1,max=100"`
+	URL      *string `json:"url" validate:"omitempty,website_url,public_host"`
+	Category *string `json:"category" validate:"omitempty,max=50"`
+	// Tags replace the tags of the website, an empty list removes them
+	Tags *[]string `json:"tags" validate:"omitempty,max=20,dive,min=1,max=30"`
+}
+
+// UpdateWebsite change name, url, category and tags of website, the host
+// name follows the url. With If-Match the website is only changed at that
+// version
+func (instance *httpDelivery) UpdateWebsite(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	request, err := req.BindAndValidate[RequestUpdateWebsite](c)
+	if err != nil {
+		req.BadRequest(c, "invalid website", err)
+		return
+	}
+	version, err := etag.IfMatch(c)
+	if err != nil {
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	aWebsite, err := instance.websiteUseCase.UpdateWebsite(userID, websiteID, request.Name, request.URL, request.Category, request.Tags, version)
+	switch err {
+	case nil:
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+		return
+	case ErrWebsiteExists:
+		httperr.Abort(c, http.StatusConflict, CodeWebsiteExists, err.Error())
+		return
+	case etag.ErrConflict:
+		httperr.Abort(c, http.StatusConflict, etag.CodeConflict, err.Error())
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "update website failed")
+		return
 	}
+
+	etag.Set(c, aWebsite.Version)
+	c.JSON(http.StatusOK, aWebsite)
 }
 
 func (instance *httpDelivery) DeleteWebsite(c *gin.Context) {
 	websiteID := c.Param("website_id")
 	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "extract token metadata failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
 		return
 	}
 
 	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "get token auth failed"})
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	// the first call only shows what would be lost, the delete goes on when
+	// the confirmation token of that call comes back
+	confirmErr := instance.websiteUseCase.ConfirmDelete(userID, websiteID, c.Query("confirm"))
+	switch confirmErr {
+	case nil:
+	case confirm.ErrInvalid:
+		instance.prepareDelete(c, userID, websiteID, c.Query("confirm") != "")
+		return
+	default:
+		logrus.Error(c, confirmErr)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "confirm delete website failed")
 		return
 	}
 
+	// the data of the website is removed in the background by RunPurge once
+	// the restore window is over
 	deleteWebsiteErr := instance.websiteUseCase.DeleteWebsite(userID, websiteID)
+	if deleteWebsiteErr == mongo.ErrNoDocuments {
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+		return
+	}
+	if deleteWebsiteErr == ErrLegalHold {
+		httperr.Abort(c, http.StatusConflict, CodeLegalHold, deleteWebsiteErr.Error())
+		return
+	}
 	if deleteWebsiteErr != nil {
-		c.HTML(http.StatusInternalServerError, "500.html", gin.H{})
+		logrus.Error(c, deleteWebsiteErr)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "delete website failed")
 		return
 	}
 
-	deleteSessionErr := instance.websiteUseCase.DeleteSession(userID, websiteID)
-	if deleteSessionErr != nil {
-		c.HTML(http.StatusInternalServerError, "500.html", gin.H{})
+	c.Redirect(http.StatusMovedPermanently, "/website/list")
+}
+
+// RequestRestoreWebsite ...
+type RequestRestoreWebsite struct {
+	WebsiteID string `json:"website_id" validate:"required"`
+}
+
+// RestoreWebsite bring back a website deleted within RestoreWindow
+func (instance *httpDelivery) RestoreWebsite(c *gin.Context) {
+	request, err := req.BindAndValidate[RequestRestoreWebsite](c)
+	if err != nil {
+		req.BadRequest(c, "invalid restore", err)
 		return
 	}
 
-	c.Redirect(http.StatusMovedPermanently, "/website/list")
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	if !instance.checkQuota(c, userID) {
+		return
+	}
+
+	aWebsite, err := instance.websiteUseCase.RestoreWebsite(userID, request.WebsiteID)
+	switch err {
+	case nil:
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotDeleted, "this website is not deleted")
+		return
+	case ErrRestoreExpired:
+		httperr.Abort(c, http.StatusGone, CodeRestoreExpired, err.Error())
+		return
+	case ErrWebsiteExists:
+		httperr.Abort(c, http.StatusConflict, CodeWebsiteExists, err.Error())
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "restore website failed")
+		return
+	}
+
+	instance.recordChange(c, tokenAuth, audit.ActionWebsiteRestore, aWebsite.ID, nil)
+	c.JSON(http.StatusOK, aWebsite)
+}
+
+// RequestTransfer ...
+type RequestTransfer struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// TransferWebsite offer a website to the account signed up with an email, it
+// changes hands once that account accepts
+func (instance *httpDelivery) TransferWebsite(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	request, err := req.BindAndValidate[RequestTransfer](c)
+	if err != nil {
+		req.BadRequest(c, "invalid transfer", err)
+		return
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	toUserID, err := instance.userUseCase.FindUserID(request.Email)
+	switch err {
+	case nil:
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeAccountNotFound, "no account signed up with this email")
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "transfer website failed")
+		return
+	}
+
+	aTransfer, err := instance.websiteUseCase.RequestTransfer(userID, websiteID, toUserID, request.Email)
+	switch err {
+	case nil:
+	case ErrTransferToSelf:
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+		return
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "transfer website failed")
+		return
+	}
+	c.JSON(http.StatusOK, aTransfer)
+}
+
+// CancelTransfer withdraw the pending transfer of a website
+func (instance *httpDelivery) CancelTransfer(c *gin.Context) {
+	websiteID := c.Param("website_id")
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	err = instance.websiteUseCase.CancelTransfer(userID, websiteID)
+	switch err {
+	case nil:
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "cancel transfer failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ListTransfers websites offered to the signed in account
+func (instance *httpDelivery) ListTransfers(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	listWebsite, err := instance.websiteUseCase.ListTransfers(userID)
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "list transfers failed")
+		return
+	}
+	transfers := []pendingTransfer{}
+	for _, aWebsite := range listWebsite {
+		from, err := instance.userUseCase.GetEmail(aWebsite.UserID)
+		if err != nil && err != mongo.ErrNoDocuments {
+			logrus.Error(c, err)
+			httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "list transfers failed")
+			return
+		}
+		transfers = append(transfers, pendingTransfer{
+			WebsiteID: aWebsite.ID,
+			Name:      aWebsite.Name,
+			URL:       aWebsite.URL,
+			From:      from,
+			ExpiresAt: aWebsite.Transfer.ExpiresAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"transfers": transfers})
+}
+
+// AcceptTransfer take over a website offered to the signed in account with
+// all its data
+func (instance *httpDelivery) AcceptTransfer(c *gin.Context) {
+	websiteID := c.Param("website_id")
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	if !instance.checkQuota(c, userID) {
+		return
+	}
+
+	aWebsite, err := instance.websiteUseCase.AcceptTransfer(userID, websiteID)
+	switch err {
+	case nil:
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeTransferNotFound, "this transfer not exists")
+		return
+	case ErrWebsiteExists, ErrDeletionPending:
+		httperr.Abort(c, http.StatusConflict, CodeWebsiteExists, err.Error())
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "accept transfer failed")
+		return
+	}
+	logrus.Info("transferred website id ", websiteID, " to user id ", userID)
+	c.JSON(http.StatusOK, aWebsite)
+}
+
+// DeclineTransfer refuse a website offered to the signed in account
+func (instance *httpDelivery) DeclineTransfer(c *gin.Context) {
+	websiteID := c.Param("website_id")
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	err = instance.websiteUseCase.DeclineTransfer(userID, websiteID)
+	switch err {
+	case nil:
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeTransferNotFound, "this transfer not exists")
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "decline transfer failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// checkQuota answer 402 with the plan of user and its limit when user may
+// not have one more website, false once answered
+// websiteLimit plan of user with the websites it allows, false once the
+// error is answered
+func (instance *httpDelivery) websiteLimit(c *gin.Context, userID string) (string, int64, bool) {
+	plan, err := instance.userUseCase.GetPlan(userID)
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get user failed")
+		return "", 0, false
+	}
+	return plan, user.WebsiteLimit(plan), true
+}
+
+func (instance *httpDelivery) checkQuota(c *gin.Context, userID string) bool {
+	plan, limit, ok := instance.websiteLimit(c, userID)
+	if !ok {
+		return false
+	}
+	err := instance.websiteUseCase.CheckQuota(userID, limit)
+	switch err {
+	case nil:
+		return true
+	case ErrWebsiteQuota:
+		httperr.AbortWithDetails(c, http.StatusPaymentRequired, CodeWebsiteQuota, err.Error(), gin.H{"plan": plan, "limit": limit})
+		return false
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "check website quota failed")
+		return false
+	}
+}
+
+// prepareDelete answer 428 with the impact of deleting website and the token
+// confirming it, json clients get the token and browsers a confirm page
+func (instance *httpDelivery) prepareDelete(c *gin.Context, userID, websiteID string, expired bool) {
+	aImpact, token, err := instance.websiteUseCase.PrepareDelete(userID, websiteID)
+	switch err {
+	case nil:
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "prepare delete website failed")
+		return
+	}
+
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEJSON {
+		message := "confirm the deletion of this website"
+		if expired {
+			message = confirm.ErrInvalid.Error()
+		}
+		httperr.AbortWithDetails(c, http.StatusPreconditionRequired, CodeConfirmationRequired, message, gin.H{
+			"confirm_token": token,
+			"expires_in":    int(confirm.TTL.Seconds()),
+			"impact":        aImpact,
+		})
+		return
+	}
+	c.HTML(http.StatusPreconditionRequired, "delete_website.html", gin.H{
+		"WebsiteID": websiteID,
+		"Token":     token,
+		"Minutes":   int(confirm.TTL.Minutes()),
+		"Impact":    aImpact,
+		"Expired":   expired,
+	})
+}
+
+// UpdateFeatures toggle tracker features of website
+func (instance *httpDelivery) UpdateFeatures(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	aFeatures, err := req.BindAndValidate[features](c)
+	if err != nil {
+		req.BadRequest(c, "invalid features", err)
+		return
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	updateErr := instance.websiteUseCase.UpdateFeatures(userID, websiteID, &aFeatures)
+	if updateErr == mongo.ErrNoDocuments {
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+		return
+	}
+	if updateErr != nil {
+		logrus.Error(c, updateErr)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "update features failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, aFeatures)
+}
+
+// RequestTimezone ...
+type RequestTimezone struct {
+	Timezone string `json:"timezone"`
+}
+
+// UpdateTimezone set timezone reports of website are computed in
+func (instance *httpDelivery) UpdateTimezone(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	request, err := req.BindAndValidate[RequestTimezone](c)
+	if err != nil {
+		req.BadRequest(c, "invalid timezone", err)
+		return
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	updateErr := instance.websiteUseCase.UpdateTimezone(userID, websiteID, request.Timezone)
+	if updateErr == ErrInvalidTimezone {
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, updateErr.Error())
+		return
+	}
+	if updateErr == mongo.ErrNoDocuments {
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+		return
+	}
+	if updateErr != nil {
+		logrus.Error(c, updateErr)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "update timezone failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// RequestAggregateOnly ...
+type RequestAggregateOnly struct {
+	Enabled bool `json:"enabled"`
+}
+
+// UpdateAggregateOnly switch aggregate-only mode of a website, batches
+// received once it is on only add to the daily counters of /aggregate
+func (instance *httpDelivery) UpdateAggregateOnly(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	request, err := req.BindAndValidate[RequestAggregateOnly](c)
+	if err != nil {
+		req.BadRequest(c, "invalid enabled", err)
+		return
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	err = instance.websiteUseCase.UpdateAggregateOnly(userID, websiteID, request.Enabled)
+	switch err {
+	case nil:
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "update aggregate-only failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"aggregate_only": request.Enabled})
+}
+
+// RequestArchived ...
+type RequestArchived struct {
+	Archived bool `json:"archived"`
+}
+
+// UpdateArchived archive a website no longer tracked, or bring it back. Its
+// collection answers 410 while its reports stay readable
+func (instance *httpDelivery) UpdateArchived(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	request, err := req.BindAndValidate[RequestArchived](c)
+	if err != nil {
+		req.BadRequest(c, "invalid archived", err)
+		return
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	aWebsite, err := instance.websiteUseCase.UpdateArchived(userID, websiteID, request.Archived)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, aWebsite)
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "update archived failed")
+	}
+}
+
+// RequestTrackingID ...
+type RequestTrackingID struct {
+	TrackingID string `json:"tracking_id" validate:"max=30"`
+}
+
+// UpdateTrackingID name a website with a readable tracking id, like
+// acme-prod, the snippet can send instead of its id. Empty removes it
+func (instance *httpDelivery) UpdateTrackingID(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	request, err := req.BindAndValidate[RequestTrackingID](c)
+	if err != nil {
+		req.BadRequest(c, "invalid tracking id", err)
+		return
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	aWebsite, err := instance.websiteUseCase.UpdateTrackingID(userID, websiteID, request.TrackingID)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, aWebsite)
+	case ErrInvalidTrackingID:
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+	case ErrTrackingIDTaken:
+		httperr.Abort(c, http.StatusConflict, CodeTrackingIDTaken, err.Error())
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "update tracking id failed")
+	}
+}
+
+// GetAudit latest changes made to a website, newest first, with who made
+// them and the fields they changed
+func (instance *httpDelivery) GetAudit(c *gin.Context) {
+	websiteID := c.Param("website_id")
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	var aWebsite website
+	err = instance.websiteUseCase.GetWebsite(userID, websiteID, &aWebsite)
+	if err == mongo.ErrNoDocuments {
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+		return
+	}
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get audit entries failed")
+		return
+	}
+
+	limit := cursor.Limit(c.Query("limit"), 50, 500)
+	entries, err := instance.auditUseCase.GetWebsiteEntries(userID, websiteID, int64(limit))
+	if err != nil {
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get audit entries failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// RequestServerKey ...
+type RequestServerKey struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// GetServerKeys keys backends send the events of a website with, without
+// their secrets
+func (instance *httpDelivery) GetServerKeys(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	keys, err := instance.websiteUseCase.GetServerKeys(userID, c.Param("website_id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{"server_keys": keys})
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get server keys failed")
+	}
+}
+
+// CreateServerKey add a server key to a website, its secret is in this
+// response only
+func (instance *httpDelivery) CreateServerKey(c *gin.Context) {
+	request, err := req.BindAndValidate[RequestServerKey](c)
+	if err != nil {
+		req.BadRequest(c, "invalid server key", err)
+		return
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	aKey, err := instance.websiteUseCase.CreateServerKey(userID, c.Param("website_id"), request.Name)
+	switch err {
+	case nil:
+		c.JSON(http.StatusCreated, aKey)
+	case ErrInvalidKeyName, ErrTooManyServerKeys:
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "create server key failed")
+	}
+}
+
+// RotateServerKey reply a new secret for a server key, the previous one
+// stops working
+func (instance *httpDelivery) RotateServerKey(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	aKey, err := instance.websiteUseCase.RotateServerKey(userID, c.Param("website_id"), c.Param("key_id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, aKey)
+	case ErrServerKeyNotFound:
+		httperr.Abort(c, http.StatusNotFound, CodeServerKeyNotFound, err.Error())
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "rotate server key failed")
+	}
+}
+
+// RevokeServerKey remove a server key of a website
+func (instance *httpDelivery) RevokeServerKey(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	err = instance.websiteUseCase.RevokeServerKey(userID, c.Param("website_id"), c.Param("key_id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{"revoked": true})
+	case ErrServerKeyNotFound:
+		httperr.Abort(c, http.StatusNotFound, CodeServerKeyNotFound, err.Error())
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "revoke server key failed")
+	}
+}
+
+// RequestShare ...
+type RequestShare struct {
+	Enabled  bool  `json:"enabled"`
+	Private  bool  `json:"private"`
+	MinCount int64 `json:"min_count" validate:"omitempty,min=2,max=1000"`
+}
+
+// UpdateShare share the stats of a website with a public link, optionally
+// private so small counts are noised and rare values suppressed
+func (instance *httpDelivery) UpdateShare(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	request, err := req.BindAndValidate[RequestShare](c)
+	if err != nil {
+		req.BadRequest(c, "invalid share", err)
+		return
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	aShare, err := instance.websiteUseCase.UpdateShare(userID, websiteID, request.Enabled, request.Private, request.MinCount)
+	switch err {
+	case nil:
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "update share failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"share": aShare})
+}
+
+// RequestVerify ...
+type RequestVerify struct {
+	Method string `json:"method" validate:"omitempty,oneof=dns meta"`
+}
+
+// VerifyWebsite check the TXT record or the meta tag proving the ownership of
+// a website, its batches are refused until it passes
+func (instance *httpDelivery) VerifyWebsite(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	request, err := req.BindAndValidate[RequestVerify](c)
+	if err != nil {
+		req.BadRequest(c, "invalid verification", err)
+		return
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	aWebsite, err := instance.websiteUseCase.VerifyWebsite(userID, websiteID, request.Method)
+	switch err {
+	case nil:
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+		return
+	case verify.ErrTXTNotFound, verify.ErrMetaNotFound, verify.ErrPageUnreachable, ErrVerificationFailed:
+		httperr.Abort(c, http.StatusUnprocessableEntity, CodeVerificationFailed, err.Error())
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "verify website failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"verified": true, "verified_at": aWebsite.VerifiedAt})
+}
+
+// RequestAliases ...
+type RequestAliases struct {
+	Aliases []string `json:"aliases" validate:"max=20,dive,hostname_syntax"`
+}
+
+// UpdateAliases replace the other host names a website is served from, like
+// www or staging, batches sent from them are counted to the website
+func (instance *httpDelivery) UpdateAliases(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	request, err := req.BindAndValidate[RequestAliases](c)
+	if err != nil {
+		req.BadRequest(c, "invalid aliases", err)
+		return
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	aliases, err := instance.websiteUseCase.UpdateAliases(userID, websiteID, request.Aliases)
+	switch err {
+	case nil:
+	case ErrInvalidAlias:
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+		return
+	case ErrWebsiteExists:
+		httperr.Abort(c, http.StatusConflict, CodeWebsiteExists, err.Error())
+		return
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "update aliases failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"aliases": aliases})
+}
+
+// RequestSettings ...
+type RequestSettings struct {
+	Timezone    string   `json:"timezone"`
+	ExcludedIPs []string `json:"excluded_ips" validate:"max=100"`
+	FilterBots  bool     `json:"filter_bots"`
+	SpamAllowed []string `json:"spam_allowed" validate:"max=100"`
+	SpamBlocked []string `json:"spam_blocked" validate:"max=100"`
+	// AllowedCountries and ExcludedCountries ISO 3166-1 alpha-2 codes
+	AllowedCountries  []string `json:"allowed_countries" validate:"max=250"`
+	ExcludedCountries []string `json:"excluded_countries" validate:"max=250"`
+}
+
+// UpdateSettings replace the settings of a website: the timezone reports are
+// computed in, the IPs whose traffic is dropped, whether bots are, the
+// referrer spam domains it allows or blocks besides the spam list and the
+// countries traffic is collected from
+func (instance *httpDelivery) UpdateSettings(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	request, err := req.BindAndValidate[RequestSettings](c)
+	if err != nil {
+		req.BadRequest(c, "invalid settings", err)
+		return
+	}
+	version, err := etag.IfMatch(c)
+	if err != nil {
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	aSettings, updated, err := instance.websiteUseCase.UpdateSettings(userID, websiteID, Settings{
+		Timezone:          request.Timezone,
+		ExcludedIPs:       request.ExcludedIPs,
+		FilterBots:        request.FilterBots,
+		SpamAllowed:       request.SpamAllowed,
+		SpamBlocked:       request.SpamBlocked,
+		AllowedCountries:  request.AllowedCountries,
+		ExcludedCountries: request.ExcludedCountries,
+	}, version)
+	switch err {
+	case nil:
+	case ErrInvalidTimezone, ErrInvalidExcludedIPs, ErrInvalidSpamDomains, ErrInvalidCountries:
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, err.Error())
+		return
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+		return
+	case etag.ErrConflict:
+		httperr.Abort(c, http.StatusConflict, etag.CodeConflict, err.Error())
+		return
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "update settings failed")
+		return
+	}
+
+	etag.Set(c, updated)
+	c.JSON(http.StatusOK, aSettings)
+}
+
+// RequestContentGroups ...
+type RequestContentGroups struct {
+	ContentGroups []pathgroup.Rule `json:"content_groups"`
+}
+
+// RequestVisitorWebhook ...
+type RequestVisitorWebhook struct {
+	URL string `json:"url" validate:"omitempty,reachable"`
+}
+
+// UpdateVisitorWebhook set the endpoint told of newly identified visitors and
+// reply the secret signing its deliveries, an empty url removes it
+func (instance *httpDelivery) UpdateVisitorWebhook(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	request, err := req.BindAndValidate[RequestVisitorWebhook](c)
+	if err != nil {
+		req.BadRequest(c, "invalid webhook", err)
+		return
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	secret, updateErr := instance.websiteUseCase.UpdateVisitorWebhook(userID, websiteID, request.URL)
+	if updateErr == webhook.ErrInvalidURL {
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, updateErr.Error())
+		return
+	}
+	if updateErr == mongo.ErrNoDocuments {
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+		return
+	}
+	if updateErr != nil {
+		logrus.Error(c, updateErr)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "update visitor webhook failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": request.URL, "secret": secret})
+}
+
+// RotateSegmentWriteKey reply a new write key for the Segment calls of website
+func (instance *httpDelivery) RotateSegmentWriteKey(c *gin.Context) {
+	websiteID := c.Param("website_id")
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	writeKey, updateErr := instance.websiteUseCase.RotateSegmentWriteKey(userID, websiteID)
+	if updateErr == mongo.ErrNoDocuments {
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+		return
+	}
+	if updateErr != nil {
+		logrus.Error(c, updateErr)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "rotate segment write key failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"write_key": writeKey})
+}
+
+// UpdateContentGroups replace the path rules grouping pages of website in reports
+func (instance *httpDelivery) UpdateContentGroups(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	request, err := req.BindAndValidate[RequestContentGroups](c)
+	if err != nil {
+		req.BadRequest(c, "invalid content groups", err)
+		return
+	}
+
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	updateErr := instance.websiteUseCase.UpdateContentGroups(userID, websiteID, request.ContentGroups)
+	if updateErr == pathgroup.ErrInvalidRule || updateErr == ErrTooManyContentGroups {
+		httperr.Abort(c, http.StatusBadRequest, httperr.CodeInvalidRequest, updateErr.Error())
+		return
+	}
+	if updateErr == mongo.ErrNoDocuments {
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+		return
+	}
+	if updateErr != nil {
+		logrus.Error(c, updateErr)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "update content groups failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// TrackerConfig config fetched by the tracking script on load
+func (instance *httpDelivery) TrackerConfig(c *gin.Context) {
+	// the snippet may name the website by its tracking id
+	websiteID, err := instance.websiteUseCase.CanonicalID(c.Param("website_id"))
+	if err != nil {
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get tracker config failed")
+		return
+	}
+
+	aFeatures, err := instance.websiteUseCase.GetFeatures(websiteID)
+	if err == mongo.ErrNoDocuments {
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+		return
+	}
+	if err != nil {
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get tracker config failed")
+		return
+	}
+
+	aggregateOnly, err := instance.websiteUseCase.GetAggregateOnly(websiteID)
+	if err != nil {
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get tracker config failed")
+		return
+	}
+
+	// the snippet asks for its config once it runs on a page
+	instance.websiteUseCase.MarkOnboarding(websiteID, StepSnippetInstalled)
+
+	c.Header("Cache-Control", "public, max-age=120")
+	c.JSON(http.StatusOK, gin.H{
+		"website_id":     websiteID,
+		"features":       aFeatures,
+		"aggregate_only": aggregateOnly,
+	})
+}
+
+// GetOnboarding checklist guiding the owner from adding the website to its
+// first goal
+func (instance *httpDelivery) GetOnboarding(c *gin.Context) {
+	tokenAuth, err := security.ExtractAccessTokenMetadata(c.Request)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "extract token metadata failed")
+		return
+	}
+
+	userID, err := instance.authUsecase.GetAuth(tokenAuth.AccessUUID)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "get token auth failed")
+		return
+	}
+
+	aState, err := instance.websiteUseCase.GetOnboarding(userID, c.Param("website_id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, aState)
+	case mongo.ErrNoDocuments:
+		httperr.Abort(c, http.StatusNotFound, CodeWebsiteNotFound, "this website not exists")
+	default:
+		logrus.Error(c, err)
+		httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get onboarding failed")
+	}
 }
@@ -0,0 +1,99 @@
+package website
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"analytics-api/configs"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// CodeLegalHold ...
+const CodeLegalHold = "legal_hold"
+
+// ErrLegalHold ...
+var ErrLegalHold = errors.New("this website is under legal hold, its data cannot be deleted until the hold is released")
+
+// legalHold keeps every data of a website, nothing of it is pruned or
+// deleted until an admin releases it
+type legalHold struct {
+	Reason   string `json:"reason" bson:"reason"`
+	PlacedAt string `json:"placed_at" bson:"placed_at"`
+}
+
+// SetLegalHold place aHold on website, or release it when nil. Deleted
+// websites waiting for their purge can be held too
+func (instance *repository) SetLegalHold(websiteID string, aHold *legalHold, aWebsite *website) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	update := bson.M{"$unset": bson.M{"legal_hold": ""}}
+	if aHold != nil {
+		update = bson.M{"$set": bson.M{"legal_hold": aHold}}
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := websiteCollection.FindOneAndUpdate(context.TODO(), bson.M{"id": websiteID}, update, opts).Decode(aWebsite)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetHeldWebsite websites under legal hold, deleted ones included
+func (instance *repository) GetHeldWebsite() (*websites, error) {
+	var websites websites
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	cursor, err := websiteCollection.Find(context.TODO(), bson.M{"legal_hold": bson.M{"$ne": nil}})
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &websites); err != nil {
+		return nil, err
+	}
+	return &websites, nil
+}
+
+// PlaceLegalHold hold website for reason, placing it again replaces the
+// reason. mongo.ErrNoDocuments when no website has this id
+func (instance *useCase) PlaceLegalHold(websiteID, reason string) (*website, error) {
+	var aWebsite website
+	aHold := &legalHold{Reason: reason, PlacedAt: time.Now().Format("2006-01-02, 15:04:05")}
+	err := instance.repo.SetLegalHold(websiteID, aHold, &aWebsite)
+	if err != nil {
+		return nil, err
+	}
+	return &aWebsite, nil
+}
+
+// ReleaseLegalHold let the data of website be pruned and deleted again
+func (instance *useCase) ReleaseLegalHold(websiteID string) (*website, error) {
+	var aWebsite website
+	err := instance.repo.SetLegalHold(websiteID, nil, &aWebsite)
+	if err != nil {
+		return nil, err
+	}
+	return &aWebsite, nil
+}
+
+func (instance *useCase) GetHeldWebsite() (*websites, error) {
+	websites, err := instance.repo.GetHeldWebsite()
+	if err != nil {
+		return nil, err
+	}
+	return websites, nil
+}
+
+// HeldWebsiteIDs ids of the websites under legal hold, left out of pruning
+// and purges
+func (instance *useCase) HeldWebsiteIDs() ([]string, error) {
+	websites, err := instance.repo.GetHeldWebsite()
+	if err != nil {
+		return nil, err
+	}
+	websiteIDs := []string{}
+	for _, aWebsite := range *websites {
+		websiteIDs = append(websiteIDs, aWebsite.ID)
+	}
+	return websiteIDs, nil
+}
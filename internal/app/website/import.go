@@ -0,0 +1,139 @@
+package website
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+
+	"analytics-api/internal/pkg/httperr"
+	req "analytics-api/internal/pkg/request"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MaxImportRows rows an import may hold, larger lists are sent in parts
+const MaxImportRows = 200
+
+// Statuses of a row of an import
+const (
+	ImportCreated = "created"
+	ImportFailed  = "failed"
+)
+
+// ErrInvalidImport ...
+var ErrInvalidImport = errors.New("import needs a JSON array or a CSV with a header naming the url column, of at most 200 rows")
+
+// ImportRow website of an import, validated like the form adding one
+type ImportRow struct {
+	Name string `json:"name" validate:"max=100"`
+	URL  string `json:"url" validate:"required,website_url,public_host"`
+}
+
+// ImportResult outcome of a row of an import, Row counts from 1 without the
+// header. Failed rows have the code and message an error response would
+type ImportResult struct {
+	Row    int    `json:"row"`
+	Name   string `json:"name,omitempty"`
+	URL    string `json:"url"`
+	Status string `json:"status"`
+	// WebsiteID and VerificationToken of the website created, to install
+	// the snippet and verify the host
+	WebsiteID         string                 `json:"website_id,omitempty"`
+	VerificationToken string                 `json:"verification_token,omitempty"`
+	Code              string                 `json:"code,omitempty"`
+	Message           string                 `json:"message,omitempty"`
+	Details           map[string]interface{} `json:"details,omitempty"`
+}
+
+// fail mark the row failed with code and message, with the fields of err
+// when it is a *req.ValidationError
+func (instance *ImportResult) fail(code, message string, err error) {
+	instance.Status = ImportFailed
+	instance.Code = code
+	instance.Message = message
+	var aValidationError *req.ValidationError
+	if errors.As(err, &aValidationError) {
+		instance.Details = map[string]interface{}{"fields": aValidationError.Fields}
+	}
+}
+
+// ReadImportCSV rows of a CSV whose header names the url column and
+// optionally the name one, in any order and without case. Other columns are
+// ignored. ErrInvalidImport without a url column or past MaxImportRows
+func ReadImportCSV(r io.Reader) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, ErrInvalidImport
+	}
+	nameColumn, urlColumn := -1, -1
+	for i, column := range header {
+		switch strings.ToLower(strings.TrimSpace(column)) {
+		case "name":
+			nameColumn = i
+		case "url":
+			urlColumn = i
+		}
+	}
+	if urlColumn < 0 {
+		return nil, ErrInvalidImport
+	}
+
+	rows := []ImportRow{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, ErrInvalidImport
+		}
+		if len(rows) == MaxImportRows {
+			return nil, ErrInvalidImport
+		}
+		var aRow ImportRow
+		if urlColumn < len(record) {
+			aRow.URL = strings.TrimSpace(record[urlColumn])
+		}
+		if nameColumn >= 0 && nameColumn < len(record) {
+			aRow.Name = strings.TrimSpace(record[nameColumn])
+		}
+		rows = append(rows, aRow)
+	}
+	return rows, nil
+}
+
+// ImportWebsite add the website of each row like AddWebsite, with the
+// default preset, and report the outcome of every row. A row failing does
+// not stop the others, rows past the quota all fail with it
+func (instance *useCase) ImportWebsite(userID string, limit int64, rows []ImportRow) []ImportResult {
+	results := make([]ImportResult, 0, len(rows))
+	for i, aRow := range rows {
+		aResult := ImportResult{Row: i + 1, Name: aRow.Name, URL: aRow.URL}
+		if err := req.Validate(aRow); err != nil {
+			aResult.fail(httperr.CodeInvalidRequest, "invalid website", err)
+			results = append(results, aResult)
+			continue
+		}
+
+		aWebsite, err := instance.AddWebsite(userID, limit, aRow.Name, aRow.URL, "", "", Presets[DefaultPreset], false)
+		switch err {
+		case nil:
+			aResult.Status = ImportCreated
+			aResult.WebsiteID = aWebsite.ID
+			aResult.VerificationToken = aWebsite.VerificationToken
+		case ErrWebsiteExists:
+			aResult.fail(CodeWebsiteExists, err.Error(), nil)
+		case ErrWebsiteQuota:
+			aResult.fail(CodeWebsiteQuota, err.Error(), nil)
+		default:
+			logrus.Error("import website error ", err)
+			aResult.fail(httperr.CodeInternal, "add website failed", nil)
+		}
+		results = append(results, aResult)
+	}
+	return results
+}
@@ -0,0 +1,158 @@
+package website
+
+import (
+	"context"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/push"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// InstallCheckAfter time after it was added a website without events gets
+// its owner told the install looks broken
+const InstallCheckAfter = 7 * 24 * time.Hour
+
+// installBatch websites notified by one run of CheckInstalls
+const installBatch = 100
+
+// Notices of the install of a website
+const (
+	NoticeFirstEvent = "first_event"
+	NoticeNoEvents   = "no_events"
+)
+
+// installNoticeFields field of the website storing when each notice was sent
+var installNoticeFields = map[string]string{
+	NoticeFirstEvent: "install_notice.first_event_at",
+	NoticeNoEvents:   "install_notice.no_events_at",
+}
+
+// Notifier push notifications to the devices of a user, the mobile usecase
+type Notifier interface {
+	Notify(userID string, notification push.Notification) (int, error)
+}
+
+// ListInstallPending websites still owing their owner a notice, up to limit:
+// those tracked not told of their first event yet, and those added before
+// createdBefore still waiting for data not told of it. Deleted and archived
+// websites are left out
+func (instance *repository) ListInstallPending(createdBefore string, limit int64) ([]website, error) {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"install_notice": bson.M{"$exists": true}},
+		{"deleted_at": nil},
+		{"archived": bson.M{"$ne": true}},
+		{"$or": []bson.M{
+			{"tracked": true, installNoticeFields[NoticeFirstEvent]: bson.M{"$exists": false}},
+			{
+				"tracked":                           false,
+				"created_at":                        bson.M{"$lte": createdBefore},
+				installNoticeFields[NoticeNoEvents]: bson.M{"$exists": false},
+			},
+		}},
+	}}
+	opts := options.Find().SetSort(primitive.D{{Key: "created_at", Value: 1}}).SetLimit(limit)
+	cursor, err := websiteCollection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var websites []website
+	if err = cursor.All(context.TODO(), &websites); err != nil {
+		return nil, err
+	}
+	return websites, nil
+}
+
+// SetInstallNotified record notice of website sent at
+func (instance *repository) SetInstallNotified(websiteID, notice, at string) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	update := bson.M{"$set": bson.M{installNoticeFields[notice]: at}}
+	_, err := websiteCollection.UpdateOne(context.TODO(), bson.M{"id": websiteID}, update)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// CheckInstalls tell the owners of websites added since notices were sent
+// that their first event arrived, or that none did InstallCheckAfter after
+// the website was added. Each notice is sent once, a website whose events
+// arrive after the second still gets the first. Returns the notices sent
+func (instance *useCase) CheckInstalls(notifier Notifier) (int, error) {
+	now := time.Now()
+	createdBefore := now.Add(-InstallCheckAfter).Format("2006-01-02, 15:04:05")
+	websites, err := instance.repo.ListInstallPending(createdBefore, installBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, aWebsite := range websites {
+		notice, notification := NoticeNoEvents, noEventsNotification(aWebsite)
+		if aWebsite.Tracked {
+			notice, notification = NoticeFirstEvent, firstEventNotification(aWebsite)
+		}
+		_, err = notifier.Notify(aWebsite.UserID, notification)
+		if err != nil {
+			logrus.Error("send install notice of website id ", aWebsite.ID, " error ", err)
+			continue
+		}
+		err = instance.repo.SetInstallNotified(aWebsite.ID, notice, now.Format("2006-01-02, 15:04:05"))
+		if err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// firstEventNotification notification of the first event of aWebsite
+func firstEventNotification(aWebsite website) push.Notification {
+	return push.Notification{
+		Title: "First event received",
+		Body:  aWebsite.URL + " is sending events, its reports are filling up",
+		Data: map[string]string{
+			"website_id": aWebsite.ID,
+			"notice":     NoticeFirstEvent,
+		},
+	}
+}
+
+// noEventsNotification notification of aWebsite without events since it was
+// added, pointing at the verification when it is the missing step
+func noEventsNotification(aWebsite website) push.Notification {
+	body := aWebsite.URL + " has sent no event since it was added, check the tracking snippet is on its pages"
+	if !aWebsite.Verified() {
+		body = aWebsite.URL + " has sent no event since it was added, its ownership is not verified yet"
+	}
+	return push.Notification{
+		Title: "No events received yet",
+		Body:  body,
+		Data: map[string]string{
+			"website_id": aWebsite.ID,
+			"notice":     NoticeNoEvents,
+		},
+	}
+}
+
+// RunInstallCheck send the install notices of the websites of store every
+// interval, until the process exits
+func RunInstallCheck(store *db.Store, notifier Notifier, interval time.Duration) {
+	useCase := NewUseCase(store)
+	for range time.Tick(interval) {
+		sent, err := useCase.CheckInstalls(notifier)
+		if err != nil {
+			logrus.Error("check website installs error ", err)
+			continue
+		}
+		if sent > 0 {
+			logrus.Info("sent install notices ", sent)
+		}
+	}
+}
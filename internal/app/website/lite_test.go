@@ -0,0 +1,76 @@
+package website
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/db/dbtest"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestLiteStats(t *testing.T) {
+	mongoStore, liteStore := dbtest.Stores(t)
+	sessionCollection, websiteCollection := configs.MongoDB.SessionCollection, configs.MongoDB.WebsiteCollection
+	configs.MongoDB.SessionCollection, configs.MongoDB.WebsiteCollection = "session", "website"
+	t.Cleanup(func() {
+		configs.MongoDB.SessionCollection, configs.MongoDB.WebsiteCollection = sessionCollection, websiteCollection
+	})
+
+	now, _ := time.Parse(time.RFC3339, "2024-03-15T12:00:00Z")
+	var sessions []interface{}
+	for i := 0; i < 60; i++ {
+		at := now.Add(-time.Duration(i*3) * time.Hour)
+		for j := 0; j <= i%4; j++ {
+			anEvent := bson.M{"type": pageviewEventType, "data": bson.M{"href": "https://example.com/"}}
+			switch {
+			case i%5 == 0:
+				anEvent = bson.M{"type": 5, "data": bson.M{"tag": screenViewTag}}
+			case j == 2:
+				anEvent = bson.M{"type": 5, "data": bson.M{"tag": "heartbeat"}}
+			}
+			if i%11 == 0 && j == 0 {
+				anEvent["late"] = true
+			}
+			sessions = append(sessions, bson.M{
+				"meta_data":   bson.M{"id": fmt.Sprintf("s%02d", i), "user_id": "u1", "website_id": fmt.Sprintf("w%d", i%3)},
+				"event":       anEvent,
+				"time_report": at.Add(time.Duration(j) * time.Minute),
+			})
+		}
+	}
+	dbtest.Insert(t, configs.MongoDB.SessionCollection, sessions, mongoStore, liteStore)
+	dbtest.Insert(t, configs.MongoDB.WebsiteCollection, []interface{}{
+		bson.M{"id": "w0", "user_id": "u1", "tags": []string{"shop", "vn"}},
+		bson.M{"id": "w1", "user_id": "u1", "tags": []string{"blog"}},
+		bson.M{"id": "w2", "user_id": "u1", "tags": []string{"shop"}},
+		bson.M{"id": "w3", "user_id": "u1", "tags": []string{"shop"}, "deleted_at": "2024-03-01T00:00:00Z"},
+		bson.M{"id": "w4", "user_id": "u1"},
+		bson.M{"id": "w5", "user_id": "u2", "tags": []string{"shop"}},
+	}, mongoStore, liteStore)
+
+	tests := []struct {
+		name      string
+		unordered bool
+		report    func(*repository) (interface{}, error)
+	}{
+		{name: "should give the stats of websites", unordered: true, report: func(r *repository) (interface{}, error) {
+			return r.GetStats("u1", []string{"w0", "w1", "w2", "w9"}, now)
+		}},
+		{name: "should give the stats of websites a day earlier", unordered: true, report: func(r *repository) (interface{}, error) {
+			return r.GetStats("u1", []string{"w1", "w2"}, now.AddDate(0, 0, -1))
+		}},
+		{name: "should count the websites of each tag", report: func(r *repository) (interface{}, error) { return r.GetTags("u1", nil) }},
+		{name: "should count the websites of each tag within scope", report: func(r *repository) (interface{}, error) { return r.GetTags("u1", []string{"vn", "blog"}) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbtest.Compare(t, mongoStore, liteStore, tt.unordered, func(store *db.Store) (interface{}, error) {
+				return tt.report(&repository{store: store})
+			})
+		})
+	}
+}
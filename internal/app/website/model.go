@@ -1,15 +1,299 @@
 package website
 
+import (
+	"net"
+	"slices"
+	"time"
+
+	"analytics-api/internal/pkg/i18n"
+	"analytics-api/internal/pkg/ipallow"
+	"analytics-api/internal/pkg/spam"
+)
+
 // website ...
 type website struct {
-	ID        string `json:"id" bson:"id"`
-	UserID    string `json:"user_id" bson:"user_id"`
-	Category  string `json:"category" bson:"category"`
-	HostName  string `json:"host_name" bson:"host_name"`
-	URL       string `json:"url" bson:"url"`
-	CreatedAt string `json:"created_at" bson:"created_at"`
-	UpdatedAt string `json:"updated_at" bson:"updated_at"`
+	ID       string    `json:"id" bson:"id"`
+	UserID   string    `json:"user_id" bson:"user_id"`
+	Name     string    `json:"name,omitempty" bson:"name,omitempty"`
+	Category string    `json:"category" bson:"category"`
+	HostName string    `json:"host_name" bson:"host_name"`
+	URL      string    `json:"url" bson:"url"`
+	Features *features `json:"features,omitempty" bson:"features,omitempty"`
+	Timezone string    `json:"timezone,omitempty" bson:"timezone,omitempty"`
+	// Locale preset the website was created with, see Presets
+	Locale         string          `json:"locale,omitempty" bson:"locale,omitempty"`
+	DateFormat     string          `json:"date_format,omitempty" bson:"date_format,omitempty"`
+	Currency       string          `json:"currency,omitempty" bson:"currency,omitempty"`
+	ContentGroups  []contentGroup  `json:"content_groups,omitempty" bson:"content_groups,omitempty"`
+	VisitorWebhook *visitorWebhook `json:"visitor_webhook,omitempty" bson:"visitor_webhook,omitempty"`
+	// SegmentWriteKey authenticates Segment calls sent to /segment/v1
+	SegmentWriteKey string `json:"-" bson:"segment_write_key,omitempty"`
+	// ServerKeys authenticate batches sent to /session/receive by backends
+	ServerKeys []ServerKey `json:"-" bson:"server_keys,omitempty"`
+	// AggregateOnly only daily counters are kept of the website, no session,
+	// event or visitor is stored
+	AggregateOnly bool   `json:"aggregate_only" bson:"aggregate_only,omitempty"`
+	CreatedAt     string `json:"created_at" bson:"created_at"`
+	UpdatedAt     string `json:"updated_at" bson:"updated_at"`
+	// DeletedAt set while the website waits out RestoreWindow before its
+	// data is purged
+	DeletedAt string `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	// VerificationToken proves the ownership of the host in a TXT record or a
+	// meta tag, the website is tracked once VerifiedAt is set
+	VerificationToken string `json:"verification_token,omitempty" bson:"verification_token,omitempty"`
+	VerifiedAt        string `json:"verified_at,omitempty" bson:"verified_at,omitempty"`
+	// InstallNotice notifications of the install of the website sent to its
+	// owner, nil for websites added before they were sent
+	InstallNotice *installNotice `json:"-" bson:"install_notice,omitempty"`
+	// Share public link to the stats of the website, nil while not shared
+	Share *Share `json:"share,omitempty" bson:"share,omitempty"`
+	// Settings traffic left out of the analytics of the website
+	Settings *Settings `json:"settings,omitempty" bson:"settings,omitempty"`
+	// Aliases other host names the website is served from, AliasIDs the ids
+	// a website of each would have had, both resolved to this one
+	Aliases  []string `json:"aliases,omitempty" bson:"aliases,omitempty"`
+	AliasIDs []string `json:"-" bson:"alias_ids,omitempty"`
+	// TrackingID readable id the snippet sends in place of ID
+	TrackingID string `json:"tracking_id,omitempty" bson:"tracking_id,omitempty"`
+	// Transfer handover of the website to another account, pending until
+	// the recipient accepts it
+	Transfer *transfer `json:"transfer,omitempty" bson:"transfer,omitempty"`
+	// PreviousUserIDs accounts the website was transferred from, trackers
+	// installed with their id keep sending to it
+	PreviousUserIDs []string `json:"-" bson:"previous_user_ids,omitempty"`
+	// Tags labels the owner groups websites by, alert templates apply to
+	// the websites of a tag
+	Tags []string `json:"tags,omitempty" bson:"tags,omitempty"`
+	// Tracked set once the first event of the website arrives, until then
+	// the website is waiting for data. LastEventAt lags by up to
+	// trackedThrottle
+	Tracked      bool   `json:"tracked" bson:"tracked"`
+	FirstEventAt string `json:"first_event_at,omitempty" bson:"first_event_at,omitempty"`
+	LastEventAt  string `json:"last_event_at,omitempty" bson:"last_event_at,omitempty"`
+	// Onboarding when the steps of the checklist not kept elsewhere were
+	// first done
+	Onboarding *onboarding `json:"-" bson:"onboarding,omitempty"`
+	// Archived website whose collection stopped, its data and reports are
+	// kept readable
+	Archived   bool   `json:"archived" bson:"archived,omitempty"`
+	ArchivedAt string `json:"archived_at,omitempty" bson:"archived_at,omitempty"`
+	// LegalHold set while litigation requires keeping every data of the
+	// website
+	LegalHold *legalHold `json:"legal_hold,omitempty" bson:"legal_hold,omitempty"`
+	// Stats set when the list is asked ?include=stats
+	Stats *Stats `json:"stats,omitempty" bson:"-"`
+	// Version counts the changes of the website made with UpdateWebsite and
+	// UpdateSettings, answered as its ETag
+	Version int64 `json:"version" bson:"version"`
+}
+
+// Settings of a website, the timezone is stored with the website since all
+// reports read it from there
+type Settings struct {
+	Timezone string `json:"timezone" bson:"-"`
+	// ExcludedIPs IP addresses and CIDRs whose traffic is dropped, like the
+	// office of the owner
+	ExcludedIPs []string `json:"excluded_ips" bson:"excluded_ips,omitempty"`
+	// FilterBots drop the traffic of user agents known to be bots
+	FilterBots bool `json:"filter_bots" bson:"filter_bots,omitempty"`
+	// SpamAllowed referrer domains of the spam list the website keeps, and
+	// SpamBlocked those it drops on top of the list
+	SpamAllowed []string `json:"spam_allowed" bson:"spam_allowed,omitempty"`
+	SpamBlocked []string `json:"spam_blocked" bson:"spam_blocked,omitempty"`
+	// AllowedCountries ISO 3166-1 alpha-2 codes of the only countries traffic
+	// is collected from when set, ExcludedCountries those it is never
+	// collected from. At most one of them is set
+	AllowedCountries  []string `json:"allowed_countries" bson:"allowed_countries,omitempty"`
+	ExcludedCountries []string `json:"excluded_countries" bson:"excluded_countries,omitempty"`
+}
+
+// Excludes whether traffic from ip, bot when its user agent is a known bot,
+// coming from referrer is left out of the analytics
+func (instance *Settings) Excludes(ip net.IP, bot bool, referrer string) bool {
+	if instance.FilterBots && bot {
+		return true
+	}
+	if spam.Spam(referrer, instance.SpamAllowed, instance.SpamBlocked) {
+		return true
+	}
+	nets, _ := ipallow.Parse(instance.ExcludedIPs)
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExcludesCountry whether traffic located in the country of countryCode is
+// left out. Traffic the GeoIP database locates nowhere, with an empty code,
+// is left out when only some countries are allowed
+func (instance *Settings) ExcludesCountry(countryCode string) bool {
+	if len(instance.AllowedCountries) > 0 {
+		return !slices.Contains(instance.AllowedCountries, countryCode)
+	}
+	return slices.Contains(instance.ExcludedCountries, countryCode)
+}
+
+// Location timezone reports of the website are read in, UTC when it is not
+// set
+func (instance *Settings) Location() *time.Location {
+	location, err := time.LoadLocation(instance.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// Share public read-only access to the stats of a website by token
+type Share struct {
+	Token string `json:"token" bson:"token"`
+	// Private counts are noised and values seen by fewer than MinCount
+	// sessions are suppressed, so low volume data cannot be read precisely
+	Private  bool  `json:"private" bson:"private"`
+	MinCount int64 `json:"min_count,omitempty" bson:"min_count,omitempty"`
+	// Seed of the noise, kept secret so the noise cannot be recomputed
+	Seed string `json:"-" bson:"seed"`
+}
+
+// Verified whether website may be tracked, websites added before ownership
+// was verified have no token and stay tracked
+func (instance *website) Verified() bool {
+	return instance.VerificationToken == "" || instance.VerifiedAt != ""
+}
+
+// installNotice when the owner was told the first event of the website
+// arrived, or that none did within InstallCheckAfter, in the layout of
+// CreatedAt
+type installNotice struct {
+	FirstEventAt string `bson:"first_event_at,omitempty"`
+	NoEventsAt   string `bson:"no_events_at,omitempty"`
+}
+
+// deletion website deleted whose data is yet to be removed
+type deletion struct {
+	UserID     string    `bson:"user_id"`
+	WebsiteID  string    `bson:"website_id"`
+	CreatedAt  time.Time `bson:"created_at"`
+	PurgeAfter time.Time `bson:"purge_after,omitempty"`
+	// NextAttemptAt when the purge is tried, after a backoff once it failed
+	NextAttemptAt time.Time `bson:"next_attempt_at,omitempty"`
+	Attempts      int       `bson:"attempts"`
+	LastError     string    `bson:"last_error,omitempty"`
+}
+
+// deleteImpact data lost when a website is deleted, shown before the delete
+// is confirmed
+type deleteImpact struct {
+	Events     int64 `json:"events"`
+	Goals      int64 `json:"goals"`
+	Visitors   int64 `json:"visitors"`
+	CRMMapping bool  `json:"crm_mapping"`
+}
+
+// transfer website offered to the account signed up with Email
+type transfer struct {
+	ToUserID  string    `json:"-" bson:"to_user_id"`
+	Email     string    `json:"email" bson:"email"`
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at"`
+}
+
+// onboarding steps done by the owner after adding a website, the time of
+// each in the layout of CreatedAt
+type onboarding struct {
+	SnippetInstalledAt string `bson:"snippet_installed_at,omitempty"`
+	GoalCreatedAt      string `bson:"goal_created_at,omitempty"`
 }
 
+// onboardingStep step of the checklist, At when it was first done
+type onboardingStep struct {
+	Step string `json:"step"`
+	Done bool   `json:"done"`
+	At   string `json:"at,omitempty"`
+}
+
+// onboardingState checklist of a website, Completed steps of Total
+type onboardingState struct {
+	WebsiteID string           `json:"website_id"`
+	Steps     []onboardingStep `json:"steps"`
+	Completed int              `json:"completed"`
+	Total     int              `json:"total"`
+}
+
+// activation websites of the instance that reached each onboarding step
+type activation struct {
+	Websites int64            `json:"websites"`
+	Steps    map[string]int64 `json:"steps"`
+}
+
+// pendingTransfer transfer as its recipient sees it
+type pendingTransfer struct {
+	WebsiteID string    `json:"website_id"`
+	Name      string    `json:"name,omitempty"`
+	URL       string    `json:"url"`
+	From      string    `json:"from"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Format how reports of a website are meant to be shown. Reports keep ISO
+// dates and plain numbers, clients format them with it
+type Format struct {
+	Locale     string `json:"locale"`
+	Timezone   string `json:"timezone"`
+	DateFormat string `json:"date_format"`
+	// Currency ISO 4217 code of revenue
+	Currency string `json:"currency"`
+}
+
+// Presets formats picked by locale when a website is added, most users are
+// in Vietnam so new websites get Vietnamese unless another preset is chosen
+var Presets = map[string]Format{
+	i18n.Vietnamese: {Locale: i18n.Vietnamese, Timezone: "Asia/Ho_Chi_Minh", DateFormat: "dd/mm/yyyy", Currency: "VND"},
+	i18n.English:    {Locale: i18n.English, Timezone: "UTC", DateFormat: "yyyy-mm-dd", Currency: "USD"},
+}
+
+// InternalHostName host name of the internal website tracking the dashboard,
+// it is not a real host so no website can be added with it
+const InternalHostName = "dashboard.internal"
+
+// DefaultPreset ...
+const DefaultPreset = i18n.Vietnamese
+
 // websites ...
 type websites []website
+
+// contentGroup pages of the website whose path matches Pattern, the first
+// matching group of the website wins
+type contentGroup struct {
+	Name    string `json:"name" bson:"name"`
+	Pattern string `json:"pattern" bson:"pattern"`
+}
+
+// visitorWebhook endpoint told of newly identified visitors, the secret signing
+// deliveries is only shown when the endpoint is set
+type visitorWebhook struct {
+	URL    string `json:"url" bson:"url"`
+	Secret string `json:"-" bson:"secret"`
+}
+
+// features tracker capabilities toggled from the dashboard
+type features struct {
+	Recording     bool `json:"recording" bson:"recording"`
+	WebVitals     bool `json:"web_vitals" bson:"web_vitals"`
+	OutboundLinks bool `json:"outbound_links" bson:"outbound_links"`
+	PageMeta      bool `json:"page_meta" bson:"page_meta"`
+	Engagement    bool `json:"engagement" bson:"engagement"`
+	Forms         bool `json:"forms" bson:"forms"`
+	CanonicalURLs bool `json:"canonical_urls" bson:"canonical_urls"`
+}
+
+// FeatureNames tracker features a website toggles, named like the fields of
+// features
+var FeatureNames = []string{"recording", "web_vitals", "outbound_links", "page_meta", "engagement", "forms", "canonical_urls"}
+
+// defaultFeatures features enabled for a newly added website
+func defaultFeatures() *features {
+	return &features{
+		Recording: true,
+	}
+}
@@ -0,0 +1,148 @@
+package website
+
+import (
+	"context"
+	"time"
+
+	"analytics-api/configs"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Steps of the onboarding checklist, in the order the owner is guided
+const (
+	StepSiteAdded        = "site_added"
+	StepSnippetInstalled = "snippet_installed"
+	StepFirstEvent       = "first_event"
+	StepGoalCreated      = "goal_created"
+)
+
+// OnboardingSteps steps of the checklist in order
+var OnboardingSteps = []string{StepSiteAdded, StepSnippetInstalled, StepFirstEvent, StepGoalCreated}
+
+// onboardingFields field of the website storing when each step was done
+var onboardingFields = map[string]string{
+	StepSiteAdded:        "created_at",
+	StepSnippetInstalled: "onboarding.snippet_installed_at",
+	StepFirstEvent:       "first_event_at",
+	StepGoalCreated:      "onboarding.goal_created_at",
+}
+
+// onboardingCacheTTL time a step marked is not written again, the snippet
+// asks for the tracker config on every page
+const onboardingCacheTTL = 24 * time.Hour
+
+// MarkOnboarding set step of website done at, kept when it was done already
+func (instance *repository) MarkOnboarding(websiteID, step, at string) error {
+	key := instance.onboardingCacheKey(websiteID, step)
+	fresh, err := configs.KeyStore.Client.SetNX(key, at, onboardingCacheTTL)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return nil
+	}
+
+	field := onboardingFields[step]
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"id": websiteID},
+		{field: bson.M{"$exists": false}},
+	}}
+	_, err = websiteCollection.UpdateOne(context.TODO(), filter, bson.M{"$set": bson.M{field: at}})
+	if err != nil {
+		// the next call tries again
+		configs.KeyStore.Client.Del(key)
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) onboardingCacheKey(websiteID, step string) string {
+	return instance.store.Key("website_onboarding:" + step + ":" + websiteID)
+}
+
+// CountOnboarding websites of the instance, the internal one excepted, with
+// how many reached each step. The snippet counts as installed once an event
+// arrived, like GetOnboarding shows it
+func (instance *repository) CountOnboarding() (*activation, error) {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	websites := func(and ...bson.M) (int64, error) {
+		filter := bson.M{"$and": append([]bson.M{
+			{"host_name": bson.M{"$ne": InternalHostName}},
+			{"deleted_at": nil},
+		}, and...)}
+		return websiteCollection.CountDocuments(context.TODO(), filter)
+	}
+
+	count, err := websites()
+	if err != nil {
+		return nil, err
+	}
+	anActivation := &activation{Websites: count, Steps: map[string]int64{}}
+	for _, step := range OnboardingSteps {
+		done := bson.M{onboardingFields[step]: bson.M{"$exists": true}}
+		if step == StepSnippetInstalled {
+			done = bson.M{"$or": []bson.M{done, {onboardingFields[StepFirstEvent]: bson.M{"$exists": true}}}}
+		}
+		count, err := websites(done)
+		if err != nil {
+			return nil, err
+		}
+		anActivation.Steps[step] = count
+	}
+	return anActivation, nil
+}
+
+// MarkOnboarding record that the owner of website did step now, errors are
+// logged since the step is only guidance
+func (instance *useCase) MarkOnboarding(websiteID, step string) {
+	err := instance.repo.MarkOnboarding(websiteID, step, time.Now().Format("2006-01-02, 15:04:05"))
+	if err != nil {
+		logrus.Error("mark onboarding step ", step, " error ", err)
+	}
+}
+
+// GetOnboarding checklist of website, mongo.ErrNoDocuments when user has no
+// such website. An event received shows the snippet installed, Segment
+// sources have none
+func (instance *useCase) GetOnboarding(userID, websiteID string) (*onboardingState, error) {
+	var aWebsite website
+	err := instance.repo.GetWebsite(userID, websiteID, &aWebsite)
+	if err != nil {
+		return nil, err
+	}
+	var anOnboarding onboarding
+	if aWebsite.Onboarding != nil {
+		anOnboarding = *aWebsite.Onboarding
+	}
+	if anOnboarding.SnippetInstalledAt == "" {
+		anOnboarding.SnippetInstalledAt = aWebsite.FirstEventAt
+	}
+	done := map[string]string{
+		StepSiteAdded:        aWebsite.CreatedAt,
+		StepSnippetInstalled: anOnboarding.SnippetInstalledAt,
+		StepFirstEvent:       aWebsite.FirstEventAt,
+		StepGoalCreated:      anOnboarding.GoalCreatedAt,
+	}
+
+	aState := &onboardingState{WebsiteID: websiteID, Steps: []onboardingStep{}, Total: len(OnboardingSteps)}
+	for _, step := range OnboardingSteps {
+		aStep := onboardingStep{Step: step, Done: done[step] != "", At: done[step]}
+		if aStep.Done {
+			aState.Completed++
+		}
+		aState.Steps = append(aState.Steps, aStep)
+	}
+	return aState, nil
+}
+
+// GetActivation websites of the instance that reached each onboarding step
+func (instance *useCase) GetActivation() (*activation, error) {
+	anActivation, err := instance.repo.CountOnboarding()
+	if err != nil {
+		return nil, err
+	}
+	return anActivation, nil
+}
@@ -2,36 +2,244 @@ package website
 
 import (
 	"context"
+	"encoding/json"
+	"slices"
+	"time"
 
 	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/etag"
 
 	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"gopkg.in/mgo.v2/bson"
 )
 
 // Repository ...
 type Repository interface {
 	FindWebsite(userID, hostName string) (int64, error)
+	FindOtherWebsite(userID, websiteID, hostName string) (int64, error)
+	FindHostName(websiteID, hostName string) (int64, error)
+	UpdateAliases(userID, websiteID string, aliases, aliasIDs []string) error
+	ResolveWebsite(userID, websiteID, hostName string, aWebsite *website) error
 	FindWebsiteByID(userID, websiteID string) (int64, error)
+	CountWebsite(userID string) (int64, error)
 	InsertWebsite(userID string, website website) error
 	GetWebsite(userID, websiteID string, website *website) error
 	GetAllWebsite(userID string) (*websites, error)
+	ListWebsite() (*websites, error)
 	DeleteWebsite(userID, websiteID string) error
+	GetDeletedWebsite(userID, websiteID string, aWebsite *website) error
+	RestoreWebsite(userID, websiteID string) error
+	RemoveWebsite(userID, websiteID string) error
+	QueueDeletion(userID, websiteID string, purgeAfter time.Time) error
+	ListDeletions(limit int64, held []string) ([]deletion, error)
+	GetDeletion(userID, websiteID string, aDeletion *deletion) error
+	HasDeletion(userID, websiteID string) (bool, error)
+	FailDeletion(userID, websiteID, lastError string, nextAttemptAt time.Time) error
+	RetryDeletions() (int64, error)
+	RemoveDeletion(userID, websiteID string) error
+	CountDeleteImpact(userID, websiteID string) (*deleteImpact, error)
 	DeleteSession(userID, websiteID string) error
+	DeleteEvents(userID, websiteID string) error
+	DeleteGoal(userID, websiteID string) error
+	DeleteMetric(userID, websiteID string) error
+	UpdateFeatures(userID, websiteID string, features *features) error
+	GetFeatures(websiteID string) (*features, error)
+	UpdateTimezone(userID, websiteID, timezone string) error
+	UpdateWebsite(userID, websiteID string, fields bson.M, version *int64, aWebsite *website) error
+	UpdateContentGroups(userID, websiteID string, contentGroups []contentGroup) error
+	UpdateVisitorWebhook(userID, websiteID string, aWebhook *visitorWebhook) error
+	DeleteVisitor(userID, websiteID string) error
+	DeleteCRMMapping(userID, websiteID string) error
+	UpdateSegmentWriteKey(userID, websiteID, writeKey string) error
+	UpdateAggregateOnly(userID, websiteID string, enabled bool) error
+	UpdateVerified(userID, websiteID, verifiedAt string) error
+	GetAggregateOnly(websiteID string) (bool, error)
+	MarkTracked(websiteID, at string) error
+	MarkOnboarding(websiteID, step, at string) error
+	CountOnboarding() (*activation, error)
+	GetTaggedWebsite(userID string, tags, scope []string) (*websites, error)
+	GetTags(userID string, scope []string) ([]tagCount, error)
+	GetStats(userID string, websiteIDs []string, now time.Time) ([]statsRow, error)
+	SetLegalHold(websiteID string, aHold *legalHold, aWebsite *website) error
+	GetHeldWebsite() (*websites, error)
+	FindTrackingID(websiteID, trackingID string) (int64, error)
+	UpdateTrackingID(userID, websiteID, trackingID string, aWebsite *website) error
+	GetTrackingWebsite(trackingID string, aWebsite *website) error
+	InsertServerKey(userID, websiteID string, aKey ServerKey) error
+	UpdateServerKey(userID, websiteID, keyID, hash, prefix string) error
+	DeleteServerKey(userID, websiteID, keyID string) (int64, error)
+	FindServerKey(hash string, aWebsite *website) error
+	UpdateServerKeyUsed(websiteID, keyID, lastUsedAt string) error
+	AddTag(userID, tag string, websiteIDs []string) (int64, error)
+	RenameTag(userID, tag, name string) (int64, error)
+	DeleteTag(userID, tag string) (int64, error)
+	DeleteAggregates(userID, websiteID string) error
+	DeleteUsage(userID, websiteID string) error
+	DeleteArchive(userID, websiteID string) error
+	FindSegmentWriteKey(writeKey string, aWebsite *website) error
+	UpdateSettings(userID, websiteID string, aSettings *Settings, version *int64) (int64, error)
+	GetSettings(websiteID string) (*Settings, error)
+	ListInstallPending(createdBefore string, limit int64) ([]website, error)
+	SetInstallNotified(websiteID, notice, at string) error
+	UpdateShare(userID, websiteID string, aShare *Share) error
+	FindShareToken(token string, aWebsite *website) error
+	UpdateTransfer(userID, websiteID string, aTransfer *transfer) error
+	ListTransfers(toUserID string) ([]website, error)
+	GetTransfer(toUserID, websiteID string, aWebsite *website) error
+	DeclineTransfer(toUserID, websiteID string) error
+	MoveSession(userID, toUserID, websiteID string) error
+	MoveEvents(userID, toUserID, websiteID string) error
+	MoveData(userID, toUserID, websiteID string) error
+	DeleteIntegrations(userID, websiteID string) error
+	MoveWebsite(userID, toUserID, websiteID string) error
 }
 
-type repository struct{}
+// featuresCacheTTL keep short so toggles reach tracked sites within minutes
+const featuresCacheTTL = 2 * time.Minute
+
+// trackedThrottle time between two writes of the last event of a website,
+// every batch would write it otherwise
+const trackedThrottle = time.Minute
+
+type repository struct {
+	store *db.Store
+}
 
 // NewRepository ...
-func NewRepository() Repository {
-	return &repository{}
+func NewRepository(store *db.Store) Repository {
+	return &repository{
+		store: store,
+	}
 }
 
+// FindWebsite count websites of user served from hostName, as their host
+// name or an alias
 func (instance *repository) FindWebsite(userID, hostName string) (int64, error) {
-	websiteCollection := configs.MongoDB.Client.Collection(configs.MongoDB.WebsiteCollection)
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"$or": []bson.M{{"host_name": hostName}, {"aliases": hostName}}},
+		{"deleted_at": nil},
+	}}
+	count, err := websiteCollection.CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// FindOtherWebsite count websites of user but website served from hostName
+func (instance *repository) FindOtherWebsite(userID, websiteID, hostName string) (int64, error) {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": bson.M{"$ne": websiteID}},
+		{"$or": []bson.M{{"host_name": hostName}, {"aliases": hostName}}},
+		{"deleted_at": nil},
+	}}
+	count, err := websiteCollection.CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// FindHostName count websites of every user but website served from
+// hostName
+func (instance *repository) FindHostName(websiteID, hostName string) (int64, error) {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"id": bson.M{"$ne": websiteID}},
+		{"$or": []bson.M{{"host_name": hostName}, {"aliases": hostName}}},
+		{"deleted_at": nil},
+	}}
+	count, err := websiteCollection.CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// UpdateAliases replace the aliases of website and their ids
+func (instance *repository) UpdateAliases(userID, websiteID string, aliases, aliasIDs []string) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": websiteID},
+		{"deleted_at": nil},
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"aliases":    aliases,
+			"alias_ids":  aliasIDs,
+			"updated_at": time.Now().Format("2006-01-02, 15:04:05"),
+		},
+	}
+	result, err := websiteCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// ResolveWebsite website of user served from hostName, or else the one of
+// websiteID or with it as the id of an alias. Websites user transferred away
+// are resolved when user has none
+func (instance *repository) ResolveWebsite(userID, websiteID, hostName string, aWebsite *website) error {
+	match := []bson.M{{"id": websiteID}, {"alias_ids": websiteID}, {"tracking_id": websiteID}}
+	if hostName != "" {
+		match = append(match, bson.M{"host_name": hostName}, bson.M{"aliases": hostName})
+	}
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"$or": []bson.M{{"user_id": userID}, {"previous_user_ids": userID}}},
+		{"$or": match},
+		{"deleted_at": nil},
+	}}
+	cursor, err := websiteCollection.Find(context.TODO(), filter)
+	if err != nil {
+		return err
+	}
+	var listWebsite []website
+	if err = cursor.All(context.TODO(), &listWebsite); err != nil {
+		return err
+	}
+	owned := slices.DeleteFunc(slices.Clone(listWebsite), func(candidate website) bool {
+		return candidate.UserID != userID
+	})
+	if len(owned) > 0 {
+		listWebsite = owned
+	}
+	if len(listWebsite) == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	*aWebsite = listWebsite[0]
+	for _, candidate := range listWebsite {
+		if hostName != "" && (candidate.HostName == hostName || slices.Contains(candidate.Aliases, hostName)) {
+			*aWebsite = candidate
+			break
+		}
+		if candidate.ID == websiteID || candidate.TrackingID == websiteID {
+			*aWebsite = candidate
+		}
+	}
+	return nil
+}
+
+// CountWebsite count websites of user, the internal website left out
+func (instance *repository) CountWebsite(userID string) (int64, error) {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
 	filter := bson.M{"$and": []bson.M{
 		{"user_id": userID},
-		{"host_name": hostName},
+		{"host_name": bson.M{"$ne": InternalHostName}},
+		{"deleted_at": nil},
 	}}
 	count, err := websiteCollection.CountDocuments(context.TODO(), filter)
 	if err != nil {
@@ -41,10 +249,11 @@ func (instance *repository) FindWebsite(userID, hostName string) (int64, error)
 }
 
 func (instance *repository) FindWebsiteByID(userID, websiteID string) (int64, error) {
-	websiteCollection := configs.MongoDB.Client.Collection(configs.MongoDB.WebsiteCollection)
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
 	filter := bson.M{"$and": []bson.M{
 		{"user_id": userID},
 		{"id": websiteID},
+		{"deleted_at": nil},
 	}}
 	count, err := websiteCollection.CountDocuments(context.TODO(), filter)
 	if err != nil {
@@ -54,15 +263,24 @@ func (instance *repository) FindWebsiteByID(userID, websiteID string) (int64, er
 }
 
 func (instance *repository) InsertWebsite(userID string, aWebsite website) error {
-	websiteCollection := configs.MongoDB.Client.Collection(configs.MongoDB.WebsiteCollection)
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
 	docs := website{
-		ID:        aWebsite.ID,
-		UserID:    userID,
-		Category:  aWebsite.Category,
-		HostName:  aWebsite.HostName,
-		URL:       aWebsite.URL,
-		CreatedAt: aWebsite.CreatedAt,
-		UpdatedAt: aWebsite.UpdatedAt,
+		ID:                aWebsite.ID,
+		UserID:            userID,
+		Name:              aWebsite.Name,
+		Category:          aWebsite.Category,
+		HostName:          aWebsite.HostName,
+		URL:               aWebsite.URL,
+		Features:          aWebsite.Features,
+		Timezone:          aWebsite.Timezone,
+		Locale:            aWebsite.Locale,
+		DateFormat:        aWebsite.DateFormat,
+		Currency:          aWebsite.Currency,
+		CreatedAt:         aWebsite.CreatedAt,
+		UpdatedAt:         aWebsite.UpdatedAt,
+		AggregateOnly:     aWebsite.AggregateOnly,
+		VerificationToken: aWebsite.VerificationToken,
+		InstallNotice:     aWebsite.InstallNotice,
 	}
 	_, err := websiteCollection.InsertOne(context.TODO(), docs)
 	if err != nil {
@@ -72,10 +290,11 @@ func (instance *repository) InsertWebsite(userID string, aWebsite website) error
 }
 
 func (instance *repository) GetWebsite(userID, websiteID string, aWebsite *website) error {
-	websiteCollection := configs.MongoDB.Client.Collection(configs.MongoDB.WebsiteCollection)
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
 	filter := bson.M{"$and": []bson.M{
 		{"user_id": userID},
 		{"id": websiteID},
+		{"deleted_at": nil},
 	}}
 	err := websiteCollection.FindOne(context.TODO(), filter).Decode(&aWebsite)
 	if err != nil {
@@ -86,8 +305,11 @@ func (instance *repository) GetWebsite(userID, websiteID string, aWebsite *websi
 
 func (instance *repository) GetAllWebsite(userID string) (*websites, error) {
 	var websites websites
-	websiteCollection := configs.MongoDB.Client.Collection(configs.MongoDB.WebsiteCollection)
-	filter := bson.M{"user_id": userID}
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"deleted_at": nil},
+	}}
 	cursor, err := websiteCollection.Find(context.TODO(), filter)
 	if err != nil {
 		return nil, err
@@ -98,11 +320,97 @@ func (instance *repository) GetAllWebsite(userID string) (*websites, error) {
 	return &websites, nil
 }
 
+// ListWebsite list websites of all users
+func (instance *repository) ListWebsite() (*websites, error) {
+	var websites websites
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	cursor, err := websiteCollection.Find(context.TODO(), bson.M{"deleted_at": nil})
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &websites); err != nil {
+		return nil, err
+	}
+	return &websites, nil
+}
+
+// DeleteWebsite mark website deleted, it is hidden from every query but kept
+// until RemoveWebsite so it can be restored
 func (instance *repository) DeleteWebsite(userID, websiteID string) error {
-	websiteCollection := configs.MongoDB.Client.Collection(configs.MongoDB.WebsiteCollection)
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
 	filter := bson.M{"$and": []bson.M{
 		{"user_id": userID},
 		{"id": websiteID},
+		{"deleted_at": nil},
+	}}
+	now := time.Now().Format("2006-01-02, 15:04:05")
+	update := bson.M{
+		"$set": bson.M{
+			"deleted_at": now,
+			"updated_at": now,
+		},
+	}
+	result, err := websiteCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	// the website is deleted whatever the cache, which expires soon anyway
+	err = configs.KeyStore.Client.Del(instance.featuresCacheKey(websiteID), instance.aggregateOnlyCacheKey(websiteID), instance.settingsCacheKey(websiteID))
+	if err != nil {
+		logrus.Error("clear cache of deleted website id ", websiteID, " error ", err)
+	}
+	return nil
+}
+
+// GetDeletedWebsite get website deleted and not yet removed
+func (instance *repository) GetDeletedWebsite(userID, websiteID string, aWebsite *website) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": websiteID},
+		{"deleted_at": bson.M{"$ne": nil}},
+	}}
+	err := websiteCollection.FindOne(context.TODO(), filter).Decode(aWebsite)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RestoreWebsite bring back website deleted, mongo.ErrNoDocuments when it is
+// not deleted or already removed
+func (instance *repository) RestoreWebsite(userID, websiteID string) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": websiteID},
+		{"deleted_at": bson.M{"$ne": nil}},
+	}}
+	update := bson.M{
+		"$unset": bson.M{"deleted_at": ""},
+		"$set":   bson.M{"updated_at": time.Now().Format("2006-01-02, 15:04:05")},
+	}
+	result, err := websiteCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// RemoveWebsite remove website deleted for good, a live one with the same id
+// is left alone
+func (instance *repository) RemoveWebsite(userID, websiteID string) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": websiteID},
+		{"deleted_at": bson.M{"$ne": nil}},
 	}}
 	deleteResult, err := websiteCollection.DeleteOne(context.TODO(), filter)
 	if err != nil {
@@ -112,8 +420,40 @@ func (instance *repository) DeleteWebsite(userID, websiteID string) error {
 	return nil
 }
 
+// CountDeleteImpact count what deleting website removes along with it
+func (instance *repository) CountDeleteImpact(userID, websiteID string) (*deleteImpact, error) {
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+	}}
+	sessionFilter := bson.M{"$and": []bson.M{
+		{"meta_data.user_id": userID},
+		{"meta_data.website_id": websiteID},
+	}}
+	var err error
+	aImpact := &deleteImpact{}
+	aImpact.Events, err = instance.store.Mongo.Collection(configs.MongoDB.SessionCollection).CountDocuments(context.TODO(), sessionFilter)
+	if err != nil {
+		return nil, err
+	}
+	aImpact.Goals, err = instance.store.Mongo.Collection(configs.MongoDB.GoalCollection).CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return nil, err
+	}
+	aImpact.Visitors, err = instance.store.Mongo.Collection(configs.MongoDB.VisitorCollection).CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return nil, err
+	}
+	mappings, err := instance.store.Mongo.Collection(configs.MongoDB.CRMMappingCollection).CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return nil, err
+	}
+	aImpact.CRMMapping = mappings > 0
+	return aImpact, nil
+}
+
 func (instance *repository) DeleteSession(userID, websiteID string) error {
-	sessionCollection := configs.MongoDB.Client.Collection(configs.MongoDB.SessionCollection)
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
 	filter := bson.M{"$and": []bson.M{
 		{"meta_data.user_id": userID},
 		{"meta_data.website_id": websiteID},
@@ -125,3 +465,519 @@ func (instance *repository) DeleteSession(userID, websiteID string) error {
 	logrus.Printf("deleted %v documents in the session collection\n", deleteResult.DeletedCount)
 	return nil
 }
+
+// DeleteEvents remove events of website from ClickHouse when it stores them,
+// the delete runs asynchronously in ClickHouse
+func (instance *repository) DeleteEvents(userID, websiteID string) error {
+	if !configs.UsesClickHouse() {
+		return nil
+	}
+	params := map[string]string{
+		"tenant":  instance.store.TenantID,
+		"user":    userID,
+		"website": websiteID,
+	}
+	return configs.ClickHouse.Client.Exec("ALTER TABLE "+db.ClickHouseEventTable+
+		" DELETE WHERE tenant_id = {tenant:String} AND user_id = {user:String} AND website_id = {website:String}", params)
+}
+
+// DeleteGoal remove goals of website
+func (instance *repository) DeleteGoal(userID, websiteID string) error {
+	goalCollection := instance.store.Mongo.Collection(configs.MongoDB.GoalCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+	}}
+	deleteResult, err := goalCollection.DeleteMany(context.TODO(), filter)
+	if err != nil {
+		return err
+	}
+	logrus.Printf("deleted %v documents in the goal collection\n", deleteResult.DeletedCount)
+	return nil
+}
+
+// DeleteMetric remove calculated metrics of website
+func (instance *repository) DeleteMetric(userID, websiteID string) error {
+	metricCollection := instance.store.Mongo.Collection(configs.MongoDB.MetricCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+	}}
+	deleteResult, err := metricCollection.DeleteMany(context.TODO(), filter)
+	if err != nil {
+		return err
+	}
+	logrus.Printf("deleted %v documents in the metric collection\n", deleteResult.DeletedCount)
+	return nil
+}
+
+// UpdateFeatures set tracker features of website and drop the cached config
+func (instance *repository) UpdateFeatures(userID, websiteID string, aFeatures *features) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": websiteID},
+		{"deleted_at": nil},
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"features":   aFeatures,
+			"updated_at": time.Now().Format("2006-01-02, 15:04:05"),
+		},
+	}
+	result, err := websiteCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return configs.KeyStore.Client.Del(instance.featuresCacheKey(websiteID))
+}
+
+// UpdateTimezone set timezone reports of website are computed in
+func (instance *repository) UpdateTimezone(userID, websiteID, timezone string) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": websiteID},
+		{"deleted_at": nil},
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"timezone":   timezone,
+			"updated_at": time.Now().Format("2006-01-02, 15:04:05"),
+		},
+	}
+	result, err := websiteCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return configs.KeyStore.Client.Del(instance.settingsCacheKey(websiteID))
+}
+
+// UpdateWebsite set fields of website, bump updated_at and its version, and
+// decode the website as updated in aWebsite. With a version the website is
+// only updated at that version, mongo.ErrNoDocuments otherwise
+func (instance *repository) UpdateWebsite(userID, websiteID string, fields bson.M, version *int64, aWebsite *website) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	and := []bson.M{
+		{"user_id": userID},
+		{"id": websiteID},
+		{"deleted_at": nil},
+	}
+	if version != nil {
+		and = append(and, etag.Filter(*version))
+	}
+	set := bson.M{"updated_at": time.Now().Format("2006-01-02, 15:04:05")}
+	for key, value := range fields {
+		set[key] = value
+	}
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := websiteCollection.FindOneAndUpdate(context.TODO(), bson.M{"$and": and}, update, opts).Decode(aWebsite)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateContentGroups replace content group rules of website
+func (instance *repository) UpdateContentGroups(userID, websiteID string, contentGroups []contentGroup) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": websiteID},
+		{"deleted_at": nil},
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"content_groups": contentGroups,
+			"updated_at":     time.Now().Format("2006-01-02, 15:04:05"),
+		},
+	}
+	result, err := websiteCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// GetFeatures get tracker features of website, cached in the key store
+func (instance *repository) GetFeatures(websiteID string) (*features, error) {
+	var aFeatures features
+	cached, err := configs.KeyStore.Client.Get(instance.featuresCacheKey(websiteID))
+	if err == nil && json.Unmarshal([]byte(cached), &aFeatures) == nil {
+		return &aFeatures, nil
+	}
+
+	var aWebsite website
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"id": websiteID},
+		{"deleted_at": nil},
+	}}
+	err = websiteCollection.FindOne(context.TODO(), filter).Decode(&aWebsite)
+	if err != nil {
+		return nil, err
+	}
+	if aWebsite.Features == nil {
+		aWebsite.Features = defaultFeatures()
+	}
+
+	data, err := json.Marshal(aWebsite.Features)
+	if err != nil {
+		return nil, err
+	}
+	err = configs.KeyStore.Client.Set(instance.featuresCacheKey(websiteID), string(data), featuresCacheTTL)
+	if err != nil {
+		logrus.Error("cache website features error ", err)
+	}
+	return aWebsite.Features, nil
+}
+
+func (instance *repository) featuresCacheKey(websiteID string) string {
+	return instance.store.Key("website_features:" + websiteID)
+}
+
+// UpdateVisitorWebhook set the endpoint told of identified visitors, removed when nil
+func (instance *repository) UpdateVisitorWebhook(userID, websiteID string, aWebhook *visitorWebhook) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": websiteID},
+		{"deleted_at": nil},
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"visitor_webhook": aWebhook,
+			"updated_at":      time.Now().Format("2006-01-02, 15:04:05"),
+		},
+	}
+	if aWebhook == nil {
+		update = bson.M{
+			"$unset": bson.M{"visitor_webhook": ""},
+			"$set":   bson.M{"updated_at": time.Now().Format("2006-01-02, 15:04:05")},
+		}
+	}
+	result, err := websiteCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// DeleteVisitor remove identified visitors of website
+func (instance *repository) DeleteVisitor(userID, websiteID string) error {
+	visitorCollection := instance.store.Mongo.Collection(configs.MongoDB.VisitorCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+	}}
+	deleteResult, err := visitorCollection.DeleteMany(context.TODO(), filter)
+	if err != nil {
+		return err
+	}
+	logrus.Printf("deleted %v documents in the visitor collection\n", deleteResult.DeletedCount)
+	return nil
+}
+
+// DeleteCRMMapping remove the CRM mapping of website and its queued pushes
+func (instance *repository) DeleteCRMMapping(userID, websiteID string) error {
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+	}}
+	for _, name := range []string{configs.MongoDB.CRMMappingCollection, configs.MongoDB.CRMQueueCollection} {
+		deleteResult, err := instance.store.Mongo.Collection(name).DeleteMany(context.TODO(), filter)
+		if err != nil {
+			return err
+		}
+		logrus.Printf("deleted %v documents in the %s collection\n", deleteResult.DeletedCount, name)
+	}
+	return nil
+}
+
+// UpdateSegmentWriteKey replace the key Segment calls of website are sent with
+func (instance *repository) UpdateSegmentWriteKey(userID, websiteID, writeKey string) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": websiteID},
+		{"deleted_at": nil},
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"segment_write_key": writeKey,
+			"updated_at":        time.Now().Format("2006-01-02, 15:04:05"),
+		},
+	}
+	result, err := websiteCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// FindSegmentWriteKey website Segment calls sent with writeKey belong to
+func (instance *repository) FindSegmentWriteKey(writeKey string, aWebsite *website) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"segment_write_key": writeKey},
+		{"deleted_at": nil},
+	}}
+	err := websiteCollection.FindOne(context.TODO(), filter).Decode(aWebsite)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateSettings replace the settings of website and bump its version,
+// returned. The cached settings are dropped so batches follow them at once.
+// With a version the website is only updated at that version,
+// mongo.ErrNoDocuments otherwise
+func (instance *repository) UpdateSettings(userID, websiteID string, aSettings *Settings, version *int64) (int64, error) {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	and := []bson.M{
+		{"user_id": userID},
+		{"id": websiteID},
+		{"deleted_at": nil},
+	}
+	if version != nil {
+		and = append(and, etag.Filter(*version))
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"timezone":   aSettings.Timezone,
+			"settings":   aSettings,
+			"updated_at": time.Now().Format("2006-01-02, 15:04:05"),
+		},
+		"$inc": bson.M{"version": 1},
+	}
+	var updated struct {
+		Version int64 `bson:"version"`
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"version": 1})
+	err := websiteCollection.FindOneAndUpdate(context.TODO(), bson.M{"$and": and}, update, opts).Decode(&updated)
+	if err != nil {
+		return 0, err
+	}
+	return updated.Version, configs.KeyStore.Client.Del(instance.settingsCacheKey(websiteID))
+}
+
+// GetSettings get settings of website, cached in the key store since every batch asks
+func (instance *repository) GetSettings(websiteID string) (*Settings, error) {
+	var aSettings Settings
+	cached, err := configs.KeyStore.Client.Get(instance.settingsCacheKey(websiteID))
+	if err == nil && json.Unmarshal([]byte(cached), &aSettings) == nil {
+		return &aSettings, nil
+	}
+
+	var aWebsite website
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"id": websiteID},
+		{"deleted_at": nil},
+	}}
+	err = websiteCollection.FindOne(context.TODO(), filter).Decode(&aWebsite)
+	if err != nil {
+		return nil, err
+	}
+	if aWebsite.Settings != nil {
+		aSettings = *aWebsite.Settings
+	}
+	aSettings.Timezone = aWebsite.Timezone
+
+	data, err := json.Marshal(aSettings)
+	if err != nil {
+		return nil, err
+	}
+	err = configs.KeyStore.Client.Set(instance.settingsCacheKey(websiteID), string(data), featuresCacheTTL)
+	if err != nil {
+		logrus.Error("cache website settings error ", err)
+	}
+	return &aSettings, nil
+}
+
+func (instance *repository) settingsCacheKey(websiteID string) string {
+	return instance.store.Key("website_settings:" + websiteID)
+}
+
+// UpdateShare replace the public link of website, a nil share stops sharing
+func (instance *repository) UpdateShare(userID, websiteID string, aShare *Share) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": websiteID},
+		{"deleted_at": nil},
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"share":      aShare,
+			"updated_at": time.Now().Format("2006-01-02, 15:04:05"),
+		},
+	}
+	if aShare == nil {
+		update = bson.M{
+			"$set":   bson.M{"updated_at": time.Now().Format("2006-01-02, 15:04:05")},
+			"$unset": bson.M{"share": ""},
+		}
+	}
+	result, err := websiteCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// FindShareToken website shared with token
+func (instance *repository) FindShareToken(token string, aWebsite *website) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"share.token": token},
+		{"deleted_at": nil},
+	}}
+	return websiteCollection.FindOne(context.TODO(), filter).Decode(aWebsite)
+}
+
+// UpdateVerified record when the ownership of website was verified
+func (instance *repository) UpdateVerified(userID, websiteID, verifiedAt string) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": websiteID},
+		{"deleted_at": nil},
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"verified_at": verifiedAt,
+			"updated_at":  time.Now().Format("2006-01-02, 15:04:05"),
+		},
+	}
+	result, err := websiteCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// UpdateAggregateOnly switch aggregate-only mode of website, the cached mode
+// is dropped so batches follow it at once
+func (instance *repository) UpdateAggregateOnly(userID, websiteID string, enabled bool) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": websiteID},
+		{"deleted_at": nil},
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"aggregate_only": enabled,
+			"updated_at":     time.Now().Format("2006-01-02, 15:04:05"),
+		},
+	}
+	result, err := websiteCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return configs.KeyStore.Client.Del(instance.aggregateOnlyCacheKey(websiteID))
+}
+
+// GetAggregateOnly whether website is in aggregate-only mode, cached in the key store
+// since every batch asks
+func (instance *repository) GetAggregateOnly(websiteID string) (bool, error) {
+	cached, err := configs.KeyStore.Client.Get(instance.aggregateOnlyCacheKey(websiteID))
+	if err == nil {
+		return cached == "1", nil
+	}
+
+	var aWebsite website
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"id": websiteID},
+		{"deleted_at": nil},
+	}}
+	err = websiteCollection.FindOne(context.TODO(), filter).Decode(&aWebsite)
+	if err != nil {
+		return false, err
+	}
+
+	value := "0"
+	if aWebsite.AggregateOnly {
+		value = "1"
+	}
+	err = configs.KeyStore.Client.Set(instance.aggregateOnlyCacheKey(websiteID), value, featuresCacheTTL)
+	if err != nil {
+		logrus.Error("cache website aggregate-only error ", err)
+	}
+	return aWebsite.AggregateOnly, nil
+}
+
+func (instance *repository) aggregateOnlyCacheKey(websiteID string) string {
+	return instance.store.Key("website_aggregate_only:" + websiteID)
+}
+
+// MarkTracked set website tracked with its last event at, the first event
+// set once. Skipped when the website was marked less than trackedThrottle ago
+func (instance *repository) MarkTracked(websiteID, at string) error {
+	fresh, err := configs.KeyStore.Client.SetNX(instance.trackedCacheKey(websiteID), at, trackedThrottle)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return nil
+	}
+
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	update := bson.M{
+		"$set": bson.M{"tracked": true, "last_event_at": at},
+		// the layout of at sorts like time
+		"$min": bson.M{"first_event_at": at},
+	}
+	_, err = websiteCollection.UpdateOne(context.TODO(), bson.M{"id": websiteID}, update)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *repository) trackedCacheKey(websiteID string) string {
+	return instance.store.Key("website_tracked:" + websiteID)
+}
+
+// DeleteAggregates remove the counters of website
+func (instance *repository) DeleteAggregates(userID, websiteID string) error {
+	aggregateCollection := instance.store.Mongo.Collection(configs.MongoDB.AggregateCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+	}}
+	deleteResult, err := aggregateCollection.DeleteMany(context.TODO(), filter)
+	if err != nil {
+		return err
+	}
+	logrus.Printf("deleted %v documents in the aggregate collection\n", deleteResult.DeletedCount)
+	return nil
+}
@@ -0,0 +1,279 @@
+package website
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/internal/pkg/webhook"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// CodeServerKeyNotFound ...
+const CodeServerKeyNotFound = "server_key_not_found"
+
+// MaxServerKeys server keys of a website, one per backend and a spare to
+// rotate without downtime
+const MaxServerKeys = 5
+
+// serverKeyPrefix starts every secret so leaked keys are easy to scan for
+const serverKeyPrefix = "sk_"
+
+// lastUsedInterval last use of a key is written at most this often, not on
+// every batch
+const lastUsedInterval = time.Minute
+
+var (
+	// ErrInvalidKeyName ...
+	ErrInvalidKeyName = errors.New("name of a key must be 1 to 100 characters")
+	// ErrTooManyServerKeys ...
+	ErrTooManyServerKeys = errors.New("at most 5 server keys per website")
+	// ErrServerKeyNotFound ...
+	ErrServerKeyNotFound = errors.New("this server key not exists")
+)
+
+// ServerKey key a backend sends the events of a website with, instead of a
+// tracker. Only the hash of the secret is kept, the secret is in the
+// response creating or rotating the key
+type ServerKey struct {
+	ID     string `json:"id" bson:"id"`
+	Name   string `json:"name" bson:"name"`
+	Hash   string `json:"-" bson:"hash"`
+	Secret string `json:"secret,omitempty" bson:"-"`
+	// Prefix first characters of the secret, to tell keys apart
+	Prefix     string `json:"prefix" bson:"prefix"`
+	CreatedAt  string `json:"created_at" bson:"created_at"`
+	RotatedAt  string `json:"rotated_at,omitempty" bson:"rotated_at,omitempty"`
+	LastUsedAt string `json:"last_used_at,omitempty" bson:"last_used_at,omitempty"`
+}
+
+// hashServerKey hex SHA-256 of secret, as stored
+func hashServerKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// newServerKeySecret ...
+func newServerKeySecret() (string, error) {
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return "", err
+	}
+	return serverKeyPrefix + secret, nil
+}
+
+// InsertServerKey add aKey to website
+func (instance *repository) InsertServerKey(userID, websiteID string, aKey ServerKey) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": websiteID},
+		{"deleted_at": nil},
+	}}
+	update := bson.M{"$push": bson.M{"server_keys": aKey}}
+	result, err := websiteCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// UpdateServerKey replace the secret of key of website with the one of
+// hash, ErrServerKeyNotFound when website has no such key
+func (instance *repository) UpdateServerKey(userID, websiteID, keyID, hash, prefix string) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": websiteID},
+		{"deleted_at": nil},
+		{"server_keys.id": keyID},
+	}}
+	update := bson.M{"$set": bson.M{
+		"server_keys.$.hash":       hash,
+		"server_keys.$.prefix":     prefix,
+		"server_keys.$.rotated_at": time.Now().Format("2006-01-02, 15:04:05"),
+	}}
+	result, err := websiteCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrServerKeyNotFound
+	}
+	return nil
+}
+
+// DeleteServerKey remove key of website, return the count of keys removed
+func (instance *repository) DeleteServerKey(userID, websiteID, keyID string) (int64, error) {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": websiteID},
+		{"server_keys.id": keyID},
+	}}
+	update := bson.M{"$pull": bson.M{"server_keys": bson.M{"id": keyID}}}
+	result, err := websiteCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// FindServerKey website with the key of hash, and only that key
+func (instance *repository) FindServerKey(hash string, aWebsite *website) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"server_keys.hash": hash},
+		{"deleted_at": nil},
+	}}
+	opts := options.FindOne().SetProjection(bson.M{"id": 1, "user_id": 1, "server_keys.$": 1})
+	err := websiteCollection.FindOne(context.TODO(), filter, opts).Decode(aWebsite)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateServerKeyUsed set when key of website was last used
+func (instance *repository) UpdateServerKeyUsed(websiteID, keyID, lastUsedAt string) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"id": websiteID},
+		{"server_keys.id": keyID},
+	}}
+	update := bson.M{"$set": bson.M{"server_keys.$.last_used_at": lastUsedAt}}
+	_, err := websiteCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetServerKeys keys of website without their secret
+func (instance *useCase) GetServerKeys(userID, websiteID string) ([]ServerKey, error) {
+	var aWebsite website
+	err := instance.repo.GetWebsite(userID, websiteID, &aWebsite)
+	if err != nil {
+		return nil, err
+	}
+	if aWebsite.ServerKeys == nil {
+		return []ServerKey{}, nil
+	}
+	return aWebsite.ServerKeys, nil
+}
+
+// CreateServerKey new key of website named name, the returned key carries
+// its secret
+func (instance *useCase) CreateServerKey(userID, websiteID, name string) (*ServerKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, ErrInvalidKeyName
+	}
+	var aWebsite website
+	err := instance.repo.GetWebsite(userID, websiteID, &aWebsite)
+	if err != nil {
+		return nil, err
+	}
+	if len(aWebsite.ServerKeys) >= MaxServerKeys {
+		return nil, ErrTooManyServerKeys
+	}
+	secret, err := newServerKeySecret()
+	if err != nil {
+		return nil, err
+	}
+
+	aKey := ServerKey{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Hash:      hashServerKey(secret),
+		Prefix:    secret[:len(serverKeyPrefix)+6],
+		CreatedAt: time.Now().Format("2006-01-02, 15:04:05"),
+	}
+	err = instance.repo.InsertServerKey(userID, websiteID, aKey)
+	if err != nil {
+		return nil, err
+	}
+	aKey.Secret = secret
+	return &aKey, nil
+}
+
+// RotateServerKey give key of website a new secret, batches sent with the
+// previous one are refused at once
+func (instance *useCase) RotateServerKey(userID, websiteID, keyID string) (*ServerKey, error) {
+	var aWebsite website
+	err := instance.repo.GetWebsite(userID, websiteID, &aWebsite)
+	if err != nil {
+		return nil, err
+	}
+	for _, aKey := range aWebsite.ServerKeys {
+		if aKey.ID != keyID {
+			continue
+		}
+		secret, err := newServerKeySecret()
+		if err != nil {
+			return nil, err
+		}
+		aKey.Secret = secret
+		aKey.Prefix = secret[:len(serverKeyPrefix)+6]
+		err = instance.repo.UpdateServerKey(userID, websiteID, keyID, hashServerKey(secret), aKey.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		aKey.RotatedAt = time.Now().Format("2006-01-02, 15:04:05")
+		return &aKey, nil
+	}
+	return nil, ErrServerKeyNotFound
+}
+
+// RevokeServerKey remove key of website, batches sent with it are refused
+// at once
+func (instance *useCase) RevokeServerKey(userID, websiteID, keyID string) error {
+	var aWebsite website
+	err := instance.repo.GetWebsite(userID, websiteID, &aWebsite)
+	if err != nil {
+		return err
+	}
+	deleted, err := instance.repo.DeleteServerKey(userID, websiteID, keyID)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrServerKeyNotFound
+	}
+	return nil
+}
+
+// FindServerKey user and id of the website of the server key secret,
+// mongo.ErrNoDocuments when no website has it
+func (instance *useCase) FindServerKey(secret string) (string, string, error) {
+	if !strings.HasPrefix(secret, serverKeyPrefix) {
+		return "", "", mongo.ErrNoDocuments
+	}
+	var aWebsite website
+	err := instance.repo.FindServerKey(hashServerKey(secret), &aWebsite)
+	if err != nil {
+		return "", "", err
+	}
+	if len(aWebsite.ServerKeys) == 1 {
+		aKey := aWebsite.ServerKeys[0]
+		lastUsedAt, parseErr := time.ParseInLocation("2006-01-02, 15:04:05", aKey.LastUsedAt, time.Local)
+		if parseErr != nil || time.Since(lastUsedAt) >= lastUsedInterval {
+			err = instance.repo.UpdateServerKeyUsed(aWebsite.ID, aKey.ID, time.Now().Format("2006-01-02, 15:04:05"))
+			if err != nil {
+				return "", "", err
+			}
+		}
+	}
+	return aWebsite.UserID, aWebsite.ID, nil
+}
@@ -0,0 +1,173 @@
+package website
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// fakeServerKeys website with keys, keeping what is written to them
+type fakeServerKeys struct {
+	Repository
+	keys     []ServerKey
+	inserted *ServerKey
+	rotated  string
+	used     []string
+}
+
+func (instance *fakeServerKeys) GetWebsite(userID, websiteID string, aWebsite *website) error {
+	aWebsite.ID = websiteID
+	aWebsite.UserID = userID
+	aWebsite.ServerKeys = instance.keys
+	return nil
+}
+
+func (instance *fakeServerKeys) InsertServerKey(userID, websiteID string, aKey ServerKey) error {
+	instance.inserted = &aKey
+	return nil
+}
+
+func (instance *fakeServerKeys) UpdateServerKey(userID, websiteID, keyID, hash, prefix string) error {
+	instance.rotated = hash
+	return nil
+}
+
+func (instance *fakeServerKeys) DeleteServerKey(userID, websiteID, keyID string) (int64, error) {
+	for _, aKey := range instance.keys {
+		if aKey.ID == keyID {
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (instance *fakeServerKeys) FindServerKey(hash string, aWebsite *website) error {
+	for _, aKey := range instance.keys {
+		if aKey.Hash == hash {
+			aWebsite.ID = "w"
+			aWebsite.UserID = "u"
+			aWebsite.ServerKeys = []ServerKey{aKey}
+			return nil
+		}
+	}
+	return mongo.ErrNoDocuments
+}
+
+func (instance *fakeServerKeys) UpdateServerKeyUsed(websiteID, keyID, lastUsedAt string) error {
+	instance.used = append(instance.used, keyID)
+	return nil
+}
+
+func TestCreateServerKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyName string
+		keys    int
+		wantErr error
+	}{
+		{name: "should create a key with its secret", keyName: " billing ", keys: 0},
+		{name: "should create the last key allowed", keyName: "billing", keys: MaxServerKeys - 1},
+		{name: "should refuse a key without name", keyName: "  ", wantErr: ErrInvalidKeyName},
+		{name: "should refuse a name too long", keyName: strings.Repeat("a", 101), wantErr: ErrInvalidKeyName},
+		{name: "should refuse a key past the limit", keyName: "billing", keys: MaxServerKeys, wantErr: ErrTooManyServerKeys},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeServerKeys{keys: make([]ServerKey, tt.keys)}
+			instance := &useCase{repo: repo}
+			got, err := instance.CreateServerKey("u", "w", tt.keyName)
+			if err != tt.wantErr {
+				t.Fatalf("CreateServerKey() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if repo.inserted != nil {
+					t.Errorf("CreateServerKey() inserted a key on error")
+				}
+				return
+			}
+			if got.Name != "billing" || !strings.HasPrefix(got.Secret, serverKeyPrefix) || !strings.HasPrefix(got.Secret, got.Prefix) {
+				t.Errorf("CreateServerKey() = %+v", got)
+			}
+			if repo.inserted.Secret != "" || repo.inserted.Hash != hashServerKey(got.Secret) {
+				t.Errorf("CreateServerKey() stored %+v, want only the hash of the secret", repo.inserted)
+			}
+		})
+	}
+}
+
+func TestRotateServerKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyID   string
+		wantErr error
+	}{
+		{name: "should give the key a new secret", keyID: "k1"},
+		{name: "should refuse an unknown key", keyID: "k2", wantErr: ErrServerKeyNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeServerKeys{keys: []ServerKey{{ID: "k1", Name: "billing", Hash: hashServerKey("sk_old")}}}
+			instance := &useCase{repo: repo}
+			got, err := instance.RotateServerKey("u", "w", tt.keyID)
+			if err != tt.wantErr {
+				t.Fatalf("RotateServerKey() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Secret == "sk_old" || repo.rotated != hashServerKey(got.Secret) || got.RotatedAt == "" {
+				t.Errorf("RotateServerKey() = %+v, stored hash %s", got, repo.rotated)
+			}
+		})
+	}
+}
+
+func TestRevokeServerKey(t *testing.T) {
+	repo := &fakeServerKeys{keys: []ServerKey{{ID: "k1"}}}
+	instance := &useCase{repo: repo}
+	if err := instance.RevokeServerKey("u", "w", "k1"); err != nil {
+		t.Errorf("RevokeServerKey() error = %v, want nil", err)
+	}
+	if err := instance.RevokeServerKey("u", "w", "k2"); err != ErrServerKeyNotFound {
+		t.Errorf("RevokeServerKey() error = %v, want %v", err, ErrServerKeyNotFound)
+	}
+}
+
+func TestFindServerKey(t *testing.T) {
+	recently := time.Now().Add(-lastUsedInterval / 2).Format("2006-01-02, 15:04:05")
+	long := time.Now().Add(-2 * lastUsedInterval).Format("2006-01-02, 15:04:05")
+	tests := []struct {
+		name     string
+		secret   string
+		lastUsed string
+		wantErr  error
+		wantUsed bool
+	}{
+		{name: "should find the website of the key", secret: "sk_secret", lastUsed: long, wantUsed: true},
+		{name: "should write the first use of the key", secret: "sk_secret", lastUsed: "", wantUsed: true},
+		{name: "should not write a use again so soon", secret: "sk_secret", lastUsed: recently},
+		{name: "should refuse an unknown secret", secret: "sk_other", wantErr: mongo.ErrNoDocuments},
+		{name: "should refuse a secret that is no server key", secret: "secret", wantErr: mongo.ErrNoDocuments},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeServerKeys{keys: []ServerKey{
+				{ID: "k1", Hash: hashServerKey("sk_secret"), LastUsedAt: tt.lastUsed},
+				{ID: "k2", Hash: hashServerKey("secret")},
+			}}
+			instance := &useCase{repo: repo}
+			userID, websiteID, err := instance.FindServerKey(tt.secret)
+			if err != tt.wantErr {
+				t.Fatalf("FindServerKey() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (userID != "u" || websiteID != "w") {
+				t.Errorf("FindServerKey() = %s, %s, want u, w", userID, websiteID)
+			}
+			if used := len(repo.used) > 0; used != tt.wantUsed {
+				t.Errorf("FindServerKey() wrote the last use %v, want %v", used, tt.wantUsed)
+			}
+		})
+	}
+}
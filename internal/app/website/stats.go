@@ -0,0 +1,144 @@
+package website
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/clickhouse"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// pageviewEventType rrweb meta event, recorded once per page load
+const pageviewEventType = 4
+
+// screenViewTag custom event mobile SDKs send instead of a page view
+const screenViewTag = "screen_view"
+
+// Stats rollup of a website shown in the list. The tracker has no visitor
+// id, Visitors24h counts the sessions with events in the last 24 hours
+type Stats struct {
+	Visitors24h int64 `json:"visitors_24h"`
+	Pageviews7d int64 `json:"pageviews_7d"`
+	// LastEventAt tracking status of the website
+	LastEventAt string `json:"last_event_at,omitempty"`
+}
+
+// statsRow Stats of the website of ID
+type statsRow struct {
+	ID          string `json:"website_id" bson:"_id"`
+	Visitors24h int64  `json:"visitors_24h" bson:"visitors_24h"`
+	Pageviews7d int64  `json:"pageviews_7d" bson:"pageviews_7d"`
+}
+
+// GetStats visitors of the last 24 hours and pageviews of the last 7 days
+// before now of websiteIDs, in one query to the primary event storage.
+// Websites without events are left out
+func (instance *repository) GetStats(userID string, websiteIDs []string, now time.Time) ([]statsRow, error) {
+	if configs.Storage.Primary == "clickhouse" {
+		return instance.getClickHouseStats(userID, websiteIDs, now)
+	}
+	day := now.Add(-24 * time.Hour)
+	week := now.AddDate(0, 0, -7)
+	pageview := bson.M{"$cond": []interface{}{
+		bson.M{"$or": []bson.M{
+			{"$eq": []interface{}{"$event.type", pageviewEventType}},
+			{"$eq": []interface{}{"$event.data.tag", screenViewTag}},
+		}},
+		1,
+		0,
+	}}
+	pipeline := []bson.M{
+		{"$match": bson.M{"$and": []bson.M{
+			{"meta_data.user_id": userID},
+			{"meta_data.website_id": bson.M{"$in": websiteIDs}},
+			{"event.late": bson.M{"$ne": true}},
+			{"time_report": bson.M{"$gte": week, "$lt": now}},
+		}}},
+		{"$group": bson.M{
+			"_id":       bson.M{"website_id": "$meta_data.website_id", "id": "$meta_data.id"},
+			"pageviews": bson.M{"$sum": pageview},
+			"last":      bson.M{"$max": "$time_report"},
+		}},
+		{"$group": bson.M{
+			"_id":          "$_id.website_id",
+			"visitors_24h": bson.M{"$sum": bson.M{"$cond": []interface{}{bson.M{"$gte": []interface{}{"$last", day}}, 1, 0}}},
+			"pageviews_7d": bson.M{"$sum": "$pageviews"},
+		}},
+	}
+
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	cur, err := db.Aggregate(context.TODO(), sessionCollection, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var rows []statsRow
+	if err := cur.All(context.TODO(), &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (instance *repository) getClickHouseStats(userID string, websiteIDs []string, now time.Time) ([]statsRow, error) {
+	params := map[string]string{
+		"tenant":   instance.store.TenantID,
+		"user":     userID,
+		"websites": clickhouse.ArrayParam(websiteIDs),
+		"day":      clickhouse.TimeParam(now.Add(-24 * time.Hour)),
+		"week":     clickhouse.TimeParam(now.AddDate(0, 0, -7)),
+		"now":      clickhouse.TimeParam(now),
+	}
+	sessions := "SELECT website_id, countIf(type = 4 OR JSONExtractString(data, 'tag') = '" + screenViewTag + "') AS pageviews," +
+		" max(time_report) AS last FROM " + db.ClickHouseEventTable +
+		" WHERE tenant_id = {tenant:String} AND user_id = {user:String} AND website_id IN {websites:Array(String)} AND NOT late" +
+		" AND time_report >= {week:DateTime64(3)} AND time_report < {now:DateTime64(3)}" +
+		" GROUP BY website_id, id"
+	query := "SELECT website_id, countIf(last >= {day:DateTime64(3)}) AS visitors_24h, sum(pageviews) AS pageviews_7d" +
+		" FROM (" + sessions + ") GROUP BY website_id"
+
+	var rows []statsRow
+	err := configs.ClickHouse.Client.Query(query, params, func(line []byte) error {
+		var row statsRow
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// AttachStats set the Stats of each of websites of user
+func (instance *useCase) AttachStats(userID string, websites *websites) error {
+	if len(*websites) == 0 {
+		return nil
+	}
+	websiteIDs := make([]string, len(*websites))
+	for i, aWebsite := range *websites {
+		websiteIDs[i] = aWebsite.ID
+	}
+	rows, err := instance.repo.GetStats(userID, websiteIDs, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]statsRow, len(rows))
+	for _, row := range rows {
+		byID[row.ID] = row
+	}
+	for i := range *websites {
+		aWebsite := &(*websites)[i]
+		row := byID[aWebsite.ID]
+		aWebsite.Stats = &Stats{
+			Visitors24h: row.Visitors24h,
+			Pageviews7d: row.Pageviews7d,
+			LastEventAt: aWebsite.LastEventAt,
+		}
+	}
+	return nil
+}
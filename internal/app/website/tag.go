@@ -0,0 +1,231 @@
+package website
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// MaxTags tags a website carries at most
+const MaxTags = 20
+
+// ErrInvalidTag ...
+var ErrInvalidTag = errors.New("a tag has 1 to 30 characters")
+
+// CodeTagNotFound ...
+const CodeTagNotFound = "tag_not_found"
+
+// ErrTagNotFound ...
+var ErrTagNotFound = errors.New("no website has this tag")
+
+// tagCount tag of the websites of a user with how many carry it
+type tagCount struct {
+	Tag      string `json:"tag" bson:"_id"`
+	Websites int64  `json:"websites" bson:"websites"`
+}
+
+// tagFilter websites of user not deleted, and carrying tag when set
+func tagFilter(userID, tag string, and ...bson.M) bson.M {
+	filters := []bson.M{
+		{"user_id": userID},
+		{"deleted_at": nil},
+	}
+	if tag != "" {
+		filters = append(filters, bson.M{"tags": tag})
+	}
+	return bson.M{"$and": append(filters, and...)}
+}
+
+// scopeFilter websites carrying one of scope, a member limited to these tags
+// sees no other. Nothing to filter without scope
+func scopeFilter(scope []string) []bson.M {
+	if len(scope) == 0 {
+		return nil
+	}
+	return []bson.M{{"tags": bson.M{"$in": scope}}}
+}
+
+// GetTaggedWebsite websites of user carrying every tag of tags and one of
+// scope
+func (instance *repository) GetTaggedWebsite(userID string, tags, scope []string) (*websites, error) {
+	var websites websites
+	and := scopeFilter(scope)
+	if len(tags) > 0 {
+		and = append(and, bson.M{"tags": bson.M{"$all": tags}})
+	}
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	cursor, err := websiteCollection.Find(context.TODO(), tagFilter(userID, "", and...))
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &websites); err != nil {
+		return nil, err
+	}
+	return &websites, nil
+}
+
+// GetTags tags of the websites of user carrying one of scope with their
+// count, by name
+func (instance *repository) GetTags(userID string, scope []string) ([]tagCount, error) {
+	tags := []tagCount{}
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	pipeline := []bson.M{
+		{"$match": tagFilter(userID, "", scopeFilter(scope)...)},
+		{"$unwind": "$tags"},
+		{"$group": bson.M{"_id": "$tags", "websites": bson.M{"$sum": 1}}},
+		{"$sort": bson.M{"_id": 1}},
+	}
+	cursor, err := db.Aggregate(context.TODO(), websiteCollection, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(context.TODO(), &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// AddTag add tag to the websites of websiteIDs with fewer than MaxTags tags
+// or carrying it already, returns their count
+func (instance *repository) AddTag(userID, tag string, websiteIDs []string) (int64, error) {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := tagFilter(userID, "",
+		bson.M{"id": bson.M{"$in": websiteIDs}},
+		bson.M{"$or": []bson.M{
+			{fmt.Sprintf("tags.%d", MaxTags-1): bson.M{"$exists": false}},
+			{"tags": tag},
+		}},
+	)
+	update := bson.M{
+		"$addToSet": bson.M{"tags": tag},
+		"$set":      bson.M{"updated_at": time.Now().Format("2006-01-02, 15:04:05")},
+	}
+	result, err := websiteCollection.UpdateMany(context.TODO(), filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.MatchedCount, nil
+}
+
+// RenameTag carry name instead of tag on the websites of user, those having
+// both keep one. Returns the count of websites that had tag
+func (instance *repository) RenameTag(userID, tag, name string) (int64, error) {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	updatedAt := time.Now().Format("2006-01-02, 15:04:05")
+	result, err := websiteCollection.UpdateMany(context.TODO(), tagFilter(userID, tag), bson.M{
+		"$addToSet": bson.M{"tags": name},
+		"$set":      bson.M{"updated_at": updatedAt},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if result.MatchedCount == 0 {
+		return 0, nil
+	}
+	_, err = websiteCollection.UpdateMany(context.TODO(), tagFilter(userID, tag), bson.M{"$pull": bson.M{"tags": tag}})
+	if err != nil {
+		return 0, err
+	}
+	return result.MatchedCount, nil
+}
+
+// DeleteTag remove tag from the websites of user, returns their count
+func (instance *repository) DeleteTag(userID, tag string) (int64, error) {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	result, err := websiteCollection.UpdateMany(context.TODO(), tagFilter(userID, tag), bson.M{
+		"$pull": bson.M{"tags": tag},
+		"$set":  bson.M{"updated_at": time.Now().Format("2006-01-02, 15:04:05")},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// normalizeTag tag compared like NormalizeTags does, ErrInvalidTag when
+// nothing or more than 30 characters are left
+func normalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" || len([]rune(tag)) > 30 {
+		return "", ErrInvalidTag
+	}
+	return tag, nil
+}
+
+// GetTaggedWebsite websites of user carrying all of tags, like a client and
+// an environment, empty tags are left out. All websites of user without tags
+func (instance *useCase) GetTaggedWebsite(userID string, tags ...string) (*websites, error) {
+	return instance.GetScopedWebsite(userID, nil, tags...)
+}
+
+// GetScopedWebsite GetTaggedWebsite among the websites carrying one of
+// scope, the tags a member of an organization is limited to
+func (instance *useCase) GetScopedWebsite(userID string, scope []string, tags ...string) (*websites, error) {
+	websites, err := instance.repo.GetTaggedWebsite(userID, NormalizeTags(tags), scope)
+	if err != nil {
+		return nil, err
+	}
+	return websites, nil
+}
+
+// GetTags tags of the websites of user carrying one of scope, all of them
+// without scope, with how many carry each
+func (instance *useCase) GetTags(userID string, scope []string) ([]tagCount, error) {
+	tags, err := instance.repo.GetTags(userID, scope)
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// AddTag tag the websites of websiteIDs of user, those carrying MaxTags tags
+// already are left as they are. Returns the count of websites tagged
+func (instance *useCase) AddTag(userID, tag string, websiteIDs []string) (int64, error) {
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return 0, err
+	}
+	count, err := instance.repo.AddTag(userID, tag, websiteIDs)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// RenameTag call tag name on every website of user, ErrTagNotFound when no
+// website has it
+func (instance *useCase) RenameTag(userID, tag, name string) (int64, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	name, err := normalizeTag(name)
+	if err != nil {
+		return 0, err
+	}
+	count, err := instance.repo.RenameTag(userID, tag, name)
+	if err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, ErrTagNotFound
+	}
+	return count, nil
+}
+
+// DeleteTag remove tag from every website of user, ErrTagNotFound when no
+// website has it
+func (instance *useCase) DeleteTag(userID, tag string) (int64, error) {
+	count, err := instance.repo.DeleteTag(userID, strings.ToLower(strings.TrimSpace(tag)))
+	if err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, ErrTagNotFound
+	}
+	return count, nil
+}
@@ -0,0 +1,131 @@
+package website
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"analytics-api/configs"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// CodeTrackingIDTaken ...
+const CodeTrackingIDTaken = "tracking_id_taken"
+
+// ErrInvalidTrackingID ...
+var ErrInvalidTrackingID = errors.New("a tracking id has 3 to 30 lowercase letters, digits or dashes, starting with a letter")
+
+// ErrTrackingIDTaken ...
+var ErrTrackingIDTaken = errors.New("this tracking id is taken by another website")
+
+// trackingIDPattern slugs are shorter than the uuid and md5 ids of websites
+// so they are never mistaken for one
+var trackingIDPattern = regexp.MustCompile(`^[a-z][a-z0-9-]{1,28}[a-z0-9]$`)
+
+// FindTrackingID count websites but website, deleted ones too, whose id or
+// tracking id is trackingID
+func (instance *repository) FindTrackingID(websiteID, trackingID string) (int64, error) {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"id": bson.M{"$ne": websiteID}},
+		{"$or": []bson.M{{"id": trackingID}, {"alias_ids": trackingID}, {"tracking_id": trackingID}}},
+	}}
+	count, err := websiteCollection.CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// UpdateTrackingID set the tracking id of website, or remove it when empty
+func (instance *repository) UpdateTrackingID(userID, websiteID, trackingID string, aWebsite *website) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": websiteID},
+		{"deleted_at": nil},
+	}}
+	update := bson.M{
+		"$set":   bson.M{"updated_at": time.Now().Format("2006-01-02, 15:04:05")},
+		"$unset": bson.M{"tracking_id": ""},
+	}
+	if trackingID != "" {
+		update = bson.M{"$set": bson.M{
+			"tracking_id": trackingID,
+			"updated_at":  time.Now().Format("2006-01-02, 15:04:05"),
+		}}
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := websiteCollection.FindOneAndUpdate(context.TODO(), filter, update, opts).Decode(aWebsite)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetTrackingWebsite website of trackingID
+func (instance *repository) GetTrackingWebsite(trackingID string, aWebsite *website) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"tracking_id": trackingID},
+		{"deleted_at": nil},
+	}}
+	opts := options.FindOne().SetProjection(bson.M{"id": 1})
+	err := websiteCollection.FindOne(context.TODO(), filter, opts).Decode(aWebsite)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateTrackingID give website a readable id the snippet and the collector
+// accept in place of its own, empty removes it. ErrTrackingIDTaken when it
+// is the id or tracking id of another website, deleted ones included until
+// they are purged, or another website takes it meanwhile
+func (instance *useCase) UpdateTrackingID(userID, websiteID, trackingID string) (*website, error) {
+	trackingID = strings.ToLower(strings.TrimSpace(trackingID))
+	if trackingID != "" {
+		if !trackingIDPattern.MatchString(trackingID) {
+			return nil, ErrInvalidTrackingID
+		}
+		count, err := instance.repo.FindTrackingID(websiteID, trackingID)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, ErrTrackingIDTaken
+		}
+	}
+	var aWebsite website
+	err := instance.repo.UpdateTrackingID(userID, websiteID, trackingID, &aWebsite)
+	if mongo.IsDuplicateKeyError(err) {
+		// another website took it since it was looked up
+		return nil, ErrTrackingIDTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &aWebsite, nil
+}
+
+// CanonicalID id of the website whose tracking id is websiteID, websiteID
+// itself when it is no tracking id
+func (instance *useCase) CanonicalID(websiteID string) (string, error) {
+	if !trackingIDPattern.MatchString(websiteID) {
+		return websiteID, nil
+	}
+	var aWebsite website
+	err := instance.repo.GetTrackingWebsite(websiteID, &aWebsite)
+	if err == mongo.ErrNoDocuments {
+		return websiteID, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return aWebsite.ID, nil
+}
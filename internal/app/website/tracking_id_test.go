@@ -0,0 +1,57 @@
+package website
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// fakeTrackingIDs finds taken tracking ids among taken, and fails writing
+// the tracking id with err
+type fakeTrackingIDs struct {
+	Repository
+	taken map[string]bool
+	err   error
+}
+
+func (instance *fakeTrackingIDs) FindTrackingID(websiteID, trackingID string) (int64, error) {
+	if instance.taken[trackingID] {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (instance *fakeTrackingIDs) UpdateTrackingID(userID, websiteID, trackingID string, aWebsite *website) error {
+	aWebsite.TrackingID = trackingID
+	return instance.err
+}
+
+func TestUpdateTrackingID(t *testing.T) {
+	duplicate := mongo.CommandError{Code: 11000, Message: "E11000 duplicate key error collection: website index: tracking_id_1"}
+	tests := []struct {
+		name       string
+		trackingID string
+		repo       *fakeTrackingIDs
+		want       string
+		wantErr    error
+	}{
+		{name: "should set a free tracking id", trackingID: " Acme-Shop ", repo: &fakeTrackingIDs{}, want: "acme-shop"},
+		{name: "should remove the tracking id when empty", trackingID: "", repo: &fakeTrackingIDs{}, want: ""},
+		{name: "should refuse a tracking id that is no slug", trackingID: "a", repo: &fakeTrackingIDs{}, wantErr: ErrInvalidTrackingID},
+		{name: "should refuse a tracking id taken", trackingID: "acme-shop", repo: &fakeTrackingIDs{taken: map[string]bool{"acme-shop": true}}, wantErr: ErrTrackingIDTaken},
+		{name: "should refuse a tracking id taken meanwhile", trackingID: "acme-shop", repo: &fakeTrackingIDs{err: duplicate}, wantErr: ErrTrackingIDTaken},
+		{name: "should return the other errors of the write", trackingID: "acme-shop", repo: &fakeTrackingIDs{err: errStep}, wantErr: errStep},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := &useCase{repo: tt.repo}
+			got, err := instance.UpdateTrackingID("u", "w", tt.trackingID)
+			if err != tt.wantErr {
+				t.Fatalf("UpdateTrackingID() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.TrackingID != tt.want {
+				t.Errorf("UpdateTrackingID() tracking id = %q, want %q", got.TrackingID, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package website
+
+import (
+	"context"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// TransferTTL time the recipient of a transfer has to accept it
+const TransferTTL = 7 * 24 * time.Hour
+
+// UpdateTransfer offer website to another account, nil withdraws the offer
+func (instance *repository) UpdateTransfer(userID, websiteID string, aTransfer *transfer) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"id": websiteID},
+		{"deleted_at": nil},
+	}}
+	update := bson.M{"$set": bson.M{
+		"transfer":   aTransfer,
+		"updated_at": time.Now().Format("2006-01-02, 15:04:05"),
+	}}
+	if aTransfer == nil {
+		update = bson.M{
+			"$unset": bson.M{"transfer": ""},
+			"$set":   bson.M{"updated_at": time.Now().Format("2006-01-02, 15:04:05")},
+		}
+	}
+	result, err := websiteCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// transferFilter websites offered to toUserID and not expired
+func transferFilter(toUserID string, and ...bson.M) bson.M {
+	return bson.M{"$and": append([]bson.M{
+		{"transfer.to_user_id": toUserID},
+		{"transfer.expires_at": bson.M{"$gt": time.Now()}},
+		{"deleted_at": nil},
+	}, and...)}
+}
+
+// ListTransfers websites offered to toUserID
+func (instance *repository) ListTransfers(toUserID string) ([]website, error) {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	cursor, err := websiteCollection.Find(context.TODO(), transferFilter(toUserID))
+	if err != nil {
+		return nil, err
+	}
+	var listWebsite []website
+	if err = cursor.All(context.TODO(), &listWebsite); err != nil {
+		return nil, err
+	}
+	return listWebsite, nil
+}
+
+// GetTransfer get website offered to toUserID
+func (instance *repository) GetTransfer(toUserID, websiteID string, aWebsite *website) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	err := websiteCollection.FindOne(context.TODO(), transferFilter(toUserID, bson.M{"id": websiteID})).Decode(aWebsite)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeclineTransfer drop the offer of website to toUserID
+func (instance *repository) DeclineTransfer(toUserID, websiteID string) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	update := bson.M{
+		"$unset": bson.M{"transfer": ""},
+		"$set":   bson.M{"updated_at": time.Now().Format("2006-01-02, 15:04:05")},
+	}
+	result, err := websiteCollection.UpdateOne(context.TODO(), transferFilter(toUserID, bson.M{"id": websiteID}), update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// MoveSession give the sessions of website to toUserID
+func (instance *repository) MoveSession(userID, toUserID, websiteID string) error {
+	sessionCollection := instance.store.Mongo.Collection(configs.MongoDB.SessionCollection)
+	filter := bson.M{"$and": []bson.M{
+		{"meta_data.user_id": userID},
+		{"meta_data.website_id": websiteID},
+	}}
+	result, err := sessionCollection.UpdateMany(context.TODO(), filter, bson.M{"$set": bson.M{"meta_data.user_id": toUserID}})
+	if err != nil {
+		return err
+	}
+	logrus.Printf("moved %v documents in the session collection\n", result.ModifiedCount)
+	return nil
+}
+
+// eventKey columns telling an event apart from the others of its website,
+// the user aside, so a copy can skip the events copied already
+const eventKey = "(id, event_id, timestamp, type, cityHash64(data, sealed))"
+
+// MoveEvents give the events of website to toUserID when ClickHouse stores
+// them. The user is part of the sorting key, so events are copied then the old
+// ones deleted, asynchronously in ClickHouse. Events toUserID already has are
+// not copied again, so a move failing after the copy, or run again while the
+// delete is pending, leaves no duplicate
+func (instance *repository) MoveEvents(userID, toUserID, websiteID string) error {
+	if !configs.UsesClickHouse() {
+		return nil
+	}
+	params := map[string]string{
+		"tenant":  instance.store.TenantID,
+		"user":    userID,
+		"to":      toUserID,
+		"website": websiteID,
+	}
+	where := " WHERE tenant_id = {tenant:String} AND user_id = {user:String} AND website_id = {website:String}"
+	copied := "SELECT " + eventKey + " FROM " + db.ClickHouseEventTable +
+		" WHERE tenant_id = {tenant:String} AND user_id = {to:String} AND website_id = {website:String}"
+	err := configs.ClickHouse.Client.Exec("INSERT INTO "+db.ClickHouseEventTable+
+		" SELECT * REPLACE ({to:String} AS user_id) FROM "+db.ClickHouseEventTable+where+
+		" AND "+eventKey+" NOT IN ("+copied+")", params)
+	if err != nil {
+		return err
+	}
+	return configs.ClickHouse.Client.Exec("ALTER TABLE "+db.ClickHouseEventTable+" DELETE"+where, params)
+}
+
+// MoveData give the goals, calculated metrics, visitors, counters, usage,
+// reconciliations and archive manifests of website to toUserID
+func (instance *repository) MoveData(userID, toUserID, websiteID string) error {
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+	}}
+	names := []string{
+		configs.MongoDB.GoalCollection,
+		configs.MongoDB.MetricCollection,
+		configs.MongoDB.VisitorCollection,
+		configs.MongoDB.AggregateCollection,
+		configs.MongoDB.UsageCollection,
+		configs.MongoDB.ReconciliationCollection,
+		configs.MongoDB.ArchiveCollection,
+	}
+	for _, name := range names {
+		result, err := instance.store.Mongo.Collection(name).UpdateMany(context.TODO(), filter, bson.M{"$set": bson.M{"user_id": toUserID}})
+		if err != nil {
+			return err
+		}
+		logrus.Printf("moved %v documents in the %s collection\n", result.ModifiedCount, name)
+	}
+	return nil
+}
+
+// DeleteIntegrations remove the ad platform integrations of website and
+// their delivery logs
+func (instance *repository) DeleteIntegrations(userID, websiteID string) error {
+	filter := bson.M{"$and": []bson.M{
+		{"user_id": userID},
+		{"website_id": websiteID},
+	}}
+	for _, name := range []string{configs.MongoDB.IntegrationCollection, configs.MongoDB.DeliveryLogCollection} {
+		deleteResult, err := instance.store.Mongo.Collection(name).DeleteMany(context.TODO(), filter)
+		if err != nil {
+			return err
+		}
+		logrus.Printf("deleted %v documents in the %s collection\n", deleteResult.DeletedCount, name)
+	}
+	return nil
+}
+
+// MoveWebsite give website offered to toUserID to it, along with the settings
+// that are not tied to the accounts of its owner
+func (instance *repository) MoveWebsite(userID, toUserID, websiteID string) error {
+	websiteCollection := instance.store.Mongo.Collection(configs.MongoDB.WebsiteCollection)
+	filter := transferFilter(toUserID, bson.M{"user_id": userID}, bson.M{"id": websiteID})
+	update := bson.M{
+		"$set": bson.M{
+			"user_id":    toUserID,
+			"updated_at": time.Now().Format("2006-01-02, 15:04:05"),
+		},
+		"$addToSet": bson.M{"previous_user_ids": userID},
+		"$unset":    bson.M{"transfer": "", "share": "", "visitor_webhook": "", "server_keys": ""},
+	}
+	result, err := websiteCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// RequestTransfer offer website to toUserID for TransferTTL, replacing the
+// offer pending if any
+func (instance *useCase) RequestTransfer(userID, websiteID, toUserID, email string) (*transfer, error) {
+	if toUserID == userID {
+		return nil, ErrTransferToSelf
+	}
+	aTransfer := &transfer{ToUserID: toUserID, Email: email, ExpiresAt: time.Now().Add(TransferTTL).UTC()}
+	err := instance.repo.UpdateTransfer(userID, websiteID, aTransfer)
+	if err != nil {
+		return nil, err
+	}
+	return aTransfer, nil
+}
+
+// CancelTransfer withdraw the offer of website
+func (instance *useCase) CancelTransfer(userID, websiteID string) error {
+	return instance.repo.UpdateTransfer(userID, websiteID, nil)
+}
+
+// ListTransfers websites offered to user, with the id of their owner
+func (instance *useCase) ListTransfers(userID string) ([]website, error) {
+	return instance.repo.ListTransfers(userID)
+}
+
+// DeclineTransfer refuse website offered to user
+func (instance *useCase) DeclineTransfer(userID, websiteID string) error {
+	return instance.repo.DeclineTransfer(userID, websiteID)
+}
+
+// AcceptTransfer take over website offered to user with all its data.
+// mongo.ErrNoDocuments when no offer is pending, ErrWebsiteExists when user
+// already has the website and ErrDeletionPending while a website of user with
+// the same id is purged. The integrations, CRM mapping, visitor webhook and
+// public share point at the accounts of the former owner and are removed
+func (instance *useCase) AcceptTransfer(userID, websiteID string) (*website, error) {
+	var aWebsite website
+	err := instance.repo.GetTransfer(userID, websiteID, &aWebsite)
+	if err != nil {
+		return nil, err
+	}
+	taken, err := instance.hostTaken(userID, websiteID, aWebsite.HostName)
+	if err != nil {
+		return nil, err
+	}
+	// websites added before their ids were drawn share the id of their host
+	count, err := instance.repo.FindWebsiteByID(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	if taken || count > 0 {
+		return nil, ErrWebsiteExists
+	}
+	pending, err := instance.repo.HasDeletion(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	if pending {
+		return nil, ErrDeletionPending
+	}
+
+	// every step moves all that matches and the website goes last, so an
+	// accept failing half way is safe to run again
+	fromUserID := aWebsite.UserID
+	steps := []func() error{
+		func() error { return instance.repo.MoveSession(fromUserID, userID, websiteID) },
+		func() error { return instance.repo.MoveEvents(fromUserID, userID, websiteID) },
+		func() error { return instance.repo.MoveData(fromUserID, userID, websiteID) },
+		func() error { return instance.repo.DeleteIntegrations(fromUserID, websiteID) },
+		func() error { return instance.repo.DeleteCRMMapping(fromUserID, websiteID) },
+		func() error { return instance.repo.MoveWebsite(fromUserID, userID, websiteID) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return nil, err
+		}
+	}
+	err = instance.repo.GetWebsite(userID, websiteID, &aWebsite)
+	if err != nil {
+		return nil, err
+	}
+	return &aWebsite, nil
+}
@@ -0,0 +1,95 @@
+package website
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/clickhouse"
+)
+
+// fakeEventTable keeps the events of a website by user in place of
+// ClickHouse, skipping on copy the events the recipient has when the copy
+// asks to, and failing the deletes while failDelete is set
+type fakeEventTable struct {
+	events     map[string][]string
+	failDelete bool
+}
+
+func (instance *fakeEventTable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	query := string(body)
+	params := r.URL.Query()
+	from, to := params.Get("param_user"), params.Get("param_to")
+	switch {
+	case strings.HasPrefix(query, "INSERT INTO "+db.ClickHouseEventTable+" SELECT"):
+		has := map[string]bool{}
+		for _, key := range instance.events[to] {
+			has[key] = true
+		}
+		for _, key := range instance.events[from] {
+			if strings.Contains(query, eventKey+" NOT IN (") && has[key] {
+				continue
+			}
+			instance.events[to] = append(instance.events[to], key)
+		}
+	case strings.HasPrefix(query, "ALTER TABLE "+db.ClickHouseEventTable+" DELETE"):
+		if instance.failDelete {
+			http.Error(w, "too many parts", http.StatusServiceUnavailable)
+			return
+		}
+		delete(instance.events, from)
+	default:
+		http.Error(w, "unexpected statement "+query, http.StatusBadRequest)
+	}
+}
+
+func TestMoveEvents(t *testing.T) {
+	tests := []struct {
+		name string
+		// failFirst delete of the first move fails, the move is run again
+		failFirst bool
+		want      []string
+	}{
+		{name: "should copy the events then delete them", want: []string{"a", "b"}},
+		{name: "should not copy the events twice when run again after a failed delete", failFirst: true, want: []string{"a", "b"}},
+	}
+
+	primary := configs.Storage.Primary
+	client := configs.ClickHouse.Client
+	defer func() {
+		configs.Storage.Primary = primary
+		configs.ClickHouse.Client = client
+	}()
+	configs.Storage.Primary = "clickhouse"
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &fakeEventTable{events: map[string][]string{"from": {"a", "b"}}, failDelete: tt.failFirst}
+			server := httptest.NewServer(table)
+			defer server.Close()
+			configs.ClickHouse.Client = clickhouse.New(server.URL, "analytics", "", "")
+
+			repo := NewRepository(&db.Store{TenantID: "acme"})
+			if tt.failFirst {
+				if err := repo.MoveEvents("from", "to", "w1"); err == nil {
+					t.Fatal("MoveEvents() error = nil, want the failed delete")
+				}
+				table.failDelete = false
+			}
+			if err := repo.MoveEvents("from", "to", "w1"); err != nil {
+				t.Fatalf("MoveEvents() error = %v", err)
+			}
+			if got := strings.Join(table.events["to"], ","); got != strings.Join(tt.want, ",") {
+				t.Errorf("events of the recipient = %s, want %s", got, strings.Join(tt.want, ","))
+			}
+			if len(table.events["from"]) > 0 {
+				t.Errorf("events of the former owner = %v, want none", table.events["from"])
+			}
+		})
+	}
+}
@@ -1,24 +1,185 @@
 package website
 
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/db"
+	"analytics-api/internal/pkg/confirm"
+	"analytics-api/internal/pkg/etag"
+	"analytics-api/internal/pkg/i18n"
+	"analytics-api/internal/pkg/ipallow"
+	"analytics-api/internal/pkg/pathgroup"
+	"analytics-api/internal/pkg/spam"
+	str "analytics-api/internal/pkg/string"
+	"analytics-api/internal/pkg/verify"
+	"analytics-api/internal/pkg/webhook"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ErrInvalidTimezone ...
+var ErrInvalidTimezone = errors.New("timezone must be an IANA name like Asia/Ho_Chi_Minh")
+
+// ErrUnknownPreset ...
+var ErrUnknownPreset = errors.New("preset must be vi or en")
+
+// ErrTooManyContentGroups ...
+var ErrTooManyContentGroups = errors.New("a website has at most 50 content groups")
+
+// ErrDeletionPending ...
+var ErrDeletionPending = errors.New("this website was deleted, restore it or add it again once its data is purged")
+
+// ErrRestoreExpired ...
+var ErrRestoreExpired = errors.New("the restore window of this website is over")
+
+// ErrWebsiteExists ...
+var ErrWebsiteExists = errors.New("this website already exists")
+
+// DefaultShareMinCount sessions a value of a private share needs to be shown
+const DefaultShareMinCount = 5
+
+// MaxAliases host names a website can be served from besides its own
+const MaxAliases = 20
+
+// ErrInvalidAlias ...
+var ErrInvalidAlias = errors.New("an alias must be another host name than the one of the website")
+
+// ErrWebsiteQuota ...
+var ErrWebsiteQuota = errors.New("the plan of this account allows no more websites")
+
+// ErrTransferToSelf ...
+var ErrTransferToSelf = errors.New("a website cannot be transferred to its owner")
+
+// ErrInvalidExcludedIPs ...
+var ErrInvalidExcludedIPs = errors.New("excluded_ips must be IP addresses or CIDRs")
+
+// ErrInvalidCountries ...
+var ErrInvalidCountries = errors.New("allowed_countries and excluded_countries must be ISO 3166-1 alpha-2 codes like VN, and only one of them set")
+
+// ErrNotVerified ...
+var ErrNotVerified = errors.New("verify the ownership of this website before tracking it")
+
+// ErrArchived ...
+var ErrArchived = errors.New("this website is archived, its reports stay readable but it no longer collects events")
+
+// ErrVerificationFailed ...
+var ErrVerificationFailed = errors.New("neither a TXT record nor a meta tag holds the verification token")
+
+// ErrInvalidSpamDomains ...
+var ErrInvalidSpamDomains = errors.New("spam_allowed and spam_blocked must be domains")
+
+// Codes of the error responses of websites
+const (
+	CodeWebsiteNotFound   = "website_not_found"
+	CodeWebsiteExists     = "website_exists"
+	CodeWebsiteNotDeleted = "website_not_deleted"
+	CodeRestoreExpired    = "restore_expired"
+	// CodeWebsiteQuota details hold the plan of the account and its limit
+	CodeWebsiteQuota = "website_quota_exceeded"
+	// CodeConfirmationRequired details hold the token confirming a deletion
+	// and what it deletes
+	CodeConfirmationRequired = "confirmation_required"
+	CodeNotVerified          = "website_not_verified"
+	CodeVerificationFailed   = "verification_failed"
+	CodeTransferNotFound     = "transfer_not_found"
+	CodeAccountNotFound      = "account_not_found"
+	CodeArchived             = "website_archived"
+)
+
+// MaxContentGroups every page of a report is matched against all rules
+const MaxContentGroups = 50
+
 // UseCase ...
 type UseCase interface {
 	FindWebsite(userID, hostName string) (int64, error)
 	FindWebsiteByID(userID, websiteID string) (int64, error)
+	CheckQuota(userID string, limit int64) error
 	InsertWebsite(userID string, aWebsite website) error
+	AddWebsite(userID string, limit int64, name, url, category, timezone string, aPreset Format, aggregateOnly bool) (*website, error)
+	ImportWebsite(userID string, limit int64, rows []ImportRow) []ImportResult
 	GetWebsite(userID, websiteID string, aWebsite *website) error
 	GetAllWebsite(userID string) (*websites, error)
+	ListWebsite() (*websites, error)
 	DeleteWebsite(userID, websiteID string) error
-	DeleteSession(userID, websiteID string) error
+	RestoreWebsite(userID, websiteID string) (*website, error)
+	PurgeDeleted() (int, int, error)
+	HasDeletion(userID, websiteID string) (bool, error)
+	RetryFailedDeletions() (int64, error)
+	PrepareDelete(userID, websiteID string) (*deleteImpact, string, error)
+	ConfirmDelete(userID, websiteID, token string) error
+	UpdateFeatures(userID, websiteID string, aFeatures *features) error
+	GetFeatures(websiteID string) (*features, error)
+	UpdateTimezone(userID, websiteID, timezone string) error
+	UpdateWebsite(userID, websiteID string, name, url, category *string, tags *[]string, version *int64) (*website, error)
+	GetLocation(userID, websiteID string) (*time.Location, error)
+	GetFormat(userID, websiteID string) (*Format, error)
+	GetURL(userID, websiteID string) (string, error)
+	ProvisionInternal(userID string) (string, error)
+	HasWebsite(userID, websiteID string) (bool, error)
+	UpdateContentGroups(userID, websiteID string, rules []pathgroup.Rule) error
+	GetContentGroups(userID, websiteID string) ([]pathgroup.Rule, error)
+	UpdateVisitorWebhook(userID, websiteID, url string) (string, error)
+	GetVisitorWebhook(userID, websiteID string) (string, string, error)
+	RotateSegmentWriteKey(userID, websiteID string) (string, error)
+	FindSegmentWriteKey(writeKey string) (string, string, error)
+	UpdateSettings(userID, websiteID string, aSettings Settings, version *int64) (*Settings, int64, error)
+	UpdateAliases(userID, websiteID string, aliases []string) ([]string, error)
+	ResolveWebsite(userID, websiteID, hostName string) (string, string, error)
+	GetSettings(websiteID string) (*Settings, error)
+	CheckInstalls(notifier Notifier) (int, error)
+	UpdateShare(userID, websiteID string, enabled, private bool, minCount int64) (*Share, error)
+	FindShareToken(token string) (string, string, *Share, error)
+	UpdateAggregateOnly(userID, websiteID string, enabled bool) error
+	UpdateArchived(userID, websiteID string, archived bool) (*website, error)
+	GetAggregateOnly(websiteID string) (bool, error)
+	MarkTracked(websiteID string) error
+	MarkOnboarding(websiteID, step string)
+	GetOnboarding(userID, websiteID string) (*onboardingState, error)
+	GetActivation() (*activation, error)
+	GetTaggedWebsite(userID string, tags ...string) (*websites, error)
+	GetScopedWebsite(userID string, scope []string, tags ...string) (*websites, error)
+	GetTags(userID string, scope []string) ([]tagCount, error)
+	AttachStats(userID string, websites *websites) error
+	PlaceLegalHold(websiteID, reason string) (*website, error)
+	ReleaseLegalHold(websiteID string) (*website, error)
+	GetHeldWebsite() (*websites, error)
+	HeldWebsiteIDs() ([]string, error)
+	UpdateTrackingID(userID, websiteID, trackingID string) (*website, error)
+	CanonicalID(websiteID string) (string, error)
+	GetServerKeys(userID, websiteID string) ([]ServerKey, error)
+	CreateServerKey(userID, websiteID, name string) (*ServerKey, error)
+	RotateServerKey(userID, websiteID, keyID string) (*ServerKey, error)
+	RevokeServerKey(userID, websiteID, keyID string) error
+	FindServerKey(secret string) (string, string, error)
+	AddTag(userID, tag string, websiteIDs []string) (int64, error)
+	RenameTag(userID, tag, name string) (int64, error)
+	DeleteTag(userID, tag string) (int64, error)
+	VerifyWebsite(userID, websiteID, method string) (*website, error)
+	Accepts(userID, websiteID string) error
+	RequestTransfer(userID, websiteID, toUserID, email string) (*transfer, error)
+	CancelTransfer(userID, websiteID string) error
+	ListTransfers(userID string) ([]website, error)
+	AcceptTransfer(userID, websiteID string) (*website, error)
+	DeclineTransfer(userID, websiteID string) error
 }
 
 type useCase struct {
-	repo Repository
+	repo  Repository
+	store *db.Store
 }
 
 // NewUseCase ...
-func NewUseCase() UseCase {
+func NewUseCase(store *db.Store) UseCase {
 	return &useCase{
-		repo: NewRepository(),
+		repo:  NewRepository(store),
+		store: store,
 	}
 }
 
@@ -30,6 +191,22 @@ func (instance *useCase) FindWebsite(userID, url string) (int64, error) {
 	return count, nil
 }
 
+// hostTaken whether a website other than websiteID is served from hostName,
+// among the websites of user or, with GlobalHostNames, of every account
+func (instance *useCase) hostTaken(userID, websiteID, hostName string) (bool, error) {
+	var count int64
+	var err error
+	if configs.GlobalHostNames {
+		count, err = instance.repo.FindHostName(websiteID, hostName)
+	} else {
+		count, err = instance.repo.FindOtherWebsite(userID, websiteID, hostName)
+	}
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 func (instance *useCase) FindWebsiteByID(userID, websiteID string) (int64, error) {
 	count, err := instance.repo.FindWebsiteByID(userID, websiteID)
 	if err != nil {
@@ -38,6 +215,22 @@ func (instance *useCase) FindWebsiteByID(userID, websiteID string) (int64, error
 	return count, nil
 }
 
+// CheckQuota ErrWebsiteQuota when user has limit websites already, 0 is no
+// limit. The internal website does not count
+func (instance *useCase) CheckQuota(userID string, limit int64) error {
+	if limit == 0 {
+		return nil
+	}
+	count, err := instance.repo.CountWebsite(userID)
+	if err != nil {
+		return err
+	}
+	if count >= limit {
+		return ErrWebsiteQuota
+	}
+	return nil
+}
+
 func (instance *useCase) InsertWebsite(userID string, aWebsite website) error {
 	err := instance.repo.InsertWebsite(userID, aWebsite)
 	if err != nil {
@@ -46,6 +239,71 @@ func (instance *useCase) InsertWebsite(userID string, aWebsite website) error {
 	return nil
 }
 
+// AddWebsite add the website of url, stored normalized, to user with the
+// formats of aPreset, its timezone replaced by timezone when set. Every
+// website gets an id of its own, so adding a host again never mixes in the
+// data of a website deleted or of another account. str.ErrInvalidURL when
+// url is not the one of a website, ErrWebsiteExists when the host is taken,
+// see hostTaken, then ErrWebsiteQuota when user has limit websites.
+// ErrInvalidTimezone before all when timezone is not an IANA name
+func (instance *useCase) AddWebsite(userID string, limit int64, name, url, category, timezone string, aPreset Format, aggregateOnly bool) (*website, error) {
+	if timezone == "" {
+		timezone = aPreset.Timezone
+	}
+	if !validTimezone(timezone) {
+		return nil, ErrInvalidTimezone
+	}
+	url, err := str.NormalizeURL(url)
+	if err != nil {
+		return nil, err
+	}
+	hostName, err := str.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	taken, err := instance.hostTaken(userID, "", hostName)
+	if err != nil {
+		return nil, err
+	}
+	if taken || hostName == InternalHostName {
+		return nil, ErrWebsiteExists
+	}
+	err = instance.CheckQuota(userID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	verificationToken, err := verify.NewToken()
+	if err != nil {
+		return nil, err
+	}
+	createdAt := time.Now().Format("2006-01-02, 15:04:05")
+	aWebsite := website{
+		ID:            uuid.New().String(),
+		UserID:        userID,
+		Name:          strings.TrimSpace(name),
+		Category:      category,
+		HostName:      hostName,
+		URL:           url,
+		Features:      defaultFeatures(),
+		Timezone:      timezone,
+		Locale:        aPreset.Locale,
+		DateFormat:    aPreset.DateFormat,
+		Currency:      aPreset.Currency,
+		CreatedAt:     createdAt,
+		UpdatedAt:     createdAt,
+		AggregateOnly: aggregateOnly,
+		// tracked once the ownership of the host is verified
+		VerificationToken: verificationToken,
+		InstallNotice:     &installNotice{},
+	}
+	err = instance.repo.InsertWebsite(userID, aWebsite)
+	if err != nil {
+		return nil, err
+	}
+	return &aWebsite, nil
+}
+
 func (instance *useCase) GetWebsite(userID, websiteID string, aWebsite *website) error {
 	err := instance.repo.GetWebsite(userID, websiteID, aWebsite)
 	if err != nil {
@@ -62,18 +320,741 @@ func (instance *useCase) GetAllWebsite(userID string) (*websites, error) {
 	return websites, nil
 }
 
+func (instance *useCase) ListWebsite() (*websites, error) {
+	websites, err := instance.repo.ListWebsite()
+	if err != nil {
+		return nil, err
+	}
+	return websites, nil
+}
+
+// DeleteWebsite hide website now and queue the deletion of its data, done by
+// PurgeDeleted once RestoreWindow is over. The website is hidden first so no
+// live website ever has a deletion queued, and shown again when queueing
+// fails. ErrLegalHold while the website is held
 func (instance *useCase) DeleteWebsite(userID, websiteID string) error {
-	err := instance.repo.DeleteWebsite(userID, websiteID)
+	var aWebsite website
+	err := instance.repo.GetWebsite(userID, websiteID, &aWebsite)
+	if err != nil {
+		return err
+	}
+	if aWebsite.LegalHold != nil {
+		return ErrLegalHold
+	}
+	err = instance.repo.DeleteWebsite(userID, websiteID)
 	if err != nil {
 		return err
 	}
+	err = instance.repo.QueueDeletion(userID, websiteID, time.Now().Add(RestoreWindow))
+	if err != nil {
+		if restoreErr := instance.repo.RestoreWebsite(userID, websiteID); restoreErr != nil {
+			logrus.Error("show website id ", websiteID, " again after its deletion failed error ", restoreErr)
+		}
+		return err
+	}
 	return nil
 }
 
-func (instance *useCase) DeleteSession(userID, websiteID string) error {
-	err := instance.repo.DeleteSession(userID, websiteID)
+// RestoreWebsite bring back website deleted within RestoreWindow with all its
+// data. mongo.ErrNoDocuments when it is not deleted or already purged,
+// ErrRestoreExpired once the purge is due and ErrWebsiteExists when another
+// website of the user took its host name meanwhile
+func (instance *useCase) RestoreWebsite(userID, websiteID string) (*website, error) {
+	var aWebsite website
+	err := instance.repo.GetDeletedWebsite(userID, websiteID, &aWebsite)
+	if err != nil {
+		return nil, err
+	}
+	// a website restored half way has no deletion left and is restored again
+	var aDeletion deletion
+	err = instance.repo.GetDeletion(userID, websiteID, &aDeletion)
+	switch err {
+	case nil:
+		if !aDeletion.PurgeAfter.After(time.Now()) {
+			return nil, ErrRestoreExpired
+		}
+	case mongo.ErrNoDocuments:
+	default:
+		return nil, err
+	}
+	taken, err := instance.hostTaken(userID, websiteID, aWebsite.HostName)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, ErrWebsiteExists
+	}
+
+	// the deletion goes first so the purge cannot catch a restored website
+	err = instance.repo.RemoveDeletion(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	err = instance.repo.RestoreWebsite(userID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	aWebsite.DeletedAt = ""
+	return &aWebsite, nil
+}
+
+// HasDeletion report whether the data of a deleted website is still being
+// removed
+func (instance *useCase) HasDeletion(userID, websiteID string) (bool, error) {
+	return instance.repo.HasDeletion(userID, websiteID)
+}
+
+// PrepareDelete what deleting website would remove, with the token
+// confirming the delete
+func (instance *useCase) PrepareDelete(userID, websiteID string) (*deleteImpact, string, error) {
+	count, err := instance.repo.FindWebsiteByID(userID, websiteID)
+	if err != nil {
+		return nil, "", err
+	}
+	if count == 0 {
+		return nil, "", mongo.ErrNoDocuments
+	}
+	aImpact, err := instance.repo.CountDeleteImpact(userID, websiteID)
+	if err != nil {
+		return nil, "", err
+	}
+	token, err := confirm.Issue(instance.store.Key, userID, "delete_website", websiteID)
+	if err != nil {
+		return nil, "", err
+	}
+	return aImpact, token, nil
+}
+
+// ConfirmDelete use up the token of PrepareDelete, confirm.ErrInvalid when it
+// was not issued for this website or has expired
+func (instance *useCase) ConfirmDelete(userID, websiteID, token string) error {
+	return confirm.Consume(instance.store.Key, userID, "delete_website", websiteID, token)
+}
+
+func (instance *useCase) UpdateFeatures(userID, websiteID string, aFeatures *features) error {
+	err := instance.repo.UpdateFeatures(userID, websiteID, aFeatures)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (instance *useCase) GetFeatures(websiteID string) (*features, error) {
+	aFeatures, err := instance.repo.GetFeatures(websiteID)
+	if err != nil {
+		return nil, err
+	}
+	return aFeatures, nil
+}
+
+// UpdateTimezone validate IANA name before storing
+func (instance *useCase) UpdateTimezone(userID, websiteID, timezone string) error {
+	if !validTimezone(timezone) {
+		return ErrInvalidTimezone
+	}
+	err := instance.repo.UpdateTimezone(userID, websiteID, timezone)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// UpdateAliases replace the host names website is served from besides its
+// own, ErrWebsiteExists when another website of the user has one of them
+func (instance *useCase) UpdateAliases(userID, websiteID string, aliases []string) ([]string, error) {
+	var aWebsite website
+	err := instance.repo.GetWebsite(userID, websiteID, &aWebsite)
+	if err != nil {
+		return nil, err
+	}
+
+	listAlias := []string{}
+	aliasIDs := []string{}
+	for _, alias := range aliases {
+		alias = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(alias)), ".")
+		if alias == aWebsite.HostName || alias == InternalHostName {
+			return nil, ErrInvalidAlias
+		}
+		if slices.Contains(listAlias, alias) {
+			continue
+		}
+		taken, err := instance.hostTaken(userID, websiteID, alias)
+		if err != nil {
+			return nil, err
+		}
+		if taken {
+			return nil, ErrWebsiteExists
+		}
+		listAlias = append(listAlias, alias)
+		aliasIDs = append(aliasIDs, str.GetMD5Hash(alias))
+	}
+
+	err = instance.repo.UpdateAliases(userID, websiteID, listAlias, aliasIDs)
+	if err != nil {
+		return nil, err
+	}
+	return listAlias, nil
+}
+
+// ResolveWebsite owner and canonical id of the website of user a batch sent
+// from hostName with websiteID, or its tracking id, belongs to, the new owner
+// when user transferred it away. userID and websiteID themselves when none
+// matches
+func (instance *useCase) ResolveWebsite(userID, websiteID, hostName string) (string, string, error) {
+	var aWebsite website
+	err := instance.repo.ResolveWebsite(userID, websiteID, strings.ToLower(hostName), &aWebsite)
+	if err == mongo.ErrNoDocuments {
+		return userID, websiteID, nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return aWebsite.UserID, aWebsite.ID, nil
+}
+
+// validTimezone whether timezone is an IANA name, Local would follow the
+// server
+func validTimezone(timezone string) bool {
+	_, err := time.LoadLocation(timezone)
+	return err == nil && timezone != "" && timezone != "Local"
+}
+
+// UpdateSettings replace the settings of website, excluded IPs are kept as
+// CIDRs, spam domains lowercased, country codes uppercased and the timezone
+// stays UTC when empty. Returns the new version of
+// the website, etag.ErrConflict when version is given and no longer current
+func (instance *useCase) UpdateSettings(userID, websiteID string, aSettings Settings, version *int64) (*Settings, int64, error) {
+	if aSettings.Timezone == "" {
+		aSettings.Timezone = "UTC"
+	}
+	if !validTimezone(aSettings.Timezone) {
+		return nil, 0, ErrInvalidTimezone
+	}
+	nets, err := ipallow.Parse(aSettings.ExcludedIPs)
+	if err != nil {
+		return nil, 0, ErrInvalidExcludedIPs
+	}
+	aSettings.ExcludedIPs = []string{}
+	for _, ipNet := range nets {
+		aSettings.ExcludedIPs = append(aSettings.ExcludedIPs, ipNet.String())
+	}
+	aSettings.SpamAllowed, err = normalizeDomains(aSettings.SpamAllowed)
+	if err != nil {
+		return nil, 0, ErrInvalidSpamDomains
+	}
+	aSettings.SpamBlocked, err = normalizeDomains(aSettings.SpamBlocked)
+	if err != nil {
+		return nil, 0, ErrInvalidSpamDomains
+	}
+	if len(aSettings.AllowedCountries) > 0 && len(aSettings.ExcludedCountries) > 0 {
+		return nil, 0, ErrInvalidCountries
+	}
+	aSettings.AllowedCountries, err = normalizeCountries(aSettings.AllowedCountries)
+	if err != nil {
+		return nil, 0, err
+	}
+	aSettings.ExcludedCountries, err = normalizeCountries(aSettings.ExcludedCountries)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	updated, err := instance.repo.UpdateSettings(userID, websiteID, &aSettings, version)
+	if err == mongo.ErrNoDocuments && version != nil {
+		// the website may exist at another version
+		count, err := instance.repo.FindWebsiteByID(userID, websiteID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if count > 0 {
+			return nil, 0, etag.ErrConflict
+		}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return &aSettings, updated, nil
+}
+
+// normalizeDomains domains as spam.Normalize keeps them, without duplicates
+func normalizeDomains(domains []string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, domain := range domains {
+		domain, err := spam.Normalize(domain)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[domain] {
+			seen[domain] = true
+			normalized = append(normalized, domain)
+		}
+	}
+	return normalized, nil
+}
+
+// normalizeCountries uppercased country codes without duplicates,
+// ErrInvalidCountries when one is not two letters
+func normalizeCountries(countries []string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, country := range countries {
+		country = strings.ToUpper(strings.TrimSpace(country))
+		if len(country) != 2 || country[0] < 'A' || country[0] > 'Z' || country[1] < 'A' || country[1] > 'Z' {
+			return nil, ErrInvalidCountries
+		}
+		if !seen[country] {
+			seen[country] = true
+			normalized = append(normalized, country)
+		}
+	}
+	return normalized, nil
+}
+
+// GetSettings settings of website, read for every batch
+func (instance *useCase) GetSettings(websiteID string) (*Settings, error) {
+	return instance.repo.GetSettings(websiteID)
+}
+
+// UpdateWebsite change name, url, category and tags of website, left
+// unchanged when nil. The host name follows the url, the id stays so the data
+// of the website is kept. etag.ErrConflict when version is given and no
+// longer current, or the website changes while it is updated
+func (instance *useCase) UpdateWebsite(userID, websiteID string, name, url, category *string, tags *[]string, version *int64) (*website, error) {
+	var aWebsite website
+	err := instance.repo.GetWebsite(userID, websiteID, &aWebsite)
+	if err != nil {
+		return nil, err
+	}
+	if version != nil && *version != aWebsite.Version {
+		return nil, etag.ErrConflict
+	}
+	fields := bson.M{}
+	if name != nil {
+		fields["name"] = strings.TrimSpace(*name)
+	}
+	if category != nil {
+		fields["category"] = strings.TrimSpace(*category)
+	}
+	if tags != nil {
+		fields["tags"] = NormalizeTags(*tags)
+	}
+	if url != nil {
+		normalized, err := str.NormalizeURL(*url)
+		if err != nil {
+			return nil, err
+		}
+		url = &normalized
+		hostName, err := str.ParseURL(*url)
+		if err != nil {
+			return nil, err
+		}
+		if hostName != aWebsite.HostName {
+			if hostName == InternalHostName || aWebsite.HostName == InternalHostName {
+				return nil, ErrWebsiteExists
+			}
+			taken, err := instance.hostTaken(userID, websiteID, hostName)
+			if err != nil {
+				return nil, err
+			}
+			if taken {
+				return nil, ErrWebsiteExists
+			}
+		}
+		fields["url"] = *url
+		fields["host_name"] = hostName
+		if hostName != aWebsite.HostName {
+			// the ownership of the new host is verified again
+			token := aWebsite.VerificationToken
+			if token == "" {
+				token, err = verify.NewToken()
+				if err != nil {
+					return nil, err
+				}
+			}
+			fields["verification_token"] = token
+			fields["verified_at"] = ""
+		}
+	}
+	if len(fields) == 0 {
+		return &aWebsite, nil
+	}
+
+	// the website is only written at the version read, so a change made in
+	// between is not overwritten
+	read := aWebsite.Version
+	err = instance.repo.UpdateWebsite(userID, websiteID, fields, &read, &aWebsite)
+	if err == mongo.ErrNoDocuments {
+		return nil, etag.ErrConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &aWebsite, nil
+}
+
+// NormalizeTags tags lower cased and trimmed, without duplicates or empty
+// ones, in their order
+func NormalizeTags(tags []string) []string {
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// GetLocation timezone of website, UTC when not set
+func (instance *useCase) GetLocation(userID, websiteID string) (*time.Location, error) {
+	var aWebsite website
+	err := instance.repo.GetWebsite(userID, websiteID, &aWebsite)
+	if err != nil {
+		return nil, err
+	}
+	if aWebsite.Timezone == "" {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(aWebsite.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+// GetFormat how reports of website are shown, websites added before presets
+// keep UTC and get the english formats
+func (instance *useCase) GetFormat(userID, websiteID string) (*Format, error) {
+	var aWebsite website
+	err := instance.repo.GetWebsite(userID, websiteID, &aWebsite)
+	if err != nil {
+		return nil, err
+	}
+	aFormat := Presets[i18n.English]
+	if preset, ok := Presets[aWebsite.Locale]; ok {
+		aFormat = preset
+	}
+	aFormat.Timezone = "UTC"
+	if aWebsite.Timezone != "" {
+		aFormat.Timezone = aWebsite.Timezone
+	}
+	if aWebsite.DateFormat != "" {
+		aFormat.DateFormat = aWebsite.DateFormat
+	}
+	if aWebsite.Currency != "" {
+		aFormat.Currency = aWebsite.Currency
+	}
+	return &aFormat, nil
+}
+
+// UpdateContentGroups validate rules before storing, in the order given
+func (instance *useCase) UpdateContentGroups(userID, websiteID string, rules []pathgroup.Rule) error {
+	if len(rules) > MaxContentGroups {
+		return ErrTooManyContentGroups
+	}
+	contentGroups := []contentGroup{}
+	for _, rule := range rules {
+		if err := pathgroup.Validate(rule); err != nil {
+			return err
+		}
+		contentGroups = append(contentGroups, contentGroup{Name: rule.Name, Pattern: rule.Pattern})
+	}
+	err := instance.repo.UpdateContentGroups(userID, websiteID, contentGroups)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetContentGroups content group rules of website
+func (instance *useCase) GetContentGroups(userID, websiteID string) ([]pathgroup.Rule, error) {
+	var aWebsite website
+	err := instance.repo.GetWebsite(userID, websiteID, &aWebsite)
+	if err != nil {
+		return nil, err
+	}
+	rules := []pathgroup.Rule{}
+	for _, aContentGroup := range aWebsite.ContentGroups {
+		rules = append(rules, pathgroup.Rule{Name: aContentGroup.Name, Pattern: aContentGroup.Pattern})
+	}
+	return rules, nil
+}
+
+// HasWebsite report whether user owns website
+func (instance *useCase) HasWebsite(userID, websiteID string) (bool, error) {
+	var aWebsite website
+	err := instance.repo.GetWebsite(userID, websiteID, &aWebsite)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UpdateVisitorWebhook set the endpoint told of identified visitors with a new
+// signing secret, which is returned. An empty url removes the endpoint
+func (instance *useCase) UpdateVisitorWebhook(userID, websiteID, url string) (string, error) {
+	if url == "" {
+		err := instance.repo.UpdateVisitorWebhook(userID, websiteID, nil)
+		if err != nil {
+			return "", err
+		}
+		return "", nil
+	}
+	if err := webhook.ValidateURL(url); err != nil {
+		return "", err
+	}
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return "", err
+	}
+	err = instance.repo.UpdateVisitorWebhook(userID, websiteID, &visitorWebhook{URL: url, Secret: secret})
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// GetVisitorWebhook url and secret of the endpoint told of identified
+// visitors, empty when not set
+func (instance *useCase) GetVisitorWebhook(userID, websiteID string) (string, string, error) {
+	var aWebsite website
+	err := instance.repo.GetWebsite(userID, websiteID, &aWebsite)
+	if err != nil {
+		return "", "", err
+	}
+	if aWebsite.VisitorWebhook == nil {
+		return "", "", nil
+	}
+	return aWebsite.VisitorWebhook.URL, aWebsite.VisitorWebhook.Secret, nil
+}
+
+// RotateSegmentWriteKey set a new key for the Segment calls of website, calls
+// sent with the previous one are refused
+func (instance *useCase) RotateSegmentWriteKey(userID, websiteID string) (string, error) {
+	writeKey := strings.ReplaceAll(uuid.New().String(), "-", "")
+	err := instance.repo.UpdateSegmentWriteKey(userID, websiteID, writeKey)
+	if err != nil {
+		return "", err
+	}
+	return writeKey, nil
+}
+
+// FindSegmentWriteKey user and id of the website of writeKey,
+// mongo.ErrNoDocuments when no website has it
+func (instance *useCase) FindSegmentWriteKey(writeKey string) (string, string, error) {
+	if writeKey == "" {
+		return "", "", mongo.ErrNoDocuments
+	}
+	var aWebsite website
+	err := instance.repo.FindSegmentWriteKey(writeKey, &aWebsite)
+	if err != nil {
+		return "", "", err
+	}
+	return aWebsite.UserID, aWebsite.ID, nil
+}
+
+// UpdateShare share the stats of website publicly or stop sharing them, the
+// token is kept while shared so changing the privacy keeps the link
+func (instance *useCase) UpdateShare(userID, websiteID string, enabled, private bool, minCount int64) (*Share, error) {
+	if !enabled {
+		return nil, instance.repo.UpdateShare(userID, websiteID, nil)
+	}
+	var aWebsite website
+	err := instance.repo.GetWebsite(userID, websiteID, &aWebsite)
+	if err != nil {
+		return nil, err
+	}
+	aShare := aWebsite.Share
+	if aShare == nil {
+		aShare = &Share{
+			Token: strings.ReplaceAll(uuid.New().String(), "-", ""),
+			Seed:  strings.ReplaceAll(uuid.New().String(), "-", ""),
+		}
+	}
+	aShare.Private = private
+	aShare.MinCount = 0
+	if private {
+		aShare.MinCount = minCount
+		if aShare.MinCount == 0 {
+			aShare.MinCount = DefaultShareMinCount
+		}
+	}
+	err = instance.repo.UpdateShare(userID, websiteID, aShare)
+	if err != nil {
+		return nil, err
+	}
+	return aShare, nil
+}
+
+// FindShareToken user, id and share of the website shared with token,
+// mongo.ErrNoDocuments when no website is
+func (instance *useCase) FindShareToken(token string) (string, string, *Share, error) {
+	if token == "" {
+		return "", "", nil, mongo.ErrNoDocuments
+	}
+	var aWebsite website
+	err := instance.repo.FindShareToken(token, &aWebsite)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return aWebsite.UserID, aWebsite.ID, aWebsite.Share, nil
+}
+
+// UpdateAggregateOnly switch aggregate-only mode of website, the data stored
+// before it is turned on is kept until it expires or the website is deleted
+func (instance *useCase) UpdateAggregateOnly(userID, websiteID string, enabled bool) error {
+	err := instance.repo.UpdateAggregateOnly(userID, websiteID, enabled)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateArchived archive website, or bring it back, mongo.ErrNoDocuments
+// when user has no such website. An archived website collects no event but
+// its data is kept and its reports stay readable
+func (instance *useCase) UpdateArchived(userID, websiteID string, archived bool) (*website, error) {
+	fields := bson.M{"archived": archived, "archived_at": ""}
+	if archived {
+		fields["archived_at"] = time.Now().Format("2006-01-02, 15:04:05")
+	}
+	var aWebsite website
+	err := instance.repo.UpdateWebsite(userID, websiteID, fields, nil, &aWebsite)
+	if err != nil {
+		return nil, err
+	}
+	return &aWebsite, nil
+}
+
+// GetAggregateOnly whether batches of website only add to its counters
+func (instance *useCase) GetAggregateOnly(websiteID string) (bool, error) {
+	enabled, err := instance.repo.GetAggregateOnly(websiteID)
+	if err != nil {
+		return false, err
+	}
+	return enabled, nil
+}
+
+// MarkTracked record that events of website arrived now, the UI shows the
+// website waiting for data until the first of them
+func (instance *useCase) MarkTracked(websiteID string) error {
+	err := instance.repo.MarkTracked(websiteID, time.Now().Format("2006-01-02, 15:04:05"))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// VerifyWebsite check the ownership of website with method, dns or meta,
+// both in turn when empty, and mark it verified when the token is found
+func (instance *useCase) VerifyWebsite(userID, websiteID, method string) (*website, error) {
+	var aWebsite website
+	err := instance.repo.GetWebsite(userID, websiteID, &aWebsite)
+	if err != nil {
+		return nil, err
+	}
+	if aWebsite.Verified() {
+		return &aWebsite, nil
+	}
+
+	switch method {
+	case "dns":
+		err = verify.DNS(aWebsite.HostName, aWebsite.VerificationToken)
+	case "meta":
+		err = verify.Meta(aWebsite.URL, aWebsite.VerificationToken)
+	default:
+		err = verify.DNS(aWebsite.HostName, aWebsite.VerificationToken)
+		if err != nil && verify.Meta(aWebsite.URL, aWebsite.VerificationToken) != nil {
+			err = ErrVerificationFailed
+		} else {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	aWebsite.VerifiedAt = time.Now().Format("2006-01-02, 15:04:05")
+	err = instance.repo.UpdateVerified(userID, websiteID, aWebsite.VerifiedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &aWebsite, nil
+}
+
+// Accepts nil when batches of website are collected, ErrNotVerified until
+// its ownership is verified and ErrArchived once it is archived.
+// mongo.ErrNoDocuments when the user has no such website
+func (instance *useCase) Accepts(userID, websiteID string) error {
+	var aWebsite website
+	err := instance.repo.GetWebsite(userID, websiteID, &aWebsite)
+	if err != nil {
+		return err
+	}
+	if !aWebsite.Verified() {
+		return ErrNotVerified
+	}
+	if aWebsite.Archived {
+		return ErrArchived
+	}
+	return nil
+}
+
+// GetURL url of website without trailing slash, ready to append a path
+func (instance *useCase) GetURL(userID, websiteID string) (string, error) {
+	var aWebsite website
+	err := instance.repo.GetWebsite(userID, websiteID, &aWebsite)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(aWebsite.URL, "/"), nil
+}
+
+// ProvisionInternal add the internal website tracking the dashboard to user
+// unless it has it already, return its id
+func (instance *useCase) ProvisionInternal(userID string) (string, error) {
+	websiteID := str.GetMD5Hash(InternalHostName)
+	count, err := instance.repo.FindWebsiteByID(userID, websiteID)
+	if err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return websiteID, nil
+	}
+	// its id is fixed, a deleted internal website is brought back rather than
+	// added next to it
+	_, err = instance.RestoreWebsite(userID, websiteID)
+	switch err {
+	case nil, ErrRestoreExpired:
+		return websiteID, nil
+	case mongo.ErrNoDocuments:
+	default:
+		return "", err
+	}
+
+	aPreset := Presets[DefaultPreset]
+	createdAt := time.Now().Format("2006-01-02, 15:04:05")
+	// usage is sent by the server, there is nothing to record in a browser
+	aWebsite := website{
+		ID:         websiteID,
+		UserID:     userID,
+		Category:   "Internal",
+		HostName:   InternalHostName,
+		URL:        "https://" + InternalHostName,
+		Features:   &features{},
+		Timezone:   aPreset.Timezone,
+		Locale:     aPreset.Locale,
+		DateFormat: aPreset.DateFormat,
+		Currency:   aPreset.Currency,
+		CreatedAt:  createdAt,
+		UpdatedAt:  createdAt,
+	}
+	err = instance.repo.InsertWebsite(userID, aWebsite)
+	if err != nil {
+		return "", err
+	}
+	return websiteID, nil
+}
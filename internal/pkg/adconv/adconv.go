@@ -0,0 +1,70 @@
+package adconv
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotHashed identifier is not a SHA-256 hex digest, raw identifiers are never sent
+var ErrNotHashed = errors.New("adconv: identifiers must be SHA-256 hex digests")
+
+// UserData identifiers of the converting visitor, hashed by the tracker with
+// SHA-256 after normalization so raw values never leave the browser
+type UserData struct {
+	// Email of trimmed lowercase email
+	Email string `json:"em,omitempty"`
+	// Phone of digits with country code, as Meta expects
+	Phone string `json:"ph,omitempty"`
+	// PhoneE164 of + and digits with country code, as Google Ads expects
+	PhoneE164 string `json:"ph_e164,omitempty"`
+	// GCLID and FBC click identifiers of the landing page, not hashed
+	GCLID string `json:"gclid,omitempty"`
+	FBC   string `json:"fbc,omitempty"`
+}
+
+// Validate ...
+func (instance UserData) Validate() error {
+	for _, value := range []string{instance.Email, instance.Phone, instance.PhoneE164} {
+		if value != "" && !ValidHash(value) {
+			return ErrNotHashed
+		}
+	}
+	return nil
+}
+
+// Conversion goal reached by a visitor
+type Conversion struct {
+	// EventID lets ad platforms drop a conversion sent twice
+	EventID   string
+	EventName string
+	Time      time.Time
+	SourceURL string
+	User      UserData
+}
+
+// Sender forward conversions to one ad platform
+type Sender interface {
+	Send(conversion Conversion) error
+}
+
+// ValidHash report whether value is a lowercase SHA-256 hex digest
+func ValidHash(value string) bool {
+	if len(value) != 64 {
+		return false
+	}
+	for _, r := range value {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// serviceError failure reported by an ad platform
+func serviceError(service, status string, body []byte) error {
+	if len(body) > 512 {
+		body = body[:512]
+	}
+	return fmt.Errorf("adconv: %s: %s: %s", service, status, body)
+}
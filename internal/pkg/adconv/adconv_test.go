@@ -0,0 +1,130 @@
+package adconv
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const emailHash = "973dfe463ec85785f5f95af5ba3906eedb2d931c24e69824a89ea65dba4e813b"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    UserData
+		wantErr bool
+	}{
+		{name: "should accept hashed identifiers", user: UserData{Email: emailHash, GCLID: "Cj0KCQ"}},
+		{name: "should accept no identifier", user: UserData{}},
+		{name: "should reject raw email", user: UserData{Email: "test@example.com"}, wantErr: true},
+		{name: "should reject uppercase digest", user: UserData{Phone: strings.ToUpper(emailHash)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.user.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMetaSend(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "should send conversion", status: http.StatusOK},
+		{name: "should report rejected conversion", status: http.StatusBadRequest, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/123/events" || r.URL.RawQuery != "" {
+					t.Errorf("request = %s", r.URL)
+				}
+				var body struct {
+					AccessToken string `json:"access_token"`
+					Data        []struct {
+						EventID  string              `json:"event_id"`
+						UserData map[string][]string `json:"user_data"`
+					} `json:"data"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Data) != 1 {
+					t.Fatalf("body error = %v", err)
+				}
+				if body.AccessToken != "secret" {
+					t.Errorf("access_token = %v", body.AccessToken)
+				}
+				if body.Data[0].EventID != "e1" || body.Data[0].UserData["em"][0] != emailHash {
+					t.Errorf("event = %+v", body.Data[0])
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"events_received":1}`))
+			}))
+			defer server.Close()
+
+			sender := NewMeta("123", "secret")
+			sender.Endpoint = server.URL
+			sender.HTTP = server.Client()
+			err := sender.Send(Conversion{EventID: "e1", EventName: "Signup", Time: time.Now(), User: UserData{Email: emailHash}})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGoogleAdsSend(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{name: "should send conversion", body: `{"results":[{"gclid":"g"}]}`},
+		{name: "should report partial failure", body: `{"partialFailureError":{"code":3,"message":"invalid gclid"}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/token":
+					tokens++
+					w.Write([]byte(`{"access_token":"at","expires_in":3600}`))
+				case "/customers/1234567890:uploadClickConversions":
+					if r.Header.Get("Authorization") != "Bearer at" || r.Header.Get("developer-token") != "dev" {
+						t.Errorf("headers = %v", r.Header)
+					}
+					data, _ := io.ReadAll(r.Body)
+					if !strings.Contains(string(data), `"gclid":"g"`) || !strings.Contains(string(data), `"hashedEmail":"`+emailHash+`"`) {
+						t.Errorf("body = %s", data)
+					}
+					w.Write([]byte(tt.body))
+				default:
+					w.WriteHeader(http.StatusNotImplemented)
+				}
+			}))
+			defer server.Close()
+
+			sender := NewGoogleAds("123-456-7890", "customers/1234567890/conversionActions/1", "", "dev", "id", "secret", "refresh")
+			sender.TokenURL = server.URL + "/token"
+			sender.Endpoint = server.URL
+			sender.HTTP = server.Client()
+			conversion := Conversion{EventID: "e1", Time: time.Now(), User: UserData{Email: emailHash, GCLID: "g"}}
+			for i := 0; i < 2; i++ {
+				err := sender.Send(conversion)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("Send() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			}
+			if tokens != 1 {
+				t.Errorf("tokens = %d, want the access token cached", tokens)
+			}
+		})
+	}
+}
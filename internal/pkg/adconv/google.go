@@ -0,0 +1,151 @@
+package adconv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"analytics-api/internal/pkg/request"
+)
+
+// GoogleAds sender of click conversions to the Google Ads API, enhanced with
+// the hashed identifiers of the visitor
+type GoogleAds struct {
+	// CustomerID account of the conversion action, digits only
+	CustomerID string
+	// ConversionAction resource name like customers/123/conversionActions/456
+	ConversionAction string
+	// LoginCustomerID manager account used to access CustomerID, if any
+	LoginCustomerID string
+	DeveloperToken  string
+	ClientID        string
+	ClientSecret    string
+	RefreshToken    string
+	TokenURL        string
+	Endpoint        string
+	HTTP            *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiry      time.Time
+}
+
+// NewGoogleAds sender authenticated with an oauth refresh token
+func NewGoogleAds(customerID, conversionAction, loginCustomerID, developerToken, clientID, clientSecret, refreshToken string) *GoogleAds {
+	return &GoogleAds{
+		CustomerID:       strings.ReplaceAll(customerID, "-", ""),
+		ConversionAction: conversionAction,
+		LoginCustomerID:  strings.ReplaceAll(loginCustomerID, "-", ""),
+		DeveloperToken:   developerToken,
+		ClientID:         clientID,
+		ClientSecret:     clientSecret,
+		RefreshToken:     refreshToken,
+		TokenURL:         "https://oauth2.googleapis.com/token",
+		Endpoint:         "https://googleads.googleapis.com/v17",
+		HTTP:             request.NewPublicClient(10 * time.Second),
+	}
+}
+
+// Send ...
+func (instance *GoogleAds) Send(conversion Conversion) error {
+	accessToken, err := instance.token()
+	if err != nil {
+		return err
+	}
+
+	identifiers := []map[string]string{}
+	if conversion.User.Email != "" {
+		identifiers = append(identifiers, map[string]string{"hashedEmail": conversion.User.Email})
+	}
+	if conversion.User.PhoneE164 != "" {
+		identifiers = append(identifiers, map[string]string{"hashedPhoneNumber": conversion.User.PhoneE164})
+	}
+	aConversion := map[string]interface{}{
+		"conversionAction":   instance.ConversionAction,
+		"conversionDateTime": conversion.Time.UTC().Format("2006-01-02 15:04:05-07:00"),
+		"orderId":            conversion.EventID,
+		"userIdentifiers":    identifiers,
+	}
+	if conversion.User.GCLID != "" {
+		aConversion["gclid"] = conversion.User.GCLID
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"conversions":    []map[string]interface{}{aConversion},
+		"partialFailure": true,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost,
+		fmt.Sprintf("%s/customers/%s:uploadClickConversions", instance.Endpoint, instance.CustomerID), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("developer-token", instance.DeveloperToken)
+	if instance.LoginCustomerID != "" {
+		req.Header.Set("login-customer-id", instance.LoginCustomerID)
+	}
+
+	res, err := instance.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return serviceError("google ads", res.Status, data)
+	}
+	// with partial failure on, rejected conversions come back with status 200
+	var result struct {
+		PartialFailureError *json.RawMessage `json:"partialFailureError"`
+	}
+	if err := json.Unmarshal(data, &result); err == nil && result.PartialFailureError != nil {
+		return serviceError("google ads", res.Status, *result.PartialFailureError)
+	}
+	return nil
+}
+
+// token oauth access token of the refresh token, renewed before it expires
+func (instance *GoogleAds) token() (string, error) {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	if instance.accessToken != "" && time.Now().Before(instance.expiry) {
+		return instance.accessToken, nil
+	}
+
+	now := time.Now()
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("client_id", instance.ClientID)
+	form.Set("client_secret", instance.ClientSecret)
+	form.Set("refresh_token", instance.RefreshToken)
+	res, err := instance.HTTP.Post(instance.TokenURL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return "", serviceError("google ads token", res.Status, data)
+	}
+
+	var grant struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(data, &grant); err != nil {
+		return "", err
+	}
+	instance.accessToken = grant.AccessToken
+	// renew a minute early so a token never expires in flight
+	instance.expiry = now.Add(time.Duration(grant.ExpiresIn)*time.Second - time.Minute)
+	return instance.accessToken, nil
+}
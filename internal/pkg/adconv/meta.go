@@ -0,0 +1,72 @@
+package adconv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"analytics-api/internal/pkg/request"
+)
+
+// Meta sender of the Meta Conversions API
+type Meta struct {
+	PixelID     string
+	AccessToken string
+	Endpoint    string
+	HTTP        *http.Client
+}
+
+// NewMeta sender of the events of a pixel
+func NewMeta(pixelID, accessToken string) *Meta {
+	return &Meta{
+		PixelID:     pixelID,
+		AccessToken: accessToken,
+		Endpoint:    "https://graph.facebook.com/v19.0",
+		HTTP:        request.NewPublicClient(10 * time.Second),
+	}
+}
+
+// Send ...
+func (instance *Meta) Send(conversion Conversion) error {
+	userData := map[string]interface{}{}
+	if conversion.User.Email != "" {
+		userData["em"] = []string{conversion.User.Email}
+	}
+	if conversion.User.Phone != "" {
+		userData["ph"] = []string{conversion.User.Phone}
+	}
+	if conversion.User.FBC != "" {
+		userData["fbc"] = conversion.User.FBC
+	}
+	// the token goes in the body, a query string ends up in access logs
+	body, err := json.Marshal(map[string]interface{}{
+		"access_token": instance.AccessToken,
+		"data": []map[string]interface{}{{
+			"event_name":       conversion.EventName,
+			"event_time":       conversion.Time.Unix(),
+			"event_id":         conversion.EventID,
+			"event_source_url": conversion.SourceURL,
+			"action_source":    "website",
+			"user_data":        userData,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/%s/events", instance.Endpoint, url.PathEscape(instance.PixelID))
+	res, err := instance.HTTP.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return serviceError("meta", res.Status, data)
+	}
+	return nil
+}
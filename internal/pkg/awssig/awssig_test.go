@@ -0,0 +1,61 @@
+package awssig
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	credentials := Credentials{
+		Region:          "us-east-1",
+		Service:         "kinesis",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		body      string
+		wantScope string
+		wantSig   string
+	}{
+		{
+			name:      "should sign put records",
+			body:      `{"StreamName":"s"}`,
+			wantScope: "Credential=AKIDEXAMPLE/20150830/us-east-1/kinesis/aws4_request",
+			wantSig:   "Signature=d74ac75d5e99983a1dcd9737e9f9137ad38938921d7048cd3ebca2114955f63a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, "https://kinesis.us-east-1.amazonaws.com/", bytes.NewReader([]byte(tt.body)))
+			req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+			req.Header.Set("X-Amz-Target", "Kinesis_20131202.PutRecords")
+			Sign(req, []byte(tt.body), credentials, now)
+
+			authorization := req.Header.Get("Authorization")
+			if !strings.Contains(authorization, tt.wantScope) || !strings.HasSuffix(authorization, tt.wantSig) {
+				t.Errorf("Authorization = %q", authorization)
+			}
+			if req.Header.Get("X-Amz-Date") != "20150830T123600Z" {
+				t.Errorf("X-Amz-Date = %q", req.Header.Get("X-Amz-Date"))
+			}
+		})
+	}
+}
+
+func TestSigningKey(t *testing.T) {
+	// example of the AWS documentation on deriving the signing key
+	key := hmacSHA256([]byte("AWS4wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"), "20120215")
+	key = hmacSHA256(key, "us-east-1")
+	key = hmacSHA256(key, "iam")
+	key = hmacSHA256(key, "aws4_request")
+	want := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"
+	if got := hex.EncodeToString(key); got != want {
+		t.Errorf("signing key = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,152 @@
+package clickhouse
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Client minimal client of the ClickHouse HTTP interface
+type Client struct {
+	URL      string
+	Database string
+	User     string
+	Password string
+	HTTP     *http.Client
+}
+
+// New ...
+func New(address, database, user, password string) *Client {
+	return &Client{
+		URL:      address,
+		Database: database,
+		User:     user,
+		Password: password,
+		HTTP:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Exec run a statement, params are bound to {name:Type} placeholders of query
+func (instance *Client) Exec(query string, params map[string]string) error {
+	_, err := instance.do(query, params, nil)
+	return err
+}
+
+// Insert write rows to table in JSONEachRow format
+func (instance *Client) Insert(table string, rows []interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	_, err := instance.do(fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", table), nil, &body)
+	return err
+}
+
+// Query run a select and call fn with each row in JSONEachRow format, rows
+// are read from the response as they arrive
+func (instance *Client) Query(query string, params map[string]string, fn func(row []byte) error) error {
+	res, err := instance.send(query+" FORMAT JSONEachRow", params, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (instance *Client) do(query string, params map[string]string, body io.Reader) ([]byte, error) {
+	res, err := instance.send(query, params, body)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	return io.ReadAll(res.Body)
+}
+
+// send the statement, the caller closes the body of a successful response
+func (instance *Client) send(query string, params map[string]string, body io.Reader) (*http.Response, error) {
+	values := url.Values{}
+	values.Set("database", instance.Database)
+	// keep Int64 and UInt64 as JSON numbers
+	values.Set("output_format_json_quote_64bit_integers", "0")
+	for name, value := range params {
+		values.Set("param_"+name, value)
+	}
+
+	// the statement goes in the query string when the body carries rows
+	var req *http.Request
+	var err error
+	if body == nil {
+		req, err = http.NewRequest(http.MethodPost, instance.URL+"/?"+values.Encode(), bytes.NewBufferString(query))
+	} else {
+		values.Set("query", query)
+		req, err = http.NewRequest(http.MethodPost, instance.URL+"/?"+values.Encode(), body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if instance.User != "" {
+		req.SetBasicAuth(instance.User, instance.Password)
+	}
+
+	res, err := instance.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("clickhouse: %s: %s", res.Status, bytes.TrimSpace(data))
+	}
+	return res, nil
+}
+
+// ArrayParam format values as an Array(String) query parameter
+func ArrayParam(values []string) string {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, value := range values {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('\'')
+		for _, r := range value {
+			if r == '\'' || r == '\\' {
+				buf.WriteByte('\\')
+			}
+			buf.WriteRune(r)
+		}
+		buf.WriteByte('\'')
+	}
+	buf.WriteByte(']')
+	return buf.String()
+}
+
+// TimeParam format t as a DateTime64(3) query parameter
+func TimeParam(t time.Time) string {
+	return t.UTC().Format(TimeLayout)
+}
+
+// TimeLayout layout of DateTime64(3) values in JSONEachRow rows
+const TimeLayout = "2006-01-02 15:04:05.000"
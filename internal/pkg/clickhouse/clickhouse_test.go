@@ -0,0 +1,111 @@
+package clickhouse
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientInsert(t *testing.T) {
+	var gotQuery, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query().Get("query")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "analytics", "", "")
+	err := client.Insert("events", []interface{}{
+		map[string]string{"id": "a"},
+		map[string]string{"id": "b"},
+	})
+	if err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+
+	if gotQuery != "INSERT INTO events FORMAT JSONEachRow" {
+		t.Errorf("Insert() query = %v", gotQuery)
+	}
+	if gotBody != "{\"id\":\"a\"}\n{\"id\":\"b\"}\n" {
+		t.Errorf("Insert() body = %v", gotBody)
+	}
+}
+
+func TestClientQuery(t *testing.T) {
+	var gotParam string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotParam = r.URL.Query().Get("param_id")
+		_, _ = w.Write([]byte("{\"n\":1}\n{\"n\":2}\n"))
+	}))
+	defer server.Close()
+
+	var got []int
+	client := New(server.URL, "analytics", "", "")
+	err := client.Query("SELECT n FROM t WHERE id = {id:String}", map[string]string{"id": "x"}, func(row []byte) error {
+		var value struct {
+			N int `json:"n"`
+		}
+		if err := json.Unmarshal(row, &value); err != nil {
+			return err
+		}
+		got = append(got, value.N)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+
+	if gotParam != "x" {
+		t.Errorf("Query() param_id = %v, want x", gotParam)
+	}
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("Query() rows = %v, want [1 2]", got)
+	}
+}
+
+func TestClientError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Code: 60. Table does not exist", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	if err := New(server.URL, "analytics", "", "").Exec("SELECT 1", nil); err == nil {
+		t.Errorf("Exec() should return error of failed statement")
+	}
+}
+
+func TestArrayParam(t *testing.T) {
+	type args struct {
+		values []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "should quote values",
+			args: args{
+				values: []string{"a", "b"},
+			},
+			want: "['a','b']",
+		},
+		{
+			name: "should escape quotes and backslashes",
+			args: args{
+				values: []string{"it's", `a\b`},
+			},
+			want: `['it\'s','a\\b']`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ArrayParam(tt.args.values); got != tt.want {
+				t.Errorf("ArrayParam() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package confirm
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/internal/pkg/keystore"
+)
+
+// TTL confirmation tokens are valid for, long enough to read what would be
+// lost and short enough that a leaked link is soon useless
+const TTL = 5 * time.Minute
+
+// ErrInvalid ...
+var ErrInvalid = errors.New("confirmation token invalid or expired")
+
+// Issue token confirming the destructive action of user on subject. key
+// prefixes the key of the token, like Store.Key does per tenant
+func Issue(key func(string) string, userID, action, subject string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	err := configs.KeyStore.Client.Set(key("confirm:"+token), binding(userID, action, subject), TTL)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Consume check token was issued for the same action of user on subject and
+// use it up, a token confirms a single call
+func Consume(key func(string) string, userID, action, subject, token string) error {
+	if token == "" {
+		return ErrInvalid
+	}
+	// two calls racing with the same token, only the one taking it goes on.
+	// A token taken for another action is used up too, it was leaked
+	value, err := configs.KeyStore.Client.Take(key("confirm:" + token))
+	if err == keystore.ErrNotFound || (err == nil && value != binding(userID, action, subject)) {
+		return ErrInvalid
+	}
+	return err
+}
+
+func binding(userID, action, subject string) string {
+	return userID + "\n" + action + "\n" + subject
+}
@@ -0,0 +1,126 @@
+package crmapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrInvalidProperty ...
+var ErrInvalidProperty = errors.New("crmapi: property names start with a letter followed by letters, digits or _")
+
+// property name of a HubSpot property or Salesforce field, custom ones included
+var property = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,99}$`)
+
+// Token oauth tokens of a connected CRM account
+type Token struct {
+	AccessToken  string `json:"access_token" bson:"access_token"`
+	RefreshToken string `json:"refresh_token" bson:"refresh_token"`
+	// InstanceURL API host of the Salesforce org, empty for HubSpot
+	InstanceURL string    `json:"instance_url,omitempty" bson:"instance_url,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at" bson:"expires_at"`
+}
+
+// Expired report whether the access token must be refreshed before use
+func (instance Token) Expired(now time.Time) bool {
+	return instance.AccessToken == "" || !now.Add(time.Minute).Before(instance.ExpiresAt)
+}
+
+// Record contact identified by the value of a unique property, with the
+// properties to set on it
+type Record struct {
+	IDProperty string
+	ID         string
+	Properties map[string]string
+}
+
+// Client write contact records of one CRM
+type Client interface {
+	// AuthorizeURL page asking the user to connect their account
+	AuthorizeURL(state, redirectURL string) string
+	Exchange(code, redirectURL string) (Token, error)
+	Refresh(token Token) (Token, error)
+	// Upsert update the contact if it exists or create it
+	Upsert(token Token, record Record) error
+}
+
+// ValidateProperty ...
+func ValidateProperty(name string) error {
+	if !property.MatchString(name) {
+		return ErrInvalidProperty
+	}
+	return nil
+}
+
+// oauth endpoints and credentials of the app registered with a CRM
+type oauth struct {
+	AuthURL      string
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       string
+	HTTP         *http.Client
+}
+
+func (instance *oauth) authorizeURL(state, redirectURL string) string {
+	query := url.Values{
+		"client_id":     {instance.ClientID},
+		"redirect_uri":  {redirectURL},
+		"scope":         {instance.Scopes},
+		"state":         {state},
+		"response_type": {"code"},
+	}
+	return instance.AuthURL + "?" + query.Encode()
+}
+
+// token request tokens with a grant, keeping refreshToken when the CRM does
+// not rotate it
+func (instance *oauth) token(grant url.Values, refreshToken string) (Token, error) {
+	grant.Set("client_id", instance.ClientID)
+	grant.Set("client_secret", instance.ClientSecret)
+	res, err := instance.HTTP.PostForm(instance.TokenURL, grant)
+	if err != nil {
+		return Token{}, err
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if res.StatusCode != http.StatusOK {
+		return Token{}, serviceError("oauth", res.Status, data)
+	}
+	var reply struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		InstanceURL  string `json:"instance_url"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return Token{}, err
+	}
+	if reply.RefreshToken == "" {
+		reply.RefreshToken = refreshToken
+	}
+	// Salesforce does not tell the session timeout, an hour is the shortest
+	if reply.ExpiresIn == 0 {
+		reply.ExpiresIn = 3600
+	}
+	return Token{
+		AccessToken:  reply.AccessToken,
+		RefreshToken: reply.RefreshToken,
+		InstanceURL:  strings.TrimSuffix(reply.InstanceURL, "/"),
+		ExpiresAt:    time.Now().Add(time.Duration(reply.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// serviceError failure reported by a CRM
+func serviceError(service, status string, body []byte) error {
+	if len(body) > 512 {
+		body = body[:512]
+	}
+	return fmt.Errorf("crmapi: %s: %s: %s", service, status, body)
+}
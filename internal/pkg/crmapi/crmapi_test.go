@@ -0,0 +1,156 @@
+package crmapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateProperty(t *testing.T) {
+	tests := []struct {
+		name     string
+		property string
+		wantErr  bool
+	}{
+		{name: "should accept hubspot property", property: "first_landing_page"},
+		{name: "should accept salesforce custom field", property: "Landing_Page__c"},
+		{name: "should reject leading digit", property: "1st_page", wantErr: true},
+		{name: "should reject path characters", property: "Contact/Id", wantErr: true},
+		{name: "should reject empty", property: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateProperty(tt.property); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateProperty() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenExpired(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		token Token
+		want  bool
+	}{
+		{name: "should use valid token", token: Token{AccessToken: "at", ExpiresAt: now.Add(time.Hour)}, want: false},
+		{name: "should refresh token about to expire", token: Token{AccessToken: "at", ExpiresAt: now.Add(30 * time.Second)}, want: true},
+		{name: "should refresh missing token", token: Token{ExpiresAt: now.Add(time.Hour)}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.Expired(now); got != tt.want {
+				t.Errorf("Expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSalesforceRefresh(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("refresh_token") != "rt" || r.Form.Get("client_secret") != "secret" {
+			t.Errorf("form = %v", r.Form)
+		}
+		w.Write([]byte(`{"access_token":"at2"}`))
+	}))
+	defer server.Close()
+
+	client := NewSalesforce("id", "secret")
+	client.TokenURL = server.URL
+	client.HTTP = server.Client()
+	token, err := client.Refresh(Token{RefreshToken: "rt", InstanceURL: "https://acme.my.salesforce.com"})
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if token.AccessToken != "at2" || token.RefreshToken != "rt" || token.InstanceURL != "https://acme.my.salesforce.com" {
+		t.Errorf("Refresh() = %+v", token)
+	}
+	if token.Expired(time.Now()) {
+		t.Errorf("Refresh() token expires at %v", token.ExpiresAt)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		status   int
+		reply    string
+		wantPath string
+		wantBody string
+		wantErr  bool
+	}{
+		{
+			name:     "should upsert hubspot contact",
+			provider: "hubspot",
+			status:   http.StatusOK,
+			reply:    `{"status":"COMPLETE","results":[{"id":"1"}]}`,
+			wantPath: "/crm/v3/objects/contacts/batch/upsert",
+			wantBody: `{"inputs":[{"id":"a@example.com","idProperty":"email","properties":{"first_landing_page":"/pricing"}}]}`,
+		},
+		{
+			name:     "should report hubspot errors",
+			provider: "hubspot",
+			status:   http.StatusMultiStatus,
+			reply:    `{"status":"COMPLETE","results":[],"errors":[{"message":"Property does not exist"}]}`,
+			wantPath: "/crm/v3/objects/contacts/batch/upsert",
+			wantErr:  true,
+		},
+		{
+			name:     "should upsert salesforce contact by external id",
+			provider: "salesforce",
+			status:   http.StatusNoContent,
+			wantPath: "/services/data/v59.0/sobjects/Contact/Customer_ID__c/42",
+			wantBody: `{"first_landing_page":"/pricing"}`,
+		},
+		{
+			name:     "should report salesforce failure",
+			provider: "salesforce",
+			status:   http.StatusBadRequest,
+			reply:    `[{"errorCode":"INVALID_FIELD"}]`,
+			wantPath: "/services/data/v59.0/sobjects/Contact/Customer_ID__c/42",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.wantPath {
+					t.Errorf("path = %v, want %v", r.URL.Path, tt.wantPath)
+				}
+				if r.Header.Get("Authorization") != "Bearer at" {
+					t.Errorf("authorization = %v", r.Header.Get("Authorization"))
+				}
+				body, _ := io.ReadAll(r.Body)
+				if tt.wantBody != "" && strings.TrimSpace(string(body)) != tt.wantBody {
+					t.Errorf("body = %s, want %s", body, tt.wantBody)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.reply))
+			}))
+			defer server.Close()
+
+			properties := map[string]string{"first_landing_page": "/pricing"}
+			var err error
+			switch tt.provider {
+			case "hubspot":
+				client := NewHubSpot("id", "secret")
+				client.Endpoint = server.URL
+				client.HTTP = server.Client()
+				err = client.Upsert(Token{AccessToken: "at"}, Record{IDProperty: "email", ID: "a@example.com", Properties: properties})
+			case "salesforce":
+				client := NewSalesforce("id", "secret")
+				client.HTTP = server.Client()
+				err = client.Upsert(Token{AccessToken: "at", InstanceURL: server.URL}, Record{IDProperty: "Customer_ID__c", ID: "42", Properties: properties})
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Upsert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
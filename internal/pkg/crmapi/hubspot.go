@@ -0,0 +1,89 @@
+package crmapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"analytics-api/internal/pkg/request"
+)
+
+// HubSpot client of the HubSpot CRM contacts API
+type HubSpot struct {
+	oauth
+	Endpoint string
+}
+
+// NewHubSpot client of the public app with clientID
+func NewHubSpot(clientID, clientSecret string) *HubSpot {
+	return &HubSpot{
+		oauth: oauth{
+			AuthURL:      "https://app.hubspot.com/oauth/authorize",
+			TokenURL:     "https://api.hubapi.com/oauth/v1/token",
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Scopes:       "crm.objects.contacts.read crm.objects.contacts.write",
+			HTTP:         request.NewPublicClient(10 * time.Second),
+		},
+		Endpoint: "https://api.hubapi.com",
+	}
+}
+
+// AuthorizeURL ...
+func (instance *HubSpot) AuthorizeURL(state, redirectURL string) string {
+	return instance.authorizeURL(state, redirectURL)
+}
+
+// Exchange ...
+func (instance *HubSpot) Exchange(code, redirectURL string) (Token, error) {
+	return instance.token(url.Values{"grant_type": {"authorization_code"}, "code": {code}, "redirect_uri": {redirectURL}}, "")
+}
+
+// Refresh ...
+func (instance *HubSpot) Refresh(token Token) (Token, error) {
+	return instance.token(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {token.RefreshToken}}, token.RefreshToken)
+}
+
+// Upsert with the batch upsert endpoint, which matches contacts on any unique
+// property including email
+func (instance *HubSpot) Upsert(token Token, record Record) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"inputs": []map[string]interface{}{{
+			"idProperty": record.IDProperty,
+			"id":         record.ID,
+			"properties": record.Properties,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, instance.Endpoint+"/crm/v3/objects/contacts/batch/upsert", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	res, err := instance.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusMultiStatus {
+		return serviceError("hubspot", res.Status, data)
+	}
+	var reply struct {
+		Errors []json.RawMessage `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return err
+	}
+	if len(reply.Errors) > 0 {
+		return serviceError("hubspot", res.Status, reply.Errors[0])
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package crmapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"analytics-api/internal/pkg/request"
+)
+
+// Salesforce client of the Salesforce REST API, writing Contact records
+type Salesforce struct {
+	oauth
+	APIVersion string
+}
+
+// NewSalesforce client of the connected app with clientID
+func NewSalesforce(clientID, clientSecret string) *Salesforce {
+	return &Salesforce{
+		oauth: oauth{
+			AuthURL:      "https://login.salesforce.com/services/oauth2/authorize",
+			TokenURL:     "https://login.salesforce.com/services/oauth2/token",
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Scopes:       "api refresh_token",
+			HTTP:         request.NewPublicClient(10 * time.Second),
+		},
+		APIVersion: "v59.0",
+	}
+}
+
+// AuthorizeURL ...
+func (instance *Salesforce) AuthorizeURL(state, redirectURL string) string {
+	return instance.authorizeURL(state, redirectURL)
+}
+
+// Exchange ...
+func (instance *Salesforce) Exchange(code, redirectURL string) (Token, error) {
+	return instance.token(url.Values{"grant_type": {"authorization_code"}, "code": {code}, "redirect_uri": {redirectURL}}, "")
+}
+
+// Refresh keep the instance url, Salesforce only returns it on exchange
+func (instance *Salesforce) Refresh(token Token) (Token, error) {
+	refreshed, err := instance.token(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {token.RefreshToken}}, token.RefreshToken)
+	if err != nil {
+		return Token{}, err
+	}
+	if refreshed.InstanceURL == "" {
+		refreshed.InstanceURL = token.InstanceURL
+	}
+	return refreshed, nil
+}
+
+// Upsert by external id, IDProperty must be an External ID field of Contact
+func (instance *Salesforce) Upsert(token Token, record Record) error {
+	body, err := json.Marshal(record.Properties)
+	if err != nil {
+		return err
+	}
+	endpoint := token.InstanceURL + "/services/data/" + instance.APIVersion + "/sobjects/Contact/" +
+		url.PathEscape(record.IDProperty) + "/" + url.PathEscape(record.ID)
+	req, err := http.NewRequest(http.MethodPatch, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	res, err := instance.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusNoContent {
+		return serviceError("salesforce", res.Status, data)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+// ErrInvalidCursor cursor was not produced by Encode
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor position after the last item of a page. Items are sorted by Key then
+// ID, so a page starting after a cursor stays the same when newer items arrive
+type Cursor struct {
+	Key int64  `json:"k"`
+	ID  string `json:"i"`
+}
+
+// Encode opaque form of the cursor returned to clients
+func (instance Cursor) Encode() string {
+	data, _ := json.Marshal(instance)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode parse a cursor sent by a client, an empty value means the first page
+func Decode(value string) (*Cursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// Next encoded cursor after next, empty when there is no next page
+func Next(next *Cursor) string {
+	if next == nil {
+		return ""
+	}
+	return next.Encode()
+}
+
+// Limit parse the page size, falling back to def and capped to max
+func Limit(value string, def, max int) int {
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return def
+	}
+	if limit > max {
+		return max
+	}
+	return limit
+}
@@ -0,0 +1,67 @@
+package cursor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    *Cursor
+		wantErr error
+	}{
+		{
+			name:  "should return nil for the first page",
+			value: "",
+			want:  nil,
+		},
+		{
+			name:  "should decode an encoded cursor",
+			value: Cursor{Key: 1657091090000, ID: "62c4f2"}.Encode(),
+			want:  &Cursor{Key: 1657091090000, ID: "62c4f2"},
+		},
+		{
+			name:    "should reject a value that is not base64",
+			value:   "not a cursor!",
+			wantErr: ErrInvalidCursor,
+		},
+		{
+			name:    "should reject a cursor without id",
+			value:   Cursor{Key: 1}.Encode(),
+			wantErr: ErrInvalidCursor,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.value)
+			if err != tt.wantErr {
+				t.Fatalf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  int
+	}{
+		{name: "should use default when empty", value: "", want: 20},
+		{name: "should use default when invalid", value: "-3", want: 20},
+		{name: "should keep a valid limit", value: "50", want: 50},
+		{name: "should cap to max", value: "1000", want: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Limit(tt.value, 20, 100); got != tt.want {
+				t.Errorf("Limit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
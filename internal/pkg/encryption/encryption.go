@@ -0,0 +1,124 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// ErrMalformed sealed value is not in the "v<version>.<payload>" form
+var ErrMalformed = errors.New("malformed sealed value")
+
+// MasterKeySize bytes of the master key, the size of the AES-256 keys
+// derived from it
+const MasterKeySize = 32
+
+// ParseMasterKey decode the base64 master key, refusing one that is not
+// MasterKeySize bytes long
+func ParseMasterKey(value string) ([]byte, error) {
+	master, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(master) != MasterKeySize {
+		return nil, fmt.Errorf("master key is %d bytes, want %d", len(master), MasterKeySize)
+	}
+	return master, nil
+}
+
+// Keyring derive data keys of one tenant from the instance master key.
+// Each key version derives a distinct key, so rotating only changes the key
+// used to seal new data while data sealed with older versions stays readable.
+type Keyring struct {
+	master   []byte
+	tenantID string
+	version  atomic.Int64
+}
+
+// NewKeyring ...
+func NewKeyring(master []byte, tenantID string, version int) *Keyring {
+	keyring := &Keyring{
+		master:   master,
+		tenantID: tenantID,
+	}
+	keyring.SetVersion(version)
+	return keyring
+}
+
+// SetVersion set key version used to seal new data
+func (instance *Keyring) SetVersion(version int) {
+	instance.version.Store(int64(version))
+}
+
+// Version key version used to seal new data
+func (instance *Keyring) Version() int {
+	return int(instance.version.Load())
+}
+
+// Seal encrypt plaintext with the current key version
+func (instance *Keyring) Seal(plaintext []byte) (string, error) {
+	version := instance.Version()
+	aead, err := instance.aead(version)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(instance.tenantID))
+	return fmt.Sprintf("v%d.%s", version, base64.RawStdEncoding.EncodeToString(sealed)), nil
+}
+
+// Open decrypt value sealed by any key version of this tenant
+func (instance *Keyring) Open(value string) ([]byte, error) {
+	prefix, payload, ok := strings.Cut(value, ".")
+	if !ok || !strings.HasPrefix(prefix, "v") {
+		return nil, ErrMalformed
+	}
+	version, err := strconv.Atoi(prefix[1:])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	aead, err := instance.aead(version)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(instance.tenantID))
+}
+
+// aead AES-256-GCM with the key of tenant and version
+func (instance *Keyring) aead(version int) (cipher.AEAD, error) {
+	key := make([]byte, 32)
+	info := fmt.Sprintf("data-key:%s:v%d", instance.tenantID, version)
+	kdf := hkdf.New(sha256.New, instance.master, []byte(instance.tenantID), []byte(info))
+	if _, err := io.ReadFull(kdf, key); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,107 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+var testMasterKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestKeyringSealOpen(t *testing.T) {
+	keyring := NewKeyring(testMasterKey, "acme", 1)
+	sealed, err := keyring.Seal([]byte("recording"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	got, err := keyring.Open(sealed)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if string(got) != "recording" {
+		t.Errorf("Open() = %v, want %v", string(got), "recording")
+	}
+}
+
+func TestKeyringOpenOtherTenant(t *testing.T) {
+	sealed, err := NewKeyring(testMasterKey, "acme", 1).Seal([]byte("recording"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	if _, err := NewKeyring(testMasterKey, "globex", 1).Open(sealed); err == nil {
+		t.Errorf("Open() of another tenant should fail")
+	}
+}
+
+func TestKeyringRotate(t *testing.T) {
+	keyring := NewKeyring(testMasterKey, "acme", 1)
+	before, _ := keyring.Seal([]byte("before"))
+
+	keyring.SetVersion(2)
+	after, _ := keyring.Seal([]byte("after"))
+
+	tests := []struct {
+		name   string
+		sealed string
+		want   string
+	}{
+		{
+			name:   "should open data sealed before rotation",
+			sealed: before,
+			want:   "before",
+		},
+		{
+			name:   "should open data sealed after rotation",
+			sealed: after,
+			want:   "after",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := keyring.Open(tt.sealed)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Open() = %v, want %v", string(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMasterKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{
+			name:  "should accept a 32 bytes key",
+			value: base64.StdEncoding.EncodeToString(testMasterKey),
+		},
+		{
+			name:    "should refuse a 16 bytes key",
+			value:   base64.StdEncoding.EncodeToString(testMasterKey[:16]),
+			wantErr: true,
+		},
+		{
+			name:    "should refuse a 64 bytes key",
+			value:   base64.StdEncoding.EncodeToString(append(testMasterKey, testMasterKey...)),
+			wantErr: true,
+		},
+		{
+			name:    "should refuse a key that is not base64",
+			value:   "not base64!",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseMasterKey(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseMasterKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package etag
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// CodeConflict code of a change made on a version of a resource that is no
+// longer the current one
+const CodeConflict = "version_conflict"
+
+// ErrConflict ...
+var ErrConflict = errors.New("this was changed by someone else in the meantime, reload it and try again")
+
+// ErrInvalidIfMatch ...
+var ErrInvalidIfMatch = errors.New("If-Match must be the ETag of the resource")
+
+// Tag strong ETag of version
+func Tag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// Set answer version in the ETag header, so the client can send it back in
+// If-Match when it changes the resource
+func Set(c *gin.Context, version int64) {
+	c.Header("ETag", Tag(version))
+}
+
+// IfMatch version the request is made on, from its If-Match header. nil
+// when there is no header or it is *, a change is then made on the current
+// version whatever it is
+func IfMatch(c *gin.Context) (*int64, error) {
+	value := strings.TrimSpace(c.GetHeader("If-Match"))
+	if value == "" || value == "*" {
+		return nil, nil
+	}
+	value = strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || version < 0 {
+		return nil, ErrInvalidIfMatch
+	}
+	return &version, nil
+}
+
+// Filter condition on the version field of a document, documents stored
+// before they had a version are at version 0
+func Filter(version int64) bson.M {
+	if version == 0 {
+		return bson.M{"version": bson.M{"$in": []interface{}{0, nil}}}
+	}
+	return bson.M{"version": version}
+}
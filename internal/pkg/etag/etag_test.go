@@ -0,0 +1,57 @@
+package etag
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIfMatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		header  string
+		want    int64
+		wantNil bool
+		wantErr error
+	}{
+		{name: "should return nil without header", header: "", wantNil: true},
+		{name: "should return nil for any version", header: "*", wantNil: true},
+		{name: "should read a strong tag", header: `"3"`, want: 3},
+		{name: "should read a weak tag", header: `W/"12"`, want: 12},
+		{name: "should read a version without quotes", header: "0", want: 0},
+		{name: "should reject another tag", header: `"abc"`, wantNil: true, wantErr: ErrInvalidIfMatch},
+		{name: "should reject a negative version", header: `"-1"`, wantNil: true, wantErr: ErrInvalidIfMatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPatch, "/", nil)
+			if tt.header != "" {
+				c.Request.Header.Set("If-Match", tt.header)
+			}
+			got, err := IfMatch(c)
+			if err != tt.wantErr {
+				t.Fatalf("IfMatch() error = %v, want %v", err, tt.wantErr)
+			}
+			if (got == nil) != tt.wantNil || (got != nil && *got != tt.want) {
+				t.Errorf("IfMatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTag(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	Set(c, 7)
+	if got := recorder.Header().Get("ETag"); got != `"7"` {
+		t.Errorf("Set() ETag = %v, want %v", got, `"7"`)
+	}
+	version, err := IfMatch(&gin.Context{Request: &http.Request{Header: http.Header{"If-Match": {Tag(7)}}}})
+	if err != nil || *version != 7 {
+		t.Errorf("IfMatch(Tag(7)) = %v, %v, want 7", version, err)
+	}
+}
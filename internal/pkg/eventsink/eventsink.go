@@ -0,0 +1,29 @@
+package eventsink
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MaxBatch most records sent in one call, the limit of Kinesis PutRecords
+const MaxBatch = 500
+
+// Record event streamed to a destination
+type Record struct {
+	// Key partition key, events of a session share it so they stay ordered
+	Key   string
+	Value json.RawMessage
+}
+
+// Sink deliver records to one destination, in order
+type Sink interface {
+	Send(records []Record) error
+}
+
+// serviceError failure reported by a destination
+func serviceError(service, status string, body []byte) error {
+	if len(body) > 512 {
+		body = body[:512]
+	}
+	return fmt.Errorf("eventsink: %s: %s: %s", service, status, body)
+}
@@ -0,0 +1,129 @@
+package eventsink
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"analytics-api/internal/pkg/webhook"
+)
+
+var records = []Record{
+	{Key: "session-1", Value: json.RawMessage(`{"type":4}`)},
+	{Key: "session-1", Value: json.RawMessage(`{"type":5}`)},
+}
+
+func TestKafkaSend(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr bool
+	}{
+		{name: "should produce records", status: http.StatusOK, body: `{"offsets":[{"partition":0,"offset":1},{"partition":0,"offset":2}]}`},
+		{name: "should report record not produced", status: http.StatusOK, body: `{"offsets":[{"partition":0,"offset":1},{"error_code":50002,"error":"broker unavailable"}]}`, wantErr: true},
+		{name: "should report unknown topic", status: http.StatusNotFound, body: `{"error_code":40401}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/topics/events" || r.Header.Get("Content-Type") != "application/vnd.kafka.json.v2+json" {
+					t.Errorf("path = %q, Content-Type = %q", r.URL.Path, r.Header.Get("Content-Type"))
+				}
+				if username, password, _ := r.BasicAuth(); username != "user" || password != "pass" {
+					t.Errorf("basic auth = %q %q", username, password)
+				}
+				var body struct {
+					Records []kafkaRecord `json:"records"`
+				}
+				json.NewDecoder(r.Body).Decode(&body)
+				if len(body.Records) != 2 || body.Records[0].Key != "session-1" || string(body.Records[1].Value) != `{"type":5}` {
+					t.Errorf("records = %+v", body.Records)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			sink := NewKafka(server.URL+"/", "events", "user", "pass")
+			sink.HTTP = server.Client()
+			if err := sink.Send(records); (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKinesisSend(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr bool
+	}{
+		{name: "should put records", status: http.StatusOK, body: `{"FailedRecordCount":0,"Records":[{"SequenceNumber":"1"},{"SequenceNumber":"2"}]}`},
+		{name: "should report throttled records", status: http.StatusOK, body: `{"FailedRecordCount":1,"Records":[{"SequenceNumber":"1"},{"ErrorCode":"ProvisionedThroughputExceededException"}]}`, wantErr: true},
+		{name: "should report missing stream", status: http.StatusBadRequest, body: `{"__type":"ResourceNotFoundException"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-Amz-Target") != "Kinesis_20131202.PutRecords" || r.Header.Get("Authorization") == "" {
+					t.Errorf("X-Amz-Target = %q, Authorization = %q", r.Header.Get("X-Amz-Target"), r.Header.Get("Authorization"))
+				}
+				var body struct {
+					StreamName string          `json:"StreamName"`
+					Records    []kinesisRecord `json:"Records"`
+				}
+				json.NewDecoder(r.Body).Decode(&body)
+				if body.StreamName != "events" || len(body.Records) != 2 || string(body.Records[0].Data) != `{"type":4}` {
+					t.Errorf("body = %+v", body)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			sink := NewKinesis("us-east-1", "events", "AKID", "secret")
+			sink.Endpoint = server.URL
+			sink.HTTP = server.Client()
+			if err := sink.Send(records); (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookSend(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "should post signed batch", status: http.StatusNoContent},
+		{name: "should report rejected batch", status: http.StatusInternalServerError, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if r.Header.Get(webhook.SignatureHeader) != webhook.Sign("secret", body) || r.Header.Get(webhook.EventHeader) != BatchEvent {
+					t.Errorf("headers = %v", r.Header)
+				}
+				if string(body) != `{"events":[{"type":4},{"type":5}]}` {
+					t.Errorf("body = %s", body)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			sink := NewWebhook(server.URL, "secret")
+			sink.Client.HTTP = server.Client()
+			if err := sink.Send(records); (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package eventsink
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"analytics-api/internal/pkg/request"
+)
+
+// Kafka sink producing to a topic through a Kafka REST Proxy v2, as run by
+// Confluent or self hosted next to the cluster
+type Kafka struct {
+	URL      string
+	Topic    string
+	Username string
+	Password string
+	HTTP     *http.Client
+}
+
+// NewKafka sink of topic behind the proxy at proxyURL, username and password
+// are sent as basic auth when set
+func NewKafka(proxyURL, topic, username, password string) *Kafka {
+	return &Kafka{
+		URL:      strings.TrimRight(proxyURL, "/"),
+		Topic:    topic,
+		Username: username,
+		Password: password,
+		HTTP:     request.NewPublicClient(10 * time.Second),
+	}
+}
+
+type kafkaRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Send ...
+func (instance *Kafka) Send(records []Record) error {
+	body := struct {
+		Records []kafkaRecord `json:"records"`
+	}{}
+	for _, aRecord := range records {
+		body.Records = append(body.Records, kafkaRecord{Key: aRecord.Key, Value: aRecord.Value})
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, instance.URL+"/topics/"+url.PathEscape(instance.Topic), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if instance.Username != "" {
+		req.SetBasicAuth(instance.Username, instance.Password)
+	}
+	res, err := instance.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	reply, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if res.StatusCode != http.StatusOK {
+		return serviceError("kafka", res.Status, reply)
+	}
+
+	// the proxy replies 200 with the error of each record it could not produce
+	var result struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.Unmarshal(reply, &result); err != nil {
+		return err
+	}
+	for _, offset := range result.Offsets {
+		if offset.ErrorCode != nil {
+			return serviceError("kafka", res.Status, []byte(offset.Error))
+		}
+	}
+	return nil
+}
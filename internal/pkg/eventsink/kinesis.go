@@ -0,0 +1,102 @@
+package eventsink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"analytics-api/internal/pkg/awssig"
+	"analytics-api/internal/pkg/request"
+)
+
+// Kinesis sink putting records to a Kinesis data stream
+type Kinesis struct {
+	Region          string
+	Stream          string
+	AccessKeyID     string
+	SecretAccessKey string
+	Endpoint        string
+	HTTP            *http.Client
+}
+
+// NewKinesis sink of stream in region, signed with the access key of an IAM
+// user allowed kinesis:PutRecords on it
+func NewKinesis(region, stream, accessKeyID, secretAccessKey string) *Kinesis {
+	return &Kinesis{
+		Region:          region,
+		Stream:          stream,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		Endpoint:        "https://kinesis." + region + ".amazonaws.com",
+		HTTP:            request.NewPublicClient(10 * time.Second),
+	}
+}
+
+type kinesisRecord struct {
+	// Data []byte is base64 encoded by encoding/json as Kinesis expects
+	Data         []byte `json:"Data"`
+	PartitionKey string `json:"PartitionKey"`
+}
+
+// Send ...
+func (instance *Kinesis) Send(records []Record) error {
+	body := struct {
+		StreamName string          `json:"StreamName"`
+		Records    []kinesisRecord `json:"Records"`
+	}{StreamName: instance.Stream}
+	for _, aRecord := range records {
+		body.Records = append(body.Records, kinesisRecord{Data: aRecord.Value, PartitionKey: aRecord.Key})
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, instance.Endpoint+"/", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "Kinesis_20131202.PutRecords")
+	awssig.Sign(req, data, awssig.Credentials{
+		Region:          instance.Region,
+		Service:         "kinesis",
+		AccessKeyID:     instance.AccessKeyID,
+		SecretAccessKey: instance.SecretAccessKey,
+	}, time.Now())
+
+	res, err := instance.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	reply, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if res.StatusCode != http.StatusOK {
+		return serviceError("kinesis", res.Status, reply)
+	}
+
+	// PutRecords is not atomic, a throttled shard fails some records only
+	var result struct {
+		FailedRecordCount int `json:"FailedRecordCount"`
+		Records           []struct {
+			ErrorCode    string `json:"ErrorCode"`
+			ErrorMessage string `json:"ErrorMessage"`
+		} `json:"Records"`
+	}
+	if err := json.Unmarshal(reply, &result); err != nil {
+		return err
+	}
+	if result.FailedRecordCount > 0 {
+		for _, aRecord := range result.Records {
+			if aRecord.ErrorCode != "" {
+				return serviceError("kinesis", fmt.Sprintf("%d of %d records failed", result.FailedRecordCount, len(records)),
+					[]byte(aRecord.ErrorCode+": "+aRecord.ErrorMessage))
+			}
+		}
+		return serviceError("kinesis", fmt.Sprintf("%d of %d records failed", result.FailedRecordCount, len(records)), nil)
+	}
+	return nil
+}
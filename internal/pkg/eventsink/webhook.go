@@ -0,0 +1,35 @@
+package eventsink
+
+import (
+	"encoding/json"
+
+	"analytics-api/internal/pkg/webhook"
+)
+
+// BatchEvent name of the deliveries of the webhook sink
+const BatchEvent = "events.batch"
+
+// Webhook sink posting records as one signed JSON batch
+type Webhook struct {
+	URL    string
+	Secret string
+	Client *webhook.Client
+}
+
+// NewWebhook sink of the endpoint at url
+func NewWebhook(url, secret string) *Webhook {
+	return &Webhook{
+		URL:    url,
+		Secret: secret,
+		Client: webhook.NewClient(),
+	}
+}
+
+// Send ...
+func (instance *Webhook) Send(records []Record) error {
+	events := make([]json.RawMessage, 0, len(records))
+	for _, aRecord := range records {
+		events = append(events, aRecord.Value)
+	}
+	return instance.Client.Post(instance.URL, instance.Secret, BatchEvent, map[string]interface{}{"events": events})
+}
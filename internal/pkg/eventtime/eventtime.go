@@ -0,0 +1,63 @@
+package eventtime
+
+import "time"
+
+// MaxSkew largest accepted difference between client and server clocks
+const MaxSkew = 5 * time.Minute
+
+// Offset milliseconds to add to client timestamps so they follow the server
+// clock. sentAt is the client time the batch was sent at, clocks within
+// MaxSkew are trusted and get no offset
+func Offset(sentAt int64, now time.Time) int64 {
+	if sentAt <= 0 {
+		return 0
+	}
+	offset := now.UnixMilli() - sentAt
+	if offset > -MaxSkew.Milliseconds() && offset < MaxSkew.Milliseconds() {
+		return 0
+	}
+	return offset
+}
+
+// Valid timestamp in milliseconds is neither ahead of now by more than
+// MaxSkew nor older than retention
+func Valid(timestamp int64, now time.Time, retention time.Duration) bool {
+	if timestamp > now.Add(MaxSkew).UnixMilli() {
+		return false
+	}
+	return timestamp >= now.Add(-retention).UnixMilli()
+}
+
+// Time UTC time of a timestamp in milliseconds
+func Time(timestamp int64) time.Time {
+	return time.UnixMilli(timestamp).UTC()
+}
+
+// Lateness of an event by when it arrived
+type Lateness int
+
+const (
+	// OnTime arrived during the day it happened in
+	OnTime Lateness = iota
+	// Late arrived after its day ended but within the late arrival window,
+	// its day is adjusted for it
+	Late
+	// TooLate arrived once the window after its day was over, its day is
+	// settled and only records it
+	TooLate
+)
+
+// Classify lateness of an event of timestamp in milliseconds arriving at now.
+// Days are those of location, the timezone reports of the event are read in,
+// and window is how long after its day ended the day still takes events
+func Classify(timestamp int64, now time.Time, window time.Duration, location *time.Location) Lateness {
+	year, month, day := time.UnixMilli(timestamp).In(location).Date()
+	end := time.Date(year, month, day+1, 0, 0, 0, 0, location)
+	if now.Before(end) {
+		return OnTime
+	}
+	if !now.Before(end.Add(window)) {
+		return TooLate
+	}
+	return Late
+}
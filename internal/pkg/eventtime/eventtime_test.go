@@ -0,0 +1,83 @@
+package eventtime
+
+import (
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+
+func TestOffset(t *testing.T) {
+	tests := []struct {
+		name   string
+		sentAt int64
+		want   int64
+	}{
+		{name: "should not offset without sent time", sentAt: 0, want: 0},
+		{name: "should trust a clock within max skew", sentAt: now.Add(-time.Minute).UnixMilli(), want: 0},
+		{name: "should offset a clock behind", sentAt: now.Add(-time.Hour).UnixMilli(), want: time.Hour.Milliseconds()},
+		{name: "should offset a clock ahead", sentAt: now.Add(10 * time.Minute).UnixMilli(), want: -(10 * time.Minute).Milliseconds()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Offset(tt.sentAt, now); got != tt.want {
+				t.Errorf("Offset() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValid(t *testing.T) {
+	retention := 180 * 24 * time.Hour
+	tests := []struct {
+		name      string
+		timestamp int64
+		want      bool
+	}{
+		{name: "should accept now", timestamp: now.UnixMilli(), want: true},
+		{name: "should accept an event buffered offline", timestamp: now.AddDate(0, 0, -3).UnixMilli(), want: true},
+		{name: "should accept small skew ahead", timestamp: now.Add(time.Minute).UnixMilli(), want: true},
+		{name: "should reject an event in the future", timestamp: now.Add(time.Hour).UnixMilli(), want: false},
+		{name: "should reject an event older than retention", timestamp: now.Add(-retention - time.Second).UnixMilli(), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Valid(tt.timestamp, now, retention); got != tt.want {
+				t.Errorf("Valid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	window := 48 * time.Hour
+	saigon, _ := time.LoadLocation("Asia/Ho_Chi_Minh")
+	newYork, _ := time.LoadLocation("America/New_York")
+	at := func(value string, location *time.Location) int64 {
+		parsed, _ := time.ParseInLocation("2006-01-02 15:04", value, location)
+		return parsed.UnixMilli()
+	}
+	tests := []struct {
+		name      string
+		timestamp int64
+		location  *time.Location
+		want      Lateness
+	}{
+		{name: "should be on time during its day", timestamp: now.Add(-11 * time.Hour).UnixMilli(), location: time.UTC, want: OnTime},
+		{name: "should be on time ahead within skew", timestamp: now.Add(time.Minute).UnixMilli(), location: time.UTC, want: OnTime},
+		{name: "should be late after its day ended", timestamp: now.Add(-13 * time.Hour).UnixMilli(), location: time.UTC, want: Late},
+		{name: "should be late while its day ended less than the window ago", timestamp: at("2024-01-29 00:00", time.UTC), location: time.UTC, want: Late},
+		{name: "should be too late once its day ended the window ago", timestamp: at("2024-01-28 23:59", time.UTC), location: time.UTC, want: TooLate},
+		{name: "should be on time during its day in the timezone though the UTC day ended", timestamp: at("2024-01-31 01:00", saigon), location: saigon, want: OnTime},
+		{name: "should be late once its day in the timezone ended though the UTC day did not", timestamp: at("2024-01-30 22:00", newYork), location: newYork, want: Late},
+		{name: "should settle the day in the timezone, not the UTC one", timestamp: at("2024-01-29 05:00", saigon), location: saigon, want: Late},
+		{name: "should be too late once the day in the timezone is settled", timestamp: at("2024-01-28 20:00", saigon), location: saigon, want: TooLate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.timestamp, now, window, tt.location); got != tt.want {
+				t.Errorf("Classify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
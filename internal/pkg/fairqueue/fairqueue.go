@@ -0,0 +1,240 @@
+package fairqueue
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// strideUnit pass a key of weight 1 advances by per task, a key of weight w
+// advances by strideUnit / w so it runs w tasks for each of a key of weight 1
+const strideUnit = 1 << 20
+
+// Pool run the tasks of many keys, websites, on a fixed number of workers.
+// Keys waiting take turns by stride scheduling on their weight, so a key
+// with many tasks cannot starve those with few, and a key never runs more
+// tasks at once than its weight
+type Pool struct {
+	name    string
+	workers int
+	// ordered the tasks of a key run one at a time, in the order submitted
+	ordered bool
+
+	mu      sync.Mutex
+	keys    map[string]*queue
+	running int
+	// vtime pass of the last task started, keys waiting again start from it
+	// instead of catching up on the turns they did not need
+	vtime   uint64
+	pending sync.WaitGroup
+}
+
+type task struct {
+	run      func() error
+	queuedAt time.Time
+}
+
+// queue tasks of one key with its counters
+type queue struct {
+	key       string
+	weight    int
+	tasks     []task
+	running   int
+	pass      uint64
+	processed int64
+	failed    int64
+	busy      time.Duration
+	lastError string
+	lastRunAt time.Time
+}
+
+// KeyMetrics of the tasks of a key since the process started
+type KeyMetrics struct {
+	Key     string `json:"key"`
+	Weight  int    `json:"weight"`
+	Queued  int    `json:"queued"`
+	Running int    `json:"running"`
+	// LagMs time the oldest task queued has waited, 0 when none is
+	LagMs     int64      `json:"lag_ms"`
+	Processed int64      `json:"processed"`
+	Failed    int64      `json:"failed"`
+	AvgMs     int64      `json:"avg_ms"`
+	LastError string     `json:"last_error,omitempty"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+}
+
+// Metrics of a pool, its keys with the longest lag first
+type Metrics struct {
+	Name    string       `json:"name"`
+	Workers int          `json:"workers"`
+	Running int          `json:"running"`
+	Queued  int          `json:"queued"`
+	Keys    []KeyMetrics `json:"keys"`
+}
+
+var (
+	mu    sync.Mutex
+	pools = map[string]*Pool{}
+)
+
+// New pool of workers, registered under name for All. A pool of the same
+// name already created is returned instead, so its metrics carry on
+func New(name string, workers int) *Pool {
+	return register(name, workers, false)
+}
+
+// NewOrdered New for tasks of a key that must run in order, one at a time.
+// The weight of a key only sets how often it gets a worker
+func NewOrdered(name string, workers int) *Pool {
+	return register(name, workers, true)
+}
+
+func register(name string, workers int, ordered bool) *Pool {
+	mu.Lock()
+	defer mu.Unlock()
+	if aPool, ok := pools[name]; ok {
+		return aPool
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	aPool := &Pool{name: name, workers: workers, ordered: ordered, keys: map[string]*queue{}}
+	pools[name] = aPool
+	return aPool
+}
+
+// All metrics of every pool created, by name
+func All() []Metrics {
+	mu.Lock()
+	listPool := make([]*Pool, 0, len(pools))
+	for _, aPool := range pools {
+		listPool = append(listPool, aPool)
+	}
+	mu.Unlock()
+	sort.Slice(listPool, func(i, j int) bool { return listPool[i].name < listPool[j].name })
+
+	listMetrics := make([]Metrics, 0, len(listPool))
+	for _, aPool := range listPool {
+		listMetrics = append(listMetrics, aPool.Metrics())
+	}
+	return listMetrics
+}
+
+// Submit queue run under key, weight is the share of the workers of key
+// while others wait, at least 1. The last weight given applies
+func (instance *Pool) Submit(key string, weight int, run func() error) {
+	if weight < 1 {
+		weight = 1
+	}
+	instance.pending.Add(1)
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	aQueue, ok := instance.keys[key]
+	if !ok {
+		aQueue = &queue{key: key}
+		instance.keys[key] = aQueue
+	}
+	aQueue.weight = weight
+	if len(aQueue.tasks) == 0 && aQueue.running == 0 && aQueue.pass < instance.vtime {
+		aQueue.pass = instance.vtime
+	}
+	aQueue.tasks = append(aQueue.tasks, task{run: run, queuedAt: time.Now()})
+	instance.dispatch()
+}
+
+// Wait until every task submitted has run
+func (instance *Pool) Wait() {
+	instance.pending.Wait()
+}
+
+// dispatch start the next tasks while workers are free, the key with the
+// lowest pass first. Called with mu held
+func (instance *Pool) dispatch() {
+	for instance.running < instance.workers {
+		var next *queue
+		for _, aQueue := range instance.keys {
+			if len(aQueue.tasks) == 0 || aQueue.running >= instance.limit(aQueue) {
+				continue
+			}
+			if next == nil || aQueue.pass < next.pass || (aQueue.pass == next.pass && aQueue.key < next.key) {
+				next = aQueue
+			}
+		}
+		if next == nil {
+			return
+		}
+		aTask := next.tasks[0]
+		next.tasks = next.tasks[1:]
+		next.running++
+		instance.running++
+		instance.vtime = next.pass
+		next.pass += strideUnit / uint64(next.weight)
+		go instance.run(next, aTask)
+	}
+}
+
+// limit tasks of aQueue running at once
+func (instance *Pool) limit(aQueue *queue) int {
+	if instance.ordered {
+		return 1
+	}
+	return aQueue.weight
+}
+
+func (instance *Pool) run(aQueue *queue, aTask task) {
+	started := time.Now()
+	err := aTask.run()
+
+	instance.mu.Lock()
+	aQueue.running--
+	instance.running--
+	aQueue.busy += time.Since(started)
+	aQueue.lastRunAt = started
+	if err != nil {
+		aQueue.failed++
+		aQueue.lastError = err.Error()
+	} else {
+		aQueue.processed++
+	}
+	instance.dispatch()
+	instance.mu.Unlock()
+	instance.pending.Done()
+}
+
+// Metrics of the pool now
+func (instance *Pool) Metrics() Metrics {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	now := time.Now()
+	aMetrics := Metrics{Name: instance.name, Workers: instance.workers, Running: instance.running, Keys: []KeyMetrics{}}
+	for _, aQueue := range instance.keys {
+		aKeyMetrics := KeyMetrics{
+			Key:       aQueue.key,
+			Weight:    aQueue.weight,
+			Queued:    len(aQueue.tasks),
+			Running:   aQueue.running,
+			Processed: aQueue.processed,
+			Failed:    aQueue.failed,
+			LastError: aQueue.lastError,
+		}
+		if len(aQueue.tasks) > 0 {
+			aKeyMetrics.LagMs = now.Sub(aQueue.tasks[0].queuedAt).Milliseconds()
+		}
+		if ran := aQueue.processed + aQueue.failed; ran > 0 {
+			aKeyMetrics.AvgMs = aQueue.busy.Milliseconds() / ran
+		}
+		if !aQueue.lastRunAt.IsZero() {
+			lastRunAt := aQueue.lastRunAt
+			aKeyMetrics.LastRunAt = &lastRunAt
+		}
+		aMetrics.Queued += aKeyMetrics.Queued
+		aMetrics.Keys = append(aMetrics.Keys, aKeyMetrics)
+	}
+	sort.Slice(aMetrics.Keys, func(i, j int) bool {
+		if aMetrics.Keys[i].LagMs != aMetrics.Keys[j].LagMs {
+			return aMetrics.Keys[i].LagMs > aMetrics.Keys[j].LagMs
+		}
+		return aMetrics.Keys[i].Key < aMetrics.Keys[j].Key
+	})
+	return aMetrics
+}
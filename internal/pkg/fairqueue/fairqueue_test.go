@@ -0,0 +1,158 @@
+package fairqueue
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPoolOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		submit []string
+		weight map[string]int
+		want   []string
+	}{
+		{
+			name:   "should take turns between keys",
+			submit: []string{"a", "a", "a", "b", "c"},
+			weight: map[string]int{},
+			want:   []string{"b", "c", "a", "a"},
+		},
+		{
+			name:   "should give a key of weight 2 twice the turns",
+			submit: []string{"a", "a", "a", "b", "b", "b", "b"},
+			weight: map[string]int{"b": 2},
+			want:   []string{"b", "b", "a", "b", "b", "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aPool := &Pool{name: tt.name, workers: 1, keys: map[string]*queue{}}
+			release := make(chan struct{})
+			var mu sync.Mutex
+			var got []string
+			// the first task holds the only worker until every task is queued
+			aPool.Submit("first", 1, func() error {
+				<-release
+				return nil
+			})
+			for _, key := range tt.submit {
+				key := key
+				aPool.Submit(key, tt.weight[key], func() error {
+					mu.Lock()
+					got = append(got, key)
+					mu.Unlock()
+					return nil
+				})
+			}
+			close(release)
+			aPool.Wait()
+			// the first task of the first key queued runs first, its pass
+			// is the lowest once the first one ran
+			if got[0] != tt.submit[0] {
+				t.Fatalf("order = %v, want %v first", got, tt.submit[0])
+			}
+			if !reflect.DeepEqual(got[1:], tt.want) {
+				t.Errorf("order = %v, want %v then %v", got, tt.submit[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestPoolWeightBoundsConcurrency(t *testing.T) {
+	aPool := &Pool{name: "concurrency", workers: 4, keys: map[string]*queue{}}
+	var mu sync.Mutex
+	running, most := 0, 0
+	for i := 0; i < 6; i++ {
+		aPool.Submit("a", 2, func() error {
+			mu.Lock()
+			running++
+			if running > most {
+				most = running
+			}
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		})
+	}
+	aPool.Wait()
+	if most != 2 {
+		t.Errorf("most tasks running at once = %d, want 2", most)
+	}
+}
+
+func TestPoolOrderedRunsOneAtATime(t *testing.T) {
+	aPool := &Pool{name: "ordered", workers: 4, ordered: true, keys: map[string]*queue{}}
+	var mu sync.Mutex
+	var got []int
+	running, most := 0, 0
+	for i := 0; i < 5; i++ {
+		i := i
+		aPool.Submit("a", 3, func() error {
+			mu.Lock()
+			running++
+			if running > most {
+				most = running
+			}
+			got = append(got, i)
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		})
+	}
+	aPool.Wait()
+	if most != 1 || !reflect.DeepEqual(got, []int{0, 1, 2, 3, 4}) {
+		t.Errorf("ran %v with at most %d at once, want in order one at a time", got, most)
+	}
+}
+
+func TestPoolMetrics(t *testing.T) {
+	mu.Lock()
+	pools = map[string]*Pool{}
+	mu.Unlock()
+	aPool := New("metrics", 1)
+	release := make(chan struct{})
+	aPool.Submit("a", 1, func() error {
+		<-release
+		return errors.New("upload failed")
+	})
+	aPool.Submit("b", 3, func() error { return nil })
+	aPool.Submit("b", 3, func() error { return nil })
+	time.Sleep(5 * time.Millisecond)
+
+	queued := aPool.Metrics()
+	if queued.Running != 1 || queued.Queued != 2 {
+		t.Fatalf("Metrics() running %d queued %d, want 1 and 2", queued.Running, queued.Queued)
+	}
+	if queued.Keys[0].Key != "b" || queued.Keys[0].Queued != 2 || queued.Keys[0].Weight != 3 || queued.Keys[0].LagMs <= 0 {
+		t.Errorf("Metrics() keys = %+v, want b first with 2 queued and a lag", queued.Keys)
+	}
+
+	close(release)
+	aPool.Wait()
+	done := aPool.Metrics()
+	want := map[string][2]int64{"a": {0, 1}, "b": {2, 0}}
+	for _, aKey := range done.Keys {
+		if got := [2]int64{aKey.Processed, aKey.Failed}; got != want[aKey.Key] || aKey.Queued != 0 || aKey.LagMs != 0 {
+			t.Errorf("Metrics() key %+v, want processed and failed %v", aKey, want[aKey.Key])
+		}
+		if aKey.Key == "a" && aKey.LastError != "upload failed" {
+			t.Errorf("Metrics() last error = %q", aKey.LastError)
+		}
+	}
+	if all := All(); len(all) != 1 || all[0].Name != "metrics" {
+		t.Errorf("All() = %+v, want the metrics pool", all)
+	}
+	if New("metrics", 8) != aPool {
+		t.Error("New() of a name created already should return its pool")
+	}
+}
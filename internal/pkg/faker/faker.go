@@ -0,0 +1,183 @@
+package faker
+
+import (
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+// Visit synthetic visit of a website, its pages in the order they were seen
+type Visit struct {
+	UserAgent string
+	IP        net.IP
+	Pages     []PageView
+	// Form filled during the visit, nil when the visitor filled none
+	Form *Form
+}
+
+// PageView view of a page with how long it was read and how deep
+type PageView struct {
+	Path        string
+	Title       string
+	At          time.Time
+	EngagedMs   int64
+	ScrollDepth int
+}
+
+// Form started on a page, submitted or left
+type Form struct {
+	ID        string
+	Path      string
+	At        time.Time
+	Submitted bool
+}
+
+// userAgents browsers and devices in rough proportion of real traffic
+var userAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15",
+	"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+	"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+	"Mozilla/5.0 (Linux; Android 14; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+	"Mozilla/5.0 (Linux; Android 14; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+	"Mozilla/5.0 (iPad; CPU OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+	"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+}
+
+// ips public addresses of networks in Vietnam and abroad so the geo reports
+// have countries and cities
+var ips = []string{
+	"113.161.0.1", "113.161.0.1", "14.160.0.1", "14.160.0.1", "27.72.0.1", "42.112.0.1", "1.52.0.1", "171.224.0.1",
+	"8.8.8.8", "81.2.69.142", "202.12.27.33", "203.208.60.1", "185.86.151.11", "139.130.4.5",
+}
+
+// landings campaign tags some visits land with
+var landings = []string{
+	"", "", "", "", "",
+	"?utm_source=facebook&utm_medium=cpc&utm_campaign=launch",
+	"?utm_source=newsletter&utm_medium=email&utm_campaign=weekly",
+}
+
+type page struct {
+	path  string
+	title string
+	// next pages a visitor goes on to, none ends the visit
+	next []string
+}
+
+// pages site map visits walk through
+var pages = map[string]page{
+	"/":                     {"/", "Home", []string{"/pricing", "/blog", "/products", "/products/starter-kit", "/about"}},
+	"/pricing":              {"/pricing", "Pricing", []string{"/signup", "/contact", "/"}},
+	"/blog":                 {"/blog", "Blog", []string{"/blog/getting-started", "/blog/release-notes", "/blog/tips-for-growth"}},
+	"/blog/getting-started": {"/blog/getting-started", "Getting started", []string{"/signup", "/blog"}},
+	"/blog/release-notes":   {"/blog/release-notes", "Release notes", []string{"/blog", "/pricing"}},
+	"/blog/tips-for-growth": {"/blog/tips-for-growth", "Tips for growth", []string{"/blog", "/"}},
+	"/products":             {"/products", "Products", []string{"/products/starter-kit", "/products/pro-bundle", "/cart"}},
+	"/products/starter-kit": {"/products/starter-kit", "Starter kit", []string{"/cart", "/products"}},
+	"/products/pro-bundle":  {"/products/pro-bundle", "Pro bundle", []string{"/cart", "/products"}},
+	"/cart":                 {"/cart", "Cart", []string{"/checkout", "/products"}},
+	"/checkout":             {"/checkout", "Checkout", nil},
+	"/signup":               {"/signup", "Sign up", nil},
+	"/contact":              {"/contact", "Contact", nil},
+	"/about":                {"/about", "About us", []string{"/contact", "/"}},
+}
+
+// landingPages first page of visits, most land on the home page
+var landingPages = []string{"/", "/", "/", "/", "/blog/getting-started", "/blog/tips-for-growth", "/pricing", "/products/starter-kit"}
+
+// forms of the pages that have one
+var forms = map[string]string{
+	"/signup":   "signup",
+	"/contact":  "contact",
+	"/checkout": "checkout",
+}
+
+// hourWeights share of visits starting at each hour of the day, busiest in
+// working hours and the evening
+var hourWeights = []int{1, 1, 1, 1, 1, 2, 3, 5, 7, 8, 8, 7, 6, 7, 8, 8, 7, 6, 6, 7, 8, 6, 4, 2}
+
+// Generate count visits starting between from and to, the same rng seed gives
+// the same visits. Hours are weighted in location
+func Generate(rng *rand.Rand, count int, from, to time.Time, location *time.Location) []Visit {
+	if !from.Before(to) {
+		return nil
+	}
+	days := int(to.Sub(from).Hours()/24) + 1
+	visits := make([]Visit, 0, count)
+	for len(visits) < count {
+		day := from.In(location).AddDate(0, 0, rng.Intn(days))
+		start := time.Date(day.Year(), day.Month(), day.Day(), pick(rng, hourWeights), rng.Intn(60), rng.Intn(60), 0, location)
+		if start.Before(from) || !start.Before(to) {
+			continue
+		}
+		visits = append(visits, visit(rng, start))
+	}
+	return visits
+}
+
+// visit walk the site map from a landing page until a page without next
+// pages, or the visitor leaves
+func visit(rng *rand.Rand, start time.Time) Visit {
+	aVisit := Visit{
+		UserAgent: userAgents[rng.Intn(len(userAgents))],
+		IP:        net.ParseIP(ips[rng.Intn(len(ips))]),
+	}
+
+	at := start
+	path := landingPages[rng.Intn(len(landingPages))]
+	landing := landings[rng.Intn(len(landings))]
+	for {
+		current := pages[path]
+		view := PageView{
+			Path:        current.path + landing,
+			Title:       current.title,
+			At:          at,
+			EngagedMs:   int64(5000 + rng.Intn(90000)),
+			ScrollDepth: 20 + rng.Intn(81),
+		}
+		landing = ""
+		aVisit.Pages = append(aVisit.Pages, view)
+
+		if formID, ok := forms[path]; ok && rng.Intn(3) > 0 {
+			aVisit.Form = &Form{
+				ID:        formID,
+				Path:      path,
+				At:        at.Add(3 * time.Second),
+				Submitted: rng.Intn(3) > 0,
+			}
+		}
+
+		// about half of the visitors leave after each page
+		if len(current.next) == 0 || rng.Intn(2) == 0 {
+			break
+		}
+		at = at.Add(time.Duration(view.EngagedMs)*time.Millisecond + time.Duration(rng.Intn(10))*time.Second)
+		path = current.next[rng.Intn(len(current.next))]
+	}
+	return aVisit
+}
+
+// pick index of weights with a chance proportional to its weight
+func pick(rng *rand.Rand, weights []int) int {
+	total := 0
+	for _, weight := range weights {
+		total += weight
+	}
+	n := rng.Intn(total)
+	for i, weight := range weights {
+		if n < weight {
+			return i
+		}
+		n -= weight
+	}
+	return len(weights) - 1
+}
+
+// PathOf path of a page view without its campaign tags
+func PathOf(view PageView) string {
+	path, _, _ := strings.Cut(view.Path, "?")
+	return path
+}
@@ -0,0 +1,97 @@
+package faker
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGenerate(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	type args struct {
+		count    int
+		from     time.Time
+		to       time.Time
+		location *time.Location
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{
+			name: "should start visits within range",
+			args: args{count: 200, from: from, to: to, location: time.UTC},
+		},
+		{
+			name: "should start visits within range of another timezone",
+			args: args{count: 200, from: from, to: to, location: time.FixedZone("ICT", 7*3600)},
+		},
+		{
+			name: "should start visits within a single day",
+			args: args{count: 50, from: from, to: from.AddDate(0, 0, 1), location: time.UTC},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			visits := Generate(rand.New(rand.NewSource(1)), tt.args.count, tt.args.from, tt.args.to, tt.args.location)
+			if len(visits) != tt.args.count {
+				t.Fatalf("Generate() = %v visits, want %v", len(visits), tt.args.count)
+			}
+			for _, aVisit := range visits {
+				if len(aVisit.Pages) == 0 {
+					t.Fatalf("Generate() visit without pages")
+				}
+				if aVisit.IP == nil || aVisit.UserAgent == "" {
+					t.Fatalf("Generate() visit without device, %+v", aVisit)
+				}
+				start := aVisit.Pages[0].At
+				if start.Before(tt.args.from) || !start.Before(tt.args.to) {
+					t.Fatalf("Generate() visit starting %v outside %v - %v", start, tt.args.from, tt.args.to)
+				}
+				for i := 1; i < len(aVisit.Pages); i++ {
+					if !aVisit.Pages[i].At.After(aVisit.Pages[i-1].At) {
+						t.Fatalf("Generate() pages out of order, %+v", aVisit.Pages)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateSeed(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	first := Generate(rand.New(rand.NewSource(42)), 20, from, to, time.UTC)
+	second := Generate(rand.New(rand.NewSource(42)), 20, from, to, time.UTC)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Generate() with the same seed differ")
+	}
+}
+
+func TestPathOf(t *testing.T) {
+	tests := []struct {
+		name string
+		view PageView
+		want string
+	}{
+		{
+			name: "should keep path without query",
+			view: PageView{Path: "/pricing"},
+			want: "/pricing",
+		},
+		{
+			name: "should drop campaign tags",
+			view: PageView{Path: "/?utm_source=facebook&utm_medium=cpc"},
+			want: "/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PathOf(tt.view); got != tt.want {
+				t.Errorf("PathOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package fields
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Parse read the fields query parameter, a comma separated list of JSON names.
+// Nested attributes are selected with a dotted path like meta_data.country
+func Parse(value string) []string {
+	var list []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field != "" {
+			list = append(list, field)
+		}
+	}
+	return list
+}
+
+// Select keep only the listed fields of v as it would be encoded to JSON. Lists
+// are shaped item by item, and v is returned unchanged without fields
+func Select(v interface{}, list []string) (interface{}, error) {
+	if len(list) == 0 {
+		return v, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	return shape(decoded, tree(list)), nil
+}
+
+// node selected fields, a nil node keeps the whole value
+type node map[string]node
+
+// tree of the fields of list, the same whatever their order: paths are
+// cleaned of empty parts and sorted, so a parent selected whole comes before
+// its children and wins over them
+func tree(list []string) node {
+	var paths []string
+	for _, field := range list {
+		var parts []string
+		for _, part := range strings.Split(field, ".") {
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) > 0 {
+			paths = append(paths, strings.Join(parts, "."))
+		}
+	}
+	sort.Strings(paths)
+
+	root := node{}
+	for _, path := range paths {
+		current := root
+		parts := strings.Split(path, ".")
+		for i, part := range parts {
+			child, ok := current[part]
+			if ok && child == nil {
+				// a parent field is already selected whole
+				break
+			}
+			if i == len(parts)-1 {
+				current[part] = nil
+				break
+			}
+			if !ok {
+				child = node{}
+				current[part] = child
+			}
+			current = child
+		}
+	}
+	return root
+}
+
+func shape(value interface{}, selected node) interface{} {
+	if selected == nil {
+		return value
+	}
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for name, child := range selected {
+			if item, ok := typed[name]; ok {
+				result[name] = shape(item, child)
+			}
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, 0, len(typed))
+		for _, item := range typed {
+			result = append(result, shape(item, selected))
+		}
+		return result
+	default:
+		return value
+	}
+}
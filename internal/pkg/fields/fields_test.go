@@ -0,0 +1,135 @@
+package fields
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{name: "should return nil when empty", value: "", want: nil},
+		{name: "should split and trim", value: "id, url ,,meta_data.country", want: []string{"id", "url", "meta_data.country"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	type metaData struct {
+		ID      string `json:"id"`
+		Country string `json:"country"`
+	}
+	type item struct {
+		ID       string   `json:"id"`
+		URL      string   `json:"url"`
+		MetaData metaData `json:"meta_data"`
+	}
+	value := []item{
+		{ID: "1", URL: "https://a.com", MetaData: metaData{ID: "s1", Country: "Vietnam"}},
+		{ID: "2", URL: "https://b.com", MetaData: metaData{ID: "s2", Country: "Japan"}},
+	}
+	tests := []struct {
+		name  string
+		value interface{}
+		list  []string
+		want  string
+	}{
+		{
+			name:  "should keep the value without fields",
+			value: value[0],
+			list:  nil,
+			want:  `{"id":"1","url":"https://a.com","meta_data":{"id":"s1","country":"Vietnam"}}`,
+		},
+		{
+			name:  "should keep top level fields of an object",
+			value: value[0],
+			list:  []string{"url", "unknown"},
+			want:  `{"url":"https://a.com"}`,
+		},
+		{
+			name:  "should shape each item of a list",
+			value: value,
+			list:  []string{"id", "meta_data.country"},
+			want:  `[{"id":"1","meta_data":{"country":"Vietnam"}},{"id":"2","meta_data":{"country":"Japan"}}]`,
+		},
+		{
+			name:  "should keep a whole object selected with its children",
+			value: value[0],
+			list:  []string{"meta_data", "meta_data.country"},
+			want:  `{"meta_data":{"country":"Vietnam","id":"s1"}}`,
+		},
+		{
+			name:  "should keep a whole object selected after its children",
+			value: value[0],
+			list:  []string{"meta_data.country", "meta_data"},
+			want:  `{"meta_data":{"country":"Vietnam","id":"s1"}}`,
+		},
+		{
+			name:  "should ignore empty parts of a path",
+			value: value[0],
+			list:  []string{"meta_data..country", "id."},
+			want:  `{"id":"1","meta_data":{"country":"Vietnam"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Select(tt.value, tt.list)
+			if err != nil {
+				t.Fatalf("Select() error = %v", err)
+			}
+			gotJSON, _ := json.Marshal(got)
+			var gotValue, wantValue interface{}
+			json.Unmarshal(gotJSON, &gotValue)
+			json.Unmarshal([]byte(tt.want), &wantValue)
+			if !reflect.DeepEqual(gotValue, wantValue) {
+				t.Errorf("Select() = %s, want %s", gotJSON, tt.want)
+			}
+		})
+	}
+}
+
+func TestTree(t *testing.T) {
+	tests := []struct {
+		name string
+		list []string
+		want node
+	}{
+		{
+			name: "should build the same tree with a parent first",
+			list: []string{"a", "a.b.c", "d.e"},
+			want: node{"a": nil, "d": node{"e": nil}},
+		},
+		{
+			name: "should build the same tree with a parent last",
+			list: []string{"d.e", "a.b.c", "a"},
+			want: node{"a": nil, "d": node{"e": nil}},
+		},
+		{
+			name: "should merge siblings given apart",
+			list: []string{"a.c", "d", "a.b"},
+			want: node{"a": node{"b": nil, "c": nil}, "d": nil},
+		},
+		{
+			name: "should drop empty fields",
+			list: []string{".", "", "a"},
+			want: node{"a": nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tree(tt.list); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tree() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
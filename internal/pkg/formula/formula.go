@@ -0,0 +1,253 @@
+package formula
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxLength longest expression parsed
+const MaxLength = 200
+
+// maxDepth deepest nesting of parentheses and unary minus
+const maxDepth = 20
+
+// ErrSyntax expression that does not parse
+var ErrSyntax = errors.New("invalid expression")
+
+// Formula arithmetic over named variables, numbers, + - * / and parentheses
+type Formula struct {
+	root node
+}
+
+type node interface {
+	eval(values map[string]float64) float64
+	variables(seen map[string]bool)
+}
+
+type number float64
+
+func (instance number) eval(map[string]float64) float64 { return float64(instance) }
+func (instance number) variables(map[string]bool)       {}
+
+type variable string
+
+func (instance variable) eval(values map[string]float64) float64 { return values[string(instance)] }
+func (instance variable) variables(seen map[string]bool)         { seen[string(instance)] = true }
+
+type negate struct {
+	operand node
+}
+
+func (instance negate) eval(values map[string]float64) float64 {
+	return -instance.operand.eval(values)
+}
+func (instance negate) variables(seen map[string]bool) { instance.operand.variables(seen) }
+
+type binary struct {
+	op          byte
+	left, right node
+}
+
+func (instance binary) eval(values map[string]float64) float64 {
+	left, right := instance.left.eval(values), instance.right.eval(values)
+	switch instance.op {
+	case '+':
+		return left + right
+	case '-':
+		return left - right
+	case '*':
+		return left * right
+	default:
+		// a zero divisor gives an infinity or NaN, reported by Eval
+		return left / right
+	}
+}
+
+func (instance binary) variables(seen map[string]bool) {
+	instance.left.variables(seen)
+	instance.right.variables(seen)
+}
+
+// Parse expression, variables are identifiers of lowercase letters, digits
+// and underscores not starting with a digit
+func Parse(expression string) (*Formula, error) {
+	if len(expression) > MaxLength {
+		return nil, fmt.Errorf("%w: longer than %d characters", ErrSyntax, MaxLength)
+	}
+	aParser := &parser{text: expression}
+	root, err := aParser.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	aParser.skipSpaces()
+	if aParser.pos < len(aParser.text) {
+		return nil, aParser.errorf("unexpected %q", aParser.text[aParser.pos])
+	}
+	return &Formula{root: root}, nil
+}
+
+// Variables names used by the formula, sorted
+func (instance *Formula) Variables() []string {
+	seen := map[string]bool{}
+	instance.root.variables(seen)
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Eval value of the formula with values of its variables, missing ones count
+// as zero. False when it has none, a divisor being zero
+func (instance *Formula) Eval(values map[string]float64) (float64, bool) {
+	value := instance.root.eval(values)
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return 0, false
+	}
+	return value, true
+}
+
+// Identifier name as a variable: lowercased, with each run of other
+// characters than letters and digits made an underscore
+func Identifier(name string) string {
+	var builder strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			if underscore && builder.Len() > 0 {
+				builder.WriteByte('_')
+			}
+			underscore = false
+			builder.WriteRune(r)
+			continue
+		}
+		underscore = true
+	}
+	identifier := builder.String()
+	if identifier != "" && identifier[0] >= '0' && identifier[0] <= '9' {
+		identifier = "_" + identifier
+	}
+	return identifier
+}
+
+// parser recursive descent over text, * and / bind tighter than + and -
+type parser struct {
+	text string
+	pos  int
+}
+
+func (instance *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s at %d", ErrSyntax, fmt.Sprintf(format, args...), instance.pos+1)
+}
+
+func (instance *parser) skipSpaces() {
+	for instance.pos < len(instance.text) && instance.text[instance.pos] == ' ' {
+		instance.pos++
+	}
+}
+
+// peek next operator or parenthesis, 0 at the end of text
+func (instance *parser) peek() byte {
+	instance.skipSpaces()
+	if instance.pos >= len(instance.text) {
+		return 0
+	}
+	return instance.text[instance.pos]
+}
+
+func (instance *parser) expression(depth int) (node, error) {
+	left, err := instance.term(depth)
+	if err != nil {
+		return nil, err
+	}
+	for op := instance.peek(); op == '+' || op == '-'; op = instance.peek() {
+		instance.pos++
+		right, err := instance.term(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (instance *parser) term(depth int) (node, error) {
+	left, err := instance.unary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for op := instance.peek(); op == '*' || op == '/'; op = instance.peek() {
+		instance.pos++
+		right, err := instance.unary(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (instance *parser) unary(depth int) (node, error) {
+	if depth > maxDepth {
+		return nil, instance.errorf("nested deeper than %d", maxDepth)
+	}
+	switch c := instance.peek(); {
+	case c == 0:
+		return nil, instance.errorf("unexpected end")
+	case c == '-':
+		instance.pos++
+		operand, err := instance.unary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return negate{operand: operand}, nil
+	case c == '(':
+		instance.pos++
+		inner, err := instance.expression(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if instance.peek() != ')' {
+			return nil, instance.errorf("missing )")
+		}
+		instance.pos++
+		return inner, nil
+	case c >= '0' && c <= '9' || c == '.':
+		return instance.number()
+	case c >= 'a' && c <= 'z' || c == '_':
+		return instance.variable(), nil
+	default:
+		return nil, instance.errorf("unexpected %q", c)
+	}
+}
+
+func (instance *parser) number() (node, error) {
+	start := instance.pos
+	for instance.pos < len(instance.text) && (instance.text[instance.pos] >= '0' && instance.text[instance.pos] <= '9' || instance.text[instance.pos] == '.') {
+		instance.pos++
+	}
+	text := instance.text[start:instance.pos]
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		instance.pos = start
+		return nil, instance.errorf("invalid number %q", text)
+	}
+	return number(value), nil
+}
+
+func (instance *parser) variable() node {
+	start := instance.pos
+	for instance.pos < len(instance.text) {
+		c := instance.text[instance.pos]
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_') {
+			break
+		}
+		instance.pos++
+	}
+	return variable(instance.text[start:instance.pos])
+}
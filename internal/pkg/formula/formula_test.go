@@ -0,0 +1,97 @@
+package formula
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	values := map[string]float64{"signups": 5, "visitors": 200, "pageviews": 600, "bounces": 0}
+	tests := []struct {
+		name       string
+		expression string
+		want       float64
+		wantOK     bool
+	}{
+		{name: "should divide variables", expression: "signups / visitors", want: 0.025, wantOK: true},
+		{name: "should multiply before adding", expression: "1 + pageviews / visitors * 2", want: 7, wantOK: true},
+		{name: "should follow parentheses", expression: "(signups + 5) * 10", want: 100, wantOK: true},
+		{name: "should negate", expression: "-signups - -1", want: -4, wantOK: true},
+		{name: "should count missing variable as zero", expression: "visitors + refunds", want: 200, wantOK: true},
+		{name: "should not evaluate division by zero", expression: "signups / bounces", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aFormula, err := Parse(tt.expression)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got, ok := aFormula.Eval(values)
+			if ok != tt.wantOK {
+				t.Fatalf("Eval() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("Eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		wantErr    bool
+	}{
+		{name: "should parse decimals", expression: "0.5 * visitors"},
+		{name: "should reject dangling operator", expression: "signups /", wantErr: true},
+		{name: "should reject unclosed parenthesis", expression: "(signups / visitors", wantErr: true},
+		{name: "should reject uppercase names", expression: "Signups / visitors", wantErr: true},
+		{name: "should reject trailing text", expression: "signups visitors", wantErr: true},
+		{name: "should reject empty expression", expression: "", wantErr: true},
+		{name: "should reject invalid number", expression: "1.2.3", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.expression)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrSyntax) {
+				t.Errorf("Parse() error = %v, want ErrSyntax", err)
+			}
+		})
+	}
+}
+
+func TestVariables(t *testing.T) {
+	aFormula, err := Parse("(signups + signups) / visitors * 100")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := []string{"signups", "visitors"}
+	if got := aFormula.Variables(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Variables() = %v, want %v", got, want)
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "should lowercase and join words", in: " Signup Rate ", want: "signup_rate"},
+		{name: "should collapse punctuation", in: "Trial -> Paid!", want: "trial_paid"},
+		{name: "should not start with a digit", in: "2nd visit", want: "_2nd_visit"},
+		{name: "should drop name without letters", in: "%%", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Identifier(tt.in); got != tt.want {
+				t.Errorf("Identifier() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package httperr
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Codes shared by every delivery, the others are declared next to the
+// errors of their module
+const (
+	// CodeInvalidRequest body or query breaking what the handler expects
+	CodeInvalidRequest = "invalid_request"
+	// CodeUnauthorized request not signed in, or with a token no longer valid
+	CodeUnauthorized = "unauthorized"
+	// CodeForbidden request signed in but not allowed
+	CodeForbidden = "forbidden"
+	// CodeInternal failure of the server, the message tells what it was doing
+	CodeInternal = "internal_error"
+)
+
+// Error body of every error response. Code is stable for clients to branch
+// on, Message is for people and translated, Details depend on the code
+type Error struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func (instance *Error) Error() string {
+	return instance.Message
+}
+
+// Abort answer status with an Error of code and message, the handlers after
+// are skipped
+func Abort(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, &Error{Code: code, Message: message})
+}
+
+// AbortWithDetails Abort with the details of the error
+func AbortWithDetails(c *gin.Context, status int, code, message string, details map[string]interface{}) {
+	c.AbortWithStatusJSON(status, &Error{Code: code, Message: message, Details: details})
+}
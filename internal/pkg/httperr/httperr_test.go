@@ -0,0 +1,53 @@
+package httperr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAbort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		status  int
+		code    string
+		message string
+		details map[string]interface{}
+		want    string
+	}{
+		{
+			name:    "should leave out empty details",
+			status:  http.StatusNotFound,
+			code:    "website_not_found",
+			message: "this website not exists",
+			want:    `{"code":"website_not_found","message":"this website not exists"}`,
+		},
+		{
+			name:    "should write details",
+			status:  http.StatusPaymentRequired,
+			code:    "website_quota_exceeded",
+			message: "the plan of this account allows no more websites",
+			details: map[string]interface{}{"limit": 3, "plan": "free"},
+			want:    `{"code":"website_quota_exceeded","message":"the plan of this account allows no more websites","details":{"limit":3,"plan":"free"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			AbortWithDetails(c, tt.status, tt.code, tt.message, tt.details)
+			if recorder.Code != tt.status {
+				t.Fatalf("AbortWithDetails() status = %d, want %d", recorder.Code, tt.status)
+			}
+			if !c.IsAborted() {
+				t.Errorf("AbortWithDetails() did not abort")
+			}
+			if got := recorder.Body.String(); got != tt.want {
+				t.Errorf("AbortWithDetails() body = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// English messages are written in, it needs no catalog
+	English = "en"
+	// Vietnamese ...
+	Vietnamese = "vi"
+)
+
+// Locales supported, the first is the default
+var Locales = []string{English, Vietnamese}
+
+//go:embed locales/*.json
+var files embed.FS
+
+// catalogs translation of each english message by locale
+var catalogs = map[string]map[string]string{}
+
+func init() {
+	for _, locale := range Locales[1:] {
+		data, err := files.ReadFile("locales/" + locale + ".json")
+		if err != nil {
+			panic(err)
+		}
+		catalog := map[string]string{}
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			panic(err)
+		}
+		catalogs[locale] = catalog
+	}
+}
+
+// Supported whether locale has translations
+func Supported(locale string) bool {
+	for _, supported := range Locales {
+		if locale == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// T message in locale, messages without a translation are left in english
+func T(locale, message string) string {
+	if translated, ok := catalogs[locale][message]; ok {
+		return translated
+	}
+	return message
+}
+
+// Match best supported locale of an Accept-Language header, english when
+// none of its languages is supported
+func Match(acceptLanguage string) string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+	var languages []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		// vi-VN is served by vi
+		base, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if q > 0 && Supported(base) {
+			languages = append(languages, weighted{base, q})
+		}
+	}
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].q > languages[j].q
+	})
+	if len(languages) == 0 {
+		return English
+	}
+	return languages[0].locale
+}
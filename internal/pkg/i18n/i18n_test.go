@@ -0,0 +1,111 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestT(t *testing.T) {
+	type args struct {
+		locale  string
+		message string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "should translate message with a translation",
+			args: args{
+				locale:  Vietnamese,
+				message: "this website not exists",
+			},
+			want: "website này không tồn tại",
+		},
+		{
+			name: "should leave english message as is",
+			args: args{
+				locale:  English,
+				message: "this website not exists",
+			},
+			want: "this website not exists",
+		},
+		{
+			name: "should fall back to english without translation",
+			args: args{
+				locale:  Vietnamese,
+				message: "no such message",
+			},
+			want: "no such message",
+		},
+		{
+			name: "should fall back to english for unknown locale",
+			args: args{
+				locale:  "fr",
+				message: "this website not exists",
+			},
+			want: "this website not exists",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := T(tt.args.locale, tt.args.message); got != tt.want {
+				t.Errorf("T() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		want           string
+	}{
+		{
+			name:           "should default to english for empty header",
+			acceptLanguage: "",
+			want:           English,
+		},
+		{
+			name:           "should match region tag by its language",
+			acceptLanguage: "vi-VN,vi;q=0.9,en;q=0.8",
+			want:           Vietnamese,
+		},
+		{
+			name:           "should prefer the highest weight",
+			acceptLanguage: "vi;q=0.3,en;q=0.7",
+			want:           English,
+		},
+		{
+			name:           "should skip unsupported languages",
+			acceptLanguage: "fr-FR,de;q=0.9,vi;q=0.5",
+			want:           Vietnamese,
+		},
+		{
+			name:           "should skip languages refused with q=0",
+			acceptLanguage: "vi;q=0",
+			want:           English,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Match(tt.acceptLanguage); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCatalogs translations of messages formatted with values, like the
+// emails, must keep their verbs for the values to land in them
+func TestCatalogs(t *testing.T) {
+	for locale, catalog := range catalogs {
+		for message, translated := range catalog {
+			if strings.Count(message, "%s") != strings.Count(translated, "%s") {
+				t.Errorf("%s translation of %q does not keep its %%s", locale, message)
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package ipallow
+
+import (
+	"net"
+	"strings"
+	"sync"
+)
+
+// List CIDR allow-list safe for concurrent use, an empty list allows every address
+type List struct {
+	mu    sync.RWMutex
+	nets  []*net.IPNet
+	cidrs []string
+}
+
+// New build list from CIDRs or plain IP addresses
+func New(cidrs []string) (*List, error) {
+	list := &List{}
+	if err := list.Set(cidrs); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Parse validate CIDRs, plain IP addresses are turned into single host networks
+func Parse(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, &net.ParseError{Type: "IP address", Text: cidr}
+			}
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// Set replace the allowed networks
+func (instance *List) Set(cidrs []string) error {
+	nets, err := Parse(cidrs)
+	if err != nil {
+		return err
+	}
+
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	instance.nets = nets
+	instance.cidrs = make([]string, 0, len(nets))
+	for _, ipNet := range nets {
+		instance.cidrs = append(instance.cidrs, ipNet.String())
+	}
+	return nil
+}
+
+// CIDRs normalized allowed networks
+func (instance *List) CIDRs() []string {
+	instance.mu.RLock()
+	defer instance.mu.RUnlock()
+	return instance.cidrs
+}
+
+// Allowed check ip against the list
+func (instance *List) Allowed(ip net.IP) bool {
+	instance.mu.RLock()
+	defer instance.mu.RUnlock()
+	return contains(instance.nets, ip)
+}
+
+// Contains check ip against networks, an empty list contains every address
+func Contains(nets []*net.IPNet, ip net.IP) bool {
+	return contains(nets, ip)
+}
+
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	if len(nets) == 0 {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
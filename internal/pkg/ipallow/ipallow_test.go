@@ -0,0 +1,71 @@
+package ipallow
+
+import (
+	"net"
+	"testing"
+)
+
+func TestListAllowed(t *testing.T) {
+	type args struct {
+		cidrs []string
+		ip    string
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{
+			name: "should allow every address when list is empty",
+			args: args{
+				cidrs: nil,
+				ip:    "203.0.113.7",
+			},
+			want: true,
+		},
+		{
+			name: "should allow address inside cidr",
+			args: args{
+				cidrs: []string{"10.8.0.0/16"},
+				ip:    "10.8.3.4",
+			},
+			want: true,
+		},
+		{
+			name: "should reject address outside cidr",
+			args: args{
+				cidrs: []string{"10.8.0.0/16"},
+				ip:    "10.9.0.1",
+			},
+			want: false,
+		},
+		{
+			name: "should accept plain ip address",
+			args: args{
+				cidrs: []string{"2001:db8::1"},
+				ip:    "2001:db8::1",
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := New(tt.args.cidrs)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if got := list.Allowed(net.ParseIP(tt.args.ip)); got != tt.want {
+				t.Errorf("Allowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	if _, err := Parse([]string{"10.0.0.0/33"}); err == nil {
+		t.Errorf("Parse() should reject invalid cidr")
+	}
+	if _, err := Parse([]string{"office"}); err == nil {
+		t.Errorf("Parse() should reject invalid ip address")
+	}
+}
@@ -0,0 +1,55 @@
+package keystore
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Kinds of stores, picked with KEY_STORE
+const (
+	KindRedis  = "redis"
+	KindMongo  = "mongo"
+	KindMemory = "memory"
+)
+
+// ErrNotFound key not set, expired, or list empty
+var ErrNotFound = errors.New("key not found")
+
+// Store keys shared by the replicas: the tokens of sign ins, caches, one time
+// tokens, the flags of the instance, queues and the messages of live
+// streams. Values, sets of members and counters expire, lists are kept until
+// they are emptied
+type Store interface {
+	// Set value of key for ttl, until it is deleted when ttl is 0
+	Set(key, value string, ttl time.Duration) error
+	// SetNX set value of key for ttl when key is not set, false when it was
+	SetNX(key, value string, ttl time.Duration) (bool, error)
+	// Get value of key, ErrNotFound when it is not set
+	Get(key string) (string, error)
+	// Take value of key and delete it at once, so only one caller gets it.
+	// ErrNotFound when it is not set
+	Take(key string) (string, error)
+	Exists(key string) (bool, error)
+	Del(keys ...string) error
+	// Incr add one to the counter of key and set it to expire after ttl,
+	// returns the count
+	Incr(key string, ttl time.Duration) (int64, error)
+	// Add members to the set of key and set it to expire after ttl
+	Add(key string, ttl time.Duration, members ...string) error
+	// Members of the set of key, none when it is not set
+	Members(key string) ([]string, error)
+	// Append value at the end of the list of key unless it holds limit
+	// values already, false then
+	Append(key, value string, limit int64) (bool, error)
+	// Prepend value in front of the list of key
+	Prepend(key, value string) error
+	// PopFront first value of the list of key, removed from it. ErrNotFound
+	// when the list is empty
+	PopFront(key string) (string, error)
+	// Publish payload to the subscribers of channel on every replica
+	Publish(channel, payload string) error
+	// Subscribe payloads published to channel from now on, until ctx is
+	// done. The channel is closed then
+	Subscribe(ctx context.Context, channel string) (<-chan string, error)
+}
@@ -0,0 +1,258 @@
+package keystore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery writes between two removals of the expired keys
+const sweepEvery = 1000
+
+// subscriberBuffer payloads a subscriber may fall behind by before it misses
+// some
+const subscriberBuffer = 64
+
+// Memory store keys in the process, for tests and single binary setups.
+// Every key is lost when the process restarts, and replicas do not see the
+// keys of each other
+type Memory struct {
+	mutex   sync.Mutex
+	entries map[string]*memoryEntry
+	lists   map[string][]string
+	// subscribers of each channel, by the context of their subscription
+	subscribers map[string]map[context.Context]chan string
+	writes      int
+	// now the clock, replaced by tests
+	now func() time.Time
+}
+
+type memoryEntry struct {
+	value     string
+	members   map[string]bool
+	count     int64
+	expiresAt time.Time
+}
+
+// NewMemory ...
+func NewMemory() *Memory {
+	return &Memory{
+		entries:     map[string]*memoryEntry{},
+		lists:       map[string][]string{},
+		subscribers: map[string]map[context.Context]chan string{},
+		now:         time.Now,
+	}
+}
+
+// expiry time an entry set now for ttl expires at, zero when it is kept
+// until deleted
+func (instance *Memory) expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return instance.now().Add(ttl)
+}
+
+// expired anEntry is past its expiry at now
+func expired(anEntry *memoryEntry, now time.Time) bool {
+	return !anEntry.expiresAt.IsZero() && !now.Before(anEntry.expiresAt)
+}
+
+// live entry of key not expired yet, nil otherwise. Callers hold the mutex
+func (instance *Memory) live(key string) *memoryEntry {
+	anEntry, ok := instance.entries[key]
+	if !ok {
+		return nil
+	}
+	if expired(anEntry, instance.now()) {
+		delete(instance.entries, key)
+		return nil
+	}
+	return anEntry
+}
+
+// wrote count a write and sweep the expired keys once in a while. Callers
+// hold the mutex
+func (instance *Memory) wrote() {
+	instance.writes++
+	if instance.writes < sweepEvery {
+		return
+	}
+	instance.writes = 0
+	now := instance.now()
+	for key, anEntry := range instance.entries {
+		if expired(anEntry, now) {
+			delete(instance.entries, key)
+		}
+	}
+}
+
+func (instance *Memory) Set(key, value string, ttl time.Duration) error {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	instance.entries[key] = &memoryEntry{value: value, expiresAt: instance.expiry(ttl)}
+	instance.wrote()
+	return nil
+}
+
+func (instance *Memory) SetNX(key, value string, ttl time.Duration) (bool, error) {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	if instance.live(key) != nil {
+		return false, nil
+	}
+	instance.entries[key] = &memoryEntry{value: value, expiresAt: instance.expiry(ttl)}
+	instance.wrote()
+	return true, nil
+}
+
+func (instance *Memory) Get(key string) (string, error) {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	anEntry := instance.live(key)
+	if anEntry == nil {
+		return "", ErrNotFound
+	}
+	return anEntry.value, nil
+}
+
+func (instance *Memory) Exists(key string) (bool, error) {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	return instance.live(key) != nil, nil
+}
+
+func (instance *Memory) Del(keys ...string) error {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	for _, key := range keys {
+		delete(instance.entries, key)
+	}
+	return nil
+}
+
+func (instance *Memory) Incr(key string, ttl time.Duration) (int64, error) {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	anEntry := instance.live(key)
+	if anEntry == nil {
+		anEntry = &memoryEntry{}
+		instance.entries[key] = anEntry
+	}
+	anEntry.count++
+	anEntry.expiresAt = instance.now().Add(ttl)
+	instance.wrote()
+	return anEntry.count, nil
+}
+
+func (instance *Memory) Add(key string, ttl time.Duration, members ...string) error {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	anEntry := instance.live(key)
+	if anEntry == nil {
+		anEntry = &memoryEntry{members: map[string]bool{}}
+		instance.entries[key] = anEntry
+	}
+	if anEntry.members == nil {
+		anEntry.members = map[string]bool{}
+	}
+	for _, member := range members {
+		anEntry.members[member] = true
+	}
+	anEntry.expiresAt = instance.now().Add(ttl)
+	instance.wrote()
+	return nil
+}
+
+func (instance *Memory) Members(key string) ([]string, error) {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	members := []string{}
+	anEntry := instance.live(key)
+	if anEntry == nil {
+		return members, nil
+	}
+	for member := range anEntry.members {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func (instance *Memory) Take(key string) (string, error) {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	anEntry := instance.live(key)
+	if anEntry == nil {
+		return "", ErrNotFound
+	}
+	delete(instance.entries, key)
+	return anEntry.value, nil
+}
+
+func (instance *Memory) Append(key, value string, limit int64) (bool, error) {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	if int64(len(instance.lists[key])) >= limit {
+		return false, nil
+	}
+	instance.lists[key] = append(instance.lists[key], value)
+	return true, nil
+}
+
+func (instance *Memory) Prepend(key, value string) error {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	instance.lists[key] = append([]string{value}, instance.lists[key]...)
+	return nil
+}
+
+func (instance *Memory) PopFront(key string) (string, error) {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	list := instance.lists[key]
+	if len(list) == 0 {
+		return "", ErrNotFound
+	}
+	if len(list) == 1 {
+		delete(instance.lists, key)
+	} else {
+		instance.lists[key] = list[1:]
+	}
+	return list[0], nil
+}
+
+// Publish hand payload to the subscribers of channel, those not reading
+// miss it rather than hold the publisher back
+func (instance *Memory) Publish(channel, payload string) error {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	for _, payloads := range instance.subscribers[channel] {
+		select {
+		case payloads <- payload:
+		default:
+		}
+	}
+	return nil
+}
+
+func (instance *Memory) Subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	payloads := make(chan string, subscriberBuffer)
+	instance.mutex.Lock()
+	if instance.subscribers[channel] == nil {
+		instance.subscribers[channel] = map[context.Context]chan string{}
+	}
+	instance.subscribers[channel][ctx] = payloads
+	instance.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		instance.mutex.Lock()
+		defer instance.mutex.Unlock()
+		delete(instance.subscribers[channel], ctx)
+		if len(instance.subscribers[channel]) == 0 {
+			delete(instance.subscribers, channel)
+		}
+		close(payloads)
+	}()
+	return payloads, nil
+}
@@ -0,0 +1,195 @@
+package keystore
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// clock of a Memory store tests move forward
+type clock struct {
+	at time.Time
+}
+
+func (instance *clock) now() time.Time { return instance.at }
+
+func newTestMemory() (*Memory, *clock) {
+	aClock := &clock{at: time.Unix(1700000000, 0)}
+	aMemory := NewMemory()
+	aMemory.now = aClock.now
+	return aMemory, aClock
+}
+
+func TestMemoryGet(t *testing.T) {
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		want    string
+		wantErr error
+	}{
+		{name: "should get value before it expires", elapsed: time.Minute - time.Second, want: "user"},
+		{name: "should not get value once it expired", elapsed: time.Minute, wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aMemory, aClock := newTestMemory()
+			_ = aMemory.Set("token", "user", time.Minute)
+			aClock.at = aClock.at.Add(tt.elapsed)
+			got, err := aMemory.Get("token")
+			if err != tt.wantErr {
+				t.Fatalf("Get() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Get() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemorySetNX(t *testing.T) {
+	aMemory, aClock := newTestMemory()
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		want    bool
+	}{
+		{name: "should set missing key", want: true},
+		{name: "should not set key set already", elapsed: time.Second, want: false},
+		{name: "should set key expired", elapsed: time.Minute, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aClock.at = aClock.at.Add(tt.elapsed)
+			got, err := aMemory.SetNX("used", "1", time.Minute)
+			if err != nil {
+				t.Fatalf("SetNX() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("SetNX() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryIncr(t *testing.T) {
+	aMemory, aClock := newTestMemory()
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		want    int64
+	}{
+		{name: "should start at one", want: 1},
+		{name: "should count on and push the expiry", elapsed: 50 * time.Second, want: 2},
+		{name: "should still count within the pushed expiry", elapsed: 50 * time.Second, want: 3},
+		{name: "should start over once expired", elapsed: time.Minute, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aClock.at = aClock.at.Add(tt.elapsed)
+			got, err := aMemory.Incr("fails", time.Minute)
+			if err != nil {
+				t.Fatalf("Incr() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Incr() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryMembers(t *testing.T) {
+	aMemory, aClock := newTestMemory()
+	_ = aMemory.Add("family", time.Minute, "access", "refresh")
+	_ = aMemory.Add("family", time.Minute, "refresh", "other")
+	got, _ := aMemory.Members("family")
+	sort.Strings(got)
+	if want := []string{"access", "other", "refresh"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Members() = %v, want %v", got, want)
+	}
+
+	_ = aMemory.Del("family")
+	if got, _ := aMemory.Members("family"); len(got) != 0 {
+		t.Errorf("Members() after Del = %v, want none", got)
+	}
+
+	_ = aMemory.Add("expiring", time.Minute, "access")
+	aClock.at = aClock.at.Add(time.Minute)
+	if got, _ := aMemory.Members("expiring"); len(got) != 0 {
+		t.Errorf("Members() once expired = %v, want none", got)
+	}
+}
+
+func TestMemoryTake(t *testing.T) {
+	aMemory, _ := newTestMemory()
+	_ = aMemory.Set("state", "user", 0)
+	tests := []struct {
+		name    string
+		want    string
+		wantErr error
+	}{
+		{name: "should take value kept until deleted", want: "user"},
+		{name: "should not take value taken already", wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := aMemory.Take("state")
+			if err != tt.wantErr {
+				t.Fatalf("Take() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Take() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryList(t *testing.T) {
+	aMemory, _ := newTestMemory()
+	for _, value := range []string{"first", "second", "third"} {
+		kept, err := aMemory.Append("spool", value, 2)
+		if err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+		if want := value != "third"; kept != want {
+			t.Errorf("Append(%v) = %v, want %v", value, kept, want)
+		}
+	}
+	_ = aMemory.Prepend("spool", "retried")
+
+	var got []string
+	for {
+		value, err := aMemory.PopFront("spool")
+		if err == ErrNotFound {
+			break
+		}
+		if err != nil {
+			t.Fatalf("PopFront() error = %v", err)
+		}
+		got = append(got, value)
+	}
+	if want := []string{"retried", "first", "second"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PopFront() = %v, want %v", got, want)
+	}
+}
+
+func TestMemorySubscribe(t *testing.T) {
+	aMemory, _ := newTestMemory()
+	_ = aMemory.Publish("live", "before")
+	ctx, cancel := context.WithCancel(context.Background())
+	payloads, err := aMemory.Subscribe(ctx, "live")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	_ = aMemory.Publish("other", "elsewhere")
+	_ = aMemory.Publish("live", "after")
+	if got := <-payloads; got != "after" {
+		t.Errorf("Subscribe() got %v, want after", got)
+	}
+
+	cancel()
+	if _, open := <-payloads; open {
+		t.Errorf("Subscribe() channel open once ctx is done")
+	}
+}
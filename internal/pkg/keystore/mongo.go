@@ -0,0 +1,305 @@
+package keystore
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// pollInterval time a subscriber waits between two reads of the messages
+// of its channel
+const pollInterval = 500 * time.Millisecond
+
+// messageTTL time a message is kept for the subscribers reading late
+const messageTTL = time.Minute
+
+// messageLag messages are read again this far back, those written by
+// another replica with a clock a little behind or stored a little late are
+// not missed
+const messageLag = 2 * time.Second
+
+// Mongo store keys as documents of a collection, shared by every replica
+// without running Redis. A TTL index on expires_at removes them some time
+// after they expire, until then they are filtered out. Each value of a list
+// and each message is a document of its own, subscribers poll for messages
+type Mongo struct {
+	Collection *mongo.Collection
+}
+
+// NewMongo ...
+func NewMongo(collection *mongo.Collection) *Mongo {
+	return &Mongo{Collection: collection}
+}
+
+// entry document of a key
+type entry struct {
+	Key       string    `bson:"_id"`
+	Value     string    `bson:"value,omitempty"`
+	Members   []string  `bson:"members,omitempty"`
+	Count     int64     `bson:"count,omitempty"`
+	ExpiresAt time.Time `bson:"expires_at,omitempty"`
+}
+
+// item value of a list, or message of a channel, in the order of Seq
+type item struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	List      string             `bson:"list,omitempty"`
+	Channel   string             `bson:"channel,omitempty"`
+	Seq       int64              `bson:"seq"`
+	Value     string             `bson:"value"`
+	ExpiresAt time.Time          `bson:"expires_at,omitempty"`
+}
+
+// live filter of key not expired yet, or kept until deleted
+func live(key string) bson.M {
+	return bson.M{"_id": key, "$or": []bson.M{
+		{"expires_at": bson.M{"$gt": time.Now()}},
+		{"expires_at": bson.M{"$exists": false}},
+	}}
+}
+
+// expiry time a key set now for ttl expires at, zero when it is kept until
+// deleted
+func expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// dropExpired remove key when it expired but is still stored, so it starts
+// over
+func (instance *Mongo) dropExpired(key string) error {
+	filter := bson.M{"_id": key, "expires_at": bson.M{"$lte": time.Now()}}
+	_, err := instance.Collection.DeleteOne(context.TODO(), filter)
+	return err
+}
+
+func (instance *Mongo) Set(key, value string, ttl time.Duration) error {
+	anEntry := entry{Key: key, Value: value, ExpiresAt: expiry(ttl)}
+	_, err := instance.Collection.ReplaceOne(context.TODO(), bson.M{"_id": key}, anEntry, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (instance *Mongo) SetNX(key, value string, ttl time.Duration) (bool, error) {
+	if err := instance.dropExpired(key); err != nil {
+		return false, err
+	}
+	_, err := instance.Collection.InsertOne(context.TODO(), entry{Key: key, Value: value, ExpiresAt: expiry(ttl)})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (instance *Mongo) Get(key string) (string, error) {
+	var anEntry entry
+	err := instance.Collection.FindOne(context.TODO(), live(key)).Decode(&anEntry)
+	if err == mongo.ErrNoDocuments {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return anEntry.Value, nil
+}
+
+func (instance *Mongo) Take(key string) (string, error) {
+	var anEntry entry
+	err := instance.Collection.FindOneAndDelete(context.TODO(), live(key)).Decode(&anEntry)
+	if err == mongo.ErrNoDocuments {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return anEntry.Value, nil
+}
+
+func (instance *Mongo) Exists(key string) (bool, error) {
+	count, err := instance.Collection.CountDocuments(context.TODO(), live(key))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (instance *Mongo) Del(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := instance.Collection.DeleteMany(context.TODO(), bson.M{"_id": bson.M{"$in": keys}})
+	return err
+}
+
+func (instance *Mongo) Incr(key string, ttl time.Duration) (int64, error) {
+	if err := instance.dropExpired(key); err != nil {
+		return 0, err
+	}
+	update := bson.M{
+		"$inc": bson.M{"count": 1},
+		"$set": bson.M{"expires_at": time.Now().Add(ttl)},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var anEntry entry
+	err := instance.Collection.FindOneAndUpdate(context.TODO(), bson.M{"_id": key}, update, opts).Decode(&anEntry)
+	if err != nil {
+		return 0, err
+	}
+	return anEntry.Count, nil
+}
+
+func (instance *Mongo) Add(key string, ttl time.Duration, members ...string) error {
+	if err := instance.dropExpired(key); err != nil {
+		return err
+	}
+	update := bson.M{
+		"$addToSet": bson.M{"members": bson.M{"$each": members}},
+		"$set":      bson.M{"expires_at": time.Now().Add(ttl)},
+	}
+	_, err := instance.Collection.UpdateOne(context.TODO(), bson.M{"_id": key}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (instance *Mongo) Members(key string) ([]string, error) {
+	var anEntry entry
+	err := instance.Collection.FindOne(context.TODO(), live(key)).Decode(&anEntry)
+	if err == mongo.ErrNoDocuments {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return anEntry.Members, nil
+}
+
+// Append count the list first, so it may go over limit by the values
+// appended at the same time
+func (instance *Mongo) Append(key, value string, limit int64) (bool, error) {
+	count, err := instance.Collection.CountDocuments(context.TODO(), bson.M{"list": key}, options.Count().SetLimit(limit))
+	if err != nil {
+		return false, err
+	}
+	if count >= limit {
+		return false, nil
+	}
+	_, err = instance.Collection.InsertOne(context.TODO(), item{List: key, Seq: time.Now().UnixNano(), Value: value})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (instance *Mongo) Prepend(key, value string) error {
+	seq := time.Now().UnixNano()
+	var first item
+	opts := options.FindOne().SetSort(primitive.D{{Key: "seq", Value: 1}})
+	err := instance.Collection.FindOne(context.TODO(), bson.M{"list": key}, opts).Decode(&first)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	if err == nil {
+		seq = first.Seq - 1
+	}
+	_, err = instance.Collection.InsertOne(context.TODO(), item{List: key, Seq: seq, Value: value})
+	return err
+}
+
+func (instance *Mongo) PopFront(key string) (string, error) {
+	var first item
+	opts := options.FindOneAndDelete().SetSort(primitive.D{{Key: "seq", Value: 1}})
+	err := instance.Collection.FindOneAndDelete(context.TODO(), bson.M{"list": key}, opts).Decode(&first)
+	if err == mongo.ErrNoDocuments {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return first.Value, nil
+}
+
+func (instance *Mongo) Publish(channel, payload string) error {
+	now := time.Now()
+	_, err := instance.Collection.InsertOne(context.TODO(), item{
+		Channel:   channel,
+		Seq:       now.UnixNano(),
+		Value:     payload,
+		ExpiresAt: now.Add(messageTTL),
+	})
+	return err
+}
+
+// Subscribe read the messages of channel every pollInterval, messageLag
+// back so messages of replicas a little behind are not missed, and each
+// message once
+func (instance *Mongo) Subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	start := time.Now().UnixNano()
+	payloads := make(chan string)
+	go func() {
+		defer close(payloads)
+		since := start
+		seen := map[primitive.ObjectID]int64{}
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			from := since - int64(messageLag)
+			if from < start {
+				from = start
+			}
+			messages, err := instance.messages(ctx, channel, from)
+			if err != nil {
+				if ctx.Err() == nil {
+					logrus.Error("read messages of channel ", channel, " error ", err)
+				}
+				continue
+			}
+			for _, message := range messages {
+				if _, ok := seen[message.ID]; ok {
+					continue
+				}
+				seen[message.ID] = message.Seq
+				if message.Seq > since {
+					since = message.Seq
+				}
+				select {
+				case payloads <- message.Value:
+				case <-ctx.Done():
+					return
+				}
+			}
+			for id, seq := range seen {
+				if seq < since-int64(messageLag) {
+					delete(seen, id)
+				}
+			}
+		}
+	}()
+	return payloads, nil
+}
+
+// messages of channel published from seq on, in order
+func (instance *Mongo) messages(ctx context.Context, channel string, from int64) ([]item, error) {
+	opts := options.Find().SetSort(primitive.D{{Key: "seq", Value: 1}})
+	cursor, err := instance.Collection.Find(ctx, bson.M{"channel": channel, "seq": bson.M{"$gt": from}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var messages []item
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
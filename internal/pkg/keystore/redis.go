@@ -0,0 +1,159 @@
+package keystore
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// Redis store keys in Redis, shared by every replica, lists and channels
+// being those of Redis
+type Redis struct {
+	Client *redis.Client
+}
+
+// NewRedis ...
+func NewRedis(client *redis.Client) *Redis {
+	return &Redis{Client: client}
+}
+
+func (instance *Redis) Set(key, value string, ttl time.Duration) error {
+	return instance.Client.Set(key, value, ttl).Err()
+}
+
+func (instance *Redis) SetNX(key, value string, ttl time.Duration) (bool, error) {
+	return instance.Client.SetNX(key, value, ttl).Result()
+}
+
+func (instance *Redis) Get(key string) (string, error) {
+	value, err := instance.Client.Get(key).Result()
+	if err == redis.Nil {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+// Take read and delete key in one transaction, so two callers racing for
+// it cannot both get it
+func (instance *Redis) Take(key string) (string, error) {
+	var get *redis.StringCmd
+	_, err := instance.Client.TxPipelined(func(pipe redis.Pipeliner) error {
+		get = pipe.Get(key)
+		pipe.Del(key)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return "", err
+	}
+	value, err := get.Result()
+	if err == redis.Nil {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+func (instance *Redis) Exists(key string) (bool, error) {
+	count, err := instance.Client.Exists(key).Result()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (instance *Redis) Del(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return instance.Client.Del(keys...).Err()
+}
+
+func (instance *Redis) Incr(key string, ttl time.Duration) (int64, error) {
+	var incr *redis.IntCmd
+	_, err := instance.Client.TxPipelined(func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(key)
+		pipe.Expire(key, ttl)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (instance *Redis) Add(key string, ttl time.Duration, members ...string) error {
+	args := make([]interface{}, len(members))
+	for i, member := range members {
+		args[i] = member
+	}
+	_, err := instance.Client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.SAdd(key, args...)
+		pipe.Expire(key, ttl)
+		return nil
+	})
+	return err
+}
+
+func (instance *Redis) Members(key string) ([]string, error) {
+	return instance.Client.SMembers(key).Result()
+}
+
+// Append push value and take it back when the list is over limit, so it
+// never holds more than limit for long
+func (instance *Redis) Append(key, value string, limit int64) (bool, error) {
+	length, err := instance.Client.RPush(key, value).Result()
+	if err != nil {
+		return false, err
+	}
+	if length > limit {
+		return false, instance.Client.RPop(key).Err()
+	}
+	return true, nil
+}
+
+func (instance *Redis) Prepend(key, value string) error {
+	return instance.Client.LPush(key, value).Err()
+}
+
+func (instance *Redis) PopFront(key string) (string, error) {
+	value, err := instance.Client.LPop(key).Result()
+	if err == redis.Nil {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+func (instance *Redis) Publish(channel, payload string) error {
+	return instance.Client.Publish(channel, payload).Err()
+}
+
+func (instance *Redis) Subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	pubSub := instance.Client.Subscribe(channel)
+	// the subscription is confirmed before the caller goes on
+	if _, err := pubSub.Receive(); err != nil {
+		pubSub.Close()
+		return nil, err
+	}
+	payloads := make(chan string)
+	go func() {
+		defer close(payloads)
+		defer pubSub.Close()
+		messages := pubSub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case payloads <- message.Payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return payloads, nil
+}
@@ -0,0 +1,140 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Phases of the process, only ready receives traffic from the load balancer
+const (
+	// PhaseWarmup connecting the databases and priming the caches
+	PhaseWarmup = "warmup"
+	PhaseReady  = "ready"
+	// PhaseLameDuck still serving but failing readiness, so the load balancer
+	// moves the traffic to the other replicas before the process stops
+	PhaseLameDuck = "lame_duck"
+)
+
+// Probe paths answered ahead of every route and tenant
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+// maxRetryDelay longest wait between two tries of a required step
+const maxRetryDelay = 10 * time.Second
+
+var phase atomic.Value
+
+func init() {
+	phase.Store(PhaseWarmup)
+}
+
+// Phase of the process now
+func Phase() string {
+	return phase.Load().(string)
+}
+
+// SetPhase ...
+func SetPhase(aPhase string) {
+	phase.Store(aPhase)
+}
+
+// Step of the warmup. A required step is tried again until it succeeds, the
+// process is not ready before; the failure of the others is logged
+type Step struct {
+	Name     string
+	Required bool
+	Run      func() error
+}
+
+// Warmup run steps in order then turn the process ready, unless it is in
+// lame duck by then
+func Warmup(steps []Step) {
+	warmup(steps, time.Second)
+}
+
+func warmup(steps []Step, retryDelay time.Duration) {
+	for _, aStep := range steps {
+		delay := retryDelay
+		for {
+			began := time.Now()
+			err := aStep.Run()
+			if err == nil {
+				logrus.Info("warmup ", aStep.Name, " done in ", time.Since(began).Round(time.Millisecond))
+				break
+			}
+			if !aStep.Required {
+				logrus.Error("warmup ", aStep.Name, " error ", err)
+				break
+			}
+			logrus.Error("warmup ", aStep.Name, " error ", err, ", retrying in ", delay)
+			time.Sleep(delay)
+			if delay *= 2; delay > maxRetryDelay {
+				delay = maxRetryDelay
+			}
+		}
+	}
+	phase.CompareAndSwap(PhaseWarmup, PhaseReady)
+}
+
+// Handler answer the liveness probe while the process serves and the
+// readiness probe while it is ready, other requests go to next
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case LivenessPath:
+			writeProbe(w, http.StatusOK)
+		case ReadinessPath:
+			status := http.StatusOK
+			if Phase() != PhaseReady {
+				status = http.StatusServiceUnavailable
+			}
+			writeProbe(w, status)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func writeProbe(w http.ResponseWriter, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"phase": Phase()})
+}
+
+// Serve handler on addr until SIGTERM or SIGINT. The process then stays in
+// lame duck for lameDuck, serving while its readiness fails, and shuts down
+// waiting up to drainTimeout for the requests in flight
+func Serve(addr string, handler http.Handler, lameDuck, drainTimeout time.Duration) error {
+	server := &http.Server{Addr: addr, Handler: handler}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case sig := <-stop:
+		logrus.Info("received ", sig, ", lame duck for ", lameDuck)
+	}
+	SetPhase(PhaseLameDuck)
+	time.Sleep(lameDuck)
+
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	logrus.Info("shutting down HTTP server...")
+	return server.Shutdown(ctx)
+}
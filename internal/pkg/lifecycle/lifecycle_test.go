@@ -0,0 +1,74 @@
+package lifecycle
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	tests := []struct {
+		name   string
+		phase  string
+		path   string
+		status int
+	}{
+		{name: "should be alive while warming up", phase: PhaseWarmup, path: LivenessPath, status: http.StatusOK},
+		{name: "should not be ready while warming up", phase: PhaseWarmup, path: ReadinessPath, status: http.StatusServiceUnavailable},
+		{name: "should be ready", phase: PhaseReady, path: ReadinessPath, status: http.StatusOK},
+		{name: "should not be ready in lame duck", phase: PhaseLameDuck, path: ReadinessPath, status: http.StatusServiceUnavailable},
+		{name: "should be alive in lame duck", phase: PhaseLameDuck, path: LivenessPath, status: http.StatusOK},
+		{name: "should serve other requests in lame duck", phase: PhaseLameDuck, path: "/session/receive", status: http.StatusTeapot},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetPhase(tt.phase)
+			recorder := httptest.NewRecorder()
+			Handler(next).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if recorder.Code != tt.status {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	tests := []struct {
+		name      string
+		phase     string
+		fails     int
+		required  bool
+		wantRuns  int
+		wantPhase string
+	}{
+		{name: "should turn ready", phase: PhaseWarmup, wantRuns: 1, wantPhase: PhaseReady},
+		{name: "should retry a required step until it succeeds", phase: PhaseWarmup, fails: 2, required: true, wantRuns: 3, wantPhase: PhaseReady},
+		{name: "should go on past an optional step failing", phase: PhaseWarmup, fails: 1, wantRuns: 1, wantPhase: PhaseReady},
+		{name: "should stay in lame duck", phase: PhaseLameDuck, wantRuns: 1, wantPhase: PhaseLameDuck},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetPhase(tt.phase)
+			runs := 0
+			aStep := Step{Name: "mongo", Required: tt.required, Run: func() error {
+				runs++
+				if runs <= tt.fails {
+					return errors.New("unreachable")
+				}
+				return nil
+			}}
+			warmup([]Step{aStep}, time.Millisecond)
+			if runs != tt.wantRuns {
+				t.Errorf("runs = %d, want %d", runs, tt.wantRuns)
+			}
+			if got := Phase(); got != tt.wantPhase {
+				t.Errorf("Phase() = %v, want %v", got, tt.wantPhase)
+			}
+		})
+	}
+}
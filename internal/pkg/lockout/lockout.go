@@ -0,0 +1,41 @@
+package lockout
+
+import (
+	"math"
+	"time"
+)
+
+// Policy how long a subject failing again and again, like sign ins of an
+// account, is locked out. Free failures are let through, the first one past
+// them locks out for Base and each next one doubles it, up to Max
+type Policy struct {
+	Free int64
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Duration lockout after the count of failures fails, 0 while they are free
+func (instance Policy) Duration(fails int64) time.Duration {
+	if fails <= instance.Free {
+		return 0
+	}
+	doublings := fails - instance.Free - 1
+	// past this many doublings any base is over a day
+	if doublings > 32 {
+		return instance.Max
+	}
+	wait := instance.Base * time.Duration(int64(1)<<doublings)
+	if wait <= 0 || wait > instance.Max {
+		return instance.Max
+	}
+	return wait
+}
+
+// RetryAfter seconds of the Retry-After header of a client waiting wait,
+// rounded up so it does not come back too early
+func RetryAfter(wait time.Duration) int {
+	if wait <= 0 {
+		return 0
+	}
+	return int(math.Ceil(wait.Seconds()))
+}
@@ -0,0 +1,48 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolicyDuration(t *testing.T) {
+	aPolicy := Policy{Free: 5, Base: 30 * time.Second, Max: 15 * time.Minute}
+	tests := []struct {
+		name  string
+		fails int64
+		want  time.Duration
+	}{
+		{name: "should not lock out without failures", fails: 0},
+		{name: "should let the free failures through", fails: 5},
+		{name: "should lock out for base past the free failures", fails: 6, want: 30 * time.Second},
+		{name: "should double with each failure", fails: 8, want: 2 * time.Minute},
+		{name: "should stop at max", fails: 12, want: 15 * time.Minute},
+		{name: "should not overflow", fails: 1000, want: 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := aPolicy.Duration(tt.fails); got != tt.want {
+				t.Errorf("Duration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name string
+		wait time.Duration
+		want int
+	}{
+		{name: "should round up", wait: 1500 * time.Millisecond, want: 2},
+		{name: "should keep whole seconds", wait: time.Minute, want: 60},
+		{name: "should be zero without wait", wait: -time.Second, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RetryAfter(tt.wait); got != tt.want {
+				t.Errorf("RetryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
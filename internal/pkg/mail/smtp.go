@@ -0,0 +1,102 @@
+package mail
+
+import (
+	"crypto/tls"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sendTimeout bound of connecting to the server
+const sendTimeout = 10 * time.Second
+
+// SMTP sender of an SMTP server, signing in with Username when it is set
+type SMTP struct {
+	Address  string
+	Host     string
+	Username string
+	Password string
+	// TLS connect over TLS, the connection is upgraded with STARTTLS
+	// otherwise when the server offers it
+	TLS  bool
+	From string
+}
+
+// Send ...
+func (instance *SMTP) Send(aMessage Message) error {
+	data, err := format(instance.From, aMessage, time.Now())
+	if err != nil {
+		return err
+	}
+	// From may carry a display name, the envelope takes the address only
+	from, err := netmail.ParseAddress(instance.From)
+	if err != nil {
+		return err
+	}
+
+	var conn net.Conn
+	if instance.TLS {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: sendTimeout}, "tcp", instance.Address, &tls.Config{ServerName: instance.Host})
+	} else {
+		conn, err = net.DialTimeout("tcp", instance.Address, sendTimeout)
+	}
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, instance.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if !instance.TLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: instance.Host}); err != nil {
+				return err
+			}
+		}
+	}
+	// PlainAuth refuses to send the password unencrypted, but to localhost
+	if instance.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", instance.Username, instance.Password, instance.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(aMessage.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// Log sender writing emails to the log instead of sending them, for
+// development
+type Log struct {
+	From string
+}
+
+// Send ...
+func (instance *Log) Send(aMessage Message) error {
+	data, err := format(instance.From, aMessage, time.Now())
+	if err != nil {
+		return err
+	}
+	logrus.Info("mail not sent, logged:\n", string(data))
+	return nil
+}
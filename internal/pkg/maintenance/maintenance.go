@@ -0,0 +1,65 @@
+package maintenance
+
+import (
+	"sync"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/internal/pkg/keystore"
+
+	"github.com/sirupsen/logrus"
+)
+
+// modeKey shared by all replicas, never prefixed per tenant since the mode is instance-wide
+const modeKey = "maintenance_mode"
+
+// refreshInterval bound how long a replica takes to see a change made on another one
+const refreshInterval = 5 * time.Second
+
+var (
+	mu        sync.Mutex
+	enabled   bool
+	refreshed time.Time
+)
+
+// Enabled maintenance mode is on by config or by the admin API
+func Enabled() bool {
+	if configs.Current().MaintenanceMode {
+		return true
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if time.Since(refreshed) < refreshInterval {
+		return enabled
+	}
+
+	value, err := configs.KeyStore.Client.Get(modeKey)
+	if err != nil && err != keystore.ErrNotFound {
+		// keep the last known state while the store is unreachable
+		logrus.Error("get maintenance mode error ", err)
+		return enabled
+	}
+	enabled = value == "true"
+	refreshed = time.Now()
+	return enabled
+}
+
+// Set turn maintenance mode on or off for all replicas
+func Set(on bool) error {
+	var err error
+	if on {
+		err = configs.KeyStore.Client.Set(modeKey, "true", 0)
+	} else {
+		err = configs.KeyStore.Client.Del(modeKey)
+	}
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	enabled = on
+	refreshed = time.Now()
+	mu.Unlock()
+	return nil
+}
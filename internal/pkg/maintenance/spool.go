@@ -0,0 +1,151 @@
+package maintenance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/internal/pkg/keystore"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SpoolKey list of the collector requests received during maintenance,
+// prefixed per tenant by the caller since each tenant replays to its own routes
+const SpoolKey = "maintenance_spool"
+
+// spoolLimit requests kept at most, the collector replies 503 past it
+const spoolLimit = 1000000
+
+// replayBatch requests replayed at most by one run of Replay
+const replayBatch = 1000
+
+// replayAttempts times a request failing with a 5xx is replayed before it is dropped
+const replayAttempts = 5
+
+// ErrSpoolFull the spool reached its limit, the request is not kept
+var ErrSpoolFull = errors.New("maintenance spool is full")
+
+// spooled request as received by the collector
+type spooled struct {
+	Method     string      `json:"method"`
+	URI        string      `json:"uri"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	RemoteAddr string      `json:"remote_addr"`
+	ReceivedAt time.Time   `json:"received_at"`
+	Attempts   int         `json:"attempts,omitempty"`
+}
+
+type receivedAtKey struct{}
+
+// Spool keep r with its body under key, to replay once maintenance is over
+func Spool(key string, r *http.Request, body []byte) error {
+	data, err := json.Marshal(spooled{
+		Method:     r.Method,
+		URI:        r.URL.RequestURI(),
+		Header:     r.Header,
+		Body:       body,
+		RemoteAddr: r.RemoteAddr,
+		ReceivedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	kept, err := configs.KeyStore.Client.Append(key, string(data), spoolLimit)
+	if err != nil {
+		return err
+	}
+	if !kept {
+		return ErrSpoolFull
+	}
+	return nil
+}
+
+// Replay send the requests spooled under key to handler in the order they
+// were received, while maintenance is off. A request failing with a 5xx is put
+// back in front and the replay stops since the storage may not be back yet,
+// return the count of requests replayed
+func Replay(key string, handler http.Handler) (int, error) {
+	replayed := 0
+	for replayed < replayBatch && !Enabled() {
+		value, err := configs.KeyStore.Client.PopFront(key)
+		if err == keystore.ErrNotFound {
+			return replayed, nil
+		}
+		if err != nil {
+			return replayed, err
+		}
+
+		var aRequest spooled
+		if err := json.Unmarshal([]byte(value), &aRequest); err != nil {
+			logrus.Error("drop malformed spooled request ", err)
+			continue
+		}
+
+		r, err := http.NewRequest(aRequest.Method, aRequest.URI, bytes.NewReader(aRequest.Body))
+		if err != nil {
+			logrus.Error("drop spooled request ", aRequest.URI, " ", err)
+			continue
+		}
+		r.Header = aRequest.Header
+		r.RemoteAddr = aRequest.RemoteAddr
+		r = r.WithContext(context.WithValue(r.Context(), receivedAtKey{}, aRequest.ReceivedAt))
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, r)
+		if recorder.Code < http.StatusInternalServerError {
+			replayed++
+			continue
+		}
+
+		aRequest.Attempts++
+		if aRequest.Attempts >= replayAttempts {
+			logrus.Error("drop spooled request ", aRequest.URI, " after ", aRequest.Attempts, " attempts")
+			continue
+		}
+		data, err := json.Marshal(aRequest)
+		if err != nil {
+			return replayed, err
+		}
+		if err := configs.KeyStore.Client.Prepend(key, string(data)); err != nil {
+			return replayed, err
+		}
+		return replayed, fmt.Errorf("replay %s: status %d", aRequest.URI, recorder.Code)
+	}
+	return replayed, nil
+}
+
+// RunReplay replay the requests spooled under key to handler every interval
+func RunReplay(key string, handler http.Handler, interval time.Duration) {
+	for range time.Tick(interval) {
+		for {
+			replayed, err := Replay(key, handler)
+			if err != nil {
+				logrus.Error("replay maintenance spool error ", err)
+			}
+			if replayed > 0 {
+				logrus.Info("replayed ", replayed, " requests spooled during maintenance")
+			}
+			if err != nil || replayed < replayBatch {
+				break
+			}
+		}
+	}
+}
+
+// ReceivedAt time the collector received r, the time it was spooled for
+// requests replayed after maintenance so their events keep their clock
+func ReceivedAt(r *http.Request) time.Time {
+	if at, ok := r.Context().Value(receivedAtKey{}).(time.Time); ok {
+		return at
+	}
+	return time.Now()
+}
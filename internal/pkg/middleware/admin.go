@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"analytics-api/configs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware guard instance admin API with the admin secret header
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret := c.GetHeader("X-Admin-Secret")
+		if configs.AdminSecretKey == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(configs.AdminSecretKey)) != 1 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin access denied"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"analytics-api/internal/pkg/ipallow"
+
+	"github.com/gin-gonic/gin"
+)
+
+// allowListKey context key of the allow-list enforced by JWTMiddleware
+const allowListKey = "allow_list"
+
+// AllowListMiddleware make the allow-list of store available to JWTMiddleware,
+// it does not reject anything itself so the public collector stays reachable
+func AllowListMiddleware(list *ipallow.List) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(allowListKey, list)
+		c.Next()
+	}
+}
@@ -1,21 +1,44 @@
 package middleware
 
 import (
+	"net"
 	"net/http"
 
+	"analytics-api/internal/pkg/httperr"
+	"analytics-api/internal/pkg/ipallow"
 	"analytics-api/internal/pkg/security"
 
 	"github.com/gin-gonic/gin"
 )
 
+// JWTMiddleware let through requests with a valid access token, of the
+// cookie of a sign in or a bearer personal token
 func JWTMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if list, ok := c.Get(allowListKey); ok {
+			// forwarding headers only count from TRUSTED_PROXIES
+			clientIP := net.ParseIP(c.ClientIP())
+			if !list.(*ipallow.List).Allowed(clientIP) {
+				httperr.Abort(c, http.StatusForbidden, httperr.CodeForbidden, "ip address not allowed")
+				return
+			}
+		}
+
 		accessTokenValidErr := security.AccessTokenValid(c.Request)
 		if accessTokenValidErr != nil {
+			// browsers get the sign in page, api clients and scripts
+			// with a bearer token an error
+			if c.GetHeader("Authorization") != "" || c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+				httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "access token invalid or expired")
+				return
+			}
 			c.HTML(http.StatusUnauthorized, "401.html", gin.H{})
 			c.Abort()
 			return
 		}
+		if !checkSignature(c) {
+			return
+		}
 		c.Next()
 	}
 }
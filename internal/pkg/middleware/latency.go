@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"analytics-api/internal/pkg/slo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LatencyMiddleware measure the latency of the routes with an objective in
+// the default tracker of slo. With shed on, routes in low are answered 503
+// while the objective of a route in protect is at risk, so the heavy reports
+// leave their time to the collector
+func LatencyMiddleware(shed bool, protect []string, low []string) gin.HandlerFunc {
+	lowRoutes := map[string]bool{}
+	for _, route := range low {
+		lowRoutes[route] = true
+	}
+
+	return func(c *gin.Context) {
+		tracker := slo.Default()
+		route := c.FullPath()
+		if shed && lowRoutes[route] {
+			if protected, atRisk := tracker.AtRisk(protect); atRisk {
+				tracker.Shed(protected)
+				c.Header("Retry-After", "60")
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "the server is busy collecting events, retry later"})
+				return
+			}
+		}
+
+		began := time.Now()
+		c.Next()
+		tracker.Observe(route, time.Since(began))
+	}
+}
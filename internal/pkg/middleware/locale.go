@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"analytics-api/internal/pkg/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LocalePreference locale the user of r chose, empty when r is not signed
+// in or the user left it unset
+type LocalePreference interface {
+	Locale(r *http.Request) string
+}
+
+// localizedFields of json error responses holding a message for people
+var localizedFields = []string{"message", "error", "msg"}
+
+// localeWriter hold back json error responses so their message can be
+// translated once the handler is done, other responses go straight through
+type localeWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (instance *localeWriter) held() bool {
+	return instance.Status() >= http.StatusBadRequest &&
+		strings.HasPrefix(instance.Header().Get("Content-Type"), gin.MIMEJSON)
+}
+
+func (instance *localeWriter) Write(data []byte) (int, error) {
+	if instance.held() {
+		return instance.body.Write(data)
+	}
+	return instance.ResponseWriter.Write(data)
+}
+
+func (instance *localeWriter) WriteString(s string) (int, error) {
+	return instance.Write([]byte(s))
+}
+
+// LocaleMiddleware translate the message of json error responses to the
+// locale the user chose, or else the best match of Accept-Language. Messages
+// are looked up by their english text, those without a translation are kept
+func LocaleMiddleware(preference LocalePreference) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Language")
+		writer := &localeWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		if writer.body.Len() == 0 {
+			return
+		}
+
+		// the preference is only looked up when there is a message to translate
+		locale := preference.Locale(c.Request)
+		if locale == "" {
+			locale = i18n.Match(c.GetHeader("Accept-Language"))
+		}
+		body := writer.body.Bytes()
+		var response map[string]interface{}
+		if locale != i18n.English && json.Unmarshal(body, &response) == nil {
+			for _, field := range localizedFields {
+				if message, ok := response[field].(string); ok {
+					response[field] = i18n.T(locale, message)
+				}
+			}
+			if translated, err := json.Marshal(response); err == nil {
+				body = translated
+			}
+		}
+		c.Header("Content-Language", locale)
+		c.Writer.Write(body)
+	}
+}
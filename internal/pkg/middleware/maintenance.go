@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+
+	"analytics-api/internal/pkg/maintenance"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// MaintenanceMiddleware reply 503 to write requests while maintenance mode is on.
+// Requests are writes when the method is not safe or the route is listed in writeRoutes,
+// routes in exempt keep working, like sign in. Requests of spooledRoutes, like the
+// collector, are kept under spoolKey and replayed once maintenance is over.
+func MaintenanceMiddleware(exempt []string, writeRoutes []string, spooledRoutes []string, spoolKey string) gin.HandlerFunc {
+	exemptRoutes := map[string]bool{}
+	for _, route := range exempt {
+		exemptRoutes[route] = true
+	}
+	unsafeRoutes := map[string]bool{}
+	for _, route := range writeRoutes {
+		unsafeRoutes[route] = true
+	}
+	spooled := map[string]bool{}
+	for _, route := range spooledRoutes {
+		spooled[route] = true
+	}
+
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		write := unsafeRoutes[route]
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			write = true
+		}
+
+		if !write || exemptRoutes[route] || !maintenance.Enabled() {
+			c.Next()
+			return
+		}
+
+		if spooled[route] {
+			body, err := io.ReadAll(c.Request.Body)
+			if err == nil {
+				err = maintenance.Spool(spoolKey, c.Request, body)
+			}
+			if err == nil {
+				c.AbortWithStatusJSON(http.StatusAccepted, gin.H{"spooled": true})
+				return
+			}
+			logrus.Error("spool request during maintenance error ", err)
+		}
+		c.Header("Retry-After", "300")
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "maintenance in progress, the service is read-only"})
+	}
+}
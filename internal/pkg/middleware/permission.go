@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+
+	"analytics-api/internal/pkg/httperr"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// scopeKey context key of the tags the user is limited to
+const scopeKey = "scope"
+
+// Authorizer tell if the user signed in on r may call route with method on
+// websiteID, the website in its path if any, true when r is not signed in.
+// Returns the tags the user is limited to, none when it sees every website
+type Authorizer interface {
+	Authorize(r *http.Request, method, route, websiteID string) ([]string, bool, error)
+}
+
+// PermissionMiddleware turn away with 403 the requests the role of their
+// user does not allow, before the route handles them, and hand the tags
+// the user is limited to over to the route in Scope
+func PermissionMiddleware(authorizer Authorizer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			c.Next()
+			return
+		}
+		scope, allowed, err := authorizer.Authorize(c.Request, c.Request.Method, route, c.Param("website_id"))
+		if err != nil {
+			logrus.Error("authorize request error ", err)
+			httperr.Abort(c, http.StatusInternalServerError, httperr.CodeInternal, "get user failed")
+			return
+		}
+		if !allowed {
+			httperr.Abort(c, http.StatusForbidden, httperr.CodeForbidden, "your role does not allow this")
+			return
+		}
+		if len(scope) > 0 {
+			c.Set(scopeKey, scope)
+		}
+		c.Next()
+	}
+}
+
+// Scope tags the user of the request is limited to, routes listing websites
+// show only those carrying one of them. Empty when the user sees every website
+func Scope(c *gin.Context) []string {
+	scope, _ := c.Get(scopeKey)
+	tags, _ := scope.([]string)
+	return tags
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"analytics-api/internal/pkg/httperr"
+	"analytics-api/internal/pkg/signature"
+
+	"github.com/gin-gonic/gin"
+)
+
+// signatureKey context key of the signature policy enforced by JWTMiddleware
+const signatureKey = "signature"
+
+// maxSignedBody larger bodies cannot be signed
+const maxSignedBody = 10 << 20
+
+// SignatureVerifier check the signed request r with its body, the key must
+// belong to the user of the access token
+type SignatureVerifier interface {
+	VerifySignature(r *http.Request, body []byte) error
+}
+
+type signaturePolicy struct {
+	verifier     SignatureVerifier
+	required     *atomic.Bool
+	exemptRoutes map[string]bool
+	unsafeRoutes map[string]bool
+}
+
+// SignatureMiddleware make signed request checks available to JWTMiddleware.
+// Writes must be signed while required is set, writes are found like
+// MaintenanceMiddleware does and routes in exempt check it themselves
+func SignatureMiddleware(verifier SignatureVerifier, required *atomic.Bool, exempt []string, writeRoutes []string) gin.HandlerFunc {
+	policy := signaturePolicy{
+		verifier:     verifier,
+		required:     required,
+		exemptRoutes: map[string]bool{},
+		unsafeRoutes: map[string]bool{},
+	}
+	for _, route := range exempt {
+		policy.exemptRoutes[route] = true
+	}
+	for _, route := range writeRoutes {
+		policy.unsafeRoutes[route] = true
+	}
+
+	return func(c *gin.Context) {
+		c.Set(signatureKey, policy)
+		c.Next()
+	}
+}
+
+// checkSignature verify the signature of a signed request, or reject writes
+// without one when the policy requires it
+func checkSignature(c *gin.Context) bool {
+	value, ok := c.Get(signatureKey)
+	if !ok {
+		return true
+	}
+	policy := value.(signaturePolicy)
+
+	if c.GetHeader(signature.HeaderSignature) == "" {
+		route := c.FullPath()
+		write := policy.unsafeRoutes[route]
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			write = true
+		}
+		if write && !policy.exemptRoutes[route] && policy.required.Load() {
+			httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, "writes must be signed")
+			return false
+		}
+		return true
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxSignedBody+1))
+	if err != nil || len(body) > maxSignedBody {
+		httperr.Abort(c, http.StatusRequestEntityTooLarge, httperr.CodeInvalidRequest, "signed body too large")
+		return false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	err = policy.verifier.VerifySignature(c.Request, body)
+	if err != nil {
+		httperr.Abort(c, http.StatusUnauthorized, httperr.CodeUnauthorized, err.Error())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UsageRecorder record a request to route answered with status, requests
+// not signed in are left out by the recorder
+type UsageRecorder interface {
+	RecordUsage(r *http.Request, route string, status int)
+}
+
+// UsageMiddleware tell recorder of every request matching a route once it is
+// answered
+func UsageMiddleware(recorder UsageRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if route := c.FullPath(); route != "" {
+			recorder.RecordUsage(c.Request, route, c.Writer.Status())
+		}
+	}
+}
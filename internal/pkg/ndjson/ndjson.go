@@ -0,0 +1,44 @@
+package ndjson
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// ContentType of newline delimited JSON responses
+const ContentType = "application/x-ndjson"
+
+// Writer write each value as one JSON line, flushed to the client right away
+// so it can render before the whole result is read
+type Writer struct {
+	enc     *json.Encoder
+	flusher http.Flusher
+	count   int
+}
+
+// NewWriter ...
+func NewWriter(w io.Writer) *Writer {
+	flusher, _ := w.(http.Flusher)
+	return &Writer{
+		enc:     json.NewEncoder(w),
+		flusher: flusher,
+	}
+}
+
+// Write encode v on its own line
+func (instance *Writer) Write(v interface{}) error {
+	if err := instance.enc.Encode(v); err != nil {
+		return err
+	}
+	instance.count++
+	if instance.flusher != nil {
+		instance.flusher.Flush()
+	}
+	return nil
+}
+
+// Count number of lines written
+func (instance *Writer) Count() int {
+	return instance.count
+}
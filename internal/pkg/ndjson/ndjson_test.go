@@ -0,0 +1,48 @@
+package ndjson
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	type row struct {
+		ID string `json:"id"`
+	}
+	tests := []struct {
+		name   string
+		values []interface{}
+		want   string
+	}{
+		{
+			name:   "should write nothing without values",
+			values: nil,
+			want:   "",
+		},
+		{
+			name:   "should write one line per value",
+			values: []interface{}{row{ID: "a"}, row{ID: "b"}},
+			want:   "{\"id\":\"a\"}\n{\"id\":\"b\"}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			writer := NewWriter(recorder)
+			for _, value := range tt.values {
+				if err := writer.Write(value); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			if got := recorder.Body.String(); got != tt.want {
+				t.Errorf("Write() = %q, want %q", got, tt.want)
+			}
+			if writer.Count() != len(tt.values) {
+				t.Errorf("Count() = %v, want %v", writer.Count(), len(tt.values))
+			}
+			if len(tt.values) > 0 && !recorder.Flushed {
+				t.Errorf("Write() did not flush")
+			}
+		})
+	}
+}
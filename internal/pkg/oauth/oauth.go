@@ -0,0 +1,220 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrUnverifiedEmail identity whose provider vouches for no email, it could
+// take over the account of somebody else
+var ErrUnverifiedEmail = errors.New("oauth: the provider has no verified email for this account")
+
+// Identity account of a user with a provider
+type Identity struct {
+	// Subject id of the account, stable when the email changes
+	Subject string
+	Email   string
+	Name    string
+}
+
+// Provider signs users in with their account of an identity provider
+type Provider interface {
+	// AuthorizeURL page asking the user to sign in with the provider
+	AuthorizeURL(state, redirectURL string) string
+	// Identify exchange the code of the redirect for the identity of the user
+	Identify(code, redirectURL string) (*Identity, error)
+}
+
+// app endpoints and credentials of the oauth app registered with a provider
+type app struct {
+	AuthURL      string
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       string
+	HTTP         *http.Client
+}
+
+func (instance *app) authorizeURL(state, redirectURL string) string {
+	query := url.Values{
+		"client_id":     {instance.ClientID},
+		"redirect_uri":  {redirectURL},
+		"scope":         {instance.Scopes},
+		"state":         {state},
+		"response_type": {"code"},
+	}
+	return instance.AuthURL + "?" + query.Encode()
+}
+
+// exchange code for an access token of the user
+func (instance *app) exchange(code, redirectURL string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURL},
+		"client_id":     {instance.ClientID},
+		"client_secret": {instance.ClientSecret},
+	}
+	request, err := http.NewRequest(http.MethodPost, instance.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// GitHub answers a form unless asked for JSON
+	request.Header.Set("Accept", "application/json")
+	var reply struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err := instance.do(request, &reply); err != nil {
+		return "", err
+	}
+	if reply.AccessToken == "" {
+		return "", fmt.Errorf("oauth: token: %s", reply.Error)
+	}
+	return reply.AccessToken, nil
+}
+
+// get the JSON document at endpoint with the access token of the user
+func (instance *app) get(endpoint, accessToken string, reply interface{}) error {
+	request, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	request.Header.Set("Accept", "application/json")
+	return instance.do(request, reply)
+}
+
+func (instance *app) do(request *http.Request, reply interface{}) error {
+	res, err := instance.HTTP.Do(request)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if res.StatusCode != http.StatusOK {
+		if len(data) > 512 {
+			data = data[:512]
+		}
+		return fmt.Errorf("oauth: %s: %s: %s", request.URL.Host, res.Status, data)
+	}
+	return json.Unmarshal(data, reply)
+}
+
+// Google sign in with a Google account
+type Google struct {
+	app
+	UserInfoURL string
+}
+
+// NewGoogle provider of the web client with clientID
+func NewGoogle(clientID, clientSecret string) *Google {
+	return &Google{
+		app: app{
+			AuthURL:      "https://accounts.google.com/o/oauth2/v2/auth",
+			TokenURL:     "https://oauth2.googleapis.com/token",
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Scopes:       "openid email profile",
+			HTTP:         &http.Client{Timeout: 10 * time.Second},
+		},
+		UserInfoURL: "https://openidconnect.googleapis.com/v1/userinfo",
+	}
+}
+
+// AuthorizeURL ...
+func (instance *Google) AuthorizeURL(state, redirectURL string) string {
+	return instance.authorizeURL(state, redirectURL)
+}
+
+// Identify ...
+func (instance *Google) Identify(code, redirectURL string) (*Identity, error) {
+	accessToken, err := instance.exchange(code, redirectURL)
+	if err != nil {
+		return nil, err
+	}
+	var profile struct {
+		Subject       string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := instance.get(instance.UserInfoURL, accessToken, &profile); err != nil {
+		return nil, err
+	}
+	if profile.Email == "" || !profile.EmailVerified {
+		return nil, ErrUnverifiedEmail
+	}
+	return &Identity{Subject: profile.Subject, Email: profile.Email, Name: profile.Name}, nil
+}
+
+// GitHub sign in with a GitHub account
+type GitHub struct {
+	app
+	Endpoint string
+}
+
+// NewGitHub provider of the oauth app with clientID
+func NewGitHub(clientID, clientSecret string) *GitHub {
+	return &GitHub{
+		app: app{
+			AuthURL:      "https://github.com/login/oauth/authorize",
+			TokenURL:     "https://github.com/login/oauth/access_token",
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Scopes:       "read:user user:email",
+			HTTP:         &http.Client{Timeout: 10 * time.Second},
+		},
+		Endpoint: "https://api.github.com",
+	}
+}
+
+// AuthorizeURL ...
+func (instance *GitHub) AuthorizeURL(state, redirectURL string) string {
+	return instance.authorizeURL(state, redirectURL)
+}
+
+// Identify the email is the primary one of the account, the public email of
+// the profile may not be verified
+func (instance *GitHub) Identify(code, redirectURL string) (*Identity, error) {
+	accessToken, err := instance.exchange(code, redirectURL)
+	if err != nil {
+		return nil, err
+	}
+	var profile struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := instance.get(instance.Endpoint+"/user", accessToken, &profile); err != nil {
+		return nil, err
+	}
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := instance.get(instance.Endpoint+"/user/emails", accessToken, &emails); err != nil {
+		return nil, err
+	}
+	anIdentity := &Identity{Subject: fmt.Sprint(profile.ID), Name: profile.Name}
+	if anIdentity.Name == "" {
+		anIdentity.Name = profile.Login
+	}
+	for _, anEmail := range emails {
+		if anEmail.Primary && anEmail.Verified {
+			anIdentity.Email = anEmail.Email
+		}
+	}
+	if anIdentity.Email == "" {
+		return nil, ErrUnverifiedEmail
+	}
+	return anIdentity, nil
+}
@@ -0,0 +1,103 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestAuthorizeURL(t *testing.T) {
+	provider := NewGoogle("client", "secret")
+	got, err := url.Parse(provider.AuthorizeURL("state-1", "https://app.example.com/auth/oauth/google/callback"))
+	if err != nil {
+		t.Fatalf("AuthorizeURL() error = %v", err)
+	}
+	query := got.Query()
+	if query.Get("state") != "state-1" || query.Get("client_id") != "client" || query.Get("response_type") != "code" {
+		t.Errorf("AuthorizeURL() query = %v", query)
+	}
+	if query.Get("redirect_uri") != "https://app.example.com/auth/oauth/google/callback" {
+		t.Errorf("AuthorizeURL() redirect_uri = %v", query.Get("redirect_uri"))
+	}
+}
+
+func TestGoogleIdentify(t *testing.T) {
+	tests := []struct {
+		name     string
+		userInfo string
+		want     *Identity
+		wantErr  error
+	}{
+		{
+			name:     "should identify verified account",
+			userInfo: `{"sub":"109","email":"ana@example.com","email_verified":true,"name":"Ana"}`,
+			want:     &Identity{Subject: "109", Email: "ana@example.com", Name: "Ana"},
+		},
+		{
+			name:     "should refuse unverified email",
+			userInfo: `{"sub":"109","email":"ana@example.com","email_verified":false}`,
+			wantErr:  ErrUnverifiedEmail,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/token":
+					r.ParseForm()
+					if r.Form.Get("code") != "code-1" || r.Form.Get("client_secret") != "secret" {
+						t.Errorf("form = %v", r.Form)
+					}
+					w.Write([]byte(`{"access_token":"at"}`))
+				case "/userinfo":
+					if r.Header.Get("Authorization") != "Bearer at" {
+						t.Errorf("Authorization = %v", r.Header.Get("Authorization"))
+					}
+					w.Write([]byte(tt.userInfo))
+				}
+			}))
+			defer server.Close()
+
+			provider := NewGoogle("client", "secret")
+			provider.TokenURL = server.URL + "/token"
+			provider.UserInfoURL = server.URL + "/userinfo"
+			got, err := provider.Identify("code-1", "https://app.example.com/callback")
+			if err != tt.wantErr {
+				t.Fatalf("Identify() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.want != nil && *got != *tt.want {
+				t.Errorf("Identify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitHubIdentify(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.Header.Get("Accept") != "application/json" {
+				t.Errorf("Accept = %v", r.Header.Get("Accept"))
+			}
+			w.Write([]byte(`{"access_token":"at"}`))
+		case "/user":
+			w.Write([]byte(`{"id":42,"login":"ana","name":""}`))
+		case "/user/emails":
+			w.Write([]byte(`[{"email":"old@example.com","primary":false,"verified":true},{"email":"ana@example.com","primary":true,"verified":true}]`))
+		}
+	}))
+	defer server.Close()
+
+	provider := NewGitHub("client", "secret")
+	provider.TokenURL = server.URL + "/token"
+	provider.Endpoint = server.URL
+	got, err := provider.Identify("code-1", "https://app.example.com/callback")
+	if err != nil {
+		t.Fatalf("Identify() error = %v", err)
+	}
+	want := Identity{Subject: "42", Email: "ana@example.com", Name: "ana"}
+	if *got != want {
+		t.Errorf("Identify() = %v, want %v", *got, want)
+	}
+}
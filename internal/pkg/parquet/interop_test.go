@@ -0,0 +1,151 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// pyarrowRows print the rows of the file as a JSON array of arrays, the
+// timestamps as milliseconds
+const pyarrowRows = `
+import json, sys
+import pyarrow as pa, pyarrow.parquet as pq
+table = pq.read_table(sys.argv[1])
+columns = []
+for name in table.column_names:
+    column = table[name]
+    if pa.types.is_timestamp(column.type):
+        column = column.cast(pa.int64())
+    columns.append(column.to_pylist())
+print(json.dumps([list(row) for row in zip(*columns)]))
+`
+
+// interopReaders parquet readers of other tools, each prints the rows of the
+// file at path as a JSON array of arrays
+var interopReaders = []struct {
+	name    string
+	check   []string
+	command func(path string) *exec.Cmd
+}{
+	{
+		name:  "pyarrow",
+		check: []string{"python3", "-c", "import pyarrow.parquet"},
+		command: func(path string) *exec.Cmd {
+			return exec.Command("python3", "-c", pyarrowRows, path)
+		},
+	},
+	{
+		name:  "duckdb",
+		check: []string{"duckdb", "-version"},
+		command: func(path string) *exec.Cmd {
+			return exec.Command("duckdb", "-noheader", "-list", "-c",
+				"SELECT to_json(list([website_id::JSON, type::JSON, epoch_ms(timestamp)::JSON])) FROM read_parquet('"+path+"')")
+		},
+	},
+}
+
+func TestInterop(t *testing.T) {
+	schema := []Column{{Name: "website_id", Type: String}, {Name: "type", Type: Int64}, {Name: "timestamp", Type: TimestampMillis}}
+	many := make([][]interface{}, RowGroupRows+3)
+	for i := range many {
+		many[i] = []interface{}{"w", int64(i), int64(1700000000000 + i)}
+	}
+	tests := []struct {
+		name string
+		rows [][]interface{}
+	}{
+		{name: "should be read with unicode strings", rows: [][]interface{}{{"a", int64(4), int64(1700000000000)}, {"Hồ Chí Minh", int64(-5), int64(0)}}},
+		{name: "should be read across row groups", rows: many},
+	}
+
+	var readers int
+	for _, reader := range interopReaders {
+		if err := exec.Command(reader.check[0], reader.check[1:]...).Run(); err != nil {
+			continue
+		}
+		readers++
+		for _, tt := range tests {
+			t.Run(reader.name+" "+tt.name, func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "events.parquet")
+				if err := os.WriteFile(path, writeRows(t, schema, tt.rows), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				out, err := reader.command(path).Output()
+				if err != nil {
+					t.Fatalf("%s error = %v", reader.name, err)
+				}
+				var got [][]interface{}
+				if err := json.Unmarshal(out, &got); err != nil {
+					t.Fatalf("%s output = %s, error = %v", reader.name, out, err)
+				}
+				if !reflect.DeepEqual(got, asJSON(t, tt.rows)) {
+					t.Errorf("%s read %d rows unlike the %d written", reader.name, len(got), len(tt.rows))
+				}
+			})
+		}
+	}
+	if readers == 0 {
+		t.Skip("neither pyarrow nor duckdb is installed")
+	}
+}
+
+// TestLayout check what every reader relies on first, as the format lays it
+// out: the magic at both ends and the footer length before the last one
+func TestLayout(t *testing.T) {
+	schema := []Column{{Name: "website_id", Type: String}}
+	tests := []struct {
+		name string
+		rows [][]interface{}
+	}{
+		{name: "should lay out an empty file", rows: nil},
+		{name: "should lay out a file with rows", rows: [][]interface{}{{"a"}, {"b"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := writeRows(t, schema, tt.rows)
+			if !bytes.HasPrefix(data, []byte(magic)) || !bytes.HasSuffix(data, []byte(magic)) {
+				t.Fatalf("file does not start and end with %s", magic)
+			}
+			footer := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+			if footer <= 0 || len(magic)+footer+8 > len(data) {
+				t.Fatalf("footer length = %d of a %d bytes file", footer, len(data))
+			}
+			if !bytes.Contains(data[len(data)-8-footer:], []byte(CreatedBy)) {
+				t.Errorf("footer does not name %q", CreatedBy)
+			}
+		})
+	}
+}
+
+func writeRows(t *testing.T, schema []Column, rows [][]interface{}) []byte {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, schema)
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// asJSON rows as decoded from JSON, numbers as float64
+func asJSON(t *testing.T, rows [][]interface{}) [][]interface{} {
+	data, err := json.Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded [][]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
@@ -0,0 +1,255 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Type of a column, every column is required
+type Type int
+
+const (
+	// String UTF-8 byte array
+	String Type = iota
+	// Int64 signed 64 bit integer
+	Int64
+	// TimestampMillis milliseconds since the epoch in UTC, an int64
+	TimestampMillis
+)
+
+// RowGroupRows rows buffered before a row group is written
+const RowGroupRows = 10000
+
+// CreatedBy writer named in the files
+const CreatedBy = "analytics-api parquet writer"
+
+const magic = "PAR1"
+
+// Physical types, converted types and encodings of the parquet format
+const (
+	physicalInt64     = 2
+	physicalByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+
+	repetitionRequired = 0
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecUncompressed = 0
+	pageData          = 0
+)
+
+// ErrClosed ...
+var ErrClosed = errors.New("parquet: writer closed")
+
+// Column of the schema of a file
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Writer write rows to a parquet file of flat required columns, PLAIN encoded
+// and uncompressed so any reader can scan it
+type Writer struct {
+	w         io.Writer
+	schema    []Column
+	offset    int64
+	values    [][]byte
+	rows      int64
+	total     int64
+	rowGroups []rowGroup
+	closed    bool
+}
+
+type rowGroup struct {
+	rows    int64
+	size    int64
+	columns []columnChunk
+}
+
+type columnChunk struct {
+	offset int64
+	size   int64
+}
+
+// NewWriter file with schema written to w
+func NewWriter(w io.Writer, schema []Column) *Writer {
+	return &Writer{
+		w:      w,
+		schema: schema,
+		values: make([][]byte, len(schema)),
+	}
+}
+
+// Write add a row, a string for String columns and an int64 for the others
+func (instance *Writer) Write(row []interface{}) error {
+	if instance.closed {
+		return ErrClosed
+	}
+	if len(row) != len(instance.schema) {
+		return fmt.Errorf("parquet: row has %d values for %d columns", len(row), len(instance.schema))
+	}
+	for i, column := range instance.schema {
+		switch column.Type {
+		case String:
+			v, ok := row[i].(string)
+			if !ok {
+				return fmt.Errorf("parquet: column %s wants a string, got %T", column.Name, row[i])
+			}
+			instance.values[i] = binary.LittleEndian.AppendUint32(instance.values[i], uint32(len(v)))
+			instance.values[i] = append(instance.values[i], v...)
+		default:
+			v, ok := row[i].(int64)
+			if !ok {
+				return fmt.Errorf("parquet: column %s wants an int64, got %T", column.Name, row[i])
+			}
+			instance.values[i] = binary.LittleEndian.AppendUint64(instance.values[i], uint64(v))
+		}
+	}
+	instance.rows++
+	if instance.rows >= RowGroupRows {
+		return instance.Flush()
+	}
+	return nil
+}
+
+// Rows written so far
+func (instance *Writer) Rows() int64 {
+	return instance.total + instance.rows
+}
+
+// Flush write the buffered rows as a row group
+func (instance *Writer) Flush() error {
+	if instance.closed {
+		return ErrClosed
+	}
+	if instance.rows == 0 {
+		return nil
+	}
+	if instance.offset == 0 {
+		if err := instance.write([]byte(magic)); err != nil {
+			return err
+		}
+	}
+
+	group := rowGroup{rows: instance.rows}
+	for i := range instance.schema {
+		header := newThriftWriter()
+		header.i32(1, pageData)
+		header.i32(2, int32(len(instance.values[i])))
+		header.i32(3, int32(len(instance.values[i])))
+		header.beginStruct(5)
+		header.i32(1, int32(instance.rows))
+		header.i32(2, encodingPlain)
+		header.i32(3, encodingRLE)
+		header.i32(4, encodingRLE)
+		header.endStruct()
+		header.endStruct()
+
+		chunk := columnChunk{offset: instance.offset, size: int64(len(header.buf) + len(instance.values[i]))}
+		if err := instance.write(header.buf); err != nil {
+			return err
+		}
+		if err := instance.write(instance.values[i]); err != nil {
+			return err
+		}
+		group.columns = append(group.columns, chunk)
+		group.size += chunk.size
+		instance.values[i] = instance.values[i][:0]
+	}
+	instance.rowGroups = append(instance.rowGroups, group)
+	instance.total += instance.rows
+	instance.rows = 0
+	return nil
+}
+
+// Close flush the buffered rows and write the footer, w is not closed
+func (instance *Writer) Close() error {
+	if err := instance.Flush(); err != nil {
+		return err
+	}
+	instance.closed = true
+	if instance.offset == 0 {
+		if err := instance.write([]byte(magic)); err != nil {
+			return err
+		}
+	}
+
+	meta := newThriftWriter()
+	meta.i32(1, 1)
+	meta.list(2, thriftStruct, len(instance.schema)+1)
+	meta.beginStruct(0)
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(instance.schema)))
+	meta.endStruct()
+	for _, column := range instance.schema {
+		meta.beginStruct(0)
+		physical, converted := columnTypes(column.Type)
+		meta.i32(1, physical)
+		meta.i32(3, repetitionRequired)
+		meta.binary(4, column.Name)
+		if converted >= 0 {
+			meta.i32(6, converted)
+		}
+		meta.endStruct()
+	}
+	meta.i64(3, instance.total)
+	meta.list(4, thriftStruct, len(instance.rowGroups))
+	for _, group := range instance.rowGroups {
+		meta.beginStruct(0)
+		meta.list(1, thriftStruct, len(group.columns))
+		for i, chunk := range group.columns {
+			physical, _ := columnTypes(instance.schema[i].Type)
+			meta.beginStruct(0)
+			meta.i64(2, chunk.offset)
+			meta.beginStruct(3)
+			meta.i32(1, physical)
+			meta.list(2, thriftI32, 2)
+			meta.zigzag(encodingPlain)
+			meta.zigzag(encodingRLE)
+			meta.list(3, thriftBinary, 1)
+			meta.rawBinary(instance.schema[i].Name)
+			meta.i32(4, codecUncompressed)
+			meta.i64(5, group.rows)
+			meta.i64(6, chunk.size)
+			meta.i64(7, chunk.size)
+			meta.i64(9, chunk.offset)
+			meta.endStruct()
+			meta.endStruct()
+		}
+		meta.i64(2, group.size)
+		meta.i64(3, group.rows)
+		meta.endStruct()
+	}
+	meta.binary(6, CreatedBy)
+	meta.endStruct()
+
+	if err := instance.write(meta.buf); err != nil {
+		return err
+	}
+	if err := instance.write(binary.LittleEndian.AppendUint32(nil, uint32(len(meta.buf)))); err != nil {
+		return err
+	}
+	return instance.write([]byte(magic))
+}
+
+func (instance *Writer) write(b []byte) error {
+	n, err := instance.w.Write(b)
+	instance.offset += int64(n)
+	return err
+}
+
+func columnTypes(columnType Type) (int32, int32) {
+	switch columnType {
+	case String:
+		return physicalByteArray, convertedUTF8
+	case TimestampMillis:
+		return physicalInt64, convertedTimestampMillis
+	}
+	return physicalInt64, convertedNone
+}
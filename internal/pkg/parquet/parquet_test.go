@@ -0,0 +1,182 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestThriftWriter(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(w *thriftWriter)
+		want  []byte
+	}{
+		{
+			name:  "should encode short field delta",
+			write: func(w *thriftWriter) { w.i32(1, 3) },
+			want:  []byte{0x15, 0x06},
+		},
+		{
+			name:  "should encode long field delta with its id",
+			write: func(w *thriftWriter) { w.i64(20, -1) },
+			want:  []byte{0x06, 0x28, 0x01},
+		},
+		{
+			name:  "should encode binary",
+			write: func(w *thriftWriter) { w.binary(4, "ab") },
+			want:  []byte{0x48, 0x02, 'a', 'b'},
+		},
+		{
+			name: "should reset field ids inside structs",
+			write: func(w *thriftWriter) {
+				w.i32(2, 0)
+				w.beginStruct(5)
+				w.i32(1, 1)
+				w.endStruct()
+			},
+			want: []byte{0x25, 0x00, 0x3c, 0x15, 0x02, 0x00},
+		},
+		{
+			name:  "should encode long list size",
+			write: func(w *thriftWriter) { w.list(1, thriftI32, 20) },
+			want:  []byte{0x19, 0xf5, 0x14},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newThriftWriter()
+			tt.write(w)
+			if !reflect.DeepEqual(w.buf, tt.want) {
+				t.Errorf("buf = % x, want % x", w.buf, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriter(t *testing.T) {
+	schema := []Column{{Name: "website_id", Type: String}, {Name: "type", Type: Int64}, {Name: "timestamp", Type: TimestampMillis}}
+	tests := []struct {
+		name     string
+		rows     [][]interface{}
+		wantRows int64
+		wantErr  bool
+	}{
+		{name: "should write empty file", rows: nil},
+		{name: "should write rows", rows: [][]interface{}{{"a", int64(4), int64(1)}, {"b", int64(5), int64(2)}}, wantRows: 2},
+		{name: "should reject wrong type", rows: [][]interface{}{{"a", 4, int64(1)}}, wantErr: true},
+		{name: "should reject missing column", rows: [][]interface{}{{"a", int64(4)}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf, schema)
+			var err error
+			for _, row := range tt.rows {
+				if err = w.Write(row); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if w.Rows() != tt.wantRows {
+				t.Errorf("Rows() = %d, want %d", w.Rows(), tt.wantRows)
+			}
+
+			data := buf.Bytes()
+			if !bytes.HasPrefix(data, []byte(magic)) || !bytes.HasSuffix(data, []byte(magic)) {
+				t.Fatalf("file not framed by %s", magic)
+			}
+			footer := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+			if footer <= 0 || footer > len(data)-12 {
+				t.Errorf("footer length = %d of file %d", footer, len(data))
+			}
+			if !bytes.Contains(data[len(data)-8-footer:], []byte(CreatedBy)) {
+				t.Errorf("footer misses created_by")
+			}
+			if err := w.Write([]interface{}{"c", int64(1), int64(1)}); err != ErrClosed {
+				t.Errorf("Write() after Close error = %v, want %v", err, ErrClosed)
+			}
+		})
+	}
+}
+
+func TestReader(t *testing.T) {
+	schema := []Column{{Name: "website_id", Type: String}, {Name: "type", Type: Int64}, {Name: "timestamp", Type: TimestampMillis}}
+	many := make([][]interface{}, RowGroupRows+3)
+	for i := range many {
+		many[i] = []interface{}{"w", int64(i), int64(i * 10)}
+	}
+	tests := []struct {
+		name    string
+		rows    [][]interface{}
+		corrupt func(data []byte) []byte
+		wantErr error
+	}{
+		{name: "should read empty file", rows: nil},
+		{name: "should read rows", rows: [][]interface{}{{"a", int64(4), int64(1)}, {"", int64(-5), int64(2)}}},
+		{name: "should read row groups", rows: many},
+		{name: "should reject truncated file", rows: [][]interface{}{{"a", int64(4), int64(1)}}, corrupt: func(data []byte) []byte { return data[:len(data)-5] }, wantErr: ErrInvalid},
+		{
+			name: "should reject compressed column",
+			rows: [][]interface{}{{"a", int64(4), int64(1)}},
+			corrupt: func(data []byte) []byte {
+				// codec of the first column chunk, after its path in schema
+				i := bytes.Index(data, []byte{0x19, 0x18, 0x0a, 'w', 'e', 'b', 's', 'i', 't', 'e', '_', 'i', 'd', 0x15, 0x00})
+				data[i+14] = 0x02
+				return data
+			},
+			wantErr: ErrUnsupported,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf, schema)
+			for _, row := range tt.rows {
+				if err := w.Write(row); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			data := buf.Bytes()
+			if tt.corrupt != nil {
+				data = tt.corrupt(data)
+			}
+
+			r, err := NewReader(data)
+			if err != tt.wantErr {
+				t.Fatalf("NewReader() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(r.Schema(), schema) {
+				t.Errorf("Schema() = %v, want %v", r.Schema(), schema)
+			}
+			if r.Rows() != int64(len(tt.rows)) {
+				t.Errorf("Rows() = %d, want %d", r.Rows(), len(tt.rows))
+			}
+			var got [][]interface{}
+			if err := r.Read(func(row []interface{}) error {
+				got = append(got, row)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.rows) {
+				t.Errorf("Read() rows = %d, want %d", len(got), len(tt.rows))
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ErrInvalid ...
+var ErrInvalid = errors.New("parquet: invalid file")
+
+// ErrUnsupported ...
+var ErrUnsupported = errors.New("parquet: only flat required columns, PLAIN encoded and uncompressed, are read")
+
+const (
+	convertedNone = -1
+	pageDataV1    = 0
+)
+
+// Reader read the files Writer writes, flat required columns PLAIN encoded and
+// uncompressed. Files of other writers using more of the format are rejected
+// with ErrUnsupported
+type Reader struct {
+	r         io.ReaderAt
+	size      int64
+	schema    []Column
+	rows      int64
+	rowGroups []readGroup
+}
+
+type readGroup struct {
+	rows    int64
+	columns []readChunk
+}
+
+type readChunk struct {
+	offset int64
+	size   int64
+	values int64
+	codec  int64
+}
+
+// NewReader parse the footer of the file in data
+func NewReader(data []byte) (*Reader, error) {
+	return NewReaderAt(bytes.NewReader(data), int64(len(data)))
+}
+
+// NewReaderAt parse the footer of the file of size bytes in r. Only the
+// footer is read here and a row group at a time by Read, so a file is never
+// held in memory at once
+func NewReaderAt(r io.ReaderAt, size int64) (*Reader, error) {
+	if size < 12 {
+		return nil, ErrInvalid
+	}
+	head, tail := make([]byte, 4), make([]byte, 8)
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return nil, err
+	}
+	if _, err := r.ReadAt(tail, size-8); err != nil {
+		return nil, err
+	}
+	if string(head) != magic || string(tail[4:]) != magic {
+		return nil, ErrInvalid
+	}
+	footer := int64(binary.LittleEndian.Uint32(tail))
+	if footer <= 0 || footer > size-12 {
+		return nil, ErrInvalid
+	}
+	buf := make([]byte, footer)
+	if _, err := r.ReadAt(buf, size-8-footer); err != nil {
+		return nil, err
+	}
+
+	instance := &Reader{r: r, size: size}
+	meta := &thriftReader{buf: buf}
+	err := meta.readStruct(func(id int16, fieldType byte) error {
+		switch id {
+		case 2:
+			return instance.readSchema(meta)
+		case 3:
+			rows, err := meta.int(fieldType)
+			instance.rows = rows
+			return err
+		case 4:
+			_, size, err := meta.list()
+			if err != nil {
+				return err
+			}
+			for i := 0; i < size; i++ {
+				group, err := readRowGroup(meta)
+				if err != nil {
+					return err
+				}
+				instance.rowGroups = append(instance.rowGroups, group)
+			}
+			return nil
+		}
+		return meta.skip(fieldType)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range instance.rowGroups {
+		if len(group.columns) != len(instance.schema) {
+			return nil, ErrInvalid
+		}
+	}
+	return instance, nil
+}
+
+// readSchema columns of the schema list, the root element first
+func (instance *Reader) readSchema(meta *thriftReader) error {
+	_, size, err := meta.list()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < size; i++ {
+		var name string
+		physical, repetition, converted, children := int64(-1), int64(repetitionRequired), int64(convertedNone), int64(0)
+		err := meta.readStruct(func(id int16, fieldType byte) error {
+			var err error
+			switch id {
+			case 1:
+				physical, err = meta.int(fieldType)
+			case 3:
+				repetition, err = meta.int(fieldType)
+			case 4:
+				var v []byte
+				v, err = meta.binary()
+				name = string(v)
+			case 5:
+				children, err = meta.int(fieldType)
+			case 6:
+				converted, err = meta.int(fieldType)
+			default:
+				err = meta.skip(fieldType)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			if children != int64(size-1) {
+				return ErrUnsupported
+			}
+			continue
+		}
+		if children != 0 || repetition != repetitionRequired {
+			return ErrUnsupported
+		}
+		column := Column{Name: name}
+		switch {
+		case physical == physicalByteArray:
+			column.Type = String
+		case physical == physicalInt64 && converted == convertedTimestampMillis:
+			column.Type = TimestampMillis
+		case physical == physicalInt64:
+			column.Type = Int64
+		default:
+			return ErrUnsupported
+		}
+		instance.schema = append(instance.schema, column)
+	}
+	return nil
+}
+
+func readRowGroup(meta *thriftReader) (readGroup, error) {
+	var group readGroup
+	err := meta.readStruct(func(id int16, fieldType byte) error {
+		switch id {
+		case 1:
+			_, size, err := meta.list()
+			if err != nil {
+				return err
+			}
+			for i := 0; i < size; i++ {
+				chunk, err := readColumnChunk(meta)
+				if err != nil {
+					return err
+				}
+				group.columns = append(group.columns, chunk)
+			}
+			return nil
+		case 3:
+			rows, err := meta.int(fieldType)
+			group.rows = rows
+			return err
+		}
+		return meta.skip(fieldType)
+	})
+	return group, err
+}
+
+func readColumnChunk(meta *thriftReader) (readChunk, error) {
+	chunk := readChunk{offset: -1}
+	dictionary := false
+	err := meta.readStruct(func(id int16, fieldType byte) error {
+		if id != 3 {
+			return meta.skip(fieldType)
+		}
+		return meta.readStruct(func(id int16, fieldType byte) error {
+			var err error
+			switch id {
+			case 4:
+				chunk.codec, err = meta.int(fieldType)
+			case 5:
+				chunk.values, err = meta.int(fieldType)
+			case 7:
+				chunk.size, err = meta.int(fieldType)
+			case 9:
+				chunk.offset, err = meta.int(fieldType)
+			case 11:
+				dictionary = true
+				err = meta.skip(fieldType)
+			default:
+				err = meta.skip(fieldType)
+			}
+			return err
+		})
+	})
+	if err != nil {
+		return chunk, err
+	}
+	if chunk.codec != codecUncompressed || dictionary {
+		return chunk, ErrUnsupported
+	}
+	if chunk.size <= 0 {
+		return chunk, ErrInvalid
+	}
+	return chunk, nil
+}
+
+// Schema columns of the file
+func (instance *Reader) Schema() []Column {
+	return instance.schema
+}
+
+// Rows of the file
+func (instance *Reader) Rows() int64 {
+	return instance.rows
+}
+
+// Read call fn with each row, a string for String columns and an int64 for
+// the others like Writer takes them. A row group is read, with one ReadAt of
+// its column chunks, and decoded at a time
+func (instance *Reader) Read(fn func(row []interface{}) error) error {
+	for _, group := range instance.rowGroups {
+		data, base, err := instance.readGroup(group)
+		if err != nil {
+			return err
+		}
+		columns := make([][]interface{}, len(instance.schema))
+		for i, chunk := range group.columns {
+			values, err := readChunkValues(data, chunk.offset-base, chunk.values, instance.schema[i].Type)
+			if err != nil {
+				return err
+			}
+			if int64(len(values)) != group.rows {
+				return ErrInvalid
+			}
+			columns[i] = values
+		}
+		for r := int64(0); r < group.rows; r++ {
+			row := make([]interface{}, len(columns))
+			for i := range columns {
+				row[i] = columns[i][r]
+			}
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readGroup bytes of the column chunks of group, from the offset of the first
+// one returned with them
+func (instance *Reader) readGroup(group readGroup) ([]byte, int64, error) {
+	start, end := instance.size, int64(0)
+	for _, chunk := range group.columns {
+		if chunk.offset < 4 || chunk.offset+chunk.size > instance.size-8 {
+			return nil, 0, ErrInvalid
+		}
+		start = min(start, chunk.offset)
+		end = max(end, chunk.offset+chunk.size)
+	}
+	if start >= end {
+		return nil, start, nil
+	}
+	data := make([]byte, end-start)
+	if _, err := instance.r.ReadAt(data, start); err != nil {
+		return nil, 0, err
+	}
+	return data, start, nil
+}
+
+// readChunkValues values of the data pages of a column chunk starting at
+// offset in data
+func readChunkValues(data []byte, offset, count int64, columnType Type) ([]interface{}, error) {
+	values := make([]interface{}, 0, count)
+	for int64(len(values)) < count {
+		if offset < 0 || offset >= int64(len(data)) {
+			return nil, ErrInvalid
+		}
+		header := &thriftReader{buf: data[offset:]}
+		pageType, size, pageCount, encoding := int64(-1), int64(-1), int64(0), int64(encodingPlain)
+		err := header.readStruct(func(id int16, fieldType byte) error {
+			var err error
+			switch id {
+			case 1:
+				pageType, err = header.int(fieldType)
+			case 3:
+				size, err = header.int(fieldType)
+			case 5:
+				err = header.readStruct(func(id int16, fieldType byte) error {
+					var err error
+					switch id {
+					case 1:
+						pageCount, err = header.int(fieldType)
+					case 2:
+						encoding, err = header.int(fieldType)
+					default:
+						err = header.skip(fieldType)
+					}
+					return err
+				})
+			default:
+				err = header.skip(fieldType)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if pageType != pageDataV1 || encoding != encodingPlain {
+			return nil, ErrUnsupported
+		}
+		start := offset + int64(header.pos)
+		if size < 0 || start+size > int64(len(data)) {
+			return nil, ErrInvalid
+		}
+		page := data[start : start+size]
+		for i := int64(0); i < pageCount; i++ {
+			var value interface{}
+			if columnType == String {
+				if len(page) < 4 {
+					return nil, ErrInvalid
+				}
+				n := binary.LittleEndian.Uint32(page)
+				if uint64(n) > uint64(len(page)-4) {
+					return nil, ErrInvalid
+				}
+				value = string(page[4 : 4+n])
+				page = page[4+n:]
+			} else {
+				if len(page) < 8 {
+					return nil, ErrInvalid
+				}
+				value = int64(binary.LittleEndian.Uint64(page))
+				page = page[8:]
+			}
+			values = append(values, value)
+		}
+		offset = start + size
+	}
+	return values, nil
+}
@@ -0,0 +1,261 @@
+package parquet
+
+import (
+	"encoding/binary"
+)
+
+// Types of the thrift compact protocol, the encoding of parquet metadata
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encode structs with the thrift compact protocol, fields must
+// be written in increasing id order
+type thriftWriter struct {
+	buf    []byte
+	lastID []int16
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{lastID: []int16{0}}
+}
+
+func (instance *thriftWriter) varint(v uint64) {
+	instance.buf = binary.AppendUvarint(instance.buf, v)
+}
+
+func (instance *thriftWriter) zigzag(v int64) {
+	instance.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (instance *thriftWriter) field(id int16, fieldType byte) {
+	last := &instance.lastID[len(instance.lastID)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		instance.buf = append(instance.buf, byte(delta)<<4|fieldType)
+	} else {
+		instance.buf = append(instance.buf, fieldType)
+		instance.zigzag(int64(id))
+	}
+	*last = id
+}
+
+func (instance *thriftWriter) i32(id int16, v int32) {
+	instance.field(id, thriftI32)
+	instance.zigzag(int64(v))
+}
+
+func (instance *thriftWriter) i64(id int16, v int64) {
+	instance.field(id, thriftI64)
+	instance.zigzag(v)
+}
+
+func (instance *thriftWriter) binary(id int16, v string) {
+	instance.field(id, thriftBinary)
+	instance.rawBinary(v)
+}
+
+func (instance *thriftWriter) rawBinary(v string) {
+	instance.varint(uint64(len(v)))
+	instance.buf = append(instance.buf, v...)
+}
+
+// list start a list field of size elements of elemType, written right after
+func (instance *thriftWriter) list(id int16, elemType byte, size int) {
+	instance.field(id, thriftList)
+	if size < 15 {
+		instance.buf = append(instance.buf, byte(size)<<4|elemType)
+		return
+	}
+	instance.buf = append(instance.buf, 0xf0|elemType)
+	instance.varint(uint64(size))
+}
+
+// beginStruct start a struct field, or a struct element of a list when id is 0
+func (instance *thriftWriter) beginStruct(id int16) {
+	if id != 0 {
+		instance.field(id, thriftStruct)
+	}
+	instance.lastID = append(instance.lastID, 0)
+}
+
+func (instance *thriftWriter) endStruct() {
+	instance.buf = append(instance.buf, 0)
+	instance.lastID = instance.lastID[:len(instance.lastID)-1]
+}
+
+// Types of the compact protocol only seen when reading
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftByte   = 3
+	thriftI16    = 4
+	thriftDouble = 7
+	thriftSet    = 10
+	thriftMap    = 11
+)
+
+// thriftReader decode structs of the thrift compact protocol, fields the
+// reader does not know are skipped
+type thriftReader struct {
+	buf []byte
+	pos int
+}
+
+func (instance *thriftReader) byte() (byte, error) {
+	if instance.pos >= len(instance.buf) {
+		return 0, ErrInvalid
+	}
+	b := instance.buf[instance.pos]
+	instance.pos++
+	return b, nil
+}
+
+func (instance *thriftReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(instance.buf[instance.pos:])
+	if n <= 0 {
+		return 0, ErrInvalid
+	}
+	instance.pos += n
+	return v, nil
+}
+
+func (instance *thriftReader) zigzag() (int64, error) {
+	v, err := instance.varint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+func (instance *thriftReader) binary() ([]byte, error) {
+	size, err := instance.varint()
+	if err != nil {
+		return nil, err
+	}
+	if size > uint64(len(instance.buf)-instance.pos) {
+		return nil, ErrInvalid
+	}
+	v := instance.buf[instance.pos : instance.pos+int(size)]
+	instance.pos += int(size)
+	return v, nil
+}
+
+// list header of a list or set, its elements follow
+func (instance *thriftReader) list() (byte, int, error) {
+	b, err := instance.byte()
+	if err != nil {
+		return 0, 0, err
+	}
+	size := uint64(b >> 4)
+	if size == 15 {
+		if size, err = instance.varint(); err != nil {
+			return 0, 0, err
+		}
+	}
+	if size > uint64(len(instance.buf)-instance.pos) {
+		return 0, 0, ErrInvalid
+	}
+	return b & 0x0f, int(size), nil
+}
+
+// readStruct call fn with the id and type of each field of a struct, fn
+// reads the value or calls skip
+func (instance *thriftReader) readStruct(fn func(id int16, fieldType byte) error) error {
+	var last int16
+	for {
+		b, err := instance.byte()
+		if err != nil {
+			return err
+		}
+		if b == 0 {
+			return nil
+		}
+		id := last + int16(b>>4)
+		if b>>4 == 0 {
+			long, err := instance.zigzag()
+			if err != nil {
+				return err
+			}
+			id = int16(long)
+		}
+		last = id
+		if err := fn(id, b&0x0f); err != nil {
+			return err
+		}
+	}
+}
+
+// skip value of a field of fieldType
+func (instance *thriftReader) skip(fieldType byte) error {
+	switch fieldType {
+	case thriftTrue, thriftFalse:
+		return nil
+	case thriftByte:
+		_, err := instance.byte()
+		return err
+	case thriftI16, thriftI32, thriftI64:
+		_, err := instance.varint()
+		return err
+	case thriftDouble:
+		if len(instance.buf)-instance.pos < 8 {
+			return ErrInvalid
+		}
+		instance.pos += 8
+		return nil
+	case thriftBinary:
+		_, err := instance.binary()
+		return err
+	case thriftList, thriftSet:
+		elemType, size, err := instance.list()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < size; i++ {
+			if err := instance.skipElement(elemType); err != nil {
+				return err
+			}
+		}
+		return nil
+	case thriftMap:
+		size, err := instance.varint()
+		if err != nil || size == 0 {
+			return err
+		}
+		types, err := instance.byte()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < size; i++ {
+			if err := instance.skipElement(types >> 4); err != nil {
+				return err
+			}
+			if err := instance.skipElement(types & 0x0f); err != nil {
+				return err
+			}
+		}
+		return nil
+	case thriftStruct:
+		return instance.readStruct(func(_ int16, fieldType byte) error {
+			return instance.skip(fieldType)
+		})
+	}
+	return ErrInvalid
+}
+
+// skipElement skip an element of a list or map, where booleans take a byte
+func (instance *thriftReader) skipElement(elemType byte) error {
+	if elemType == thriftTrue || elemType == thriftFalse {
+		_, err := instance.byte()
+		return err
+	}
+	return instance.skip(elemType)
+}
+
+// int read an integer field as an int64
+func (instance *thriftReader) int(fieldType byte) (int64, error) {
+	if fieldType != thriftI32 && fieldType != thriftI64 && fieldType != thriftI16 {
+		return 0, ErrInvalid
+	}
+	return instance.zigzag()
+}
@@ -0,0 +1,56 @@
+package pathgroup
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalidRule ...
+var ErrInvalidRule = errors.New("content group needs a name and a pattern starting with /")
+
+// Rule pages whose path matches Pattern belong to the group Name. A * in
+// Pattern matches any characters, / included, so /blog/* matches every page
+// under /blog/
+type Rule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// Validate ...
+func Validate(rule Rule) error {
+	if strings.TrimSpace(rule.Name) == "" || !strings.HasPrefix(rule.Pattern, "/") {
+		return ErrInvalidRule
+	}
+	return nil
+}
+
+// Match report whether path matches pattern
+func Match(pattern, path string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == path
+	}
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	path = path[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		index := strings.Index(path, part)
+		if index < 0 {
+			return false
+		}
+		path = path[index+len(part):]
+	}
+	return strings.HasSuffix(path, last)
+}
+
+// Group name of the first rule path matches, empty when none does
+func Group(rules []Rule, path string) string {
+	for _, rule := range rules {
+		if Match(rule.Pattern, path) {
+			return rule.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,53 @@
+package pathgroup
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		path    string
+		want    bool
+	}{
+		{name: "should match exact path", pattern: "/pricing", path: "/pricing", want: true},
+		{name: "should not match other path", pattern: "/pricing", path: "/pricing/team", want: false},
+		{name: "should match page under prefix", pattern: "/blog/*", path: "/blog/hello", want: true},
+		{name: "should match nested page under prefix", pattern: "/blog/*", path: "/blog/2024/hello", want: true},
+		{name: "should not match prefix itself", pattern: "/blog/*", path: "/blog", want: false},
+		{name: "should match wildcard in the middle", pattern: "/docs/*/intro", path: "/docs/v2/intro", want: true},
+		{name: "should not match wrong suffix", pattern: "/docs/*/intro", path: "/docs/v2/setup", want: false},
+		{name: "should match several wildcards", pattern: "/*/shop/*", path: "/en/shop/shoes", want: true},
+		{name: "should not reuse characters for suffix", pattern: "/a*ab", path: "/ab", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Match(tt.pattern, tt.path); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroup(t *testing.T) {
+	rules := []Rule{
+		{Name: "Docs", Pattern: "/docs/*"},
+		{Name: "Blog", Pattern: "/blog/*"},
+		{Name: "Content", Pattern: "/*"},
+	}
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "should use first matching rule", path: "/blog/hello", want: "Blog"},
+		{name: "should fall through to catch-all", path: "/about", want: "Content"},
+		{name: "should be empty when no rule matches", path: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Group(rules, tt.path); got != tt.want {
+				t.Errorf("Group() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
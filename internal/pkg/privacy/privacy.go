@@ -0,0 +1,44 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"strconv"
+)
+
+// Epsilon privacy budget of a noised count, a visitor changes a count of
+// sessions by at most one so the noise has scale 1/Epsilon
+const Epsilon = 0.5
+
+// laplace noise of scale drawn from u, a uniform value in (0, 1)
+func laplace(u, scale float64) float64 {
+	u -= 0.5
+	if u < 0 {
+		return scale * math.Log(1+2*u)
+	}
+	return -scale * math.Log(1-2*u)
+}
+
+// uniform value in (0, 1) derived from seed and key, the same key always
+// draws the same value so asking again does not average the noise out
+func uniform(seed []byte, key string) float64 {
+	mac := hmac.New(sha256.New, seed)
+	mac.Write([]byte(key))
+	bits := binary.BigEndian.Uint64(mac.Sum(nil)) >> 11
+	return (float64(bits) + 0.5) / (1 << 53)
+}
+
+// Count count with Laplace noise of Epsilon, rounded and never negative. key
+// names the count, like the value it is of, but not the date range asked: the
+// noise is drawn from the key and the count itself, so ranges holding the same
+// data return the same number and cannot be asked over and over to average
+// the noise out
+func Count(seed []byte, key string, count int64) int64 {
+	noised := math.Round(float64(count) + laplace(uniform(seed, key+"|"+strconv.FormatInt(count, 10)), 1/Epsilon))
+	if noised < 0 {
+		return 0
+	}
+	return int64(noised)
+}
@@ -0,0 +1,77 @@
+package privacy
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestLaplace(t *testing.T) {
+	tests := []struct {
+		name string
+		u    float64
+		want float64
+	}{
+		{name: "should not move the median", u: 0.5, want: 0},
+		{name: "should add above the median", u: 1 - 0.5*math.Exp(-1), want: 2},
+		{name: "should remove below the median", u: 0.5 * math.Exp(-1), want: -2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := laplace(tt.u, 2); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("laplace() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCount(t *testing.T) {
+	seed := []byte("seed")
+	tests := []struct {
+		name  string
+		check func() bool
+	}{
+		{name: "should draw the same noise for the same key", check: func() bool {
+			return Count(seed, "path:/blog", 40) == Count(seed, "path:/blog", 40)
+		}},
+		{name: "should draw other noise for another count", check: func() bool {
+			for i := int64(0); i < 20; i++ {
+				if Count(seed, "path:/blog", 1000+i)-i != Count(seed, "path:/blog", 1000) {
+					return true
+				}
+			}
+			return false
+		}},
+		{name: "should draw other noise with another seed", check: func() bool {
+			for i := 0; i < 20; i++ {
+				key := strconv.Itoa(i)
+				if Count(seed, key, 1000) != Count([]byte("other"), key, 1000) {
+					return true
+				}
+			}
+			return false
+		}},
+		{name: "should never be negative", check: func() bool {
+			for i := 0; i < 200; i++ {
+				if Count(seed, strconv.Itoa(i), 0) < 0 {
+					return false
+				}
+			}
+			return true
+		}},
+		{name: "should stay close on average", check: func() bool {
+			var sum int64
+			for i := 0; i < 2000; i++ {
+				sum += Count(seed, strconv.Itoa(i), 1000)
+			}
+			return math.Abs(float64(sum)/2000-1000) < 0.5
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.check() {
+				t.Errorf("Count() %s", tt.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package push
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"analytics-api/internal/pkg/request"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// APNs sender of the Apple Push Notification service, used for ios devices
+type APNs struct {
+	KeyID    string
+	TeamID   string
+	Topic    string
+	Key      *ecdsa.PrivateKey
+	Endpoint string
+	HTTP     *http.Client
+
+	mu     sync.Mutex
+	token  string
+	issued time.Time
+}
+
+// NewAPNs sender authenticated with a .p8 signing key, topic is the bundle id of the app
+func NewAPNs(key []byte, keyID, teamID, topic string, sandbox bool) (*APNs, error) {
+	signingKey, err := jwt.ParseECPrivateKeyFromPEM(key)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := "https://api.push.apple.com"
+	if sandbox {
+		endpoint = "https://api.sandbox.push.apple.com"
+	}
+	return &APNs{
+		KeyID:    keyID,
+		TeamID:   teamID,
+		Topic:    topic,
+		Key:      signingKey,
+		Endpoint: endpoint,
+		HTTP:     request.NewPublicClient(10 * time.Second),
+	}, nil
+}
+
+// Send ...
+func (instance *APNs) Send(token string, notification Notification) error {
+	authToken, err := instance.authToken()
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]string{
+				"title": notification.Title,
+				"body":  notification.Body,
+			},
+		},
+	}
+	for name, value := range notification.Data {
+		if name != "aps" {
+			payload[name] = value
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, instance.Endpoint+"/3/device/"+token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+authToken)
+	req.Header.Set("apns-topic", instance.Topic)
+	req.Header.Set("apns-push-type", "alert")
+
+	res, err := instance.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(res.Body)
+
+	switch {
+	case res.StatusCode == http.StatusOK:
+		return nil
+	case res.StatusCode == http.StatusGone || bytes.Contains(data, []byte("BadDeviceToken")):
+		return ErrUnregistered
+	default:
+		return serviceError("apns", res.Status, data)
+	}
+}
+
+// authToken provider token, apple rejects tokens older than an hour and
+// throttles tokens renewed more often than every 20 minutes
+func (instance *APNs) authToken() (string, error) {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	if instance.token != "" && time.Since(instance.issued) < 40*time.Minute {
+		return instance.token, nil
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": instance.TeamID,
+		"iat": now.Unix(),
+	})
+	token.Header["kid"] = instance.KeyID
+	signed, err := token.SignedString(instance.Key)
+	if err != nil {
+		return "", err
+	}
+	instance.token = signed
+	instance.issued = now
+	return signed, nil
+}
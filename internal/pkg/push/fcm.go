@@ -0,0 +1,154 @@
+package push
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"analytics-api/internal/pkg/request"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// FCM sender of Firebase Cloud Messaging HTTP v1, used for android devices
+type FCM struct {
+	ProjectID   string
+	ClientEmail string
+	Key         *rsa.PrivateKey
+	TokenURL    string
+	Endpoint    string
+	HTTP        *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiry      time.Time
+}
+
+// NewFCM sender authenticated with a service account credentials file
+func NewFCM(credentials []byte) (*FCM, error) {
+	var account struct {
+		ProjectID   string `json:"project_id"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(credentials, &account); err != nil {
+		return nil, err
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(account.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return &FCM{
+		ProjectID:   account.ProjectID,
+		ClientEmail: account.ClientEmail,
+		Key:         key,
+		TokenURL:    account.TokenURI,
+		Endpoint:    "https://fcm.googleapis.com",
+		HTTP:        request.NewPublicClient(10 * time.Second),
+	}, nil
+}
+
+// Send ...
+func (instance *FCM) Send(token string, notification Notification) error {
+	accessToken, err := instance.token()
+	if err != nil {
+		return err
+	}
+
+	message := map[string]interface{}{
+		"message": map[string]interface{}{
+			"token": token,
+			"notification": map[string]string{
+				"title": notification.Title,
+				"body":  notification.Body,
+			},
+			"data": notification.Data,
+		},
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost,
+		fmt.Sprintf("%s/v1/projects/%s/messages:send", instance.Endpoint, instance.ProjectID), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := instance.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(res.Body)
+
+	switch {
+	case res.StatusCode == http.StatusOK:
+		return nil
+	case res.StatusCode == http.StatusNotFound || bytes.Contains(data, []byte("UNREGISTERED")):
+		return ErrUnregistered
+	default:
+		return serviceError("fcm", res.Status, data)
+	}
+}
+
+// token oauth access token of the service account, renewed before it expires
+func (instance *FCM) token() (string, error) {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	if instance.accessToken != "" && time.Now().Before(instance.expiry) {
+		return instance.accessToken, nil
+	}
+
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   instance.ClientEmail,
+		"scope": fcmScope,
+		"aud":   instance.TokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(instance.Key)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+	res, err := instance.HTTP.Post(instance.TokenURL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return "", serviceError("fcm token", res.Status, data)
+	}
+
+	var grant struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(data, &grant); err != nil {
+		return "", err
+	}
+	instance.accessToken = grant.AccessToken
+	// renew a minute early so a token never expires in flight
+	instance.expiry = now.Add(time.Duration(grant.ExpiresIn)*time.Second - time.Minute)
+	return instance.accessToken, nil
+}
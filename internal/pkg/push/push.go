@@ -0,0 +1,26 @@
+package push
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnregistered device token is no longer valid and should be removed
+var ErrUnregistered = errors.New("push: device token unregistered")
+
+// Notification alert shown on a device
+type Notification struct {
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// Sender deliver notifications through one push service
+type Sender interface {
+	Send(token string, notification Notification) error
+}
+
+// serviceError failure reported by a push service
+func serviceError(service, status string, body []byte) error {
+	return fmt.Errorf("push: %s: %s: %s", service, status, body)
+}
@@ -0,0 +1,112 @@
+package push
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFCMSend(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr error
+	}{
+		{name: "should send notification", status: http.StatusOK, body: `{"name":"projects/p/messages/1"}`},
+		{name: "should report unregistered token", status: http.StatusNotFound, body: `{"error":{"status":"NOT_FOUND"}}`, wantErr: ErrUnregistered},
+		{name: "should report unregistered token on bad request", status: http.StatusBadRequest, body: `{"error":{"details":[{"errorCode":"UNREGISTERED"}]}}`, wantErr: ErrUnregistered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/token":
+					w.Write([]byte(`{"access_token":"at","expires_in":3600}`))
+				case "/v1/projects/p/messages:send":
+					if r.Header.Get("Authorization") != "Bearer at" {
+						t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+					}
+					w.WriteHeader(tt.status)
+					w.Write([]byte(tt.body))
+				default:
+					w.WriteHeader(http.StatusNotImplemented)
+				}
+			}))
+			defer server.Close()
+
+			sender := &FCM{
+				ProjectID:   "p",
+				ClientEmail: "push@p.iam.gserviceaccount.com",
+				Key:         key,
+				TokenURL:    server.URL + "/token",
+				Endpoint:    server.URL,
+				HTTP:        server.Client(),
+			}
+			err := sender.Send("device", Notification{Title: "t", Body: "b"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAPNsSend(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr bool
+		want    error
+	}{
+		{name: "should send notification", status: http.StatusOK},
+		{name: "should report unregistered token", status: http.StatusGone, body: `{"reason":"Unregistered"}`, wantErr: true, want: ErrUnregistered},
+		{name: "should report bad token", status: http.StatusBadRequest, body: `{"reason":"BadDeviceToken"}`, wantErr: true, want: ErrUnregistered},
+		{name: "should report other failures", status: http.StatusForbidden, body: `{"reason":"InvalidProviderToken"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/3/device/device" || r.Header.Get("apns-topic") != "com.example.app" {
+					t.Errorf("unexpected request %s topic %q", r.URL.Path, r.Header.Get("apns-topic"))
+				}
+				if !strings.HasPrefix(r.Header.Get("Authorization"), "bearer ") {
+					t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			sender := &APNs{
+				KeyID:    "KEY",
+				TeamID:   "TEAM",
+				Topic:    "com.example.app",
+				Key:      key,
+				Endpoint: server.URL,
+				HTTP:     server.Client(),
+			}
+			err := sender.Send("device", Notification{Title: "t", Body: "b"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Send() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package querykey
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Param query parameter of an endpoint
+type Param struct {
+	// Aliases other names clients send the parameter by
+	Aliases []string
+	// Default value the endpoint uses when the parameter is missing
+	Default string
+	// Fold spelling of a value equivalent ones share, like its case
+	Fold func(string) string
+}
+
+// Spec parameters of an endpoint by name, other parameters do not change
+// its response
+type Spec map[string]Param
+
+// Canonical query of values under spec, the same for every equivalent query.
+// Parameters sent by an alias are renamed, values are trimmed and folded,
+// missing ones take their default and parameters outside spec are dropped.
+// Names are sorted and only the first value of a parameter is kept
+func Canonical(values url.Values, spec Spec) string {
+	canonical := url.Values{}
+	for name, param := range spec {
+		value := first(values, name)
+		for _, alias := range param.Aliases {
+			if value != "" {
+				break
+			}
+			value = first(values, alias)
+		}
+		if value != "" && param.Fold != nil {
+			value = param.Fold(value)
+		}
+		if value == "" {
+			value = param.Default
+		}
+		if value != "" {
+			canonical.Set(name, value)
+		}
+	}
+	return canonical.Encode()
+}
+
+// first non blank value of name, trimmed
+func first(values url.Values, name string) string {
+	for _, value := range values[name] {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package querykey
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCanonical(t *testing.T) {
+	spec := Spec{
+		"by":      {Default: "platform", Fold: strings.ToLower},
+		"country": {Aliases: []string{"country_code"}, Fold: strings.ToUpper},
+		"from":    {},
+	}
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "should fill defaults of an empty query",
+			query: "",
+			want:  "by=platform",
+		},
+		{
+			name:  "should sort parameters",
+			query: "from=2024-01-01&by=os",
+			want:  "by=os&from=2024-01-01",
+		},
+		{
+			name:  "should rename aliases and fold values",
+			query: "country_code=vn&by=OS",
+			want:  "by=os&country=VN",
+		},
+		{
+			name:  "should prefer the name over an alias",
+			query: "country_code=us&country=vn",
+			want:  "by=platform&country=VN",
+		},
+		{
+			name:  "should drop parameters outside the spec",
+			query: "by=platform&_=1700000000&utm_source=x",
+			want:  "by=platform",
+		},
+		{
+			name:  "should use the default for a blank value",
+			query: "by=%20&from=+2024-01-01",
+			want:  "by=platform&from=2024-01-01",
+		},
+		{
+			name:  "should keep the first value of a repeated parameter",
+			query: "by=os&by=device",
+			want:  "by=os",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Canonical(values, spec); got != tt.want {
+				t.Errorf("Canonical() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
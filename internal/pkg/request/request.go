@@ -0,0 +1,365 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/internal/pkg/httperr"
+	str "analytics-api/internal/pkg/string"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// ValidationError fields of a request breaking their validate tag, by name
+// as sent, with what is expected of them
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (instance *ValidationError) Error() string {
+	parts := make([]string, 0, len(instance.Fields))
+	for name, message := range instance.Fields {
+		parts = append(parts, name+" "+message)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// reachTimeout time a url has to answer the reachable check
+const reachTimeout = 5 * time.Second
+
+// publicDialer checks the address when connecting, so a name cannot resolve
+// publicly when validated and privately when reached
+var publicDialer = &net.Dialer{
+	Timeout: reachTimeout,
+	Control: func(network, address string, conn syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if !configs.AllowPrivateURLs && !PublicIP(net.ParseIP(host)) {
+			return ErrPrivateAddress
+		}
+		return nil
+	},
+}
+
+var publicTransport = &http.Transport{
+	// every request connects again, a pooled connection would skip the
+	// address check below
+	DisableKeepAlives: true,
+	DialContext:       publicDialer.DialContext,
+	// a custom dialer turns HTTP/2 off otherwise, APNs needs it
+	ForceAttemptHTTP2: true,
+}
+
+var reachClient = &http.Client{
+	Timeout:   reachTimeout,
+	Transport: publicTransport,
+	// a redirect is an answer, it is not followed
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// fetchClient follows redirects, each one connects through the address check
+var fetchClient = &http.Client{
+	Timeout:   reachTimeout,
+	Transport: publicTransport,
+}
+
+// NewPublicClient http client connecting to public addresses only, unless
+// ALLOW_PRIVATE_URLS is set, for every delivery leaving the server
+func NewPublicClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: publicTransport,
+	}
+}
+
+// DialPublic connect to address on network like net.Dial, to public
+// addresses only unless ALLOW_PRIVATE_URLS is set, for deliveries over raw
+// connections like syslog
+func DialPublic(network, address string, timeout time.Duration) (net.Conn, error) {
+	dialer := *publicDialer
+	dialer.Timeout = timeout
+	return dialer.Dial(network, address)
+}
+
+// ErrPrivateAddress ...
+var ErrPrivateAddress = errors.New("request: address is not public")
+
+// ErrFetchStatus ...
+var ErrFetchStatus = errors.New("request: page did not answer 200")
+
+// resolveTimeout time a host name has to resolve for the public_host check
+const resolveTimeout = 3 * time.Second
+
+// nonPublicNetworks ranges net.IP has no predicate for: shared address space
+// of carrier NAT, benchmarking and the NAT64 prefix
+var nonPublicNetworks = []*net.IPNet{
+	mustCIDR("100.64.0.0/10"),
+	mustCIDR("198.18.0.0/15"),
+	mustCIDR("64:ff9b::/96"),
+}
+
+func mustCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// validate shared by all handlers. Validators are registered once here, a
+// Validate is then safe for concurrent use and caches struct metadata
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// errors name fields as clients send them
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return field.Name
+	})
+	v.RegisterValidation("hostname_syntax", func(fl validator.FieldLevel) bool {
+		return HostName(fl.Field().String())
+	})
+	v.RegisterValidation("website_url", func(fl validator.FieldLevel) bool {
+		normalized, err := str.NormalizeURL(fl.Field().String())
+		return err == nil && HostName(normalized)
+	})
+	v.RegisterValidation("reachable", func(fl validator.FieldLevel) bool {
+		return Reachable(fl.Field().String())
+	})
+	v.RegisterValidation("public_host", func(fl validator.FieldLevel) bool {
+		return configs.AllowPrivateURLs || PublicHost(fl.Field().String())
+	})
+	return v
+}
+
+// BindAndValidate decode the json body of c into a T and check its validate
+// tags. A body that is not json of a T is returned as the decode error, a T
+// breaking its tags as a *ValidationError
+func BindAndValidate[T any](c *gin.Context) (T, error) {
+	var value T
+	if err := c.ShouldBindWith(&value, binding.JSON); err != nil {
+		return value, err
+	}
+	return value, Validate(value)
+}
+
+// BindFormAndValidate BindAndValidate for html forms, fields are read by
+// their form tag
+func BindFormAndValidate[T any](c *gin.Context) (T, error) {
+	var value T
+	if err := c.ShouldBindWith(&value, binding.Form); err != nil {
+		return value, err
+	}
+	return value, Validate(value)
+}
+
+// BindEitherAndValidate T of the html form of c, or of its json body for
+// the other clients, with whether it was a form
+func BindEitherAndValidate[T any](c *gin.Context) (T, bool, error) {
+	if c.ContentType() == binding.MIMEPOSTForm {
+		value, err := BindFormAndValidate[T](c)
+		return value, true, err
+	}
+	value, err := BindAndValidate[T](c)
+	return value, false, err
+}
+
+// Validate check the validate tags of value
+func Validate(value interface{}) error {
+	err := validate.Struct(value)
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return err
+	}
+	aValidationError := &ValidationError{Fields: map[string]string{}}
+	for _, fieldError := range fieldErrors {
+		// the namespace starts with the name of the struct
+		_, name, _ := strings.Cut(fieldError.Namespace(), ".")
+		aValidationError.Fields[name] = expectation(fieldError)
+	}
+	return aValidationError
+}
+
+// expectation what fieldError asks of its field, in english like other
+// error messages
+func expectation(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be an email"
+	case "url", "http_url":
+		return "must be an absolute url"
+	case "hostname_syntax":
+		return "must be a host name like example.com"
+	case "website_url":
+		return "must be an http or https url like https://example.com"
+	case "reachable":
+		return "must be a url the server can reach"
+	case "public_host":
+		return "must resolve to public addresses, not localhost or a private network"
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fieldError.Param(), " ", ", ")
+	case "min":
+		if fieldError.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters", fieldError.Param())
+		}
+		return "must be at least " + fieldError.Param()
+	case "max":
+		if fieldError.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters", fieldError.Param())
+		}
+		return "must be at most " + fieldError.Param()
+	}
+	return "is invalid"
+}
+
+// BadRequest answer 400 invalid_request with message, and with the fields of
+// err in the details when it is a *ValidationError, the shape of every
+// invalid request
+func BadRequest(c *gin.Context, message string, err error) {
+	var details map[string]interface{}
+	var aValidationError *ValidationError
+	if errors.As(err, &aValidationError) {
+		details = map[string]interface{}{"fields": aValidationError.Fields}
+	}
+	httperr.AbortWithDetails(c, http.StatusBadRequest, httperr.CodeInvalidRequest, message, details)
+}
+
+// HostName report whether value, a host name or an absolute url, names a
+// host by RFC 1123: dot separated labels of letters, digits and inner
+// hyphens, at most 63 characters each and 253 in all
+func HostName(value string) bool {
+	if strings.Contains(value, "://") {
+		u, err := url.Parse(value)
+		if err != nil {
+			return false
+		}
+		value = u.Hostname()
+	}
+	value = strings.TrimSuffix(value, ".")
+	if value == "" || len(value) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(value, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// PublicIP report whether ip is reachable on the internet, false for
+// loopback, private networks of RFC 1918 and RFC 4193, link local,
+// unspecified, multicast and the other reserved ranges
+func PublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// PublicHost report whether value, a host name or a url, resolves and only
+// to public addresses, so the server reaching it cannot be turned against
+// its own network. Urls are read as NormalizeURL does, a bare IPv6 address
+// is not one
+func PublicHost(value string) bool {
+	if normalized, err := str.NormalizeURL(value); err == nil {
+		value = normalized
+	}
+	if strings.Contains(value, "://") {
+		u, err := url.Parse(value)
+		if err != nil {
+			return false
+		}
+		value = u.Hostname()
+	}
+	if ip := net.ParseIP(value); ip != nil {
+		return PublicIP(ip)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, value)
+	if err != nil || len(addresses) == 0 {
+		return false
+	}
+	for _, address := range addresses {
+		if !PublicIP(address.IP) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reachable report whether value is an absolute http or https url answering
+// within reachTimeout, with any status. Unless AllowPrivateURLs is set only
+// public addresses are connected to
+func Reachable(value string) bool {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	res, err := reachClient.Head(value)
+	if err != nil {
+		return false
+	}
+	res.Body.Close()
+	return true
+}
+
+// Fetch body of the page at the http or https url value, up to limit bytes,
+// following redirects. Unless AllowPrivateURLs is set only public addresses
+// are connected to
+func Fetch(value string, limit int64) ([]byte, error) {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("request: %q is not an http url", value)
+	}
+	res, err := fetchClient.Get(value)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, ErrFetchStatus
+	}
+	return io.ReadAll(io.LimitReader(res.Body, limit))
+}
@@ -0,0 +1,270 @@
+package request
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"analytics-api/configs"
+
+	"github.com/gin-gonic/gin"
+)
+
+type testRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Name  string `json:"name" validate:"min=2,max=5"`
+	Host  string `json:"host" validate:"omitempty,hostname_syntax"`
+	URL   string `json:"url" validate:"omitempty,website_url"`
+	Inner struct {
+		Kind string `json:"kind" validate:"oneof=a b"`
+	} `json:"inner"`
+}
+
+func TestBindAndValidate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		body       string
+		wantFields map[string]string
+		wantErr    bool
+	}{
+		{
+			name: "should bind a valid request",
+			body: `{"email":"a@example.com","name":"abc","host":"example.com","inner":{"kind":"a"}}`,
+		},
+		{
+			name: "should accept a website url without scheme or with an IDN",
+			body: `{"email":"a@example.com","name":"abc","url":"ví-dụ.vn/shop/","inner":{"kind":"a"}}`,
+		},
+		{
+			name:    "should return the decode error of a malformed body",
+			body:    `{"email":`,
+			wantErr: true,
+		},
+		{
+			name: "should name fields as sent, nested ones by their path",
+			body: `{"name":"abcdefg","host":"-bad-.com","url":"ftp://example.com","inner":{"kind":"c"}}`,
+			wantFields: map[string]string{
+				"email":      "is required",
+				"name":       "must be at most 5 characters",
+				"host":       "must be a host name like example.com",
+				"url":        "must be an http or https url like https://example.com",
+				"inner.kind": "must be one of a, b",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			_, err := BindAndValidate[testRequest](c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BindAndValidate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var fields map[string]string
+			if aValidationError, ok := err.(*ValidationError); ok {
+				fields = aValidationError.Fields
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("BindAndValidate() fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestBindEitherAndValidate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	type token struct {
+		Token string `json:"token" form:"token" validate:"required"`
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantForm    bool
+		wantErr     bool
+	}{
+		{name: "should bind a form", contentType: "application/x-www-form-urlencoded", body: "token=abc", wantForm: true},
+		{name: "should bind json", contentType: "application/json", body: `{"token":"abc"}`},
+		{name: "should bind json without content type", body: `{"token":"abc"}`},
+		{name: "should validate a form", contentType: "application/x-www-form-urlencoded", body: "other=abc", wantForm: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				c.Request.Header.Set("Content-Type", tt.contentType)
+			}
+			got, form, err := BindEitherAndValidate[token](c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BindEitherAndValidate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if form != tt.wantForm {
+				t.Errorf("BindEitherAndValidate() form = %v, want %v", form, tt.wantForm)
+			}
+			if err == nil && got.Token != "abc" {
+				t.Errorf("BindEitherAndValidate() token = %v, want abc", got.Token)
+			}
+		})
+	}
+}
+
+func TestBadRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	BadRequest(c, "invalid goal", &ValidationError{Fields: map[string]string{"name": "is required"}})
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("BadRequest() status = %d, want 400", recorder.Code)
+	}
+	want := `{"code":"invalid_request","message":"invalid goal","details":{"fields":{"name":"is required"}}}`
+	if got := recorder.Body.String(); got != want {
+		t.Errorf("BadRequest() body = %v, want %v", got, want)
+	}
+}
+
+func TestHostName(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{name: "should accept a host name", value: "shop.example.com", want: true},
+		{name: "should accept a single label", value: "localhost", want: true},
+		{name: "should accept the host of a url", value: "https://example.com:8080/path", want: true},
+		{name: "should accept a trailing dot", value: "example.com.", want: true},
+		{name: "should reject an empty value", value: "", want: false},
+		{name: "should reject an empty label", value: "example..com", want: false},
+		{name: "should reject a label starting with a hyphen", value: "-example.com", want: false},
+		{name: "should reject underscores", value: "my_site.com", want: false},
+		{name: "should reject a label over 63 characters", value: strings.Repeat("a", 64) + ".com", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HostName(tt.value); got != tt.want {
+				t.Errorf("HostName(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReachable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	defer server.Close()
+
+	// a port nothing listens on once the listener is closed
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := "http://" + listener.Addr().String()
+	listener.Close()
+
+	allowPrivateURLs := configs.AllowPrivateURLs
+	defer func() { configs.AllowPrivateURLs = allowPrivateURLs }()
+
+	tests := []struct {
+		name         string
+		value        string
+		allowPrivate bool
+		want         bool
+	}{
+		{name: "should accept a url answering with any status", value: server.URL, allowPrivate: true, want: true},
+		{name: "should reject a private url unless allowed", value: server.URL, want: false},
+		{name: "should reject a url nothing answers", value: closed, allowPrivate: true, want: false},
+		{name: "should reject a url that is not http", value: "ftp://example.com", want: false},
+		{name: "should reject a relative url", value: "/hook", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs.AllowPrivateURLs = tt.allowPrivate
+			if got := Reachable(tt.value); got != tt.want {
+				t.Errorf("Reachable(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/moved":
+			http.Redirect(w, r, "/", http.StatusFound)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Write([]byte("<html><head></head></html>"))
+		}
+	}))
+	defer server.Close()
+
+	allowPrivateURLs := configs.AllowPrivateURLs
+	defer func() { configs.AllowPrivateURLs = allowPrivateURLs }()
+
+	tests := []struct {
+		name         string
+		value        string
+		limit        int64
+		allowPrivate bool
+		want         string
+		wantErr      bool
+	}{
+		{name: "should read the page", value: server.URL, limit: 1024, allowPrivate: true, want: "<html><head></head></html>"},
+		{name: "should follow redirects", value: server.URL + "/moved", limit: 1024, allowPrivate: true, want: "<html><head></head></html>"},
+		{name: "should read up to limit", value: server.URL, limit: 6, allowPrivate: true, want: "<html>"},
+		{name: "should reject a page not answering 200", value: server.URL + "/missing", limit: 1024, allowPrivate: true, wantErr: true},
+		{name: "should reject a private url unless allowed", value: server.URL, limit: 1024, wantErr: true},
+		{name: "should reject a url that is not http", value: "ftp://example.com", limit: 1024, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs.AllowPrivateURLs = tt.allowPrivate
+			got, err := Fetch(tt.value, tt.limit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fetch(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Fetch(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublicHost(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{name: "should accept a public address", value: "93.184.216.34", want: true},
+		{name: "should accept a public ipv6 address", value: "2606:2800:220:1::", want: true},
+		{name: "should accept the host of a public url", value: "https://93.184.216.34/shop", want: true},
+		{name: "should accept a public url without scheme", value: "93.184.216.34/shop", want: true},
+		{name: "should reject loopback", value: "127.0.0.1", want: false},
+		{name: "should reject localhost", value: "http://localhost:8080", want: false},
+		{name: "should reject localhost without scheme", value: "localhost:8080/admin", want: false},
+		{name: "should reject ipv6 loopback", value: "http://[::1]/", want: false},
+		{name: "should reject rfc1918 networks", value: "http://10.1.2.3", want: false},
+		{name: "should reject 172.16.0.0/12", value: "172.20.0.1", want: false},
+		{name: "should reject 192.168.0.0/16", value: "192.168.1.1", want: false},
+		{name: "should reject unique local ipv6", value: "fd00::1", want: false},
+		{name: "should reject link local like cloud metadata", value: "http://169.254.169.254/latest", want: false},
+		{name: "should reject carrier nat", value: "100.64.0.1", want: false},
+		{name: "should reject unspecified", value: "0.0.0.0", want: false},
+		{name: "should reject a host that does not resolve", value: "no-such-host.invalid", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PublicHost(tt.value); got != tt.want {
+				t.Errorf("PublicHost(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package s3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"analytics-api/internal/pkg/awssig"
+	"analytics-api/internal/pkg/request"
+)
+
+// ErrNotFound ...
+var ErrNotFound = errors.New("s3: no such key")
+
+// Client of one bucket, addressed path style so S3 compatible stores like
+// MinIO work with their own endpoint
+type Client struct {
+	Bucket          string
+	Region          string
+	Endpoint        string
+	AccessKeyID     string
+	SecretAccessKey string
+	HTTP            *http.Client
+}
+
+// NewClient client of bucket in region, endpoint defaults to AWS
+func NewClient(bucket, region, endpoint, accessKeyID, secretAccessKey string) *Client {
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	return &Client{
+		Bucket:          bucket,
+		Region:          region,
+		Endpoint:        strings.TrimRight(endpoint, "/"),
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		HTTP:            request.NewPublicClient(5 * time.Minute),
+	}
+}
+
+// URL s3:// url of key, as query engines reading the bucket expect
+func (instance *Client) URL(key string) string {
+	return "s3://" + instance.Bucket + "/" + key
+}
+
+// Put store body at key, replacing the object there
+func (instance *Client) Put(key string, body []byte, contentType string) error {
+	req, err := http.NewRequest(http.MethodPut, instance.objectURL(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	instance.sign(req, body)
+
+	res, err := instance.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return serviceError(res)
+	}
+	io.Copy(io.Discard, res.Body)
+	return nil
+}
+
+// Get content of the object at key
+func (instance *Client) Get(key string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, instance.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	instance.sign(req, nil)
+
+	res, err := instance.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, serviceError(res)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// Object of the bucket read by ranges, an io.ReaderAt so a reader of the
+// format picks the parts it needs without downloading the whole object
+type Object struct {
+	client *Client
+	key    string
+	Size   int64
+}
+
+// Open object at key, its size is read here and its content by ReadAt
+func (instance *Client) Open(key string) (*Object, error) {
+	req, err := http.NewRequest(http.MethodHead, instance.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	instance.sign(req, nil)
+
+	res, err := instance.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, serviceError(res)
+	}
+	if res.ContentLength < 0 {
+		return nil, fmt.Errorf("s3: no length for %s", key)
+	}
+	return &Object{client: instance, key: key, Size: res.ContentLength}, nil
+}
+
+// ReadAt read len(p) bytes of the object from off with one ranged GET
+func (instance *Object) ReadAt(p []byte, off int64) (int, error) {
+	if off >= instance.Size {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	end := min(off+int64(len(p)), instance.Size)
+	req, err := http.NewRequest(http.MethodGet, instance.client.objectURL(instance.key), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, end-1))
+	instance.client.sign(req, nil)
+
+	res, err := instance.client.HTTP.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return 0, ErrNotFound
+	}
+	if res.StatusCode != http.StatusPartialContent {
+		return 0, serviceError(res)
+	}
+	n, err := io.ReadFull(res.Body, p[:end-off])
+	if err != nil {
+		return n, err
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Delete the object at key, deleting one not there succeeds
+func (instance *Client) Delete(key string) error {
+	req, err := http.NewRequest(http.MethodDelete, instance.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+	instance.sign(req, nil)
+
+	res, err := instance.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK {
+		return serviceError(res)
+	}
+	io.Copy(io.Discard, res.Body)
+	return nil
+}
+
+func (instance *Client) objectURL(key string) string {
+	segments := strings.Split(key, "/")
+	for i := range segments {
+		segments[i] = escape(segments[i])
+	}
+	return instance.Endpoint + "/" + escape(instance.Bucket) + "/" + strings.Join(segments, "/")
+}
+
+// escape segment of a path the way AWS signs it, every byte but the
+// unreserved characters of RFC 3986 is percent encoded, = of partitions too
+func escape(segment string) string {
+	var b strings.Builder
+	for i := 0; i < len(segment); i++ {
+		c := segment[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func (instance *Client) sign(req *http.Request, body []byte) {
+	awssig.Sign(req, body, awssig.Credentials{
+		Region:          instance.Region,
+		Service:         "s3",
+		AccessKeyID:     instance.AccessKeyID,
+		SecretAccessKey: instance.SecretAccessKey,
+	}, time.Now())
+}
+
+// serviceError failure reported by S3, its XML error is kept short
+func serviceError(res *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	return fmt.Errorf("s3: %s: %s", res.Status, body)
+}
@@ -0,0 +1,172 @@
+package s3
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPut(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		status   int
+		wantPath string
+		wantErr  bool
+	}{
+		{name: "should put object", key: "year=2026/month=10/day=13/part-00000.parquet", status: http.StatusOK, wantPath: "/archive/year%3D2026/month%3D10/day%3D13/part-00000.parquet"},
+		{name: "should escape key", key: "a b/c", status: http.StatusOK, wantPath: "/archive/a%20b/c"},
+		{name: "should report denied put", key: "k", status: http.StatusForbidden, wantPath: "/archive/k", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPut || r.URL.EscapedPath() != tt.wantPath {
+					t.Errorf("%s %s, want PUT %s", r.Method, r.URL.EscapedPath(), tt.wantPath)
+				}
+				if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") || r.Header.Get("X-Amz-Content-Sha256") == "" {
+					t.Errorf("headers = %v", r.Header)
+				}
+				body, _ := io.ReadAll(r.Body)
+				if string(body) != "data" {
+					t.Errorf("body = %q", body)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			client := NewClient("archive", "us-east-1", server.URL, "AKID", "secret")
+			client.HTTP = server.Client()
+			if err := client.Put(tt.key, []byte("data"), "application/octet-stream"); (err != nil) != tt.wantErr {
+				t.Errorf("Put() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		want    string
+		wantErr error
+	}{
+		{name: "should get object", status: http.StatusOK, want: "data"},
+		{name: "should report missing key", status: http.StatusNotFound, wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet || r.URL.EscapedPath() != "/archive/day%3D13/part.parquet" {
+					t.Errorf("%s %s", r.Method, r.URL.EscapedPath())
+				}
+				if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+					t.Errorf("headers = %v", r.Header)
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.want)
+			}))
+			defer server.Close()
+
+			client := NewClient("archive", "us-east-1", server.URL, "AKID", "secret")
+			client.HTTP = server.Client()
+			got, err := client.Get("day=13/part.parquet")
+			if err != tt.wantErr {
+				t.Fatalf("Get() error = %v, want %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Get() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name    string
+		off     int64
+		size    int
+		want    string
+		wantErr error
+	}{
+		{name: "should read a range", off: 2, size: 3, want: "rqu"},
+		{name: "should read the end", off: 4, size: 3, want: "uet"},
+		{name: "should stop at the end", off: 5, size: 4, want: "et", wantErr: io.EOF},
+		{name: "should read nothing past the end", off: 7, size: 1, want: "", wantErr: io.EOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := "parquet"
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.EscapedPath() != "/archive/day%3D13/part.parquet" {
+					t.Errorf("%s %s", r.Method, r.URL.EscapedPath())
+				}
+				if !strings.Contains(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+					t.Errorf("headers = %v", r.Header)
+				}
+				http.ServeContent(w, r, "part.parquet", time.Time{}, strings.NewReader(content))
+			}))
+			defer server.Close()
+
+			client := NewClient("archive", "us-east-1", server.URL, "AKID", "secret")
+			client.HTTP = server.Client()
+			object, err := client.Open("day=13/part.parquet")
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			if object.Size != int64(len(content)) {
+				t.Errorf("Size = %d, want %d", object.Size, len(content))
+			}
+			p := make([]byte, tt.size)
+			n, err := object.ReadAt(p, tt.off)
+			if err != tt.wantErr {
+				t.Fatalf("ReadAt() error = %v, want %v", err, tt.wantErr)
+			}
+			if string(p[:n]) != tt.want {
+				t.Errorf("ReadAt() = %q, want %q", p[:n], tt.want)
+			}
+		})
+	}
+
+	t.Run("should report missing key", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		defer server.Close()
+
+		client := NewClient("archive", "us-east-1", server.URL, "AKID", "secret")
+		client.HTTP = server.Client()
+		if _, err := client.Open("k"); err != ErrNotFound {
+			t.Errorf("Open() error = %v, want %v", err, ErrNotFound)
+		}
+	})
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "should delete object", status: http.StatusNoContent},
+		{name: "should report denied delete", status: http.StatusForbidden, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodDelete || r.URL.EscapedPath() != "/archive/day%3D13/part.parquet" {
+					t.Errorf("%s %s", r.Method, r.URL.EscapedPath())
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			client := NewClient("archive", "us-east-1", server.URL, "AKID", "secret")
+			client.HTTP = server.Client()
+			if err := client.Delete("day=13/part.parquet"); (err != nil) != tt.wantErr {
+				t.Errorf("Delete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -4,16 +4,23 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/golang-jwt/jwt"
 )
 
+// ExtractAccessToken access token of the cookie, or else of the bearer
+// authorization of clients without cookies and of personal tokens
 func ExtractAccessToken(r *http.Request) string {
 	atCookie, err := r.Cookie("access_token")
-	if err != nil {
-		return ""
+	if err == nil && atCookie.Value != "" {
+		return atCookie.Value
+	}
+	bearer := r.Header.Get("Authorization")
+	if len(bearer) > len("Bearer ") && strings.EqualFold(bearer[:len("Bearer ")], "Bearer ") {
+		return bearer[len("Bearer "):]
 	}
-	return atCookie.Value
+	return ""
 }
 
 func VerifyAccessToken(r *http.Request) (*jwt.Token, error) {
@@ -56,9 +63,27 @@ func ExtractAccessTokenMetadata(r *http.Request) (*TokenDetails, error) {
 		if !ok {
 			return nil, err
 		}
+		// tokens issued before multi-tenant mode carry no tenant, before
+		// refresh tokens no family, before organizations no org
+		tenantID, _ := claims["tenant_id"].(string)
+		orgID, _ := claims["org_id"].(string)
+		familyID, _ := claims["family_id"].(string)
+		var scopes []string
+		if personal, ok := claims["scopes"].([]interface{}); ok {
+			scopes = []string{}
+			for _, scope := range personal {
+				if scope, ok := scope.(string); ok {
+					scopes = append(scopes, scope)
+				}
+			}
+		}
 		return &TokenDetails{
 			AccessUUID: accessUUID,
 			UserID:     userID,
+			TenantID:   tenantID,
+			OrgID:      orgID,
+			FamilyID:   familyID,
+			Scopes:     scopes,
 		}, nil
 	}
 	return nil, err
@@ -0,0 +1,83 @@
+package security
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExtractAccessTokenMetadata(t *testing.T) {
+	t.Setenv("ACCESS_SECRET", "secret")
+	signIn, err := CreateToken("user", "tenant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	personal, err := CreatePersonalToken(PersonalPrefix+"id", "user", "tenant", "org", []string{"read"}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := CreatePersonalToken(PersonalPrefix+"id", "user", "tenant", "", []string{"read"}, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		cookie        string
+		authorization string
+		wantUUID      string
+		wantScopes    []string
+		wantErr       bool
+	}{
+		{name: "should read the cookie", cookie: signIn.AccessToken, wantUUID: signIn.AccessUUID},
+		{name: "should prefer the cookie", cookie: signIn.AccessToken, authorization: "Bearer " + personal, wantUUID: signIn.AccessUUID},
+		{name: "should read a bearer personal token", authorization: "Bearer " + personal, wantUUID: PersonalPrefix + "id", wantScopes: []string{"read"}},
+		{name: "should read a lowercase bearer", authorization: "bearer " + signIn.AccessToken, wantUUID: signIn.AccessUUID},
+		{name: "should reject an expired personal token", authorization: "Bearer " + expired, wantErr: true},
+		{name: "should reject other schemes", authorization: "Basic " + signIn.AccessToken, wantErr: true},
+		{name: "should reject a request without token", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "/stats", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "access_token", Value: tt.cookie})
+			}
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			got, err := ExtractAccessTokenMetadata(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExtractAccessTokenMetadata() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.AccessUUID != tt.wantUUID || !reflect.DeepEqual(got.Scopes, tt.wantScopes) {
+				t.Errorf("ExtractAccessTokenMetadata() = %+v, want uuid %v scopes %v", got, tt.wantUUID, tt.wantScopes)
+			}
+		})
+	}
+}
+
+func TestTokenDetailsAllows(t *testing.T) {
+	tests := []struct {
+		name       string
+		details    TokenDetails
+		permission string
+		want       bool
+	}{
+		{name: "should allow a sign in anything", details: TokenDetails{AccessUUID: "uuid"}, permission: "manage", want: true},
+		{name: "should allow a scope of a personal token", details: TokenDetails{AccessUUID: PersonalPrefix + "id", Scopes: []string{"read", "write"}}, permission: "write", want: true},
+		{name: "should not allow beyond the scopes", details: TokenDetails{AccessUUID: PersonalPrefix + "id", Scopes: []string{"read"}}, permission: "replay"},
+		{name: "should not allow a personal token without scopes", details: TokenDetails{AccessUUID: PersonalPrefix + "id"}, permission: "read"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.details.Allows(tt.permission); got != tt.want {
+				t.Errorf("Allows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
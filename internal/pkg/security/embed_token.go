@@ -0,0 +1,141 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// embedScope claim telling embed tokens from access tokens
+const embedScope = "embed"
+
+// ErrInvalidOrigin ...
+var ErrInvalidOrigin = errors.New("origins must be http or https origins like https://app.example.com, without path")
+
+// ErrInvalidEmbedToken ...
+var ErrInvalidEmbedToken = errors.New("embed token invalid or expired")
+
+// EmbedDetails what an embed token grants: reading the stats of WebsiteID as
+// UserID, from pages of Origins, until ExpiresAt
+type EmbedDetails struct {
+	UserID    string
+	TenantID  string
+	WebsiteID string
+	Origins   []string
+	ExpiresAt int64
+}
+
+// embedKey key signing embed tokens, derived from the access secret so an
+// embed token never verifies as an access token
+func embedKey() []byte {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("ACCESS_SECRET")))
+	mac.Write([]byte(embedScope))
+	return mac.Sum(nil)
+}
+
+// CreateEmbedToken signed token of details
+func CreateEmbedToken(details *EmbedDetails) (string, error) {
+	claims := jwt.MapClaims{
+		"scope":      embedScope,
+		"user_id":    details.UserID,
+		"tenant_id":  details.TenantID,
+		"website_id": details.WebsiteID,
+		"origins":    details.Origins,
+		"exp":        details.ExpiresAt,
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(embedKey())
+}
+
+// ExtractEmbedToken embed token of r, in the Authorization header as a
+// bearer token or else in the token query parameter for iframe urls
+func ExtractEmbedToken(r *http.Request) string {
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(bearer)
+	}
+	return r.URL.Query().Get("token")
+}
+
+// VerifyEmbedToken details of tokenString, ErrInvalidEmbedToken when it is
+// not an unexpired embed token
+func VerifyEmbedToken(tokenString string) (*EmbedDetails, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return embedKey(), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidEmbedToken
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["scope"] != embedScope {
+		return nil, ErrInvalidEmbedToken
+	}
+	details := &EmbedDetails{}
+	details.UserID, _ = claims["user_id"].(string)
+	details.TenantID, _ = claims["tenant_id"].(string)
+	details.WebsiteID, _ = claims["website_id"].(string)
+	if exp, ok := claims["exp"].(float64); ok {
+		details.ExpiresAt = int64(exp)
+	}
+	origins, _ := claims["origins"].([]interface{})
+	for _, origin := range origins {
+		if anOrigin, ok := origin.(string); ok {
+			details.Origins = append(details.Origins, anOrigin)
+		}
+	}
+	if details.UserID == "" || details.WebsiteID == "" || details.ExpiresAt == 0 {
+		return nil, ErrInvalidEmbedToken
+	}
+	return details, nil
+}
+
+// Allows report whether the page of r is on one of the origins of the
+// token, by its Origin header or else the origin of its Referer. A request
+// with neither is refused
+func (instance *EmbedDetails) Allows(r *http.Request) (string, bool) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		referer, err := url.Parse(r.Header.Get("Referer"))
+		if err != nil || referer.Host == "" {
+			return "", false
+		}
+		origin = referer.Scheme + "://" + referer.Host
+	}
+	origin = strings.ToLower(origin)
+	for _, anOrigin := range instance.Origins {
+		if anOrigin == origin {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// Expires time the token stops being accepted
+func (instance *EmbedDetails) Expires() time.Time {
+	return time.Unix(instance.ExpiresAt, 0)
+}
+
+// NormalizeOrigin value as scheme://host[:port] in lower case,
+// ErrInvalidOrigin when it is not an http or https origin
+func NormalizeOrigin(value string) (string, error) {
+	anURL, err := url.Parse(strings.TrimSpace(value))
+	if err != nil || anURL.Host == "" || anURL.User != nil || anURL.RawQuery != "" || anURL.Fragment != "" {
+		return "", ErrInvalidOrigin
+	}
+	if anURL.Scheme != "http" && anURL.Scheme != "https" {
+		return "", ErrInvalidOrigin
+	}
+	if anURL.Path != "" && anURL.Path != "/" {
+		return "", ErrInvalidOrigin
+	}
+	return strings.ToLower(anURL.Scheme + "://" + anURL.Host), nil
+}
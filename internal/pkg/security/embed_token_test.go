@@ -0,0 +1,109 @@
+package security
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVerifyEmbedToken(t *testing.T) {
+	t.Setenv("ACCESS_SECRET", "secret")
+	details := &EmbedDetails{
+		UserID:    "user",
+		WebsiteID: "website",
+		Origins:   []string{"https://app.example.com"},
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+	}
+	valid, err := CreateEmbedToken(details)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := CreateEmbedToken(&EmbedDetails{UserID: "user", WebsiteID: "website", ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	access, err := CreateToken("user", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "embed token", token: valid},
+		{name: "expired embed token", token: expired, wantErr: true},
+		{name: "access token", token: access.AccessToken, wantErr: true},
+		{name: "garbage", token: "not a token", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyEmbedToken(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyEmbedToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (got.WebsiteID != "website" || len(got.Origins) != 1 || got.Origins[0] != "https://app.example.com") {
+				t.Errorf("VerifyEmbedToken() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestEmbedDetailsAllows(t *testing.T) {
+	details := &EmbedDetails{Origins: []string{"https://app.example.com"}}
+	tests := []struct {
+		name    string
+		origin  string
+		referer string
+		want    bool
+	}{
+		{name: "allowed origin", origin: "https://app.example.com", want: true},
+		{name: "origin in another case", origin: "https://App.Example.com", want: true},
+		{name: "other origin", origin: "https://evil.example.com"},
+		{name: "other scheme", origin: "http://app.example.com"},
+		{name: "allowed referer", referer: "https://app.example.com/clients/42", want: true},
+		{name: "other referer", referer: "https://evil.example.com/app.example.com"},
+		{name: "neither", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/embed/breakdown", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.referer != "" {
+				r.Header.Set("Referer", tt.referer)
+			}
+			if _, got := details.Allows(r); got != tt.want {
+				t.Errorf("Allows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeOrigin(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "https://App.Example.com", want: "https://app.example.com"},
+		{value: "http://localhost:8080/", want: "http://localhost:8080"},
+		{value: "https://app.example.com/dashboard", wantErr: true},
+		{value: "app.example.com", wantErr: true},
+		{value: "ftp://app.example.com", wantErr: true},
+		{value: "https://user@app.example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := NormalizeOrigin(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeOrigin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeOrigin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,65 +1,67 @@
 package security
 
-// import (
-// 	"fmt"
-// 	"net/http"
-// 	"os"
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
 
-// 	"github.com/golang-jwt/jwt"
-// )
+	"github.com/golang-jwt/jwt"
+)
 
-// func ExtractRefreshToken(r *http.Request) string {
-// 	rtCookie, err := r.Cookie("refresh_token")
-// 	if err != nil {
-// 		return ""
-// 	}
-// 	return rtCookie.Value
-// }
+// ErrInvalidRefreshToken ...
+var ErrInvalidRefreshToken = errors.New("refresh token invalid or expired")
 
-// func VerifyRefreshToken(r *http.Request) (*jwt.Token, error) {
-// 	tokenString := ExtractRefreshToken(r)
-// 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-// 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-// 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-// 		}
-// 		return []byte(os.Getenv("REFRESH_SECRET")), nil
-// 	})
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	return token, nil
-// }
+// refreshKey key signing refresh tokens, REFRESH_SECRET or else one derived
+// from the access secret, never the access secret itself so a refresh token
+// does not verify as an access token
+func refreshKey() []byte {
+	if secret := os.Getenv("REFRESH_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	mac := hmac.New(sha256.New, []byte(os.Getenv("ACCESS_SECRET")))
+	mac.Write([]byte("refresh"))
+	return mac.Sum(nil)
+}
 
-// func RefreshTokenValid(r *http.Request) error {
-// 	token, err := VerifyRefreshToken(r)
-// 	if err != nil {
-// 		return err
-// 	}
-// 	if _, ok := token.Claims.(jwt.MapClaims); !ok && !token.Valid {
-// 		return err
-// 	}
-// 	return nil
-// }
+// ExtractRefreshToken refresh token of the cookie of r
+func ExtractRefreshToken(r *http.Request) string {
+	rtCookie, err := r.Cookie("refresh_token")
+	if err != nil {
+		return ""
+	}
+	return rtCookie.Value
+}
 
-// func ExtractRefreshTokenMetadata(r *http.Request) (*TokenDetails, error) {
-// 	token, err := VerifyRefreshToken(r)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	claims, ok := token.Claims.(jwt.MapClaims)
-// 	if ok && token.Valid {
-// 		refreshUUID, ok := claims["refresh_uuid"].(string)
-// 		if !ok {
-// 			return nil, err
-// 		}
-// 		userID, ok := claims["user_id"].(string)
-// 		if !ok {
-// 			return nil, err
-// 		}
-// 		return &TokenDetails{
-// 			RefreshUUID: refreshUUID,
-// 			UserID:      userID,
-// 		}, nil
-// 	}
-// 	return nil, err
-// }
+// VerifyRefreshToken details of tokenString, with its RefreshUUID and
+// FamilyID, ErrInvalidRefreshToken when it is not an unexpired refresh token
+func VerifyRefreshToken(tokenString string) (*TokenDetails, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return refreshKey(), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidRefreshToken
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidRefreshToken
+	}
+	td := &TokenDetails{}
+	td.RefreshUUID, _ = claims["refresh_uuid"].(string)
+	td.UserID, _ = claims["user_id"].(string)
+	td.TenantID, _ = claims["tenant_id"].(string)
+	td.OrgID, _ = claims["org_id"].(string)
+	td.FamilyID, _ = claims["family_id"].(string)
+	if exp, ok := claims["exp"].(float64); ok {
+		td.RtExpires = int64(exp)
+	}
+	if td.RefreshUUID == "" || td.UserID == "" || td.FamilyID == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+	return td, nil
+}
@@ -0,0 +1,44 @@
+package security
+
+import (
+	"testing"
+)
+
+func TestVerifyRefreshToken(t *testing.T) {
+	t.Setenv("ACCESS_SECRET", "secret")
+	t.Setenv("REFRESH_SECRET", "")
+	first, err := CreateToken("user", "tenant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := RotateToken("user", "tenant", "org", first.FamilyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		want    *TokenDetails
+		wantErr bool
+	}{
+		{name: "refresh token", token: first.RefreshToken, want: first},
+		{name: "rotated refresh token of an org", token: rotated.RefreshToken, want: rotated},
+		{name: "access token", token: first.AccessToken, wantErr: true},
+		{name: "garbage", token: "not a token", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyRefreshToken(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyRefreshToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.RefreshUUID != tt.want.RefreshUUID || got.FamilyID != first.FamilyID || got.UserID != "user" || got.TenantID != "tenant" || got.OrgID != tt.want.OrgID {
+				t.Errorf("VerifyRefreshToken() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,28 +2,62 @@ package security
 
 import (
 	"os"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
 )
 
+// PersonalPrefix starts the access uuid of personal tokens
+const PersonalPrefix = "pat_"
+
+// RefreshLifetime time a refresh token can be used, each use gives a new one
+const RefreshLifetime = 30 * 24 * time.Hour
+
+// TokenDetails access and refresh token of a session. The tokens refreshed
+// from one sign in share its FamilyID
 type TokenDetails struct {
-	UserID      string
-	AccessToken string
-	AccessUUID  string
-	AtExpires   int64
-	// RefreshToken string
-	// RefreshUUID  string
-	// RtExpires    int64
+	UserID   string
+	TenantID string
+	// OrgID organization the user acts for, its websites instead of those of
+	// the user. Empty for the account of the user
+	OrgID    string
+	FamilyID string
+	// Scopes permissions of a personal token, nil for a sign in
+	Scopes       []string
+	AccessToken  string
+	AccessUUID   string
+	AtExpires    int64
+	RefreshToken string
+	RefreshUUID  string
+	RtExpires    int64
+}
+
+// CreateToken create access and refresh token of user in a new family,
+// tenantID is empty in single tenant mode
+func CreateToken(userID, tenantID string) (*TokenDetails, error) {
+	return RotateToken(userID, tenantID, "", uuid.New().String())
+}
+
+// CreateOrgToken create access and refresh token of user acting for orgID in
+// a new family
+func CreateOrgToken(userID, tenantID, orgID string) (*TokenDetails, error) {
+	return RotateToken(userID, tenantID, orgID, uuid.New().String())
 }
 
-func CreateToken(userID string) (*TokenDetails, error) {
+// RotateToken create access and refresh token of user acting for orgID in
+// familyID, for a refresh token of the family being used
+func RotateToken(userID, tenantID, orgID, familyID string) (*TokenDetails, error) {
 	td := &TokenDetails{
-		AtExpires:  time.Now().Add(time.Hour * 24).Unix(),
-		AccessUUID: uuid.New().String(),
-		// RtExpires:   time.Now().Add(time.Hour * 24).Unix(),
-		// RefreshUUID: uuid.NewV4().String(),
+		UserID:      userID,
+		TenantID:    tenantID,
+		OrgID:       orgID,
+		FamilyID:    familyID,
+		AtExpires:   time.Now().Add(time.Hour * 24).Unix(),
+		AccessUUID:  uuid.New().String(),
+		RtExpires:   time.Now().Add(RefreshLifetime).Unix(),
+		RefreshUUID: uuid.New().String(),
 	}
 
 	var err error
@@ -32,6 +66,9 @@ func CreateToken(userID string) (*TokenDetails, error) {
 	atClaims := jwt.MapClaims{
 		"access_uuid": td.AccessUUID,
 		"user_id":     userID,
+		"tenant_id":   tenantID,
+		"org_id":      orgID,
+		"family_id":   familyID,
 		"exp":         td.AtExpires,
 	}
 	at := jwt.NewWithClaims(jwt.SigningMethodHS256, atClaims)
@@ -41,16 +78,54 @@ func CreateToken(userID string) (*TokenDetails, error) {
 	}
 
 	// refresh token
-	// rtClaims := jwt.MapClaims{
-	// 	"refresh_uuid": td.RefreshUUID,
-	// 	"user_id":      userID,
-	// 	"exp":          td.RtExpires,
-	// }
-	// rt := jwt.NewWithClaims(jwt.SigningMethodHS256, rtClaims)
-	// td.RefreshToken, err = rt.SignedString([]byte(os.Getenv("REFRESH_SECRET")))
-	// if err != nil {
-	// 	return nil, err
-	// }
+	rtClaims := jwt.MapClaims{
+		"refresh_uuid": td.RefreshUUID,
+		"user_id":      userID,
+		"tenant_id":    tenantID,
+		"org_id":       orgID,
+		"family_id":    familyID,
+		"exp":          td.RtExpires,
+	}
+	rt := jwt.NewWithClaims(jwt.SigningMethodHS256, rtClaims)
+	td.RefreshToken, err = rt.SignedString(refreshKey())
+	if err != nil {
+		return nil, err
+	}
 
 	return td, nil
 }
+
+// Personal tell if the token is a personal token
+func (instance *TokenDetails) Personal() bool {
+	return strings.HasPrefix(instance.AccessUUID, PersonalPrefix)
+}
+
+// Allows tell if the token may be used with permission, a sign in may be
+// used with any
+func (instance *TokenDetails) Allows(permission string) bool {
+	if !instance.Personal() {
+		return true
+	}
+	for _, scope := range instance.Scopes {
+		if scope == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// CreatePersonalToken create an access token without refresh token for user
+// acting for orgID, limited to scopes until expires. tokenID must start with
+// PersonalPrefix
+func CreatePersonalToken(tokenID, userID, tenantID, orgID string, scopes []string, expires time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"access_uuid": tokenID,
+		"user_id":     userID,
+		"tenant_id":   tenantID,
+		"org_id":      orgID,
+		"scopes":      scopes,
+		"exp":         expires.Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(os.Getenv("ACCESS_SECRET")))
+}
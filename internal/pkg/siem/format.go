@@ -0,0 +1,56 @@
+package siem
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// cefHeader vendor, product and version of the CEF header
+const cefHeader = "CEF:0|dactoankmapydev|analytics-app|1.0|"
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// Format line of anEvent in format, JSON or CEF
+func Format(anEvent Event, format string) ([]byte, error) {
+	if format == FormatCEF {
+		return []byte(cef(anEvent)), nil
+	}
+	return json.Marshal(anEvent)
+}
+
+// cef anEvent in ArcSight Common Event Format, the action is the signature
+// and name, failures are of a higher severity
+func cef(anEvent Event) string {
+	severity := "3"
+	if anEvent.Outcome == OutcomeFailure {
+		severity = "6"
+	}
+	action := cefHeaderEscaper.Replace(anEvent.Action)
+
+	extensions := []string{
+		"rt=" + strconv.FormatInt(anEvent.Time.UnixMilli(), 10),
+		"act=" + cefExtensionEscaper.Replace(anEvent.Action),
+		"outcome=" + cefExtensionEscaper.Replace(anEvent.Outcome),
+	}
+	fields := []struct{ key, value string }{
+		{"suid", anEvent.UserID},
+		{"suser", anEvent.Email},
+		{"src", anEvent.IP},
+		{"requestClientApplication", anEvent.UserAgent},
+		{"reason", anEvent.Reason},
+		{"cs1Label=tenantId cs1", anEvent.TenantID},
+		{"cs2Label=websiteId cs2", anEvent.WebsiteID},
+		{"cs3Label=sessionId cs3", anEvent.SessionID},
+		{"cs4Label=visitorId cs4", anEvent.VisitorID},
+	}
+	for _, field := range fields {
+		if field.value != "" {
+			extensions = append(extensions, field.key+"="+cefExtensionEscaper.Replace(field.value))
+		}
+	}
+	return cefHeader + action + "|" + action + "|" + severity + "|" + strings.Join(extensions, " ")
+}
@@ -0,0 +1,110 @@
+package siem
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"analytics-api/internal/pkg/request"
+)
+
+// sendTimeout bound of a delivery, a SIEM slower than this fails it
+const sendTimeout = 10 * time.Second
+
+// syslogPriority facility log audit (13) at severity informational (6)
+const syslogPriority = "<110>"
+
+// NewSender sender of the endpoint at rawURL, syslog+tcp://host:port,
+// syslog+udp://host:port or an https url the events are posted to
+func NewSender(rawURL, token, format string) (Sender, error) {
+	endpoint, err := url.Parse(rawURL)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("siem: invalid endpoint %q", rawURL)
+	}
+	switch endpoint.Scheme {
+	case "syslog+tcp", "syslog+udp":
+		hostname, _ := os.Hostname()
+		if hostname == "" {
+			hostname = "-"
+		}
+		return &Syslog{Network: endpoint.Scheme[len("syslog+"):], Address: endpoint.Host, Hostname: hostname}, nil
+	case "https":
+		contentType := "application/x-ndjson"
+		if format == FormatCEF {
+			contentType = "text/plain"
+		}
+		return &HTTPS{URL: rawURL, Token: token, ContentType: contentType, Client: request.NewPublicClient(sendTimeout)}, nil
+	}
+	return nil, fmt.Errorf("siem: endpoint must be syslog+tcp, syslog+udp or https, not %q", endpoint.Scheme)
+}
+
+// Syslog sender writing RFC 5424 messages, octet counted over TCP and one
+// datagram each over UDP. The connection is opened again after a failure
+type Syslog struct {
+	Network  string
+	Address  string
+	Hostname string
+
+	conn net.Conn
+}
+
+// Send ...
+func (instance *Syslog) Send(lines [][]byte) error {
+	if instance.conn == nil {
+		conn, err := request.DialPublic(instance.Network, instance.Address, sendTimeout)
+		if err != nil {
+			return err
+		}
+		instance.conn = conn
+	}
+	instance.conn.SetWriteDeadline(time.Now().Add(sendTimeout))
+	for _, line := range lines {
+		message := syslogPriority + "1 " + time.Now().UTC().Format(time.RFC3339Nano) + " " + instance.Hostname + " analytics-app - - - " + string(line)
+		if instance.Network == "tcp" {
+			message = strconv.Itoa(len(message)) + " " + message
+		}
+		if _, err := io.WriteString(instance.conn, message); err != nil {
+			instance.conn.Close()
+			instance.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// HTTPS sender posting a batch as one body, a line per event, with Token
+// as bearer when set
+type HTTPS struct {
+	URL         string
+	Token       string
+	ContentType string
+	Client      *http.Client
+}
+
+// Send ...
+func (instance *HTTPS) Send(lines [][]byte) error {
+	request, err := http.NewRequest(http.MethodPost, instance.URL, bytes.NewReader(bytes.Join(lines, []byte("\n"))))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", instance.ContentType)
+	if instance.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+instance.Token)
+	}
+	response, err := instance.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("siem: %s: %s", response.Status, body)
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package siem
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Formats events are written in, one line each
+const (
+	FormatJSON = "json"
+	FormatCEF  = "cef"
+)
+
+// Outcomes of events
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+const (
+	// MaxBatch events sent in one delivery
+	MaxBatch = 100
+	// QueueSize events waiting for their delivery, those published while it
+	// is full are dropped
+	QueueSize = 10000
+	// flushInterval longest an event waits for its batch to fill
+	flushInterval = time.Second
+	// deliverAttempts tries of a batch before it is counted as failed, after
+	// retryDelay then twice retryDelay
+	deliverAttempts = 3
+)
+
+// Event action a SIEM keeps track of, like a sign in or a replay
+type Event struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Outcome   string    `json:"outcome"`
+	TenantID  string    `json:"tenant_id,omitempty"`
+	UserID    string    `json:"user_id,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	WebsiteID string    `json:"website_id,omitempty"`
+	SessionID string    `json:"session_id,omitempty"`
+	VisitorID string    `json:"visitor_id,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+// Sender deliver formatted events to the SIEM, in order
+type Sender interface {
+	Send(lines [][]byte) error
+}
+
+// Metrics of the deliveries since the exporter started
+type Metrics struct {
+	Enabled   bool   `json:"enabled"`
+	Format    string `json:"format,omitempty"`
+	Queued    int    `json:"queued"`
+	Capacity  int    `json:"capacity"`
+	Published int64  `json:"published"`
+	Delivered int64  `json:"delivered"`
+	Failed    int64  `json:"failed"`
+	// Dropped events published while the queue was full
+	Dropped         int64      `json:"dropped"`
+	Batches         int64      `json:"batches"`
+	AvgLatencyMs    int64      `json:"avg_latency_ms"`
+	LastDeliveredAt *time.Time `json:"last_delivered_at,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+	LastErrorAt     *time.Time `json:"last_error_at,omitempty"`
+}
+
+// Exporter queue events and deliver them in batches in the background. A
+// SIEM slower than the events fills the queue, events published then are
+// dropped and counted so the requests publishing them never wait
+type Exporter struct {
+	sender     Sender
+	format     string
+	queue      chan Event
+	retryDelay time.Duration
+
+	mu        sync.Mutex
+	metrics   Metrics
+	latencyMs int64
+}
+
+// NewExporter exporter of events written in format to sender, queuing size
+// events at most
+func NewExporter(sender Sender, format string, size int) (*Exporter, error) {
+	if format != FormatJSON && format != FormatCEF {
+		return nil, fmt.Errorf("siem: format must be %s or %s", FormatJSON, FormatCEF)
+	}
+	return &Exporter{
+		sender:     sender,
+		format:     format,
+		queue:      make(chan Event, size),
+		retryDelay: time.Second,
+		metrics:    Metrics{Enabled: true, Format: format, Capacity: size},
+	}, nil
+}
+
+// Publish queue anEvent, dropped when the queue is full
+func (instance *Exporter) Publish(anEvent Event) {
+	if anEvent.Time.IsZero() {
+		anEvent.Time = time.Now()
+	}
+	select {
+	case instance.queue <- anEvent:
+		instance.count(func(aMetrics *Metrics) { aMetrics.Published++ })
+	default:
+		instance.count(func(aMetrics *Metrics) { aMetrics.Dropped++ })
+	}
+}
+
+// Run deliver the queued events until Close, then the events left
+func (instance *Exporter) Run() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	batch := make([]Event, 0, MaxBatch)
+	for {
+		select {
+		case anEvent, ok := <-instance.queue:
+			if !ok {
+				instance.deliver(batch)
+				return
+			}
+			batch = append(batch, anEvent)
+			if len(batch) == MaxBatch {
+				instance.deliver(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			instance.deliver(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// Close stop taking events, Run returns once the queued ones are delivered
+func (instance *Exporter) Close() {
+	close(instance.queue)
+}
+
+// Metrics of the exporter now
+func (instance *Exporter) Metrics() Metrics {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	aMetrics := instance.metrics
+	aMetrics.Queued = len(instance.queue)
+	if aMetrics.Batches > 0 {
+		aMetrics.AvgLatencyMs = instance.latencyMs / aMetrics.Batches
+	}
+	return aMetrics
+}
+
+func (instance *Exporter) count(fn func(aMetrics *Metrics)) {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	fn(&instance.metrics)
+}
+
+// deliver batch, retrying failures. Events that cannot be formatted are
+// counted as failed
+func (instance *Exporter) deliver(batch []Event) {
+	if len(batch) == 0 {
+		return
+	}
+	lines := make([][]byte, 0, len(batch))
+	unformatted := int64(0)
+	for _, anEvent := range batch {
+		line, err := Format(anEvent, instance.format)
+		if err != nil {
+			unformatted++
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	began := time.Now()
+	var err error
+	for attempt := 1; attempt <= deliverAttempts; attempt++ {
+		err = instance.sender.Send(lines)
+		if err == nil {
+			break
+		}
+		if attempt < deliverAttempts {
+			time.Sleep(time.Duration(attempt) * instance.retryDelay)
+		}
+	}
+	latency := time.Since(began).Milliseconds()
+
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	now := time.Now()
+	instance.metrics.Batches++
+	instance.latencyMs += latency
+	instance.metrics.Failed += unformatted
+	if err != nil {
+		instance.metrics.Failed += int64(len(lines))
+		instance.metrics.LastError = err.Error()
+		instance.metrics.LastErrorAt = &now
+		return
+	}
+	instance.metrics.Delivered += int64(len(lines))
+	instance.metrics.LastDeliveredAt = &now
+}
+
+var (
+	mu       sync.RWMutex
+	exporter *Exporter
+)
+
+// Start export the events published from now on to the endpoint at rawURL,
+// written in format, JSON when empty. token authenticates HTTPS deliveries
+func Start(rawURL, format, token string) error {
+	if format == "" {
+		format = FormatJSON
+	}
+	sender, err := NewSender(rawURL, token, format)
+	if err != nil {
+		return err
+	}
+	anExporter, err := NewExporter(sender, format, QueueSize)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	exporter = anExporter
+	mu.Unlock()
+	go anExporter.Run()
+	return nil
+}
+
+// Publish anEvent to the exporter started, nothing when none is
+func Publish(anEvent Event) {
+	mu.RLock()
+	anExporter := exporter
+	mu.RUnlock()
+	if anExporter != nil {
+		anExporter.Publish(anEvent)
+	}
+}
+
+// Current metrics of the exporter started, not Enabled when none is
+func Current() Metrics {
+	mu.RLock()
+	anExporter := exporter
+	mu.RUnlock()
+	if anExporter == nil {
+		return Metrics{}
+	}
+	return anExporter.Metrics()
+}
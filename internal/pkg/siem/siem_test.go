@@ -0,0 +1,219 @@
+package siem
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"analytics-api/configs"
+	"analytics-api/internal/pkg/request"
+)
+
+func TestFormat(t *testing.T) {
+	at := time.Date(2026, 10, 14, 8, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		event  Event
+		format string
+		want   string
+	}{
+		{
+			name:   "should write json leaving out empty fields",
+			event:  Event{Time: at, Action: "auth.signin", Outcome: OutcomeSuccess, UserID: "u1", IP: "10.0.0.1"},
+			format: FormatJSON,
+			want:   `{"time":"2026-10-14T08:30:00Z","action":"auth.signin","outcome":"success","user_id":"u1","ip":"10.0.0.1"}`,
+		},
+		{
+			name:   "should write cef with custom strings",
+			event:  Event{Time: at, Action: "session.replay", Outcome: OutcomeSuccess, UserID: "u1", WebsiteID: "w1", SessionID: "s1"},
+			format: FormatCEF,
+			want:   `CEF:0|dactoankmapydev|analytics-app|1.0|session.replay|session.replay|3|rt=1791966600000 act=session.replay outcome=success suid=u1 cs2Label=websiteId cs2=w1 cs3Label=sessionId cs3=s1`,
+		},
+		{
+			name:   "should write the visitor watched in cef",
+			event:  Event{Time: at, Action: "visitor.watch", Outcome: OutcomeSuccess, UserID: "u1", WebsiteID: "w1", VisitorID: "v1"},
+			format: FormatCEF,
+			want:   `CEF:0|dactoankmapydev|analytics-app|1.0|visitor.watch|visitor.watch|3|rt=1791966600000 act=visitor.watch outcome=success suid=u1 cs2Label=websiteId cs2=w1 cs4Label=visitorId cs4=v1`,
+		},
+		{
+			name:   "should raise the severity of failures and escape cef",
+			event:  Event{Time: at, Action: "auth|signin", Outcome: OutcomeFailure, Email: "a=b@x.io", Reason: "wrong\\password\nagain"},
+			format: FormatCEF,
+			want:   `CEF:0|dactoankmapydev|analytics-app|1.0|auth\|signin|auth\|signin|6|rt=1791966600000 act=auth|signin outcome=failure suser=a\=b@x.io reason=wrong\\password\nagain`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Format(tt.event, tt.format)
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Format() = %v, want %v", string(got), tt.want)
+			}
+		})
+	}
+}
+
+type recordingSender struct {
+	mu      sync.Mutex
+	lines   []string
+	fails   int
+	release chan struct{}
+}
+
+func (instance *recordingSender) Send(lines [][]byte) error {
+	if instance.release != nil {
+		<-instance.release
+	}
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	if instance.fails > 0 {
+		instance.fails--
+		return errors.New("unavailable")
+	}
+	for _, line := range lines {
+		instance.lines = append(instance.lines, string(line))
+	}
+	return nil
+}
+
+func TestExporter(t *testing.T) {
+	tests := []struct {
+		name          string
+		size          int
+		events        int
+		fails         int
+		wantDelivered int64
+		wantFailed    int64
+		wantDropped   int64
+	}{
+		{name: "should deliver every event", size: 10, events: 5, wantDelivered: 5},
+		{name: "should drop events published while the queue is full", size: 3, events: 5, wantDelivered: 3, wantDropped: 2},
+		{name: "should retry a failed delivery", size: 10, events: 2, fails: 1, wantDelivered: 2},
+		{name: "should count events failing every attempt", size: 10, events: 2, fails: deliverAttempts, wantFailed: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := &recordingSender{fails: tt.fails}
+			anExporter, err := NewExporter(sender, FormatJSON, tt.size)
+			if err != nil {
+				t.Fatalf("NewExporter() error = %v", err)
+			}
+			anExporter.retryDelay = time.Millisecond
+			for i := 0; i < tt.events; i++ {
+				anExporter.Publish(Event{Action: "auth.signin", Outcome: OutcomeSuccess})
+			}
+			anExporter.Close()
+			anExporter.Run()
+
+			got := anExporter.Metrics()
+			if got.Published != int64(tt.events)-tt.wantDropped {
+				t.Errorf("Published = %d, want %d", got.Published, int64(tt.events)-tt.wantDropped)
+			}
+			if got.Delivered != tt.wantDelivered || got.Failed != tt.wantFailed || got.Dropped != tt.wantDropped {
+				t.Errorf("Delivered, Failed, Dropped = %d, %d, %d, want %d, %d, %d",
+					got.Delivered, got.Failed, got.Dropped, tt.wantDelivered, tt.wantFailed, tt.wantDropped)
+			}
+			if int64(len(sender.lines)) != tt.wantDelivered {
+				t.Errorf("sent %d lines, want %d", len(sender.lines), tt.wantDelivered)
+			}
+		})
+	}
+}
+
+func TestNewExporter(t *testing.T) {
+	if _, err := NewExporter(&recordingSender{}, "xml", 1); err == nil {
+		t.Errorf("NewExporter() with format xml did not fail")
+	}
+}
+
+func TestNewSender(t *testing.T) {
+	tests := []struct {
+		name    string
+		rawURL  string
+		wantErr bool
+	}{
+		{name: "should accept syslog over tcp", rawURL: "syslog+tcp://siem.local:6514"},
+		{name: "should accept syslog over udp", rawURL: "syslog+udp://siem.local:514"},
+		{name: "should accept https", rawURL: "https://siem.local/ingest"},
+		{name: "should refuse plain http", rawURL: "http://siem.local/ingest", wantErr: true},
+		{name: "should refuse an url without host", rawURL: "syslog+tcp:///", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSender(tt.rawURL, "", FormatJSON)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewSender() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHTTPS_Send(t *testing.T) {
+	var gotBody, gotAuthorization string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody, gotAuthorization = string(body), r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	sender := &HTTPS{URL: server.URL, Token: "secret", ContentType: "application/x-ndjson", Client: server.Client()}
+	if err := sender.Send([][]byte{[]byte(`{"a":1}`), []byte(`{"a":2}`)}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if gotBody != "{\"a\":1}\n{\"a\":2}" {
+		t.Errorf("Send() body = %q", gotBody)
+	}
+	if gotAuthorization != "Bearer secret" {
+		t.Errorf("Send() authorization = %q", gotAuthorization)
+	}
+}
+
+func TestSyslog_Send(t *testing.T) {
+	allowPrivateURLs := configs.AllowPrivateURLs
+	defer func() { configs.AllowPrivateURLs = allowPrivateURLs }()
+	configs.AllowPrivateURLs = false
+	refused := &Syslog{Network: "tcp", Address: "127.0.0.1:514", Hostname: "api"}
+	if err := refused.Send([][]byte{[]byte(`{"a":1}`)}); !errors.Is(err, request.ErrPrivateAddress) {
+		t.Fatalf("Send() to a private address error = %v, want %v", err, request.ErrPrivateAddress)
+	}
+
+	configs.AllowPrivateURLs = true
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('}')
+		received <- line
+	}()
+
+	sender := &Syslog{Network: "tcp", Address: listener.Addr().String(), Hostname: "api"}
+	if err := sender.Send([][]byte{[]byte(`{"a":1}`)}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	got := <-received
+	length, message, _ := strings.Cut(got, " ")
+	if length != strconv.Itoa(len(message)) {
+		t.Errorf("Send() framing = %q", got)
+	}
+	if !strings.HasPrefix(message, "<110>1 ") || !strings.HasSuffix(message, " api analytics-app - - - {\"a\":1}") {
+		t.Errorf("Send() message = %q", message)
+	}
+}
@@ -0,0 +1,55 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Headers of a signed request
+const (
+	HeaderKey       = "X-Signature-Key"
+	HeaderTimestamp = "X-Signature-Timestamp"
+	HeaderSignature = "X-Signature"
+)
+
+// MaxSkew signed requests older or further in the future are rejected, the
+// replay cache keeps signatures for twice as long
+const MaxSkew = 5 * time.Minute
+
+var (
+	// ErrExpired ...
+	ErrExpired = errors.New("signature timestamp outside of 5 minutes")
+	// ErrInvalid ...
+	ErrInvalid = errors.New("invalid request signature")
+)
+
+// Sign HMAC-SHA256 with secret of the unix timestamp in seconds, the method,
+// the path with its query and the SHA-256 of body, one per line, hex encoded
+func Sign(secret string, timestamp int64, method, path string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "\n" + method + "\n" + path + "\n" + hex.EncodeToString(bodyHash[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify check signature of a request sent at timestamp, a header value, is
+// recent and made with secret
+func Verify(secret, timestamp, method, path string, body []byte, signature string, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalid
+	}
+	skew := now.Sub(time.Unix(seconds, 0))
+	if skew > MaxSkew || skew < -MaxSkew {
+		return ErrExpired
+	}
+	want := Sign(secret, seconds, method, path, body)
+	if !hmac.Equal([]byte(want), []byte(signature)) {
+		return ErrInvalid
+	}
+	return nil
+}
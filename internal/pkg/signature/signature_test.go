@@ -0,0 +1,37 @@
+package signature
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"name":"blog"}`)
+	valid := Sign("secret", now.Unix(), "POST", "/goal/w1?x=1", body)
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		path      string
+		body      []byte
+		signature string
+		want      error
+	}{
+		{name: "should accept signed request", secret: "secret", timestamp: "1700000000", path: "/goal/w1?x=1", body: body, signature: valid},
+		{name: "should accept small clock skew", secret: "secret", timestamp: strconv.FormatInt(now.Unix()+60, 10), path: "/goal/w1?x=1", body: body, signature: Sign("secret", now.Unix()+60, "POST", "/goal/w1?x=1", body)},
+		{name: "should reject old request", secret: "secret", timestamp: strconv.FormatInt(now.Add(-6*time.Minute).Unix(), 10), path: "/goal/w1?x=1", body: body, signature: valid, want: ErrExpired},
+		{name: "should reject other secret", secret: "other", timestamp: "1700000000", path: "/goal/w1?x=1", body: body, signature: valid, want: ErrInvalid},
+		{name: "should reject changed body", secret: "secret", timestamp: "1700000000", path: "/goal/w1?x=1", body: []byte(`{}`), signature: valid, want: ErrInvalid},
+		{name: "should reject changed query", secret: "secret", timestamp: "1700000000", path: "/goal/w1?x=2", body: body, signature: valid, want: ErrInvalid},
+		{name: "should reject malformed timestamp", secret: "secret", timestamp: "soon", path: "/goal/w1?x=1", body: body, signature: valid, want: ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.secret, tt.timestamp, "POST", tt.path, tt.body, tt.signature, now); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package slo
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WritePrometheus write the objectives and their burn rates in the text
+// exposition format of Prometheus
+func (instance *Tracker) WritePrometheus(w io.Writer) error {
+	statuses := instance.Statuses()
+	instance.mu.Lock()
+	totals := map[string][2]int64{}
+	for route, aWindow := range instance.windows {
+		totals[route] = [2]int64{aWindow.total, aWindow.slow}
+	}
+	instance.mu.Unlock()
+
+	metrics := []struct {
+		name, kind, help string
+		value            func(aStatus Status) string
+	}{
+		{"analytics_slo_requests_total", "counter", "Requests of the routes with a latency objective.",
+			func(aStatus Status) string { return fmt.Sprint(totals[aStatus.Route][0]) }},
+		{"analytics_slo_slow_requests_total", "counter", "Requests answered slower than the threshold of their objective.",
+			func(aStatus Status) string { return fmt.Sprint(totals[aStatus.Route][1]) }},
+		{"analytics_slo_shed_requests_total", "counter", "Requests of low priority routes shed while the objective was at risk.",
+			func(aStatus Status) string { return fmt.Sprint(aStatus.Shed) }},
+		{"analytics_slo_threshold_seconds", "gauge", "Latency threshold of the objective.",
+			func(aStatus Status) string { return fmt.Sprint(float64(aStatus.ThresholdMs) / 1000) }},
+		{"analytics_slo_target_ratio", "gauge", "Share of the requests the objective wants within the threshold.",
+			func(aStatus Status) string { return fmt.Sprint(aStatus.Target / 100) }},
+		{"analytics_slo_at_risk", "gauge", "1 while the objective burns its budget too fast over both windows.",
+			func(aStatus Status) string {
+				if aStatus.AtRisk {
+					return "1"
+				}
+				return "0"
+			}},
+	}
+	for _, metric := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.kind); err != nil {
+			return err
+		}
+		for _, aStatus := range statuses {
+			if _, err := fmt.Fprintf(w, "%s{route=\"%s\"} %s\n", metric.name, labelEscaper.Replace(aStatus.Route), metric.value(aStatus)); err != nil {
+				return err
+			}
+		}
+	}
+
+	name := "analytics_slo_burn_rate"
+	if _, err := fmt.Fprintf(w, "# HELP %s Share of slow requests over the share the objective allows.\n# TYPE %s gauge\n", name, name); err != nil {
+		return err
+	}
+	for _, aStatus := range statuses {
+		route := labelEscaper.Replace(aStatus.Route)
+		if _, err := fmt.Fprintf(w, "%s{route=\"%s\",window=\"5m\"} %v\n%s{route=\"%s\",window=\"1h\"} %v\n",
+			name, route, aStatus.BurnRate5m, name, route, aStatus.BurnRate1h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
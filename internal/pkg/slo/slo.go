@@ -0,0 +1,241 @@
+package slo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// bucketCount minutes of requests kept per route, the longest window
+	bucketCount = 60
+	// shortWindow minutes of the fast window of the burn rate
+	shortWindow = 5
+	// FastBurnRate burn rate spending a 30 days budget in about 2 days, an
+	// objective burning faster over both windows is at risk
+	FastBurnRate = 14.4
+	// MinRequests requests in the short window before an objective can be at
+	// risk, so a few slow requests on a quiet route do not shed
+	MinRequests = 20
+)
+
+// Objective latency budget of a route, Target percent of its requests are
+// answered within Threshold
+type Objective struct {
+	Route     string
+	Threshold time.Duration
+	Target    float64
+}
+
+// ParseObjectives objectives written route=threshold@target and separated
+// by commas, like /session/receive=200ms@99.9
+func ParseObjectives(value string) ([]Objective, error) {
+	objectives := []Objective{}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		equal := strings.LastIndex(part, "=")
+		at := strings.LastIndex(part, "@")
+		if equal <= 0 || at < equal {
+			return nil, fmt.Errorf("slo: %q is not route=threshold@target", part)
+		}
+		route := part[:equal]
+		threshold, err := time.ParseDuration(part[equal+1 : at])
+		if err != nil || threshold <= 0 {
+			return nil, fmt.Errorf("slo: invalid threshold of %s", route)
+		}
+		target, err := strconv.ParseFloat(part[at+1:], 64)
+		if err != nil || target <= 0 || target >= 100 {
+			return nil, fmt.Errorf("slo: target of %s must be a percent between 0 and 100", route)
+		}
+		if seen[route] {
+			return nil, fmt.Errorf("slo: %s has two objectives", route)
+		}
+		seen[route] = true
+		objectives = append(objectives, Objective{Route: route, Threshold: threshold, Target: target})
+	}
+	return objectives, nil
+}
+
+// Status compliance of an objective over the last hour, with its burn rates
+// over the last 5 minutes and hour. A burn rate of 1 spends the budget, the
+// requests allowed to be slow, exactly as fast as the target allows
+type Status struct {
+	Route       string  `json:"route"`
+	ThresholdMs int64   `json:"threshold_ms"`
+	Target      float64 `json:"target"`
+	Requests    int64   `json:"requests"`
+	Slow        int64   `json:"slow"`
+	// Compliance percent of the requests within the threshold, 100 without
+	// requests
+	Compliance float64 `json:"compliance"`
+	BurnRate5m float64 `json:"burn_rate_5m"`
+	BurnRate1h float64 `json:"burn_rate_1h"`
+	AtRisk     bool    `json:"at_risk"`
+	// Shed requests of low priority routes answered 503 while the objective
+	// was at risk, since the start of the process
+	Shed int64 `json:"shed"`
+}
+
+type bucket struct {
+	minute int64
+	total  int64
+	slow   int64
+}
+
+type objectiveWindow struct {
+	objective Objective
+	buckets   [bucketCount]bucket
+	// since the start of the process, for the counters of Prometheus
+	total int64
+	slow  int64
+	shed  int64
+}
+
+// Tracker requests of the routes with an objective, counted per minute
+type Tracker struct {
+	mu      sync.Mutex
+	windows map[string]*objectiveWindow
+	routes  []string
+	now     func() time.Time
+}
+
+// NewTracker tracker of objectives
+func NewTracker(objectives []Objective) *Tracker {
+	aTracker := &Tracker{windows: map[string]*objectiveWindow{}, now: time.Now}
+	for _, anObjective := range objectives {
+		aTracker.windows[anObjective.Route] = &objectiveWindow{objective: anObjective}
+		aTracker.routes = append(aTracker.routes, anObjective.Route)
+	}
+	return aTracker
+}
+
+// Observe count a request of route answered in latency, nothing when the
+// route has no objective
+func (instance *Tracker) Observe(route string, latency time.Duration) {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	aWindow, ok := instance.windows[route]
+	if !ok {
+		return
+	}
+	minute := instance.now().Unix() / 60
+	aBucket := &aWindow.buckets[minute%bucketCount]
+	if aBucket.minute != minute {
+		*aBucket = bucket{minute: minute}
+	}
+	aBucket.total++
+	aWindow.total++
+	if latency > aWindow.objective.Threshold {
+		aBucket.slow++
+		aWindow.slow++
+	}
+}
+
+// Tracked route has an objective
+func (instance *Tracker) Tracked(route string) bool {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	_, ok := instance.windows[route]
+	return ok
+}
+
+// AtRisk first of routes whose objective is at risk, false when none is
+func (instance *Tracker) AtRisk(routes []string) (string, bool) {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	for _, route := range routes {
+		if aWindow, ok := instance.windows[route]; ok && instance.status(aWindow).AtRisk {
+			return route, true
+		}
+	}
+	return "", false
+}
+
+// Shed count a request shed to protect the objective of route
+func (instance *Tracker) Shed(route string) {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	if aWindow, ok := instance.windows[route]; ok {
+		aWindow.shed++
+	}
+}
+
+// Statuses of the objectives, in the order they were defined
+func (instance *Tracker) Statuses() []Status {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	statuses := make([]Status, 0, len(instance.routes))
+	for _, route := range instance.routes {
+		statuses = append(statuses, instance.status(instance.windows[route]))
+	}
+	return statuses
+}
+
+func (instance *Tracker) status(aWindow *objectiveWindow) Status {
+	minute := instance.now().Unix() / 60
+	var total, slow, shortTotal, shortSlow int64
+	for _, aBucket := range aWindow.buckets {
+		age := minute - aBucket.minute
+		if age < 0 || age >= bucketCount {
+			continue
+		}
+		total += aBucket.total
+		slow += aBucket.slow
+		if age < shortWindow {
+			shortTotal += aBucket.total
+			shortSlow += aBucket.slow
+		}
+	}
+
+	aStatus := Status{
+		Route:       aWindow.objective.Route,
+		ThresholdMs: aWindow.objective.Threshold.Milliseconds(),
+		Target:      aWindow.objective.Target,
+		Requests:    total,
+		Slow:        slow,
+		Compliance:  100,
+		BurnRate5m:  burnRate(shortTotal, shortSlow, aWindow.objective.Target),
+		BurnRate1h:  burnRate(total, slow, aWindow.objective.Target),
+		Shed:        aWindow.shed,
+	}
+	if total > 0 {
+		aStatus.Compliance = float64(total-slow) * 100 / float64(total)
+	}
+	aStatus.AtRisk = shortTotal >= MinRequests && aStatus.BurnRate5m >= FastBurnRate && aStatus.BurnRate1h >= FastBurnRate
+	return aStatus
+}
+
+// burnRate share of slow requests over the share the target allows, to 2
+// decimals
+func burnRate(total, slow int64, target float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(slow)/float64(total)/(1-target/100)*100) / 100
+}
+
+var (
+	mu      sync.RWMutex
+	tracker = NewTracker(nil)
+)
+
+// Configure track objectives from now on, instead of those before
+func Configure(objectives []Objective) {
+	mu.Lock()
+	tracker = NewTracker(objectives)
+	mu.Unlock()
+}
+
+// Default tracker of the objectives configured, shared by every tenant
+func Default() *Tracker {
+	mu.RLock()
+	defer mu.RUnlock()
+	return tracker
+}
@@ -0,0 +1,153 @@
+package slo
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseObjectives(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []Objective
+		wantErr bool
+	}{
+		{name: "should parse nothing from empty", value: "", want: []Objective{}},
+		{
+			name:  "should parse routes with parameters",
+			value: "/session/receive=200ms@99.9, /segment/v1/:method=1s@99",
+			want: []Objective{
+				{Route: "/session/receive", Threshold: 200 * time.Millisecond, Target: 99.9},
+				{Route: "/segment/v1/:method", Threshold: time.Second, Target: 99},
+			},
+		},
+		{name: "should refuse a missing target", value: "/session/receive=200ms", wantErr: true},
+		{name: "should refuse an invalid threshold", value: "/session/receive=fast@99", wantErr: true},
+		{name: "should refuse a target of 100", value: "/session/receive=200ms@100", wantErr: true},
+		{name: "should refuse two objectives of a route", value: "/a=1s@99,/a=2s@99", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseObjectives(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseObjectives() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseObjectives() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTracker(t *testing.T) {
+	start := time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC)
+	objective := Objective{Route: "/session/receive", Threshold: 100 * time.Millisecond, Target: 99}
+	type request struct {
+		minutesAgo int
+		count      int
+		latency    time.Duration
+	}
+	tests := []struct {
+		name           string
+		requests       []request
+		wantRequests   int64
+		wantSlow       int64
+		wantBurnRate5m float64
+		wantAtRisk     bool
+	}{
+		{name: "should comply without requests", wantBurnRate5m: 0},
+		{
+			name:           "should burn at 1 with the slow share the target allows",
+			requests:       []request{{count: 99, latency: time.Millisecond}, {count: 1, latency: time.Second}},
+			wantRequests:   100,
+			wantSlow:       1,
+			wantBurnRate5m: 1,
+		},
+		{
+			name:           "should be at risk burning fast over both windows",
+			requests:       []request{{count: 80, latency: time.Millisecond}, {count: 20, latency: time.Second}},
+			wantRequests:   100,
+			wantSlow:       20,
+			wantBurnRate5m: 20,
+			wantAtRisk:     true,
+		},
+		{
+			name:           "should not be at risk with few requests",
+			requests:       []request{{count: 5, latency: time.Second}},
+			wantRequests:   5,
+			wantSlow:       5,
+			wantBurnRate5m: 100,
+		},
+		{
+			name: "should not be at risk once slow requests left the short window",
+			requests: []request{
+				{minutesAgo: 30, count: 50, latency: time.Second},
+				{count: 100, latency: time.Millisecond},
+			},
+			wantRequests:   150,
+			wantSlow:       50,
+			wantBurnRate5m: 0,
+		},
+		{
+			name:         "should forget requests older than an hour",
+			requests:     []request{{minutesAgo: 61, count: 50, latency: time.Second}},
+			wantRequests: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aTracker := NewTracker([]Objective{objective})
+			for _, aRequest := range tt.requests {
+				at := start.Add(-time.Duration(aRequest.minutesAgo) * time.Minute)
+				aTracker.now = func() time.Time { return at }
+				for i := 0; i < aRequest.count; i++ {
+					aTracker.Observe(objective.Route, aRequest.latency)
+				}
+			}
+			aTracker.Observe("/untracked", time.Hour)
+			aTracker.now = func() time.Time { return start }
+
+			got := aTracker.Statuses()[0]
+			if got.Requests != tt.wantRequests || got.Slow != tt.wantSlow {
+				t.Errorf("Requests, Slow = %d, %d, want %d, %d", got.Requests, got.Slow, tt.wantRequests, tt.wantSlow)
+			}
+			if diff := got.BurnRate5m - tt.wantBurnRate5m; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("BurnRate5m = %v, want %v", got.BurnRate5m, tt.wantBurnRate5m)
+			}
+			if got.AtRisk != tt.wantAtRisk {
+				t.Errorf("AtRisk = %v, want %v", got.AtRisk, tt.wantAtRisk)
+			}
+			if _, atRisk := aTracker.AtRisk([]string{"/untracked", objective.Route}); atRisk != tt.wantAtRisk {
+				t.Errorf("AtRisk() = %v, want %v", atRisk, tt.wantAtRisk)
+			}
+		})
+	}
+}
+
+func TestTracker_WritePrometheus(t *testing.T) {
+	aTracker := NewTracker([]Objective{{Route: "/segment/v1/:method", Threshold: 250 * time.Millisecond, Target: 99.5}})
+	aTracker.Observe("/segment/v1/:method", time.Second)
+	aTracker.Observe("/segment/v1/:method", time.Millisecond)
+	aTracker.Shed("/segment/v1/:method")
+
+	var buffer bytes.Buffer
+	if err := aTracker.WritePrometheus(&buffer); err != nil {
+		t.Fatalf("WritePrometheus() error = %v", err)
+	}
+	for _, want := range []string{
+		"# TYPE analytics_slo_requests_total counter\n",
+		`analytics_slo_requests_total{route="/segment/v1/:method"} 2` + "\n",
+		`analytics_slo_slow_requests_total{route="/segment/v1/:method"} 1` + "\n",
+		`analytics_slo_shed_requests_total{route="/segment/v1/:method"} 1` + "\n",
+		`analytics_slo_threshold_seconds{route="/segment/v1/:method"} 0.25` + "\n",
+		`analytics_slo_target_ratio{route="/segment/v1/:method"} 0.995` + "\n",
+		`analytics_slo_burn_rate{route="/segment/v1/:method",window="5m"} 100` + "\n",
+	} {
+		if !strings.Contains(buffer.String(), want) {
+			t.Errorf("WritePrometheus() misses %q in\n%s", want, buffer.String())
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package spam
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxFeedSize largest feed read, a longer one is refused
+const maxFeedSize = 1 << 20
+
+// ErrInvalidDomain domain that is not a host name
+var ErrInvalidDomain = errors.New("invalid domain")
+
+//go:embed domains.txt
+var embedded string
+
+// List referrer spam domains safe for concurrent use, a domain blocks its
+// subdomains too
+type List struct {
+	mu      sync.RWMutex
+	domains map[string]bool
+}
+
+// Default list applied at ingestion, the embedded domains plus those of the
+// feed once it is fetched
+var Default = New(Parse(embedded))
+
+// New build list from domains
+func New(domains []string) *List {
+	list := &List{}
+	list.Set(domains)
+	return list
+}
+
+// Set replace the domains of the list
+func (instance *List) Set(domains []string) {
+	set := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		set[domain] = true
+	}
+
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	instance.domains = set
+}
+
+// Len count of the domains of the list
+func (instance *List) Len() int {
+	instance.mu.RLock()
+	defer instance.mu.RUnlock()
+	return len(instance.domains)
+}
+
+// Contains whether host or one of its parent domains is in the list
+func (instance *List) Contains(host string) bool {
+	instance.mu.RLock()
+	defer instance.mu.RUnlock()
+	for _, domain := range parents(host) {
+		if instance.domains[domain] {
+			return true
+		}
+	}
+	return false
+}
+
+// Match whether host or one of its parent domains is one of domains
+func Match(host string, domains []string) bool {
+	for _, parent := range parents(host) {
+		for _, domain := range domains {
+			if parent == domain {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Spam whether the host of referrer is spam for a website that allowed and
+// blocked domains of its own, allowed ones win over both lists
+func Spam(referrer string, allowed, blocked []string) bool {
+	host := Host(referrer)
+	if host == "" || Match(host, allowed) {
+		return false
+	}
+	return Match(host, blocked) || Default.Contains(host)
+}
+
+// Host lowercased host of referrer, a url or a bare host name, empty when
+// it has none
+func Host(referrer string) string {
+	referrer = strings.TrimSpace(referrer)
+	if referrer == "" {
+		return ""
+	}
+	if !strings.Contains(referrer, "://") {
+		referrer = "http://" + referrer
+	}
+	parsed, err := url.Parse(referrer)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+}
+
+// Normalize lowercase domain and check it is a host name, a url is reduced
+// to its host
+func Normalize(domain string) (string, error) {
+	host := Host(domain)
+	if host == "" || !strings.Contains(host, ".") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidDomain, domain)
+	}
+	for _, r := range host {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.') {
+			return "", fmt.Errorf("%w: %q", ErrInvalidDomain, domain)
+		}
+	}
+	return host, nil
+}
+
+// Parse domains of text, one per line, blank lines, comments after # and
+// invalid domains are skipped
+func Parse(text string) []string {
+	var domains []string
+	for _, line := range strings.Split(text, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		domain, err := Normalize(line)
+		if err != nil {
+			continue
+		}
+		domains = append(domains, domain)
+	}
+	return domains
+}
+
+// Fetch domains of the feed at feedURL, a text file in the format of Parse
+func Fetch(client *http.Client, feedURL string) ([]string, error) {
+	resp, err := client.Get(feedURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("spam feed answered %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFeedSize {
+		return nil, fmt.Errorf("spam feed larger than %d bytes", maxFeedSize)
+	}
+	return Parse(string(data)), nil
+}
+
+// Run refresh Default with the embedded domains and those of feedURL every
+// interval, a failed fetch keeps the domains of the last one
+func Run(feedURL string, interval time.Duration) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	for {
+		domains, err := Fetch(client, feedURL)
+		if err != nil {
+			logrus.Error("fetch spam feed error ", err)
+		} else {
+			Default.Set(append(Parse(embedded), domains...))
+			logrus.Info("spam list refreshed with ", Default.Len(), " domains")
+		}
+		time.Sleep(interval)
+	}
+}
+
+// parents host and each domain it is a subdomain of, down to the last two
+// labels
+func parents(host string) []string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	var domains []string
+	for {
+		domains = append(domains, host)
+		i := strings.Index(host, ".")
+		if i < 0 || !strings.Contains(host[i+1:], ".") {
+			return domains
+		}
+		host = host[i+1:]
+	}
+}
@@ -0,0 +1,110 @@
+package spam
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSpam(t *testing.T) {
+	type args struct {
+		referrer string
+		allowed  []string
+		blocked  []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{
+			name: "should block embedded domain",
+			args: args{referrer: "https://semalt.com/crawler"},
+			want: true,
+		},
+		{
+			name: "should block subdomain of embedded domain",
+			args: args{referrer: "http://Forum.Semalt.com/"},
+			want: true,
+		},
+		{
+			name: "should keep other referrers",
+			args: args{referrer: "https://www.google.com/"},
+			want: false,
+		},
+		{
+			name: "should keep direct traffic",
+			args: args{referrer: ""},
+			want: false,
+		},
+		{
+			name: "should not match suffix that is not a parent domain",
+			args: args{referrer: "https://notsemalt.com/"},
+			want: false,
+		},
+		{
+			name: "should block domain of the website",
+			args: args{referrer: "https://spam.example.com/", blocked: []string{"example.com"}},
+			want: true,
+		},
+		{
+			name: "should let website allow a listed domain",
+			args: args{referrer: "https://semalt.com/", allowed: []string{"semalt.com"}},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Spam(tt.args.referrer, tt.args.allowed, tt.args.blocked); got != tt.want {
+				t.Errorf("Spam() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		domain  string
+		want    string
+		wantErr bool
+	}{
+		{name: "should lowercase domain", domain: " Spam.Example.COM ", want: "spam.example.com"},
+		{name: "should reduce url to its host", domain: "https://spam.example.com/path?q=1", want: "spam.example.com"},
+		{name: "should reject single label", domain: "localhost", wantErr: true},
+		{name: "should reject invalid characters", domain: "spam_example.com", wantErr: true},
+		{name: "should reject empty domain", domain: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.domain)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Normalize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("# feed\nghost.example.com\n\nnot a domain\nreferrer.example.org # trailing comment\n"))
+	}))
+	defer server.Close()
+
+	domains, err := Fetch(server.Client(), server.URL)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	want := []string{"ghost.example.com", "referrer.example.org"}
+	if len(domains) != len(want) {
+		t.Fatalf("Fetch() = %v, want %v", domains, want)
+	}
+	for i := range want {
+		if domains[i] != want[i] {
+			t.Errorf("Fetch()[%d] = %v, want %v", i, domains[i], want[i])
+		}
+	}
+}
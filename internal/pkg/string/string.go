@@ -3,11 +3,18 @@ package pkg
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"errors"
+	"net"
 	"net/url"
 	"strings"
 	"unicode"
+
+	"golang.org/x/net/idna"
 )
 
+// ErrInvalidURL ...
+var ErrInvalidURL = errors.New("url must be an http or https url with a host")
+
 // RemoveSubstring remove substring in string
 func RemoveSubstring(s string, substr string) string {
 	if n := strings.Index(s, substr); n >= 0 {
@@ -39,6 +46,45 @@ func ParseURL(input string) (string, error) {
 	return hostname, nil
 }
 
+// NormalizeURL url of a website in the form it is stored: https when it has
+// no scheme, the host lower cased with IDNs in punycode and without the
+// default port, no fragment and no trailing slash. ErrInvalidURL when it is
+// not an http or https url of a host, or has credentials
+func NormalizeURL(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if !strings.Contains(input, "://") {
+		input = "https://" + input
+	}
+	u, err := url.Parse(input)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.User != nil {
+		return "", ErrInvalidURL
+	}
+	host := strings.TrimSuffix(u.Hostname(), ".")
+	if host == "" {
+		return "", ErrInvalidURL
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+	} else {
+		host, err = idna.Lookup.ToASCII(host)
+		if err != nil {
+			return "", ErrInvalidURL
+		}
+	}
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	u.Host = host
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String(), nil
+}
+
 func GetMD5Hash(text string) string {
 	binHash := md5.Sum([]byte(text))
 	return hex.EncodeToString(binHash[:])
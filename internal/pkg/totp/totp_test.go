@@ -0,0 +1,89 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret "12345678901234567890" of the test vectors of RFC 6238
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		name string
+		unix int64
+		want string
+	}{
+		{name: "should match the first vector", unix: 59, want: "287082"},
+		{name: "should match the vector of 2005", unix: 1111111109, want: "081804"},
+		{name: "should match the vector of 2009", unix: 1234567890, want: "005924"},
+		{name: "should match the vector of 2033", unix: 2000000000, want: "279037"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Code(rfcSecret, Step(time.Unix(tt.unix, 0))); got != tt.want {
+				t.Errorf("Code() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	at := time.Unix(1111111109, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		at     time.Time
+		want   bool
+	}{
+		{name: "should accept the current code", secret: rfcSecret, code: "081804", at: at, want: true},
+		{name: "should accept code of the step before", secret: rfcSecret, code: "081804", at: at.Add(Period), want: true},
+		{name: "should accept code with spaces", secret: rfcSecret, code: "081 804", at: at, want: true},
+		{name: "should reject code of two steps before", secret: rfcSecret, code: "081804", at: at.Add(2 * Period), want: false},
+		{name: "should reject wrong code", secret: rfcSecret, code: "123456", at: at, want: false},
+		{name: "should reject short code", secret: rfcSecret, code: "81804", at: at, want: false},
+		{name: "should reject invalid secret", secret: "not base32!", code: "081804", at: at, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := Validate(tt.secret, tt.code, tt.at); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatalf("NewSecret() error = %v", err)
+	}
+	other, _ := NewSecret()
+	if secret == other {
+		t.Errorf("NewSecret() returned %v twice", secret)
+	}
+	at := time.Now()
+	if _, ok := Validate(secret, Code(secret, Step(at)), at); !ok {
+		t.Errorf("Validate() rejected the code of secret %v", secret)
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Theodoiweb", "a@example.com", rfcSecret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Theodoiweb:a@example.com?") {
+		t.Fatalf("URI() = %v", uri)
+	}
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("URI() does not parse: %v", err)
+	}
+	if got := parsed.Query().Get("secret"); got != rfcSecret {
+		t.Errorf("secret = %v, want %v", got, rfcSecret)
+	}
+	if got := parsed.Query().Get("issuer"); got != "Theodoiweb" {
+		t.Errorf("issuer = %v, want Theodoiweb", got)
+	}
+}
@@ -0,0 +1,114 @@
+package verify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"html"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"analytics-api/internal/pkg/request"
+)
+
+// Name of the meta tag and prefix of the TXT record proving the ownership of
+// a website
+const Name = "analytics-site-verification"
+
+// pageLimit bytes of the page read looking for the meta tag, it belongs in
+// the head
+const pageLimit = 512 << 10
+
+// lookupTimeout time the TXT records of a host have to resolve
+const lookupTimeout = 5 * time.Second
+
+// ErrTXTNotFound ...
+var ErrTXTNotFound = errors.New("no TXT record of the host holds the verification token")
+
+// ErrMetaNotFound ...
+var ErrMetaNotFound = errors.New("the page has no meta tag with the verification token")
+
+// ErrPageUnreachable ...
+var ErrPageUnreachable = errors.New("the page of the website could not be read")
+
+var metaTag = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+
+var attribute = regexp.MustCompile(`(?is)([a-z][a-z0-9_:-]*)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+
+// lookupTXT replaced in tests
+var lookupTXT = func(ctx context.Context, host string) ([]string, error) {
+	return net.DefaultResolver.LookupTXT(ctx, host)
+}
+
+// NewToken random token a website is verified with
+func NewToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// TXTRecord value of the TXT record verifying with token
+func TXTRecord(token string) string {
+	return Name + "=" + token
+}
+
+// MetaTag tag verifying with token, placed in the head of the home page
+func MetaTag(token string) string {
+	return `<meta name="` + Name + `" content="` + token + `">`
+}
+
+// DNS check a TXT record of host holds token. A www host may also be
+// verified on its parent domain
+func DNS(host, token string) error {
+	hosts := []string{host}
+	if parent := strings.TrimPrefix(host, "www."); parent != host {
+		hosts = append(hosts, parent)
+	}
+	for _, name := range hosts {
+		ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+		records, err := lookupTXT(ctx, name)
+		cancel()
+		if err != nil {
+			continue
+		}
+		for _, record := range records {
+			if strings.TrimSpace(record) == TXTRecord(token) {
+				return nil
+			}
+		}
+	}
+	return ErrTXTNotFound
+}
+
+// Meta check the page at pageURL has the meta tag of token
+func Meta(pageURL, token string) error {
+	page, err := request.Fetch(pageURL, pageLimit)
+	if err != nil {
+		return ErrPageUnreachable
+	}
+	if !hasMeta(page, token) {
+		return ErrMetaNotFound
+	}
+	return nil
+}
+
+// hasMeta report whether page has a meta tag named Name with token as
+// content, attributes in any order and quoting
+func hasMeta(page []byte, token string) bool {
+	for _, tag := range metaTag.FindAll(page, -1) {
+		values := map[string]string{}
+		for _, match := range attribute.FindAllSubmatch(tag, -1) {
+			value := string(match[2]) + string(match[3]) + string(match[4])
+			values[strings.ToLower(string(match[1]))] = html.UnescapeString(value)
+		}
+		if strings.EqualFold(values["name"], Name) && strings.TrimSpace(values["content"]) == token {
+			return true
+		}
+	}
+	return false
+}
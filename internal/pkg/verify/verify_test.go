@@ -0,0 +1,101 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"analytics-api/configs"
+)
+
+func TestHasMeta(t *testing.T) {
+	tests := []struct {
+		name  string
+		page  string
+		token string
+		want  bool
+	}{
+		{name: "should find the tag", page: `<head><meta name="analytics-site-verification" content="abc"></head>`, token: "abc", want: true},
+		{name: "should find the tag with attributes in any order", page: `<META content='abc' NAME="analytics-site-verification" />`, token: "abc", want: true},
+		{name: "should find the tag among others", page: `<meta charset="utf-8"><meta name=analytics-site-verification content=abc>`, token: "abc", want: true},
+		{name: "should reject another token", page: `<meta name="analytics-site-verification" content="abd">`, token: "abc", want: false},
+		{name: "should reject another tag", page: `<meta name="description" content="abc">`, token: "abc", want: false},
+		{name: "should reject the token outside a tag", page: `<p>analytics-site-verification abc</p>`, token: "abc", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasMeta([]byte(tt.page), tt.token); got != tt.want {
+				t.Errorf("hasMeta() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNS(t *testing.T) {
+	records := map[string][]string{
+		"example.com":  {"v=spf1 -all", "analytics-site-verification=abc"},
+		"shop.example": {"analytics-site-verification=other"},
+	}
+	lookup := lookupTXT
+	defer func() { lookupTXT = lookup }()
+	lookupTXT = func(ctx context.Context, host string) ([]string, error) {
+		if values, ok := records[host]; ok {
+			return values, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	tests := []struct {
+		name    string
+		host    string
+		token   string
+		wantErr error
+	}{
+		{name: "should find the record", host: "example.com", token: "abc"},
+		{name: "should find the record of the parent of a www host", host: "www.example.com", token: "abc"},
+		{name: "should reject another token", host: "shop.example", token: "abc", wantErr: ErrTXTNotFound},
+		{name: "should reject a host without records", host: "missing.example", token: "abc", wantErr: ErrTXTNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := DNS(tt.host, tt.token); err != tt.wantErr {
+				t.Errorf("DNS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMeta(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`<html><head>` + MetaTag("abc") + `</head></html>`))
+	}))
+	defer server.Close()
+
+	allowPrivateURLs := configs.AllowPrivateURLs
+	defer func() { configs.AllowPrivateURLs = allowPrivateURLs }()
+	configs.AllowPrivateURLs = true
+
+	tests := []struct {
+		name    string
+		url     string
+		token   string
+		wantErr error
+	}{
+		{name: "should find the tag on the page", url: server.URL, token: "abc"},
+		{name: "should reject another token", url: server.URL, token: "abd", wantErr: ErrMetaNotFound},
+		{name: "should report a page not answering", url: server.URL + "/missing", token: "abc", wantErr: ErrPageUnreachable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Meta(tt.url, tt.token); err != tt.wantErr {
+				t.Errorf("Meta() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
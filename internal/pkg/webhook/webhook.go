@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"analytics-api/internal/pkg/request"
+)
+
+// SignatureHeader carry the HMAC-SHA256 of the body with the secret of the
+// endpoint, as sha256=<hex>, so receivers can check deliveries come from us
+const SignatureHeader = "X-Signature"
+
+// EventHeader name of the delivered event
+const EventHeader = "X-Event"
+
+// ErrInvalidURL ...
+var ErrInvalidURL = errors.New("webhook: url must be an absolute http or https url")
+
+// Client deliver events to webhook endpoints
+type Client struct {
+	HTTP *http.Client
+}
+
+// NewClient client refusing to deliver to private addresses, endpoints are
+// set by users
+func NewClient() *Client {
+	return &Client{
+		HTTP: request.NewPublicClient(10 * time.Second),
+	}
+}
+
+// ValidateURL ...
+func ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+// NewSecret random signing secret of an endpoint
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign ...
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Post send v as JSON to endpoint, any status other than 2xx is an error
+func (instance *Client) Post(endpoint, secret, event string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(SignatureHeader, Sign(secret, body))
+
+	res, err := instance.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook: %s replied %s", endpoint, res.Status)
+	}
+	return nil
+}
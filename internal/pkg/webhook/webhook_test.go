@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{name: "should accept https", url: "https://crm.example.com/hooks/1"},
+		{name: "should accept http", url: "http://localhost:8080/"},
+		{name: "should reject relative url", url: "/hooks/1", wantErr: true},
+		{name: "should reject other scheme", url: "ftp://example.com", wantErr: true},
+		{name: "should reject empty url", url: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateURL(tt.url); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPost(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "should deliver signed event", status: http.StatusNoContent},
+		{name: "should report failed delivery", status: http.StatusInternalServerError, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if string(body) != `{"id":"v1"}` {
+					t.Errorf("body = %s", body)
+				}
+				if got := r.Header.Get(SignatureHeader); got != Sign("secret", body) {
+					t.Errorf("signature = %v", got)
+				}
+				if got := r.Header.Get(EventHeader); got != "visitor.identified" {
+					t.Errorf("event = %v", got)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			client := NewClient()
+			client.HTTP = server.Client()
+			err := client.Post(server.URL, "secret", "visitor.identified", map[string]string{"id": "v1"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Post() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
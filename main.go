@@ -1,12 +1,49 @@
 package main
 
 import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+	// website timezones must resolve on hosts without a zoneinfo database
+	_ "time/tzdata"
+
 	"analytics-api/configs"
 	"analytics-api/db"
+	"analytics-api/internal/app/admin"
+	"analytics-api/internal/app/aggregate"
+	"analytics-api/internal/app/alert"
+	"analytics-api/internal/app/apikey"
+	"analytics-api/internal/app/archive"
+	"analytics-api/internal/app/audit"
+	"analytics-api/internal/app/auth"
+	"analytics-api/internal/app/benchmark"
+	"analytics-api/internal/app/capability"
+	"analytics-api/internal/app/crm"
+	"analytics-api/internal/app/firehose"
+	"analytics-api/internal/app/goal"
+	"analytics-api/internal/app/integration"
+	"analytics-api/internal/app/metric"
+	"analytics-api/internal/app/mobile"
+	"analytics-api/internal/app/reconcile"
+	"analytics-api/internal/app/reingest"
 	"analytics-api/internal/app/session"
+	"analytics-api/internal/app/stats"
+	"analytics-api/internal/app/tenant"
+	"analytics-api/internal/app/usage"
 	"analytics-api/internal/app/user"
+	"analytics-api/internal/app/visitor"
 	"analytics-api/internal/app/website"
+	"analytics-api/internal/pkg/encryption"
+	"analytics-api/internal/pkg/geodb"
+	"analytics-api/internal/pkg/keystore"
+	"analytics-api/internal/pkg/lifecycle"
+	"analytics-api/internal/pkg/mail"
+	"analytics-api/internal/pkg/maintenance"
 	"analytics-api/internal/pkg/middleware"
+	"analytics-api/internal/pkg/siem"
+	"analytics-api/internal/pkg/slo"
+	"analytics-api/internal/pkg/spam"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -14,37 +51,220 @@ import (
 
 func main() {
 	var err error
+	applyLogLevel(configs.Current())
+
+	// a wrong key would only fail once a tenant store seals its first event
+	if configs.DataMasterKey != "" {
+		if _, keyErr := encryption.ParseMasterKey(configs.DataMasterKey); keyErr != nil {
+			logrus.Fatalln("invalid DATA_MASTER_KEY ", keyErr)
+		}
+	}
+
+	// lite mode keeps every event in its embedded MongoDB and hosts no tenant
+	if configs.Lite.Enabled && (configs.MultiTenant || configs.UsesClickHouse()) {
+		logrus.Fatalln("LITE_MODE cannot be combined with MULTI_TENANT or ClickHouse storage")
+	}
 
 	db.NewMongo()
 
-	userErr := db.CreateUserCollection()
-	if userErr != nil {
-		logrus.Fatalln(userErr)
+	migrateErr := db.Migrate(configs.MongoDB.Client)
+	if migrateErr != nil {
+		logrus.Fatalln(migrateErr)
 	}
 
-	webErr := db.CreateWebsiteCollection()
-	if webErr != nil {
-		logrus.Fatalln(webErr)
+	if err := db.NewKeyStore(); err != nil {
+		logrus.Fatalln(err)
 	}
 
-	sessionErr := db.CreateSessionCollection()
-	if sessionErr != nil {
-		logrus.Fatalln(sessionErr)
+	// the embedded spam list applies until the feed is fetched
+	if configs.Spam.FeedURL != "" && configs.Spam.Refresh > 0 {
+		go spam.Run(configs.Spam.FeedURL, configs.Spam.Refresh)
 	}
 
-	db.NewRedis()
+	if configs.UsesClickHouse() {
+		db.NewClickHouse()
+	}
 
-	r := gin.Default()
-	initializeRoutes(r)
+	// every tenant exports to the same SIEM, its events carry the tenant id
+	if configs.SIEM.URL != "" {
+		siemErr := siem.Start(configs.SIEM.URL, configs.SIEM.Format, configs.SIEM.Token)
+		if siemErr != nil {
+			logrus.Fatalln(siemErr)
+		}
+	}
+
+	// every tenant sends emails through the same server
+	if configs.Mail.URL != "" {
+		mailErr := mail.Start(configs.Mail.URL, configs.Mail.From)
+		if mailErr != nil {
+			logrus.Fatalln(mailErr)
+		}
+	}
+
+	objectives, sloErr := slo.ParseObjectives(configs.SLO.Objectives)
+	if sloErr != nil {
+		logrus.Fatalln(sloErr)
+	}
+	slo.Configure(objectives)
+
+	var handler http.Handler
+	var router *tenant.Router
+	if configs.MultiTenant {
+		tenantErr := db.CreateTenantCollection()
+		if tenantErr != nil {
+			logrus.Fatalln(tenantErr)
+		}
+
+		control := gin.Default()
+		trustProxies(control)
+		admin.NewHTTPDelivery().InitRoutes(control.Group("/"))
+		tenant.NewHTTPDelivery(db.DefaultStore()).InitRoutes(control.Group("/"))
+		router = tenant.NewRouter(control, func(store *db.Store) http.Handler {
+			return newEngine(store)
+		})
+		handler = router
+		go configs.Watch(5*time.Second, applyLogLevel)
+	} else {
+		store := db.DefaultStore()
+		r := newEngine(store)
+		admin.NewHTTPDelivery().InitRoutes(r.Group("/"))
+		handler = r
+
+		// tenants retry their queued pushes with analyticsctl crm retry
+		go crm.RunRetry(db.DefaultStore(), time.Minute)
+		// and archive their events with analyticsctl archive run
+		if configs.ArchiveEnabled() {
+			go archive.RunArchive(db.DefaultStore())
+		}
+		// and purge the data of deleted websites with analyticsctl website purge
+		go website.RunPurge(db.DefaultStore(), time.Minute)
+		// and send their install notices with analyticsctl website install-check
+		go website.RunInstallCheck(db.DefaultStore(), mobile.NewUseCase(db.DefaultStore()), time.Minute)
+		// and empty their goal trash with analyticsctl goal purge
+		go goal.RunPurge(db.DefaultStore(), time.Minute)
+		// and send their quota alerts with analyticsctl usage alerts, run
+		// without a quota too since a reload may set one
+		go usage.RunAlerts(db.DefaultStore(), mobile.NewUseCase(db.DefaultStore()), time.Minute)
+		// and compute their benchmarks with analyticsctl benchmark compute
+		go benchmark.RunBenchmarks(db.DefaultStore())
+		// and evaluate their alert templates with analyticsctl alert evaluate
+		go alert.RunEvaluate(db.DefaultStore(), mobile.NewUseCase(db.DefaultStore()), time.Minute)
+		// and replace their corrected events with analyticsctl reingest run
+		go reingest.RunReingest(db.DefaultStore(), time.Minute)
+
+		// tenants keep their allow-list in their tenant document
+		go configs.Watch(5*time.Second, func(aTunables *configs.Tunables) {
+			applyLogLevel(aTunables)
+			if err := store.AllowList.Set(aTunables.AllowedCIDRs); err != nil {
+				logrus.Error("reload ALLOWED_CIDRS error ", err)
+			}
+		})
+	}
+
+	// the server listens from the start, readiness fails until the warmup ends
+	go lifecycle.Warmup(warmupSteps(router))
 
 	logrus.Info("starting HTTP server...")
-	err = r.Run(":" + configs.Port)
-	if err != nil {
+	err = lifecycle.Serve(":"+configs.Port, lifecycle.Handler(handler), configs.Lifecycle.LameDuck, configs.Lifecycle.DrainTimeout)
+	if err != nil && err != http.ErrServerClosed {
 		logrus.Fatalln(err)
 	}
 }
 
-func initializeRoutes(r *gin.Engine) {
+// warmupStepPings concurrent pings opening the connections of a pool
+const warmupStepPings = 10
+
+// warmupSteps connect the pools of the databases, read the geo database and,
+// in multi tenant mode, build the routes of the tenants of router
+func warmupSteps(router *tenant.Router) []lifecycle.Step {
+	steps := []lifecycle.Step{
+		{Name: "mongo", Required: true, Run: func() error {
+			return concurrently(warmupStepPings, func() error {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				return configs.MongoDB.Client.Client().Ping(ctx, nil)
+			})
+		}},
+	}
+	if configs.KeyStore.Kind == keystore.KindRedis {
+		steps = append(steps, lifecycle.Step{Name: "redis", Required: true, Run: func() error {
+			return concurrently(warmupStepPings, func() error {
+				return configs.Redis.Client.Ping().Err()
+			})
+		}})
+	}
+	if configs.UsesClickHouse() {
+		steps = append(steps, lifecycle.Step{Name: "clickhouse", Required: true, Run: func() error {
+			return configs.ClickHouse.Client.Exec("SELECT 1", nil)
+		}})
+	}
+	// the collector opens the geo database on every request, a first lookup
+	// brings the file into the page cache
+	steps = append(steps, lifecycle.Step{Name: "geoip", Run: func() error {
+		geoDB, err := geodb.Open(configs.Current().PathGeoDB)
+		if err != nil {
+			return err
+		}
+		defer geoDB.Close()
+		_, err = geoDB.City(net.ParseIP("8.8.8.8"))
+		return err
+	}})
+	if router != nil {
+		steps = append(steps, lifecycle.Step{Name: "tenants", Run: func() error {
+			count, err := router.Warm()
+			logrus.Info("warmup built the routes of ", count, " tenants")
+			return err
+		}})
+	}
+	return steps
+}
+
+// concurrently run fn n times at once, return the first error
+func concurrently(n int, fn func() error) error {
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			errs <- fn()
+		}()
+	}
+	var err error
+	for i := 0; i < n; i++ {
+		if anErr := <-errs; anErr != nil && err == nil {
+			err = anErr
+		}
+	}
+	return err
+}
+
+// applyLogLevel set the level of logrus to the one of aTunables
+func applyLogLevel(aTunables *configs.Tunables) {
+	level, err := logrus.ParseLevel(aTunables.LogLevel)
+	if err != nil {
+		logrus.Error("invalid LOG_LEVEL ", err)
+		return
+	}
+	logrus.SetLevel(level)
+}
+
+// newEngine build all routes bound to store, and replay to them the collector
+// batches spooled during maintenance
+func newEngine(store *db.Store) *gin.Engine {
+	r := gin.Default()
+	trustProxies(r)
+	initializeRoutes(r, store)
+	go maintenance.RunReplay(store.Key(maintenance.SpoolKey), r, 30*time.Second)
+	return r
+}
+
+// trustProxies let engine read the client address from the forwarding
+// headers of TRUSTED_PROXIES only, the allow-list keys on it
+func trustProxies(engine *gin.Engine) {
+	if err := engine.SetTrustedProxies(configs.TrustedProxies); err != nil {
+		logrus.Fatalln("invalid TRUSTED_PROXIES ", err)
+	}
+}
+
+func initializeRoutes(r *gin.Engine, store *db.Store) {
 	// Register health check handler
 	r.GET("/", func(c *gin.Context) {
 		c.HTML(200, "home.html", gin.H{})
@@ -56,14 +276,96 @@ func initializeRoutes(r *gin.Engine) {
 	r.Static("/js", "./web/static/js")
 	r.Static("/assets", "./web/static/assets")
 	r.Static("/css", "./web/static/css")
+	// exports and heavy reports wait while the collector is slow
+	r.Use(middleware.LatencyMiddleware(configs.SLO.Shed,
+		[]string{"/session/receive", "/segment/v1/:method"},
+		[]string{"/visitor/:website_id", "/aggregate/:website_id", "/stats/compare", "/stats/:website_id/breakdown",
+			"/stats/:website_id/pages/breakdown", "/stats/:website_id/heat-table", "/share/:token/breakdown",
+			"/embed/breakdown", "/embed/heat-table"},
+	))
+	r.Use(middleware.LocaleMiddleware(user.NewUseCase(store)))
+	if monitor := selfMonitor(store); monitor != nil {
+		r.Use(middleware.UsageMiddleware(monitor))
+	}
 	r.Use(middleware.CORSMiddleware())
+	r.Use(middleware.AllowListMiddleware(store.AllowList))
+	r.Use(middleware.PermissionMiddleware(user.NewUseCase(store)))
+	// sign in keeps working during maintenance and the collector batches are
+	// spooled for newEngine to replay, website delete is a GET
+	r.Use(middleware.MaintenanceMiddleware(
+		[]string{"/signin", "/signin/2fa", "/auth/refresh", "/admin/maintenance"},
+		[]string{"/website/delete/:website_id"},
+		[]string{"/session/receive", "/segment/v1/:method"},
+		store.Key(maintenance.SpoolKey),
+	))
+	// the first api key of a user is created before any request can be signed
+	r.Use(middleware.SignatureMiddleware(apikey.NewUseCase(store), store.SignedWrites,
+		[]string{"/api-keys"},
+		[]string{"/website/delete/:website_id"},
+	))
 
 	g := r.Group("/")
-	sessionDelivery := session.NewHTTPDelivery()
-	userDelivery := user.NewHTTPDelivery()
-	websiteDelivery := website.NewHTTPDelivery()
+	authDelivery := auth.NewHTTPDelivery(store, user.NewUseCase(store))
+	sessionDelivery := session.NewHTTPDelivery(store)
+	userDelivery := user.NewHTTPDelivery(store)
+	websiteDelivery := website.NewHTTPDelivery(store)
+	mobileDelivery := mobile.NewHTTPDelivery(store)
+	statsDelivery := stats.NewHTTPDelivery(store)
+	goalDelivery := goal.NewHTTPDelivery(store)
+	integrationDelivery := integration.NewHTTPDelivery(store)
+	crmDelivery := crm.NewHTTPDelivery(store)
+	visitorDelivery := visitor.NewHTTPDelivery(store)
+	firehoseDelivery := firehose.NewHTTPDelivery(store)
+	archiveDelivery := archive.NewHTTPDelivery(store)
+	apiKeyDelivery := apikey.NewHTTPDelivery(store)
+	reconcileDelivery := reconcile.NewHTTPDelivery(store)
+	usageDelivery := usage.NewHTTPDelivery(store)
+	auditDelivery := audit.NewHTTPDelivery(store)
+	aggregateDelivery := aggregate.NewHTTPDelivery(store)
+	alertDelivery := alert.NewHTTPDelivery(store)
+	metricDelivery := metric.NewHTTPDelivery(store)
+	reingestDelivery := reingest.NewHTTPDelivery(store)
+	capabilityDelivery := capability.NewHTTPDelivery(store, r.Routes)
 
+	authDelivery.InitRoutes(g)
 	sessionDelivery.InitRoutes(g)
 	userDelivery.InitRoutes(g)
 	websiteDelivery.InitRoutes(g)
+	mobileDelivery.InitRoutes(g)
+	statsDelivery.InitRoutes(g)
+	goalDelivery.InitRoutes(g)
+	integrationDelivery.InitRoutes(g)
+	crmDelivery.InitRoutes(g)
+	visitorDelivery.InitRoutes(g)
+	firehoseDelivery.InitRoutes(g)
+	archiveDelivery.InitRoutes(g)
+	apiKeyDelivery.InitRoutes(g)
+	reconcileDelivery.InitRoutes(g)
+	usageDelivery.InitRoutes(g)
+	auditDelivery.InitRoutes(g)
+	aggregateDelivery.InitRoutes(g)
+	alertDelivery.InitRoutes(g)
+	metricDelivery.InitRoutes(g)
+	reingestDelivery.InitRoutes(g)
+	capabilityDelivery.InitRoutes(g)
+}
+
+// selfMonitor recorder of the use of the dashboard in the internal website of
+// the self monitoring owner, added to the owner on first start. Nil when self
+// monitoring is off, and for tenants whose users are not the operators
+func selfMonitor(store *db.Store) *session.SelfMonitor {
+	if configs.SelfMonitoringOwner == "" || store.TenantID != "" {
+		return nil
+	}
+	userID, err := user.NewUseCase(store).FindUserID(configs.SelfMonitoringOwner)
+	if err != nil {
+		logrus.Error("self monitoring owner not found, sign up ", configs.SelfMonitoringOwner, " first: ", err)
+		return nil
+	}
+	websiteID, err := website.NewUseCase(store).ProvisionInternal(userID)
+	if err != nil {
+		logrus.Error("provision internal website error ", err)
+		return nil
+	}
+	return session.NewSelfMonitor(store, userID, websiteID)
 }
@@ -1,5 +1,7 @@
 window.recorder = {
+	host: document.currentScript ? new URL(document.currentScript.src).origin : 'https://theodoiweb.fly.dev',
 	events: [],
+	features: { recording: true, web_vitals: false, outbound_links: false, page_meta: false, engagement: false, forms: false, canonical_urls: false },
 	rrweb: undefined,
 	runner: undefined,
 	session: {
@@ -17,6 +19,7 @@ window.recorder = {
 			if (session) return JSON.parse(session);
 			session = {
 				session_id: window.recorder.session.genID(64),
+				first: true,
 			};
 			window.sessionStorage.setItem('rrweb', JSON.stringify(session));
 			return session;
@@ -29,10 +32,62 @@ window.recorder = {
 			window.sessionStorage.removeItem('rrweb')
 		}
 	},
+	ads: {
+		// click identifiers of the landing page, sent only once the visitor consents
+		capture() {
+			const params = new URLSearchParams(window.location.search);
+			const clicks = JSON.parse(window.sessionStorage.getItem('rrweb_clicks') || '{}');
+			if (params.get('gclid')) clicks.gclid = params.get('gclid');
+			if (params.get('fbclid')) clicks.fbc = 'fb.1.' + Date.now() + '.' + params.get('fbclid');
+			window.sessionStorage.setItem('rrweb_clicks', JSON.stringify(clicks));
+		},
+		get() {
+			const ad = window.sessionStorage.getItem('rrweb_ad');
+			return ad ? JSON.parse(ad) : undefined;
+		},
+	},
+	// firstTouch landing page, referrer and campaign of the first visit, kept
+	// across sessions until the visitor is identified
+	firstTouch() {
+		const stored = window.localStorage.getItem('rrweb_first_touch');
+		if (stored) return JSON.parse(stored);
+		const params = new URLSearchParams(window.location.search);
+		const touch = { landing_page: window.location.href, referrer: document.referrer, timestamp: Date.now() };
+		['utm_source', 'utm_medium', 'utm_campaign', 'utm_term', 'utm_content'].forEach(name => {
+			if (params.get(name)) touch[name] = params.get(name);
+		});
+		window.localStorage.setItem('rrweb_first_touch', JSON.stringify(touch));
+		return touch;
+	},
+	// identify name the visitor, once signed up or logged in, with the id the
+	// site knows them by
+	identify: function (visitorID) {
+		window.recorder.track('identify', { visitor_id: String(visitorID), first_touch: window.recorder.firstTouch() });
+		return window.recorder;
+	},
+	// consentAds let submitted forms be forwarded to ad platforms, identifiers
+	// are normalized and hashed with SHA-256 here so raw values are never sent
+	consentAds: function ({ email, phone } = {}) {
+		const sha256 = value => crypto.subtle.digest('SHA-256', new TextEncoder().encode(value)).then(digest =>
+			Array.from(new Uint8Array(digest)).map(b => b.toString(16).padStart(2, '0')).join(''));
+		const ad = JSON.parse(window.sessionStorage.getItem('rrweb_clicks') || '{}');
+		const hashes = [];
+		if (email) hashes.push(sha256(String(email).trim().toLowerCase()).then(h => { ad.em = h; }));
+		if (phone) {
+			const digits = String(phone).replace(/\D/g, '');
+			hashes.push(sha256(digits).then(h => { ad.ph = h; }));
+			hashes.push(sha256('+' + digits).then(h => { ad.ph_e164 = h; }));
+		}
+		return Promise.all(hashes).then(() => {
+			window.sessionStorage.setItem('rrweb_ad', JSON.stringify(ad));
+			return window.recorder;
+		});
+	},
 	setSession: function (user_id) {
 		const session = window.recorder.session.get();
 		session.user_id = user_id;
 		session.session_id = window.recorder.session.genID(64);
+		session.first = true;
 		window.recorder.session.receive(session)
 		return window.recorder;
 	},
@@ -40,39 +95,196 @@ window.recorder = {
 		const session = window.recorder.session.get();
 		session.website_id = website_id;
 		window.recorder.session.receive(session)
+		window.recorder.boot();
 		return window.recorder;
 	},
+	loadConfig() {
+		const session = window.recorder.session.get();
+		return fetch(window.recorder.host + '/website/config/' + session.website_id)
+			.then(res => res.ok ? res.json() : {})
+			.then(config => {
+				window.recorder.features = Object.assign({}, window.recorder.features, config.features);
+				window.recorder.aggregateOnly = !!config.aggregate_only;
+				return window.recorder.features;
+			})
+			.catch(() => window.recorder.features);
+	},
+	// canonical url of the page from its link rel=canonical, empty without one
+	canonical() {
+		const link = document.querySelector('link[rel="canonical"][href]');
+		return link && /^https?:/.test(link.href) ? link.href : '';
+	},
+	// pagePath path pages are counted under, the canonical one when the website
+	// groups duplicate urls
+	pagePath() {
+		const canonical = window.recorder.features.canonical_urls && window.recorder.canonical();
+		return canonical ? new URL(canonical).pathname : window.location.pathname;
+	},
+	// track send a custom event, like track('cart_abandon', { form_id: 'cart' })
+	track(tag, payload) {
+		if (!window.recorder.rrweb) return;
+		window.recorder.rrweb.record.addCustomEvent(tag, Object.assign({ path: window.location.pathname }, payload));
+	},
 	stop() {
 		clearInterval(window.recorder.runner);
 	},
 	start() {
 		window.recorder.runner = setInterval(function receive() {
 			const session = window.recorder.session.get();
-			fetch('https://theodoiweb.fly.dev/session/receive', {
+			const events = window.recorder.events;
+			window.recorder.events = []; // cleans-up events for next cycle
+			// the server counts the session once, with its first batch of events
+			if (session.first && events.length) window.recorder.session.receive({ first: false });
+			// events serialize the same alone and inside the body, the server checks the bytes it got
+			window.recorder.checksum(JSON.stringify(events)).then(checksum => fetch(window.recorder.host + '/session/receive', {
 				method: 'POST',
 				headers: { 'Content-Type': 'application/json' },
-				body: JSON.stringify(Object.assign({}, { events: window.recorder.events }, session)),
-			});
-			window.recorder.events = []; // cleans-up events for next cycle
+				body: JSON.stringify(Object.assign({}, { events: events, sent_at: Date.now(), event_count: events.length, checksum: checksum, host_name: window.location.hostname, referrer: document.referrer }, session)),
+			}));
 		}, 5 * 1000);
 	},
+	// checksum hex SHA-256 of text, empty where WebCrypto is missing like on plain http
+	checksum(text) {
+		if (!window.crypto || !window.crypto.subtle || !window.TextEncoder) return Promise.resolve('');
+		return window.crypto.subtle.digest('SHA-256', new TextEncoder().encode(text))
+			.then(digest => Array.from(new Uint8Array(digest)).map(b => b.toString(16).padStart(2, '0')).join(''))
+			.catch(() => '');
+	},
 	close() {
 		clearInterval();
 		window.recorder.session.clear();
+	},
+	boot() {
+		if (window.recorder.booted) return;
+		window.recorder.booted = true;
+		window.recorder.ads.capture();
+		window.recorder.firstTouch();
+		window.recorder.loadConfig().then(features => {
+			if (!features.recording) return Promise.reject();
+		}).then(() => new Promise((resolve, reject) => {
+			const script = document.createElement('script');
+			script.src = 'https://cdn.jsdelivr.net/npm/rrweb@latest/dist/rrweb.min.js';
+			script.addEventListener('load', resolve);
+			script.addEventListener('error', e => reject(e.error));
+			document.head.appendChild(script);
+		})).then(() => {
+			window.recorder.rrweb = rrweb;
+			rrweb.record({
+				emit(event) {
+					// aggregate-only websites only count page loads and custom events
+					if (window.recorder.aggregateOnly && event.type !== 4 && event.type !== 5) return;
+					if (event.type === 4 && window.recorder.features.canonical_urls) {
+						const canonical = window.recorder.canonical();
+						if (canonical) event.data.canonical = canonical;
+					}
+					window.recorder.events.push(event);
+				}
+			});
+			window.recorder.trackOptional();
+			window.recorder.start();
+		}).catch(console.err);
+	},
+	trackOptional() {
+		const features = window.recorder.features;
+		if (features.page_meta) {
+			const meta = (...names) => {
+				for (const name of names) {
+					const tag = document.querySelector(`meta[name="${name}"], meta[property="${name}"]`);
+					if (tag && tag.content) return tag.content.trim().slice(0, 200);
+				}
+				return '';
+			};
+			rrweb.record.addCustomEvent('page_meta', {
+				href: window.location.href,
+				author: meta('author', 'article:author'),
+				category: meta('article:section', 'category'),
+				published_at: meta('article:published_time', 'date'),
+			});
+		}
+		if (features.engagement) {
+			// engaged time only counts while the page is visible and the visitor
+			// did something in the last 30 seconds
+			const viewID = window.recorder.session.genID(16);
+			let lastActive = Date.now(), engaged = 0, depth = 0, sentDepth = 0, ticks = 0;
+			const active = () => { lastActive = Date.now(); };
+			['scroll', 'mousemove', 'keydown', 'touchstart'].forEach(name => document.addEventListener(name, active, { passive: true }));
+			const heartbeat = () => {
+				if (!engaged && depth === sentDepth) return;
+				rrweb.record.addCustomEvent('heartbeat', { path: window.recorder.pagePath(), view_id: viewID, engaged_ms: engaged, scroll_depth: depth });
+				engaged = 0;
+				sentDepth = depth;
+			};
+			setInterval(() => {
+				const height = document.documentElement.scrollHeight;
+				if (height) depth = Math.max(depth, Math.min(100, Math.round(100 * (window.scrollY + window.innerHeight) / height)));
+				if (document.visibilityState === 'visible' && Date.now() - lastActive < 30 * 1000) engaged += 1000;
+				if (++ticks % 15 === 0) heartbeat();
+			}, 1000);
+			document.addEventListener('visibilitychange', () => {
+				if (document.visibilityState === 'hidden') heartbeat();
+			});
+		}
+		if (features.forms) {
+			// only which form was started or submitted is sent, never field values
+			const formID = form => form.id || form.name || form.getAttribute('action') || 'form-' + Array.prototype.indexOf.call(document.forms, form);
+			const started = {};
+			document.addEventListener('focusin', e => {
+				const form = e.target.form;
+				if (!form || started[formID(form)] !== undefined) return;
+				started[formID(form)] = false;
+				window.recorder.track('form_start', { form_id: formID(form) });
+			});
+			// fields are identified by name or position with the time spent and
+			// whether an error was shown, never their value
+			const fieldTimes = new WeakMap(), fieldErrors = new WeakSet();
+			const field = el => el.form && el.type !== 'hidden' && el.type !== 'submit' && el.type !== 'button';
+			document.addEventListener('focusin', e => {
+				if (field(e.target)) fieldTimes.set(e.target, Date.now());
+			});
+			document.addEventListener('invalid', e => {
+				if (field(e.target)) fieldErrors.add(e.target);
+			}, true);
+			document.addEventListener('focusout', e => {
+				const el = e.target;
+				if (!field(el) || !fieldTimes.has(el)) return;
+				const index = Array.prototype.indexOf.call(el.form.elements, el);
+				window.recorder.track('form_field', {
+					form_id: formID(el.form),
+					field: el.name || el.id || 'field-' + index,
+					index: index,
+					time_ms: Date.now() - fieldTimes.get(el),
+					error: fieldErrors.has(el) || el.getAttribute('aria-invalid') === 'true',
+				});
+				fieldTimes.delete(el);
+				fieldErrors.delete(el);
+			});
+			document.addEventListener('submit', e => {
+				started[formID(e.target)] = true;
+				const ad = window.recorder.ads.get();
+				window.recorder.track('form_submit', ad ? { form_id: formID(e.target), ad: ad } : { form_id: formID(e.target) });
+			});
+			document.addEventListener('visibilitychange', () => {
+				if (document.visibilityState !== 'hidden') return;
+				Object.keys(started).filter(id => !started[id]).forEach(id => {
+					started[id] = true;
+					window.recorder.track('form_abandon', { form_id: id });
+				});
+			});
+		}
+		if (features.outbound_links) {
+			document.addEventListener('click', e => {
+				const link = e.target.closest && e.target.closest('a[href]');
+				if (link && link.host && link.host !== window.location.host) {
+					rrweb.record.addCustomEvent('outbound_link', { href: link.href });
+				}
+			});
+		}
+		if (features.web_vitals && window.PerformanceObserver) {
+			new PerformanceObserver(list => {
+				list.getEntries().forEach(entry => {
+					rrweb.record.addCustomEvent('web_vitals', { name: entry.entryType, value: entry.startTime });
+				});
+			}).observe({ type: 'largest-contentful-paint', buffered: true });
+		}
 	}
 };
-new Promise((resolve, reject) => {
-	const script = document.createElement('script');
-	script.src = 'https://cdn.jsdelivr.net/npm/rrweb@latest/dist/rrweb.min.js';
-	script.addEventListener('load', resolve);
-	script.addEventListener('error', e => reject(e.error));
-	document.head.appendChild(script);
-}).then(() => {
-	window.recorder.rrweb = rrweb;
-	rrweb.record({
-		emit(event) {
-			window.recorder.events.push(event);
-		}
-	});
-	window.recorder.start();
-}).catch(console.err);